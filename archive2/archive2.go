@@ -5,7 +5,9 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"sort"
 	"sync"
 
 	"github.com/davecgh/go-spew/spew"
@@ -36,6 +38,8 @@ type Archive2 struct {
 	// Mutex so ElevationScans can be concurrently updated, e.g. in the case of loading
 	// chunks in parallel
 	mtx sync.Mutex
+	// azimuth numbers already added to each elevation scan, to reject duplicates
+	seenRadials map[int]map[uint16]bool
 }
 
 func (ar2 *Archive2) LoadLDMRecord(reader io.Reader) (*LoadedLDMRecord, error) {
@@ -50,8 +54,9 @@ func (ar2 *Archive2) LoadLDMRecord(reader io.Reader) (*LoadedLDMRecord, error) {
 	// As the control word contains a negative size under some circumstances,
 	// the absolute value of the control word must be used for determining
 	// the size of the block.
-	if ldm.Size < 0 {
-		ldm.Size = -ldm.Size
+	ldm.Size, err = LDMRecordSize(ldm.Size)
+	if err != nil {
+		return nil, err
 	}
 
 	logrus.Debugf("---------------- LDM Compressed Record (%d bytes)----------------", ldm.Size)
@@ -86,6 +91,9 @@ func (ar2 *Archive2) LoadLDMRecord(reader io.Reader) (*LoadedLDMRecord, error) {
 		}).Tracef("== Message %d", header.MessageType)
 
 		switch header.MessageType {
+		case 1:
+			// legacy radials, from volumes older than message 31
+			return loadedRecord, ErrUnsupportedVersion
 		case 2:
 			loadedRecord.M2 = &Message2{}
 			binary.Read(bzipReader, binary.BigEndian, loadedRecord.M2)
@@ -103,17 +111,25 @@ func (ar2 *Archive2) LoadLDMRecord(reader io.Reader) (*LoadedLDMRecord, error) {
 				sz = uint32(header.NumMessageSegments)<<16 | uint32(header.MessageSegmentNum)
 			}
 
+			// checked before converting, as segmented sizes overflow
+			if sz < MessageHeaderSize/2 || sz > MaxMessage31Size/2 {
+				return loadedRecord, fmt.Errorf("ar2: message 31 size %d half-words out of range", sz)
+			}
 			// convert to byte count
 			sz *= 2
 			// minus size of header
-			sz -= 16
+			sz -= MessageHeaderSize
 
-			data := make([]byte, sz)
+			// the moments are copied out of the message, so its buffer can
+			// be reused for the next one
+			data := getBytes(int(sz))
 			_, err := io.ReadFull(bzipReader, data)
 			if err != nil {
+				putBytes(data)
 				return loadedRecord, err
 			}
 			m31, err := NewMessage31(bytes.NewReader(data))
+			putBytes(data)
 			if err != nil {
 				return loadedRecord, err
 			}
@@ -133,17 +149,131 @@ func (ar2 *Archive2) String() string {
 	return fmt.Sprintf("%s\n%s", ar2.VolumeHeader, ar2.RadarStatus)
 }
 
-func (ar2 *Archive2) AddFromLDMRecord(loadedRecord *LoadedLDMRecord) {
+// SweepUpdate describes how a single elevation scan changed when a record was added
+type SweepUpdate struct {
+	ElevationNumber int
+	// Added is the number of new radials added to the sweep
+	Added int
+	// Duplicates is the number of radials rejected because the sweep already had
+	// a radial with the same azimuth number
+	Duplicates int
+	// Radials is the total number of radials now in the sweep
+	Radials int
+	// ExpectedRadials is the number of radials in a complete sweep, based on the
+	// azimuthal resolution
+	ExpectedRadials int
+	// Started and Ended are set when the record held the sweep's first or last
+	// radial, by radial status. Out of order records can end a sweep before
+	// all of its radials have arrived.
+	Started bool
+	Ended   bool
+}
+
+// Complete returns the fraction (0-1) of the sweep that has been received
+func (s SweepUpdate) Complete() float64 {
+	if s.ExpectedRadials == 0 {
+		return 0
+	}
+	return math.Min(1, float64(s.Radials)/float64(s.ExpectedRadials))
+}
+
+// AddResult describes what AddFromLDMRecord changed in the volume, so callers
+// receiving records incrementally can report progress.
+type AddResult struct {
+	// Sweeps touched by the record, ordered by elevation number
+	Sweeps             []SweepUpdate
+	RadialsAdded       int
+	DuplicatesRejected int
+	// RadarStatusSet and RadarPerformanceSet report whether the record provided
+	// the volume's first Message 2 and Message 3
+	RadarStatusSet      bool
+	RadarPerformanceSet bool
+	// VolumeEnded is set when the record held the last radial of the volume
+	VolumeEnded bool
+}
+
+// AddFromLDMRecord adds the messages in the record to the volume. Radials that
+// are already present (same elevation and azimuth number) are rejected.
+func (ar2 *Archive2) AddFromLDMRecord(loadedRecord *LoadedLDMRecord) AddResult {
+	ar2.mtx.Lock()
+	defer ar2.mtx.Unlock()
+
+	result := AddResult{}
 	if loadedRecord.M2 != nil && ar2.RadarStatus == nil {
 		// keep a reference around
 		ar2.RadarStatus = loadedRecord.M2
+		result.RadarStatusSet = true
 	}
 	if loadedRecord.M3 != nil && ar2.RadarPerformance == nil {
 		ar2.RadarPerformance = loadedRecord.M3
+		result.RadarPerformanceSet = true
 	}
+	if ar2.seenRadials == nil {
+		ar2.seenRadials = map[int]map[uint16]bool{}
+		for elv, radials := range ar2.ElevationScans {
+			ar2.seenRadials[elv] = map[uint16]bool{}
+			for _, m31 := range radials {
+				ar2.seenRadials[elv][m31.Header.AzimuthNumber] = true
+			}
+		}
+	}
+
+	updates := map[int]*SweepUpdate{}
 	for _, m31 := range loadedRecord.M31s {
-		ar2.ElevationScans[int(m31.Header.ElevationNumber)] = append(ar2.ElevationScans[int(m31.Header.ElevationNumber)], m31)
+		elv := int(m31.Header.ElevationNumber)
+		update, ok := updates[elv]
+		if !ok {
+			update = &SweepUpdate{ElevationNumber: elv}
+			updates[elv] = update
+		}
+		if ar2.seenRadials[elv] == nil {
+			ar2.seenRadials[elv] = map[uint16]bool{}
+		}
+
+		if ar2.seenRadials[elv][m31.Header.AzimuthNumber] {
+			update.Duplicates++
+			result.DuplicatesRejected++
+			continue
+		}
+		ar2.seenRadials[elv][m31.Header.AzimuthNumber] = true
+		update.Started = update.Started || m31.Header.StartsElevation()
+		update.Ended = update.Ended || m31.Header.EndsElevation()
+		result.VolumeEnded = result.VolumeEnded || m31.Header.EndsVolume()
+		ar2.ElevationScans[elv] = append(ar2.ElevationScans[elv], m31)
+		update.Added++
+		result.RadialsAdded++
 	}
+
+	for elv, update := range updates {
+		radials := ar2.ElevationScans[elv]
+		update.Radials = len(radials)
+		if len(radials) > 0 {
+			update.ExpectedRadials = int(360 / radials[0].Header.AzimuthResolutionSpacing())
+		}
+		result.Sweeps = append(result.Sweeps, *update)
+	}
+	sort.Slice(result.Sweeps, func(i, j int) bool {
+		return result.Sweeps[i].ElevationNumber < result.Sweeps[j].ElevationNumber
+	})
+
+	return result
+}
+
+// AvailableMoments returns the moments (in MomentNames order) that hold data in
+// at least one radial of the elevation scan. Moments that are missing or are
+// entirely below threshold are omitted, so callers only offer products that
+// will render something.
+func (ar2 *Archive2) AvailableMoments(elevation int) []string {
+	available := []string{}
+	for _, name := range MomentNames {
+		for _, m31 := range ar2.ElevationScans[elevation] {
+			if m31.Moment(name).HasData() {
+				available = append(available, name)
+				break
+			}
+		}
+	}
+	return available
 }
 
 // Extract returns a new Archive2 from the provided reader
@@ -169,8 +299,11 @@ func Extract(reader io.Reader) (*Archive2, error) {
 	binary.Read(reader, binary.BigEndian, &ar2.VolumeHeader)
 
 	logrus.Debug(ar2.VolumeHeader)
+	if err := ar2.VolumeHeader.CheckVersion(); err != nil {
+		return nil, err
+	}
 
-	offset := 24
+	offset := volumeHeaderSize
 
 	// ------------------------------ LDM Records ------------------------------
 
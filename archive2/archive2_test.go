@@ -1,8 +1,17 @@
 package archive2
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
 	"os"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/dsnet/compress/bzip2"
 )
 
 func TestExtract(t *testing.T) {
@@ -29,3 +38,699 @@ func TestRawDatasets(t *testing.T) {
 		Extract(tamu)
 	}
 }
+
+func TestAddFromLDMRecord(t *testing.T) {
+	ar2 := &Archive2{ElevationScans: map[int][]*Message31{}}
+
+	record := func(elv uint8, azimuths ...uint16) *LoadedLDMRecord {
+		r := &LoadedLDMRecord{}
+		for _, az := range azimuths {
+			m31 := &Message31{}
+			m31.Header.ElevationNumber = elv
+			m31.Header.AzimuthNumber = az
+			m31.Header.AzimuthResolutionSpacingCode = 1
+			m31.Header.RadialStatus = RadialStatusIntermediateRadialData
+			r.M31s = append(r.M31s, m31)
+		}
+		return r
+	}
+
+	res := ar2.AddFromLDMRecord(record(1, 1, 2, 3))
+	if res.RadialsAdded != 3 || len(res.Sweeps) != 1 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+
+	res = ar2.AddFromLDMRecord(record(1, 3, 4))
+	if res.RadialsAdded != 1 || res.DuplicatesRejected != 1 {
+		t.Fatalf("expected 1 added and 1 duplicate, got %+v", res)
+	}
+	sweep := res.Sweeps[0]
+	if sweep.Radials != 4 || sweep.ExpectedRadials != 720 {
+		t.Fatalf("unexpected sweep update %+v", sweep)
+	}
+	if c := sweep.Complete(); c != 4.0/720 {
+		t.Errorf("expected %v complete, got %v", 4.0/720, c)
+	}
+	if len(ar2.ElevationScans[1]) != 4 {
+		t.Errorf("expected 4 radials in elevation 1, got %d", len(ar2.ElevationScans[1]))
+	}
+	if sweep.Started || sweep.Ended || res.VolumeEnded {
+		t.Errorf("boundaries set by intermediate radials: %+v", res)
+	}
+
+	last := record(1, 5)
+	last.M31s[0].Header.RadialStatus = RadialStatusEndOfVolumeScan
+	res = ar2.AddFromLDMRecord(last)
+	if !res.Sweeps[0].Ended || res.Sweeps[0].Started || !res.VolumeEnded {
+		t.Errorf("expected the end of the sweep and volume, got %+v", res)
+	}
+}
+
+func TestIndexedAzimuth(t *testing.T) {
+	tests := []struct {
+		spacing, mode uint8
+		azimuth, want float32
+	}{
+		{1, 0, 0.31, 0.31},
+		{1, 50, 0.31, 0.25},
+		{1, 50, 0.74, 0.75},
+		{1, 50, 359.99, 359.75},
+		{1, 50, 0.01, 0.25},
+		{2, 100, 358.8, 358.5},
+		{2, 100, 359.7, 359.5},
+		{2, 100, 0.2, 0.5},
+	}
+	for _, tt := range tests {
+		h := Message31Header{AzimuthAngle: tt.azimuth, AzimuthResolutionSpacingCode: tt.spacing, AzimuthIndexingMode: tt.mode}
+		if got := h.IndexedAzimuth(); math.Abs(float64(got-tt.want)) > 1e-4 {
+			t.Errorf("%v (spacing %d, mode %d): got %v, want %v", tt.azimuth, tt.spacing, tt.mode, got, tt.want)
+		}
+	}
+
+	ar2 := &Archive2{ElevationScans: map[int][]*Message31{}}
+	m31 := &Message31{}
+	m31.Header.ElevationNumber = 1
+	m31.Header.AzimuthResolutionSpacingCode = 1
+	m31.Header.AzimuthIndexingMode = 50
+	m31.Header.AzimuthAngle = 10.27
+	ar2.AddFromLDMRecord(&LoadedLDMRecord{M31s: []*Message31{m31}})
+	// radials keep the azimuth they were decoded with
+	if az := ar2.ElevationScans[1][0].Header.AzimuthAngle; az != 10.27 {
+		t.Errorf("expected radial azimuth 10.27, got %v", az)
+	}
+}
+
+func TestAvailableMoments(t *testing.T) {
+	moment := func(data ...byte) *DataMoment {
+		return &DataMoment{GenericDataMoment: GenericDataMoment{DataWordSize: 8}, Data: data}
+	}
+	ar2 := &Archive2{ElevationScans: map[int][]*Message31{
+		1: {
+			{ReflectivityData: moment(0, 0, 0), VelocityData: moment(0, 1, 0)},
+			{ReflectivityData: moment(0, 40, 0), VelocityData: moment(0, 0, 0)},
+		},
+		2: {
+			{VelocityData: moment(2), SwData: moment(0)},
+		},
+	}}
+
+	tests := []struct {
+		elevation int
+		want      string
+	}{
+		{1, "REF"},
+		{2, "VEL"},
+		{3, ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(ar2.AvailableMoments(tt.elevation), ","); got != tt.want {
+			t.Errorf("elevation %d: got %q, want %q", tt.elevation, got, tt.want)
+		}
+	}
+}
+
+func TestParseVolumeKey(t *testing.T) {
+	want := VolumeKey{ICAO: "KCRP", Time: time.Date(2017, 8, 25, 23, 57, 33, 0, time.UTC)}
+	for _, name := range []string{
+		"2017/08/25/KCRP/KCRP20170825_235733_V06",
+		"KCRP20170825_235733_V06.ar2v",
+		"KCRP20170825_235733.gz",
+		"KCRP/596/20170825-235733-001-S",
+		"KCRP/596/20170825-235733-042-I",
+	} {
+		key, ok := ParseVolumeKey(name)
+		if !ok || key != want {
+			t.Errorf("%s: got %v (%v), expected %v", name, key, ok, want)
+		}
+	}
+	for _, name := range []string{"", "KCRP20170825_235733_V06_MDM", "KCRP/596"} {
+		if key, ok := ParseVolumeKey(name); ok {
+			t.Errorf("%s: expected no key, got %v", name, key)
+		}
+	}
+
+	vh := VolumeHeaderRecord{X_ModifiedJulianDate: 17404, X_ModifiedTime: (23*3600+57*60+33)*1000 + 123}
+	copy(vh.ICAO[:], "KCRP")
+	if key := vh.Key(); key != want {
+		t.Errorf("header key %v, expected %v", key, want)
+	}
+}
+
+func TestMessage31Date(t *testing.T) {
+	// day 1 is 1 January 1970, as for the volume header
+	h := Message31Header{CollectionDate: 17404, CollectionTime: (23*3600+57*60+33)*1000 + 123}
+	want := time.Date(2017, 8, 25, 23, 57, 33, 123000000, time.UTC)
+	if d := h.Date(); !d.Equal(want) {
+		t.Errorf("date %v, expected %v", d, want)
+	}
+	vh := VolumeHeaderRecord{X_ModifiedJulianDate: 17404, X_ModifiedTime: (23*3600+57*60+33)*1000 + 123}
+	if d := vh.Date(); !d.Equal(h.Date()) {
+		t.Errorf("volume header date %v, expected radial date %v", d, h.Date())
+	}
+}
+
+func TestMessage2(t *testing.T) {
+	m2 := Message2{
+		RDAStatus:                RDAStatusOperate,
+		OperabilityStatus:        OperabilityMaintenanceRequired,
+		OperationalMode:          OperationalModeOperational,
+		RDAAlarmSummary:          AlarmTransmitter | AlarmReceiver,
+		DataTxEnabled:            4 | 8 | 16,
+		VolumeCoveragePatternNum: uint16(0x10000 - 212),
+		RDABuild:                 1950,
+		HorizRefCalibCorr:        uint16(0x10000 - 150),
+		BypassMapGenDate:         18629,
+		BypassMapGenTime:         90,
+	}
+	if s := m2.RDAStatusString(); s != "operating" {
+		t.Errorf("unexpected RDA status %q", s)
+	}
+	if s := m2.OperationalModeString(); s != "operational" {
+		t.Errorf("unexpected operational mode %q", s)
+	}
+	if s := m2.AlarmSummaryString(); s != "transmitter, receiver" {
+		t.Errorf("unexpected alarms %q", s)
+	}
+	if s := strings.Join(m2.DataTransmitted(), ","); s != "REF,VEL,SW" {
+		t.Errorf("unexpected data transmitted %q", s)
+	}
+	if m2.VCP() != -212 || !strings.Contains(m2.VCPDescription(), "SZ-2") {
+		t.Errorf("unexpected VCP %d: %s", m2.VCP(), m2.VCPDescription())
+	}
+	if ClearAirVCP(m2.VCP()) || !ClearAirVCP(35) || !ClearAirVCP(-32) {
+		t.Error("expected only VCPs 31, 32 and 35 to be clear air")
+	}
+	if b := m2.GetBuildNumber(); b != 19.5 {
+		t.Errorf("unexpected build %v", b)
+	}
+	if c := m2.HorizRefCalibration(); c != -1.5 {
+		t.Errorf("unexpected calibration %v", c)
+	}
+	if d := m2.BypassMapGenerated(); !d.Equal(time.Date(2021, 1, 1, 1, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected bypass map date %s", d)
+	}
+	if s := (Message2{RDAStatus: 3}).RDAStatusString(); s != "unknown (3)" {
+		t.Errorf("unexpected unknown status %q", s)
+	}
+
+	b, err := json.Marshal(m2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["RDAStatus"] != float64(RDAStatusOperate) || decoded["RDAStatusString"] != "operating" {
+		t.Errorf("unexpected json %s", b)
+	}
+}
+
+func TestMessage3(t *testing.T) {
+	m3 := Message3{
+		HorizontalXMTRPeakPower:     750,
+		ConvertedGeneratorFuelLevel: 85,
+		PerformanceCheckTime:        1609459200,
+		KlystronAirflow:             1,
+		AzimuthMotorOvertemp:        1,
+	}
+	copy(m3.RCPString[:], "RCP OK")
+	if h, _ := m3.PeakPowerWatts(); h != 750000 {
+		t.Errorf("unexpected peak power %v", h)
+	}
+	if f := m3.FuelLevel(); f != 0.85 {
+		t.Errorf("unexpected fuel level %v", f)
+	}
+	if c := m3.PerformanceCheck(); !c.Equal(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected performance check time %s", c)
+	}
+	if r := m3.RCP(); r != "RCP OK" {
+		t.Errorf("unexpected RCP string %q", r)
+	}
+	if f := strings.Join(m3.Faults(), ","); f != "klystron airflow,azimuth motor overtemp" {
+		t.Errorf("unexpected faults %q", f)
+	}
+	if s := (Message3{}).String(); !strings.HasSuffix(s, "no faults") {
+		t.Errorf("unexpected summary %q", s)
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	m31 := &Message31{}
+	copy(m31.Header.RadarIdentifier[:], "KTLX")
+	m31.Header.CollectionDate = 18629
+	m31.Header.CollectionTime = 5400000
+	copy(m31.RadialData.DataName[:], "RAD")
+	m31.ReflectivityData = &DataMoment{
+		GenericDataMoment: GenericDataMoment{Scale: 2, Offset: 66, DataWordSize: 8},
+		Data:              []byte{0, 1, 86},
+	}
+	copy(m31.ReflectivityData.DataName[:], "REF")
+
+	decode := func() map[string]interface{} {
+		b, err := json.Marshal(m31)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded map[string]interface{}
+		if err := json.Unmarshal(b, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		return decoded
+	}
+
+	decoded := decode()
+	header := decoded["Header"].(map[string]interface{})
+	if header["RadarIdentifier"] != "KTLX" || header["Date"] != "2021-01-01T01:30:00Z" {
+		t.Errorf("unexpected header %v", header)
+	}
+	if _, ok := header["Spare"]; ok {
+		t.Error("spare field wasn't left out")
+	}
+	if name := decoded["RadialData"].(map[string]interface{})["DataName"]; name != "RAD" {
+		t.Errorf("unexpected data block name %v", name)
+	}
+	moments := decoded["Moments"].(map[string]interface{})
+	if _, ok := moments["VEL"]; ok || len(moments) != 1 {
+		t.Errorf("unexpected moments %v", moments)
+	}
+	if data := moments["REF"].(map[string]interface{})["Data"]; data != "AAFW" {
+		t.Errorf("unexpected raw data %v", data)
+	}
+
+	JSONScaledMoments = true
+	defer func() { JSONScaledMoments = false }()
+	ref := decode()["Moments"].(map[string]interface{})["REF"].(map[string]interface{})
+	if data := ref["Data"].([]interface{}); len(data) != 3 || data[0] != float64(MomentDataBelowThreshold) || data[2] != float64(10) {
+		t.Errorf("unexpected scaled data %v", data)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	radial := func(elv float32, ms uint32, ref ...byte) *Message31 {
+		m31 := &Message31{}
+		copy(m31.Header.RadarIdentifier[:], "KTLX")
+		m31.Header.CollectionDate = 18629
+		m31.Header.CollectionTime = ms
+		m31.Header.ElevationAngle = elv
+		m31.VolumeData.VolumeCoveragePatternNumber = 212
+		m31.ReflectivityData = &DataMoment{
+			GenericDataMoment: GenericDataMoment{DataWordSize: 8, Scale: 2, Offset: 66},
+			Data:              ref,
+		}
+		return m31
+	}
+	ar2 := &Archive2{ElevationScans: map[int][]*Message31{
+		2: {radial(0.9, 30000, 0, 100), radial(0.9, 40000, 0)},
+		1: {radial(0.4, 0, 1, 86), radial(0.6, 20000, 150)},
+	}}
+
+	s := ar2.Summary()
+	if s.Site != "KTLX" || s.VCP != 212 || s.Radials != 4 || len(s.Cuts) != 2 {
+		t.Fatalf("unexpected summary %+v", s)
+	}
+	if !s.Start.Equal(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)) || s.End.Sub(s.Start) != 40*time.Second {
+		t.Errorf("unexpected time span %s - %s", s.Start, s.End)
+	}
+	if c := s.Cuts[0]; c.ElevationNumber != 1 || c.ElevationAngle != 0.5 || c.Radials != 2 || strings.Join(c.Moments, ",") != "REF" {
+		t.Errorf("unexpected first cut %+v", c)
+	}
+	if s.MaxReflectivity == nil || *s.MaxReflectivity != 42 {
+		t.Errorf("unexpected max reflectivity %v", s.MaxReflectivity)
+	}
+
+	if s := (&Archive2{}).Summary(); s.MaxReflectivity != nil || len(s.Cuts) != 0 {
+		t.Errorf("unexpected empty summary %+v", s)
+	}
+}
+
+func TestStats(t *testing.T) {
+	radial := func(ref ...byte) *Message31 {
+		return &Message31{ReflectivityData: &DataMoment{
+			GenericDataMoment: GenericDataMoment{DataWordSize: 8, Scale: 2, Offset: 66},
+			Data:              ref,
+		}}
+	}
+	ar2 := &Archive2{ElevationScans: map[int][]*Message31{
+		// 0, 10, 20 and 40 dBZ, one below threshold and one range folded
+		1: {radial(66, 86, 0), radial(106, 146, 1)},
+		2: {{VelocityData: &DataMoment{GenericDataMoment: GenericDataMoment{DataWordSize: 8}, Data: []byte{0}}}},
+		3: {},
+	}}
+
+	stats := ar2.Stats(4)
+	if len(stats) != 2 || stats[0].ElevationNumber != 1 || len(stats[0].Moments) != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	s := stats[0].Moments[0]
+	if s.Moment != "REF" || s.Gates != 6 || s.BelowThreshold != 1 || s.RangeFolded != 1 {
+		t.Errorf("unexpected counts %+v", s)
+	}
+	if s.Min != 0 || s.Max != 40 || s.Mean != 17.5 || s.BinWidth != 10 {
+		t.Errorf("unexpected values %+v", s)
+	}
+	if h := fmt.Sprint(s.Histogram); h != "[1 1 1 1]" {
+		t.Errorf("unexpected histogram %s", h)
+	}
+	if p := s.BelowThresholdPercent(); math.Abs(p-100.0/6) > 1e-9 {
+		t.Errorf("unexpected below threshold percentage %v", p)
+	}
+	if v := stats[1].Moments[0]; v.Moment != "VEL" || v.Gates != 1 || v.Histogram != nil || v.Mean != 0 {
+		t.Errorf("unexpected stats of a sweep without values %+v", v)
+	}
+}
+
+// readerAt records the bytes read through it
+type readerAt struct {
+	*bytes.Reader
+	read int64
+}
+
+func (r *readerAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.Reader.ReadAt(p, off)
+	r.read += int64(n)
+	return n, err
+}
+
+func TestExtractAt(t *testing.T) {
+	raw := testArchive(t, 3, 10, []byte{0, 1, 66, 106})
+	ar2, err := Extract(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := ar2.Index()
+	if len(idx.Records) != 3 || idx.Records[0].Offset != 24 || idx.Records[1].Offset != idx.Records[0].Offset+idx.Records[0].Size {
+		t.Fatalf("unexpected index %+v", idx)
+	}
+	if elvs := idx.Elevations(); len(elvs) != 3 || elvs[2] != 3 {
+		t.Errorf("unexpected elevations %v", elvs)
+	}
+
+	r := &readerAt{Reader: bytes.NewReader(raw)}
+	elv3, err := ExtractAt(r, idx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(elv3.ElevationScans) != 1 || len(elv3.ElevationScans[3]) != 10 {
+		t.Errorf("expected only elevation 3, got %d elevations", len(elv3.ElevationScans))
+	}
+	if string(elv3.VolumeHeader.ICAO[:]) != "KTST" {
+		t.Errorf("volume header not read: %+v", elv3.VolumeHeader)
+	}
+	// the header, the first record and elevation 3's record, skipping 2
+	if want := 24 + idx.Records[0].Size + idx.Records[2].Size; r.read != want {
+		t.Errorf("read %d bytes, expected %d", r.read, want)
+	}
+
+	ranges := idx.Ranges(2)
+	if len(ranges) != 1 || ranges[0].Offset != 0 || ranges[0].Size != idx.Records[2].Offset {
+		t.Errorf("expected one merged range, got %+v", ranges)
+	}
+	if _, err := ExtractAt(r, idx, 4); err == nil {
+		t.Error("expected an error extracting a missing elevation")
+	}
+}
+
+func TestLayout(t *testing.T) {
+	raw := testArchive(t, 2, 3, []byte{0, 1, 66})
+	ar2, err := Extract(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layouts, err := Layout(bytes.NewReader(raw), ar2.Index())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layouts) != 2 || layouts[0].Offset != 24 || layouts[1].Offset != 24+layouts[0].Size {
+		t.Fatalf("unexpected records %+v", layouts)
+	}
+	m31 := int64(len(testMessage31(1, 1, []byte{0, 1, 66})))
+	if l := layouts[1]; len(l.Messages) != 3 || l.DecompressedSize != 3*m31 {
+		t.Fatalf("unexpected messages in %+v", l)
+	}
+	m := layouts[1].Messages[1]
+	if m.Offset != m31 || m.Size != m31 || m.Header.MessageType != 31 || len(m.Blocks) != 4 {
+		t.Fatalf("unexpected message %+v", m)
+	}
+	// the header, 4 pointers, VOL, ELV and RAD, then REF to the end
+	ref := m.Blocks[3]
+	if ref.Name != "DREF" || ref.Offset+ref.Size != m.Offset+m.Size {
+		t.Errorf("unexpected REF block %+v", ref)
+	}
+	for i, name := range []string{"RVOL", "RELV", "RRAD"} {
+		b := m.Blocks[i]
+		if b.Name != name || b.Offset+b.Size != m.Blocks[i+1].Offset {
+			t.Errorf("unexpected %s block %+v", name, b)
+		}
+	}
+}
+
+func TestIncrementalVolume(t *testing.T) {
+	// radials of elevation elv from azimuth number first to last
+	record := func(elv uint8, first, last uint16) *LoadedLDMRecord {
+		r := &LoadedLDMRecord{}
+		for az := first; az <= last; az++ {
+			m31 := &Message31{}
+			m31.Header.ElevationNumber = elv
+			m31.Header.AzimuthNumber = az
+			m31.Header.RadialStatus = RadialStatusIntermediateRadialData
+			switch {
+			case az == 1 && elv == 1:
+				m31.Header.RadialStatus = RadialStatusBeginningOfVolumeScan
+			case az == 1:
+				m31.Header.RadialStatus = RadialStatusStartOfElevationScan
+			case az == 360 && elv == 2:
+				m31.Header.RadialStatus = RadialStatusEndOfVolumeScan
+			case az == 360:
+				m31.Header.RadialStatus = RadialStatusEndOfElevation
+			}
+			r.M31s = append(r.M31s, m31)
+		}
+		return r
+	}
+
+	v := NewIncrementalVolume()
+	// the end of elevation 1 and start of 2 arrive first
+	v.AddRecord(3, nil, record(1, 241, 360))
+	v.AddRecord(4, nil, record(2, 1, 120))
+	if sweeps := v.CompleteSweeps(); len(sweeps) != 0 {
+		t.Fatalf("expected no complete sweeps, got %v", sweeps)
+	}
+	v.AddRecord(2, nil, record(1, 121, 240))
+	if res := v.AddRecord(2, nil, record(1, 121, 240)); res.RadialsAdded != 0 {
+		t.Errorf("repeated chunk was added again: %+v", res)
+	}
+	if sweeps := v.CompleteSweeps(); len(sweeps) != 0 {
+		t.Fatalf("expected no complete sweeps without the first radials, got %v", sweeps)
+	}
+	v.AddRecord(1, &VolumeHeaderRecord{}, record(1, 1, 120))
+	if sweeps := v.CompleteSweeps(); len(sweeps) != 1 || sweeps[0] != 1 {
+		t.Fatalf("expected elevation 1 to be complete, got %v", sweeps)
+	}
+	sweep := v.Sweep(1)
+	if len(sweep) != 360 || sweep[0].Header.AzimuthNumber != 1 || sweep[359].Header.AzimuthNumber != 360 {
+		t.Errorf("sweep isn't in azimuth order")
+	}
+	if v.Complete() {
+		t.Error("volume is complete without the end of elevation 2")
+	}
+	v.AddRecord(6, nil, record(2, 241, 360))
+	v.AddRecord(5, nil, record(2, 121, 240))
+	if !v.Complete() || len(v.CompleteSweeps()) != 2 || !v.HasChunk(6) {
+		t.Errorf("expected the volume to be complete, got %v", v.CompleteSweeps())
+	}
+
+	raw := testArchive(t, 1, 10, []byte{66})
+	v = NewIncrementalVolume()
+	if _, err := v.AddChunk(1, raw); err != nil {
+		t.Fatal(err)
+	}
+	if icao := string(v.Archive2().VolumeHeader.ICAO[:]); icao != "KTST" || len(v.Sweep(1)) != 10 {
+		t.Errorf("chunk wasn't decoded: %s, %d radials", icao, len(v.Sweep(1)))
+	}
+}
+
+func TestRelease(t *testing.T) {
+	d := &DataMoment{Data: getBytes(1832)}
+	d.Scale, d.Offset = 2, 66
+	for i := range d.Data {
+		d.Data[i] = 86
+	}
+	if cap(d.Data) != 2048 {
+		t.Errorf("expected a buffer from the 2048 byte pool, got %d", cap(d.Data))
+	}
+	gates := d.ScaledData()
+	if len(gates) != 1832 || gates[0] != 10 || gates[1831] != 10 {
+		t.Errorf("unexpected gates %v", gates[:4])
+	}
+	ReleaseScaled(gates)
+
+	m31 := &Message31{ReflectivityData: d}
+	ar2 := &Archive2{ElevationScans: map[int][]*Message31{1: {m31}}}
+	ar2.Release()
+	if d.Data != nil {
+		t.Error("moment data wasn't released")
+	}
+	// the radial is released once, even though it's in the volume twice
+	ar2.LDMRecords = []*LoadedLDMRecord{{M31s: []*Message31{m31}}}
+	ar2.Release()
+
+	if b := getBytes(10); len(b) != 10 || cap(b) != 16 {
+		t.Errorf("unexpected buffer length %d, capacity %d", len(b), cap(b))
+	}
+}
+
+func TestLimits(t *testing.T) {
+	for _, c := range []struct {
+		controlWord int32
+		size        int32
+		ok          bool
+	}{
+		{1000, 1000, true},
+		{-1000, 1000, true},
+		{MaxLDMRecordSize + 1, 0, false},
+		{math.MinInt32, 0, false},
+	} {
+		size, err := LDMRecordSize(c.controlWord)
+		if size != c.size || (err == nil) != c.ok {
+			t.Errorf("LDMRecordSize(%d) = %d, %v", c.controlWord, size, err)
+		}
+	}
+
+	body := testMessage31(1, 1, make([]byte, MaxDataMomentGates+1))[LegacyCTMHeaderLen+MessageHeaderSize:]
+	if _, err := NewMessage31(bytes.NewReader(body)); err == nil {
+		t.Error("expected an error for too many gates")
+	}
+
+	// a segmented size of 2^32-1 half-words
+	msg := &bytes.Buffer{}
+	msg.Write(make([]byte, LegacyCTMHeaderLen))
+	binary.Write(msg, binary.BigEndian, MessageHeader{MessageSize: 65535, MessageType: 31, NumMessageSegments: 65535, MessageSegmentNum: 65535})
+	compressed := &bytes.Buffer{}
+	w, _ := bzip2.NewWriter(compressed, nil)
+	w.Write(msg.Bytes())
+	w.Close()
+	record := &bytes.Buffer{}
+	binary.Write(record, binary.BigEndian, int32(compressed.Len()))
+	record.Write(compressed.Bytes())
+	if _, err := (&Archive2{}).LoadLDMRecord(record); err == nil {
+		t.Error("expected an error for an oversized message")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	r := Verify(bytes.NewReader(testArchive(t, 2, 360, []byte{0, 1, 66})))
+	want := []string{"no RDA status message", "no end of volume radial"}
+	if len(r.Problems) != len(want) {
+		t.Fatalf("expected %v, got %v", want, r.Problems)
+	}
+	for i, p := range r.Problems {
+		if p.String() != want[i] {
+			t.Errorf("expected %q, got %q", want[i], p)
+		}
+	}
+	if r.Site != "KTST" || r.Elevations != 2 || r.Radials != 720 {
+		t.Errorf("unexpected report %+v", r)
+	}
+
+	raw := testArchive(t, 1, 10, []byte{0, 1, 66})
+	copy(raw[:4], "XXXX")
+	r = Verify(bytes.NewReader(raw))
+	if r.OK() || r.Problems[0].Problem != `header doesn't start with AR2V: "XXXX0006.001"` {
+		t.Errorf("bad header not reported: %v", r.Problems)
+	}
+	found := false
+	for _, p := range r.Problems {
+		found = found || p.String() == "elevation 1: 10 radials, expected 360"
+	}
+	if !found {
+		t.Errorf("missing radials not reported: %v", r.Problems)
+	}
+
+	if r := Verify(bytes.NewReader(raw[:40])); r.OK() || !strings.HasPrefix(r.Problems[0].Problem, "decoding failed") {
+		t.Errorf("truncated volume not reported: %v", r.Problems)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	extract := func(raw []byte) *Archive2 {
+		ar2, err := Extract(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ar2
+	}
+	raw := testArchive(t, 2, 10, []byte{0, 1, 66, 106})
+	if ds := Diff(extract(raw), extract(raw)); len(ds) != 0 {
+		t.Errorf("expected no differences, got %v", ds)
+	}
+
+	a := extract(raw)
+	b := extract(testArchive(t, 2, 10, []byte{0, 1, 66, 107}))
+	copy(b.VolumeHeader.ICAO[:], "KXXX")
+	b.ElevationScans[1] = b.ElevationScans[1][:9]
+	b.ElevationScans[2][0].Header.ElevationAngle = 2
+	b.ElevationScans[2][1].ReflectivityData = nil
+	want := []string{
+		`volume header ICAO "KTST" != "KXXX"`,
+		"elevation 1: 10 radials != 9",
+		"elevation 1: 1 radials only in the first volume",
+		"elevation 1 REF: 9 of 36 gates differ, by at most 0.5",
+		"elevation 2: 1 radial headers differ, the first in ElevationAngle 1 != 2",
+		"elevation 2 REF: only in one volume in 1 radials",
+		"elevation 2 REF: 9 of 36 gates differ, by at most 0.5",
+	}
+	ds := Diff(a, b)
+	if len(ds) != len(want) {
+		t.Fatalf("expected %d differences, got %v", len(want), ds)
+	}
+	for i, d := range ds {
+		if d.String() != want[i] {
+			t.Errorf("expected %q, got %q", want[i], d)
+		}
+	}
+}
+
+func TestVersion(t *testing.T) {
+	for name, want := range map[string]int{
+		"AR2V0006.001": 6,
+		"AR2V0002.123": 2,
+		"ARCHIVE2.001": 1,
+		"":             0,
+	} {
+		vh := VolumeHeaderRecord{}
+		copy(vh.X_FileName[:], name)
+		if v := vh.Version(); v != want {
+			t.Errorf("%q: expected version %d, got %d", name, want, v)
+		}
+	}
+
+	raw := testArchive(t, 1, 10, []byte{66})
+	copy(raw, "AR2V0003.001")
+	ar2, err := Extract(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := ar2.VolumeHeader.Moments(); len(m) != 3 {
+		t.Errorf("expected only the base moments for version 3, got %v", m)
+	}
+
+	copy(raw, "ARCHIVE2.001")
+	if _, err := Extract(bytes.NewReader(raw)); err != ErrUnsupportedVersion {
+		t.Errorf("expected ErrUnsupportedVersion, got %v", err)
+	}
+
+	// message 1 radials in a record are rejected whatever the header says
+	msg := make([]byte, DefaultMessageSize)
+	msg[LegacyCTMHeaderLen+3] = 1
+	compressed := &bytes.Buffer{}
+	w, _ := bzip2.NewWriter(compressed, nil)
+	w.Write(msg)
+	w.Close()
+	record := &bytes.Buffer{}
+	binary.Write(record, binary.BigEndian, int32(compressed.Len()))
+	record.Write(compressed.Bytes())
+	if _, err := (&Archive2{}).LoadLDMRecord(record); err != ErrUnsupportedVersion {
+		t.Errorf("expected ErrUnsupportedVersion for message 1, got %v", err)
+	}
+}
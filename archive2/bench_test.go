@@ -0,0 +1,81 @@
+package archive2
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+// Benchmarks of decoding, with allocations reported. Compare runs before and
+// after a change with benchstat:
+//
+//	go test ./archive2 -run '^$' -bench . -count 10 > old.txt
+//
+// The volumes of TestRawDatasets are benchmarked too when they're in
+// testdata; they aren't checked in, so fetch them with e.g.
+//
+//	nexrad-render fetch KCRP --date 2021-09-19 --start 00:02 --end 00:03 -o archive2/testdata
+
+// benchGates are the gates of a full range super-resolution radial, with
+// varied values so they compress like real data
+func benchGates() []byte {
+	gates := make([]byte, 1832)
+	for i := range gates {
+		gates[i] = byte(2 + (i*7+i/40)%200)
+	}
+	return gates
+}
+
+// benchVolumes are a synthetic volume of 4 super-resolution tilts and the
+// TestRawDatasets volumes that are in testdata, by name
+func benchVolumes(b *testing.B) map[string][]byte {
+	volumes := map[string][]byte{"synthetic": testArchive(b, 4, 720, benchGates())}
+	for _, f := range []string{"KCRP20210919_000249_V06", "KGRK20200914_043239_V06"} {
+		if raw, err := ioutil.ReadFile("testdata/" + f); err == nil {
+			volumes[f] = raw
+		}
+	}
+	return volumes
+}
+
+func BenchmarkExtract(b *testing.B) {
+	for name, raw := range benchVolumes(b) {
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(raw)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ar2, err := Extract(bytes.NewReader(raw))
+				if err != nil {
+					b.Fatal(err)
+				}
+				ar2.Release()
+			}
+		})
+	}
+}
+
+func BenchmarkScaledData(b *testing.B) {
+	d := &DataMoment{Data: benchGates()}
+	d.DataWordSize, d.Scale, d.Offset = 8, 2, 66
+	b.SetBytes(int64(len(d.Data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ReleaseScaled(d.ScaledData())
+	}
+}
+
+// TestScaledDataAllocations is ScaledData's allocation budget. With gates
+// released the scaled gates come from the pool, leaving only the slice header
+// put back in the pool. HasData doesn't allocate.
+func TestScaledDataAllocations(t *testing.T) {
+	for _, size := range []uint8{8, 16} {
+		d := &DataMoment{Data: benchGates()}
+		d.DataWordSize, d.Scale, d.Offset = size, 2, 66
+		if n := testing.AllocsPerRun(100, func() { ReleaseScaled(d.ScaledData()) }); n > 1 {
+			t.Errorf("%d bit ScaledData allocated %v times a call, expected at most 1", size, n)
+		}
+		if n := testing.AllocsPerRun(100, func() { d.HasData() }); n > 0 {
+			t.Errorf("%d bit HasData allocated %v times a call", size, n)
+		}
+	}
+}
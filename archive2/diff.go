@@ -0,0 +1,211 @@
+package archive2
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Difference is a difference between two volumes found by Diff
+type Difference struct {
+	// Elevation is the elevation number of the scan that differs, or 0 if
+	// it's the volume as a whole
+	Elevation int
+	// Moment is the moment that differs, if it's a moment's data
+	Moment     string `json:",omitempty"`
+	Difference string
+}
+
+func (d Difference) String() string {
+	switch {
+	case d.Elevation == 0:
+		return d.Difference
+	case d.Moment == "":
+		return fmt.Sprintf("elevation %d: %s", d.Elevation, d.Difference)
+	}
+	return fmt.Sprintf("elevation %d %s: %s", d.Elevation, d.Moment, d.Difference)
+}
+
+type differences []Difference
+
+func (ds *differences) add(elevation int, moment, format string, args ...interface{}) {
+	*ds = append(*ds, Difference{elevation, moment, fmt.Sprintf(format, args...)})
+}
+
+// Diff compares two decoded volumes, e.g. from two decoders or before and
+// after a transfer: their headers, status messages, LDM record and radial
+// counts, radial headers and moment data. Radials are matched by elevation and
+// azimuth number. The differences are in the order of the volume, and none
+// means the volumes decoded the same.
+func Diff(a, b *Archive2) []Difference {
+	ds := differences{}
+	va, vb := a.VolumeHeader, b.VolumeHeader
+	if va.FileName() != vb.FileName() {
+		ds.add(0, "", "volume header file name %q != %q", va.FileName(), vb.FileName())
+	}
+	if va.ICAO != vb.ICAO {
+		ds.add(0, "", "volume header ICAO %q != %q", va.ICAO[:], vb.ICAO[:])
+	}
+	if !va.Date().Equal(vb.Date()) {
+		ds.add(0, "", "volume header date %s != %s", va.Date(), vb.Date())
+	}
+	ds.diffMessage("RDA status", a.RadarStatus, b.RadarStatus)
+	ds.diffMessage("RDA performance", a.RadarPerformance, b.RadarPerformance)
+	if len(a.LDMRecords) != len(b.LDMRecords) {
+		ds.add(0, "", "%d LDM records != %d", len(a.LDMRecords), len(b.LDMRecords))
+	}
+
+	elevations := map[int]bool{}
+	for elv := range a.ElevationScans {
+		elevations[elv] = true
+	}
+	for elv := range b.ElevationScans {
+		elevations[elv] = true
+	}
+	var sorted []int
+	for elv := range elevations {
+		sorted = append(sorted, elv)
+	}
+	sort.Ints(sorted)
+	for _, elv := range sorted {
+		ds.diffScan(elv, a.ElevationScans[elv], b.ElevationScans[elv])
+	}
+	return ds
+}
+
+// diffMessage compares a status message of the volumes, either of which may
+// be nil
+func (ds *differences) diffMessage(name string, a, b interface{}) {
+	aNil, bNil := reflect.ValueOf(a).IsNil(), reflect.ValueOf(b).IsNil()
+	switch {
+	case aNil && bNil:
+	case aNil:
+		ds.add(0, "", "%s message only in the second volume", name)
+	case bNil:
+		ds.add(0, "", "%s message only in the first volume", name)
+	default:
+		for _, f := range fieldDiff(reflect.ValueOf(a).Elem().Interface(), reflect.ValueOf(b).Elem().Interface()) {
+			ds.add(0, "", "%s %s", name, f)
+		}
+	}
+}
+
+// diffScan compares the radials of an elevation scan of the volumes
+func (ds *differences) diffScan(elv int, a, b []*Message31) {
+	if len(a) != len(b) {
+		ds.add(elv, "", "%d radials != %d", len(a), len(b))
+	}
+	byAzimuth := map[uint16]*Message31{}
+	for _, m31 := range b {
+		byAzimuth[m31.Header.AzimuthNumber] = m31
+	}
+
+	onlyA, headers := 0, 0
+	var headerFields []string
+	type momentDiff struct {
+		only, gates, differ int
+		max                 float64
+	}
+	moments := map[string]*momentDiff{}
+	for _, ma := range a {
+		mb, ok := byAzimuth[ma.Header.AzimuthNumber]
+		if !ok {
+			onlyA++
+			continue
+		}
+		delete(byAzimuth, ma.Header.AzimuthNumber)
+		if f := fieldDiff(ma.Header, mb.Header); len(f) > 0 {
+			headers++
+			if headerFields == nil {
+				headerFields = f
+			}
+		}
+		for _, name := range MomentNames {
+			da, db := ma.Moment(name), mb.Moment(name)
+			if da == nil && db == nil {
+				continue
+			}
+			md := moments[name]
+			if md == nil {
+				md = &momentDiff{}
+				moments[name] = md
+			}
+			if da == nil || db == nil {
+				md.only++
+				continue
+			}
+			ga, gb := da.ScaledData(), db.ScaledData()
+			for i := 0; i < len(ga) || i < len(gb); i++ {
+				md.gates++
+				if i >= len(ga) || i >= len(gb) {
+					md.differ++
+					continue
+				}
+				if ga[i] == gb[i] {
+					continue
+				}
+				md.differ++
+				// a value and below threshold or range folded have no
+				// difference to measure
+				if ga[i] < MomentDataFolded && gb[i] < MomentDataFolded {
+					md.max = math.Max(md.max, math.Abs(float64(ga[i]-gb[i])))
+				}
+			}
+			ReleaseScaled(ga)
+			ReleaseScaled(gb)
+		}
+	}
+
+	if onlyA > 0 {
+		ds.add(elv, "", "%d radials only in the first volume", onlyA)
+	}
+	if len(byAzimuth) > 0 {
+		ds.add(elv, "", "%d radials only in the second volume", len(byAzimuth))
+	}
+	if headers > 0 {
+		ds.add(elv, "", "%d radial headers differ, the first in %s", headers, strings.Join(headerFields, ", "))
+	}
+	for _, name := range MomentNames {
+		md := moments[name]
+		if md == nil {
+			continue
+		}
+		if md.only > 0 {
+			ds.add(elv, name, "only in one volume in %d radials", md.only)
+		}
+		if md.differ > 0 {
+			ds.add(elv, name, "%d of %d gates differ, by at most %g", md.differ, md.gates, md.max)
+		}
+	}
+}
+
+// fieldDiff returns the exported fields that differ between two structs of
+// the same type, with their values. Byte arrays are text, e.g. identifiers.
+func fieldDiff(a, b interface{}) []string {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	var fields []string
+	for i := 0; i < va.NumField(); i++ {
+		f := va.Type().Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		x, y := va.Field(i).Interface(), vb.Field(i).Interface()
+		if reflect.DeepEqual(x, y) {
+			continue
+		}
+		if f.Type.Kind() == reflect.Array && f.Type.Elem().Kind() == reflect.Uint8 {
+			fields = append(fields, fmt.Sprintf("%s %q != %q", f.Name, arrayString(va.Field(i)), arrayString(vb.Field(i))))
+			continue
+		}
+		fields = append(fields, fmt.Sprintf("%s %v != %v", f.Name, x, y))
+	}
+	return fields
+}
+
+func arrayString(v reflect.Value) string {
+	b := make([]byte, v.Len())
+	reflect.Copy(reflect.ValueOf(b), v)
+	return byteString(b)
+}
@@ -0,0 +1,71 @@
+package archive2
+
+import (
+	"bytes"
+	"testing"
+)
+
+// The fuzz targets check the decoders return errors, rather than panicking or
+// allocating without bound, on corrupt volumes. Run one with e.g.
+//
+//	go test ./archive2 -run '^$' -fuzz FuzzExtract -fuzztime 1m
+//
+// Inputs that found bugs are kept in testdata/fuzz, and run as regression
+// tests by go test.
+
+func FuzzExtract(f *testing.F) {
+	f.Add(testArchive(f, 2, 10, []byte{0, 1, 66, 106, 200, 2}))
+	f.Add(testArchive(f, 1, 1, nil))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ar2, err := Extract(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		for elv, scan := range ar2.ElevationScans {
+			for _, m31 := range scan {
+				if int(m31.Header.ElevationNumber) != elv {
+					t.Fatalf("radial of elevation %d in scan %d", m31.Header.ElevationNumber, elv)
+				}
+				for _, name := range MomentNames {
+					if d := m31.Moment(name); d != nil {
+						d.ScaledData()
+					}
+				}
+			}
+		}
+	})
+}
+
+func FuzzNewMessage31(f *testing.F) {
+	f.Add(testMessage31(1, 1, []byte{0, 1, 66, 106, 200, 2})[LegacyCTMHeaderLen+MessageHeaderSize:])
+	f.Add(testMessage31(2, 720, nil)[LegacyCTMHeaderLen+MessageHeaderSize:])
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m31, err := NewMessage31(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		for _, name := range MomentNames {
+			d := m31.Moment(name)
+			if d == nil {
+				continue
+			}
+			if len(d.Data) > len(data) {
+				t.Fatalf("%s: %d bytes of gates from a %d byte message", name, len(d.Data), len(data))
+			}
+			d.ScaledData()
+		}
+	})
+}
+
+func FuzzLoadLDMRecord(f *testing.F) {
+	raw := testArchive(f, 1, 10, []byte{0, 1, 66, 106, 200, 2})
+	f.Add(raw[volumeHeaderSize:])
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ar2 := &Archive2{ElevationScans: map[int][]*Message31{}}
+		record, err := ar2.LoadLDMRecord(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		ar2.AddFromLDMRecord(record)
+	})
+}
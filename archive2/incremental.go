@@ -0,0 +1,152 @@
+package archive2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sort"
+	"sync"
+)
+
+// IncrementalVolume builds a volume from the chunks of the realtime feed,
+// which can arrive out of order, and tracks which elevation scans are
+// complete so they can be rendered before the rest of the volume arrives.
+//
+// A scan is complete once its first and last radials (by radial status) have
+// arrived, along with every radial numbered between them. Chunks delivered by
+// a realtime.Follower can be added with AddRecord(c.Number, c.Header, c.Record).
+type IncrementalVolume struct {
+	mtx sync.Mutex
+	ar2 *Archive2
+	// chunks are the numbers of the chunks added
+	chunks map[int]bool
+	// started are the elevations whose first radial has arrived
+	started map[int]bool
+	// ends are the azimuth numbers of the elevations' last radials
+	ends map[int]uint16
+	// volumeEnded is set once the last radial of the volume has arrived
+	volumeEnded bool
+}
+
+// NewIncrementalVolume returns an empty volume
+func NewIncrementalVolume() *IncrementalVolume {
+	return &IncrementalVolume{
+		ar2:     &Archive2{ElevationScans: map[int][]*Message31{}},
+		chunks:  map[int]bool{},
+		started: map[int]bool{},
+		ends:    map[int]uint16{},
+	}
+}
+
+// AddChunk decodes and adds the chunk with the number (from 1). The first
+// chunk starts with the volume header. Chunks that were already added are
+// ignored.
+func (v *IncrementalVolume) AddChunk(number int, data []byte) (AddResult, error) {
+	r := bytes.NewReader(data)
+	var header *VolumeHeaderRecord
+	if number == 1 {
+		header = &VolumeHeaderRecord{}
+		if err := binary.Read(r, binary.BigEndian, header); err != nil {
+			return AddResult{}, err
+		}
+		if err := header.CheckVersion(); err != nil {
+			return AddResult{}, err
+		}
+	}
+	record, err := v.ar2.LoadLDMRecord(r)
+	if err == io.EOF {
+		return AddResult{}, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return AddResult{}, err
+	}
+	return v.AddRecord(number, header, record), nil
+}
+
+// AddRecord adds the chunk's decoded record, and the volume header if it's
+// the first chunk. Chunks that were already added are ignored.
+func (v *IncrementalVolume) AddRecord(number int, header *VolumeHeaderRecord, record *LoadedLDMRecord) AddResult {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	if v.chunks[number] {
+		return AddResult{}
+	}
+	v.chunks[number] = true
+	if header != nil {
+		v.ar2.VolumeHeader = *header
+	}
+
+	for _, m31 := range record.M31s {
+		elv := int(m31.Header.ElevationNumber)
+		if m31.Header.StartsElevation() {
+			v.started[elv] = true
+		}
+		if m31.Header.EndsElevation() {
+			v.ends[elv] = m31.Header.AzimuthNumber
+		}
+		if m31.Header.EndsVolume() {
+			v.volumeEnded = true
+		}
+	}
+	return v.ar2.AddFromLDMRecord(record)
+}
+
+// HasChunk reports whether the chunk with the number was added
+func (v *IncrementalVolume) HasChunk(number int) bool {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	return v.chunks[number]
+}
+
+// complete reports whether the elevation scan is complete
+func (v *IncrementalVolume) complete(elv int) bool {
+	end, ok := v.ends[elv]
+	return ok && v.started[elv] && len(v.ar2.ElevationScans[elv]) >= int(end)
+}
+
+// CompleteSweeps returns the elevation numbers of the complete scans, in order
+func (v *IncrementalVolume) CompleteSweeps() []int {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	sweeps := []int{}
+	for elv := range v.ends {
+		if v.complete(elv) {
+			sweeps = append(sweeps, elv)
+		}
+	}
+	sort.Ints(sweeps)
+	return sweeps
+}
+
+// Complete reports whether the volume's last radial has arrived and every
+// elevation scan is complete
+func (v *IncrementalVolume) Complete() bool {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	if !v.volumeEnded {
+		return false
+	}
+	for elv := range v.ar2.ElevationScans {
+		if !v.complete(elv) {
+			return false
+		}
+	}
+	return true
+}
+
+// Sweep returns the radials of the elevation scan received so far, in
+// azimuth number order
+func (v *IncrementalVolume) Sweep(elv int) []*Message31 {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	radials := append([]*Message31{}, v.ar2.ElevationScans[elv]...)
+	sort.Slice(radials, func(i, j int) bool {
+		return radials[i].Header.AzimuthNumber < radials[j].Header.AzimuthNumber
+	})
+	return radials
+}
+
+// Archive2 returns the volume built so far. Its elevation scans are in the
+// order radials arrived, and it mustn't be read while chunks are being added.
+func (v *IncrementalVolume) Archive2() *Archive2 {
+	return v.ar2
+}
@@ -0,0 +1,157 @@
+package archive2
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// volumeHeaderSize is the size of the volume header record at the start of
+// every volume
+const volumeHeaderSize = 24
+
+// Index locates the LDM records of a volume, so single elevation scans can be
+// decoded from a seekable source (a local file, or ranged GETs of an object)
+// with ExtractAt rather than reading the whole volume. It only holds offsets
+// and elevation numbers, so it can be stored alongside the volume as JSON.
+type Index struct {
+	Records []IndexRecord
+}
+
+// IndexRecord is an LDM record in the volume
+type IndexRecord struct {
+	// Offset is the offset of the record's control word in the volume
+	Offset int64
+	// Size is the size of the record, including the 4 byte control word
+	Size int64
+	// Elevations are the elevation numbers of the radials in the record, in
+	// order. Records at the end of a scan can hold radials of the next one.
+	Elevations []int
+	// Metadata is set on records holding an RDA status or performance message
+	Metadata bool
+}
+
+// ByteRange is a range of bytes in the volume, from Offset for Size bytes
+type ByteRange struct {
+	Offset int64
+	Size   int64
+}
+
+// Index returns the index of the volume's LDM records. It's built from
+// LDMOffsets and LDMRecords, so the volume must have been read with Extract.
+func (ar2 *Archive2) Index() Index {
+	idx := Index{Records: []IndexRecord{}}
+	for i, offset := range ar2.LDMOffsets {
+		if i >= len(ar2.LDMRecords) {
+			break
+		}
+		record := ar2.LDMRecords[i]
+		r := IndexRecord{
+			Offset:   int64(offset),
+			Size:     int64(record.Size) + 4,
+			Metadata: record.M2 != nil || record.M3 != nil,
+		}
+		for _, m31 := range record.M31s {
+			elv := int(m31.Header.ElevationNumber)
+			if len(r.Elevations) == 0 || r.Elevations[len(r.Elevations)-1] != elv {
+				r.Elevations = append(r.Elevations, elv)
+			}
+		}
+		idx.Records = append(idx.Records, r)
+	}
+	return idx
+}
+
+// Elevations returns the elevation numbers in the volume, in order
+func (idx Index) Elevations() []int {
+	seen := map[int]bool{}
+	elevations := []int{}
+	for _, r := range idx.Records {
+		for _, elv := range r.Elevations {
+			if !seen[elv] {
+				seen[elv] = true
+				elevations = append(elevations, elv)
+			}
+		}
+	}
+	sort.Ints(elevations)
+	return elevations
+}
+
+// ElevationRecords returns the records holding radials of the elevation scan
+func (idx Index) ElevationRecords(elevation int) []IndexRecord {
+	var records []IndexRecord
+	for _, r := range idx.Records {
+		for _, elv := range r.Elevations {
+			if elv == elevation {
+				records = append(records, r)
+				break
+			}
+		}
+	}
+	return records
+}
+
+// Ranges returns the byte ranges ExtractAt reads to decode the elevation scan:
+// the volume header and first (metadata) record, then the scan's records.
+// Adjacent records are merged into one range, so each range can be fetched
+// with a single request.
+func (idx Index) Ranges(elevation int) []ByteRange {
+	ranges := []ByteRange{{0, volumeHeaderSize}}
+	add := func(r IndexRecord) {
+		last := &ranges[len(ranges)-1]
+		if last.Offset+last.Size == r.Offset {
+			last.Size += r.Size
+			return
+		}
+		ranges = append(ranges, ByteRange{r.Offset, r.Size})
+	}
+	records := idx.ElevationRecords(elevation)
+	if len(idx.Records) > 0 && (len(records) == 0 || records[0].Offset != idx.Records[0].Offset) {
+		add(idx.Records[0])
+	}
+	for _, r := range records {
+		add(r)
+	}
+	return ranges
+}
+
+// ExtractAt decodes the volume header, the first (metadata) record and the
+// elevation scan from r, reading only the records the index says hold them.
+// Radials of other elevations in the records read are dropped, so the
+// returned volume only has the one elevation scan.
+func ExtractAt(r io.ReaderAt, idx Index, elevation int) (*Archive2, error) {
+	records := idx.ElevationRecords(elevation)
+	if len(records) == 0 {
+		return nil, fmt.Errorf("ar2: elevation %d not in index", elevation)
+	}
+	ar2 := &Archive2{ElevationScans: map[int][]*Message31{}}
+	if err := binary.Read(io.NewSectionReader(r, 0, volumeHeaderSize), binary.BigEndian, &ar2.VolumeHeader); err != nil {
+		return nil, err
+	}
+	if err := ar2.VolumeHeader.CheckVersion(); err != nil {
+		return nil, err
+	}
+
+	if records[0].Offset != idx.Records[0].Offset {
+		records = append([]IndexRecord{idx.Records[0]}, records...)
+	}
+	for _, rec := range records {
+		loadedRecord, err := ar2.LoadLDMRecord(io.NewSectionReader(r, rec.Offset, rec.Size))
+		if err != nil {
+			return nil, fmt.Errorf("ar2: record at %d: %s", rec.Offset, err)
+		}
+		m31s := loadedRecord.M31s
+		loadedRecord.M31s = nil
+		for _, m31 := range m31s {
+			if int(m31.Header.ElevationNumber) == elevation {
+				loadedRecord.M31s = append(loadedRecord.M31s, m31)
+			}
+		}
+		ar2.LDMOffsets = append(ar2.LDMOffsets, int(rec.Offset))
+		ar2.LDMRecords = append(ar2.LDMRecords, loadedRecord)
+		ar2.AddFromLDMRecord(loadedRecord)
+	}
+	return ar2, nil
+}
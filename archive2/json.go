@@ -0,0 +1,186 @@
+package archive2
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// JSON encoding of the archive 2 types follows the same conventions
+// throughout: fixed size byte arrays (identifiers and names) are strings,
+// NEXRAD julian dates and times are also given as an RFC 3339 Date, and spare
+// fields are left out. Data moments are encoded with their raw gate bytes
+// unless JSONScaledMoments is set.
+
+// JSONScaledMoments makes data moments marshal their gates as scaled values,
+// as returned by ScaledData, rather than as the raw (base64 encoded) bytes
+var JSONScaledMoments = false
+
+// byteString returns the fixed size byte array field as a string, without
+// trailing padding
+func byteString(b []byte) string {
+	return strings.TrimRight(string(b), "\x00 ")
+}
+
+// omitted shadows a field of an embedded struct so it's left out of the JSON
+type omitted *struct{}
+
+// MarshalJSON encodes the volume header
+func (vh VolumeHeaderRecord) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		FileName string
+		ICAO     string
+		Date     time.Time
+	}{
+		byteString(vh.X_FileName[:]),
+		byteString(vh.ICAO[:]),
+		vh.Date(),
+	})
+}
+
+// MarshalJSON encodes the message header
+func (h MessageHeader) MarshalJSON() ([]byte, error) {
+	type raw MessageHeader
+	return json.Marshal(struct {
+		raw
+		Date time.Time
+	}{
+		raw(h),
+		timeFromModifiedJulian(int(h.JulianDate), int(h.MillisOfDay)),
+	})
+}
+
+// MarshalJSON encodes the radial with its data moments keyed by name (see
+// MomentNames). Moments the radial doesn't have are left out.
+func (m31 Message31) MarshalJSON() ([]byte, error) {
+	moments := map[string]*DataMoment{}
+	for _, name := range MomentNames {
+		if d := m31.Moment(name); d != nil {
+			moments[name] = d
+		}
+	}
+	return json.Marshal(struct {
+		Header        Message31Header
+		VolumeData    VolumeData
+		ElevationData ElevationData
+		RadialData    RadialData
+		Moments       map[string]*DataMoment
+	}{
+		m31.Header,
+		m31.VolumeData,
+		m31.ElevationData,
+		m31.RadialData,
+		moments,
+	})
+}
+
+// MarshalJSON encodes the message 31 header
+func (h Message31Header) MarshalJSON() ([]byte, error) {
+	type raw Message31Header
+	return json.Marshal(struct {
+		raw
+		RadarIdentifier string
+		Date            time.Time
+		Spare           omitted `json:",omitempty"`
+	}{
+		raw:             raw(h),
+		RadarIdentifier: byteString(h.RadarIdentifier[:]),
+		Date:            h.Date(),
+	})
+}
+
+// dataBlockJSON are the fields of a DataBlock as strings
+type dataBlockJSON struct {
+	DataBlockType string
+	DataName      string
+}
+
+func newDataBlockJSON(d DataBlock) dataBlockJSON {
+	return dataBlockJSON{byteString(d.DataBlockType[:]), byteString(d.DataName[:])}
+}
+
+// MarshalJSON encodes the volume data block
+func (v VolumeData) MarshalJSON() ([]byte, error) {
+	type raw VolumeData
+	return json.Marshal(struct {
+		raw
+		dataBlockJSON
+	}{raw(v), newDataBlockJSON(v.DataBlock)})
+}
+
+// MarshalJSON encodes the elevation data block, with the atmospheric
+// attenuation factor in dB/km
+func (e ElevationData) MarshalJSON() ([]byte, error) {
+	type raw ElevationData
+	return json.Marshal(struct {
+		raw
+		dataBlockJSON
+		ATMOS float32
+	}{
+		raw(e),
+		newDataBlockJSON(e.DataBlock),
+		float32(int16(binary.BigEndian.Uint16(e.ATMOS[:]))) / 1000,
+	})
+}
+
+// MarshalJSON encodes the radial data block
+func (r RadialData) MarshalJSON() ([]byte, error) {
+	type raw RadialData
+	return json.Marshal(struct {
+		raw
+		dataBlockJSON
+		Spares omitted `json:",omitempty"`
+	}{raw: raw(r), dataBlockJSON: newDataBlockJSON(r.DataBlock)})
+}
+
+// MarshalJSON encodes the data moment. The gates are the raw bytes, or the
+// scaled values if JSONScaledMoments is set.
+func (d DataMoment) MarshalJSON() ([]byte, error) {
+	type raw GenericDataMoment
+	var data interface{} = d.Data
+	if JSONScaledMoments {
+		data = d.ScaledData()
+	}
+	return json.Marshal(struct {
+		raw
+		dataBlockJSON
+		Data interface{}
+	}{
+		raw(d.GenericDataMoment),
+		newDataBlockJSON(d.DataBlock),
+		data,
+	})
+}
+
+// MarshalJSON encodes the performance data
+func (m3 Message3) MarshalJSON() ([]byte, error) {
+	type raw Message3
+	return json.Marshal(struct {
+		raw
+		RCPString        string
+		PerformanceCheck time.Time
+		Faults           []string
+	}{
+		raw(m3),
+		m3.RCP(),
+		m3.PerformanceCheck(),
+		m3.Faults(),
+	})
+}
+
+// MarshalJSON encodes the volume's header, status and elevation scans. The
+// LDM records the volume was assembled from are left out.
+func (ar2 *Archive2) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		VolumeHeader     VolumeHeaderRecord
+		RadarStatus      *Message2
+		RadarPerformance *Message3
+		ElevationScans   map[int][]*Message31
+	}{
+		ar2.VolumeHeader,
+		ar2.RadarStatus,
+		ar2.RadarPerformance,
+		ar2.ElevationScans,
+	})
+}
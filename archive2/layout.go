@@ -0,0 +1,136 @@
+package archive2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/dsnet/compress/bzip2"
+)
+
+// RecordLayout is where an LDM record and the messages in it are, for
+// checking a volume byte by byte against the ICD. Records are bzip2
+// compressed, so messages are located by their offset in the decompressed
+// record.
+type RecordLayout struct {
+	// Offset is the offset of the record's control word in the volume, and
+	// Size its size including the control word
+	Offset int64
+	Size   int64
+	// DecompressedSize is the size of the record's messages
+	DecompressedSize int64
+	Messages         []MessageLayout
+}
+
+// MessageLayout is where a message is in its decompressed record
+type MessageLayout struct {
+	// Offset is the offset of the message's CTM header, and Size its size
+	// including the CTM and message headers
+	Offset int64
+	Size   int64
+	Header MessageHeader
+	// Blocks are the data blocks of message 31 radials, in order
+	Blocks []BlockLayout `json:",omitempty"`
+}
+
+// BlockLayout is where a message 31 data block is in its decompressed record
+type BlockLayout struct {
+	// Name is the block type and name, e.g. "RVOL" or "DREF"
+	Name   string
+	Offset int64
+	Size   int64
+}
+
+// Layout returns the layout of the volume's LDM records in the index, reading
+// them from r
+func Layout(r io.ReaderAt, idx Index) ([]RecordLayout, error) {
+	layouts := []RecordLayout{}
+	for _, rec := range idx.Records {
+		compressed := io.NewSectionReader(r, rec.Offset+4, rec.Size-4)
+		bz, err := bzip2.NewReader(compressed, nil)
+		if err != nil {
+			return layouts, err
+		}
+		// records decompress to well under the compressed limit
+		data, err := ioutil.ReadAll(io.LimitReader(bz, MaxLDMRecordSize))
+		if err != nil {
+			return layouts, fmt.Errorf("ar2: record at %d: %s", rec.Offset, err)
+		}
+		l := RecordLayout{Offset: rec.Offset, Size: rec.Size, DecompressedSize: int64(len(data))}
+		l.Messages, err = messageLayouts(data)
+		layouts = append(layouts, l)
+		if err != nil {
+			return layouts, fmt.Errorf("ar2: record at %d: %s", rec.Offset, err)
+		}
+	}
+	return layouts, nil
+}
+
+// messageLayouts locates the messages in a decompressed record
+func messageLayouts(data []byte) ([]MessageLayout, error) {
+	var messages []MessageLayout
+	for p := 0; p+LegacyCTMHeaderLen+MessageHeaderSize <= len(data); {
+		m := MessageLayout{Offset: int64(p), Size: DefaultMessageSize}
+		binary.Read(bytes.NewReader(data[p+LegacyCTMHeaderLen:]), binary.BigEndian, &m.Header)
+		if m.Header.MessageType == 31 {
+			sz := int64(m.Header.MessageSize)
+			if sz == 65535 {
+				sz = int64(m.Header.NumMessageSegments)<<16 | int64(m.Header.MessageSegmentNum)
+			}
+			m.Size = LegacyCTMHeaderLen + sz*2
+			if sz*2 < MessageHeaderSize || sz*2 > MaxMessage31Size {
+				return messages, fmt.Errorf("message 31 at %d: size %d half-words out of range", p, sz)
+			}
+		}
+		if int64(p)+m.Size > int64(len(data)) {
+			return messages, fmt.Errorf("message %d at %d: %d bytes, but only %d left in the record", m.Header.MessageType, p, m.Size, len(data)-p)
+		}
+		if m.Header.MessageType == 31 {
+			start := p + LegacyCTMHeaderLen + MessageHeaderSize
+			m.Blocks = blockLayouts(data[start:p+int(m.Size)], int64(start))
+		}
+		messages = append(messages, m)
+		p += int(m.Size)
+	}
+	return messages, nil
+}
+
+// blockLayouts locates the data blocks of a message 31 body, which starts at
+// offset in the record. Blocks are sized up to the next block, or the end of
+// the message.
+func blockLayouts(body []byte, offset int64) []BlockLayout {
+	h := Message31Header{}
+	r := bytes.NewReader(body)
+	if binary.Read(r, binary.BigEndian, &h) != nil || h.DataBlockCount < 3 {
+		return nil
+	}
+	pointers := []uint32{h.VOLDataBlockPtr, h.ELVDataBlockPtr, h.RADDataBlockPtr}
+	for i := uint16(3); i < h.DataBlockCount; i++ {
+		var ptr uint32
+		if binary.Read(r, binary.BigEndian, &ptr) != nil {
+			break
+		}
+		pointers = append(pointers, ptr)
+	}
+	sort.Slice(pointers, func(i, j int) bool { return pointers[i] < pointers[j] })
+
+	var blocks []BlockLayout
+	for i, ptr := range pointers {
+		if int(ptr)+4 > len(body) {
+			continue
+		}
+		end := uint32(len(body))
+		if i+1 < len(pointers) && pointers[i+1] <= end {
+			end = pointers[i+1]
+		}
+		blocks = append(blocks, BlockLayout{
+			Name:   string(body[ptr : ptr+4]),
+			Offset: offset + int64(ptr),
+			Size:   int64(end - ptr),
+		})
+	}
+	return blocks
+}
@@ -0,0 +1,47 @@
+package archive2
+
+import "fmt"
+
+// Limits on the sizes read from volumes. Sizes are checked against them before
+// anything is allocated, so a corrupt volume (or one crafted to exhaust
+// memory) fails with an error rather than a multi-GB allocation.
+const (
+	// MaxLDMRecordSize is the largest compressed LDM record accepted. Records
+	// hold up to 120 radials or the 134 metadata messages, which are well
+	// under a MB even before compression.
+	MaxLDMRecordSize = 4 << 20
+	// MaxMessage31Size is the largest message 31 accepted, in bytes. The
+	// largest radials, with every moment at super resolution, are around
+	// 16KB.
+	MaxMessage31Size = 1 << 17
+	// MaxDataMomentGates is the most gates a data moment can have, per the
+	// ICD's range of 0 to 1840
+	MaxDataMomentGates = 1840
+)
+
+// LDMRecordSize returns the size of the compressed LDM record following the
+// control word. The control word is negative under some circumstances, so
+// its absolute value is the size.
+func LDMRecordSize(controlWord int32) (int32, error) {
+	size := controlWord
+	if size < 0 {
+		size = -size
+	}
+	// the negation of the smallest int32 is still negative
+	if size < 0 || size > MaxLDMRecordSize {
+		return 0, fmt.Errorf("ar2: LDM record size %d out of range", controlWord)
+	}
+	return size, nil
+}
+
+// checkDataMoment returns an error if the moment's gate count or word size is
+// out of range
+func checkDataMoment(m *GenericDataMoment) error {
+	if m.NumberDataMomentGates > MaxDataMomentGates {
+		return fmt.Errorf("%d gates, more than the maximum of %d", m.NumberDataMomentGates, MaxDataMomentGates)
+	}
+	if m.DataWordSize != 8 && m.DataWordSize != 16 {
+		return fmt.Errorf("unsupported data word size %d", m.DataWordSize)
+	}
+	return nil
+}
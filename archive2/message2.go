@@ -1,9 +1,10 @@
 package archive2
 
 import (
+	"encoding/json"
 	"fmt"
-
-	"github.com/sirupsen/logrus"
+	"strings"
+	"time"
 )
 
 // Message2 RDA Status Data (User 3.2.4.6)
@@ -38,53 +39,328 @@ type Message2 struct {
 	Spares                          [14]byte
 }
 
+// RDA status values (halfword 1)
+const (
+	RDAStatusStartUp        = 2
+	RDAStatusStandby        = 4
+	RDAStatusRestart        = 8
+	RDAStatusOperate        = 16
+	RDAStatusOfflineOperate = 64
+)
+
+// Operability status values (halfword 2)
+const (
+	OperabilityOnline               = 2
+	OperabilityMaintenanceRequired  = 4
+	OperabilityMaintenanceMandatory = 8
+	OperabilityCommandedShutDown    = 16
+	OperabilityInoperable           = 32
+)
+
+// Operational mode values (halfword 11)
+const (
+	OperationalModeTest        = 2
+	OperationalModeOperational = 4
+	OperationalModeMaintenance = 8
+)
+
+// RDA alarm summary bits (halfword 15). No bits set means no alarms.
+const (
+	AlarmTowerUtilities  = 1 << 1
+	AlarmPedestal        = 1 << 2
+	AlarmTransmitter     = 1 << 3
+	AlarmReceiver        = 1 << 4
+	AlarmRDAControl      = 1 << 5
+	AlarmCommunication   = 1 << 6
+	AlarmSignalProcessor = 1 << 7
+)
+
+var rdaStatusNames = map[uint16]string{
+	RDAStatusStartUp:        "start-up",
+	RDAStatusStandby:        "standby",
+	RDAStatusRestart:        "restart",
+	RDAStatusOperate:        "operating",
+	32:                      "spare",
+	RDAStatusOfflineOperate: "off-line operate",
+}
+
+var operabilityNames = map[uint16]string{
+	OperabilityOnline:               "online",
+	OperabilityMaintenanceRequired:  "maintenance required",
+	OperabilityMaintenanceMandatory: "maintenance mandatory",
+	OperabilityCommandedShutDown:    "commanded shut down",
+	OperabilityInoperable:           "inoperable",
+}
+
+var controlStatusNames = map[uint16]string{
+	2: "local only",
+	4: "remote only",
+	8: "either",
+}
+
+var operationalModeNames = map[uint16]string{
+	OperationalModeTest:        "test",
+	OperationalModeOperational: "operational",
+	OperationalModeMaintenance: "maintenance",
+}
+
+var alarmNames = []struct {
+	bit  uint16
+	name string
+}{
+	{AlarmTowerUtilities, "tower/utilities"},
+	{AlarmPedestal, "pedestal"},
+	{AlarmTransmitter, "transmitter"},
+	{AlarmReceiver, "receiver"},
+	{AlarmRDAControl, "RDA control"},
+	{AlarmCommunication, "communication"},
+	{AlarmSignalProcessor, "signal processor"},
+}
+
+var auxPowerNames = []struct {
+	bit  uint16
+	name string
+}{
+	{1 << 0, "switched to auxiliary power"},
+	{1 << 1, "utility power available"},
+	{1 << 2, "generator on"},
+	{1 << 3, "transfer switch manual"},
+	{1 << 4, "commanded switchover"},
+}
+
+var dataTransmissionNames = []struct {
+	bit  uint16
+	name string
+}{
+	{1 << 2, "REF"},
+	{1 << 3, "VEL"},
+	{1 << 4, "SW"},
+}
+
+// vcpDescriptions describe the volume coverage patterns in use
+var vcpDescriptions = map[int]string{
+	11:  "precipitation/severe, 14 elevations in 5 minutes",
+	12:  "precipitation/severe, 14 elevations in 4.2 minutes",
+	21:  "precipitation, 9 elevations in 6 minutes",
+	31:  "clear air long pulse, 5 elevations in 10 minutes",
+	32:  "clear air short pulse, 5 elevations in 10 minutes",
+	35:  "clear air, 7 elevations in 7 minutes",
+	112: "severe with MPDA velocity, 14 elevations in 5.5 minutes",
+	121: "precipitation with MPDA velocity, 9 elevations in 6 minutes",
+	212: "precipitation/severe with SZ-2, 14 elevations in 4.5 minutes",
+	215: "precipitation with SZ-2, 15 elevations in 6 minutes",
+}
+
+// ClearAirVCP reports whether the volume coverage pattern is a clear air mode
+// pattern, scanning slowly for sensitivity to the weak echoes of clear air and
+// light snow rather than for precipitation
+func ClearAirVCP(vcp int) bool {
+	if vcp < 0 {
+		vcp = -vcp
+	}
+	return vcp == 31 || vcp == 32 || vcp == 35
+}
+
+// enumString returns the name of the value, or unknown with the raw value
+func enumString(names map[uint16]string, v uint16) string {
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown (%d)", v)
+}
+
+// bitNames returns the names of the bits set in v
+func bitNames(names []struct {
+	bit  uint16
+	name string
+}, v uint16) []string {
+	set := []string{}
+	for _, n := range names {
+		if v&n.bit != 0 {
+			set = append(set, n.name)
+		}
+	}
+	return set
+}
+
 func (m2 Message2) String() string {
 	return fmt.Sprintf("Message 2 - %s and %s. VCP %d build %.2f",
-		m2.GetRDAStatus(),
-		m2.GetOperabilityStatus(),
-		m2.VolumeCoveragePatternNum,
+		m2.RDAStatusString(),
+		m2.OperabilityStatusString(),
+		m2.VCP(),
 		m2.GetBuildNumber(),
 	)
 }
 
-// GetRDAStatus returns a human friendly status
-func (m2 Message2) GetRDAStatus() string {
-	mapping := map[uint16]string{
-		2:  "start-up",
-		4:  "standby",
-		8:  "restart",
-		16: "operating",
-		32: "spare",
-		64: "spare",
-	}
+// RDAStatusString returns the RDA status, e.g. operating
+func (m2 Message2) RDAStatusString() string {
+	return enumString(rdaStatusNames, m2.RDAStatus)
+}
 
-	if val, ok := mapping[m2.RDAStatus]; ok {
-		return val
-	}
+// OperabilityStatusString returns the operability status, e.g. online
+func (m2 Message2) OperabilityStatusString() string {
+	return enumString(operabilityNames, m2.OperabilityStatus)
+}
 
-	logrus.Warnf("unknown RDA status code %d", m2.RDAStatus)
-	return "UNKNOWN"
+// ControlStatusString returns whether the RDA is controlled locally, remotely
+// (by the RPG) or either
+func (m2 Message2) ControlStatusString() string {
+	return enumString(controlStatusNames, m2.ControlStatus)
 }
 
-// GetOperabilityStatus returns a human friendly status
-func (m2 Message2) GetOperabilityStatus() string {
-	mapping := map[uint16]string{
-		2:  "online",
-		4:  "maintenance required",
-		8:  "maintenance mandatory",
-		16: "commanded shut down",
-		32: "inoperable",
+// OperationalModeString returns the operational mode, e.g. operational or
+// maintenance
+func (m2 Message2) OperationalModeString() string {
+	return enumString(operationalModeNames, m2.OperationalMode)
+}
+
+// Alarms returns the names of the RDA alarms that are set, empty if there are
+// none
+func (m2 Message2) Alarms() []string {
+	return bitNames(alarmNames, m2.RDAAlarmSummary)
+}
+
+// AlarmSummaryString summarizes the RDA alarms, e.g. "transmitter, receiver"
+func (m2 Message2) AlarmSummaryString() string {
+	if m2.RDAAlarmSummary == 0 {
+		return "no alarms"
 	}
+	return strings.Join(m2.Alarms(), ", ")
+}
+
+// AuxPowerStates returns the auxiliary power generator states that are set
+func (m2 Message2) AuxPowerStates() []string {
+	return bitNames(auxPowerNames, m2.AuxPowerGeneratorState)
+}
+
+// DataTransmitted returns the moments the RDA is transmitting, e.g. REF, VEL
+// and SW
+func (m2 Message2) DataTransmitted() []string {
+	return bitNames(dataTransmissionNames, m2.DataTxEnabled)
+}
+
+// SuperResEnabled reports whether super resolution is enabled
+func (m2 Message2) SuperResEnabled() bool {
+	return m2.SuperResStatus == 2
+}
+
+// AvsetEnabled reports whether AVSET (automated volume scan evaluation and
+// termination) is enabled
+func (m2 Message2) AvsetEnabled() bool {
+	return m2.AvsetStatus == 2
+}
 
-	if val, ok := mapping[m2.OperabilityStatus]; ok {
-		return val
+// ClutterMitigationEnabled reports whether clutter mitigation decision (CMD)
+// is enabled
+func (m2 Message2) ClutterMitigationEnabled() bool {
+	return m2.ClutterMitigationDecisionStatus&1 != 0
+}
+
+// VCP returns the volume coverage pattern number. Local patterns, defined at
+// the RDA rather than downloaded from the RPG, are negative.
+func (m2 Message2) VCP() int {
+	return int(int16(m2.VolumeCoveragePatternNum))
+}
+
+// VCPDescription describes the volume coverage pattern
+func (m2 Message2) VCPDescription() string {
+	vcp := m2.VCP()
+	if vcp < 0 {
+		vcp = -vcp
+	}
+	if vcp == 0 {
+		return "none"
 	}
+	if d, ok := vcpDescriptions[vcp]; ok {
+		return d
+	}
+	return fmt.Sprintf("VCP %d", vcp)
+}
+
+// HorizRefCalibration returns the horizontal reflectivity calibration
+// correction in dB
+func (m2 Message2) HorizRefCalibration() float32 {
+	return float32(int16(m2.HorizRefCalibCorr)) / 100
+}
+
+// VertRefCalibration returns the vertical reflectivity calibration correction
+// in dB
+func (m2 Message2) VertRefCalibration() float32 {
+	return float32(int16(m2.VertRefCalibCorr)) / 100
+}
+
+// BypassMapGenerated returns when the clutter bypass map was generated
+func (m2 Message2) BypassMapGenerated() time.Time {
+	return timeFromModifiedJulian(int(m2.BypassMapGenDate), int(m2.BypassMapGenTime)*60000)
+}
 
-	logrus.Warnf("unknown operability status code %d", m2.RDAStatus)
-	return "UNKNOWN"
+// ClutterFilterMapGenerated returns when the clutter filter map was generated
+func (m2 Message2) ClutterFilterMapGenerated() time.Time {
+	return timeFromModifiedJulian(int(m2.ClutterFilterMapGenDate), int(m2.ClutterFilterMapGenTime)*60000)
+}
+
+// GetRDAStatus returns a human friendly status
+//
+// Deprecated: use RDAStatusString
+func (m2 Message2) GetRDAStatus() string {
+	return m2.RDAStatusString()
+}
+
+// GetOperabilityStatus returns a human friendly status
+//
+// Deprecated: use OperabilityStatusString
+func (m2 Message2) GetOperabilityStatus() string {
+	return m2.OperabilityStatusString()
 }
 
 // GetBuildNumber as a more recognizable float
 func (m2 Message2) GetBuildNumber() float32 {
-	return float32(m2.RDABuild / 100)
+	return float32(m2.RDABuild) / 100
+}
+
+// MarshalJSON encodes the raw fields along with their decoded meanings, so
+// the status is readable without the ICD at hand
+func (m2 Message2) MarshalJSON() ([]byte, error) {
+	type raw Message2
+	return json.Marshal(struct {
+		raw
+		RDAStatusString           string
+		OperabilityStatusString   string
+		ControlStatusString       string
+		OperationalModeString     string
+		Alarms                    []string
+		AuxPowerStates            []string
+		DataTransmitted           []string
+		SuperResEnabled           bool
+		AvsetEnabled              bool
+		ClutterMitigationEnabled  bool
+		VCP                       int
+		VCPDescription            string
+		BuildNumber               float32
+		HorizRefCalibration       float32
+		VertRefCalibration        float32
+		BypassMapGenerated        time.Time
+		ClutterFilterMapGenerated time.Time
+		Spares                    omitted `json:",omitempty"`
+	}{
+		raw(m2),
+		m2.RDAStatusString(),
+		m2.OperabilityStatusString(),
+		m2.ControlStatusString(),
+		m2.OperationalModeString(),
+		m2.Alarms(),
+		m2.AuxPowerStates(),
+		m2.DataTransmitted(),
+		m2.SuperResEnabled(),
+		m2.AvsetEnabled(),
+		m2.ClutterMitigationEnabled(),
+		m2.VCP(),
+		m2.VCPDescription(),
+		m2.GetBuildNumber(),
+		m2.HorizRefCalibration(),
+		m2.VertRefCalibration(),
+		m2.BypassMapGenerated(),
+		m2.ClutterFilterMapGenerated(),
+		nil,
+	})
 }
@@ -1,7 +1,16 @@
 package archive2
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 // Message3 Performance/Maintenance Data
 // see documentation RDA/RPG 3-44
+//
+// Status halfwords are generally 0 for OK and 1 for a fault, see Faults.
+// Measurements are in the units commented on the fields.
 type Message3 struct {
 	_                                    uint16
 	LoopBackTestStatus                   uint16
@@ -38,9 +47,9 @@ type Message3 struct {
 	_                                    uint32
 	_                                    uint16
 	Polarization                         uint16
-	AMEInternalTemp                      float32
-	AMERecvModuleTemp                    float32
-	AMEBITECALModuleTemp                 float32
+	AMEInternalTemp                      float32 // °C
+	AMERecvModuleTemp                    float32 // °C
+	AMEBITECALModuleTemp                 float32 // °C
 	AMEPeltier                           uint16
 	AMEPeltierStatus                     uint16
 	AMEADConverterStatus                 uint16
@@ -136,15 +145,15 @@ type Message3 struct {
 	XMTRSPIPInterface                    uint16
 	TransmitterSummaryStatus             uint16
 	_                                    uint16
-	TransmitterRFPowerSensor             float32
-	HorizontalXMTRPeakPower              float32
-	XMTRPeakPower                        float32
-	VerticalXMTRPeakPower                float32
-	XMTRRFAvgPower                       float32
+	TransmitterRFPowerSensor             float32 // mW
+	HorizontalXMTRPeakPower              float32 // kW
+	XMTRPeakPower                        float32 // kW
+	VerticalXMTRPeakPower                float32 // kW
+	XMTRRFAvgPower                       float32 // W
 	_                                    uint32
 	XMTRRecycleCount                     uint32
-	ReceiverBiasMeasurement              float32
-	TransmitImbalance                    float32
+	ReceiverBiasMeasurement              float32 // dB
+	TransmitImbalance                    float32 // dB
 	XMTRPowerMeterZero                   float32
 	_                                    [8]byte
 	ACUnit1CompressorShutoff             uint16
@@ -169,19 +178,19 @@ type Message3 struct {
 	RadomeHatch                          uint16
 	ACUnit1FilterDirty                   uint16
 	ACUnit2FilterDirty                   uint16
-	EquipmentShelterTemp                 float32
-	OutsideAmbientTemp                   float32
-	TransmitterLeavingAirTemp            float32
-	ACUnit1DischargeAirTemp              float32
-	GeneratorShelterTemp                 float32
-	RadomeAirTemp                        float32
-	ACUnit2DischargeAirTemp              float32
+	EquipmentShelterTemp                 float32 // °C
+	OutsideAmbientTemp                   float32 // °C
+	TransmitterLeavingAirTemp            float32 // °C
+	ACUnit1DischargeAirTemp              float32 // °C
+	GeneratorShelterTemp                 float32 // °C
+	RadomeAirTemp                        float32 // °C
+	ACUnit2DischargeAirTemp              float32 // °C
 	SPIPp15VPS                           float32
 	SPIPn15VPS                           float32
 	SPIP28VStatus                        uint16
 	_                                    uint16
 	SPIP5VPS                             float32
-	ConvertedGeneratorFuelLevel          uint16
+	ConvertedGeneratorFuelLevel          uint16 // percent
 	_                                    [32]byte
 	ElevationUpperDeadLimit              uint16
 	Overvolatage150                      uint16
@@ -223,20 +232,20 @@ type Message3 struct {
 	Receiver9VnPS                        uint16
 	SingleChanRDAIU5VpPS                 uint16
 	_                                    uint16
-	HorzShortPulseNoise                  float32
-	HorzLongPulseNoise                   float32
-	HorzNoiseTemp                        float32
-	VertShortPulseNoise                  float32
-	VertLongPulseNoise                   float32
-	VertNoiseTemp                        float32
+	HorzShortPulseNoise                  float32 // dBm
+	HorzLongPulseNoise                   float32 // dBm
+	HorzNoiseTemp                        float32 // K
+	VertShortPulseNoise                  float32 // dBm
+	VertLongPulseNoise                   float32 // dBm
+	VertNoiseTemp                        float32 // K
 	HorzLinearty                         float32
 	HorzDynamicRange                     float32
-	HorzDeltadBZ                         float32
-	VertDeltadBZ                         float32
+	HorzDeltadBZ                         float32 // dB
+	VertDeltadBZ                         float32 // dB
 	KDPeakMeasured                       float32
 	_                                    uint32
-	ShortPulseHorzdbz                    float32
-	LongPulseHorzdbz                     float32
+	ShortPulseHorzdbz                    float32 // dBZ
+	LongPulseHorzdbz                     float32 // dBZ
 	VelocityProcessed                    uint16
 	WidthProcessed                       uint16
 	VelocityRFGen                        uint16
@@ -244,17 +253,17 @@ type Message3 struct {
 	HorzIO                               float32
 	VertIO                               float32
 	VertDynamicRange                     float32
-	ShortPulseVertdbz                    float32
-	LongPulseVertdbz                     float32
+	ShortPulseVertdbz                    float32 // dBZ
+	LongPulseVertdbz                     float32 // dBZ
 	_                                    uint32
 	_                                    uint32
-	HorzPowerSense                       float32
-	VertPowerSense                       float32
-	ZDRBias                              float32
+	HorzPowerSense                       float32 // dBm
+	VertPowerSense                       float32 // dBm
+	ZDRBias                              float32 // dB
 	_                                    [12]byte
-	ClutterSuppressionDelta              float32
-	ClutterSuppressionUnfilteredPower    float32
-	ClutterSuppressionFilteredPower      float32
+	ClutterSuppressionDelta              float32 // dB
+	ClutterSuppressionUnfilteredPower    float32 // dBZ
+	ClutterSuppressionFilteredPower      float32 // dBZ
 	_                                    uint32
 	_                                    uint32
 	_                                    uint32
@@ -297,7 +306,109 @@ type Message3 struct {
 	RMSLinkStatus                        uint16
 	RPGLinkStatus                        uint16
 	InterpanelLinkStatus                 uint16
-	PerformanceCheckTime                 uint32
+	PerformanceCheckTime                 uint32 // seconds since 1970/1/1
 	_                                    [18]byte
 	Version                              uint16 /// Version number for the performance data message
 }
+
+func (m3 Message3) String() string {
+	faults := "no faults"
+	if f := m3.Faults(); len(f) > 0 {
+		faults = strings.Join(f, ", ")
+	}
+	return fmt.Sprintf("Message 3 - XMTR %.1f kW peak %.0f W avg, H noise %.1f dBm, ZDR bias %.2f dB, shelter %.1f°C, checked %s, %s",
+		m3.XMTRPeakPower,
+		m3.XMTRRFAvgPower,
+		m3.HorzShortPulseNoise,
+		m3.ZDRBias,
+		m3.EquipmentShelterTemp,
+		m3.PerformanceCheck().Format(time.RFC3339),
+		faults,
+	)
+}
+
+// PerformanceCheck returns the time of the last performance check
+func (m3 Message3) PerformanceCheck() time.Time {
+	return time.Unix(int64(m3.PerformanceCheckTime), 0).UTC()
+}
+
+// RCP returns the RCP string
+func (m3 Message3) RCP() string {
+	return strings.TrimRight(string(m3.RCPString[:]), "\x00 ")
+}
+
+// PeakPowerWatts returns the horizontal and vertical transmitter peak power in
+// watts
+func (m3 Message3) PeakPowerWatts() (float64, float64) {
+	return float64(m3.HorizontalXMTRPeakPower) * 1000, float64(m3.VerticalXMTRPeakPower) * 1000
+}
+
+// FuelLevel returns the generator fuel level as a fraction of full
+func (m3 Message3) FuelLevel() float64 {
+	return float64(m3.ConvertedGeneratorFuelLevel) / 100
+}
+
+// OutsideTempFahrenheit returns the outside ambient temperature in °F
+func (m3 Message3) OutsideTempFahrenheit() float64 {
+	return float64(m3.OutsideAmbientTemp)*9/5 + 32
+}
+
+// Faults returns the names of the transmitter, tower and pedestal alarms that
+// are set, empty if none are
+func (m3 Message3) Faults() []string {
+	alarms := []struct {
+		name  string
+		value uint16
+	}{
+		// transmitter
+		{"modulator overload", m3.ModulatorOverload},
+		{"modulator inverse current", m3.ModulatorInvCurrent},
+		{"modulator switch fail", m3.ModulatorSwitchFail},
+		{"charging system fail", m3.ChargingSystemFail},
+		{"inverse diode current", m3.InverseDiodeCurrent},
+		{"trigger amplifier", m3.TriggerAmp},
+		{"circulator temperature", m3.CirculatorTemp},
+		{"spectrum filter pressure", m3.SpectrumFilterPressure},
+		{"waveguide arc/VSWR", m3.WGARCVSWR},
+		{"cabinet interlock", m3.CabinetInterlock},
+		{"cabinet air temperature", m3.CabinetAirTemp},
+		{"cabinet airflow", m3.CabinetAirflow},
+		{"klystron current", m3.KlystronCurrent},
+		{"klystron filament current", m3.KlystronFilamentCurrent},
+		{"klystron vacion current", m3.KlystronVacionCurrent},
+		{"klystron air temperature", m3.KlystronAirTemp},
+		{"klystron airflow", m3.KlystronAirflow},
+		{"transmitter overvoltage", m3.TransmitterOvervoltage},
+		{"transmitter overcurrent", m3.TransmitterOvercurrent},
+		{"focus coil current", m3.FocusCoilCurrent},
+		{"focus coil airflow", m3.FocusCoilAirflow},
+		{"oil temperature", m3.OilTemperature},
+		{"PRF limit", m3.PRFLimit},
+		{"transmitter oil level", m3.TransmitterOilLevel},
+		{"transmitter inoperable", m3.TransmitterInoperable},
+		{"transmitter air filter", m3.TransmitterAirFilter},
+		// tower/utilities
+		{"equipment shelter smoke", m3.EquipmentShelterFireSmoke},
+		{"generator shelter smoke", m3.GeneratorShelterFireSmoke},
+		{"site security alarm", m3.SiteSecurityAlarm},
+		{"AC unit 1 filter dirty", m3.ACUnit1FilterDirty},
+		{"AC unit 2 filter dirty", m3.ACUnit2FilterDirty},
+		// pedestal
+		{"elevation servo amp overtemp", m3.ElevationServoAmpOvertemp},
+		{"elevation servo amp short circuit", m3.ElevationServoAmpShortCircuit},
+		{"elevation motor overtemp", m3.ElevationMotorOvertemp},
+		{"elevation gearbox oil", m3.ElevationGearboxOil},
+		{"azimuth servo amp overtemp", m3.AzimuthServoAmpOvertemp},
+		{"azimuth servo amp short circuit", m3.AzimuthServoAmpShortCircuit},
+		{"azimuth motor overtemp", m3.AzimuthMotorOvertemp},
+		{"azimuth gearbox oil", m3.AzimuthGearboxOil},
+		{"azimuth bull gear oil", m3.AzimuthBullGearboxOil},
+	}
+	faults := []string{}
+	for _, a := range alarms {
+		if a.value != 0 {
+			faults = append(faults, a.name)
+		}
+	}
+	return faults
+}
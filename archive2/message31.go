@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"time"
 )
 
@@ -28,6 +29,7 @@ type Message31 struct {
 	ZdrData          *DataMoment
 	PhiData          *DataMoment
 	RhoData          *DataMoment
+	CfpData          *DataMoment
 }
 
 func (h Message31Header) String() string {
@@ -41,9 +43,7 @@ func (h Message31Header) String() string {
 
 // Date and time this data is valid for
 func (h Message31Header) Date() time.Time {
-	return time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC).
-		Add(time.Duration(h.CollectionDate) * time.Hour * 24).
-		Add(time.Duration(h.CollectionTime) * time.Millisecond)
+	return timeFromModifiedJulian(int(h.CollectionDate), int(h.CollectionTime))
 }
 
 // Message31Header contains header information for an Archive 2 Message 31 type
@@ -74,7 +74,7 @@ type Message31Header struct {
 	ElevationAngle float32
 	// RadialSpotBlankingStatus Spot blanking status for current radial, elevation scan and volume scan
 	RadialSpotBlankingStatus uint8
-	// AzimuthIndexingMode Azimuth indexing value (Set if azimuth angle is keyed to constant angles)
+	// AzimuthIndexingMode Azimuth indexing value (Set if azimuth angle is keyed to constant angles). 0 = no indexing, 1-100 = indexing angle of .01 to 1 degrees
 	AzimuthIndexingMode uint8
 	DataBlockCount      uint16
 	// DataBlockPointers   [10]uint32
@@ -84,6 +84,72 @@ type Message31Header struct {
 	// REFDataBlockPtr uint32
 }
 
+// MomentNames are the data moments decoded from message 31 data blocks
+var MomentNames = []string{"REF", "VEL", "SW", "ZDR", "PHI", "RHO", "CFP"}
+
+// Moment returns the named data moment (one of MomentNames), or nil if the
+// radial doesn't contain it
+func (m31 *Message31) Moment(name string) *DataMoment {
+	switch name {
+	case "REF":
+		return m31.ReflectivityData
+	case "VEL":
+		return m31.VelocityData
+	case "SW":
+		return m31.SwData
+	case "ZDR":
+		return m31.ZdrData
+	case "PHI":
+		return m31.PhiData
+	case "RHO":
+		return m31.RhoData
+	case "CFP":
+		return m31.CfpData
+	}
+	return nil
+}
+
+// SetMoment stores d as the named data moment (one of MomentNames). Unknown
+// names are ignored.
+func (m31 *Message31) SetMoment(name string, d *DataMoment) {
+	switch name {
+	case "REF":
+		m31.ReflectivityData = d
+	case "VEL":
+		m31.VelocityData = d
+	case "SW":
+		m31.SwData = d
+	case "ZDR":
+		m31.ZdrData = d
+	case "PHI":
+		m31.PhiData = d
+	case "RHO":
+		m31.RhoData = d
+	case "CFP":
+		m31.CfpData = d
+	}
+}
+
+// StartsElevation reports whether the radial is the first of its elevation
+// scan
+func (h *Message31Header) StartsElevation() bool {
+	switch h.RadialStatus {
+	case RadialStatusStartOfElevationScan, RadialStatusBeginningOfVolumeScan, RadialStatusStartNewElevation:
+		return true
+	}
+	return false
+}
+
+// EndsElevation reports whether the radial is the last of its elevation scan
+func (h *Message31Header) EndsElevation() bool {
+	return h.RadialStatus == RadialStatusEndOfElevation || h.RadialStatus == RadialStatusEndOfVolumeScan
+}
+
+// EndsVolume reports whether the radial is the last of the volume
+func (h *Message31Header) EndsVolume() bool {
+	return h.RadialStatus == RadialStatusEndOfVolumeScan
+}
+
 // AzimuthResolutionSpacing returns the spacing in degrees according to the AzimuthResolutionSpacingCode
 func (h *Message31Header) AzimuthResolutionSpacing() float64 {
 	if h.AzimuthResolutionSpacingCode == 1 {
@@ -92,11 +158,36 @@ func (h *Message31Header) AzimuthResolutionSpacing() float64 {
 	return 1
 }
 
+// AzimuthIndexingAngle returns the angle in degrees radials are indexed to, or
+// 0 if the azimuth isn't indexed
+func (h *Message31Header) AzimuthIndexingAngle() float64 {
+	return float64(h.AzimuthIndexingMode) / 100
+}
+
+// IndexedAzimuth returns the center of the indexed radial the azimuth angle
+// falls in. The edges of indexed radials are multiples of the indexing angle,
+// so radials snapped to them line up from volume to volume. Radials that
+// aren't indexed return their azimuth angle unchanged.
+func (h *Message31Header) IndexedAzimuth() float32 {
+	index := h.AzimuthIndexingAngle()
+	if index == 0 {
+		return h.AzimuthAngle
+	}
+	half := h.AzimuthResolutionSpacing() / 2
+	az := math.Floor((float64(h.AzimuthAngle)-half)/index+0.5)*index + half
+	return float32(math.Mod(az+360, 360))
+}
+
 func NewMessage31(r *bytes.Reader) (*Message31, error) {
 	m31h := Message31Header{}
 	startPos, _ := r.Seek(0, io.SeekCurrent)
 
-	binary.Read(r, binary.BigEndian, &m31h)
+	if err := binary.Read(r, binary.BigEndian, &m31h); err != nil {
+		return nil, fmt.Errorf("failed to read header: %s", err)
+	}
+	if m31h.DataBlockCount < 3 {
+		return nil, fmt.Errorf("%d data blocks, expected at least VOL, ELV and RAD", m31h.DataBlockCount)
+	}
 
 	m31 := Message31{
 		Header: m31h,
@@ -154,14 +245,20 @@ func NewMessage31(r *bytes.Reader) (*Message31, error) {
 		case "RHO":
 			m := GenericDataMoment{}
 			binary.Read(r, binary.BigEndian, &m)
+			if err := checkDataMoment(&m); err != nil {
+				return nil, fmt.Errorf("%s: %s", blockName, err)
+			}
 
 			// LDM is the amount of space in bytes required for a data moment
 			// array and equals ((NG * DWS) / 8) where NG is the number of gates
 			// at the gate spacing resolution specified and DWS is the number of
 			// bits stored for each gate (DWS is always a multiple of 8).
-			ldm := m.NumberDataMomentGates * uint16(m.DataWordSize) / 8
-			data := make([]uint8, ldm)
-			binary.Read(r, binary.BigEndian, data)
+			ldm := int(m.NumberDataMomentGates) * int(m.DataWordSize) / 8
+			if ldm > r.Len() {
+				return nil, fmt.Errorf("%s: %d bytes of gates, but only %d left in the message", blockName, ldm, r.Len())
+			}
+			data := getBytes(ldm)
+			io.ReadFull(r, data)
 
 			d := &DataMoment{
 				GenericDataMoment: m,
@@ -181,6 +278,8 @@ func NewMessage31(r *bytes.Reader) (*Message31, error) {
 				m31.PhiData = d
 			case "RHO":
 				m31.RhoData = d
+			case "CFP":
+				m31.CfpData = d
 			}
 		default:
 			// preview(r, 256)
@@ -0,0 +1,101 @@
+package archive2
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// Moment data and scaled gates are decoded into buffers from these pools, so
+// decoding volume after volume (e.g. rendering an animation) reuses the
+// buffers of the volumes already released rather than allocating new ones.
+// Buffers are pooled by capacity, in powers of two, so a REF buffer isn't
+// handed out for a longer PHI moment and then thrown away.
+var (
+	bytePools  [32]sync.Pool
+	floatPools [32]sync.Pool
+)
+
+// poolClass returns the index of the pool holding buffers of at least n
+// elements
+func poolClass(n int) uint {
+	return uint(bits.Len(uint(n - 1)))
+}
+
+// getBytes returns a buffer of length n, from the pool if one is free
+func getBytes(n int) []byte {
+	if n == 0 {
+		return []byte{}
+	}
+	c := poolClass(n)
+	if c >= uint(len(bytePools)) {
+		return make([]byte, n)
+	}
+	if b, ok := bytePools[c].Get().(*[]byte); ok {
+		return (*b)[:n]
+	}
+	return make([]byte, n, 1<<c)
+}
+
+// putBytes returns a buffer from getBytes to the pool
+func putBytes(b []byte) {
+	c := poolClass(cap(b))
+	if cap(b) == 0 || c >= uint(len(bytePools)) || cap(b) != 1<<c {
+		return
+	}
+	bytePools[c].Put(&b)
+}
+
+// getFloats returns a buffer of length n, from the pool if one is free
+func getFloats(n int) []float32 {
+	if n == 0 {
+		return []float32{}
+	}
+	c := poolClass(n)
+	if c >= uint(len(floatPools)) {
+		return make([]float32, n)
+	}
+	if f, ok := floatPools[c].Get().(*[]float32); ok {
+		return (*f)[:n]
+	}
+	return make([]float32, n, 1<<c)
+}
+
+// ReleaseScaled returns gates from ScaledData to be reused. The gates must not
+// be used after they're released.
+func ReleaseScaled(gates []float32) {
+	c := poolClass(cap(gates))
+	if cap(gates) == 0 || c >= uint(len(floatPools)) || cap(gates) != 1<<c {
+		return
+	}
+	floatPools[c].Put(&gates)
+}
+
+// Release returns the radial's moment data to be reused by the next volume
+// decoded. The moments are cleared, so the radial must not be used after it's
+// released.
+func (m31 *Message31) Release() {
+	for _, name := range MomentNames {
+		if d := m31.Moment(name); d != nil {
+			putBytes(d.Data)
+			d.Data = nil
+		}
+	}
+}
+
+// Release returns the moment data of every radial in the volume to be reused
+// by the next volume decoded. The volume must not be used after it's
+// released.
+func (ar2 *Archive2) Release() {
+	ar2.mtx.Lock()
+	defer ar2.mtx.Unlock()
+	for _, scan := range ar2.ElevationScans {
+		for _, m31 := range scan {
+			m31.Release()
+		}
+	}
+	for _, r := range ar2.LDMRecords {
+		for _, m31 := range r.M31s {
+			m31.Release()
+		}
+	}
+}
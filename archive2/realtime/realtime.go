@@ -0,0 +1,325 @@
+// Package realtime follows a radar's volumes as they're uploaded to the
+// realtime chunks bucket, delivering each LDM record as soon as it's
+// available rather than waiting for the whole volume.
+//
+// The bucket holds a directory per site, with volumes in numbered
+// directories that cycle through 1-999, e.g.
+//
+//	KTLX/596/20200101-000000-001-S
+//	KTLX/596/20200101-000000-002-I
+//	...
+//	KTLX/596/20200101-000000-055-E
+//
+// Each object is a chunk of the volume: the start (S) chunk holds the volume
+// header and metadata record, and every intermediate (I) and end (E) chunk
+// holds one LDM record of radials.
+package realtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/internal/objio"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultBucket is the public realtime chunks bucket
+const DefaultBucket = "https://unidata-nexrad-level2-chunks.s3.amazonaws.com"
+
+// maxVolumeNumber is the highest volume directory before numbering wraps to 1
+const maxVolumeNumber = 999
+
+// Chunk types
+const (
+	Start        = 'S'
+	Intermediate = 'I'
+	End          = 'E'
+)
+
+// Chunk is a chunk of a volume
+type Chunk struct {
+	Key    string
+	Volume archive2.VolumeKey
+	// VolumeNumber is the numbered directory the volume is in
+	VolumeNumber int
+	// Number is the chunk's position in the volume, from 1
+	Number int
+	// Type is Start, Intermediate or End
+	Type byte
+	// Header is the volume header, set on start chunks
+	Header *archive2.VolumeHeaderRecord
+	Record *archive2.LoadedLDMRecord
+	// NewVolume is set on the first chunk delivered of each volume. The
+	// previous volume won't get any more chunks, even if its end chunk was
+	// never seen.
+	NewVolume bool
+}
+
+// Follower polls the bucket for a site's new chunks
+type Follower struct {
+	Site string
+	// Bucket is the base URL of the bucket
+	Bucket string
+	// Interval is the time between polls
+	Interval time.Duration
+	Client   *http.Client
+}
+
+// NewFollower returns a follower for the site polling the public bucket
+func NewFollower(site string) *Follower {
+	return &Follower{
+		Site:     strings.ToUpper(site),
+		Bucket:   DefaultBucket,
+		Interval: 5 * time.Second,
+		Client:   http.DefaultClient,
+	}
+}
+
+// following is the position of a follower in the site's volumes
+type following struct {
+	// volume is the current volume number, 0 until the latest volume is found
+	volume int
+	key    archive2.VolumeKey
+	// next is the number of the next chunk to deliver
+	next int
+	// ended is set once the volume's end chunk is delivered
+	ended bool
+}
+
+// Follow delivers the chunks of the site's current volume, starting with the
+// chunks already uploaded, then the chunks of each volume after it. Chunks are
+// delivered in order. The channel is closed once ctx is done. Errors talking
+// to the bucket are logged and retried at the next poll.
+func (f *Follower) Follow(ctx context.Context) <-chan Chunk {
+	chunks := make(chan Chunk)
+	go func() {
+		defer close(chunks)
+		st := &following{}
+		for {
+			if err := f.poll(ctx, st, chunks); err != nil && ctx.Err() == nil {
+				logrus.Warnf("realtime: %s: %s", f.Site, err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(f.Interval):
+			}
+		}
+	}()
+	return chunks
+}
+
+// poll delivers the chunks uploaded since the last poll, moving on to the next
+// volume once it starts
+func (f *Follower) poll(ctx context.Context, st *following, chunks chan<- Chunk) error {
+	if st.volume == 0 {
+		vol, key, ok, err := f.latestVolume(ctx)
+		if err != nil || !ok {
+			return err
+		}
+		*st = following{volume: vol, key: key, next: 1}
+	}
+
+	for {
+		delivered, err := f.deliver(ctx, st, chunks)
+		if err != nil {
+			return err
+		}
+		// a volume that's still arriving has no successor yet, so only look
+		// for one once the volume has ended or stalled
+		if delivered > 0 && !st.ended {
+			return nil
+		}
+		next := st.volume%maxVolumeNumber + 1
+		newest, err := f.newestVolume(ctx, next)
+		if err != nil || !newest.Time.After(st.key.Time) {
+			return err
+		}
+		if !st.ended {
+			logrus.Warnf("realtime: %s: volume %s ended without its end chunk, after chunk %d", f.Site, st.key, st.next-1)
+		}
+		*st = following{volume: next, key: newest, next: 1}
+	}
+}
+
+// deliver sends the current volume's chunks from st.next on, returning how
+// many were sent
+func (f *Follower) deliver(ctx context.Context, st *following, chunks chan<- Chunk) (int, error) {
+	names, err := f.chunks(ctx, st.volume)
+	if err != nil {
+		return 0, err
+	}
+	delivered := 0
+	for _, c := range names {
+		if c.Volume != st.key || c.Number < st.next {
+			continue
+		}
+		if c.Number > st.next {
+			// wait for the missing chunk
+			break
+		}
+		if err := f.load(ctx, &c); err != nil {
+			return delivered, fmt.Errorf("%s: %s", c.Key, err)
+		}
+		c.NewVolume = st.next == 1
+		select {
+		case chunks <- c:
+		case <-ctx.Done():
+			return delivered, ctx.Err()
+		}
+		delivered++
+		st.next++
+		if c.Type == End {
+			st.ended = true
+			break
+		}
+	}
+	return delivered, nil
+}
+
+// latestVolume finds the volume number being uploaded now. Volume numbers
+// wrap around, so the times of the volumes increase up to the latest and then
+// drop to the oldest; it's found with a binary search over them.
+func (f *Follower) latestVolume(ctx context.Context) (int, archive2.VolumeKey, bool, error) {
+	_, prefixes, err := objio.List(ctx, f.Client, f.Bucket, f.Site+"/", "/")
+	if err != nil {
+		return 0, archive2.VolumeKey{}, false, err
+	}
+	var volumes []int
+	for _, p := range prefixes {
+		if n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(p, f.Site+"/"), "/")); err == nil {
+			volumes = append(volumes, n)
+		}
+	}
+	if len(volumes) == 0 {
+		return 0, archive2.VolumeKey{}, false, nil
+	}
+	sort.Ints(volumes)
+
+	keys := map[int]archive2.VolumeKey{}
+	keyOf := func(i int) (archive2.VolumeKey, error) {
+		if k, ok := keys[i]; ok {
+			return k, nil
+		}
+		k, err := f.newestVolume(ctx, volumes[i])
+		keys[i] = k
+		return k, err
+	}
+	first, err := keyOf(0)
+	if err != nil {
+		return 0, archive2.VolumeKey{}, false, err
+	}
+	lo, hi := 0, len(volumes)-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		k, err := keyOf(mid)
+		if err != nil {
+			return 0, archive2.VolumeKey{}, false, err
+		}
+		if !k.Time.Before(first.Time) {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	key, _ := keyOf(lo)
+	return volumes[lo], key, true, nil
+}
+
+// chunkName matches chunk keys, e.g. KTLX/596/20200101-000000-001-S
+var chunkName = regexp.MustCompile(`^([A-Z0-9]{4})/(\d+)/(\d{8}-\d{6})-(\d{3})-([SIE])$`)
+
+// parseChunk parses a chunk's key
+func parseChunk(key string) (Chunk, bool) {
+	m := chunkName.FindStringSubmatch(key)
+	if m == nil {
+		return Chunk{}, false
+	}
+	t, err := time.Parse("20060102-150405", m[3])
+	if err != nil {
+		return Chunk{}, false
+	}
+	vol, _ := strconv.Atoi(m[2])
+	n, _ := strconv.Atoi(m[4])
+	return Chunk{
+		Key:          key,
+		Volume:       archive2.VolumeKey{ICAO: m[1], Time: t},
+		VolumeNumber: vol,
+		Number:       n,
+		Type:         m[5][0],
+	}, true
+}
+
+// chunks lists the chunks in the volume directory, in order
+func (f *Follower) chunks(ctx context.Context, volume int) ([]Chunk, error) {
+	objects, _, err := objio.List(ctx, f.Client, f.Bucket, fmt.Sprintf("%s/%d/", f.Site, volume), "")
+	if err != nil {
+		return nil, err
+	}
+	var chunks []Chunk
+	for _, o := range objects {
+		if c, ok := parseChunk(o.Key); ok {
+			chunks = append(chunks, c)
+		}
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].Number < chunks[j].Number })
+	return chunks, nil
+}
+
+// newestVolume returns the newest volume in the volume directory, or the zero
+// key if it's empty. A directory may still hold chunks of the volume that used
+// its number before, until they expire.
+func (f *Follower) newestVolume(ctx context.Context, volume int) (archive2.VolumeKey, error) {
+	chunks, err := f.chunks(ctx, volume)
+	var newest archive2.VolumeKey
+	for _, c := range chunks {
+		if c.Volume.Time.After(newest.Time) {
+			newest = c.Volume
+		}
+	}
+	return newest, err
+}
+
+// load fetches and decodes the chunk's record
+func (f *Follower) load(ctx context.Context, c *Chunk) error {
+	req, err := http.NewRequest("GET", strings.TrimRight(f.Bucket, "/")+"/"+c.Key, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := f.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	r := bytes.NewReader(data)
+	if c.Type == Start {
+		c.Header = &archive2.VolumeHeaderRecord{}
+		if err := binary.Read(r, binary.BigEndian, c.Header); err != nil {
+			return err
+		}
+	}
+	c.Record, err = (&archive2.Archive2{}).LoadLDMRecord(r)
+	if err == io.EOF {
+		return fmt.Errorf("no LDM record")
+	}
+	return err
+}
@@ -0,0 +1,169 @@
+package realtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dsnet/compress/bzip2"
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/internal/objio"
+)
+
+// bucket is a fake of the chunks bucket
+type bucket struct {
+	mtx     sync.Mutex
+	objects map[string][]byte
+}
+
+func (b *bucket) put(key string, data []byte) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.objects[key] = data
+}
+
+func (b *bucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if r.URL.Path != "/" {
+		data, ok := b.objects[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+		return
+	}
+
+	q := r.URL.Query()
+	prefix, delimiter := q.Get("prefix"), q.Get("delimiter")
+	var keys []string
+	for k := range b.objects {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var result objio.ListResult
+	seen := map[string]bool{}
+	for _, k := range keys {
+		if delimiter != "" {
+			if i := strings.Index(k[len(prefix):], delimiter); i >= 0 {
+				p := k[:len(prefix)+i+1]
+				if !seen[p] {
+					seen[p] = true
+					result.CommonPrefixes = append(result.CommonPrefixes, objio.CommonPrefix{Prefix: p})
+				}
+				continue
+			}
+		}
+		result.Contents = append(result.Contents, objio.Object{Key: k, Size: int64(len(b.objects[k]))})
+	}
+	xml.NewEncoder(w).Encode(result)
+}
+
+// testChunk encodes a chunk holding an LDM record with one message 2. Start
+// chunks begin with a volume header.
+func testChunk(t *testing.T, start bool) []byte {
+	msg := &bytes.Buffer{}
+	msg.Write(make([]byte, archive2.LegacyCTMHeaderLen))
+	binary.Write(msg, binary.BigEndian, archive2.MessageHeader{MessageType: 2})
+	binary.Write(msg, binary.BigEndian, archive2.Message2{RDAStatus: archive2.RDAStatusOperate})
+	msg.Write(make([]byte, archive2.MessageBodySize-68))
+
+	compressed := &bytes.Buffer{}
+	w, err := bzip2.NewWriter(compressed, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(msg.Bytes())
+	w.Close()
+
+	chunk := &bytes.Buffer{}
+	if start {
+		vh := archive2.VolumeHeaderRecord{}
+		copy(vh.ICAO[:], "KTLX")
+		binary.Write(chunk, binary.BigEndian, vh)
+	}
+	binary.Write(chunk, binary.BigEndian, int32(compressed.Len()))
+	chunk.Write(compressed.Bytes())
+	return chunk.Bytes()
+}
+
+func TestFollow(t *testing.T) {
+	b := &bucket{objects: map[string][]byte{}}
+	// volume numbers have wrapped, so 2 is the latest and 998 the oldest
+	for _, key := range []string{
+		"KTLX/998/20200101-000000-001-S",
+		"KTLX/999/20200101-000500-001-S",
+		"KTLX/1/20200101-001000-001-S",
+		"KTLX/2/20200101-001500-001-S",
+		"KTLX/2/20200101-001500-002-I",
+	} {
+		b.put(key, testChunk(t, strings.HasSuffix(key, "-S")))
+	}
+	srv := httptest.NewServer(b)
+	defer srv.Close()
+
+	f := NewFollower("ktlx")
+	f.Bucket = srv.URL
+	f.Interval = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	chunks := f.Follow(ctx)
+
+	next := func() Chunk {
+		select {
+		case c := <-chunks:
+			return c
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a chunk")
+		}
+		return Chunk{}
+	}
+
+	c := next()
+	if c.VolumeNumber != 2 || c.Number != 1 || c.Type != Start || !c.NewVolume || c.Header == nil {
+		t.Fatalf("expected the start of volume 2, got %+v", c)
+	}
+	if c.Record.M2 == nil || c.Record.M2.RDAStatus != archive2.RDAStatusOperate {
+		t.Errorf("record wasn't decoded: %+v", c.Record)
+	}
+	if c := next(); c.Number != 2 || c.NewVolume {
+		t.Fatalf("expected chunk 2 of volume 2, got %+v", c)
+	}
+
+	// a stale volume 3 from before the wrap isn't followed
+	b.put("KTLX/3/20191231-000000-001-S", testChunk(t, true))
+	b.put("KTLX/2/20200101-001500-003-E", testChunk(t, false))
+	if c := next(); c.Number != 3 || c.Type != End {
+		t.Fatalf("expected the end of volume 2, got %+v", c)
+	}
+	b.put("KTLX/3/20200101-002000-001-S", testChunk(t, true))
+	c = next()
+	if c.VolumeNumber != 3 || c.Number != 1 || !c.NewVolume || !c.Volume.Time.Equal(time.Date(2020, 1, 1, 0, 20, 0, 0, time.UTC)) {
+		t.Fatalf("expected the start of volume 3, got %+v", c)
+	}
+
+	cancel()
+	for range chunks {
+	}
+}
+
+func TestParseChunk(t *testing.T) {
+	c, ok := parseChunk("KTLX/596/20200101-000000-012-I")
+	if !ok || c.VolumeNumber != 596 || c.Number != 12 || c.Type != Intermediate || c.Volume.ICAO != "KTLX" {
+		t.Errorf("unexpected chunk %+v", c)
+	}
+	if _, ok := parseChunk("KTLX/596/20200101-000000-012-X"); ok {
+		t.Error("parsed an invalid chunk key")
+	}
+}
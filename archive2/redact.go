@@ -0,0 +1,209 @@
+package archive2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+
+	"github.com/dsnet/compress/bzip2"
+)
+
+// RedactMode controls what moment data is replaced with when redacting
+type RedactMode int
+
+const (
+	// RedactZero marks every gate as below threshold
+	RedactZero RedactMode = iota
+	// RedactSynthetic fills gates with a deterministic pattern, so redacted
+	// files still render something recognizable
+	RedactSynthetic
+)
+
+// RedactOptions controls Redact
+type RedactOptions struct {
+	Moments RedactMode
+	// ICAO, if set, replaces the radar identifier in the volume header and
+	// every radial
+	ICAO string
+	// ClearLocation zeroes the site latitude and longitude in the volume data
+	// blocks
+	ClearLocation bool
+}
+
+// m31HeaderSize is the size of the fixed part of the message 31 header, before
+// the data block pointers
+const m31HeaderSize = 32
+
+// Redact copies the archive 2 volume from r to w, replacing the moment data in
+// every radial while preserving the structure of the file: the volume header,
+// LDM records, message headers and data block layout are unchanged, so the
+// output can be shared to reproduce parsing bugs in files that can't be
+// redistributed. LDM records are recompressed, so their sizes will differ.
+func Redact(r io.Reader, w io.Writer, opts RedactOptions) error {
+	if opts.ICAO != "" && len(opts.ICAO) != 4 {
+		return fmt.Errorf("ar2: redact: ICAO must be 4 characters, got %q", opts.ICAO)
+	}
+
+	vh := VolumeHeaderRecord{}
+	if err := binary.Read(r, binary.BigEndian, &vh); err != nil {
+		return err
+	}
+	if opts.ICAO != "" {
+		copy(vh.ICAO[:], opts.ICAO)
+	}
+	if err := binary.Write(w, binary.BigEndian, &vh); err != nil {
+		return err
+	}
+
+	for {
+		var size int32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		compressedSize, err := LDMRecordSize(size)
+		if err != nil {
+			return err
+		}
+
+		bzipReader, err := bzip2.NewReader(io.LimitReader(r, int64(compressedSize)), nil)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(bzipReader)
+		if err != nil {
+			return err
+		}
+
+		if err := redactRecord(data, opts); err != nil {
+			return err
+		}
+
+		compressed := &bytes.Buffer{}
+		bzipWriter, err := bzip2.NewWriter(compressed, &bzip2.WriterConfig{Level: bzip2.BestCompression})
+		if err != nil {
+			return err
+		}
+		if _, err := bzipWriter.Write(data); err != nil {
+			return err
+		}
+		if err := bzipWriter.Close(); err != nil {
+			return err
+		}
+
+		// keep the sign of the control word
+		newSize := int32(compressed.Len())
+		if size < 0 {
+			newSize = -newSize
+		}
+		if err := binary.Write(w, binary.BigEndian, newSize); err != nil {
+			return err
+		}
+		if _, err := w.Write(compressed.Bytes()); err != nil {
+			return err
+		}
+	}
+}
+
+// redactRecord rewrites the messages of a decompressed LDM record in place
+func redactRecord(data []byte, opts RedactOptions) error {
+	for pos := 0; pos+LegacyCTMHeaderLen+MessageHeaderSize <= len(data); {
+		header := MessageHeader{}
+		binary.Read(bytes.NewReader(data[pos+LegacyCTMHeaderLen:]), binary.BigEndian, &header)
+
+		if header.MessageType != 31 {
+			pos += DefaultMessageSize
+			continue
+		}
+
+		// sizes are in half-words and include the message header
+		sz := int(header.MessageSize)
+		if sz == 65535 {
+			sz = int(header.NumMessageSegments)<<16 | int(header.MessageSegmentNum)
+		}
+		end := pos + LegacyCTMHeaderLen + sz*2
+		if end > len(data) {
+			return fmt.Errorf("ar2: redact: message 31 at %d overruns record", pos)
+		}
+		if err := redactMessage31(data[pos+LegacyCTMHeaderLen+MessageHeaderSize:end], opts); err != nil {
+			return err
+		}
+		pos = end
+	}
+	return nil
+}
+
+func redactMessage31(body []byte, opts RedactOptions) error {
+	if len(body) < m31HeaderSize {
+		return fmt.Errorf("ar2: redact: message 31 too short (%d bytes)", len(body))
+	}
+	if opts.ICAO != "" {
+		copy(body[0:4], opts.ICAO)
+	}
+	azimuthNumber := int(binary.BigEndian.Uint16(body[10:]))
+	blockCount := int(binary.BigEndian.Uint16(body[30:]))
+
+	for i := 0; i < blockCount; i++ {
+		ptrOffset := m31HeaderSize + i*4
+		if ptrOffset+4 > len(body) {
+			return fmt.Errorf("ar2: redact: data block pointer %d out of range", i)
+		}
+		ptr := int(binary.BigEndian.Uint32(body[ptrOffset:]))
+		if ptr+4 > len(body) {
+			return fmt.Errorf("ar2: redact: data block %d at %d out of range", i, ptr)
+		}
+
+		switch blockType, name := body[ptr], string(body[ptr+1:ptr+4]); {
+		case blockType == 'R' && name == "VOL":
+			if opts.ClearLocation && ptr+16 <= len(body) {
+				// Lat and Long follow the block header, LRTUP and version
+				copy(body[ptr+8:ptr+16], make([]byte, 8))
+			}
+		case blockType == 'D':
+			m := GenericDataMoment{}
+			if err := binary.Read(bytes.NewReader(body[ptr:]), binary.BigEndian, &m); err != nil {
+				return err
+			}
+			start := ptr + binary.Size(m)
+			end := start + int(m.NumberDataMomentGates)*int(m.DataWordSize)/8
+			if end > len(body) {
+				return fmt.Errorf("ar2: redact: %s data overruns message", name)
+			}
+			redactMoment(body[start:end], int(m.DataWordSize), azimuthNumber, opts.Moments)
+		}
+	}
+	return nil
+}
+
+func redactMoment(data []byte, wordSize, azimuthNumber int, mode RedactMode) {
+	if wordSize != 16 {
+		wordSize = 8
+	}
+	bytesPerGate := wordSize / 8
+	for gate := 0; gate < len(data)/bytesPerGate; gate++ {
+		v := 0
+		if mode == RedactSynthetic {
+			v = syntheticGate(gate, azimuthNumber)
+		}
+		if bytesPerGate == 2 {
+			binary.BigEndian.PutUint16(data[gate*2:], uint16(v<<2))
+		} else {
+			data[gate] = byte(v)
+		}
+	}
+}
+
+// syntheticGate returns a raw 8 bit gate value forming bands that vary with
+// range and azimuth, leaving gaps of below threshold data between them.
+func syntheticGate(gate, azimuthNumber int) int {
+	v := math.Sin(float64(gate)/40) * math.Cos(float64(azimuthNumber)*math.Pi/180)
+	if v <= 0.1 {
+		return 0
+	}
+	return 2 + int(v*250)
+}
@@ -0,0 +1,125 @@
+package archive2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/dsnet/compress/bzip2"
+)
+
+// testMessage31 encodes a message 31 (including the CTM and message headers)
+// with VOL, ELV, RAD and REF blocks.
+func testMessage31(elevation uint8, azimuthNumber uint16, gates []byte) []byte {
+	body := &bytes.Buffer{}
+	h := Message31Header{
+		AzimuthNumber:                azimuthNumber,
+		AzimuthAngle:                 float32(azimuthNumber-1) + 0.5,
+		AzimuthResolutionSpacingCode: 2,
+		ElevationNumber:              elevation,
+		ElevationAngle:               float32(elevation) * 0.5,
+		DataBlockCount:               4,
+	}
+	copy(h.RadarIdentifier[:], "KTST")
+	vol := VolumeData{DataBlock: DataBlock{[1]byte{'R'}, [3]byte{'V', 'O', 'L'}}, Lat: 35, Long: -97}
+	elv := ElevationData{DataBlock: DataBlock{[1]byte{'R'}, [3]byte{'E', 'L', 'V'}}}
+	rad := RadialData{DataBlock: DataBlock{[1]byte{'R'}, [3]byte{'R', 'A', 'D'}}}
+	ref := GenericDataMoment{
+		DataBlock:                     DataBlock{[1]byte{'D'}, [3]byte{'R', 'E', 'F'}},
+		NumberDataMomentGates:         uint16(len(gates)),
+		DataMomentRange:               2125,
+		DataMomentRangeSampleInterval: 250,
+		DataWordSize:                  8,
+		Scale:                         2,
+		Offset:                        66,
+	}
+
+	h.VOLDataBlockPtr = uint32(binary.Size(h) + 4)
+	h.ELVDataBlockPtr = h.VOLDataBlockPtr + uint32(binary.Size(vol))
+	h.RADDataBlockPtr = h.ELVDataBlockPtr + uint32(binary.Size(elv))
+	refPtr := h.RADDataBlockPtr + uint32(binary.Size(rad))
+
+	binary.Write(body, binary.BigEndian, h)
+	binary.Write(body, binary.BigEndian, refPtr)
+	binary.Write(body, binary.BigEndian, vol)
+	binary.Write(body, binary.BigEndian, elv)
+	binary.Write(body, binary.BigEndian, rad)
+	binary.Write(body, binary.BigEndian, ref)
+	body.Write(gates)
+	if body.Len()%2 == 1 {
+		body.WriteByte(0)
+	}
+
+	msg := &bytes.Buffer{}
+	msg.Write(make([]byte, LegacyCTMHeaderLen))
+	binary.Write(msg, binary.BigEndian, MessageHeader{
+		MessageSize: uint16((MessageHeaderSize + body.Len()) / 2),
+		MessageType: 31,
+	})
+	msg.Write(body.Bytes())
+	return msg.Bytes()
+}
+
+// testArchive encodes a volume with one LDM record per elevation, each
+// containing numRadials radials.
+func testArchive(t testing.TB, elevations, numRadials int, gates []byte) []byte {
+	f := &bytes.Buffer{}
+	vh := VolumeHeaderRecord{}
+	copy(vh.X_FileName[:], "AR2V0006.001")
+	copy(vh.ICAO[:], "KTST")
+	binary.Write(f, binary.BigEndian, vh)
+
+	for elv := 1; elv <= elevations; elv++ {
+		record := &bytes.Buffer{}
+		for az := 1; az <= numRadials; az++ {
+			record.Write(testMessage31(uint8(elv), uint16(az), gates))
+		}
+		compressed := &bytes.Buffer{}
+		w, err := bzip2.NewWriter(compressed, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(record.Bytes())
+		w.Close()
+		binary.Write(f, binary.BigEndian, int32(compressed.Len()))
+		f.Write(compressed.Bytes())
+	}
+	return f.Bytes()
+}
+
+func TestRedact(t *testing.T) {
+	raw := testArchive(t, 2, 10, []byte{0, 1, 66, 106, 200, 2})
+
+	out := &bytes.Buffer{}
+	err := Redact(bytes.NewReader(raw), out, RedactOptions{ICAO: "KXXX", ClearLocation: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ar2, err := Extract(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icao := string(ar2.VolumeHeader.ICAO[:]); icao != "KXXX" {
+		t.Errorf("expected ICAO KXXX, got %s", icao)
+	}
+	if len(ar2.ElevationScans) != 2 || len(ar2.ElevationScans[2]) != 10 {
+		t.Fatalf("structure not preserved: %d elevations", len(ar2.ElevationScans))
+	}
+	for _, m31 := range ar2.ElevationScans[1] {
+		if string(m31.Header.RadarIdentifier[:]) != "KXXX" {
+			t.Errorf("radial %d not renamed", m31.Header.AzimuthNumber)
+		}
+		if m31.VolumeData.Lat != 0 || m31.VolumeData.Long != 0 {
+			t.Errorf("radial %d location not cleared", m31.Header.AzimuthNumber)
+		}
+		if len(m31.ReflectivityData.Data) != 6 {
+			t.Fatalf("expected 6 gates, got %d", len(m31.ReflectivityData.Data))
+		}
+		for _, v := range m31.ReflectivityData.Data {
+			if v != 0 {
+				t.Fatalf("radial %d not redacted: %v", m31.Header.AzimuthNumber, m31.ReflectivityData.Data)
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package archive2
+
+import (
+	"math"
+	"sort"
+)
+
+// ElevationStats are the statistics of each moment of an elevation scan
+type ElevationStats struct {
+	ElevationNumber int
+	// Moments are the statistics of the moments the scan has, in MomentNames
+	// order
+	Moments []MomentStats
+}
+
+// MomentStats are statistics of a moment's gates, for checking the data and
+// the range color tables need to cover
+type MomentStats struct {
+	Moment string
+	Gates  int
+	// BelowThreshold and RangeFolded count the gates without a value
+	BelowThreshold int
+	RangeFolded    int
+	// Min, Max and Mean are of the gates with values, zero if there are none
+	Min  float32
+	Max  float32
+	Mean float32
+	// Histogram counts the gates with values in equal width bins from Min to
+	// Max, of BinWidth
+	Histogram []int
+	BinWidth  float32
+}
+
+// BelowThresholdPercent is the percentage of the gates below threshold
+func (s MomentStats) BelowThresholdPercent() float64 {
+	return percent(s.BelowThreshold, s.Gates)
+}
+
+// RangeFoldedPercent is the percentage of the gates range folded
+func (s MomentStats) RangeFoldedPercent() float64 {
+	return percent(s.RangeFolded, s.Gates)
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(total)
+}
+
+// Stats returns the statistics of each elevation scan's moments in elevation
+// number order, with histograms of bins bins
+func (ar2 *Archive2) Stats(bins int) []ElevationStats {
+	var elevations []int
+	for elv, radials := range ar2.ElevationScans {
+		if len(radials) > 0 {
+			elevations = append(elevations, elv)
+		}
+	}
+	sort.Ints(elevations)
+
+	stats := []ElevationStats{}
+	for _, elv := range elevations {
+		e := ElevationStats{ElevationNumber: elv, Moments: []MomentStats{}}
+		for _, name := range MomentNames {
+			if s, ok := momentStats(ar2.ElevationScans[elv], name, bins); ok {
+				e.Moments = append(e.Moments, s)
+			}
+		}
+		stats = append(stats, e)
+	}
+	return stats
+}
+
+// momentStats returns the statistics of the moment's gates in the radials. ok
+// is false if none of them have it.
+func momentStats(radials []*Message31, name string, bins int) (s MomentStats, ok bool) {
+	s.Moment = name
+	var values []float32
+	var sum float64
+	for _, m31 := range radials {
+		d := m31.Moment(name)
+		if d == nil {
+			continue
+		}
+		ok = true
+		gates := d.ScaledData()
+		s.Gates += len(gates)
+		for _, v := range gates {
+			switch v {
+			case MomentDataBelowThreshold:
+				s.BelowThreshold++
+			case MomentDataFolded:
+				s.RangeFolded++
+			default:
+				if len(values) == 0 || v < s.Min {
+					s.Min = v
+				}
+				if len(values) == 0 || v > s.Max {
+					s.Max = v
+				}
+				values = append(values, v)
+				sum += float64(v)
+			}
+		}
+		ReleaseScaled(gates)
+	}
+	if len(values) == 0 {
+		return s, ok
+	}
+	s.Mean = float32(sum / float64(len(values)))
+	if bins < 1 {
+		return s, ok
+	}
+
+	s.Histogram = make([]int, bins)
+	s.BinWidth = (s.Max - s.Min) / float32(bins)
+	for _, v := range values {
+		i := bins - 1
+		if s.BinWidth > 0 {
+			i = int(math.Min(float64((v-s.Min)/s.BinWidth), float64(bins-1)))
+		}
+		s.Histogram[i]++
+	}
+	return s, ok
+}
@@ -0,0 +1,118 @@
+package archive2
+
+import (
+	"sort"
+	"time"
+)
+
+// Summary describes a volume without its radials, for listings and metadata
+// responses
+type Summary struct {
+	Site string
+	// VCP is the volume coverage pattern, from the RDA status if the volume
+	// has it, otherwise from the radials' volume data
+	VCP   int
+	Start time.Time
+	End   time.Time
+	// Cuts are the elevation scans in elevation number order
+	Cuts    []CutSummary
+	Radials int
+	// MaxReflectivity is the highest reflectivity in the volume in dBZ, or nil
+	// if it has no reflectivity data
+	MaxReflectivity *float32
+}
+
+// CutSummary describes an elevation scan
+type CutSummary struct {
+	ElevationNumber int
+	// ElevationAngle is the mean elevation angle of the radials in degrees
+	ElevationAngle float32
+	// Moments are the moments with data, see AvailableMoments
+	Moments []string
+	Radials int
+	Start   time.Time
+	End     time.Time
+}
+
+// Summary returns a summary of the volume
+func (ar2 *Archive2) Summary() Summary {
+	s := Summary{
+		Site: byteString(ar2.VolumeHeader.ICAO[:]),
+		Cuts: []CutSummary{},
+	}
+	if ar2.RadarStatus != nil {
+		s.VCP = ar2.RadarStatus.VCP()
+	}
+
+	var elevations []int
+	for elv := range ar2.ElevationScans {
+		elevations = append(elevations, elv)
+	}
+	sort.Ints(elevations)
+
+	var maxRef float32
+	hasRef := false
+	for _, elv := range elevations {
+		radials := ar2.ElevationScans[elv]
+		if len(radials) == 0 {
+			continue
+		}
+		cut := CutSummary{
+			ElevationNumber: elv,
+			Moments:         ar2.AvailableMoments(elv),
+			Radials:         len(radials),
+		}
+		var angles float64
+		for _, m31 := range radials {
+			angles += float64(m31.Header.ElevationAngle)
+			t := m31.Header.Date()
+			if cut.Start.IsZero() || t.Before(cut.Start) {
+				cut.Start = t
+			}
+			if t.After(cut.End) {
+				cut.End = t
+			}
+			if ref, ok := maxValue(m31.ReflectivityData); ok && (!hasRef || ref > maxRef) {
+				maxRef, hasRef = ref, true
+			}
+		}
+		cut.ElevationAngle = float32(angles / float64(len(radials)))
+
+		if s.Site == "" {
+			s.Site = byteString(radials[0].Header.RadarIdentifier[:])
+		}
+		if s.VCP == 0 {
+			s.VCP = int(radials[0].VolumeData.VolumeCoveragePatternNumber)
+		}
+		if s.Start.IsZero() || cut.Start.Before(s.Start) {
+			s.Start = cut.Start
+		}
+		if cut.End.After(s.End) {
+			s.End = cut.End
+		}
+		s.Radials += cut.Radials
+		s.Cuts = append(s.Cuts, cut)
+	}
+	if hasRef {
+		s.MaxReflectivity = &maxRef
+	}
+	return s
+}
+
+// maxValue returns the highest scaled value of the moment's gates, ignoring
+// those below threshold or range folded. ok is false if no gate has data.
+func maxValue(d *DataMoment) (max float32, ok bool) {
+	if d == nil {
+		return 0, false
+	}
+	for i := 0; i < d.numGates(); i++ {
+		v := d.rawGate(i)
+		if v <= 1 {
+			continue
+		}
+		if f := scaleUint(v, d.Offset, d.Scale); !ok || f > max {
+			max, ok = f, true
+		}
+	}
+	return max, ok
+}
@@ -1,18 +1,27 @@
 package archive2
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// Radial status values of Message31Header.RadialStatus, marking where the
+// radial falls in its elevation scan and the volume
 const (
-	radialStatusStartOfElevationScan   = 0
-	radialStatusIntermediateRadialData = 1
-	radialStatusEndOfElevation         = 2
-	radialStatusBeginningOfVolumeScan  = 3
-	radialStatusEndOfVolumeScan        = 4
-	radialStatusStartNewElevation      = 5
+	RadialStatusStartOfElevationScan   = 0
+	RadialStatusIntermediateRadialData = 1
+	RadialStatusEndOfElevation         = 2
+	RadialStatusBeginningOfVolumeScan  = 3
+	RadialStatusEndOfVolumeScan        = 4
+	// RadialStatusStartNewElevation starts the last elevation scan of the VCP
+	RadialStatusStartNewElevation = 5
+)
 
+const (
 	LegacyCTMHeaderLen = 12
 	MessageHeaderSize  = 16
 	DefaultMessageSize = 2432
@@ -40,12 +49,16 @@ const (
 // contain a 4-letter radar identifier assigned by ICAO.
 //
 // Version Number Reference:
+// Version 01: Legacy resolution message 1 radials (volumes before 2008 are
+// named ARCHIVE2. rather than AR2V)
 // Version 02: Super Resolution disabled at the RDA (pre RDA Build 12.0)
 // Version 03: Super Resolution (pre RDA Build 12.0)
 // Version 04: Recombined Super Resolution
 // Version 05: Super Resolution disabled at the RDA (RDA Build 12.0 and later)
 // Version 06: Super Resolution (RDA Build 12.0 and later)
 // Version 07: Recombined Super Resolution (RDA Build 12.0 and later)
+// Version 08: Super Resolution with message 31 version 2.0 (RDA Build 19.0
+// and later)
 type VolumeHeaderRecord struct {
 	X_FileName [12]byte
 	// ModifiedJulianDate NEXRAD date since 1970/1/1 = 1
@@ -71,6 +84,48 @@ func (vh VolumeHeaderRecord) FileName() string {
 	return string(vh.X_FileName[:])
 }
 
+// ErrUnsupportedVersion is returned for volumes in a format version that can't
+// be decoded, i.e. those with message 1 radials from before message 31
+var ErrUnsupportedVersion = errors.New("ar2: unsupported Archive II version, only message 31 volumes (AR2V0002 and later) can be decoded")
+
+// Version returns the format version from the file name, e.g. 6 for
+// AR2V0006.001. Volumes named ARCHIVE2. predate the versions and are version
+// 1, and 0 is returned for unrecognized names.
+func (vh VolumeHeaderRecord) Version() int {
+	name := vh.FileName()
+	if strings.HasPrefix(name, "ARCHIVE2") {
+		return 1
+	}
+	if !strings.HasPrefix(name, "AR2V") {
+		return 0
+	}
+	v, err := strconv.Atoi(name[4:8])
+	if err != nil || v < 0 {
+		return 0
+	}
+	return v
+}
+
+// CheckVersion returns ErrUnsupportedVersion if the volume's format version
+// can't be decoded. Unrecognized names are decoded as the current version.
+func (vh VolumeHeaderRecord) CheckVersion() error {
+	if vh.Version() == 1 {
+		return ErrUnsupportedVersion
+	}
+	return nil
+}
+
+// Moments returns the moments (of MomentNames) volumes of the format version
+// can hold. Dual polarization moments were added with RDA Build 12.0, so the
+// versions from before it only have the base moments.
+func (vh VolumeHeaderRecord) Moments() []string {
+	switch vh.Version() {
+	case 1, 2, 3:
+		return []string{"REF", "VEL", "SW"}
+	}
+	return MomentNames
+}
+
 func timeFromModifiedJulian(days, ms int) time.Time {
 	return time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC).
 		AddDate(0, 0, int(days-1)).
@@ -186,22 +241,75 @@ const MomentDataFolded = 998
 // For all data moment integer values N = 0 indicates received signal is below
 // threshold and N = 1 indicates range folded data. Actual data range is N = 2
 // through 255, or 1023 for data resolution size 8, and 10 bits respectively.
+//
+// The gates can be passed to ReleaseScaled once they're no longer needed.
 func (d *DataMoment) ScaledData() []float32 {
-	scaledData := []float32{}
-	for _, v := range d.Data {
-		if v == 0 {
-			// below threshold
-			scaledData = append(scaledData, MomentDataBelowThreshold)
-		} else if v == 1 {
-			// range folded
-			scaledData = append(scaledData, MomentDataFolded)
-		} else {
-			scaledData = append(scaledData, scaleUint(uint16(v), d.GenericDataMoment.Offset, d.GenericDataMoment.Scale))
+	if d.DataWordSize == 16 {
+		scaledData := getFloats(len(d.Data) / 2)
+		for i := range scaledData {
+			scaledData[i] = d.scale(binary.BigEndian.Uint16(d.Data[i*2:]))
 		}
+		return scaledData
+	}
+	scaledData := getFloats(len(d.Data))
+	for i, v := range d.Data {
+		scaledData[i] = d.scale(uint16(v))
 	}
 	return scaledData
 }
 
+// scale converts a raw gate value to its scaled value
+func (d *DataMoment) scale(v uint16) float32 {
+	switch v {
+	case 0:
+		// below threshold
+		return MomentDataBelowThreshold
+	case 1:
+		// range folded
+		return MomentDataFolded
+	}
+	return scaleUint(v, d.GenericDataMoment.Offset, d.GenericDataMoment.Scale)
+}
+
+// HasData reports whether any gate holds an actual value, i.e. isn't below
+// threshold or range folded
+func (d *DataMoment) HasData() bool {
+	if d == nil {
+		return false
+	}
+	if d.DataWordSize == 16 {
+		for i := 0; i+1 < len(d.Data); i += 2 {
+			if binary.BigEndian.Uint16(d.Data[i:]) > 1 {
+				return true
+			}
+		}
+		return false
+	}
+	for _, v := range d.Data {
+		if v > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// numGates returns the number of gates. Most moments use a single byte per
+// gate, but some (e.g. PHI) are stored as 16 bit big endian words.
+func (d *DataMoment) numGates() int {
+	if d.DataWordSize == 16 {
+		return len(d.Data) / 2
+	}
+	return len(d.Data)
+}
+
+// rawGate returns the unscaled value of gate i
+func (d *DataMoment) rawGate(i int) uint16 {
+	if d.DataWordSize == 16 {
+		return binary.BigEndian.Uint16(d.Data[i*2:])
+	}
+	return uint16(d.Data[i])
+}
+
 // scaleUint converts unsigned integer data that can be converted to floating point
 // data using the Scale and Offset fields, i.e., F = (N - OFFSET) / SCALE where
 // N is the integer data value and F is the resulting floating point value. A
@@ -0,0 +1,156 @@
+package archive2
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// VerifyReport is the result of checking a volume's integrity
+type VerifyReport struct {
+	Site string
+	// VCP is the volume coverage pattern, see Summary
+	VCP int
+	// Elevations is the number of elevation scans decoded
+	Elevations int
+	Radials    int
+	// Problems are in the order they were found
+	Problems []VerifyProblem
+}
+
+// VerifyProblem is an integrity problem found in a volume
+type VerifyProblem struct {
+	// Elevation is the elevation number of the scan with the problem, or 0 if
+	// it's with the volume as a whole
+	Elevation int
+	Problem   string
+}
+
+func (p VerifyProblem) String() string {
+	if p.Elevation == 0 {
+		return p.Problem
+	}
+	return fmt.Sprintf("elevation %d: %s", p.Elevation, p.Problem)
+}
+
+// OK reports whether no problems were found
+func (r *VerifyReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *VerifyReport) add(elevation int, format string, args ...interface{}) {
+	r.Problems = append(r.Problems, VerifyProblem{elevation, fmt.Sprintf(format, args...)})
+}
+
+// Verify decodes the volume from reader and checks it for the problems seen in
+// damaged or truncated archives: a bad header or ICAO, radials out of order,
+// elevation scans missing radials or moments, moments the format version
+// can't hold, and no end of volume. Failing to
+// decode the volume is reported as a problem too, so the report is always
+// returned.
+func Verify(reader io.Reader) *VerifyReport {
+	r := &VerifyReport{Problems: []VerifyProblem{}}
+	ar2, err := Extract(reader)
+	if err != nil {
+		r.add(0, "decoding failed: %s", err)
+		return r
+	}
+	ar2.verify(r)
+	return r
+}
+
+func (ar2 *Archive2) verify(r *VerifyReport) {
+	s := ar2.Summary()
+	r.Site, r.VCP, r.Elevations, r.Radials = s.Site, s.VCP, len(s.Cuts), s.Radials
+
+	if name := ar2.VolumeHeader.FileName(); !strings.HasPrefix(name, "AR2V") {
+		r.add(0, "header doesn't start with AR2V: %q", name)
+	}
+	icao := byteString(ar2.VolumeHeader.ICAO[:])
+	if !validICAO(icao) {
+		r.add(0, "invalid ICAO %q", icao)
+	}
+	if ar2.RadarStatus == nil {
+		r.add(0, "no RDA status message")
+	}
+	if len(s.Cuts) == 0 {
+		r.add(0, "no radials")
+		return
+	}
+
+	var elevations []int
+	for elv := range ar2.ElevationScans {
+		elevations = append(elevations, elv)
+	}
+	sort.Ints(elevations)
+	for i, elv := range elevations {
+		if i > 0 && elv != elevations[i-1]+1 {
+			r.add(0, "elevations %d to %d missing", elevations[i-1]+1, elv-1)
+		}
+	}
+
+	version := ar2.VolumeHeader.Version()
+	versionMoments := map[string]bool{}
+	for _, name := range ar2.VolumeHeader.Moments() {
+		versionMoments[name] = true
+	}
+
+	ended := false
+	for _, elv := range elevations {
+		radials := ar2.ElevationScans[elv]
+		if len(radials) == 0 {
+			continue
+		}
+		if id := byteString(radials[0].Header.RadarIdentifier[:]); validICAO(icao) && id != icao {
+			r.add(elv, "radials are from %s, not %s", id, icao)
+		}
+
+		expected := int(360 / radials[0].Header.AzimuthResolutionSpacing())
+		if len(radials) != expected {
+			r.add(elv, "%d radials, expected %d", len(radials), expected)
+		}
+		for i := 1; i < len(radials); i++ {
+			if prev, cur := radials[i-1].Header.AzimuthNumber, radials[i].Header.AzimuthNumber; cur <= prev {
+				r.add(elv, "azimuth number %d follows %d", cur, prev)
+				break
+			}
+		}
+
+		for _, name := range MomentNames {
+			with := 0
+			for _, m31 := range radials {
+				if m31.Moment(name) != nil {
+					with++
+				}
+			}
+			if with > 0 && with < len(radials) {
+				r.add(elv, "%s missing from %d of %d radials", name, len(radials)-with, len(radials))
+			}
+			if with > 0 && !versionMoments[name] {
+				r.add(elv, "%s in a version %d volume, which can't hold it", name, version)
+			}
+		}
+
+		for _, m31 := range radials {
+			ended = ended || m31.Header.EndsVolume()
+		}
+	}
+	if !ended {
+		r.add(0, "no end of volume radial")
+	}
+}
+
+// validICAO reports whether the identifier looks like a radar's, i.e. four
+// upper case letters
+func validICAO(icao string) bool {
+	if len(icao) != 4 {
+		return false
+	}
+	for _, c := range icao {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
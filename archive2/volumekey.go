@@ -0,0 +1,54 @@
+package archive2
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"time"
+)
+
+// VolumeKey identifies a volume scan by its radar and start time, so the same
+// volume can be recognized in different sources, e.g. the archive and realtime
+// chunk buckets.
+type VolumeKey struct {
+	ICAO string
+	Time time.Time
+}
+
+func (k VolumeKey) String() string {
+	return fmt.Sprintf("%s %s", k.ICAO, k.Time.Format(time.RFC3339))
+}
+
+// Key returns the key of the volume described by the header
+func (vh VolumeHeaderRecord) Key() VolumeKey {
+	return VolumeKey{ICAO: string(vh.ICAO[:]), Time: vh.Date().Truncate(time.Second)}
+}
+
+var (
+	// archive volumes, e.g. KCRP20170825_235733_V06, KCRP20170825_235733_V06.gz
+	// or KCRP20170825_235733_V06.ar2v. The _MDM metadata files alongside them
+	// aren't volumes.
+	archiveVolumeName = regexp.MustCompile(`^([A-Z0-9]{4})(\d{8}_\d{6})(_V\d\d)?(\.gz|\.ar2v)?$`)
+	// realtime chunks, e.g. KCRP/596/20170825-235733-001-S
+	realtimeChunkName = regexp.MustCompile(`([A-Z0-9]{4})/\d+/(\d{8}-\d{6})-\d{3}-[SIE]$`)
+)
+
+// ParseVolumeKey parses the key of the volume from the name of an archive
+// volume or realtime chunk object. Directories before the name are ignored.
+func ParseVolumeKey(name string) (VolumeKey, bool) {
+	if m := realtimeChunkName.FindStringSubmatch(name); m != nil {
+		t, err := time.Parse("20060102-150405", m[2])
+		if err != nil {
+			return VolumeKey{}, false
+		}
+		return VolumeKey{ICAO: m[1], Time: t}, true
+	}
+	if m := archiveVolumeName.FindStringSubmatch(path.Base(name)); m != nil {
+		t, err := time.Parse("20060102_150405", m[2])
+		if err != nil {
+			return VolumeKey{}, false
+		}
+		return VolumeKey{ICAO: m[1], Time: t}, true
+	}
+	return VolumeKey{}, false
+}
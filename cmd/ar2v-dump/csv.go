@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/geo"
+	"github.com/kallsyms/go-nexrad/sites"
+)
+
+var csvFile string
+var csvProduct string
+
+func init() {
+	cmd.Flags().StringVar(&csvFile, "csv", "", "write a row per gate of the --product (of the --elevation, or all of them) to this CSV file")
+	cmd.Flags().StringVar(&csvProduct, "product", "ref", "with --csv, the moment to write: "+strings.ToLower(strings.Join(archive2.MomentNames, ", ")))
+}
+
+// checkCSVFlags validates the --product
+func checkCSVFlags() error {
+	for _, m := range archive2.MomentNames {
+		if strings.EqualFold(m, csvProduct) {
+			csvProduct = m
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown product %s, expected one of %s", csvProduct, strings.ToLower(strings.Join(archive2.MomentNames, ", ")))
+}
+
+// runCSV writes the --product's gates with values to the --csv file, with
+// their elevation and azimuth angles in degrees, range in meters to the
+// center of the gate and location in degrees
+func runCSV(ar2 *archive2.Archive2) error {
+	f, err := os.Create(csvFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	buf := bufio.NewWriter(f)
+	w := csv.NewWriter(buf)
+	w.Write([]string{"elevation", "azimuth", "range", "lat", "lon", strings.ToLower(csvProduct)})
+
+	rows := 0
+	// the summary's cuts are in elevation number order
+	for _, c := range ar2.Summary().Cuts {
+		if dumpElevation != 0 && c.ElevationNumber != dumpElevation {
+			continue
+		}
+		for _, r := range ar2.ElevationScans[c.ElevationNumber] {
+			d := r.Moment(csvProduct)
+			if d == nil || !inAzimuthRange(r.Header) {
+				continue
+			}
+			lat, lon, _, ok := sites.Locate(r)
+			if !ok {
+				return fmt.Errorf("the radar's location isn't in the volume or a known site")
+			}
+			elevation := float64(r.Header.ElevationAngle)
+			azimuth := float64(r.Header.AzimuthAngle)
+			gates := d.ScaledData()
+			for g, v := range gates {
+				if v == archive2.MomentDataBelowThreshold || v == archive2.MomentDataFolded {
+					continue
+				}
+				rng := float64(d.DataMomentRange) + float64(g)*float64(d.DataMomentRangeSampleInterval)
+				gateLat, gateLon := geo.Destination(lat, lon, azimuth, geo.GroundRange(rng, elevation))
+				w.Write([]string{
+					strconv.FormatFloat(elevation, 'f', 2, 32),
+					strconv.FormatFloat(azimuth, 'f', 2, 32),
+					strconv.FormatFloat(rng, 'f', -1, 64),
+					strconv.FormatFloat(gateLat, 'f', 5, 64),
+					strconv.FormatFloat(gateLon, 'f', 5, 64),
+					strconv.FormatFloat(float64(v), 'f', -1, 32),
+				})
+				rows++
+			}
+			archive2.ReleaseScaled(gates)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote %d gates to %s\n", rows, csvFile)
+	return nil
+}
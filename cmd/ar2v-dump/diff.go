@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff A B",
+	Short: "compare two archive 2 files, exiting non-zero if they decode differently",
+	Long: `diff compares the volume headers, status messages, LDM record and radial
+counts, radial headers and moment data of two archive 2 files, e.g. to debug
+decoders that disagree or a transfer that corrupted a file.`,
+	Args: cobra.ExactArgs(2),
+	Run:  runDiff,
+}
+
+func init() {
+	cmd.AddCommand(diffCmd)
+}
+
+func runDiff(cmd *cobra.Command, args []string) {
+	logrus.SetLevel(logrus.WarnLevel)
+	var volumes [2]*archive2.Archive2
+	for i, file := range args {
+		f, err := os.Open(file)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		volumes[i], err = archive2.Extract(f)
+		f.Close()
+		if err != nil {
+			logrus.Fatalf("%s: %s", file, err)
+		}
+	}
+
+	ds := archive2.Diff(volumes[0], volumes[1])
+	if format != "text" {
+		if err := encode(ds); err != nil {
+			logrus.Fatal(err)
+		}
+	} else {
+		for _, d := range ds {
+			fmt.Println(d)
+		}
+	}
+	if len(ds) > 0 {
+		stopProfiling()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/sirupsen/logrus"
+)
+
+var dumpM2 bool
+var dumpM3 bool
+var dumpHeaders bool
+var dumpMoments []string
+var dumpElevation int
+var dumpAzimuth string
+
+// dumpAzimuthFrom and dumpAzimuthTo are the --azimuth range in degrees
+var dumpAzimuthFrom, dumpAzimuthTo float64
+
+func init() {
+	cmd.Flags().BoolVar(&dumpM2, "dump-m2", false, "dump the RDA status message (message 2)")
+	cmd.Flags().BoolVar(&dumpM3, "dump-m3", false, "dump the RDA performance message (message 3)")
+	cmd.Flags().BoolVar(&dumpHeaders, "dump-m31-headers", false, "dump the headers of the radials (message 31)")
+	cmd.Flags().StringSliceVar(&dumpMoments, "dump-moments", nil, "dump the gates of these moments of the radials, e.g. REF,VEL")
+	cmd.Flags().IntVar(&dumpElevation, "elevation", 0, "only dump, report stats of or write as csv the radials of this elevation number")
+	cmd.Flags().StringVar(&dumpAzimuth, "azimuth", "", "only dump or write as csv radials in this range of azimuths in degrees, e.g. 245-255 or 355-5")
+}
+
+// dumping reports whether any --dump flags are set
+func dumping() bool {
+	return dumpM2 || dumpM3 || dumpHeaders || len(dumpMoments) > 0
+}
+
+// checkDumpFlags validates the --dump-moments and --azimuth flags
+func checkDumpFlags() error {
+	for i, name := range dumpMoments {
+		dumpMoments[i] = strings.ToUpper(name)
+		known := false
+		for _, m := range archive2.MomentNames {
+			known = known || m == dumpMoments[i]
+		}
+		if !known {
+			return fmt.Errorf("unknown moment %s, expected one of %s", name, strings.Join(archive2.MomentNames, ", "))
+		}
+	}
+
+	dumpAzimuthFrom, dumpAzimuthTo = 0, 360
+	if dumpAzimuth == "" {
+		return nil
+	}
+	from, to := dumpAzimuth, dumpAzimuth
+	if i := strings.Index(dumpAzimuth, "-"); i >= 0 {
+		from, to = dumpAzimuth[:i], dumpAzimuth[i+1:]
+	}
+	var err1, err2 error
+	dumpAzimuthFrom, err1 = strconv.ParseFloat(from, 64)
+	dumpAzimuthTo, err2 = strconv.ParseFloat(to, 64)
+	if err1 != nil || err2 != nil || dumpAzimuthFrom < 0 || dumpAzimuthFrom > 360 || dumpAzimuthTo < 0 || dumpAzimuthTo > 360 {
+		return fmt.Errorf("invalid --azimuth %q, expected degrees or a range of them such as 245-255", dumpAzimuth)
+	}
+	return nil
+}
+
+// inAzimuthRange reports whether the azimuth is in the --azimuth range, which
+// wraps through north if it ends before it starts. A single azimuth matches
+// the radial covering it.
+func inAzimuthRange(h archive2.Message31Header) bool {
+	az := float64(h.AzimuthAngle)
+	from, to := dumpAzimuthFrom, dumpAzimuthTo
+	if from == to {
+		half := h.AzimuthResolutionSpacing() / 2
+		d := math.Mod(from-az+540, 360) - 180
+		return d >= -half && d < half
+	}
+	if from <= to {
+		return az >= from && az <= to
+	}
+	return az >= from || az <= to
+}
+
+// messageDump is what the --dump flags write
+type messageDump struct {
+	RadarStatus      *archive2.Message2 `json:",omitempty"`
+	RadarPerformance *archive2.Message3 `json:",omitempty"`
+	Radials          []radialDump       `json:",omitempty"`
+}
+
+type radialDump struct {
+	ElevationNumber int
+	AzimuthAngle    float32
+	// Header is set with --dump-m31-headers
+	Header *archive2.Message31Header `json:",omitempty"`
+	// Moments are the --dump-moments the radial has
+	Moments map[string]*archive2.DataMoment `json:",omitempty"`
+}
+
+// runDump writes the messages selected by the --dump flags, in the --format.
+// Text is written as YAML, which reads well and can still be parsed.
+func runDump(ar2 *archive2.Archive2) error {
+	archive2.JSONScaledMoments = scaled
+	d := messageDump{}
+	if dumpM2 {
+		if d.RadarStatus = ar2.RadarStatus; d.RadarStatus == nil {
+			logrus.Warn("no RDA status message in the volume")
+		}
+	}
+	if dumpM3 {
+		if d.RadarPerformance = ar2.RadarPerformance; d.RadarPerformance == nil {
+			logrus.Warn("no RDA performance message in the volume")
+		}
+	}
+	if dumpHeaders || len(dumpMoments) > 0 {
+		// the summary's cuts are in elevation number order
+		for _, c := range ar2.Summary().Cuts {
+			if dumpElevation != 0 && c.ElevationNumber != dumpElevation {
+				continue
+			}
+			for _, m31 := range ar2.ElevationScans[c.ElevationNumber] {
+				if !inAzimuthRange(m31.Header) {
+					continue
+				}
+				r := radialDump{ElevationNumber: c.ElevationNumber, AzimuthAngle: m31.Header.AzimuthAngle}
+				if dumpHeaders {
+					h := m31.Header
+					r.Header = &h
+				}
+				for _, name := range dumpMoments {
+					if m := m31.Moment(name); m != nil {
+						if r.Moments == nil {
+							r.Moments = map[string]*archive2.DataMoment{}
+						}
+						r.Moments[name] = m
+					}
+				}
+				d.Radials = append(d.Radials, r)
+			}
+		}
+	}
+	if format == "json" {
+		return encode(d)
+	}
+	return writeYAML(os.Stdout, d)
+}
@@ -1,27 +1,220 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/kallsyms/go-nexrad/archive2"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
 )
 
+var cmd = &cobra.Command{
+	Use:   "ar2v-dump [FILE]",
+	Short: "ar2v-dump prints the header and status of an archive 2 file, test.ar2v by default.",
+	Args:  cobra.MaximumNArgs(1),
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if format != "text" && format != "json" && format != "yaml" {
+			return fmt.Errorf("unsupported format %s, expected text, json or yaml", format)
+		}
+		return startProfiling()
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		stopProfiling()
+	},
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if statsBins < 1 {
+			return fmt.Errorf("invalid --bins %d, expected at least 1", statsBins)
+		}
+		if err := checkCSVFlags(); err != nil {
+			return err
+		}
+		return checkDumpFlags()
+	},
+	Run: run,
+}
+
+var format string
+var radials bool
+var asJSON bool
+var scaled bool
+var summary bool
+var verify bool
+
+func init() {
+	cmd.PersistentFlags().StringVarP(&format, "format", "F", "text", "output format: text, or json or yaml for scripts")
+	cmd.Flags().BoolVar(&radials, "radials", false, "with --format json or yaml, include the header of every radial")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "write the whole decoded volume, as JSON or with --format yaml as YAML")
+	cmd.Flags().BoolVar(&scaled, "scaled", false, "with --json or --dump-moments, write moment gates as scaled values rather than raw bytes")
+	cmd.Flags().BoolVar(&summary, "summary", false, "with --json, write the volume summary rather than the whole volume")
+	cmd.Flags().BoolVar(&verify, "verify", false, "check the volume's integrity, exiting non-zero if there are problems")
+}
+
 func main() {
-	f, err := os.Open("test.ar2v")
+	if err := cmd.Execute(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func run(cmd *cobra.Command, args []string) {
+	file := "test.ar2v"
+	if len(args) > 0 {
+		file = args[0]
+	}
+	f, err := os.Open(file)
 	logrus.SetLevel(logrus.DebugLevel)
-	defer f.Close()
+	if asJSON || format != "text" || dumping() || stats || traceOffsets || csvFile != "" {
+		logrus.SetLevel(logrus.WarnLevel)
+	}
 	if err != nil {
-		logrus.Error(err)
+		logrus.Fatal(err)
+	}
+	defer f.Close()
+
+	if verify {
+		runVerify(f)
 		return
 	}
 
-	ar2 := archive2.Extract(f)
+	ar2, err := archive2.Extract(f)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	if csvFile != "" {
+		if err := runCSV(ar2); err != nil {
+			logrus.Fatal(err)
+		}
+		return
+	}
+	if traceOffsets {
+		if err := runTrace(f, ar2); err != nil {
+			logrus.Fatal(err)
+		}
+		return
+	}
+	if stats {
+		if err := runStats(ar2); err != nil {
+			logrus.Fatal(err)
+		}
+		return
+	}
+	if dumping() {
+		if err := runDump(ar2); err != nil {
+			logrus.Fatal(err)
+		}
+		return
+	}
+	if asJSON {
+		archive2.JSONScaledMoments = scaled
+		var v interface{} = ar2
+		if summary {
+			v = ar2.Summary()
+		}
+		if err := encode(v); err != nil {
+			logrus.Fatal(err)
+		}
+		return
+	}
+	if format != "text" {
+		if err := encode(newReport(ar2, radials)); err != nil {
+			logrus.Fatal(err)
+		}
+		return
+	}
 
 	fmt.Printf("Station: %s\n", ar2.VolumeHeader.ICAO)
 	fmt.Printf("Date: %s\n", ar2.VolumeHeader.Date())
 	fmt.Printf("File: %s\n", ar2.VolumeHeader.FileName())
+	if m2 := ar2.RadarStatus; m2 != nil {
+		fmt.Printf("RDA: %s, %s, %s mode, build %.2f\n", m2.RDAStatusString(), m2.OperabilityStatusString(), m2.OperationalModeString(), m2.GetBuildNumber())
+		fmt.Printf("VCP: %d (%s)\n", m2.VCP(), m2.VCPDescription())
+		fmt.Printf("Alarms: %s\n", m2.AlarmSummaryString())
+	}
+	if m3 := ar2.RadarPerformance; m3 != nil {
+		fmt.Println(m3)
+	}
+	s := ar2.Summary()
+	if s.MaxReflectivity != nil {
+		fmt.Printf("Max reflectivity: %.1f dBZ\n", *s.MaxReflectivity)
+	}
+	for _, c := range s.Cuts {
+		fmt.Printf("Elevation %2d: %5.2f deg, %d radials, %s - %s, %s\n",
+			c.ElevationNumber, c.ElevationAngle, c.Radials, c.Start.Format("15:04:05"), c.End.Format("15:04:05"), strings.Join(c.Moments, " "))
+	}
+}
+
+// runVerify checks the volume, printing the report (as JSON with --json, or
+// in the --format) and exiting non-zero if there are problems
+func runVerify(f *os.File) {
+	logrus.SetLevel(logrus.WarnLevel)
+	r := archive2.Verify(f)
+	if asJSON || format != "text" {
+		if err := encode(r); err != nil {
+			logrus.Fatal(err)
+		}
+	} else {
+		fmt.Printf("%s: %s, VCP %d, %d elevations, %d radials\n", f.Name(), r.Site, r.VCP, r.Elevations, r.Radials)
+		for _, p := range r.Problems {
+			fmt.Println(p)
+		}
+		if r.OK() {
+			fmt.Println("OK")
+		}
+	}
+	if !r.OK() {
+		stopProfiling()
+		os.Exit(1)
+	}
+}
+
+// encode writes the value to stdout as YAML with --format yaml, otherwise as
+// indented JSON
+func encode(v interface{}) error {
+	if format == "yaml" {
+		return writeYAML(os.Stdout, v)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// report is what --format json and yaml write: what the text output prints,
+// and optionally the radials' headers
+type report struct {
+	VolumeHeader     archive2.VolumeHeaderRecord
+	RadarStatus      *archive2.Message2 `json:",omitempty"`
+	RadarPerformance *archive2.Message3 `json:",omitempty"`
+	Summary          archive2.Summary
+	// Radials are the headers of each elevation's radials, with --radials
+	Radials []elevationRadials `json:",omitempty"`
+}
+
+type elevationRadials struct {
+	ElevationNumber int
+	Headers         []archive2.Message31Header
+}
 
-	// spew.Dump(ar2.VolumeHeader)
+func newReport(ar2 *archive2.Archive2, withRadials bool) report {
+	r := report{
+		VolumeHeader:     ar2.VolumeHeader,
+		RadarStatus:      ar2.RadarStatus,
+		RadarPerformance: ar2.RadarPerformance,
+		Summary:          ar2.Summary(),
+	}
+	if !withRadials {
+		return r
+	}
+	// the summary's cuts are in elevation number order
+	for _, c := range r.Summary.Cuts {
+		e := elevationRadials{ElevationNumber: c.ElevationNumber}
+		for _, m31 := range ar2.ElevationScans[c.ElevationNumber] {
+			e.Headers = append(e.Headers, m31.Header)
+		}
+		r.Radials = append(r.Radials, e)
+	}
+	return r
 }
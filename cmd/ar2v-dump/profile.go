@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	// registers the profiling handlers on http.DefaultServeMux
+	_ "net/http/pprof"
+	"os"
+	"runtime"
+	"runtime/pprof"
+
+	"github.com/sirupsen/logrus"
+)
+
+var cpuProfile string
+var memProfile string
+var pprofAddr string
+
+var cpuProfileFile *os.File
+
+func init() {
+	cmd.PersistentFlags().StringVar(&cpuProfile, "cpuprofile", "", "write a CPU profile to this file")
+	cmd.PersistentFlags().StringVar(&memProfile, "memprofile", "", "write a heap profile to this file when done")
+	cmd.PersistentFlags().StringVar(&pprofAddr, "pprof-addr", "", "serve net/http/pprof on this address while running, e.g. localhost:6060")
+}
+
+// startProfiling starts the profiling asked for by the flags. Nothing is
+// profiled, or written, by default.
+func startProfiling() error {
+	if pprofAddr != "" {
+		go func() {
+			if err := http.ListenAndServe(pprofAddr, nil); err != nil {
+				logrus.Errorf("pprof: %s", err)
+			}
+		}()
+	}
+	if cpuProfile == "" {
+		return nil
+	}
+	f, err := os.Create(cpuProfile)
+	if err != nil {
+		return err
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		return err
+	}
+	cpuProfileFile = f
+	return nil
+}
+
+// stopProfiling writes the profiles, after commands run and before they exit
+// non-zero for what they found. Commands failing with an error don't write
+// them.
+func stopProfiling() {
+	if cpuProfileFile != nil {
+		pprof.StopCPUProfile()
+		cpuProfileFile.Close()
+		cpuProfileFile = nil
+	}
+	if memProfile == "" {
+		return
+	}
+	f, err := os.Create(memProfile)
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+	defer f.Close()
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		logrus.Error(err)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+var stats bool
+var statsBins int
+
+// histogramWidth is the length in characters of the longest histogram bar
+const histogramWidth = 40
+
+func init() {
+	cmd.Flags().BoolVar(&stats, "stats", false, "report each elevation's moments' min, max and mean, gates below threshold and range folded, and a histogram")
+	cmd.Flags().IntVar(&statsBins, "bins", 20, "with --stats, the number of histogram bins")
+}
+
+// runStats writes the --stats of the volume (or the --elevation) in the
+// --format
+func runStats(ar2 *archive2.Archive2) error {
+	elevations := []archive2.ElevationStats{}
+	for _, e := range ar2.Stats(statsBins) {
+		if dumpElevation == 0 || e.ElevationNumber == dumpElevation {
+			elevations = append(elevations, e)
+		}
+	}
+	if format != "text" {
+		return encode(elevations)
+	}
+
+	for _, e := range elevations {
+		fmt.Printf("Elevation %d:\n", e.ElevationNumber)
+		for _, m := range e.Moments {
+			fmt.Printf("  %s: %d gates, %.1f%% below threshold, %.1f%% range folded", m.Moment, m.Gates, m.BelowThresholdPercent(), m.RangeFoldedPercent())
+			if m.Histogram == nil {
+				fmt.Println()
+				continue
+			}
+			fmt.Printf(", min %.2f, max %.2f, mean %.2f\n", m.Min, m.Max, m.Mean)
+			most := 0
+			for _, n := range m.Histogram {
+				if n > most {
+					most = n
+				}
+			}
+			for i, n := range m.Histogram {
+				from := m.Min + float32(i)*m.BinWidth
+				bar := strings.Repeat("#", (n*histogramWidth+most-1)/most)
+				fmt.Printf("    %9.2f to %9.2f |%-*s %d\n", from, from+m.BinWidth, histogramWidth, bar, n)
+			}
+		}
+	}
+	return nil
+}
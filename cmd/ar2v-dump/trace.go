@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+var traceOffsets bool
+
+func init() {
+	cmd.Flags().BoolVar(&traceOffsets, "trace-offsets", false, "print the file offset and size of every LDM record, and the offset in the decompressed record and size of every message and data block")
+}
+
+// runTrace writes the layout of the volume's records, read from f with the
+// index of the volume decoded from it, in the --format
+func runTrace(f *os.File, ar2 *archive2.Archive2) error {
+	layouts, err := archive2.Layout(f, ar2.Index())
+	if format != "text" {
+		if encErr := encode(layouts); encErr != nil {
+			return encErr
+		}
+		return err
+	}
+
+	// messages and blocks are at offsets in their decompressed record
+	fmt.Printf("%10s %8s\n", "offset", "size")
+	fmt.Printf("%10d %8d volume header\n", 0, 24)
+	for i, r := range layouts {
+		fmt.Printf("%10d %8d LDM record %d, %d bytes decompressed\n", r.Offset, r.Size, i, r.DecompressedSize)
+		for _, m := range r.Messages {
+			h := m.Header
+			fmt.Printf("%10s %8d   message %d, sequence %d, segment %d of %d\n", fmt.Sprintf("+%d", m.Offset), m.Size, h.MessageType, h.IDSequenceNumber, h.MessageSegmentNum, h.NumMessageSegments)
+			for _, b := range m.Blocks {
+				fmt.Printf("%10s %8d     %s\n", fmt.Sprintf("+%d", b.Offset), b.Size, b.Name)
+			}
+		}
+	}
+	return err
+}
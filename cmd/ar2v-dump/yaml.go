@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// yamlNode is a value decoded from JSON, keeping the order of object keys so
+// the YAML is in the order of the JSON (and the Go structs)
+type yamlNode struct {
+	keys   []string
+	values []*yamlNode
+	items  []*yamlNode
+	// scalar is the YAML of strings, numbers, booleans and null; objects and
+	// arrays have none
+	scalar   string
+	isObject bool
+	isArray  bool
+}
+
+// writeYAML writes the value as block style YAML, by way of its JSON encoding
+// so the archive2 types' MarshalJSON methods apply
+func writeYAML(w io.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	n, err := decodeYAMLNode(dec)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	writeYAMLNode(&buf, n, 0, false)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func decodeYAMLNode(dec *json.Decoder) (*yamlNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	n := &yamlNode{}
+	switch t := tok.(type) {
+	case json.Delim:
+		n.isObject, n.isArray = t == '{', t == '['
+		for dec.More() {
+			if n.isObject {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				n.keys = append(n.keys, key.(string))
+			}
+			v, err := decodeYAMLNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			if n.isObject {
+				n.values = append(n.values, v)
+			} else {
+				n.items = append(n.items, v)
+			}
+		}
+		// the closing delimiter
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	case string:
+		n.scalar = yamlString(t)
+	case json.Number:
+		n.scalar = t.String()
+	case bool:
+		n.scalar = strconv.FormatBool(t)
+	case nil:
+		n.scalar = "null"
+	}
+	return n, nil
+}
+
+// writeYAMLNode writes the node indented by indent spaces. inline is set when
+// the first line's indentation has been written, after a sequence's "- ".
+func writeYAMLNode(buf *bytes.Buffer, n *yamlNode, indent int, inline bool) {
+	pad := strings.Repeat(" ", indent)
+	switch {
+	case n.isObject && len(n.keys) > 0:
+		for i, key := range n.keys {
+			if i > 0 || !inline {
+				buf.WriteString(pad)
+			}
+			buf.WriteString(yamlString(key) + ":")
+			writeYAMLValue(buf, n.values[i], indent+2)
+		}
+	case n.isArray && len(n.items) > 0:
+		for i, item := range n.items {
+			if i > 0 || !inline {
+				buf.WriteString(pad)
+			}
+			buf.WriteString("- ")
+			if item.isObject && len(item.keys) > 0 || item.isArray && len(item.items) > 0 {
+				writeYAMLNode(buf, item, indent+2, true)
+			} else {
+				buf.WriteString(yamlScalar(item) + "\n")
+			}
+		}
+	default:
+		buf.WriteString(yamlScalar(n) + "\n")
+	}
+}
+
+// writeYAMLValue writes the value of a key, on the key's line if it's a
+// scalar or empty, otherwise on the lines below
+func writeYAMLValue(buf *bytes.Buffer, n *yamlNode, indent int) {
+	if n.isObject && len(n.keys) > 0 || n.isArray && len(n.items) > 0 {
+		buf.WriteString("\n")
+		writeYAMLNode(buf, n, indent, false)
+		return
+	}
+	buf.WriteString(" " + yamlScalar(n) + "\n")
+}
+
+func yamlScalar(n *yamlNode) string {
+	switch {
+	case n.isObject:
+		return "{}"
+	case n.isArray:
+		return "[]"
+	}
+	return n.scalar
+}
+
+// plainYAML matches strings that can be written unquoted
+var plainYAML = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_ ./-]*$`)
+
+// yamlString quotes the string unless it's plain and can't be read as another
+// type, e.g. "true" or "null". JSON strings are valid double quoted YAML.
+func yamlString(s string) string {
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null":
+	default:
+		if plainYAML.MatchString(s) && !strings.HasSuffix(s, " ") {
+			return s
+		}
+	}
+	b, _ := json.Marshal(s)
+	return string(b)
+}
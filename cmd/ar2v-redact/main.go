@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var cmd = &cobra.Command{
+	Use:   "ar2v-redact",
+	Short: "ar2v-redact strips the moment data from an archive 2 file, keeping its structure, to make shareable test fixtures.",
+	Run:   run,
+}
+
+var inputFile string
+var outputFile string
+var moments string
+var icao string
+var clearLocation bool
+
+func init() {
+	cmd.PersistentFlags().StringVarP(&inputFile, "file", "f", "", "archive 2 file to redact")
+	cmd.PersistentFlags().StringVarP(&outputFile, "output", "o", "redacted.ar2v", "output archive 2 file")
+	cmd.PersistentFlags().StringVarP(&moments, "moments", "m", "zero", "replacement moment data. zero, synthetic")
+	cmd.PersistentFlags().StringVar(&icao, "icao", "", "replace the radar identifier, ex: KXXX")
+	cmd.PersistentFlags().BoolVar(&clearLocation, "clear-location", false, "zero the site latitude and longitude")
+}
+
+func main() {
+	if err := cmd.Execute(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func run(cmd *cobra.Command, args []string) {
+	if inputFile == "" {
+		cmd.Usage()
+		return
+	}
+
+	opts := archive2.RedactOptions{ICAO: icao, ClearLocation: clearLocation}
+	switch moments {
+	case "zero":
+		opts.Moments = archive2.RedactZero
+	case "synthetic":
+		opts.Moments = archive2.RedactSynthetic
+	default:
+		logrus.Fatalf("unsupported moment replacement %s", moments)
+	}
+
+	in, err := os.Open(inputFile)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(outputFile)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	if err := archive2.Redact(in, out, opts); err != nil {
+		out.Close()
+		os.Remove(outputFile)
+		logrus.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		logrus.Fatal(err)
+	}
+	fmt.Printf("Redacted %s -> %s\n", inputFile, outputFile)
+}
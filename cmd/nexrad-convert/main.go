@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cheggaaa/pb/v3"
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/container"
+	"github.com/kallsyms/go-nexrad/derived"
+	"github.com/kallsyms/go-nexrad/export/cfradial"
+	"github.com/kallsyms/go-nexrad/export/geotiff"
+	"github.com/kallsyms/go-nexrad/export/grib2"
+	"github.com/kallsyms/go-nexrad/export/odim"
+	"github.com/kallsyms/go-nexrad/export/parquet"
+	"github.com/kallsyms/go-nexrad/export/zarr"
+	"github.com/kallsyms/go-nexrad/fetch"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var cmd = &cobra.Command{
+	Use:   "nexrad-convert",
+	Short: "nexrad-convert converts NEXRAD Level 2 (archive 2) data files into analysis formats.",
+	Run:   run,
+}
+
+var inputFile string
+var directory string
+var outputDir string
+var format string
+var logLevel string
+var runners int
+var force bool
+
+// converter writes a volume to the given output path. Converters that can
+// write directly to S3 set writeS3, which is passed an s3://bucket/key URL.
+type converter struct {
+	ext     string
+	write   func(out string, ar2 *archive2.Archive2) error
+	writeS3 func(url string, ar2 *archive2.Archive2) error
+}
+
+var converters = map[string]converter{
+	"cfradial": {".nc", writeCfRadial, nil},
+	"cog":      {".tif", writeCOG, nil},
+	"grib2":    {".grib2", writeGRIB2, nil},
+	"odim":     {".h5", writeODIM, nil},
+	"parquet":  {".parquet", writeParquet, nil},
+	"zarr":     {".zarr", writeZarr, writeZarrS3},
+}
+
+// archive2Name matches the names volumes are distributed with, e.g.
+// KCRP20170825_235733_V06 or KMOB20210830_130003_V06.ar2v
+var archive2Name = regexp.MustCompile(`(_V\d\d|\.ar2v)$`)
+
+// isVolume reports whether the file name is that of a (possibly gzipped) volume
+func isVolume(name string) bool {
+	return archive2Name.MatchString(strings.TrimSuffix(name, ".gz"))
+}
+
+func init() {
+	cmd.PersistentFlags().StringVarP(&inputFile, "file", "f", "", "archive 2 file, or tar or zip of them, to convert")
+	cmd.PersistentFlags().StringVarP(&directory, "directory", "d", "", "directory of L2 files and tar or zip containers to convert, searched recursively, or s3:// or gs:// bucket prefix of volumes")
+	cmd.PersistentFlags().StringVarP(&outputDir, "output", "o", "out", "output directory, or s3://bucket/prefix for formats supporting it")
+	cmd.PersistentFlags().StringVarP(&format, "format", "F", "cfradial", "output format. ex: "+strings.Join(formatNames(), ", "))
+	cmd.PersistentFlags().StringVarP(&logLevel, "log-level", "l", "warn", "log level, debug, info, warn, error")
+	cmd.PersistentFlags().IntVarP(&runners, "threads", "t", runtime.NumCPU(), "threads")
+	cmd.PersistentFlags().BoolVar(&force, "force", false, "reconvert files whose output already exists")
+}
+
+func main() {
+	if err := cmd.Execute(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func formatNames() []string {
+	names := []string{}
+	for name := range converters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func run(cmd *cobra.Command, args []string) {
+	lvl, err := logrus.ParseLevel(logLevel)
+	if err != nil {
+		logrus.Fatalf("failed to parse level: %s", err)
+	}
+	logrus.SetLevel(lvl)
+
+	conv, ok := converters[format]
+	if !ok {
+		logrus.Fatalf("unsupported format %s", format)
+	}
+	if isS3(outputDir) && conv.writeS3 == nil {
+		logrus.Fatalf("format %s can't be written to s3", format)
+	}
+
+	var walk walker
+	if inputFile != "" {
+		walk = walkFiles([]string{inputFile}, filepath.Dir(inputFile))
+	} else if fetch.IsURL(directory) {
+		walk, err = walkBucket(directory)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+	} else if directory != "" {
+		inputs, err := findVolumes(directory)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		walk = walkFiles(inputs, directory)
+	} else {
+		cmd.Usage()
+		return
+	}
+
+	failed, total := convertAll(walk, conv)
+	if failed > 0 {
+		logrus.Errorf("%d of %d volumes failed to convert", failed, total)
+		os.Exit(1)
+	}
+}
+
+// findVolumes returns all the archive 2 files and containers under dir
+func findVolumes(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() && (isVolume(info.Name()) || container.IsContainer(info.Name())) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// job is a volume to convert
+type job struct {
+	// name of the volume in log messages
+	name string
+	// rel is the path of the output relative to the output directory,
+	// without its extension
+	rel  string
+	load func() (*archive2.Archive2, error)
+}
+
+// walker calls queue with each volume to convert, and fail with inputs that
+// couldn't be read
+type walker func(queue func(j job), fail func(name string, err error))
+
+// walkFiles walks the volumes in the files and containers in inputs, whose
+// outputs mirror their layout under root. Volumes in containers are written
+// to a directory named after the container.
+func walkFiles(inputs []string, root string) walker {
+	return func(queue func(j job), fail func(name string, err error)) {
+		for _, in := range inputs {
+			err := container.Walk(in, isVolume, func(v *container.Volume) error {
+				name := v.Name
+				if v.Container != "" {
+					name = filepath.Join(container.TrimExt(v.Container), filepath.FromSlash(v.Member))
+				}
+				rel, err := outputName(name, root)
+				if err != nil {
+					fail(v.Name, err)
+					return nil
+				}
+				queue(job{v.Name, rel, v.Load})
+				return nil
+			})
+			if err != nil {
+				fail(in, err)
+			}
+		}
+	}
+}
+
+// walkBucket walks the volumes under the bucket prefix URL, whose outputs
+// mirror their layout under the prefix's directory. The bucket is read
+// anonymously, as by fetch.ParseURL.
+func walkBucket(url string) (walker, error) {
+	c, prefix, err := fetch.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	volumes, err := c.Prefix(context.Background(), prefix)
+	if err != nil {
+		return nil, err
+	}
+	// the directory of the prefix, which is the prefix itself if it ends in /
+	root := filepath.FromSlash(path.Dir(prefix + "x"))
+	return func(queue func(j job), fail func(name string, err error)) {
+		for _, v := range volumes {
+			key := v.Key
+			rel, err := outputName(filepath.FromSlash(key), root)
+			if err != nil {
+				fail(key, err)
+				continue
+			}
+			queue(job{key, rel, func() (*archive2.Archive2, error) {
+				return c.Extract(context.Background(), key)
+			}})
+		}
+	}, nil
+}
+
+// convertAll converts the volumes walk finds in parallel. Volumes whose output
+// already exists are skipped so an interrupted run can be resumed. It returns
+// the number of failed conversions and the number of volumes found.
+func convertAll(walk walker, conv converter) (int, int) {
+	// the number of volumes in containers isn't known until they're read, so
+	// the total grows as they're found
+	bar := pb.StartNew(0)
+	defer bar.Finish()
+
+	var mtx sync.Mutex
+	failed := 0
+	fail := func(name string, err error) {
+		logrus.Errorf("%s: %s", name, err)
+		mtx.Lock()
+		failed++
+		mtx.Unlock()
+	}
+
+	source := make(chan job, runners)
+	wg := sync.WaitGroup{}
+	wg.Add(runners)
+	for i := 0; i < runners; i++ {
+		go func() {
+			defer wg.Done()
+			for j := range source {
+				if err := convert(j, conv); err != nil {
+					fail(j.name, err)
+				}
+				bar.Increment()
+			}
+		}()
+	}
+
+	total := 0
+	walk(func(j job) {
+		total++
+		bar.SetTotal(int64(total))
+		source <- j
+	}, fail)
+	close(source)
+	wg.Wait()
+	return failed, total
+}
+
+// outputName returns the path of the output of the volume at in relative to
+// the output directory, which it must stay under, without its extension
+func outputName(in, root string) (string, error) {
+	rel, err := filepath.Rel(root, in)
+	if err != nil {
+		rel = filepath.Base(in)
+	}
+	rel = strings.TrimSuffix(strings.TrimSuffix(rel, ".gz"), ".ar2v")
+	if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("output %s would be outside the output directory", rel)
+	}
+	return rel, nil
+}
+
+func convert(j job, conv converter) error {
+	rel := j.rel + conv.ext
+	if isS3(outputDir) {
+		// S3 outputs are always written, there's no cheap way to tell if a
+		// previous upload completed
+		ar2, err := j.load()
+		if err != nil {
+			return err
+		}
+		return conv.writeS3(strings.TrimSuffix(outputDir, "/")+"/"+filepath.ToSlash(rel), ar2)
+	}
+
+	out := filepath.Join(outputDir, rel)
+	if _, err := os.Stat(out); err == nil && !force {
+		logrus.Debugf("skipping %s, %s exists", j.name, out)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(out), os.ModePerm); err != nil {
+		return err
+	}
+
+	ar2, err := j.load()
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary path and move it into place once complete, so an
+	// interrupted conversion is redone rather than skipped on the next run.
+	partial := out + ".partial"
+	os.RemoveAll(partial)
+	if err := conv.write(partial, ar2); err != nil {
+		os.RemoveAll(partial)
+		return err
+	}
+	os.RemoveAll(out)
+	return os.Rename(partial, out)
+}
+
+func writeCfRadial(out string, ar2 *archive2.Archive2) error {
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := cfradial.Write(f, ar2); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+const (
+	// gridded products cover the full range of the radar at about 1 km
+	gridRadius = 460000
+	gridSize   = 920
+)
+
+// writeCOG writes the gridded composite reflectivity of the volume as a Cloud
+// Optimized GeoTIFF
+func writeCOG(out string, ar2 *archive2.Archive2) error {
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := geotiff.Write(f, derived.CompositeReflectivity(ar2, gridRadius, gridSize), geotiff.Options{COG: true}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeGRIB2 writes the gridded composite reflectivity, echo tops and
+// precipitation rate of the volume
+func writeGRIB2(out string, ar2 *archive2.Archive2) error {
+	rate := derived.PrecipitationRate(ar2, gridRadius, gridSize)
+	// mm/h to kg m-2 s-1
+	for i := range rate.Values {
+		rate.Values[i] /= 3600
+	}
+	t := ar2.VolumeHeader.Date()
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	err = grib2.Write(f,
+		grib2.Field{Parameter: grib2.CompositeReflectivity, Time: t, Grid: derived.CompositeReflectivity(ar2, gridRadius, gridSize)},
+		grib2.Field{Parameter: grib2.EchoTop, Time: t, Grid: derived.EchoTops(ar2, derived.EchoTopThreshold, gridRadius, gridSize)},
+		grib2.Field{Parameter: grib2.PrecipitationRate, Time: t, Grid: rate},
+	)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeODIM(out string, ar2 *archive2.Archive2) error {
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := odim.Write(f, ar2); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeParquet(out string, ar2 *archive2.Archive2) error {
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := parquet.Write(f, ar2, parquet.Options{}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeZarr(out string, ar2 *archive2.Archive2) error {
+	return zarr.Write(zarr.NewDirStore(out), ar2)
+}
+
+func writeZarrS3(url string, ar2 *archive2.Archive2) error {
+	s, err := zarr.NewS3Store(url)
+	if err != nil {
+		return err
+	}
+	return zarr.Write(s, ar2)
+}
+
+func isS3(path string) bool {
+	return strings.HasPrefix(path, "s3://")
+}
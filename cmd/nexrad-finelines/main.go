@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/detect"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var cmd = &cobra.Command{
+	Use:   "nexrad-finelines",
+	Short: "nexrad-finelines detects gust fronts and other fine lines in NEXRAD Level 2 (archive 2) data files, writing them as GeoJSON.",
+	Run:   run,
+}
+
+var inputFile string
+var directory string
+var outputDir string
+var logLevel string
+var elevation int
+var opts = detect.DefaultFineLineOptions
+
+func init() {
+	cmd.PersistentFlags().StringVarP(&inputFile, "file", "f", "", "archive 2 file to process")
+	cmd.PersistentFlags().StringVarP(&directory, "directory", "d", "", "directory of consecutive L2 files to process, tracking lines between them")
+	cmd.PersistentFlags().StringVarP(&outputDir, "output", "o", "out", "output directory")
+	cmd.PersistentFlags().StringVarP(&logLevel, "log-level", "l", "warn", "log level, debug, info, warn, error")
+	cmd.PersistentFlags().IntVarP(&elevation, "elevation", "e", 0, "elevation number to search. defaults to the lowest with reflectivity and velocity")
+	cmd.PersistentFlags().Float32Var(&opts.MinReflectivity, "min-dbz", opts.MinReflectivity, "minimum reflectivity of a line")
+	cmd.PersistentFlags().Float32Var(&opts.MaxReflectivity, "max-dbz", opts.MaxReflectivity, "maximum reflectivity of a line")
+	cmd.PersistentFlags().Float32Var(&opts.Contrast, "contrast", opts.Contrast, "dB a line must exceed its surroundings by")
+	cmd.PersistentFlags().Float64Var(&opts.Convergence, "convergence", opts.Convergence, "minimum convergence (s^-1) along a line. 0 to ignore velocity")
+	cmd.PersistentFlags().Float64Var(&opts.MinLength, "min-length", opts.MinLength, "minimum line length in meters")
+}
+
+func main() {
+	if err := cmd.Execute(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func run(cmd *cobra.Command, args []string) {
+	lvl, err := logrus.ParseLevel(logLevel)
+	if err != nil {
+		logrus.Fatalf("failed to parse level: %s", err)
+	}
+	logrus.SetLevel(lvl)
+
+	var inputs []string
+	if inputFile != "" {
+		inputs = []string{inputFile}
+	} else if directory != "" {
+		files, err := ioutil.ReadDir(directory)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		for _, f := range files {
+			if f.Mode().IsRegular() {
+				inputs = append(inputs, filepath.Join(directory, f.Name()))
+			}
+		}
+		// volumes are named by time, so this puts them in order
+		sort.Strings(inputs)
+	} else {
+		cmd.Usage()
+		return
+	}
+
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		logrus.Fatal(err)
+	}
+
+	var previous []*detect.Line
+	for _, in := range inputs {
+		lines, err := process(in)
+		if err != nil {
+			logrus.Errorf("%s: %s", in, err)
+			previous = nil
+			continue
+		}
+		detect.Track(previous, lines)
+		previous = lines
+
+		out := filepath.Join(outputDir, strings.TrimSuffix(filepath.Base(in), ".ar2v")+".geojson")
+		if err := write(out, lines); err != nil {
+			logrus.Errorf("%s: %s", out, err)
+		}
+		logrus.Infof("%s: %d lines", in, len(lines))
+	}
+}
+
+func process(in string) ([]*detect.Line, error) {
+	ar2, err := archive2.NewArchive2FromFile(in)
+	if err != nil {
+		return nil, err
+	}
+	elv := elevation
+	if elv == 0 {
+		elv = lowestDopplerElevation(ar2)
+	}
+	radials := ar2.ElevationScans[elv]
+	if len(radials) == 0 {
+		return nil, fmt.Errorf("no radials in elevation %d", elv)
+	}
+	return detect.FineLines(radials, opts), nil
+}
+
+// lowestDopplerElevation returns the lowest elevation number with both
+// reflectivity and velocity. The lowest cuts are split, with the surveillance
+// scan (reflectivity only) followed by a doppler scan at the same angle.
+func lowestDopplerElevation(ar2 *archive2.Archive2) int {
+	var elevations []int
+	for elv := range ar2.ElevationScans {
+		elevations = append(elevations, elv)
+	}
+	sort.Ints(elevations)
+	for _, elv := range elevations {
+		moments := map[string]bool{}
+		for _, m := range ar2.AvailableMoments(elv) {
+			moments[m] = true
+		}
+		if moments["REF"] && moments["VEL"] {
+			return elv
+		}
+	}
+	return 1
+}
+
+func write(out string, lines []*detect.Line) error {
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := detect.GeoJSON(lines).Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
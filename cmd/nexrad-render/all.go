@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sync"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/render"
+	"github.com/kallsyms/go-nexrad/sink"
+	"github.com/sirupsen/logrus"
+)
+
+// sweepJob is a product of an elevation scan to render with --all
+type sweepJob struct {
+	product string
+	elv     int
+	name    string
+}
+
+// renderAll decodes the volume once and renders every product of every
+// elevation scan into out as product/cut (e.g. ref/01.png), or as named by the
+// --name-template, returning the
+// number of sweeps that failed. If --elevation is set, only the selected
+// cut of each product is rendered. Sweeps are rendered in parallel with
+// --threads workers.
+func renderAll(in, out string) int {
+	ar2, err := load(in, 0)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	defer ar2.Release()
+	s, err := sink.Open(out)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	summary := ar2.Summary()
+	jobs, err := allSweeps(in, ar2, summary.Cuts)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	if len(jobs) == 0 {
+		logrus.Fatalf("no products in %s", in)
+	}
+	fmt.Printf("Generating %d sweeps from %s -> %s\n", len(jobs), in, out)
+
+	queue := make(chan sweepJob)
+	failed := 0
+	mtx := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	wg.Add(runners)
+	for i := 0; i < runners; i++ {
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				radials := ar2.ElevationScans[j.elv]
+				label := newLabelFields(ar2.VolumeHeader, radials, j.product)
+				if err := output(s, j.name, radials, j.product, label); err != nil {
+					logrus.Errorf("%s: %s", j.name, err)
+					mtx.Lock()
+					failed++
+					mtx.Unlock()
+				}
+			}
+		}()
+	}
+	for _, j := range jobs {
+		queue <- j
+	}
+	close(queue)
+	wg.Wait()
+
+	if failed > 0 {
+		logrus.Errorf("%d of %d sweeps failed", failed, len(jobs))
+	}
+	return failed
+}
+
+// allSweeps returns the sweeps of the volume read from in to render with
+// --all, by product then cut. It's an error for the --name-template to give
+// two the same name.
+func allSweeps(in string, ar2 *archive2.Archive2, cuts []archive2.CutSummary) ([]sweepJob, error) {
+	var jobs []sweepJob
+	names := map[string]bool{}
+	for _, p := range render.Products {
+		only := 0
+		if elevation != "" {
+			if only = selected.elevationFor(ar2, p); only == 0 {
+				continue
+			}
+		}
+		for _, cut := range cuts {
+			elv := cut.ElevationNumber
+			if (only != 0 && elv != only) || !render.HasProduct(ar2, elv, p) {
+				continue
+			}
+			fields := newNameFields(in, ar2.VolumeHeader, ar2.ElevationScans[elv], p, elv)
+			name, err := fields.name(path.Join(p, fields.Tilt+fields.Ext))
+			if err != nil {
+				return nil, err
+			}
+			if names[name] {
+				return nil, fmt.Errorf("--name-template names several sweeps %s, include .Product and .Tilt", name)
+			}
+			names[name] = true
+			jobs = append(jobs, sweepJob{product: p, elv: elv, name: name})
+		}
+	}
+	return jobs, nil
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"image"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cheggaaa/pb/v3"
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/container"
+	"github.com/kallsyms/go-nexrad/sink"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var animateCmd = &cobra.Command{
+	Use:   "animate SOURCE",
+	Short: "render every volume in a directory, or tar or zip container, as animation frames",
+	Args:  cobra.ExactArgs(1),
+	Run:   runAnimate,
+}
+
+var outputDir string
+var maxVolumes int
+var recursive bool
+var include []string
+var exclude []string
+var skipExisting bool
+
+func init() {
+	animateCmd.Flags().StringVarP(&outputDir, "output", "o", "out", "directory, or s3://, gs:// or azure:// url, to write frames to")
+	animateCmd.Flags().IntVar(&maxVolumes, "max-volumes", 0, "maximum number of volumes decoded at once, bounding memory use. defaults to threads")
+	animateCmd.Flags().SetAnnotation("output", cobra.BashCompSubdirsInDir, []string{})
+	animateCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "also render the volumes and containers in subdirectories of the source")
+	animateCmd.Flags().StringArrayVar(&include, "include", nil, "glob of the file names of volumes to render, e.g. \"KTLX*\" or \"*.gz\", instead of any volume name. repeatable")
+	animateCmd.Flags().StringArrayVar(&exclude, "exclude", nil, "glob of the file names of volumes and containers not to render, e.g. \"*_MDM\". repeatable")
+	animateCmd.Flags().BoolVar(&skipExisting, "skip-existing", false, "skip volumes whose frame was already written, to resume an interrupted run")
+	cmd.AddCommand(animateCmd)
+}
+
+func runAnimate(cmd *cobra.Command, args []string) {
+	if err := checkAnimation(); err != nil {
+		logrus.Fatal(err)
+	}
+	if err := checkNameTemplate(); err != nil {
+		logrus.Fatal(err)
+	}
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			logrus.Fatalf("invalid pattern %q: %s", pattern, err)
+		}
+	}
+	if skipExisting && animation != "" {
+		logrus.Fatal("--animation needs every frame rendered, it can't be used with --skip-existing")
+	}
+	if failed := animate(args[0], outputDir, product); failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// frame is a volume to render in an animation
+type frame struct {
+	index int
+	in    *container.Volume
+	// name is the file the frame is written to, named once the volume is
+	// decoded
+	name string
+	err  error
+	// skipped is set when the volume has no data for the product
+	skipped bool
+	// existing is set when the frame was already written and --skip-existing
+	// is set
+	existing bool
+	// manifest describes the rendered frame
+	manifest *manifestFrame
+	// image is the frame for the --animation
+	image *image.Paletted
+}
+
+// animate renders every volume in src into out, returning the number of
+// volumes that failed. src is a directory of volumes and containers of them,
+// or a single container, and out is a directory or bucket URL. Volumes are
+// rendered in parallel, but results are reported and listed in frames.txt in
+// the order of the volumes (i.e. time order), so the frames can be assembled
+// into an animation. Ctrl-C stops starting new volumes and lets the ones in
+// progress finish.
+func animate(src, out, prod string) int {
+	sources, err := animationSources(src)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	s, err := sink.Open(out)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			logrus.Warn("interrupted, finishing volumes in progress")
+			cancel()
+			// a second interrupt exits immediately
+			signal.Stop(interrupt)
+		case <-ctx.Done():
+		}
+	}()
+
+	limit := maxVolumes
+	if limit <= 0 {
+		limit = runners
+	}
+	// decoded volumes are the bulk of the memory used, so only limit of them
+	// are held at once
+	volumes := make(chan struct{}, limit)
+
+	// the number of volumes in containers isn't known until they're read, so
+	// the total grows as they're found
+	bar := pb.StartNew(0)
+	jobs := make(chan *frame)
+	results := make(chan *frame)
+
+	wg := sync.WaitGroup{}
+	wg.Add(runners)
+	for i := 0; i < runners; i++ {
+		go func() {
+			defer wg.Done()
+			for f := range jobs {
+				volumes <- struct{}{}
+				f.err = renderFrame(s, f, prod)
+				<-volumes
+				results <- f
+			}
+		}()
+	}
+
+	total := 0
+	go func() {
+		defer close(jobs)
+		for _, source := range sources {
+			err := container.Walk(source, isVolume, func(v *container.Volume) error {
+				f := &frame{index: total, in: v}
+				select {
+				case jobs <- f:
+					total++
+					bar.SetTotal(int64(total))
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+			if ctx.Err() != nil {
+				return
+			} else if err != nil {
+				// the volumes already read from a broken container are still
+				// rendered
+				logrus.Errorf("%s: %s", source, err)
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// results arrive in any order; hold them until the frames before them are
+	// done
+	pending := map[int]*frame{}
+	next := 0
+	failed := 0
+	var rendered []string
+	names := map[string]bool{}
+	existing := 0
+	var images []*image.Paletted
+	m := &manifest{Product: prod, Format: format, Frames: []*manifestFrame{}}
+	for f := range results {
+		bar.Increment()
+		pending[f.index] = f
+		for ; pending[next] != nil; next++ {
+			f := pending[next]
+			delete(pending, next)
+			switch {
+			case f.err != nil:
+				logrus.Errorf("%s: %s", f.in.Name, f.err)
+				failed++
+			case f.skipped:
+				logrus.Warnf("%s: no %s data", f.in.Name, prod)
+			case f.existing:
+				// frames already written are still listed, but aren't in the
+				// manifest as they weren't decoded
+				rendered = append(rendered, f.name)
+				existing++
+			default:
+				if names[f.name] {
+					logrus.Warnf("%s: overwrote another frame named %s, see --name-template", f.in.Name, f.name)
+				}
+				names[f.name] = true
+				rendered = append(rendered, f.name)
+				m.Frames = append(m.Frames, f.manifest)
+				if f.image != nil {
+					images = append(images, f.image)
+				}
+			}
+		}
+	}
+	bar.Finish()
+
+	if len(rendered) > 0 {
+		// the lists change as frames are added, so they're never cached
+		list := strings.Join(rendered, "\n") + "\n"
+		if err := put(s, "frames.txt", []byte(list), "no-cache"); err != nil {
+			logrus.Error(err)
+		}
+		if err := m.write(s, "manifest.json"); err != nil {
+			logrus.Error(err)
+		}
+	}
+	if len(images) > 0 {
+		if err := writeAnimation(s, images); err != nil {
+			logrus.Error(err)
+		}
+	}
+	if existing > 0 {
+		logrus.Infof("skipped %d volumes already rendered", existing)
+	}
+	if ctx.Err() != nil {
+		logrus.Warnf("stopped after %d volumes", next)
+	}
+	if failed > 0 {
+		logrus.Errorf("%d of %d volumes failed", failed, next)
+	}
+	return failed
+}
+
+// renderFrame decodes the volume and renders the product's sweep into the
+// sink. Only the sweep is kept once the volume is decoded, so the rest can be
+// freed while rendering, and the sweep is released once it's rendered so the
+// next volume reuses its buffers.
+func renderFrame(s sink.Sink, f *frame, prod string) error {
+	defaultName := filepath.Base(f.in.Name) + formatExtensions[format]
+	// with a --name-template, frames can only be named once they're decoded
+	if skipExisting && outputName == nil {
+		if f.existing = exists(s, defaultName); f.existing {
+			f.name = defaultName
+			return nil
+		}
+	}
+	radials, vh, err := loadSweep(f.in, prod)
+	if err != nil {
+		return err
+	}
+	if radials == nil {
+		f.skipped = true
+		return nil
+	}
+	defer func() {
+		for _, m31 := range radials {
+			m31.Release()
+		}
+	}()
+	elv := int(radials[0].Header.ElevationNumber)
+	if f.name, err = newNameFields(f.in.Name, vh, radials, prod, elv).name(defaultName); err != nil {
+		return err
+	}
+	if skipExisting && outputName != nil {
+		if f.existing = exists(s, f.name); f.existing {
+			return nil
+		}
+	}
+	f.manifest = newManifestFrame(f.name, vh, radials)
+	img, err := outputImage(s, f.name, radials, prod, newLabelFields(vh, radials, prod))
+	if err == nil && animation != "" {
+		// paletted frames are a quarter of the size, and it's done in parallel
+		f.image = paletted(img)
+	}
+	return err
+}
+
+// loadSweep returns the radials of the elevation scan to render the product
+// from, or nil if the volume doesn't have the product, and the volume's header
+func loadSweep(v *container.Volume, prod string) ([]*archive2.Message31, archive2.VolumeHeaderRecord, error) {
+	ar2, err := v.Load()
+	if err != nil {
+		return nil, archive2.VolumeHeaderRecord{}, err
+	}
+
+	elv := selected.elevationFor(ar2, prod)
+	if elv == 0 {
+		ar2.Release()
+		return nil, ar2.VolumeHeader, nil
+	}
+	for e, scan := range ar2.ElevationScans {
+		if e == elv {
+			continue
+		}
+		for _, m31 := range scan {
+			m31.Release()
+		}
+	}
+	return ar2.ElevationScans[elv], ar2.VolumeHeader, nil
+}
+
+// animationSources returns the volumes and containers to render from src in
+// name (i.e. time) order, searching its subdirectories too with --recursive
+func animationSources(src string) ([]string, error) {
+	if container.IsContainer(src) {
+		return []string{src}, nil
+	}
+	var sources []string
+	err := filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if p != src && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		name := info.Name()
+		if info.Mode().IsRegular() && !excluded(name) && (isVolume(name) || container.IsContainer(name)) {
+			sources = append(sources, p)
+		}
+		return nil
+	})
+	// volume names start with the site and time, so sorting by them rather
+	// than the whole path keeps volumes from different directories in order
+	sort.SliceStable(sources, func(i, j int) bool {
+		bi, bj := filepath.Base(sources[i]), filepath.Base(sources[j])
+		if bi != bj {
+			return bi < bj
+		}
+		return sources[i] < sources[j]
+	})
+	return sources, err
+}
+
+// exists reports whether the sink has the file. Errors checking are logged,
+// and the file assumed missing so it's rendered.
+func exists(s sink.Sink, name string) bool {
+	ok, err := s.Exists(name)
+	if err != nil {
+		logrus.Warnf("%s: %s", name, err)
+	}
+	return ok
+}
+
+// isVolume reports whether the file name is that of a volume to render: a
+// volume name, plain or gzipped, or one matching an --include glob if there
+// are any, and not an --exclude glob
+func isVolume(name string) bool {
+	if excluded(name) {
+		return false
+	}
+	if len(include) > 0 {
+		return matchAny(include, name)
+	}
+	if strings.HasSuffix(strings.TrimSuffix(name, ".gz"), ".ar2v") {
+		return true
+	}
+	_, ok := archive2.ParseVolumeKey(name)
+	return ok
+}
+
+// excluded reports whether the file name matches an --exclude glob
+func excluded(name string) bool {
+	return matchAny(exclude, name)
+}
+
+// matchAny reports whether the name matches any of the globs, which were
+// checked when the flags were parsed
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
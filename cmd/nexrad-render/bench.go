@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/kallsyms/go-nexrad/container"
+	"github.com/kallsyms/go-nexrad/sink"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench FILE",
+	Short: "time decoding and rendering a volume",
+	Long: `Decodes the volume and renders the product in the selected format
+repeatedly, reporting how long each stage takes. Output is written to a
+temporary directory and removed afterwards.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runBench,
+}
+
+var benchIterations int
+
+func init() {
+	benchCmd.Flags().IntVarP(&benchIterations, "iterations", "n", 5, "number of times to decode and render the volume")
+	cmd.AddCommand(benchCmd)
+}
+
+// stageTimes collects the durations of one stage of rendering
+type stageTimes []time.Duration
+
+func (s stageTimes) String() string {
+	if len(s) == 0 {
+		return "-"
+	}
+	min, max, total := s[0], s[0], time.Duration(0)
+	for _, d := range s {
+		if d < min {
+			min = d
+		}
+		if d > max {
+			max = d
+		}
+		total += d
+	}
+	return fmt.Sprintf("mean %-12s min %-12s max %s", total/time.Duration(len(s)), min, max)
+}
+
+func runBench(cmd *cobra.Command, args []string) {
+	if benchIterations < 1 {
+		logrus.Fatalf("invalid iterations %d", benchIterations)
+	}
+	tmp, err := ioutil.TempDir("", "nexrad-render-bench")
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	out := sink.NewDir(tmp)
+	name := "radar" + formatExtensions[format]
+
+	var decode, render stageTimes
+	for i := 0; i < benchIterations; i++ {
+		start := time.Now()
+		radials, vh, err := loadSweep(&container.Volume{Name: args[0]}, product)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		if radials == nil {
+			logrus.Fatalf("no %s data in %s", product, args[0])
+		}
+		decode = append(decode, time.Since(start))
+
+		start = time.Now()
+		if err := output(out, name, radials, product, newLabelFields(vh, radials, product)); err != nil {
+			logrus.Fatal(err)
+		}
+		render = append(render, time.Since(start))
+	}
+
+	fmt.Printf("%s %s %dpx, %d iterations\n", args[0], product, imageSize, benchIterations)
+	fmt.Printf("decode  %s\n", decode)
+	fmt.Printf("%-7s %s\n", format, render)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/kallsyms/go-nexrad/render"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var completionCmd = &cobra.Command{
+	Use:   "completion SHELL",
+	Short: "generate shell completions for bash or zsh",
+	Long: `Writes the completion script for the shell to stdout. For bash, load it with
+
+    $ source <(nexrad-render completion bash)
+
+and for zsh, write it to a file named _nexrad-render on your $fpath.`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"bash", "zsh"},
+	Run:       runCompletion,
+}
+
+func init() {
+	cmd.AddCommand(completionCmd)
+}
+
+func runCompletion(cmd *cobra.Command, args []string) {
+	var err error
+	switch args[0] {
+	case "bash":
+		err = cmd.Root().GenBashCompletion(os.Stdout)
+	case "zsh":
+		err = cmd.Root().GenZshCompletion(os.Stdout)
+	default:
+		logrus.Fatalf("unsupported shell %s, expected bash or zsh", args[0])
+	}
+	if err != nil {
+		logrus.Fatal(err)
+	}
+}
+
+// addCompletions completes the values of the global flags with a fixed set of
+// choices. It's called once the flags are defined.
+func addCompletions() {
+	var schemes []string
+	seen := map[string]bool{}
+	for _, s := range render.ColorSchemes {
+		for name := range s {
+			if !seen[name] {
+				seen[name] = true
+				schemes = append(schemes, name)
+			}
+		}
+	}
+	var formats []string
+	for f := range formatExtensions {
+		formats = append(formats, f)
+	}
+	sort.Strings(schemes)
+	sort.Strings(formats)
+
+	choices := []struct {
+		flag  string
+		words []string
+	}{
+		{"product", append(append(append([]string{}, render.Products...), render.VolumeProducts...), "vwp")},
+		{"color-scheme", schemes},
+		{"format", formats},
+		{"log-level", []string{"trace", "debug", "info", "warn", "error"}},
+		{"projection", append([]string{"latlon"}, projections...)},
+	}
+	for _, c := range choices {
+		fn := "__nexrad-render_" + strings.Replace(c.flag, "-", "_", -1)
+		cmd.BashCompletionFunction += fmt.Sprintf("%s()\n{\n    COMPREPLY=( $(compgen -W %q -- \"$cur\") )\n}\n", fn, strings.Join(c.words, " "))
+		cmd.PersistentFlags().SetAnnotation(c.flag, cobra.BashCompCustom, []string{fn})
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/render"
+)
+
+// elevationChoice is the elevation scan selected with --elevation
+type elevationChoice struct {
+	// number is the elevation number of the cut, or 0 to select by angle
+	number int
+	angle  float64
+}
+
+// sameAngle is how close in degrees the mean angles of cuts are to be
+// considered the same tilt, such as the scans of a split cut or the SAILS
+// repeats of the lowest tilt
+const sameAngle = 0.1
+
+// parseElevation parses the --elevation flag. Numbers without a decimal point
+// are cut numbers (1 is the first), others angles, so 2 is the second cut and
+// 2.0 the cut nearest 2 degrees. An empty flag selects the lowest tilt.
+func parseElevation(s string) (elevationChoice, error) {
+	if s == "" {
+		return elevationChoice{}, nil
+	}
+	if !strings.Contains(s, ".") {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return elevationChoice{}, fmt.Errorf("invalid elevation %q, expected a cut number from 1 or an angle in degrees", s)
+		}
+		return elevationChoice{number: n}, nil
+	}
+	a, err := strconv.ParseFloat(s, 64)
+	if err != nil || a < -1 || a > 90 {
+		return elevationChoice{}, fmt.Errorf("invalid elevation angle %q", s)
+	}
+	return elevationChoice{angle: a}, nil
+}
+
+// elevationFor returns the elevation number of the cut to render the product
+// from, or 0 if there's none with the product. Selecting by angle picks the
+// cut nearest the angle that has the product, and the first of them when
+// several are at the same tilt, so velocity comes from the doppler scan of a
+// split cut.
+func (c elevationChoice) elevationFor(ar2 *archive2.Archive2, prod string) int {
+	if c.number != 0 {
+		if render.HasProduct(ar2, c.number, prod) {
+			return c.number
+		}
+		return 0
+	}
+	var cuts []archive2.CutSummary
+	nearest := math.Inf(1)
+	for _, cut := range ar2.Summary().Cuts {
+		if !render.HasProduct(ar2, cut.ElevationNumber, prod) {
+			continue
+		}
+		cuts = append(cuts, cut)
+		nearest = math.Min(nearest, math.Abs(float64(cut.ElevationAngle)-c.angle))
+	}
+	for _, cut := range cuts {
+		if math.Abs(float64(cut.ElevationAngle)-c.angle) <= nearest+sameAngle {
+			return cut.ElevationNumber
+		}
+	}
+	return 0
+}
+
+// elevationName describes the --elevation flag in messages
+func elevationName() string {
+	if elevation == "" {
+		return "any elevation scan"
+	}
+	return "elevation " + elevation
+}
+
+// writeElevations lists the volume's cuts and the products each has
+func writeElevations(w io.Writer, ar2 *archive2.Archive2) {
+	fmt.Fprintf(w, "%-4s %7s %7s  %s\n", "CUT", "ANGLE", "RADIALS", "PRODUCTS")
+	for _, cut := range ar2.Summary().Cuts {
+		products := render.AvailableProducts(ar2, cut.ElevationNumber)
+		fmt.Fprintf(w, "%-4d %7.2f %7d  %s\n", cut.ElevationNumber, cut.ElevationAngle, cut.Radials, strings.Join(products, ", "))
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"github.com/kallsyms/go-nexrad/export/pngtext"
+	"github.com/kallsyms/go-nexrad/render"
+)
+
+// pngCompressionLevels are the --png-compression levels
+var pngCompressionLevels = map[string]png.CompressionLevel{
+	"default": png.DefaultCompression,
+	"none":    png.NoCompression,
+	"speed":   png.BestSpeed,
+	"best":    png.BestCompression,
+}
+
+// unsupportedFormats are formats asked for that can't be encoded, and why
+var unsupportedFormats = map[string]string{
+	"webp": "there's no webp encoder in the Go libraries used; use png with --png-palette, or convert with cwebp",
+	"avif": "there's no avif encoder in the Go libraries used; use png with --png-palette, or convert with avifenc",
+}
+
+// checkEncoding validates the encoder flags
+func checkEncoding() error {
+	if quality < 1 || quality > 100 {
+		return fmt.Errorf("invalid --quality %d, expected 1 to 100", quality)
+	}
+	if _, ok := pngCompressionLevels[pngCompression]; !ok {
+		return fmt.Errorf("unsupported png compression %s, expected default, none, speed or best", pngCompression)
+	}
+	return nil
+}
+
+// isRaster reports whether the format is an image format rendered like png
+func isRaster(format string) bool {
+	return format == "png" || format == "jpeg"
+}
+
+// encodeImage encodes the image in the selected raster format. PNGs carry the
+// metadata fields as text chunks; JPEGs don't carry them.
+func encodeImage(w io.Writer, img image.Image, meta []pngtext.Field) error {
+	if format == "jpeg" {
+		return encodeJPEG(w, img)
+	}
+	return encodePNG(w, img, meta)
+}
+
+// encodePNG encodes the image as a PNG with the --png-compression. With
+// --png-palette, images of at most 256 colors, which radar images without
+// antialiased overlays are, are written paletted, several times smaller than
+// RGBA and without losing anything. The metadata fields are written as text
+// chunks.
+func encodePNG(w io.Writer, img image.Image, meta []pngtext.Field) error {
+	if pngPalette {
+		if pi := render.Paletted(img, 256); pi != nil {
+			img = pi
+		}
+	}
+	e := png.Encoder{CompressionLevel: pngCompressionLevels[pngCompression]}
+	if len(meta) == 0 {
+		return e.Encode(w, img)
+	}
+	var buf bytes.Buffer
+	if err := e.Encode(&buf, img); err != nil {
+		return err
+	}
+	b, err := pngtext.Insert(buf.Bytes(), meta)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// encodeJPEG encodes the image as a JPEG with the --quality. JPEGs have no
+// transparency, so translucent pixels are blended over black.
+func encodeJPEG(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	flat := image.NewRGBA(b)
+	draw.Draw(flat, b, image.Black, image.ZP, draw.Src)
+	draw.Draw(flat, b, img, b.Min, draw.Over)
+	return jpeg.Encode(w, flat, &jpeg.Options{Quality: quality})
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cheggaaa/pb/v3"
+	"github.com/kallsyms/go-nexrad/fetch"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var fetchCmd = &cobra.Command{
+	Use:   "fetch SITE",
+	Short: "download a day of archive 2 volumes for a radar site from the public NEXRAD bucket",
+	Args:  cobra.ExactArgs(1),
+	Run:   runFetch,
+}
+
+var fetchDate string
+var fetchStart string
+var fetchEnd string
+var fetchOutput string
+var fetchBucket string
+
+func init() {
+	fetchCmd.Flags().StringVar(&fetchDate, "date", "", "day to fetch, as YYYY-MM-DD. defaults to today (UTC)")
+	fetchCmd.Flags().StringVar(&fetchStart, "start", "00:00", "fetch volumes starting at or after this time of day (UTC), as HH:MM")
+	fetchCmd.Flags().StringVar(&fetchEnd, "end", "24:00", "fetch volumes starting before this time of day (UTC), as HH:MM")
+	fetchCmd.Flags().StringVarP(&fetchOutput, "output", "o", "", "directory to download to. defaults to the site")
+	fetchCmd.Flags().StringVar(&fetchBucket, "bucket", fetch.DefaultBucket, "URL of the bucket to fetch from")
+	fetchCmd.Flags().SetAnnotation("output", cobra.BashCompSubdirsInDir, []string{})
+	cmd.AddCommand(fetchCmd)
+}
+
+func runFetch(cmd *cobra.Command, args []string) {
+	site := strings.ToUpper(args[0])
+	day := time.Now().UTC().Truncate(24 * time.Hour)
+	if fetchDate != "" {
+		var err error
+		if day, err = time.Parse("2006-01-02", fetchDate); err != nil {
+			logrus.Fatalf("invalid date %q: %s", fetchDate, err)
+		}
+	}
+	start, err := timeOfDay(fetchStart)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	end, err := timeOfDay(fetchEnd)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	out := fetchOutput
+	if out == "" {
+		out = site
+	}
+
+	client := fetch.NewClient()
+	client.Bucket = fetchBucket
+	ctx := context.Background()
+	volumes, err := client.Range(ctx, site, day.Add(start), day.Add(end))
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	if len(volumes) == 0 {
+		logrus.Fatalf("no %s volumes on %s between %s and %s", site, day.Format("2006-01-02"), fetchStart, fetchEnd)
+	}
+	if err := os.MkdirAll(out, os.ModePerm); err != nil {
+		logrus.Fatal(err)
+	}
+
+	fmt.Printf("Fetching %d volumes -> %s\n", len(volumes), out)
+	bar := pb.StartNew(len(volumes))
+	jobs := make(chan fetch.Volume)
+	failed := 0
+	mtx := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	wg.Add(runners)
+	for i := 0; i < runners; i++ {
+		go func() {
+			defer wg.Done()
+			for v := range jobs {
+				dest := filepath.Join(out, path.Base(v.Key))
+				// volumes already downloaded are skipped
+				if _, err := os.Stat(dest); err != nil {
+					if err := client.Download(ctx, v.Key, dest); err != nil {
+						logrus.Errorf("%s: %s", v.Key, err)
+						mtx.Lock()
+						failed++
+						mtx.Unlock()
+					}
+				}
+				bar.Increment()
+			}
+		}()
+	}
+	for _, v := range volumes {
+		jobs <- v
+	}
+	close(jobs)
+	wg.Wait()
+	bar.Finish()
+
+	if failed > 0 {
+		logrus.Errorf("%d of %d volumes failed", failed, len(volumes))
+		os.Exit(1)
+	}
+}
+
+// timeOfDay parses an HH:MM time of day, allowing 24:00 for the end of the day
+func timeOfDay(s string) (time.Duration, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil || h < 0 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	colorpalette "image/color/palette"
+	"image/draw"
+	"image/gif"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/kallsyms/go-nexrad/render"
+	"github.com/kallsyms/go-nexrad/sink"
+)
+
+// animation is the --animation file to assemble the frames of animate into
+var animation string
+var frameDelay time.Duration
+var lastFrameDelay time.Duration
+var loops int
+var interpolate int
+var interpolation string
+
+func init() {
+	animateCmd.Flags().StringVar(&animation, "animation", "", "also assemble the png frames into an animated gif of this name in the output, e.g. loop.gif")
+	animateCmd.Flags().DurationVar(&frameDelay, "frame-delay", 500*time.Millisecond, "time each frame of the --animation is shown")
+	animateCmd.Flags().DurationVar(&lastFrameDelay, "last-frame-delay", 2*time.Second, "time the last frame of the --animation is shown before it loops")
+	animateCmd.Flags().IntVar(&loops, "loop", 0, "times to play the --animation, 0 to loop forever")
+	animateCmd.Flags().IntVar(&interpolate, "interpolate", 0, "frames to interpolate between each volume's in the --animation, for smooth animations at high frame rates")
+	animateCmd.Flags().StringVar(&interpolation, "interpolation", "motion", "how --interpolate frames are made. motion moves the echoes along their estimated motion, fade cross-fades")
+}
+
+// checkAnimation validates the --animation flags
+func checkAnimation() error {
+	if animation == "" {
+		return nil
+	}
+	if !strings.EqualFold(path.Ext(animation), ".gif") {
+		return fmt.Errorf("unsupported animation %s, only .gif can be written", animation)
+	}
+	if !isRaster(format) {
+		return fmt.Errorf("--animation needs png or jpeg frames, not %s", format)
+	}
+	if frameDelay <= 0 || lastFrameDelay <= 0 {
+		return fmt.Errorf("frame delays must be positive")
+	}
+	if loops < 0 {
+		return fmt.Errorf("invalid --loop %d", loops)
+	}
+	if interpolate < 0 {
+		return fmt.Errorf("invalid --interpolate %d", interpolate)
+	}
+	if interpolation != "motion" && interpolation != "fade" {
+		return fmt.Errorf("unsupported interpolation %s", interpolation)
+	}
+	return nil
+}
+
+// interpolateFrames returns the frames with --interpolate frames between each
+// of them
+func interpolateFrames(frames []*image.Paletted) []*image.Paletted {
+	if interpolate == 0 {
+		return frames
+	}
+	out := []*image.Paletted{frames[0]}
+	for i := 1; i < len(frames); i++ {
+		a, b := frames[i-1], frames[i]
+		motion := image.ZP
+		if interpolation == "motion" {
+			// storms move at most a few tens of km between volumes, well
+			// within an eighth of the image at the default range
+			motion = render.Motion(a, b, a.Bounds().Dx()/8)
+		}
+		// blends of the frames are mostly their colors faded into each other,
+		// so the colors of both are used if they fit
+		p := append(append(color.Palette{}, a.Palette...), b.Palette...)
+		for n := 1; n <= interpolate; n++ {
+			tween := render.Tween(a, b, float64(n)/float64(interpolate+1), motion)
+			if len(p) > 256 {
+				out = append(out, paletted(tween))
+				continue
+			}
+			pi := image.NewPaletted(tween.Bounds(), p)
+			draw.Draw(pi, pi.Bounds(), tween, tween.Bounds().Min, draw.Src)
+			out = append(out, pi)
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// paletted converts the frame to a paletted image for a GIF. Radar images
+// only use a few colors, so they're kept exactly if there are at most 256;
+// otherwise, such as with antialiased overlays, the frame is dithered to the
+// web safe palette.
+func paletted(img image.Image) *image.Paletted {
+	// transparent is first so it's the GIF's transparent index
+	if pi := render.Paletted(img, 256); pi != nil {
+		return pi
+	}
+	b := img.Bounds()
+	pi := image.NewPaletted(b, append(color.Palette{color.Transparent}, colorpalette.WebSafe...))
+	draw.FloydSteinberg.Draw(pi, b, img, b.Min)
+	return pi
+}
+
+// encodeGIF encodes the frames as an animated GIF with the --frame-delay,
+// --last-frame-delay and --loop
+func encodeGIF(frames []*image.Paletted) ([]byte, error) {
+	g := &gif.GIF{Image: frames}
+	for i := range frames {
+		d := frameDelay
+		if i == len(frames)-1 {
+			d = lastFrameDelay
+		}
+		// delays are in 100ths of a second
+		g.Delay = append(g.Delay, int(d/(10*time.Millisecond)))
+		g.Disposal = append(g.Disposal, gif.DisposalBackground)
+	}
+	// LoopCount is the number of times the animation is repeated after the
+	// first play, with -1 playing it once
+	switch loops {
+	case 0:
+		g.LoopCount = 0
+	case 1:
+		g.LoopCount = -1
+	default:
+		g.LoopCount = loops - 1
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeAnimation writes the frames to the --animation file in the sink
+func writeAnimation(s sink.Sink, frames []*image.Paletted) error {
+	data, err := encodeGIF(interpolateFrames(frames))
+	if err != nil {
+		return fmt.Errorf("%s: %s", animation, err)
+	}
+	// like the frame lists, the animation changes as frames are added
+	return put(s, animation, data, "no-cache")
+}
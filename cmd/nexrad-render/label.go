@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/render"
+	"github.com/sirupsen/logrus"
+)
+
+// label flags
+var labelTemplate string
+var labelFont string
+var labelSize float64
+var labelPosition string
+var labelColor string
+var timestamp bool
+var timestampFormat string
+var timestampPosition string
+
+// defaultLabelTemplate is the label drawn with --label
+const defaultLabelTemplate = `{{.Site}} {{printf "%f" .Elevation}} {{.Product}} VCP:{{.VCP}} {{.File}} {{.Time.Format "2006-01-02T15:04:05Z07:00"}}`
+
+// labelText is the parsed --label-template
+var labelText *template.Template
+
+// labelStyle and watermarkStyle are the styles of --label and --timestamp
+var labelStyle, watermarkStyle render.LabelStyle
+
+func init() {
+	cmd.PersistentFlags().StringVar(&labelTemplate, "label-template", defaultLabelTemplate, "text/template of the --label, of .Site, .Product, .Elevation, .VCP, .File and .Time")
+	cmd.PersistentFlags().StringVar(&labelFont, "label-font", "inconsolata", "font of labels and timestamps. inconsolata (16 pixels only), go, gobold, gomono, gomonobold or a .ttf file")
+	cmd.MarkPersistentFlagFilename("label-font", "ttf")
+	cmd.PersistentFlags().Float64Var(&labelSize, "label-size", 16, "size in pixels of the --label-font")
+	cmd.PersistentFlags().StringVar(&labelPosition, "label-position", "bottom-right", "corner to draw the label in. top-left, top-right, bottom-left, bottom-right")
+	cmd.PersistentFlags().StringVar(&labelColor, "label-color", "gray", "color of the label, a color name or #rrggbb[aa]")
+	cmd.PersistentFlags().BoolVar(&timestamp, "timestamp", false, "stamp png, jpeg and svg images, such as every frame of an animation, with the volume's time")
+	cmd.PersistentFlags().StringVar(&timestampFormat, "timestamp-format", "2006-01-02 15:04 MST", "Go time layout of the --timestamp")
+	cmd.PersistentFlags().StringVar(&timestampPosition, "timestamp-position", "top-left", "corner to draw the --timestamp in. top-left, top-right, bottom-left, bottom-right")
+}
+
+// labelFields are the fields of the --label-template
+type labelFields struct {
+	Site      string
+	Product   string
+	Elevation float32
+	VCP       int
+	File      string
+	Time      time.Time
+}
+
+// newLabelFields returns the label fields of the product's sweep in the
+// volume
+func newLabelFields(vh archive2.VolumeHeaderRecord, radials []*archive2.Message31, prod string) labelFields {
+	return labelFields{
+		Site:      string(vh.ICAO[:]),
+		Product:   strings.ToUpper(prod),
+		Elevation: radials[0].Header.ElevationAngle,
+		VCP:       int(int16(radials[0].VolumeData.VolumeCoveragePatternNumber)),
+		File:      vh.FileName(),
+		Time:      vh.Date(),
+	}
+}
+
+// checkLabels parses the label and timestamp flags
+func checkLabels() error {
+	t, err := template.New("label").Parse(labelTemplate)
+	if err != nil {
+		return fmt.Errorf("invalid --label-template: %s", err)
+	}
+	// unknown fields are only found executing the template
+	if err := t.Execute(&bytes.Buffer{}, labelFields{}); err != nil {
+		return fmt.Errorf("invalid --label-template: %s", err)
+	}
+	labelText = t
+
+	font, err := render.LoadFont(labelFont, labelSize)
+	if err != nil {
+		return err
+	}
+	c, err := render.ParseColor(labelColor)
+	if err != nil {
+		return err
+	}
+	corner, err := render.ParseCorner(labelPosition)
+	if err != nil {
+		return err
+	}
+	labelStyle = render.LabelStyle{Font: font, Color: c, Corner: corner}
+	if corner, err = render.ParseCorner(timestampPosition); err != nil {
+		return err
+	}
+	watermarkStyle = render.LabelStyle{Font: font, Corner: corner}
+	return nil
+}
+
+// apply sets the --label and --timestamp of the options
+func (f labelFields) apply(opts *render.Options) {
+	if renderLabel {
+		var buf bytes.Buffer
+		if err := labelText.Execute(&buf, f); err != nil {
+			logrus.Warnf("label: %s", err)
+		}
+		opts.Label, opts.LabelStyle = buf.String(), labelStyle
+	}
+	if timestamp {
+		opts.Watermark, opts.WatermarkStyle = f.Time.UTC().Format(timestampFormat), watermarkStyle
+	}
+}
@@ -1,301 +1,670 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
 	"fmt"
 	"image"
 	"image/color"
-	"image/draw"
-	"io/ioutil"
-	"log"
+	"io"
 	"math"
 	"os"
+	"path"
 	"runtime"
+	"strconv"
 	"strings"
-	"sync"
-	"time"
 
-	"github.com/llgcode/draw2d"
-
-	"golang.org/x/image/colornames"
-	"golang.org/x/image/font"
-	"golang.org/x/image/math/fixed"
-
-	"github.com/cheggaaa/pb/v3"
 	"github.com/kallsyms/go-nexrad/archive2"
-	"github.com/llgcode/draw2d/draw2dimg"
+	"github.com/kallsyms/go-nexrad/container"
+	"github.com/kallsyms/go-nexrad/derived"
+	"github.com/kallsyms/go-nexrad/export/geojson"
+	"github.com/kallsyms/go-nexrad/export/geotiff"
+	"github.com/kallsyms/go-nexrad/fetch"
+	"github.com/kallsyms/go-nexrad/geo"
+	"github.com/kallsyms/go-nexrad/grid"
+	"github.com/kallsyms/go-nexrad/overlay"
+	"github.com/kallsyms/go-nexrad/render"
+	"github.com/kallsyms/go-nexrad/sink"
+	"github.com/kallsyms/go-nexrad/sites"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-	"golang.org/x/image/font/inconsolata"
 )
 
 var cmd = &cobra.Command{
 	Use:   "nexrad-render",
 	Short: "nexrad-render generates products from NEXRAD Level 2 (archive 2) data files.",
-	Run:   run,
+	// flags shared by the subcommands are checked before any of them run
+	PersistentPreRun: setup,
 }
 
-var inputFile string
-var outputFile string
+var renderCmd = &cobra.Command{
+	Use:   "render FILE|URL|-",
+	Short: "render a single archive 2 volume, from a file, an https://, s3:// or gs:// URL or stdin (-)",
+	Args:  cobra.MinimumNArgs(1),
+	Run:   runRender,
+}
+
+// global flags
 var colorScheme string
+var colorTableFile string
 var logLevel string
-var directory string
 var renderLabel bool
+var legend bool
+var background string
 var product string
+var elevation string
 var imageSize int32
 var runners int
-var products []string
+var format string
+var cog bool
+var quality int
+var pngCompression string
+var pngPalette bool
+var contours []string
+var projection string
+var parallels []string
+var cacheControl string
+var rangeKm float64
+var overlayFiles []string
+var overlayColor string
+var rings []string
+var spokes float64
+var center string
+var minValue float64
+var maxValue float64
+var smooth bool
+
+// render flags
+var outputFile string
+var listElevations bool
+var all bool
+
+// backgroundColor is the parsed --background flag
+var backgroundColor color.Color
+
+// overlays are the layers loaded from --overlay
+var overlays []*overlay.Layer
+
+// lineColor is the parsed --overlay-color, of overlays and range rings
+var lineColor color.Color
+
+// ringRanges are the --rings in meters
+var ringRanges []float64
 
-var colorSchemes map[string]map[string]func(float32) color.Color
+// selected is the elevation scan chosen with --elevation
+var selected elevationChoice
+
+// colors is the color table of the product, from --color-scheme or
+// --color-table
+var colors render.ColorTable
+
+// palette is the --color-table, if one was loaded
+var palette *render.Palette
+
+// autoScheme is set when neither --color-scheme nor --color-table is, to
+// color reflectivity from clear air mode volumes in the clean-air scheme
+var autoScheme bool
 
 func init() {
-	cmd.PersistentFlags().StringVarP(&inputFile, "file", "f", "", "archive 2 file to process")
-	cmd.PersistentFlags().StringVarP(&outputFile, "output", "o", "", "output radar image")
-	cmd.PersistentFlags().StringVarP(&product, "product", "p", "ref", "product to produce. ex: ref, vel, sw, rho")
-	cmd.PersistentFlags().StringVarP(&colorScheme, "color-scheme", "c", "noaa", "color scheme to use. noaa, radarscope, pink")
+	cmd.PersistentFlags().StringVarP(&product, "product", "p", "ref", "product to produce. ex: ref, vel, sw, zdr, phi, kdp, rho, cfp, div, shear, and for render cref, et and vil from the whole volume, or vwp, its VAD wind profile")
+	cmd.PersistentFlags().StringVarP(&elevation, "elevation", "e", "", "elevation scan to render, a cut number (1 is the first) or an angle in degrees (e.g. 0.5) matched to the nearest cut. defaults to the lowest tilt with the product")
+	cmd.PersistentFlags().StringVarP(&colorScheme, "color-scheme", "c", "noaa", "color scheme to use. noaa, radarscope, pink, clean-air. defaults to noaa, or clean-air for reflectivity from clear air mode volumes")
+	cmd.PersistentFlags().StringVar(&colorTableFile, "color-table", "", "color table file to use instead of a color scheme, a GRLevelX .pal or CSV of value,r,g,b[,a] stops")
+	cmd.MarkPersistentFlagFilename("color-table", "pal", "csv")
 	cmd.PersistentFlags().StringVarP(&logLevel, "log-level", "l", "warn", "log level, debug, info, warn, error")
 	cmd.PersistentFlags().Int32VarP(&imageSize, "size", "s", 1024, "size in pixel of the output image")
 	cmd.PersistentFlags().IntVarP(&runners, "threads", "t", runtime.NumCPU(), "threads")
-	cmd.PersistentFlags().StringVarP(&directory, "directory", "d", "", "directory of L2 files to process")
 	cmd.PersistentFlags().BoolVarP(&renderLabel, "label", "L", false, "label the image with station and date")
-
-	products = []string{"ref", "vel", "sw", "rho"}
-
-	colorSchemes = make(map[string]map[string]func(float32) color.Color)
-	colorSchemes["ref"] = map[string]func(float32) color.Color{
-		"noaa":          dbzColorNOAA,
-		"radarscope":    dbzColorScope,
-		"scope-classic": dbzColorScopeClassic,
-		"pink":          dbzColor,
-		"clean-air":     dbzColorCleanAirMode,
-	}
-	colorSchemes["vel"] = map[string]func(float32) color.Color{
-		"noaa":       velColorRadarscope, // placeholder for default product value
-		"radarscope": velColorRadarscope,
-	}
-	colorSchemes["sw"] = map[string]func(float32) color.Color{
-		"noaa": swColor,
-	}
-	colorSchemes["rho"] = map[string]func(float32) color.Color{
-		"noaa": rhoColor,
-	}
+	cmd.PersistentFlags().BoolVar(&legend, "legend", false, "draw a color bar of the product's values and units on png and svg images")
+	cmd.PersistentFlags().Float64Var(&minValue, "min-value", 0, "leave out values of the product below this, in its units, e.g. 20 to render only echoes of 20 dBZ and more")
+	cmd.PersistentFlags().Float64Var(&maxValue, "max-value", 0, "leave out values of the product above this, in its units")
+	cmd.PersistentFlags().BoolVar(&smooth, "smooth", false, "interpolate between gates for smooth rather than blocky png images and tiles")
+	cmd.PersistentFlags().StringVar(&background, "background", "black", "background of png and svg images, transparent, a color name or #rrggbb[aa]")
+	cmd.PersistentFlags().StringVarP(&format, "format", "F", "png", "output format. png, jpeg, svg, geotiff, geojson")
+	cmd.PersistentFlags().IntVar(&quality, "quality", 85, "quality of jpeg images, 1 to 100")
+	cmd.PersistentFlags().StringVar(&pngCompression, "png-compression", "default", "compression of png images. default, none, speed, best")
+	cmd.PersistentFlags().BoolVar(&pngPalette, "png-palette", true, "write png images of at most 256 colors paletted, several times smaller and quicker to encode without losing anything")
+	cmd.PersistentFlags().BoolVar(&cog, "cog", false, "write geotiffs using the cloud optimized geotiff layout")
+	cmd.PersistentFlags().StringSliceVar(&contours, "contours", []string{"20", "30", "40", "50", "60"}, "thresholds to contour at when writing geojson")
+	cmd.PersistentFlags().StringVar(&projection, "projection", "latlon", "projection of geotiff and geojson grids, and of png and jpeg images if set. latlon, aeqd, lcc, mercator, webmercator")
+	cmd.PersistentFlags().StringSliceVar(&parallels, "parallels", []string{"33", "45"}, "standard parallels of the lcc projection")
+	cmd.PersistentFlags().Float64Var(&rangeKm, "range-km", render.DefaultRadius/1000, "range in km from the center to the edges of the output")
+	cmd.PersistentFlags().StringVar(&center, "center", "", "lat,lon to center the output on instead of the radar, e.g. to zoom in on a storm with --range-km")
+	cmd.PersistentFlags().StringArrayVar(&overlayFiles, "overlay", nil, "GeoJSON or shapefile (.shp) of lines, such as state boundaries, to draw over png and svg images. repeatable")
+	cmd.MarkPersistentFlagFilename("overlay", "geojson", "json", "shp")
+	cmd.PersistentFlags().StringVar(&overlayColor, "overlay-color", "#ffffffb0", "color of --overlay lines and --rings, a color name or #rrggbb[aa]")
+	cmd.PersistentFlags().StringSliceVar(&rings, "rings", nil, "ranges in km to draw labelled range rings at over png and svg images, with a site marker, e.g. 50,100,150")
+	cmd.PersistentFlags().Float64Var(&spokes, "spokes", 30, "degrees between the azimuth lines drawn with --rings, 0 for none")
+	cmd.PersistentFlags().StringVar(&cacheControl, "cache-control", "", "Cache-Control header of products uploaded to object storage outputs, e.g. \"public, max-age=300\"")
+
+	renderCmd.Flags().StringVarP(&outputFile, "output", "o", "", "output file, or s3://, gs:// or azure:// url. defaults to radar.png (or .tif, .geojson), or the radar directory with --all")
+	renderCmd.MarkFlagFilename("output", "png", "jpg", "svg", "tif", "geojson")
+	renderCmd.Flags().BoolVar(&all, "all", false, "render every product of every elevation scan, decoding the volume once, into the output directory as product/cut, e.g. ref/01.png")
+	renderCmd.Flags().BoolVar(&listElevations, "list-elevations", false, "list the volume's elevation scans and their products instead of rendering")
+	cmd.AddCommand(renderCmd)
 }
 
 func main() {
+	addCompletions()
 	if err := cmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
 
-func run(cmd *cobra.Command, args []string) {
-
-	if _, ok := colorSchemes[product][colorScheme]; !ok {
-		logrus.Fatal(fmt.Sprintf("unsupported %s colorscheme %s", product, colorScheme))
-	}
-
+// setup applies and checks the global flags
+func setup(cmd *cobra.Command, args []string) {
 	lvl, err := logrus.ParseLevel(logLevel)
 	if err != nil {
 		logrus.Fatalf("failed to parse level: %s", err)
 	}
 	logrus.SetLevel(lvl)
 
-	if inputFile != "" {
-		out := "radar.png"
+	if colorTableFile != "" {
+		palette, err = render.LoadPalette(colorTableFile)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		colors = palette.Color
+	} else {
+		colors, err = render.Colors(product, colorScheme)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+	}
+	autoScheme = !cmd.Flags().Changed("color-scheme") && colorTableFile == ""
+	if backgroundColor, err = render.ParseColor(background); err != nil {
+		logrus.Fatal(err)
+	}
+	if lineColor, err = render.ParseColor(overlayColor); err != nil {
+		logrus.Fatal(err)
+	}
+	for _, name := range overlayFiles {
+		l, err := overlay.Load(name)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		l.Color = lineColor
+		overlays = append(overlays, l)
+	}
+	for _, r := range rings {
+		km, err := strconv.ParseFloat(strings.TrimSpace(r), 64)
+		if err != nil || km <= 0 {
+			logrus.Fatalf("invalid range ring %q", r)
+		}
+		ringRanges = append(ringRanges, km*1000)
+	}
+	if spokes < 0 || spokes > 360 {
+		logrus.Fatalf("invalid spokes %g", spokes)
+	}
+	if rangeKm <= 0 || rangeKm > 1000 {
+		logrus.Fatalf("invalid range %g km", rangeKm)
+	}
+	renderRadius = rangeKm * 1000
+	if centerLat, centerLon, err = parseCenter(center); err != nil {
+		logrus.Fatal(err)
+	}
+	// unset bounds are NaN, which every comparison with is false
+	if !cmd.Flags().Changed("min-value") {
+		minValue = math.NaN()
+	}
+	if !cmd.Flags().Changed("max-value") {
+		maxValue = math.NaN()
+	}
+	if minValue > maxValue {
+		logrus.Fatalf("--min-value %g is above --max-value %g", minValue, maxValue)
+	}
+	if selected, err = parseElevation(elevation); err != nil {
+		logrus.Fatal(err)
+	}
+	if (product == "vwp" || isVolumeProduct(product)) && cmd != renderCmd {
+		logrus.Fatalf("-p %s is only rendered by nexrad-render render", product)
+	}
+	if why, ok := unsupportedFormats[format]; ok {
+		logrus.Fatalf("unsupported format %s: %s", format, why)
+	}
+	if _, ok := formatExtensions[format]; !ok {
+		logrus.Fatalf("unsupported format %s", format)
+	}
+	if err := checkEncoding(); err != nil {
+		logrus.Fatal(err)
+	}
+	if err := checkLabels(); err != nil {
+		logrus.Fatal(err)
+	}
+	if _, err := projectionAt(0, 0); err != nil {
+		logrus.Fatal(err)
+	}
+	reproject = isRaster(format) && cmd.Flags().Changed("projection")
+	if runners < 1 {
+		runners = 1
+	}
+}
+
+func runRender(cmd *cobra.Command, args []string) {
+	if product == "vwp" {
+		out := "radar" + formatExtensions[format]
 		if outputFile != "" {
 			out = outputFile
 		}
-		single(inputFile, out, product)
-	} else if directory != "" {
-		out := "out"
+		renderWindProfile(args, out)
+		return
+	}
+	if len(args) > 1 {
+		logrus.Fatal("render takes one volume, use nexrad-render animate to render many")
+	}
+	if container.IsContainer(args[0]) {
+		logrus.Fatalf("%s holds many volumes, use nexrad-render animate to render them", args[0])
+	}
+	if listElevations {
+		ar2, err := load(args[0], 0)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		writeElevations(os.Stdout, ar2)
+		return
+	}
+	if all {
+		if err := checkNameTemplate(); err != nil {
+			logrus.Fatal(err)
+		}
+		out := "radar"
 		if outputFile != "" {
 			out = outputFile
 		}
-		animate(directory, out, product)
+		if failed := renderAll(args[0], out); failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	out := "radar" + formatExtensions[format]
+	if outputFile != "" {
+		out = outputFile
+	}
+	if isVolumeProduct(product) {
+		renderVolume(args[0], out)
+		return
 	}
+	single(args[0], out, product)
 }
 
-func animate(dir, outdir, prod string) {
-	files, err := ioutil.ReadDir(dir)
+func single(in, out, product string) {
+	fmt.Printf("Generating %s from %s -> %s\n", strings.ToUpper(product), in, out)
+
+	ar2, err := load(in, selected.number)
+	if err != nil {
+		logrus.Panic(err)
+	}
+	fmt.Println(ar2)
+	elv := selected.elevationFor(ar2, product)
+	if elv == 0 {
+		logrus.Fatalf("no %s data in %s, see --list-elevations for the products of each", product, elevationName())
+	}
+	label := newLabelFields(ar2.VolumeHeader, ar2.ElevationScans[elv], product)
+	dir, name := sink.Split(out)
+	s, err := sink.Open(dir)
 	if err != nil {
 		logrus.Fatal(err)
 	}
+	if err := output(s, name, ar2.ElevationScans[elv], product, label); err != nil {
+		logrus.Fatal(err)
+	}
+}
 
-	// create the output dir
-	if _, err := os.Stat(outdir); os.IsNotExist(err) {
-		os.Mkdir(outdir, os.ModePerm)
-	}
-
-	bar := pb.StartNew(len(files))
-
-	source := make(chan string, runners)
-	wg := sync.WaitGroup{}
-	wg.Add(runners)
-	for i := 0; i < runners; i++ {
-		go func(i int) {
-			for l2f := range source {
-				outf := fmt.Sprintf("%s/%s.png", outdir, l2f)
-				// fmt.Printf("Generating %s from %s -> %s\n", prod, l2f, outf)
-				f, err := os.Open(dir + "/" + l2f)
-				if err != nil {
-					logrus.Error(err)
-					return
-				}
-				ar2, err := archive2.Extract(f)
-				if err != nil {
-					logrus.Panic(err)
-				}
-				f.Close()
-				elv := 1
-				if prod == "vel" {
-					elv = 2
-				}
-				render(outf, ar2.ElevationScans[elv], fmt.Sprintf("%s - %s", ar2.VolumeHeader.ICAO, ar2.VolumeHeader.Date()))
-				bar.Increment()
-			}
-			wg.Done()
-		}(i)
+// load reads the volume from a local file, stdin if in is "-", or an
+// https://, s3:// or gs:// URL of a volume in a bucket. Volumes in buckets
+// are streamed, or read with ranged GETs loading only the elevation scan if
+// elv isn't 0.
+func load(in string, elv int) (*archive2.Archive2, error) {
+	if in == "-" {
+		return loadStdin()
+	}
+	if !fetch.IsURL(in) {
+		return (&container.Volume{Name: in}).Load()
 	}
+	client, key, err := fetch.ParseURL(in)
+	if err != nil {
+		return nil, err
+	}
+	if elv == 0 {
+		return client.Extract(context.Background(), key)
+	}
+	return fetch.NewLoader(client).Elevation(context.Background(), key, elv)
+}
 
-	for _, fn := range files {
-		if strings.HasSuffix(fn.Name(), ".ar2v") {
-			source <- fn.Name()
-		} else {
-			bar.Increment()
+// loadStdin decodes a volume piped to stdin, which may be gzipped
+func loadStdin() (*archive2.Archive2, error) {
+	r := bufio.NewReader(os.Stdin)
+	if magic, _ := r.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
 		}
+		defer gz.Close()
+		return archive2.Extract(bufio.NewReader(gz))
 	}
-	close(source)
-	wg.Wait()
-	bar.Finish()
+	return archive2.Extract(r)
 }
 
-func single(in, out, product string) {
-	fmt.Printf("Generating %s from %s -> %s\n", strings.ToUpper(product), in, out)
+// renderRadius is the range in meters from the center covered by each output,
+// from --range-km
+var renderRadius float64 = render.DefaultRadius
 
-	f, err := os.Open(in)
-	defer f.Close()
-	if err != nil {
-		logrus.Error(err)
-		return
-	}
+// centerLat and centerLon are the --center of outputs, NaN to center them on
+// the radar
+var centerLat, centerLon = math.NaN(), math.NaN()
 
-	ar2, err := archive2.Extract(f)
-	if err != nil {
-		logrus.Panic(err)
+// parseCenter parses a lat,lon center, returning NaNs if it's empty
+func parseCenter(s string) (float64, float64, error) {
+	if s == "" {
+		return math.NaN(), math.NaN(), nil
 	}
-	fmt.Println(ar2)
-	elv := 1
-	// if product != "ref" {
-	// elv = 2 // uhhh, why did i do this again?
-	// }
-	label := fmt.Sprintf("%s %f %s VCP:%d %s %s", ar2.VolumeHeader.ICAO, ar2.ElevationScans[2][0].Header.ElevationAngle, strings.ToUpper(product), ar2.RadarStatus.VolumeCoveragePatternNum, ar2.VolumeHeader.FileName(), ar2.VolumeHeader.Date().Format(time.RFC3339))
-	render(out, ar2.ElevationScans[elv], label)
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid center %q, expected lat,lon", s)
+	}
+	lat, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lon, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err1 != nil || err2 != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return 0, 0, fmt.Errorf("invalid center %q, expected lat,lon", s)
+	}
+	return lat, lon, nil
 }
 
-func render(out string, radials []*archive2.Message31, label string) {
-
-	width := float64(imageSize)
-	height := float64(imageSize)
-
-	canvas := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
-	draw.Draw(canvas, canvas.Bounds(), image.Black, image.ZP, draw.Src)
+// centerOf returns where to center the output of the sweep: --center, or the
+// radar
+func centerOf(radials []*archive2.Message31) (lat, lon float64) {
+	if !math.IsNaN(centerLat) {
+		return centerLat, centerLon
+	}
+	lat, lon, _, _ = sites.Locate(radials[0])
+	return lat, lon
+}
 
-	gc := draw2dimg.NewGraphicContext(canvas)
+var formatExtensions = map[string]string{
+	"png":     ".png",
+	"jpeg":    ".jpg",
+	"svg":     ".svg",
+	"geotiff": ".tif",
+	"geojson": ".geojson",
+}
 
-	xc := width / 2
-	yc := height / 2
-	pxPerKm := width / 2 / 460
-	// spew.Dump(radials)
-	firstGatePx := float64(radials[0].ReflectivityData.DataMomentRange) / 1000 * pxPerKm
-	gateIntervalKm := float64(radials[0].ReflectivityData.DataMomentRangeSampleInterval) / 1000
-	gateWidthPx := gateIntervalKm * pxPerKm
+// output writes the product from the radials in the selected output format to
+// name in the sink
+func output(s sink.Sink, name string, radials []*archive2.Message31, prod string, label labelFields) error {
+	_, err := outputImage(s, name, radials, prod, label)
+	return err
+}
 
-	log.Println("rendering radials")
-	// valueDist := map[float32]int{}
+// worldFileExtensions are the extensions of the world files of raster formats
+var worldFileExtensions = map[string]string{
+	"png":  ".pgw",
+	"jpeg": ".jgw",
+}
 
-	for _, radial := range radials {
-		// round to the nearest rounded azimuth for the given resolution.
-		// ex: for radial 20.5432, round to 20.5
-		azimuthAngle := float64(radial.Header.AzimuthAngle) - 90
-		if azimuthAngle < 0 {
-			azimuthAngle = 360.0 + azimuthAngle
+// outputImage is output, also returning the image written for png and jpeg
+// output
+func outputImage(s sink.Sink, name string, radials []*archive2.Message31, prod string, label labelFields) (image.Image, error) {
+	var buf bytes.Buffer
+	var img image.Image
+	var world []byte
+	var err error
+	switch format {
+	case "geotiff":
+		err = writeGeoTIFF(&buf, radials, prod)
+	case "geojson":
+		err = writeGeoJSON(&buf, radials, prod)
+	case "svg":
+		err = render.SVG(&buf, radials, ppiOptions(radials, prod, label))
+	case "png", "jpeg":
+		meta := label.meta(true)
+		if !reproject {
+			opts := ppiOptions(radials, prod, label)
+			img, err = render.PPI(radials, opts)
+			meta = append(meta, ppiGeoref(radials, opts)...)
+		} else {
+			var g *grid.Grid
+			if g, err = gridSweep(radials, prod); err == nil {
+				img, err = renderGridPNG(g, radials, prod, label)
+				// the world file georeferences the image
+				world = g.WorldFile()
+				meta = append(meta, gridGeoref(g)...)
+			}
 		}
-		azimuthSpacing := radial.Header.AzimuthResolutionSpacing()
-		azimuth := math.Floor(azimuthAngle)
-		if math.Floor(azimuthAngle+azimuthSpacing) > azimuth {
-			azimuth += azimuthSpacing
+		if err == nil {
+			err = encodeImage(&buf, img, meta)
 		}
-		startAngle := azimuth * (math.Pi / 180.0)      /* angles are specified */
-		endAngle := azimuthSpacing * (math.Pi / 180.0) /* clockwise in radians           */
-
-		// start drawing gates from the start of the first gate
-		distanceX, distanceY := firstGatePx, firstGatePx
-		gc.SetLineWidth(gateWidthPx + 1)
-		gc.SetLineCap(draw2d.ButtCap)
-
-		var gates []float32
-		switch product {
-		case "vel":
-			gates = radial.VelocityData.ScaledData()
-		case "sw":
-			gates = radial.SwData.ScaledData()
-		case "rho":
-			gates = radial.RhoData.ScaledData()
-		default:
-			gates = radial.ReflectivityData.ScaledData()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if world != nil {
+		if err := put(s, strings.TrimSuffix(name, path.Ext(name))+worldFileExtensions[format], world, cacheControl); err != nil {
+			return nil, err
 		}
+	}
+	return img, put(s, name, buf.Bytes(), cacheControl)
+}
 
-		numGates := len(gates)
-		for i, v := range gates {
-			if v != archive2.MomentDataBelowThreshold {
+// put writes the file to the sink with the content type for its name
+func put(s sink.Sink, name string, data []byte, cacheControl string) error {
+	return s.Put(name, data, sink.Metadata{ContentType: sink.ContentType(name), CacheControl: cacheControl})
+}
 
-				// valueDist[v] += 1
+// momentFor returns a function selecting the product's values from the
+// radials, with --min-value and --max-value applied to --product
+func momentFor(prod string, radials []*archive2.Message31) grid.MomentFunc {
+	moment := render.MomentFor(prod, radials)
+	if prod != product || (math.IsNaN(minValue) && math.IsNaN(maxValue)) {
+		return moment
+	}
+	return derived.Threshold(radials, moment, minValue, maxValue).Moment
+}
 
-				gc.MoveTo(xc+math.Cos(startAngle)*distanceX, yc+math.Sin(startAngle)*distanceY)
+// schemeFor returns the color scheme of the product from the sweep: the
+// --color-scheme, unless it's reflectivity from a clear air mode volume and
+// neither --color-scheme nor --color-table is set, as NWS displays switch to
+// the clean-air scale when the radar does
+func schemeFor(prod string, radials []*archive2.Message31) string {
+	if !autoScheme || (prod != "ref" && prod != "cref") || len(radials) == 0 {
+		return colorScheme
+	}
+	if archive2.ClearAirVCP(int(int16(radials[0].VolumeData.VolumeCoveragePatternNumber))) {
+		return "clean-air"
+	}
+	return colorScheme
+}
 
-				// make the gates connect visually by extending arcs so there is no space between adjacent gates.
-				if i == 0 {
-					gc.ArcTo(xc, yc, distanceX, distanceY, startAngle-.001, endAngle+.001)
-				} else if i == numGates-1 {
-					gc.ArcTo(xc, yc, distanceX, distanceY, startAngle, endAngle)
-				} else {
-					gc.ArcTo(xc, yc, distanceX, distanceY, startAngle, endAngle+.001)
-				}
+// colorsFor returns the color table of the product from the sweep: the one
+// selected with --color-scheme or --color-table for --product, and for others
+// (rendered with --all) the --color-scheme of the product if it has one,
+// otherwise noaa. See schemeFor.
+func colorsFor(prod string, radials []*archive2.Message31) render.ColorTable {
+	scheme := schemeFor(prod, radials)
+	if prod == product && scheme == colorScheme {
+		return colors
+	}
+	if c, err := render.Colors(prod, scheme); err == nil {
+		return c
+	}
+	c, _ := render.Colors(prod, "noaa")
+	return c
+}
 
-				gc.SetStrokeColor(colorSchemes[product][colorScheme](v))
-				gc.Stroke()
+// projections are the choices of --projection, other than latlon
+var projections = []string{"aeqd", "lcc", "mercator", "webmercator"}
+
+// reproject is set when png images are gridded in --projection, rather than
+// drawn with the radar at the center
+var reproject bool
+
+// projectionAt returns the selected projection for a radar at lat, lon, or
+// nil for lat/lon grids
+func projectionAt(lat, lon float64) (geo.Projection, error) {
+	switch projection {
+	case "latlon":
+		return nil, nil
+	case "aeqd":
+		return geo.AzimuthalEquidistant{Lat0: lat, Lon0: lon}, nil
+	case "mercator":
+		return geo.Mercator{Lon0: lon}, nil
+	case "webmercator":
+		return geo.WebMercator{}, nil
+	case "lcc":
+		if len(parallels) < 1 || len(parallels) > 2 {
+			return nil, fmt.Errorf("expected one or two standard parallels, got %d", len(parallels))
+		}
+		var ps [2]float64
+		for i, p := range parallels {
+			v, err := strconv.ParseFloat(p, 64)
+			if err != nil || v <= -90 || v >= 90 {
+				return nil, fmt.Errorf("invalid standard parallel %q", p)
 			}
-
-			distanceX += gateWidthPx
-			distanceY += gateWidthPx
-			azimuth += radial.Header.AzimuthResolutionSpacing()
+			ps[i] = v
+		}
+		if len(parallels) == 1 {
+			ps[1] = ps[0]
 		}
+		if ps[0] == -ps[1] {
+			return nil, fmt.Errorf("standard parallels %v can't be opposite", parallels)
+		}
+		return geo.LambertConformal{Lat0: lat, Lon0: lon, Parallel1: ps[0], Parallel2: ps[1]}, nil
 	}
+	return nil, fmt.Errorf("unsupported projection %s", projection)
+}
 
-	// fmt.Println(valueDist)
+// gridSweep grids the product in the selected projection
+func gridSweep(radials []*archive2.Message31, prod string) (*grid.Grid, error) {
+	lat, lon, _, _ := sites.Locate(radials[0])
+	proj, err := projectionAt(lat, lon)
+	if err != nil {
+		return nil, err
+	}
+	moment := momentFor(prod, radials)
+	clat, clon := centerOf(radials)
+	if proj == nil {
+		return grid.FromSweepAt(radials, moment, clat, clon, renderRadius, int(imageSize)), nil
+	}
+	return grid.FromSweepProjectedAt(radials, moment, clat, clon, renderRadius, int(imageSize), proj), nil
+}
 
-	if renderLabel {
-		addLabel(canvas, int(width-495.0), int(height-10.0), label)
+func writeGeoTIFF(w io.Writer, radials []*archive2.Message31, prod string) error {
+	g, err := gridSweep(radials, prod)
+	if err != nil {
+		return err
 	}
+	return geotiff.Write(w, g, geotiff.Options{COG: cog})
+}
 
-	// Save to file
-	draw2dimg.SaveToPngFile(out, canvas)
+// writeGeoJSON writes contours of the sweep as a GeoJSON feature collection
+func writeGeoJSON(w io.Writer, radials []*archive2.Message31, prod string) error {
+	g, err := gridSweep(radials, prod)
+	if err != nil {
+		return err
+	}
+	return writeContours(w, g, prod)
 }
 
-func addLabel(img *image.RGBA, x, y int, label string) {
-	point := fixed.Point26_6{fixed.Int26_6(x * 64), fixed.Int26_6(y * 64)}
+// writeContours writes contours of the grid as a GeoJSON feature collection
+func writeContours(w io.Writer, g *grid.Grid, prod string) error {
+	thresholds := make([]float32, len(contours))
+	for i, c := range contours {
+		t, err := strconv.ParseFloat(c, 32)
+		if err != nil {
+			return fmt.Errorf("invalid contour threshold %q: %s", c, err)
+		}
+		thresholds[i] = float32(t)
+	}
+	fc := geojson.Contours(g, thresholds)
+	for _, f := range fc.Features {
+		f.Properties["product"] = prod
+	}
+	return fc.Write(w)
+}
+
+// ppiOptions returns the options to render the product from the sweep with,
+// labelled with the label fields if --label or --timestamp is set
+func ppiOptions(radials []*archive2.Message31, prod string, label labelFields) render.Options {
+	opts := render.Options{
+		Product:    prod,
+		Size:       int(imageSize),
+		ColorTable: colorsFor(prod, radials),
+		Moment:     momentFor(prod, radials),
+		Smooth:     smooth,
+		Radius:     renderRadius,
+		Background: backgroundColor,
+		Overlays:   overlaysFor(radials),
+		Legend:     legendFor(prod, radials),
+	}
+	if !math.IsNaN(centerLat) {
+		lat, lon, _, _ := sites.Locate(radials[0])
+		bearing, distance := geo.BearingDistance(lat, lon, centerLat, centerLon)
+		opts.East = distance * math.Sin(bearing*math.Pi/180)
+		opts.North = distance * math.Cos(bearing*math.Pi/180)
+	}
+	label.apply(&opts)
+	return opts
+}
+
+// legendFor returns the scale of the product's --legend from the sweep, nil
+// if it isn't set. A --color-table's legend covers its stops, and --min-value
+// and --max-value narrow it.
+func legendFor(prod string, radials []*archive2.Message31) *render.Scale {
+	if !legend {
+		return nil
+	}
+	s := render.ScaleFor(prod, schemeFor(prod, radials))
+	if prod == product && palette != nil {
+		s.Min, s.Max = palette.Range()
+	}
+	// only the values left in are shown
+	if prod == product && minValue > float64(s.Min) && minValue < float64(s.Max) {
+		s.Min = float32(minValue)
+	}
+	if prod == product && maxValue > float64(s.Min) && maxValue < float64(s.Max) {
+		s.Max = float32(maxValue)
+	}
+	return &s
+}
 
-	d := &font.Drawer{
-		Dst:  img,
-		Src:  image.NewUniform(colornames.Gray),
-		Face: inconsolata.Bold8x16,
-		Dot:  point,
+// overlaysFor returns the layers to draw over images of the sweep: the
+// --overlay files, then the --rings around its radar
+func overlaysFor(radials []*archive2.Message31) []*overlay.Layer {
+	rings := ringLayers(radials)
+	if len(rings) == 0 {
+		return overlays
 	}
-	d.DrawString(label)
+	return append(append([]*overlay.Layer{}, overlays...), rings...)
 }
 
-// scaleInt scales a number form one range to another range
-func scaleInt(value, oldMax, oldMin, newMax, newMin int32) int32 {
-	oldRange := (oldMax - oldMin)
-	newRange := (newMax - newMin)
-	return (((value - oldMin) * newRange) / oldRange) + newMin
+// ringLayers returns the --rings around the sweep's radar and its marker,
+// none if --rings isn't set
+func ringLayers(radials []*archive2.Message31) []*overlay.Layer {
+	if len(ringRanges) == 0 {
+		return nil
+	}
+	lat, lon, _, ok := sites.Locate(radials[0])
+	if !ok {
+		return nil
+	}
+	r := overlay.Rings(lat, lon, ringRanges, spokes)
+	m := overlay.Marker(lat, lon, strings.TrimSpace(string(radials[0].Header.RadarIdentifier[:])))
+	r.Color, m.Color = lineColor, lineColor
+	return []*overlay.Layer{r, m}
+}
+
+// renderGridPNG renders the gridded product from the sweep for a PNG with a
+// pixel per cell
+func renderGridPNG(g *grid.Grid, radials []*archive2.Message31, prod string, label labelFields) (image.Image, error) {
+	opts := render.Options{
+		Product:    prod,
+		ColorTable: colorsFor(prod, radials),
+		Background: backgroundColor,
+		Overlays:   overlaysFor(radials),
+		Legend:     legendFor(prod, radials),
+	}
+	label.apply(&opts)
+	return render.Grid(g, opts)
 }
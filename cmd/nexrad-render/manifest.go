@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+
+	"time"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/geo"
+	"github.com/kallsyms/go-nexrad/sink"
+	"github.com/kallsyms/go-nexrad/sites"
+)
+
+// manifest lists the frames of an animation, for web players to drive time
+// sliders and place the frames on a map
+type manifest struct {
+	Product string           `json:"product"`
+	Format  string           `json:"format"`
+	Frames  []*manifestFrame `json:"frames"`
+}
+
+type manifestFrame struct {
+	File string `json:"file"`
+	Site string `json:"site"`
+	// Time is when the sweep started, which is the time the frame is valid
+	// for; VolumeTime is the start of the volume scan
+	Time       time.Time `json:"time"`
+	VolumeTime time.Time `json:"volume_time"`
+	// Elevation is the elevation angle of the sweep in degrees
+	Elevation float32 `json:"elevation"`
+	Georef    georef  `json:"georef"`
+}
+
+// georef places a frame on the map
+type georef struct {
+	// Projection is "EPSG:4326" for lat/lon geotiff and png frames and
+	// geojson frames (which are always lat/lon), the PROJ string of frames
+	// in another --projection, and "radar" for images without a
+	// --projection, which are drawn with the radar at the center and slant
+	// range scaled linearly to the edges
+	Projection string `json:"projection"`
+	// Latitude and Longitude of the radar
+	Latitude  float32 `json:"latitude"`
+	Longitude float32 `json:"longitude"`
+	// Range in meters covered from the center (the radar, unless --center
+	// is set) to each edge
+	Range float64 `json:"range"`
+	// Bounds are the west, south, east and north edges in degrees. Images
+	// and projected geotiffs only approximately fill them.
+	Bounds [4]float64 `json:"bounds"`
+}
+
+func newManifestFrame(file string, vh archive2.VolumeHeaderRecord, radials []*archive2.Message31) *manifestFrame {
+	first := radials[0]
+	lat, lon, _, _ := sites.Locate(first)
+	clat, clon := centerOf(radials)
+	north, _ := geo.Destination(clat, clon, 0, renderRadius)
+	_, east := geo.Destination(clat, clon, 90, renderRadius)
+	dlat, dlon := north-clat, east-clon
+
+	projection := "EPSG:4326"
+	if (isRaster(format) || format == "svg") && !reproject {
+		projection = "radar"
+	} else if format != "geojson" {
+		// the projection was checked before rendering
+		if proj, _ := projectionAt(lat, lon); proj != nil {
+			projection = proj.Proj4()
+		}
+	}
+	return &manifestFrame{
+		File:       file,
+		Site:       string(vh.ICAO[:]),
+		Time:       first.Header.Date(),
+		VolumeTime: vh.Date(),
+		Elevation:  first.Header.ElevationAngle,
+		Georef: georef{
+			Projection: projection,
+			Latitude:   float32(lat),
+			Longitude:  float32(lon),
+			Range:      renderRadius,
+			Bounds:     [4]float64{clon - dlon, clat - dlat, clon + dlon, clat + dlat},
+		},
+	}
+}
+
+// write writes the manifest to name in the sink
+func (m *manifest) write(s sink.Sink, name string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return put(s, name, append(b, '\n'), "no-cache")
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/export/pngtext"
+	"github.com/kallsyms/go-nexrad/geo"
+	"github.com/kallsyms/go-nexrad/grid"
+	"github.com/kallsyms/go-nexrad/render"
+	"github.com/kallsyms/go-nexrad/sites"
+)
+
+// software names the renderer and its module version in png metadata
+func software() string {
+	version := "(devel)"
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		version = info.Main.Version
+	}
+	return "nexrad-render, github.com/kallsyms/go-nexrad " + version
+}
+
+// meta returns the provenance of png images of the label fields: the site,
+// product, the sweep's elevation if the image is of one, VCP and valid time,
+// and the software that rendered it
+func (f labelFields) meta(sweep bool) []pngtext.Field {
+	fields := []pngtext.Field{
+		{Key: "Site", Value: f.Site},
+		{Key: "Product", Value: f.Product},
+	}
+	if sweep {
+		fields = append(fields, pngtext.Field{Key: "Elevation", Value: fmt.Sprintf("%.2f", f.Elevation)})
+	}
+	if f.VCP != 0 {
+		fields = append(fields, pngtext.Field{Key: "VCP", Value: strconv.Itoa(f.VCP)})
+	}
+	return append(fields,
+		pngtext.Field{Key: "Valid Time", Value: f.Time.UTC().Format(time.RFC3339)},
+		pngtext.Field{Key: "Software", Value: software()},
+	)
+}
+
+// imageGeoref returns the georeferencing of an image: its projection, its
+// extent in the projection (xmin,ymin,xmax,ymax, from the outer edges of the
+// corner pixels) and its bounds in degrees (west,south,east,north, as in
+// animation manifests). inverse returns the lat, lon of projected points.
+func imageGeoref(proj string, xmin, ymin, xmax, ymax float64, inverse func(x, y float64) (lat, lon float64)) []pngtext.Field {
+	// the edges of a projected extent needn't be lines of latitude or
+	// longitude, so points along them are bounded
+	south, west := math.Inf(1), math.Inf(1)
+	north, east := math.Inf(-1), math.Inf(-1)
+	const steps = 32
+	for i := 0; i <= steps; i++ {
+		f := float64(i) / steps
+		x, y := xmin+(xmax-xmin)*f, ymin+(ymax-ymin)*f
+		for _, p := range [][2]float64{{x, ymin}, {x, ymax}, {xmin, y}, {xmax, y}} {
+			lat, lon := inverse(p[0], p[1])
+			south, north = math.Min(south, lat), math.Max(north, lat)
+			west, east = math.Min(west, lon), math.Max(east, lon)
+		}
+	}
+	return []pngtext.Field{
+		{Key: "Projection", Value: proj},
+		{Key: "Extent", Value: fmt.Sprintf("%.12g,%.12g,%.12g,%.12g", xmin, ymin, xmax, ymax)},
+		{Key: "Bounds", Value: fmt.Sprintf("%.6f,%.6f,%.6f,%.6f", west, south, east, north)},
+	}
+}
+
+// ppiGeoref returns the georeferencing of a PPI of the sweep. PPIs draw slant
+// range as distance from the radar, so they're in the azimuthal equidistant
+// projection centered on it, to within the small difference between slant
+// range and ground distance.
+func ppiGeoref(radials []*archive2.Message31, opts render.Options) []pngtext.Field {
+	lat, lon, _, _ := sites.Locate(radials[0])
+	p := geo.AzimuthalEquidistant{Lat0: lat, Lon0: lon}
+	return imageGeoref(p.Proj4(), opts.East-opts.Radius, opts.North-opts.Radius, opts.East+opts.Radius, opts.North+opts.Radius, p.Inverse)
+}
+
+// gridGeoref returns the georeferencing of an image of the grid, with a
+// pixel per cell
+func gridGeoref(g *grid.Grid) []pngtext.Field {
+	proj := "EPSG:4326"
+	inverse := func(x, y float64) (float64, float64) { return y, x }
+	if g.Projection != nil {
+		proj, inverse = g.Projection.Proj4(), g.Projection.Inverse
+	}
+	return imageGeoref(proj, g.West, g.North-float64(g.Height)*g.DLat, g.West+float64(g.Width)*g.DLon, g.North, inverse)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/container"
+	"github.com/kallsyms/go-nexrad/export/geotiff"
+	"github.com/kallsyms/go-nexrad/geo"
+	"github.com/kallsyms/go-nexrad/grid"
+	"github.com/kallsyms/go-nexrad/overlay"
+	"github.com/kallsyms/go-nexrad/render"
+	"github.com/kallsyms/go-nexrad/sink"
+	"github.com/kallsyms/go-nexrad/sites"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var mosaicCmd = &cobra.Command{
+	Use:   "mosaic VOLUME...",
+	Short: "merge the volumes of several radars into one regional image",
+	Long: `Grids the product from the volumes of several radars onto one map
+covering them all, or --bounds, keeping the largest value where they overlap
+so the strongest echo wins. The volumes should be valid at about the same
+time; those more than --max-skew older than the latest are left out.
+
+The mosaic is --size cells across, in the --projection (lat/lon by default).`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runMosaic,
+}
+
+var mosaicOutput string
+var bounds string
+var maxSkew time.Duration
+
+func init() {
+	mosaicCmd.Flags().StringVarP(&mosaicOutput, "output", "o", "", "output file, or s3://, gs:// or azure:// url. defaults to mosaic.png (or .tif, .geojson)")
+	mosaicCmd.Flags().StringVar(&bounds, "bounds", "", "south,west,north,east bounds of the mosaic in degrees. defaults to the area the radars cover")
+	mosaicCmd.Flags().DurationVar(&maxSkew, "max-skew", 10*time.Minute, "how much older than the latest volume the others can be")
+	cmd.AddCommand(mosaicCmd)
+}
+
+// mosaicSweep is the sweep of a radar to merge into a mosaic
+type mosaicSweep struct {
+	name    string
+	radials []*archive2.Message31
+	time    time.Time
+}
+
+func runMosaic(cmd *cobra.Command, args []string) {
+	if !isRaster(format) && format != "geotiff" && format != "geojson" {
+		logrus.Fatalf("mosaics can't be written as %s", format)
+	}
+	sweeps, failed := loadSweeps(args)
+	if len(sweeps) == 0 {
+		logrus.Fatalf("no %s data in any volume", product)
+	}
+	latest := sweeps[0].time
+	for _, s := range sweeps {
+		if s.time.After(latest) {
+			latest = s.time
+		}
+	}
+	var current []*mosaicSweep
+	for _, s := range sweeps {
+		if latest.Sub(s.time) > maxSkew {
+			logrus.Warnf("%s: left out, %s older than the latest volume", s.name, latest.Sub(s.time))
+			continue
+		}
+		current = append(current, s)
+	}
+
+	south, west, north, east, err := mosaicBounds(current)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	proj, err := projectionAt((south+north)/2, (west+east)/2)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	out := "mosaic" + formatExtensions[format]
+	if mosaicOutput != "" {
+		out = mosaicOutput
+	}
+	fmt.Printf("Generating %s mosaic of %d radars -> %s\n", strings.ToUpper(product), len(current), out)
+
+	g := grid.FromBounds(south, west, north, east, int(imageSize), proj)
+	layers := append([]*overlay.Layer{}, overlays...)
+	label := labelFields{
+		Product:   strings.ToUpper(product),
+		Elevation: current[0].radials[0].Header.ElevationAngle,
+		VCP:       int(int16(current[0].radials[0].VolumeData.VolumeCoveragePatternNumber)),
+		File:      path.Base(out),
+		Time:      latest,
+	}
+	var names []string
+	for _, s := range current {
+		g.MergeSweep(s.radials, momentFor(product, s.radials), renderRadius)
+		layers = append(layers, ringLayers(s.radials)...)
+		names = append(names, strings.TrimSpace(string(s.radials[0].Header.RadarIdentifier[:])))
+		for _, m31 := range s.radials {
+			m31.Release()
+		}
+	}
+
+	dir, name := sink.Split(out)
+	s, err := sink.Open(dir)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	label.Site = strings.Join(names, ",")
+	if err := writeMosaic(s, name, g, layers, label); err != nil {
+		logrus.Fatal(err)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// loadSweeps loads the sweep of the product from each volume, in parallel,
+// returning them in the order of the volumes and the number that failed.
+// Volumes without the product are left out.
+func loadSweeps(names []string) ([]*mosaicSweep, int) {
+	sweeps := make([]*mosaicSweep, len(names))
+	failed := 0
+	mtx := sync.Mutex{}
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	wg.Add(runners)
+	for i := 0; i < runners; i++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				radials, vh, err := loadSweep(&container.Volume{Name: names[i]}, product)
+				switch {
+				case err != nil:
+					logrus.Errorf("%s: %s", names[i], err)
+					mtx.Lock()
+					failed++
+					mtx.Unlock()
+				case radials == nil:
+					logrus.Warnf("%s: no %s data in %s", names[i], product, elevationName())
+				default:
+					sweeps[i] = &mosaicSweep{name: names[i], radials: radials, time: vh.Date()}
+				}
+			}
+		}()
+	}
+	for i := range names {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var loaded []*mosaicSweep
+	for _, s := range sweeps {
+		if s != nil {
+			loaded = append(loaded, s)
+		}
+	}
+	return loaded, failed
+}
+
+// mosaicBounds returns the --bounds, or the bounds of the area within the
+// --range-km of the sweeps' radars
+func mosaicBounds(sweeps []*mosaicSweep) (south, west, north, east float64, err error) {
+	if bounds != "" {
+		parts := strings.Split(bounds, ",")
+		var v [4]float64
+		if len(parts) == 4 {
+			for i, p := range parts {
+				if v[i], err = strconv.ParseFloat(strings.TrimSpace(p), 64); err != nil {
+					break
+				}
+			}
+		}
+		if len(parts) != 4 || err != nil || v[0] >= v[2] || v[1] >= v[3] || v[0] < -90 || v[2] > 90 {
+			return 0, 0, 0, 0, fmt.Errorf("invalid bounds %q, expected south,west,north,east", bounds)
+		}
+		return v[0], v[1], v[2], v[3], nil
+	}
+	south, west = math.Inf(1), math.Inf(1)
+	north, east = math.Inf(-1), math.Inf(-1)
+	for _, s := range sweeps {
+		lat, lon, _, ok := sites.Locate(s.radials[0])
+		if !ok {
+			continue
+		}
+		n, _ := geo.Destination(lat, lon, 0, renderRadius)
+		so, _ := geo.Destination(lat, lon, 180, renderRadius)
+		_, e := geo.Destination(lat, lon, 90, renderRadius)
+		south, north = math.Min(south, so), math.Max(north, n)
+		west, east = math.Min(west, lon-(e-lon)), math.Max(east, e)
+	}
+	if math.IsInf(south, 0) {
+		return 0, 0, 0, 0, fmt.Errorf("no radar locations, set --bounds")
+	}
+	return south, west, north, east, nil
+}
+
+// writeMosaic writes the mosaic in the selected output format to name in the
+// sink, with the layers drawn over images
+func writeMosaic(s sink.Sink, name string, g *grid.Grid, layers []*overlay.Layer, label labelFields) error {
+	var buf bytes.Buffer
+	switch format {
+	case "geotiff":
+		if err := geotiff.Write(&buf, g, geotiff.Options{COG: cog}); err != nil {
+			return err
+		}
+	case "geojson":
+		if err := writeContours(&buf, g, product); err != nil {
+			return err
+		}
+	default:
+		// the radars may not all be in the same mode, so the scheme isn't
+		// chosen by any one's
+		opts := render.Options{
+			Product:    product,
+			ColorTable: colorsFor(product, nil),
+			Background: backgroundColor,
+			Overlays:   layers,
+			Legend:     legendFor(product, nil),
+		}
+		label.apply(&opts)
+		img, err := render.Grid(g, opts)
+		if err != nil {
+			return err
+		}
+		if err := encodeImage(&buf, img, append(label.meta(false), gridGeoref(g)...)); err != nil {
+			return err
+		}
+		world := strings.TrimSuffix(name, path.Ext(name)) + worldFileExtensions[format]
+		if err := put(s, world, g.WorldFile(), cacheControl); err != nil {
+			return err
+		}
+	}
+	return put(s, name, buf.Bytes(), cacheControl)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/container"
+)
+
+// nameTemplate is the --name-template of animate frames and render --all
+// sweeps
+var nameTemplate string
+
+// outputName is the parsed --name-template, nil to name outputs the default
+// way
+var outputName *template.Template
+
+func init() {
+	usage := "text/template of output file names, of .ICAO, .Time, .Product, .Tilt (the cut number), .Elevation, .Input and .Ext, e.g. \"{{.ICAO}}_{{.Time}}_{{.Product}}_{{.Tilt}}.png\". the format's extension is added if it doesn't end with it"
+	animateCmd.Flags().StringVar(&nameTemplate, "name-template", "", usage+". defaults to the volume's name")
+	renderCmd.Flags().StringVar(&nameTemplate, "name-template", "", usage+". defaults to product/cut with --all")
+}
+
+// nameTime is the time of a volume in output names, sortable as
+// YYYYMMDD_HHMMSS by default and formattable like a time.Time
+type nameTime struct {
+	time.Time
+}
+
+func (t nameTime) String() string {
+	return t.UTC().Format("20060102_150405")
+}
+
+// nameFields are the fields of the --name-template
+type nameFields struct {
+	ICAO      string
+	Time      nameTime
+	Product   string
+	Tilt      string
+	Elevation float32
+	Input     string
+	Ext       string
+}
+
+// newNameFields returns the name fields of the product's sweep, the elv'th
+// of the volume read from input
+func newNameFields(input string, vh archive2.VolumeHeaderRecord, radials []*archive2.Message31, prod string, elv int) nameFields {
+	return nameFields{
+		ICAO:      strings.TrimSpace(string(vh.ICAO[:])),
+		Time:      nameTime{vh.Date()},
+		Product:   prod,
+		Tilt:      fmt.Sprintf("%02d", elv),
+		Elevation: radials[0].Header.ElevationAngle,
+		Input:     container.TrimExt(filepath.Base(input)),
+		Ext:       formatExtensions[format],
+	}
+}
+
+// checkNameTemplate parses the --name-template
+func checkNameTemplate() error {
+	if nameTemplate == "" {
+		return nil
+	}
+	t, err := template.New("name").Parse(nameTemplate)
+	if err != nil {
+		return fmt.Errorf("invalid --name-template: %s", err)
+	}
+	// unknown fields are only found executing the template
+	if err := t.Execute(&bytes.Buffer{}, nameFields{}); err != nil {
+		return fmt.Errorf("invalid --name-template: %s", err)
+	}
+	outputName = t
+	return nil
+}
+
+// name returns the output name from the --name-template, or def if it isn't
+// set
+func (f nameFields) name(def string) (string, error) {
+	if outputName == nil {
+		return def, nil
+	}
+	var buf bytes.Buffer
+	if err := outputName.Execute(&buf, f); err != nil {
+		return "", err
+	}
+	name := strings.TrimPrefix(path.Clean(filepath.ToSlash(buf.String())), "/")
+	if name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return "", fmt.Errorf("invalid output name %q from --name-template", buf.String())
+	}
+	if !strings.HasSuffix(name, f.Ext) {
+		name += f.Ext
+	}
+	return name, nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"path"
+	"strings"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/container"
+	"github.com/kallsyms/go-nexrad/derived"
+	"github.com/kallsyms/go-nexrad/geo"
+	"github.com/kallsyms/go-nexrad/grid"
+	"github.com/kallsyms/go-nexrad/render"
+	"github.com/kallsyms/go-nexrad/sink"
+	"github.com/kallsyms/go-nexrad/sites"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var sectionCmd = &cobra.Command{
+	Use:   "section FILE|URL|-",
+	Short: "render a vertical cross-section through every elevation of a volume",
+	Long: `Slices every elevation scan of the volume along a line on the ground,
+--from one lat,lon --to another, or from the radar out --range-km along an
+--azimuth (an RHI), and plots the product by distance along the line and
+height above sea level up to --top-km.
+
+Between the beams of the higher tilts values are interpolated from the tilts
+above and below; above the highest and below the lowest beam there's no data.
+--beams outlines the beam of each tilt on sections out from the radar, showing
+how the beams rise over the echoes with range.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runSection,
+}
+
+var sectionOutput string
+var sectionFrom string
+var sectionTo string
+var sectionAzimuth float64
+var topKm float64
+var sectionBeams bool
+
+func init() {
+	sectionCmd.Flags().StringVarP(&sectionOutput, "output", "o", "", "output file, or s3://, gs:// or azure:// url. defaults to section.png (or .jpg)")
+	sectionCmd.Flags().StringVar(&sectionFrom, "from", "", "lat,lon of the start of the section. defaults to the radar")
+	sectionCmd.Flags().StringVar(&sectionTo, "to", "", "lat,lon of the end of the section")
+	sectionCmd.Flags().Float64Var(&sectionAzimuth, "azimuth", 0, "azimuth in degrees to slice along from the radar out to --range-km, instead of --from and --to")
+	sectionCmd.Flags().Float64Var(&topKm, "top-km", 15, "height in km above sea level of the top of the section")
+	sectionCmd.Flags().BoolVar(&sectionBeams, "beams", false, "outline the beam of each elevation, for sections from the radar")
+	cmd.AddCommand(sectionCmd)
+}
+
+func runSection(cmd *cobra.Command, args []string) {
+	if !isRaster(format) {
+		logrus.Fatalf("sections can't be written as %s", format)
+	}
+	if topKm <= 0 || topKm > 30 {
+		logrus.Fatalf("invalid --top-km %g", topKm)
+	}
+	azimuth := cmd.Flags().Changed("azimuth")
+	if azimuth && (sectionFrom != "" || sectionTo != "") {
+		logrus.Fatal("--azimuth can't be used with --from and --to")
+	}
+	if !azimuth && sectionTo == "" {
+		logrus.Fatal("a section needs --to or --azimuth")
+	}
+	if sectionBeams && sectionFrom != "" {
+		logrus.Fatal("--beams can't be used with --from, the section must start at the radar")
+	}
+	if container.IsContainer(args[0]) {
+		logrus.Fatalf("%s holds many volumes, a section is of one", args[0])
+	}
+
+	ar2, err := load(args[0], 0)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	// the lowest tilt with the product locates the radar and labels the image
+	elv := (elevationChoice{}).elevationFor(ar2, product)
+	if elv == 0 {
+		logrus.Fatalf("no %s data in %s", product, args[0])
+	}
+	radials := ar2.ElevationScans[elv]
+	lat, lon, _, ok := sites.Locate(radials[0])
+	if !ok {
+		logrus.Fatalf("%s: unknown radar site", args[0])
+	}
+	startLat, startLon, endLat, endLon, err := sectionLine(lat, lon, azimuth)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	out := "section" + formatExtensions[format]
+	if sectionOutput != "" {
+		out = sectionOutput
+	}
+	fmt.Printf("Generating %s cross-section from %s -> %s\n", strings.ToUpper(product), args[0], out)
+
+	label := newLabelFields(ar2.VolumeHeader, radials, product)
+	label.File = path.Base(out)
+	opts := render.Options{
+		Product:    product,
+		Size:       int(imageSize),
+		ColorTable: colorsFor(product, radials),
+		Background: backgroundColor,
+		Legend:     legendFor(product, radials),
+	}
+	label.apply(&opts)
+	width, height := render.SectionSize(opts)
+	if width <= 0 || height <= 0 {
+		logrus.Fatalf("--size %d is too small for a section", imageSize)
+	}
+	moment := func(sweep []*archive2.Message31) grid.MomentFunc { return momentFor(product, sweep) }
+	s := derived.CrossSection(ar2, moment, startLat, startLon, endLat, endLon, topKm*1000, width, height)
+	if sectionBeams {
+		s.Beams = derived.SectionBeams(ar2, moment, s.Length, width)
+	}
+	img, err := render.Section(s, opts)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := encodeImage(&buf, img, label.meta(false)); err != nil {
+		logrus.Fatal(err)
+	}
+	dir, name := sink.Split(out)
+	sk, err := sink.Open(dir)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	if err := put(sk, name, buf.Bytes(), cacheControl); err != nil {
+		logrus.Fatal(err)
+	}
+}
+
+// sectionLine returns the ends of the section: from the radar at lat, lon out
+// --range-km along the --azimuth, or --from (the radar by default) --to
+func sectionLine(lat, lon float64, azimuth bool) (startLat, startLon, endLat, endLon float64, err error) {
+	if azimuth {
+		endLat, endLon = geo.Destination(lat, lon, sectionAzimuth, renderRadius)
+		return lat, lon, endLat, endLon, nil
+	}
+	startLat, startLon = lat, lon
+	if sectionFrom != "" {
+		if startLat, startLon, err = parseLatLon("--from", sectionFrom); err != nil {
+			return
+		}
+	}
+	if endLat, endLon, err = parseLatLon("--to", sectionTo); err != nil {
+		return
+	}
+	if _, d := geo.BearingDistance(startLat, startLon, endLat, endLon); d < 1000 {
+		return 0, 0, 0, 0, fmt.Errorf("the section to %s is under 1 km long", sectionTo)
+	}
+	return
+}
+
+// parseLatLon parses the lat,lon of the flag
+func parseLatLon(flag, s string) (float64, float64, error) {
+	lat, lon, err := parseCenter(s)
+	if err != nil || math.IsNaN(lat) {
+		return 0, 0, fmt.Errorf("invalid %s %q, expected lat,lon", flag, s)
+	}
+	return lat, lon, nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/cheggaaa/pb/v3"
+	"github.com/kallsyms/go-nexrad/container"
+	"github.com/kallsyms/go-nexrad/geo"
+	"github.com/kallsyms/go-nexrad/grid"
+	"github.com/kallsyms/go-nexrad/render"
+	"github.com/kallsyms/go-nexrad/sink"
+	"github.com/kallsyms/go-nexrad/sites"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var tilesCmd = &cobra.Command{
+	Use:   "tiles FILE",
+	Short: "render a volume as web map (XYZ) tiles",
+	Long: `Renders the product as 256 pixel web mercator PNG tiles, written to
+OUTPUT/z/x/y.png for use as an XYZ layer in web maps. Tiles without data
+aren't written, and pixels without data are transparent.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runTiles,
+}
+
+const tileSize = 256
+
+var tilesOutput string
+var minZoom int
+var maxZoom int
+
+func init() {
+	tilesCmd.Flags().StringVarP(&tilesOutput, "output", "o", "tiles", "directory, or s3://, gs:// or azure:// url, to write tiles to")
+	tilesCmd.Flags().IntVar(&minZoom, "min-zoom", 5, "lowest zoom level to render")
+	tilesCmd.Flags().IntVar(&maxZoom, "max-zoom", 9, "highest zoom level to render")
+	tilesCmd.Flags().SetAnnotation("output", cobra.BashCompSubdirsInDir, []string{})
+	cmd.AddCommand(tilesCmd)
+}
+
+// tile is the x, y position of a tile at zoom level z
+type tile struct {
+	z, x, y int
+}
+
+func runTiles(cmd *cobra.Command, args []string) {
+	if minZoom < 0 || maxZoom > 20 || minZoom > maxZoom {
+		logrus.Fatalf("invalid zoom levels %d-%d", minZoom, maxZoom)
+	}
+	radials, _, err := loadSweep(&container.Volume{Name: args[0]}, product)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	if radials == nil {
+		logrus.Fatalf("no %s data in %s", product, args[0])
+	}
+	sampler := grid.NewSampler(radials, momentFor(product, radials), renderRadius)
+	tileColors := colorsFor(product, radials)
+	sampler.Smooth = smooth
+	s, err := sink.Open(tilesOutput)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	var tiles []tile
+	lat, lon, _, ok := sites.Locate(radials[0])
+	if !ok {
+		logrus.Fatalf("%s: unknown radar location", args[0])
+	}
+	north, _ := geo.Destination(lat, lon, 0, renderRadius)
+	south, _ := geo.Destination(lat, lon, 180, renderRadius)
+	_, east := geo.Destination(lat, lon, 90, renderRadius)
+	west := lon - (east - lon)
+	for z := minZoom; z <= maxZoom; z++ {
+		x0, y0 := tileAt(north, west, z)
+		x1, y1 := tileAt(south, east, z)
+		for x := x0; x <= x1; x++ {
+			for y := y0; y <= y1; y++ {
+				tiles = append(tiles, tile{z, x, y})
+			}
+		}
+	}
+
+	fmt.Printf("Generating %d %s tiles from %s -> %s\n", len(tiles), strings.ToUpper(product), args[0], tilesOutput)
+	bar := pb.StartNew(len(tiles))
+	jobs := make(chan tile)
+	failed := 0
+	mtx := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	wg.Add(runners)
+	for i := 0; i < runners; i++ {
+		go func() {
+			defer wg.Done()
+			for t := range jobs {
+				if err := writeTile(s, t, sampler, tileColors); err != nil {
+					logrus.Errorf("tile %d/%d/%d: %s", t.z, t.x, t.y, err)
+					mtx.Lock()
+					failed++
+					mtx.Unlock()
+				}
+				bar.Increment()
+			}
+		}()
+	}
+	for _, t := range tiles {
+		jobs <- t
+	}
+	close(jobs)
+	wg.Wait()
+	bar.Finish()
+
+	if failed > 0 {
+		logrus.Errorf("%d of %d tiles failed", failed, len(tiles))
+		os.Exit(1)
+	}
+}
+
+// tileAt returns the tile containing the point at zoom level z
+func tileAt(lat, lon float64, z int) (int, int) {
+	n := float64(int(1) << uint(z))
+	x := (lon + 180) / 360 * n
+	y := (1 - math.Log(math.Tan(lat*math.Pi/180)+1/math.Cos(lat*math.Pi/180))/math.Pi) / 2 * n
+	clamp := func(v float64) int {
+		return int(math.Max(0, math.Min(n-1, math.Floor(v))))
+	}
+	return clamp(x), clamp(y)
+}
+
+// pixelCenter returns the coordinates of the center of pixel px, py of the tile
+func pixelCenter(t tile, px, py int) (lat, lon float64) {
+	n := float64(int(1)<<uint(t.z)) * tileSize
+	x := float64(t.x*tileSize+px) + 0.5
+	y := float64(t.y*tileSize+py) + 0.5
+	lon = x/n*360 - 180
+	lat = math.Atan(math.Sinh(math.Pi*(1-2*y/n))) * 180 / math.Pi
+	return lat, lon
+}
+
+// writeTile renders the tile in the color table, writing it to the sink only if it has data
+func writeTile(s sink.Sink, t tile, sampler *grid.Sampler, colorTable render.ColorTable) error {
+	img := image.NewRGBA(image.Rect(0, 0, tileSize, tileSize))
+	empty := true
+	for py := 0; py < tileSize; py++ {
+		for px := 0; px < tileSize; px++ {
+			v := sampler.At(pixelCenter(t, px, py))
+			if math.IsNaN(float64(v)) {
+				continue
+			}
+			img.Set(px, py, colorTable(v))
+			empty = false
+		}
+	}
+	if empty {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := encodePNG(&buf, img, nil); err != nil {
+		return err
+	}
+	return put(s, fmt.Sprintf("%d/%d/%d.png", t.z, t.x, t.y), buf.Bytes(), cacheControl)
+}
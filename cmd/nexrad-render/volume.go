@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math"
+	"path"
+	"strings"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/derived"
+	"github.com/kallsyms/go-nexrad/export/geotiff"
+	"github.com/kallsyms/go-nexrad/grid"
+	"github.com/kallsyms/go-nexrad/render"
+	"github.com/kallsyms/go-nexrad/sink"
+	"github.com/sirupsen/logrus"
+)
+
+// isVolumeProduct reports whether the product is computed from the whole
+// volume, one of render.VolumeProducts
+func isVolumeProduct(prod string) bool {
+	for _, p := range render.VolumeProducts {
+		if p == prod {
+			return true
+		}
+	}
+	return false
+}
+
+// volumeGrid computes the volume product over the --range-km around the
+// radar, --size cells across, with --min-value and --max-value applied
+func volumeGrid(ar2 *archive2.Archive2, prod string) *grid.Grid {
+	var g *grid.Grid
+	switch prod {
+	case "cref":
+		g = derived.CompositeReflectivity(ar2, renderRadius, int(imageSize))
+	case "et":
+		g = derived.EchoTops(ar2, derived.EchoTopThreshold, renderRadius, int(imageSize))
+	case "vil":
+		g = derived.VIL(ar2, renderRadius, int(imageSize))
+	}
+	// unset bounds are NaN, which no value is below or above
+	for i, v := range g.Values {
+		if float64(v) < minValue || float64(v) > maxValue {
+			g.Values[i] = float32(math.NaN())
+		}
+	}
+	return g
+}
+
+// renderVolume renders the volume product from the volume read from in to out
+func renderVolume(in, out string) {
+	if format == "svg" {
+		logrus.Fatalf("%s is gridded and can't be written as svg", product)
+	}
+	if !math.IsNaN(centerLat) || projection != "latlon" {
+		logrus.Fatalf("%s is gridded in lat/lon around the radar, --center and --projection aren't supported", product)
+	}
+	fmt.Printf("Generating %s from %s -> %s\n", strings.ToUpper(product), in, out)
+
+	ar2, err := load(in, 0)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	// the lowest tilt places overlays and labels the image
+	elv := (elevationChoice{}).elevationFor(ar2, "ref")
+	if elv == 0 {
+		logrus.Fatalf("no reflectivity in %s", in)
+	}
+	radials := ar2.ElevationScans[elv]
+	g := volumeGrid(ar2, product)
+
+	var buf bytes.Buffer
+	var world []byte
+	switch format {
+	case "geotiff":
+		err = geotiff.Write(&buf, g, geotiff.Options{COG: cog})
+	case "geojson":
+		err = writeContours(&buf, g, product)
+	case "png", "jpeg":
+		label := newLabelFields(ar2.VolumeHeader, radials, product)
+		label.File = path.Base(out)
+		var img image.Image
+		if img, err = renderGridPNG(g, radials, product, label); err == nil {
+			err = encodeImage(&buf, img, append(label.meta(false), gridGeoref(g)...))
+		}
+		world = g.WorldFile()
+	}
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	dir, name := sink.Split(out)
+	s, err := sink.Open(dir)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	if world != nil {
+		if err := put(s, strings.TrimSuffix(name, path.Ext(name))+worldFileExtensions[format], world, cacheControl); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+	if err := put(s, name, buf.Bytes(), cacheControl); err != nil {
+		logrus.Fatal(err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"path"
+	"sort"
+
+	"github.com/kallsyms/go-nexrad/derived"
+	"github.com/kallsyms/go-nexrad/render"
+	"github.com/kallsyms/go-nexrad/sink"
+	"github.com/sirupsen/logrus"
+)
+
+// vwpPlot is the --vwp-plot of -p vwp
+var vwpPlot string
+
+func init() {
+	renderCmd.Flags().StringVar(&vwpPlot, "vwp-plot", "barbs", "plot of the VAD wind profile of -p vwp. barbs, a time-height chart of the volumes' profiles, or hodograph, of the latest's")
+}
+
+// renderWindProfile renders the VAD wind profiles of the volumes, in time
+// order, as the --vwp-plot to out
+func renderWindProfile(ins []string, out string) {
+	if !isRaster(format) {
+		logrus.Fatalf("wind profiles can't be written as %s", format)
+	}
+	if vwpPlot != "barbs" && vwpPlot != "hodograph" {
+		logrus.Fatalf("unknown --vwp-plot %s, expected barbs or hodograph", vwpPlot)
+	}
+	fmt.Printf("Generating VWP %s from %d volumes -> %s\n", vwpPlot, len(ins), out)
+
+	var profiles []*derived.WindProfile
+	var label labelFields
+	for _, in := range ins {
+		ar2, err := load(in, 0)
+		if err != nil {
+			logrus.Fatalf("%s: %s", in, err)
+		}
+		p := derived.VAD(ar2)
+		if len(p.Levels) == 0 {
+			logrus.Warnf("%s: no winds in the volume's velocity", in)
+		}
+		profiles = append(profiles, p)
+		if elv := (elevationChoice{}).elevationFor(ar2, "vel"); elv != 0 && !p.Time.Before(label.Time) {
+			label = newLabelFields(ar2.VolumeHeader, ar2.ElevationScans[elv], "vwp")
+		}
+	}
+	sort.SliceStable(profiles, func(i, j int) bool { return profiles[i].Time.Before(profiles[j].Time) })
+	label.File = path.Base(out)
+
+	opts := render.Options{
+		Size:       int(imageSize),
+		ColorTable: colors,
+		Background: backgroundColor,
+		Legend:     legendFor(product, nil),
+	}
+	label.apply(&opts)
+	var img image.Image
+	var err error
+	if vwpPlot == "hodograph" {
+		img, err = render.Hodograph(profiles[len(profiles)-1], opts)
+	} else {
+		img, err = render.WindBarbs(profiles, opts)
+	}
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := encodeImage(&buf, img, label.meta(false)); err != nil {
+		logrus.Fatal(err)
+	}
+	dir, name := sink.Split(out)
+	s, err := sink.Open(dir)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	if err := put(s, name, buf.Bytes(), cacheControl); err != nil {
+		logrus.Fatal(err)
+	}
+}
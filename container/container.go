@@ -0,0 +1,198 @@
+// Package container reads archive 2 volumes from tar and zip containers, such
+// as the daily tarballs some mirrors distribute, as well as plain and gzipped
+// volume files, so tools can process them without extracting them first.
+package container
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/internal/objio"
+)
+
+// Volume is an archive 2 volume in a file or container
+type Volume struct {
+	// Name of the volume. For volumes in containers, this is the path of the
+	// container followed by the name of the member, e.g.
+	// KTLX20200101.tar/KTLX20200101_000000_V06.
+	Name string
+	// Container is the path of the container holding the volume, or empty for
+	// plain files
+	Container string
+	// Member is the name of the volume within its container
+	Member string
+
+	data []byte
+}
+
+// Open returns a reader for the (decompressed) volume
+func (v *Volume) Open() (io.ReadCloser, error) {
+	var r io.ReadCloser
+	if v.Container == "" {
+		f, err := os.Open(v.Name)
+		if err != nil {
+			return nil, err
+		}
+		r = f
+	} else {
+		r = ioutil.NopCloser(bytes.NewReader(v.data))
+	}
+	if !strings.HasSuffix(v.Name, ".gz") {
+		return r, nil
+	}
+	return objio.Gunzip(r)
+}
+
+// Load decodes the volume
+func (v *Volume) Load() (*archive2.Archive2, error) {
+	r, err := v.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return archive2.Extract(r)
+}
+
+// IsContainer reports whether the path names a supported container: .tar,
+// .tar.gz, .tgz or .zip
+func IsContainer(p string) bool {
+	return containerType(p) != ""
+}
+
+func containerType(p string) string {
+	switch lower := strings.ToLower(p); {
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar"
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tgz"
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip"
+	}
+	return ""
+}
+
+// TrimExt returns the path without its container extension
+func TrimExt(p string) string {
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar", ".zip"} {
+		if strings.HasSuffix(strings.ToLower(p), ext) {
+			return p[:len(p)-len(ext)]
+		}
+	}
+	return p
+}
+
+// Walk calls fn for each volume in the file at p. A plain file is a single
+// volume; for containers, fn is called with each member whose base name
+// satisfies match, in the order they're stored. Members are read into memory
+// one at a time, so containers can be streamed, and the Volume passed to fn
+// remains readable after fn returns. Walk stops at the first error from fn.
+func Walk(p string, match func(name string) bool, fn func(v *Volume) error) error {
+	switch containerType(p) {
+	case "tar", "tgz":
+		return walkTar(p, match, fn)
+	case "zip":
+		return walkZip(p, match, fn)
+	}
+	return fn(&Volume{Name: p})
+}
+
+func walkTar(p string, match func(string) bool, fn func(*Volume) error) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if containerType(p) == "tgz" {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if h.Typeflag != tar.TypeReg || !match(path.Base(h.Name)) {
+			continue
+		}
+		if err := checkMember(p, h.Name); err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if err := fn(member(p, h.Name, data)); err != nil {
+			return err
+		}
+	}
+}
+
+func walkZip(p string, match func(string) bool, fn func(*Volume) error) error {
+	zr, err := zip.OpenReader(p)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !match(path.Base(f.Name)) {
+			continue
+		}
+		if err := checkMember(p, f.Name); err != nil {
+			return err
+		}
+		r, err := f.Open()
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		if err := fn(member(p, f.Name, data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkMember rejects member names that are absolute or climb out of the
+// container with "..", so volumes can't be named (and their outputs written)
+// outside where the container is
+func checkMember(container, name string) error {
+	clean := path.Clean(name)
+	if path.IsAbs(clean) || filepath.IsAbs(name) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("container: %s: unsafe member name %q", container, name)
+	}
+	return nil
+}
+
+func member(container, name string, data []byte) *Volume {
+	return &Volume{
+		Name:      filepath.Join(container, filepath.FromSlash(name)),
+		Container: container,
+		Member:    name,
+		data:      data,
+	}
+}
@@ -0,0 +1,201 @@
+package container
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var members = []struct {
+	name string
+	data string
+}{
+	{"KTST20200101_000000_V06", "first"},
+	{"KTST20200101_000000_V06_MDM", "metadata"},
+	{"sub/KTST20200101_000500_V06.gz", "second"},
+}
+
+func isVolume(name string) bool {
+	return strings.HasSuffix(strings.TrimSuffix(name, ".gz"), "_V06")
+}
+
+// content returns the stored content of the member, gzipping .gz members
+func content(name, data string) []byte {
+	if !strings.HasSuffix(name, ".gz") {
+		return []byte(data)
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(data))
+	gz.Close()
+	return buf.Bytes()
+}
+
+func writeTar(t *testing.T, path string, gzipped bool) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := &bytes.Buffer{}
+	tw := tar.NewWriter(w)
+	tw.WriteHeader(&tar.Header{Name: "sub/", Typeflag: tar.TypeDir, Mode: 0755})
+	for _, m := range members {
+		data := content(m.name, m.data)
+		tw.WriteHeader(&tar.Header{Name: m.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(data))})
+		tw.Write(data)
+	}
+	tw.Close()
+	if gzipped {
+		gz := gzip.NewWriter(f)
+		gz.Write(w.Bytes())
+		gz.Close()
+	} else {
+		f.Write(w.Bytes())
+	}
+}
+
+func writeZip(t *testing.T, path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for _, m := range members {
+		w, _ := zw.Create(m.name)
+		w.Write(content(m.name, m.data))
+	}
+	zw.Close()
+}
+
+func TestWalk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "container")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"vols.tar", "vols.tar.gz", "vols.tgz", "vols.zip"} {
+		path := filepath.Join(dir, name)
+		if strings.HasSuffix(name, ".zip") {
+			writeZip(t, path)
+		} else {
+			writeTar(t, path, !strings.HasSuffix(name, ".tar"))
+		}
+		if !IsContainer(path) {
+			t.Errorf("%s: not a container", name)
+		}
+
+		var got []*Volume
+		err := Walk(path, isVolume, func(v *Volume) error {
+			got = append(got, v)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		want := []string{"first", "second"}
+		var contents []string
+		for _, v := range got {
+			if v.Container != path {
+				t.Errorf("%s: container %s", name, v.Container)
+			}
+			r, err := v.Open()
+			if err != nil {
+				t.Fatalf("%s: %s", v.Name, err)
+			}
+			data, _ := ioutil.ReadAll(r)
+			r.Close()
+			contents = append(contents, string(data))
+		}
+		if !reflect.DeepEqual(contents, want) {
+			t.Errorf("%s: got %q, want %q", name, contents, want)
+		}
+		if want := filepath.Join(path, "sub", "KTST20200101_000500_V06.gz"); len(got) == 2 && got[1].Name != want {
+			t.Errorf("%s: got name %s, want %s", name, got[1].Name, want)
+		}
+	}
+}
+
+func TestWalkUnsafeMember(t *testing.T) {
+	dir, err := ioutil.TempDir("", "container")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"../evil_V06", "sub/../../evil_V06", "/tmp/evil_V06"} {
+		p := filepath.Join(dir, "vols.tar")
+		w := &bytes.Buffer{}
+		tw := tar.NewWriter(w)
+		tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: 4})
+		tw.Write([]byte("evil"))
+		tw.Close()
+		if err := ioutil.WriteFile(p, w.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		err := Walk(p, isVolume, func(v *Volume) error {
+			t.Errorf("%s: walked to %s", name, v.Name)
+			return nil
+		})
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestWalkFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "container")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "KTST20200101_000000_V06.gz")
+	if err := ioutil.WriteFile(path, content(path, "volume"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if IsContainer(path) {
+		t.Errorf("%s is a container", path)
+	}
+	var got []string
+	err = Walk(path, isVolume, func(v *Volume) error {
+		r, err := v.Open()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		data, err := ioutil.ReadAll(r)
+		got = append(got, string(data))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"volume"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestTrimExt(t *testing.T) {
+	for in, want := range map[string]string{
+		"a/KTST20200101.tar":      "a/KTST20200101",
+		"KTST20200101.TAR.GZ":     "KTST20200101",
+		"KTST20200101.tgz":        "KTST20200101",
+		"KTST20200101.zip":        "KTST20200101",
+		"KTST20200101_000000_V06": "KTST20200101_000000_V06",
+	} {
+		if got := TrimExt(in); got != want {
+			t.Errorf("TrimExt(%s) = %s, want %s", in, got, want)
+		}
+	}
+}
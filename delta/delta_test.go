@@ -0,0 +1,191 @@
+package delta
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+func testRadial(elv, az int) *archive2.Message31 {
+	m31 := &archive2.Message31{}
+	m31.Header.ElevationNumber = uint8(elv)
+	m31.Header.AzimuthNumber = uint16(az)
+	m31.Header.AzimuthAngle = float32(az) / 2
+	m31.Header.ElevationAngle = 0.5
+	m31.Header.CollectionDate = 17404
+	m31.Header.CollectionTime = 86253000
+	m31.ReflectivityData = &archive2.DataMoment{
+		GenericDataMoment: archive2.GenericDataMoment{
+			DataBlock:                     archive2.DataBlock{DataName: [3]byte{'R', 'E', 'F'}},
+			NumberDataMomentGates:         4,
+			DataMomentRange:               2125,
+			DataMomentRangeSampleInterval: 250,
+			DataWordSize:                  8,
+			Scale:                         2,
+			Offset:                        66,
+		},
+		Data: []byte{0, 1, 100, byte(az)},
+	}
+	m31.VelocityData = &archive2.DataMoment{
+		GenericDataMoment: archive2.GenericDataMoment{DataWordSize: 8, NumberDataMomentGates: 1},
+		Data:              []byte{129},
+	}
+	return m31
+}
+
+func seqs(d *Delta) []uint64 {
+	var s []uint64
+	for _, r := range d.Radials {
+		s = append(s, r.Seq)
+	}
+	return s
+}
+
+func TestFeed(t *testing.T) {
+	f := NewFeed()
+	ktlx := archive2.VolumeKey{ICAO: "KTLX", Time: time.Date(2017, 8, 25, 23, 57, 33, 0, time.UTC)}
+	f.NewVolume(ktlx)
+	if n := f.Add(testRadial(1, 1), testRadial(1, 2)); n != 2 {
+		t.Fatalf("added %d radials", n)
+	}
+
+	d := f.Since(0)
+	if !d.Reset || len(d.Radials) != 2 || d.Volume != ktlx {
+		t.Fatalf("first delta: reset %v, %d radials, volume %v", d.Reset, len(d.Radials), d.Volume)
+	}
+	cursor := d.Cursor
+
+	// duplicates are dropped
+	if n := f.Add(testRadial(1, 2), testRadial(1, 3)); n != 1 {
+		t.Errorf("added %d radials, expected the duplicate to be dropped", n)
+	}
+	d = f.Since(cursor)
+	if d.Reset || len(d.Radials) != 1 || d.Radials[0].Header.AzimuthNumber != 3 || d.Radials[0].Seq != d.Cursor {
+		t.Fatalf("delta after cursor %d: reset %v, radials %v", cursor, d.Reset, seqs(d))
+	}
+	cursor = d.Cursor
+	if d = f.Since(cursor); len(d.Radials) != 0 || d.Reset {
+		t.Errorf("up to date client got %v", seqs(d))
+	}
+
+	// a new volume resets clients, once
+	f.NewVolume(archive2.VolumeKey{ICAO: "KTLX"})
+	d = f.Since(cursor)
+	if !d.Reset || len(d.Radials) != 0 {
+		t.Fatalf("new volume: reset %v, radials %v", d.Reset, seqs(d))
+	}
+	cursor = d.Cursor
+	f.Add(testRadial(1, 1))
+	d = f.Since(cursor)
+	if d.Reset || len(d.Radials) != 1 {
+		t.Errorf("after reset: reset %v, radials %v", d.Reset, seqs(d))
+	}
+
+	// cursors from the future, e.g. before a restart, are reset
+	if d = f.Since(1000); !d.Reset || len(d.Radials) != 1 {
+		t.Errorf("unknown cursor: reset %v, radials %v", d.Reset, seqs(d))
+	}
+}
+
+func TestBinary(t *testing.T) {
+	f := NewFeed()
+	f.NewVolume(archive2.VolumeKey{ICAO: "KTLX", Time: time.Date(2017, 8, 25, 23, 57, 33, 0, time.UTC)})
+	end := testRadial(2, 7)
+	end.Header.RadialStatus = archive2.RadialStatusEndOfElevation
+	f.Add(testRadial(1, 1), end)
+	d := f.Since(0)
+
+	buf := &bytes.Buffer{}
+	if err := WriteBinary(buf, d, []string{"REF"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadBinary(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Volume != d.Volume || got.Cursor != d.Cursor || got.Reset != d.Reset || len(got.Radials) != 2 {
+		t.Fatalf("got %+v, want %+v", got, d)
+	}
+	r := got.Radials[1]
+	want := d.Radials[1]
+	if r.Seq != want.Seq || r.Header.ElevationNumber != 2 || r.Header.AzimuthNumber != 7 || r.Header.AzimuthAngle != 3.5 || !r.Header.EndsElevation() {
+		t.Errorf("radial header %+v", r.Header)
+	}
+	if !r.Header.Date().Equal(want.Header.Date()) {
+		t.Errorf("radial time %s, want %s", r.Header.Date(), want.Header.Date())
+	}
+	if r.VelocityData != nil {
+		t.Error("filtered moment sent")
+	}
+	if ref := r.ReflectivityData; ref == nil || !bytes.Equal(ref.Data, want.ReflectivityData.Data) || ref.Scale != 2 || ref.DataMomentRange != 2125 {
+		t.Errorf("reflectivity %+v", ref)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	f := NewFeed()
+	f.NewVolume(archive2.VolumeKey{ICAO: "KTLX"})
+	f.Add(testRadial(1, 1))
+	srv := httptest.NewServer(Handler(f))
+	defer srv.Close()
+
+	var d struct {
+		Cursor  uint64
+		Reset   bool
+		Radials []struct {
+			Seq     uint64
+			Moments map[string]json.RawMessage
+		}
+	}
+	resp, err := http.Get(srv.URL + "?moments=ref")
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.NewDecoder(resp.Body).Decode(&d)
+	resp.Body.Close()
+	if !d.Reset || len(d.Radials) != 1 || len(d.Radials[0].Moments) != 1 || d.Radials[0].Moments["REF"] == nil {
+		t.Fatalf("first poll %+v", d)
+	}
+
+	// long polls return once radials arrive
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		f.Add(testRadial(1, 2))
+	}()
+	resp, err = http.Get(srv.URL + "?wait=10s&cursor=" + strconv.FormatUint(d.Cursor, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.NewDecoder(resp.Body).Decode(&d)
+	resp.Body.Close()
+	if d.Reset || len(d.Radials) != 1 || d.Radials[0].Seq != d.Cursor {
+		t.Fatalf("long poll %+v", d)
+	}
+
+	// event streams resume from Last-Event-ID
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Last-Event-ID", strconv.FormatUint(d.Cursor-1, 10))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, 1<<20)
+	var event []string
+	for scanner.Scan() && scanner.Text() != "" {
+		event = append(event, scanner.Text())
+	}
+	if len(event) != 3 || event[0] != "id: "+strconv.FormatUint(d.Cursor, 10) || event[1] != "event: delta" || !strings.HasPrefix(event[2], "data: {") {
+		t.Fatalf("event %q", event)
+	}
+}
@@ -0,0 +1,292 @@
+package delta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+// Deltas can be encoded as JSON, for browsers, or in a compact binary format.
+// Both carry the moments' raw gate values, which clients scale with
+// F = (N - offset) / scale, so they're about the size of the archive data.
+//
+// The binary format is big endian:
+//
+//	magic "NXDS", version (u8), flags (u8, bit 0 = reset), cursor (u64),
+//	ICAO ([4]byte), volume time (i64 unix ms), radial count (u32)
+//
+// followed by each radial:
+//
+//	seq (u64), elevation number (u8), azimuth number (u16), radial status
+//	(u8, see archive2.RadialStatus*), azimuth (f32), elevation (f32),
+//	time (i64 unix ms), moment count (u8)
+//
+// followed by each of its moments:
+//
+//	name ([3]byte), word size (u8), gates (u16), first gate (u16 m),
+//	gate spacing (u16 m), scale (f32), offset (f32), gates * word size / 8 bytes
+
+const (
+	binaryMagic   = "NXDS"
+	binaryVersion = 2
+	flagReset     = 1
+)
+
+type jsonDelta struct {
+	ICAO       string       `json:"icao"`
+	VolumeTime time.Time    `json:"volume_time"`
+	Cursor     uint64       `json:"cursor"`
+	Reset      bool         `json:"reset"`
+	Radials    []jsonRadial `json:"radials"`
+}
+
+type jsonRadial struct {
+	Seq             uint64                 `json:"seq"`
+	ElevationNumber int                    `json:"elevation_number"`
+	AzimuthNumber   int                    `json:"azimuth_number"`
+	RadialStatus    int                    `json:"radial_status"`
+	Azimuth         float32                `json:"azimuth"`
+	Elevation       float32                `json:"elevation"`
+	Time            time.Time              `json:"time"`
+	Moments         map[string]*jsonMoment `json:"moments"`
+}
+
+type jsonMoment struct {
+	FirstGate   int     `json:"first_gate"`
+	GateSpacing int     `json:"gate_spacing"`
+	Scale       float32 `json:"scale"`
+	Offset      float32 `json:"offset"`
+	WordSize    int     `json:"word_size"`
+	// Data is base64 encoded by encoding/json
+	Data []byte `json:"data"`
+}
+
+// moments returns the moments of the radial to send, in MomentNames order. A
+// nil filter sends all of them.
+func moments(m31 *archive2.Message31, filter []string) ([]string, []*archive2.DataMoment) {
+	var names []string
+	var data []*archive2.DataMoment
+	for _, name := range archive2.MomentNames {
+		if filter != nil && !contains(filter, name) {
+			continue
+		}
+		if d := m31.Moment(name); d != nil {
+			names = append(names, name)
+			data = append(data, d)
+		}
+	}
+	return names, data
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteJSON writes the delta as JSON, including only the moments named in
+// filter (all of them if filter is nil)
+func WriteJSON(w io.Writer, d *Delta, filter []string) error {
+	return json.NewEncoder(w).Encode(toJSON(d, filter))
+}
+
+func toJSON(d *Delta, filter []string) *jsonDelta {
+	jd := &jsonDelta{
+		ICAO:       d.Volume.ICAO,
+		VolumeTime: d.Volume.Time,
+		Cursor:     d.Cursor,
+		Reset:      d.Reset,
+		Radials:    []jsonRadial{},
+	}
+	for _, r := range d.Radials {
+		jr := jsonRadial{
+			Seq:             r.Seq,
+			ElevationNumber: int(r.Header.ElevationNumber),
+			AzimuthNumber:   int(r.Header.AzimuthNumber),
+			RadialStatus:    int(r.Header.RadialStatus),
+			Azimuth:         r.Header.AzimuthAngle,
+			Elevation:       r.Header.ElevationAngle,
+			Time:            r.Header.Date(),
+			Moments:         map[string]*jsonMoment{},
+		}
+		names, data := moments(r.Message31, filter)
+		for i, m := range data {
+			jr.Moments[names[i]] = &jsonMoment{
+				FirstGate:   int(m.DataMomentRange),
+				GateSpacing: int(m.DataMomentRangeSampleInterval),
+				Scale:       m.Scale,
+				Offset:      m.Offset,
+				WordSize:    int(m.DataWordSize),
+				Data:        m.Data,
+			}
+		}
+		jd.Radials = append(jd.Radials, jr)
+	}
+	return jd
+}
+
+// WriteBinary writes the delta in the binary format, including only the
+// moments named in filter (all of them if filter is nil)
+func WriteBinary(w io.Writer, d *Delta, filter []string) error {
+	buf := &bytes.Buffer{}
+	buf.WriteString(binaryMagic)
+	flags := uint8(0)
+	if d.Reset {
+		flags |= flagReset
+	}
+	var icao [4]byte
+	copy(icao[:], d.Volume.ICAO)
+	binary.Write(buf, binary.BigEndian, binaryHeader{binaryVersion, flags, d.Cursor, icao, unixMillis(d.Volume.Time), uint32(len(d.Radials))})
+
+	for _, r := range d.Radials {
+		names, data := moments(r.Message31, filter)
+		binary.Write(buf, binary.BigEndian, binaryRadial{
+			Seq:             r.Seq,
+			ElevationNumber: r.Header.ElevationNumber,
+			AzimuthNumber:   r.Header.AzimuthNumber,
+			RadialStatus:    r.Header.RadialStatus,
+			Azimuth:         r.Header.AzimuthAngle,
+			Elevation:       r.Header.ElevationAngle,
+			Time:            unixMillis(r.Header.Date()),
+			Moments:         uint8(len(data)),
+		})
+		for i, m := range data {
+			var name [3]byte
+			copy(name[:], names[i])
+			binary.Write(buf, binary.BigEndian, binaryMoment{
+				Name:        name,
+				WordSize:    m.DataWordSize,
+				Gates:       m.NumberDataMomentGates,
+				FirstGate:   m.DataMomentRange,
+				GateSpacing: m.DataMomentRangeSampleInterval,
+				Scale:       m.Scale,
+				Offset:      m.Offset,
+			})
+			buf.Write(m.Data)
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+type binaryHeader struct {
+	Version uint8
+	Flags   uint8
+	Cursor  uint64
+	ICAO    [4]byte
+	Time    int64
+	Count   uint32
+}
+
+type binaryRadial struct {
+	Seq             uint64
+	ElevationNumber uint8
+	AzimuthNumber   uint16
+	RadialStatus    uint8
+	Azimuth         float32
+	Elevation       float32
+	Time            int64
+	Moments         uint8
+}
+
+type binaryMoment struct {
+	Name        [3]byte
+	WordSize    uint8
+	Gates       uint16
+	FirstGate   uint16
+	GateSpacing uint16
+	Scale       float32
+	Offset      float32
+}
+
+// ReadBinary decodes a delta written by WriteBinary. Radials only have the
+// header fields and moments that are sent filled in.
+func ReadBinary(r io.Reader) (*Delta, error) {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != binaryMagic {
+		return nil, errors.New("delta: not a binary delta")
+	}
+	var h binaryHeader
+	if err := binary.Read(r, binary.BigEndian, &h); err != nil {
+		return nil, err
+	}
+	if h.Version != binaryVersion {
+		return nil, fmt.Errorf("delta: unsupported version %d", h.Version)
+	}
+	d := &Delta{
+		Volume: archive2.VolumeKey{ICAO: string(bytes.TrimRight(h.ICAO[:], "\x00")), Time: fromUnixMillis(h.Time)},
+		Cursor: h.Cursor,
+		Reset:  h.Flags&flagReset != 0,
+	}
+	for i := uint32(0); i < h.Count; i++ {
+		var br binaryRadial
+		if err := binary.Read(r, binary.BigEndian, &br); err != nil {
+			return nil, err
+		}
+		m31 := &archive2.Message31{}
+		m31.Header.ElevationNumber = br.ElevationNumber
+		m31.Header.AzimuthNumber = br.AzimuthNumber
+		m31.Header.RadialStatus = br.RadialStatus
+		m31.Header.AzimuthAngle = br.Azimuth
+		m31.Header.ElevationAngle = br.Elevation
+		setDate(&m31.Header, fromUnixMillis(br.Time))
+		for j := uint8(0); j < br.Moments; j++ {
+			var bm binaryMoment
+			if err := binary.Read(r, binary.BigEndian, &bm); err != nil {
+				return nil, err
+			}
+			m := &archive2.DataMoment{}
+			m.DataBlock = archive2.DataBlock{DataBlockType: [1]byte{'D'}, DataName: bm.Name}
+			m.DataWordSize = bm.WordSize
+			m.NumberDataMomentGates = bm.Gates
+			m.DataMomentRange = bm.FirstGate
+			m.DataMomentRangeSampleInterval = bm.GateSpacing
+			m.Scale = bm.Scale
+			m.Offset = bm.Offset
+			m.Data = make([]byte, int(bm.Gates)*int(bm.WordSize)/8)
+			if _, err := io.ReadFull(r, m.Data); err != nil {
+				return nil, err
+			}
+			m31.SetMoment(string(bytes.TrimRight(bm.Name[:], " \x00")), m)
+		}
+		d.Radials = append(d.Radials, Radial{Seq: br.Seq, Message31: m31})
+	}
+	return d, nil
+}
+
+func unixMillis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func fromUnixMillis(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)).UTC()
+}
+
+// setDate sets the modified julian date and time of the header to t
+func setDate(h *archive2.Message31Header, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	days := t.Sub(time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)) / (24 * time.Hour)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	h.CollectionDate = uint16(days + 1)
+	h.CollectionTime = uint32(t.Sub(midnight) / time.Millisecond)
+}
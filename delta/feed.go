@@ -0,0 +1,129 @@
+// Package delta streams a volume to realtime clients as it arrives, sending
+// only the radials added since the client's last update instead of the whole
+// sweep.
+//
+// Radials are numbered with a sequence that keeps increasing across volumes.
+// Clients pass the sequence of the last radial they have as a cursor and
+// receive a Delta holding the radials after it, along with the cursor to pass
+// next time. A cursor from an earlier volume (or from a server that has since
+// restarted) gets the whole current volume, marked as a reset.
+package delta
+
+import (
+	"sync"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+// Radial is a radial and its sequence number
+type Radial struct {
+	Seq uint64
+	*archive2.Message31
+}
+
+// Delta holds the radials of a volume after a cursor
+type Delta struct {
+	Volume archive2.VolumeKey
+	// Cursor is the sequence of the last radial in the volume, to request the
+	// next delta with
+	Cursor uint64
+	// Reset is set when the delta holds the whole volume, rather than the
+	// radials following the client's cursor, and the client should drop the
+	// radials it has
+	Reset   bool
+	Radials []Radial
+}
+
+// Feed collects the radials of the current volume in the order they arrive
+type Feed struct {
+	mtx     sync.Mutex
+	volume  archive2.VolumeKey
+	radials []Radial
+	// first is the sequence of the first radial of the current volume
+	first uint64
+	// next is the sequence of the next radial added
+	next uint64
+	seen map[int]map[uint16]bool
+	// changed is closed and replaced whenever radials are added or a volume
+	// starts, to wake up waiting clients
+	changed chan struct{}
+}
+
+// NewFeed returns an empty feed
+func NewFeed() *Feed {
+	return &Feed{
+		first:   1,
+		next:    1,
+		seen:    map[int]map[uint16]bool{},
+		changed: make(chan struct{}),
+	}
+}
+
+func (f *Feed) notify() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// NewVolume drops the radials of the previous volume and starts collecting the
+// given volume
+func (f *Feed) NewVolume(key archive2.VolumeKey) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.volume = key
+	f.radials = nil
+	// the sequence before the volume's first radial marks the start of the
+	// volume, so clients reset to it aren't reset again before radials arrive
+	f.next++
+	f.first = f.next
+	f.seen = map[int]map[uint16]bool{}
+	f.notify()
+}
+
+// Add appends the radials to the volume, returning the number added. Radials
+// already in the volume (same elevation and azimuth number) are ignored, so
+// whole LDM records can be added as they're received.
+func (f *Feed) Add(radials ...*archive2.Message31) int {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	added := 0
+	for _, m31 := range radials {
+		elv := int(m31.Header.ElevationNumber)
+		if f.seen[elv] == nil {
+			f.seen[elv] = map[uint16]bool{}
+		}
+		if f.seen[elv][m31.Header.AzimuthNumber] {
+			continue
+		}
+		f.seen[elv][m31.Header.AzimuthNumber] = true
+		f.radials = append(f.radials, Radial{Seq: f.next, Message31: m31})
+		f.next++
+		added++
+	}
+	if added > 0 {
+		f.notify()
+	}
+	return added
+}
+
+// Since returns the radials added after cursor, the sequence of the last radial
+// the client has. Clients without any radials pass 0 and get the whole volume.
+func (f *Feed) Since(cursor uint64) *Delta {
+	d, _ := f.since(cursor)
+	return d
+}
+
+// since also returns the channel closed on the next change, for waiting on
+// updates after the delta
+func (f *Feed) since(cursor uint64) (*Delta, <-chan struct{}) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	d := &Delta{Volume: f.volume, Cursor: f.next - 1}
+	if cursor+1 < f.first || cursor >= f.next {
+		// from an earlier volume, or not from this feed at all
+		d.Reset = true
+		cursor = f.first - 1
+	}
+	// radials are numbered consecutively from first
+	d.Radials = append([]Radial(nil), f.radials[cursor+1-f.first:]...)
+	return d, f.changed
+}
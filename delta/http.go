@@ -0,0 +1,131 @@
+package delta
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxWait bounds how long a long polling request waits for new radials
+const MaxWait = time.Minute
+
+// keepAlive is how often an idle event stream is sent a comment, so proxies
+// don't close it
+const keepAlive = 30 * time.Second
+
+// Handler serves deltas from the feed. Clients either poll, or hold open an
+// event stream.
+//
+// A GET returns the delta after the cursor query parameter, as JSON or, with
+// format=binary, in the binary format. With wait (a duration, e.g. 30s, up to
+// MaxWait), the request is held until there are new radials. moments limits
+// the moments sent, e.g. moments=REF,VEL.
+//
+// Requests accepting text/event-stream are sent each delta as it arrives, as a
+// JSON "delta" event with the cursor as its id, so reconnecting clients resume
+// from the Last-Event-ID header.
+func Handler(f *Feed) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		cursor := uint64(0)
+		if c := q.Get("cursor"); c != "" {
+			var err error
+			if cursor, err = strconv.ParseUint(c, 10, 64); err != nil {
+				http.Error(w, fmt.Sprintf("invalid cursor: %s", err), http.StatusBadRequest)
+				return
+			}
+		}
+		var filter []string
+		if m := q.Get("moments"); m != "" {
+			filter = strings.Split(strings.ToUpper(m), ",")
+		}
+
+		if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			if id := r.Header.Get("Last-Event-ID"); id != "" {
+				cursor, _ = strconv.ParseUint(id, 10, 64)
+			}
+			stream(w, r, f, cursor, filter)
+			return
+		}
+
+		var wait time.Duration
+		if v := q.Get("wait"); v != "" {
+			var err error
+			if wait, err = time.ParseDuration(v); err != nil {
+				http.Error(w, fmt.Sprintf("invalid wait: %s", err), http.StatusBadRequest)
+				return
+			}
+			if wait > MaxWait {
+				wait = MaxWait
+			}
+		}
+
+		d, changed := f.since(cursor)
+		if len(d.Radials) == 0 && !d.Reset && wait > 0 {
+			timeout := time.NewTimer(wait)
+			defer timeout.Stop()
+			select {
+			case <-changed:
+				d, _ = f.since(cursor)
+			case <-timeout.C:
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		if q.Get("format") == "binary" {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			WriteBinary(w, d, filter)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		WriteJSON(w, d, filter)
+	})
+}
+
+// stream sends deltas as server-sent events until the client goes away
+func stream(w http.ResponseWriter, r *http.Request, f *Feed, cursor uint64, filter []string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		d, changed := f.since(cursor)
+		if len(d.Radials) > 0 || d.Reset {
+			buf := &bytes.Buffer{}
+			WriteJSON(buf, d, filter)
+			// the encoder ends with a newline, which ends the data line
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: delta\ndata: %s\n", d.Cursor, buf.Bytes()); err != nil {
+				return
+			}
+			flusher.Flush()
+			cursor = d.Cursor
+		}
+
+		for waiting := true; waiting; {
+			select {
+			case <-changed:
+				waiting = false
+			case <-ticker.C:
+				if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Package derived computes products derived from the base moments of a sweep,
+// such as velocity divergence, azimuthal shear and specific differential phase.
+//
+// Derived products are returned as synthetic archive 2 data moments on the
+// range axis of the moment they were computed from, so they can be rendered,
+// gridded and exported the same way as the base moments. Products computed from
+// the whole volume, such as composite reflectivity, echo tops and VIL, are
+// returned as grids.
+package derived
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+// Product holds a derived moment for each radial of a sweep
+type Product map[*archive2.Message31]*archive2.DataMoment
+
+// Moment returns the derived moment for the radial, or nil if there is none.
+// It can be used as a grid.MomentFunc.
+func (p Product) Moment(m *archive2.Message31) *archive2.DataMoment {
+	return p[m]
+}
+
+const (
+	// derived moments are stored as 16 bit words, F = (N - offset) / scale
+	derivedOffset = 32768
+	// gradientScale is the scale of the velocity gradients, in s^-1, which
+	// are always small
+	gradientScale = 1e5
+	// kdpScale is the scale of KDP, in deg/km
+	kdpScale = 1e3
+)
+
+// newMoment encodes values as a moment with the name and range axis of geometry.
+// NaN values are stored as below threshold.
+func newMoment(name string, geometry *archive2.DataMoment, values []float64, scale float64) *archive2.DataMoment {
+	d := &archive2.DataMoment{GenericDataMoment: geometry.GenericDataMoment}
+	d.DataBlock.DataBlockType = [1]byte{'D'}
+	copy(d.DataBlock.DataName[:], name)
+	d.NumberDataMomentGates = uint16(len(values))
+	d.DataWordSize = 16
+	d.Scale = float32(scale)
+	d.Offset = derivedOffset
+	d.Data = make([]byte, len(values)*2)
+	for i, v := range values {
+		n := uint16(0)
+		if !math.IsNaN(v) {
+			// keep clear of the below threshold and range folded values
+			n = uint16(math.Max(2, math.Min(math.MaxUint16, math.Round(v*scale+derivedOffset))))
+		}
+		binary.BigEndian.PutUint16(d.Data[i*2:], n)
+	}
+	return d
+}
+
+// gateValues returns the scaled gates of the moment, with NaN in place of
+// below threshold and range folded gates.
+func gateValues(d *archive2.DataMoment) []float64 {
+	scaled := d.ScaledData()
+	values := make([]float64, len(scaled))
+	for i, v := range scaled {
+		if v == archive2.MomentDataBelowThreshold || v == archive2.MomentDataFolded {
+			values[i] = math.NaN()
+		} else {
+			values[i] = float64(v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,36 @@
+package derived
+
+import (
+	"math"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+// KDP computes specific differential phase, half the gradient of differential
+// phase (PHI) along each beam, in deg/km. Heavy rain and melting hail raise
+// it while it's unaffected by attenuation or calibration, so it picks out
+// rain cores reflectivity underestimates.
+//
+// As with Divergence, the gradient at each gate is the least squares slope of
+// the differential phase within baseline meters centered on it. PHI isn't
+// unfolded where it wraps around 360 degrees, which only happens in long
+// paths of heavy rain.
+func KDP(radials []*archive2.Message31, baseline float64) Product {
+	p := Product{}
+	for _, r := range radials {
+		phi := r.PhiData
+		if phi == nil || phi.DataMomentRangeSampleInterval == 0 {
+			continue
+		}
+		interval := float64(phi.DataMomentRangeSampleInterval)
+		half := int(math.Max(1, math.Round(baseline/interval/2)))
+		v := gateValues(phi)
+		kdp := make([]float64, len(v))
+		for i := range v {
+			// one way, per km
+			kdp[i] = slope(v, i-half, i+half) / interval * 1000 / 2
+		}
+		p[r] = newMoment("KDP", phi, kdp, kdpScale)
+	}
+	return p
+}
@@ -0,0 +1,42 @@
+package derived
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+func TestKDP(t *testing.T) {
+	// differential phase rising 4 deg/km, so KDP is 2 deg/km
+	m31 := &archive2.Message31{}
+	data := make([]byte, 120)
+	for g := 0; g < 60; g++ {
+		phi := 20 + float64(250+g*250)/1000*4
+		binary.BigEndian.PutUint16(data[g*2:], uint16(phi*100+2))
+	}
+	m31.PhiData = &archive2.DataMoment{
+		GenericDataMoment: archive2.GenericDataMoment{
+			NumberDataMomentGates:         60,
+			DataMomentRange:               250,
+			DataMomentRangeSampleInterval: 250,
+			DataWordSize:                  16,
+			Scale:                         100,
+			Offset:                        2,
+		},
+		Data: data,
+	}
+
+	p := KDP([]*archive2.Message31{m31, {}}, 2000)
+	if len(p) != 1 {
+		t.Fatalf("expected 1 radial with PHI, got %d", len(p))
+	}
+	kdp := p.Moment(m31).ScaledData()
+	if len(kdp) != 60 {
+		t.Fatalf("expected 60 gates, got %d", len(kdp))
+	}
+	if math.Abs(float64(kdp[30])-2) > 1e-2 {
+		t.Errorf("expected KDP of 2 deg/km, got %v", kdp[30])
+	}
+}
@@ -0,0 +1,223 @@
+package derived
+
+import (
+	"math"
+	"sort"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/geo"
+	"github.com/kallsyms/go-nexrad/grid"
+	"github.com/kallsyms/go-nexrad/sites"
+)
+
+// EchoTopThreshold is the reflectivity (dBZ) echo tops are measured at, as for
+// the NEXRAD enhanced echo tops product
+const EchoTopThreshold = 18
+
+// reflectivity selects the reflectivity moment of a radial
+func reflectivity(m *archive2.Message31) *archive2.DataMoment {
+	return m.ReflectivityData
+}
+
+// sweeps returns the elevation scans of the volume with reflectivity, in
+// elevation number order
+func sweeps(ar2 *archive2.Archive2) [][]*archive2.Message31 {
+	var elevations []int
+	for elv, radials := range ar2.ElevationScans {
+		if len(radials) > 0 && radials[0].ReflectivityData != nil {
+			elevations = append(elevations, elv)
+		}
+	}
+	sort.Ints(elevations)
+	scans := make([][]*archive2.Message31, len(elevations))
+	for i, elv := range elevations {
+		scans[i] = ar2.ElevationScans[elv]
+	}
+	return scans
+}
+
+// CompositeReflectivity grids the maximum reflectivity (dBZ) in any sweep of
+// the volume above each cell, over the same square as grid.FromSweep.
+func CompositeReflectivity(ar2 *archive2.Archive2, radius float64, size int) *grid.Grid {
+	var composite *grid.Grid
+	for _, radials := range sweeps(ar2) {
+		g := grid.FromSweep(radials, reflectivity, radius, size)
+		if composite == nil {
+			composite = g
+			continue
+		}
+		for i, v := range g.Values {
+			if c := composite.Values[i]; math.IsNaN(float64(c)) || v > c {
+				composite.Values[i] = v
+			}
+		}
+	}
+	if composite == nil {
+		return grid.FromSweep(nil, reflectivity, radius, size)
+	}
+	return composite
+}
+
+// EchoTops grids the height in meters above sea level of the highest beam
+// center in the volume with reflectivity of at least threshold dBZ above each
+// cell. Cells without such echoes are NaN.
+func EchoTops(ar2 *archive2.Archive2, threshold float32, radius float64, size int) *grid.Grid {
+	scans := sweeps(ar2)
+	if len(scans) == 0 {
+		return grid.FromSweep(nil, reflectivity, radius, size)
+	}
+	lat, lon, antenna, _ := sites.Locate(scans[0][0])
+
+	var tops *grid.Grid
+	for _, radials := range scans {
+		g := grid.FromSweep(radials, reflectivity, radius, size)
+		if tops == nil {
+			tops = &grid.Grid{
+				West: g.West, North: g.North, DLon: g.DLon, DLat: g.DLat,
+				Width: g.Width, Height: g.Height, Projection: g.Projection,
+				Values: make([]float32, len(g.Values)),
+			}
+			for i := range tops.Values {
+				tops.Values[i] = float32(math.NaN())
+			}
+		}
+		elevation := float64(radials[0].Header.ElevationAngle)
+		for y := 0; y < g.Height; y++ {
+			for x := 0; x < g.Width; x++ {
+				i := y*g.Width + x
+				if !(g.Values[i] >= threshold) {
+					continue
+				}
+				clat, clon := g.Center(x, y)
+				_, distance := geo.BearingDistance(lat, lon, clat, clon)
+				height := float32(geo.BeamHeight(geo.SlantRange(distance, elevation), elevation) + antenna)
+				if t := tops.Values[i]; math.IsNaN(float64(t)) || height > t {
+					tops.Values[i] = height
+				}
+			}
+		}
+	}
+	return tops
+}
+
+const (
+	// VILCap is the reflectivity (dBZ) VIL is capped at, so hail doesn't
+	// count as liquid water
+	VILCap = 56
+	// vilCoefficient converts reflectivity factor Z (mm^6/m^3) to liquid water
+	// content M (kg/m^3), M = 3.44e-6 Z^(4/7)
+	vilCoefficient = 3.44e-6
+)
+
+// VIL grids the vertically integrated liquid in kg/m^2 above each cell: the
+// liquid water content estimated from the mean reflectivity of each pair of
+// sweeps one above the other, capped at VILCap, integrated over the height
+// between their beams. Repeated tilts are only counted once. Cells without echo in
+// any sweep are NaN.
+func VIL(ar2 *archive2.Archive2, radius float64, size int) *grid.Grid {
+	scans := distinctTilts(sweeps(ar2))
+	if len(scans) == 0 {
+		return grid.FromSweep(nil, reflectivity, radius, size)
+	}
+	lat, lon, _, _ := sites.Locate(scans[0][0])
+
+	grids := make([]*grid.Grid, len(scans))
+	for i, radials := range scans {
+		grids[i] = grid.FromSweep(radials, reflectivity, radius, size)
+	}
+	vil := grids[0]
+	// the reflectivity factor and height of the last sweep below, by cell
+	z := make([]float64, len(vil.Values))
+	below := make([]float64, len(vil.Values))
+	echo := make([]bool, len(vil.Values))
+	total := make([]float64, len(vil.Values))
+	for n, g := range grids {
+		elevation := float64(scans[n][0].Header.ElevationAngle)
+		for y := 0; y < g.Height; y++ {
+			for x := 0; x < g.Width; x++ {
+				i := y*g.Width + x
+				zi := 0.0
+				if dbz := float64(g.Values[i]); !math.IsNaN(dbz) {
+					zi = math.Pow(10, math.Min(dbz, VILCap)/10)
+					echo[i] = true
+				}
+				clat, clon := g.Center(x, y)
+				_, distance := geo.BearingDistance(lat, lon, clat, clon)
+				height := geo.BeamHeight(geo.SlantRange(distance, elevation), elevation)
+				if n > 0 {
+					total[i] += vilCoefficient * math.Pow((z[i]+zi)/2, 4.0/7) * (height - below[i])
+				}
+				z[i], below[i] = zi, height
+			}
+		}
+	}
+	for i := range vil.Values {
+		vil.Values[i] = float32(math.NaN())
+		if echo[i] {
+			vil.Values[i] = float32(total[i])
+		}
+	}
+	return vil
+}
+
+// distinctTilts returns the scans in elevation angle order, leaving out
+// repeats of a tilt from split cuts and SAILS
+func distinctTilts(scans [][]*archive2.Message31) [][]*archive2.Message31 {
+	sorted := append([][]*archive2.Message31{}, scans...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i][0].Header.ElevationAngle < sorted[j][0].Header.ElevationAngle
+	})
+	var distinct [][]*archive2.Message31
+	for _, radials := range sorted {
+		if n := len(distinct); n > 0 && radials[0].Header.ElevationAngle-distinct[n-1][0].Header.ElevationAngle < 0.2 {
+			continue
+		}
+		distinct = append(distinct, radials)
+	}
+	return distinct
+}
+
+const (
+	// Marshall-Palmer Z-R relationship, Z = a R^b
+	zrA = 200
+	zrB = 1.6
+	// HailCap is the reflectivity (dBZ) rain rates are capped at, as hail
+	// cores would otherwise produce absurd rates
+	HailCap = 53
+)
+
+// PrecipitationRate grids the rain rate in mm/h estimated from the reflectivity
+// of the lowest sweep, using the Marshall-Palmer Z-R relationship. Reflectivity
+// is capped at HailCap. Cells without echo are 0, and NaN outside the sweep.
+func PrecipitationRate(ar2 *archive2.Archive2, radius float64, size int) *grid.Grid {
+	scans := sweeps(ar2)
+	if len(scans) == 0 {
+		return grid.FromSweep(nil, reflectivity, radius, size)
+	}
+	radials := scans[0]
+	g := grid.FromSweep(radials, reflectivity, radius, size)
+
+	site := radials[0].VolumeData
+	lat, lon := float64(site.Lat), float64(site.Long)
+	maxRange := 0.0
+	if ref := radials[0].ReflectivityData; ref != nil {
+		maxRange = float64(ref.DataMomentRange) + float64(ref.NumberDataMomentGates)*float64(ref.DataMomentRangeSampleInterval)
+	}
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			i := y*g.Width + x
+			dbz := float64(g.Values[i])
+			if math.IsNaN(dbz) {
+				// below threshold gates are NaN, but are still observed as dry
+				clat, clon := g.Center(x, y)
+				if _, distance := geo.BearingDistance(lat, lon, clat, clon); distance <= math.Min(radius, maxRange) {
+					g.Values[i] = 0
+				}
+				continue
+			}
+			z := math.Pow(10, math.Min(dbz, HailCap)/10)
+			g.Values[i] = float32(math.Pow(z/zrA, 1/zrB))
+		}
+	}
+	return g
+}
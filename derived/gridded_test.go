@@ -0,0 +1,56 @@
+package derived
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/geo"
+	"github.com/kallsyms/go-nexrad/internal/testvol"
+)
+
+// refSweep returns a sweep at the elevation with uniform reflectivity out to
+// 100 km
+func refSweep(elevation, dbz float32) []*archive2.Message31 {
+	return testvol.Filled(elevation, dbz, nil)
+}
+
+func TestGridded(t *testing.T) {
+	ar2 := &archive2.Archive2{ElevationScans: map[int][]*archive2.Message31{
+		1: refSweep(0.5, 40),
+		2: refSweep(3, 10),
+		// a repeated tilt, as with SAILS
+		3: refSweep(0.5, 0),
+	}}
+	const radius, size = 150000, 60
+	// a cell 50 km north of the radar
+	x, y := size/2, size/2-10
+	g := CompositeReflectivity(ar2, radius, size)
+	lat, lon := g.Center(x, y)
+	_, distance := geo.BearingDistance(35, -97, lat, lon)
+
+	if v := g.At(x, y); v != 40 {
+		t.Errorf("composite reflectivity %v, want 40", v)
+	}
+	if v := g.At(0, 0); !math.IsNaN(float64(v)) {
+		t.Errorf("composite reflectivity %v outside the sweeps", v)
+	}
+
+	want := geo.BeamHeight(geo.SlantRange(distance, 0.5), 0.5) + 390
+	if v := EchoTops(ar2, EchoTopThreshold, radius, size).At(x, y); math.Abs(float64(v)-want) > 1 {
+		t.Errorf("echo top %v, want %v", v, want)
+	}
+
+	// the mean reflectivity factor of the two tilts over the height between them
+	dh := geo.BeamHeight(geo.SlantRange(distance, 3), 3) - geo.BeamHeight(geo.SlantRange(distance, 0.5), 0.5)
+	want = 3.44e-6 * math.Pow((1e4+10)/2, 4.0/7) * dh
+	if v := VIL(ar2, radius, size).At(x, y); math.Abs(float64(v)-want) > 1e-3 {
+		t.Errorf("VIL %v, want %v", v, want)
+	}
+
+	// Z = 200 R^1.6
+	want = math.Pow(1e4/200, 1/1.6)
+	if v := PrecipitationRate(ar2, radius, size).At(x, y); math.Abs(float64(v)-want) > 1e-3 {
+		t.Errorf("precipitation rate %v, want %v", v, want)
+	}
+}
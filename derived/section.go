@@ -0,0 +1,184 @@
+package derived
+
+import (
+	"math"
+	"sort"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/geo"
+	"github.com/kallsyms/go-nexrad/grid"
+	"github.com/kallsyms/go-nexrad/sites"
+)
+
+// Section is a vertical cross-section of a volume: the values of a moment
+// along a line on the ground from a start to an end point, at heights from
+// sea level to the top of the section
+type Section struct {
+	StartLat, StartLon float64
+	EndLat, EndLon     float64
+	// Length is the distance along the ground from the start to the end, and
+	// Top the height above sea level of the top of the section, in meters
+	Length, Top float64
+	// Width is the number of columns along the line and Height the number of
+	// rows
+	Width, Height int
+	// Values are the rows of the section from the top down, NaN where no
+	// sweep has data
+	Values []float32
+	// Beams, if set, are the paths of beams for render.Section to outline,
+	// such as those from SectionBeams
+	Beams [][]geo.BeamPoint
+}
+
+// At returns the value of the cell at column x (from the start) and row y
+// (from the top)
+func (s *Section) At(x, y int) float32 {
+	return s.Values[y*s.Width+x]
+}
+
+// sectionSweep is a sweep sampled for a cross-section
+type sectionSweep struct {
+	radials   []*archive2.Message31
+	moment    grid.MomentFunc
+	elevation float64
+	sampler   *grid.Sampler
+}
+
+// CrossSection slices every elevation scan of the volume with the moment
+// along the line from start to end into a width x height section reaching top
+// meters above sea level. Each cell takes the value of the sweep whose beam
+// passes through it, within half the beam width of its center, and between
+// sweeps is interpolated in elevation angle from the sweeps above and below,
+// so gaps between the higher tilts are filled. moment returns the moment of
+// each sweep, as render.MomentFor does, so derived products can be sliced.
+func CrossSection(ar2 *archive2.Archive2, moment func(sweep []*archive2.Message31) grid.MomentFunc, startLat, startLon, endLat, endLon, top float64, width, height int) *Section {
+	bearing, length := geo.BearingDistance(startLat, startLon, endLat, endLon)
+	s := &Section{
+		StartLat: startLat, StartLon: startLon,
+		EndLat: endLat, EndLon: endLon,
+		Length: length, Top: top,
+		Width: width, Height: height,
+		Values: make([]float32, width*height),
+	}
+	for i := range s.Values {
+		s.Values[i] = float32(math.NaN())
+	}
+
+	sweeps := sectionSweeps(ar2, moment)
+	if len(sweeps) == 0 {
+		return s
+	}
+	lat, lon, antenna, _ := sites.Locate(sweeps[0].radials[0])
+	for i := range sweeps {
+		sweeps[i].sampler = grid.NewSampler(sweeps[i].radials, sweeps[i].moment, math.Inf(1))
+	}
+
+	values := make([]float64, len(sweeps))
+	for x := 0; x < width; x++ {
+		plat, plon := geo.Destination(startLat, startLon, bearing, length*(float64(x)+0.5)/float64(width))
+		_, distance := geo.BearingDistance(lat, lon, plat, plon)
+		for i, sw := range sweeps {
+			values[i] = float64(sw.sampler.At(plat, plon))
+		}
+		for y := 0; y < height; y++ {
+			h := top*(float64(height-y)-0.5)/float64(height) - antenna
+			if v, ok := sectionValue(sweeps, values, elevationTo(distance, h)); ok {
+				s.Values[y*width+x] = float32(v)
+			}
+		}
+	}
+	return s
+}
+
+// SectionBeams returns the paths of the beams of the sweeps CrossSection
+// slices with the moment, from the radar out to length meters along the
+// ground, sampled at about width points. Heights are above sea level, so the
+// paths can be drawn over a section from the radar, such as an RHI, to show
+// the beams passing over or under echoes with range.
+func SectionBeams(ar2 *archive2.Archive2, moment func(sweep []*archive2.Message31) grid.MomentFunc, length float64, width int) [][]geo.BeamPoint {
+	sweeps := sectionSweeps(ar2, moment)
+	if len(sweeps) == 0 || width <= 0 {
+		return nil
+	}
+	_, _, antenna, _ := sites.Locate(sweeps[0].radials[0])
+	var beams [][]geo.BeamPoint
+	for _, sw := range sweeps {
+		maxRange := geo.SlantRange(length, sw.elevation)
+		if path := geo.BeamPath(sw.elevation, antenna, maxRange, maxRange/float64(width)); path != nil {
+			beams = append(beams, path)
+		}
+	}
+	return beams
+}
+
+// sectionValue returns the value at the elevation angle from the sweeps'
+// values at the column: that of the sweep within half the beam width, or
+// interpolated between the sweeps either side
+func sectionValue(sweeps []sectionSweep, values []float64, elevation float64) (float64, bool) {
+	// the first sweep at or above the elevation
+	i := sort.Search(len(sweeps), func(i int) bool { return sweeps[i].elevation >= elevation })
+	nearest := -1
+	for _, j := range []int{i - 1, i} {
+		if j < 0 || j >= len(sweeps) || math.Abs(sweeps[j].elevation-elevation) > geo.BeamWidth/2 {
+			continue
+		}
+		if nearest < 0 || math.Abs(sweeps[j].elevation-elevation) < math.Abs(sweeps[nearest].elevation-elevation) {
+			nearest = j
+		}
+	}
+	if nearest >= 0 {
+		return values[nearest], !math.IsNaN(values[nearest])
+	}
+	if i == 0 || i == len(sweeps) {
+		return 0, false
+	}
+	below, above := values[i-1], values[i]
+	if math.IsNaN(below) || math.IsNaN(above) {
+		return 0, false
+	}
+	f := (elevation - sweeps[i-1].elevation) / (sweeps[i].elevation - sweeps[i-1].elevation)
+	return below + (above-below)*f, true
+}
+
+// sectionSweeps returns samplers of the volume's sweeps with the moment in
+// elevation angle order. Repeated tilts, from split cuts and SAILS, are only
+// sampled from their first scan.
+func sectionSweeps(ar2 *archive2.Archive2, moment func(sweep []*archive2.Message31) grid.MomentFunc) []sectionSweep {
+	var elevations []int
+	for elv, radials := range ar2.ElevationScans {
+		if len(radials) > 0 {
+			elevations = append(elevations, elv)
+		}
+	}
+	sort.Ints(elevations)
+	var sweeps []sectionSweep
+	for _, elv := range elevations {
+		radials := ar2.ElevationScans[elv]
+		angle := float64(radials[0].Header.ElevationAngle)
+		repeated := false
+		for _, s := range sweeps {
+			if math.Abs(s.elevation-angle) < 0.2 {
+				repeated = true
+			}
+		}
+		if repeated {
+			continue
+		}
+		m := moment(radials)
+		if m(radials[0]) == nil {
+			continue
+		}
+		sweeps = append(sweeps, sectionSweep{radials: radials, moment: m, elevation: angle})
+	}
+	sort.Slice(sweeps, func(i, j int) bool { return sweeps[i].elevation < sweeps[j].elevation })
+	return sweeps
+}
+
+// elevationTo returns the elevation angle in degrees of the beam reaching
+// height meters above the radar at the ground distance, using the 4/3
+// effective earth radius model like geo.BeamHeight
+func elevationTo(distance, height float64) float64 {
+	re := geo.EarthRadius * geo.EffectiveRadiusFactor
+	theta := distance / re
+	return math.Atan2((re+height)*math.Cos(theta)-re, (re+height)*math.Sin(theta)) * 180 / math.Pi
+}
@@ -0,0 +1,74 @@
+package derived
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/geo"
+	"github.com/kallsyms/go-nexrad/grid"
+)
+
+func TestCrossSection(t *testing.T) {
+	ar2 := &archive2.Archive2{ElevationScans: map[int][]*archive2.Message31{
+		1: refSweep(0.5, 40),
+		2: refSweep(0.5, 0),
+		3: refSweep(1.5, 30),
+		4: refSweep(3, 10),
+	}}
+	endLat, endLon := geo.Destination(35, -97, 90, 90000)
+	const top, rows = 10000.0, 100
+	s := CrossSection(ar2, func([]*archive2.Message31) grid.MomentFunc { return reflectivity }, 35, -97, endLat, endLon, top, 90, rows)
+	if math.Abs(s.Length-90000) > 1 {
+		t.Errorf("expected a 90 km section, got %g m", s.Length)
+	}
+
+	// the column 49.5 km out
+	const x, distance = 49, 49500.0
+	row := func(elevation float64) int {
+		h := geo.BeamHeight(geo.SlantRange(distance, elevation), elevation) + 390
+		return int((top - h) / (top / rows))
+	}
+	// the repeated 0.5 degree scan is ignored
+	if v := s.At(x, row(0.5)); v != 40 {
+		t.Errorf("expected 40 dBZ in the 0.5 degree beam, got %v", v)
+	}
+	if v := s.At(x, row(1.5)); v != 30 {
+		t.Errorf("expected 30 dBZ in the 1.5 degree beam, got %v", v)
+	}
+	// half way between 1.5 and 3 degrees, outside both beams
+	if v := s.At(x, row(2.25)); math.Abs(float64(v)-20) > 2 {
+		t.Errorf("expected about 20 dBZ between the beams, got %v", v)
+	}
+	if v := s.At(x, row(5)); !math.IsNaN(float64(v)) {
+		t.Errorf("expected no data above the highest beam, got %v", v)
+	}
+	if v := s.At(x, rows-1); !math.IsNaN(float64(v)) {
+		t.Errorf("expected no data below the lowest beam, got %v", v)
+	}
+}
+
+func TestSectionBeams(t *testing.T) {
+	ar2 := &archive2.Archive2{ElevationScans: map[int][]*archive2.Message31{
+		1: refSweep(0.5, 40),
+		2: refSweep(0.5, 0),
+		3: refSweep(1.5, 30),
+	}}
+	beams := SectionBeams(ar2, func([]*archive2.Message31) grid.MomentFunc { return reflectivity }, 90000, 90)
+	if len(beams) != 2 {
+		t.Fatalf("expected the beams of the 0.5 and 1.5 degree sweeps, got %d", len(beams))
+	}
+	end := beams[0][len(beams[0])-1]
+	if math.Abs(end.GroundRange-90000) > 1000 {
+		t.Errorf("expected the beam to reach 90 km, got %g m", end.GroundRange)
+	}
+	if want := geo.BeamHeight(end.SlantRange, 0.5) + 390; math.Abs(end.Center-want) > 1 {
+		t.Errorf("expected the beam center %g m above sea level, got %g m", want, end.Center)
+	}
+	if beams[1][len(beams[1])-1].Bottom <= end.Top {
+		t.Errorf("expected the 1.5 degree beam above the 0.5 degree beam")
+	}
+	if beams := SectionBeams(ar2, func([]*archive2.Message31) grid.MomentFunc { return reflectivity }, 0, 90); beams != nil {
+		t.Errorf("expected no beams for a section of no length, got %+v", beams)
+	}
+}
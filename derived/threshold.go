@@ -0,0 +1,39 @@
+package derived
+
+import (
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+// Threshold returns the moment selected by moment from each radial with the
+// gates outside min to max set below threshold, e.g. to leave out echoes
+// weaker than 20 dBZ or velocities beyond a range. Either bound can be NaN for
+// none. Range folded gates are kept, and the values are left in their
+// original encoding.
+func Threshold(radials []*archive2.Message31, moment func(*archive2.Message31) *archive2.DataMoment, min, max float64) Product {
+	p := Product{}
+	for _, r := range radials {
+		d := moment(r)
+		if d == nil {
+			continue
+		}
+		t := *d
+		t.Data = append([]byte(nil), d.Data...)
+		scaled := d.ScaledData()
+		for i, v := range scaled {
+			if v == archive2.MomentDataBelowThreshold || v == archive2.MomentDataFolded {
+				continue
+			}
+			if float64(v) < min || float64(v) > max {
+				// a raw value of 0 is below threshold
+				if d.DataWordSize == 16 {
+					t.Data[i*2], t.Data[i*2+1] = 0, 0
+				} else {
+					t.Data[i] = 0
+				}
+			}
+		}
+		archive2.ReleaseScaled(scaled)
+		p[r] = &t
+	}
+	return p
+}
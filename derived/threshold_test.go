@@ -0,0 +1,43 @@
+package derived
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+func TestThreshold(t *testing.T) {
+	// reflectivity of 0, 10, 20, 30 and 40 dBZ, then below threshold and
+	// range folded
+	m31 := &archive2.Message31{}
+	m31.ReflectivityData = &archive2.DataMoment{
+		GenericDataMoment: archive2.GenericDataMoment{
+			NumberDataMomentGates: 7,
+			DataWordSize:          8,
+			Scale:                 2,
+			Offset:                66,
+		},
+		Data: []byte{66, 86, 106, 126, 146, 0, 1},
+	}
+	ref := func(m *archive2.Message31) *archive2.DataMoment { return m.ReflectivityData }
+
+	got := Threshold([]*archive2.Message31{m31}, ref, 20, math.NaN()).Moment(m31).ScaledData()
+	want := []float32{999, 999, 20, 30, 40, 999, 998}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+	if m31.ReflectivityData.Data[0] != 66 {
+		t.Error("expected the original moment unchanged")
+	}
+
+	got = Threshold([]*archive2.Message31{m31}, ref, 5, 25).Moment(m31).ScaledData()
+	want = []float32{999, 10, 20, 999, 999, 999, 998}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package derived
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/geo"
+	"github.com/kallsyms/go-nexrad/sites"
+)
+
+const (
+	// VADLevel is the spacing in meters of the levels of a wind profile,
+	// 1000 ft as in the NEXRAD VAD wind profile product
+	VADLevel = 304.8
+	// VADMaxRMS is the largest RMS error in m/s of the fit of a wind
+	VADMaxRMS = 5.0
+
+	// the slant ranges in meters VADs are fit at on each sweep
+	vadMinRange  = 5000
+	vadMaxRange  = 80000
+	vadRangeStep = 2500
+	// the largest gap in degrees between radials with data around a VAD ring
+	vadMaxGap = 60
+)
+
+// WindLevel is the horizontal wind at a height above sea level, in meters,
+// from a VAD fit with the RMS error of the fit
+type WindLevel struct {
+	Height float64
+	// U and V are the eastward and northward components of the wind in m/s
+	U, V float64
+	RMS  float64
+}
+
+// Speed returns the wind speed in m/s
+func (l WindLevel) Speed() float64 {
+	return math.Hypot(l.U, l.V)
+}
+
+// Direction returns the direction the wind blows from, in degrees clockwise
+// from north
+func (l WindLevel) Direction() float64 {
+	return math.Mod(math.Atan2(-l.U, -l.V)*180/math.Pi+360, 360)
+}
+
+// WindProfile is the wind above a radar at the time of a volume
+type WindProfile struct {
+	Time time.Time
+	// Levels are the winds at each height with a good fit, from the lowest up
+	Levels []WindLevel
+}
+
+// VAD derives the wind profile of the volume with the velocity azimuth display
+// technique: on each sweep with velocity, the radial velocities around rings
+// of constant range are fit to a sine wave, whose amplitude and phase give the
+// wind at the height of the ring. Rings without data most of the way around,
+// or fit with an RMS error over VADMaxRMS, are left out. The profile has a
+// level every VADLevel meters with a fit, of the fit with the smallest error.
+func VAD(ar2 *archive2.Archive2) *WindProfile {
+	p := &WindProfile{Time: ar2.VolumeHeader.Date()}
+	levels := map[int]WindLevel{}
+	for _, radials := range ar2.ElevationScans {
+		if len(radials) == 0 || radials[0].VelocityData == nil {
+			continue
+		}
+		_, _, antenna, _ := sites.Locate(radials[0])
+		elevation := float64(radials[0].Header.ElevationAngle)
+		values := make([][]float64, len(radials))
+		for i, r := range radials {
+			if r.VelocityData != nil {
+				values[i] = gateValues(r.VelocityData)
+			}
+		}
+		for rng := float64(vadMinRange); rng <= vadMaxRange; rng += vadRangeStep {
+			l, ok := vadFit(radials, values, rng, elevation)
+			if !ok {
+				continue
+			}
+			l.Height = geo.BeamHeight(rng, elevation) + antenna
+			n := int(math.Round(l.Height / VADLevel))
+			if best, ok := levels[n]; !ok || l.RMS < best.RMS {
+				levels[n] = l
+			}
+		}
+	}
+	for _, l := range levels {
+		p.Levels = append(p.Levels, l)
+	}
+	sort.Slice(p.Levels, func(i, j int) bool { return p.Levels[i].Height < p.Levels[j].Height })
+	return p
+}
+
+// vadFit fits the radial velocities at the slant range around the sweep to
+// v = a0 + a1 cos(az) + b1 sin(az) by least squares, returning the wind it
+// gives
+func vadFit(radials []*archive2.Message31, values [][]float64, rng, elevation float64) (WindLevel, bool) {
+	var az, vr []float64
+	for i, r := range radials {
+		if values[i] == nil {
+			continue
+		}
+		if v := valueAt(r.VelocityData, values[i], rng); !math.IsNaN(v) {
+			az = append(az, float64(r.Header.AzimuthAngle)*math.Pi/180)
+			vr = append(vr, v)
+		}
+	}
+	if len(az) < len(radials)/2 || len(az) < 3 || maxGap(az) > vadMaxGap*math.Pi/180 {
+		return WindLevel{}, false
+	}
+
+	// the normal equations of the fit
+	var m [3][3]float64
+	var b [3]float64
+	for i := range az {
+		f := [3]float64{1, math.Cos(az[i]), math.Sin(az[i])}
+		for j := range f {
+			for k := range f {
+				m[j][k] += f[j] * f[k]
+			}
+			b[j] += f[j] * vr[i]
+		}
+	}
+	c, ok := solve3(m, b)
+	if !ok {
+		return WindLevel{}, false
+	}
+	var sum float64
+	for i := range az {
+		d := vr[i] - (c[0] + c[1]*math.Cos(az[i]) + c[2]*math.Sin(az[i]))
+		sum += d * d
+	}
+	rms := math.Sqrt(sum / float64(len(az)))
+	if rms > VADMaxRMS {
+		return WindLevel{}, false
+	}
+	// the beam sees the horizontal wind foreshortened by the elevation
+	cos := math.Cos(elevation * math.Pi / 180)
+	return WindLevel{U: c[2] / cos, V: c[1] / cos, RMS: rms}, true
+}
+
+// maxGap returns the largest gap in radians between the azimuths around the
+// circle
+func maxGap(az []float64) float64 {
+	sorted := append([]float64{}, az...)
+	sort.Float64s(sorted)
+	gap := sorted[0] + 2*math.Pi - sorted[len(sorted)-1]
+	for i := 1; i < len(sorted); i++ {
+		gap = math.Max(gap, sorted[i]-sorted[i-1])
+	}
+	return gap
+}
+
+// solve3 solves m x = b by Cramer's rule, returning false if m is singular
+func solve3(m [3][3]float64, b [3]float64) ([3]float64, bool) {
+	det := func(m [3][3]float64) float64 {
+		return m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+			m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+			m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+	}
+	d := det(m)
+	var x [3]float64
+	if math.Abs(d) < 1e-9 {
+		return x, false
+	}
+	for i := range x {
+		mi := m
+		for j := range b {
+			mi[j][i] = b[j]
+		}
+		x[i] = det(mi) / d
+	}
+	return x, true
+}
@@ -0,0 +1,43 @@
+package derived
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+func TestVAD(t *testing.T) {
+	// 10 m/s from the southwest
+	u, v := 10/math.Sqrt2, 10/math.Sqrt2
+	wind := func(az, rng float64) float64 {
+		return u*math.Sin(az*math.Pi/180) + v*math.Cos(az*math.Pi/180)
+	}
+	// a higher sweep with data only half way around
+	half := testSweep(func(az, rng float64) float64 {
+		if az < 180 {
+			return -64.5
+		}
+		return wind(az, rng)
+	})
+	for _, r := range half {
+		r.Header.ElevationAngle = 10
+	}
+	p := VAD(&archive2.Archive2{ElevationScans: map[int][]*archive2.Message31{
+		1: testSweep(wind),
+		2: half,
+	}})
+
+	// rings from 5 to 15 km on the lowest sweep
+	if len(p.Levels) == 0 {
+		t.Fatal("expected a wind profile")
+	}
+	for _, l := range p.Levels {
+		if l.Height > 100 {
+			t.Errorf("expected no winds from the sweep with data half way around, got %+v", l)
+		}
+		if math.Abs(l.Speed()-10) > 0.5 || math.Abs(l.Direction()-225) > 2 {
+			t.Errorf("expected 10 m/s from 225 degrees, got %.1f m/s from %.0f", l.Speed(), l.Direction())
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package derived
+
+import (
+	"math"
+	"sort"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+// Divergence computes the radial divergence of radial velocity: the gradient
+// of velocity along each beam, in s^-1. Positive values are divergent (e.g.
+// microburst outflow), negative values convergent (e.g. gust fronts and other
+// boundaries crossing the beam).
+//
+// The gradient at each gate is the least squares slope of the velocities within
+// baseline meters centered on it. At least half of the gates in the window must
+// have data. Velocities are not dealiased, so aliased gates produce spurious
+// gradients.
+func Divergence(radials []*archive2.Message31, baseline float64) Product {
+	p := Product{}
+	for _, r := range radials {
+		vel := r.VelocityData
+		if vel == nil || vel.DataMomentRangeSampleInterval == 0 {
+			continue
+		}
+		interval := float64(vel.DataMomentRangeSampleInterval)
+		half := int(math.Max(1, math.Round(baseline/interval/2)))
+		v := gateValues(vel)
+		div := make([]float64, len(v))
+		for i := range v {
+			div[i] = slope(v, i-half, i+half) / interval
+		}
+		p[r] = newMoment("DIV", vel, div, gradientScale)
+	}
+	return p
+}
+
+// slope returns the least squares slope of values[from:to+1] against their
+// index, or NaN if fewer than half of them have data.
+func slope(values []float64, from, to int) float64 {
+	var n, sx, sy, sxx, sxy float64
+	for i := from; i <= to; i++ {
+		if i < 0 || i >= len(values) || math.IsNaN(values[i]) {
+			continue
+		}
+		x := float64(i)
+		n++
+		sx += x
+		sy += values[i]
+		sxx += x * x
+		sxy += x * values[i]
+	}
+	if n < 2 || n < float64(to-from+1)/2 {
+		return math.NaN()
+	}
+	d := n*sxx - sx*sx
+	if d == 0 {
+		return math.NaN()
+	}
+	return (n*sxy - sx*sy) / d
+}
+
+// AzimuthalShear computes the azimuthal gradient of radial velocity, the
+// change in velocity across neighboring radials divided by the distance between
+// them, in s^-1. Positive values indicate cyclonic (counterclockwise) rotation
+// or shear; together with Divergence it separates rotational and convergent
+// signatures, since convergence along a boundary parallel to the beam shows up
+// here rather than in the radial gradient.
+//
+// Each gate is compared with the gates at the same range in the radials about
+// baseline/2 degrees of azimuth either side.
+func AzimuthalShear(radials []*archive2.Message31, baseline float64) Product {
+	p := Product{}
+	if len(radials) == 0 {
+		return p
+	}
+	sorted := make([]*archive2.Message31, 0, len(radials))
+	for _, r := range radials {
+		if r.VelocityData != nil && r.VelocityData.DataMomentRangeSampleInterval != 0 {
+			sorted = append(sorted, r)
+		}
+	}
+	if len(sorted) < 3 {
+		return p
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Header.AzimuthAngle < sorted[j].Header.AzimuthAngle })
+
+	spacing := sorted[0].Header.AzimuthResolutionSpacing()
+	half := int(math.Max(1, math.Round(baseline/spacing/2)))
+	values := make([][]float64, len(sorted))
+	for i, r := range sorted {
+		values[i] = gateValues(r.VelocityData)
+	}
+
+	n := len(sorted)
+	for i, r := range sorted {
+		// the neighbors wrap around north
+		ccw, cw := (i-half+n)%n, (i+half)%n
+		dtheta := float64(sorted[cw].Header.AzimuthAngle) - float64(sorted[ccw].Header.AzimuthAngle)
+		if dtheta <= 0 {
+			dtheta += 360
+		}
+		// skip gaps in the sweep
+		if dtheta > 2*float64(2*half)*spacing {
+			continue
+		}
+		dtheta *= math.Pi / 180
+
+		vel := r.VelocityData
+		first := float64(vel.DataMomentRange)
+		interval := float64(vel.DataMomentRangeSampleInterval)
+		shear := make([]float64, len(values[i]))
+		for g := range shear {
+			rng := first + float64(g)*interval
+			a := valueAt(sorted[ccw].VelocityData, values[ccw], rng)
+			b := valueAt(sorted[cw].VelocityData, values[cw], rng)
+			shear[g] = (b - a) / (rng * dtheta)
+		}
+		p[r] = newMoment("AZS", vel, shear, gradientScale)
+	}
+	return p
+}
+
+// valueAt returns the value of the gate at the range (in meters) from values,
+// the gates of d
+func valueAt(d *archive2.DataMoment, values []float64, rng float64) float64 {
+	g := int(math.Round((rng - float64(d.DataMomentRange)) / float64(d.DataMomentRangeSampleInterval)))
+	if g < 0 || g >= len(values) {
+		return math.NaN()
+	}
+	return values[g]
+}
@@ -0,0 +1,84 @@
+package derived
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+// testSweep returns a sweep of 1 degree radials with velocities from vel, given
+// the azimuth and range in meters
+func testSweep(vel func(az, rng float64) float64) []*archive2.Message31 {
+	var radials []*archive2.Message31
+	for az := 0; az < 360; az++ {
+		m31 := &archive2.Message31{}
+		m31.Header.AzimuthAngle = float32(az)
+		data := make([]byte, 60)
+		for g := range data {
+			data[g] = byte(vel(float64(az), float64(250+g*250))*2 + 129)
+		}
+		m31.VelocityData = &archive2.DataMoment{
+			GenericDataMoment: archive2.GenericDataMoment{
+				NumberDataMomentGates:         60,
+				DataMomentRange:               250,
+				DataMomentRangeSampleInterval: 250,
+				DataWordSize:                  8,
+				Scale:                         2,
+				Offset:                        129,
+			},
+			Data: data,
+		}
+		radials = append(radials, m31)
+	}
+	return radials
+}
+
+func TestDivergence(t *testing.T) {
+	// outflow accelerating away from the radar, except for a ring of no data
+	radials := testSweep(func(az, rng float64) float64 {
+		if rng > 9000 && rng < 11000 {
+			return -64.5
+		}
+		return rng * 0.004
+	})
+
+	p := Divergence(radials, 1000)
+	if len(p) != 360 {
+		t.Fatalf("expected 360 radials, got %d", len(p))
+	}
+	div := p.Moment(radials[10]).ScaledData()
+	if len(div) != 60 {
+		t.Fatalf("expected 60 gates, got %d", len(div))
+	}
+	if math.Abs(float64(div[20])-0.004) > 1e-5 {
+		t.Errorf("expected divergence of 0.004 s^-1, got %v", div[20])
+	}
+	if div[39] != archive2.MomentDataBelowThreshold {
+		t.Errorf("expected no divergence without velocity data, got %v", div[39])
+	}
+}
+
+func TestAzimuthalShear(t *testing.T) {
+	// velocity increasing clockwise through the south, like a cyclonic vortex
+	radials := testSweep(func(az, rng float64) float64 {
+		if az > 150 && az < 210 {
+			return (az - 180) * 0.5
+		}
+		return 0
+	})
+
+	p := AzimuthalShear(radials, 2)
+	shear := p.Moment(radials[180]).ScaledData()
+	// 1 m/s over 2 degrees at 10 km
+	want := 1 / (10000 * 2 * math.Pi / 180)
+	if got := float64(shear[39]); math.Abs(got-want) > 1e-4 {
+		t.Errorf("expected shear of %v s^-1, got %v", want, got)
+	}
+	if v := p.Moment(radials[90]).ScaledData()[39]; v != 0 {
+		t.Errorf("expected no shear outside the vortex, got %v", v)
+	}
+	if p.Moment(&archive2.Message31{}) != nil {
+		t.Error("expected no moment for a radial outside the sweep")
+	}
+}
@@ -0,0 +1,283 @@
+// Package detect finds features such as gust fronts in radar sweeps.
+package detect
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/derived"
+	"github.com/kallsyms/go-nexrad/geo"
+	"github.com/kallsyms/go-nexrad/sites"
+)
+
+// FineLineOptions controls fine line detection
+type FineLineOptions struct {
+	// MinReflectivity and MaxReflectivity bound the reflectivity (dBZ) of the
+	// line. Fine lines are usually weak echoes from insects and debris lofted
+	// along the boundary.
+	MinReflectivity float32
+	MaxReflectivity float32
+	// Contrast is how much stronger (dB) the line must be than the reflectivity
+	// on either side of it along the beam
+	Contrast float32
+	// Width is the maximum width of the line in meters
+	Width float64
+	// Convergence is the minimum convergence (negative radial divergence, s^-1)
+	// within Width of the line. 0 skips the velocity check.
+	Convergence float64
+	// MinLength is the minimum length of a line in meters
+	MinLength float64
+}
+
+// DefaultFineLineOptions are suitable for gust fronts in the lowest elevation
+// scan
+var DefaultFineLineOptions = FineLineOptions{
+	MinReflectivity: 5,
+	MaxReflectivity: 30,
+	Contrast:        5,
+	Width:           2000,
+	Convergence:     5e-4,
+	MinLength:       20000,
+}
+
+// Point is a location on a line
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+// Line is a detected fine line
+type Line struct {
+	// Points run along the line in order of azimuth
+	Points []Point
+	// Length in meters
+	Length float64
+	// Time the sweep started
+	Time time.Time
+	// Speed (m/s) and Direction (degrees clockwise from north, towards) the line
+	// moved since the previous volume, set by Track. NaN if unknown.
+	Speed     float64
+	Direction float64
+}
+
+// candidate is a gate on a radial which looks like part of a fine line
+type candidate struct {
+	radial int
+	rng    float64
+}
+
+// FineLines detects thin lines of enhanced reflectivity in a sweep, such as
+// gust fronts and other boundaries, optionally requiring convergence in the
+// velocity field. Lines must cross the beams to be detected, so boundaries
+// pointing at the radar are missed.
+func FineLines(radials []*archive2.Message31, opts FineLineOptions) []*Line {
+	sorted := make([]*archive2.Message31, 0, len(radials))
+	for _, r := range radials {
+		if r.ReflectivityData != nil && r.ReflectivityData.DataMomentRangeSampleInterval != 0 {
+			sorted = append(sorted, r)
+		}
+	}
+	if len(sorted) == 0 {
+		return nil
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Header.AzimuthAngle < sorted[j].Header.AzimuthAngle })
+
+	var div derived.Product
+	if opts.Convergence != 0 {
+		div = derived.Divergence(sorted, opts.Width)
+	}
+
+	// find the gates on each radial that stand out from their surroundings
+	var candidates []candidate
+	for i, r := range sorted {
+		ref := r.ReflectivityData
+		interval := float64(ref.DataMomentRangeSampleInterval)
+		w := int(math.Max(1, math.Round(opts.Width/interval/2)))
+		values := gateValues(ref)
+
+		var convergence []float64
+		if d := div.Moment(r); d != nil {
+			convergence = gateValues(d)
+		} else if opts.Convergence != 0 {
+			continue
+		}
+
+		for g, v := range values {
+			if math.IsNaN(v) || float32(v) < opts.MinReflectivity || float32(v) > opts.MaxReflectivity {
+				continue
+			}
+			if !isPeak(values, g, w) {
+				continue
+			}
+			if v-sideMean(values, g-2*w, g-w) < float64(opts.Contrast) ||
+				v-sideMean(values, g+w, g+2*w) < float64(opts.Contrast) {
+				continue
+			}
+			rng := float64(ref.DataMomentRange) + float64(g)*interval
+			if opts.Convergence != 0 && !converging(r.VelocityData, convergence, rng, opts) {
+				continue
+			}
+			candidates = append(candidates, candidate{i, rng})
+		}
+	}
+
+	var lines []*Line
+	for _, component := range connect(candidates, len(sorted), opts.Width) {
+		if l := newLine(sorted, component); l.Length >= opts.MinLength {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// isPeak reports whether gate g is the strongest within w gates of it
+func isPeak(values []float64, g, w int) bool {
+	for i := g - w; i <= g+w; i++ {
+		if i < 0 || i >= len(values) || i == g {
+			continue
+		}
+		if values[i] > values[g] || (values[i] == values[g] && i < g) {
+			return false
+		}
+	}
+	return true
+}
+
+// sideMean returns the mean of the gates from..to with data. Gates without data
+// are weaker than any echo, so a side without data returns -Inf.
+func sideMean(values []float64, from, to int) float64 {
+	sum, n := 0.0, 0
+	for i := from; i <= to; i++ {
+		if i >= 0 && i < len(values) && !math.IsNaN(values[i]) {
+			sum += values[i]
+			n++
+		}
+	}
+	if n == 0 {
+		return math.Inf(-1)
+	}
+	return sum / float64(n)
+}
+
+// converging reports whether there's enough convergence within Width of the
+// range, div being the divergence gates on the range axis of vel
+func converging(vel *archive2.DataMoment, div []float64, rng float64, opts FineLineOptions) bool {
+	interval := float64(vel.DataMomentRangeSampleInterval)
+	center := int(math.Round((rng - float64(vel.DataMomentRange)) / interval))
+	w := int(math.Round(opts.Width / interval))
+	for i := center - w; i <= center+w; i++ {
+		if i >= 0 && i < len(div) && -div[i] >= opts.Convergence {
+			return true
+		}
+	}
+	return false
+}
+
+// connect groups candidates on neighboring radials within tolerance meters of
+// each other in range
+func connect(candidates []candidate, nradials int, tolerance float64) [][]candidate {
+	parent := make([]int, len(candidates))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	byRadial := map[int][]int{}
+	for i, c := range candidates {
+		byRadial[c.radial] = append(byRadial[c.radial], i)
+	}
+	for i, c := range candidates {
+		// the sweep wraps around north
+		for _, j := range byRadial[(c.radial+1)%nradials] {
+			if math.Abs(candidates[j].rng-c.rng) <= tolerance {
+				parent[find(i)] = find(j)
+			}
+		}
+	}
+
+	groups := map[int][]candidate{}
+	var roots []int
+	for i, c := range candidates {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], c)
+	}
+	components := make([][]candidate, len(roots))
+	for i, root := range roots {
+		components[i] = groups[root]
+	}
+	return components
+}
+
+// newLine builds a line through the mean range of the component on each radial
+func newLine(radials []*archive2.Message31, component []candidate) *Line {
+	sums := map[int]float64{}
+	counts := map[int]int{}
+	var order []int
+	for _, c := range component {
+		if counts[c.radial] == 0 {
+			order = append(order, c.radial)
+		}
+		sums[c.radial] += c.rng
+		counts[c.radial]++
+	}
+	sort.Ints(order)
+
+	// start after the largest gap in azimuth, so lines crossing north are in order
+	start, gap := 0, 0
+	for i := range order {
+		next := order[(i+1)%len(order)]
+		d := next - order[i]
+		if d <= 0 {
+			d += len(radials)
+		}
+		if d > gap {
+			start, gap = (i+1)%len(order), d
+		}
+	}
+
+	l := &Line{Time: radials[0].Header.Date(), Speed: math.NaN(), Direction: math.NaN()}
+	for i := range order {
+		radial := order[(start+i)%len(order)]
+		r := radials[radial]
+		slantRange := sums[radial] / float64(counts[radial])
+		siteLat, siteLon, _, _ := sites.Locate(r)
+		lat, lon := geo.Destination(
+			siteLat, siteLon,
+			float64(r.Header.AzimuthAngle),
+			geo.GroundRange(slantRange, float64(r.Header.ElevationAngle)),
+		)
+		p := Point{lat, lon}
+		if n := len(l.Points); n > 0 {
+			_, d := geo.BearingDistance(l.Points[n-1].Lat, l.Points[n-1].Lon, p.Lat, p.Lon)
+			l.Length += d
+		}
+		l.Points = append(l.Points, p)
+	}
+	return l
+}
+
+// gateValues returns the scaled gates of the moment, with NaN in place of
+// below threshold and range folded gates.
+func gateValues(d *archive2.DataMoment) []float64 {
+	scaled := d.ScaledData()
+	values := make([]float64, len(scaled))
+	for i, v := range scaled {
+		if v == archive2.MomentDataBelowThreshold || v == archive2.MomentDataFolded {
+			values[i] = math.NaN()
+		} else {
+			values[i] = float64(v)
+		}
+	}
+	return values
+}
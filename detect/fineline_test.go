@@ -0,0 +1,115 @@
+package detect
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+// testSweep returns a sweep with a gust front lineRange meters from the radar
+// between azimuths 0 and 90: a thin line of 20 dBZ in 0 dBZ clear air, with
+// outflow converging on it.
+func testSweep(lineRange float64, seconds uint32) []*archive2.Message31 {
+	var radials []*archive2.Message31
+	for az := 0; az < 360; az++ {
+		m31 := &archive2.Message31{}
+		m31.Header.AzimuthAngle = float32(az)
+		m31.Header.CollectionDate = 18000
+		m31.Header.CollectionTime = seconds * 1000
+		m31.Header.ElevationAngle = 0.5
+		m31.VolumeData.Lat = 35
+		m31.VolumeData.Long = -97
+
+		ref := make([]byte, 400)
+		vel := make([]byte, 400)
+		for g := range ref {
+			rng := float64(250 + g*250)
+			ref[g] = 66
+			vel[g] = 129
+			if az < 90 {
+				if math.Abs(rng-lineRange) < 200 {
+					ref[g] = 106
+				}
+				if rng < lineRange {
+					vel[g] = 129 + 10
+				} else {
+					vel[g] = 129 - 10
+				}
+			}
+		}
+		m31.ReflectivityData = testMoment(ref, 2, 66)
+		m31.VelocityData = testMoment(vel, 2, 129)
+		radials = append(radials, m31)
+	}
+	return radials
+}
+
+func testMoment(data []byte, scale, offset float32) *archive2.DataMoment {
+	return &archive2.DataMoment{
+		GenericDataMoment: archive2.GenericDataMoment{
+			NumberDataMomentGates:         uint16(len(data)),
+			DataMomentRange:               250,
+			DataMomentRangeSampleInterval: 250,
+			DataWordSize:                  8,
+			Scale:                         scale,
+			Offset:                        offset,
+		},
+		Data: data,
+	}
+}
+
+func TestFineLines(t *testing.T) {
+	lines := FineLines(testSweep(20000, 0), DefaultFineLineOptions)
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 line, got %d", len(lines))
+	}
+	l := lines[0]
+	if len(l.Points) != 90 {
+		t.Errorf("expected a point per radial, got %d", len(l.Points))
+	}
+	// a quarter circle of radius 20 km
+	if want := 2 * math.Pi * 20000 / 4; math.Abs(l.Length-want) > 1000 {
+		t.Errorf("expected length of about %.0f m, got %.0f", want, l.Length)
+	}
+	if l.Points[0].Lat < l.Points[89].Lat {
+		t.Errorf("expected the line to run clockwise from north: %v, %v", l.Points[0], l.Points[89])
+	}
+
+	// without convergence it's just a line of echoes
+	radials := testSweep(20000, 0)
+	for _, r := range radials {
+		r.VelocityData = testMoment(make([]byte, 400), 2, 129)
+	}
+	if lines := FineLines(radials, DefaultFineLineOptions); len(lines) != 0 {
+		t.Errorf("expected no lines without convergence, got %d", len(lines))
+	}
+	opts := DefaultFineLineOptions
+	opts.Convergence = 0
+	if lines := FineLines(radials, opts); len(lines) != 1 {
+		t.Errorf("expected 1 line without the velocity check, got %d", len(lines))
+	}
+}
+
+func TestTrack(t *testing.T) {
+	previous := FineLines(testSweep(20000, 0), DefaultFineLineOptions)
+	current := FineLines(testSweep(23000, 300), DefaultFineLineOptions)
+	Track(previous, current)
+
+	l := current[0]
+	if math.Abs(l.Speed-10) > 1 {
+		t.Errorf("expected the line to move 3 km in 5 minutes, got %.1f m/s", l.Speed)
+	}
+	if math.Abs(l.Direction-45) > 5 {
+		t.Errorf("expected the line to move north east, got %.0f degrees", l.Direction)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := GeoJSON(current).Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"type":"LineString"`)) || !bytes.Contains(buf.Bytes(), []byte(`"speed"`)) {
+		t.Errorf("unexpected geojson: %s", buf.String())
+	}
+}
@@ -0,0 +1,76 @@
+package detect
+
+import (
+	"math"
+
+	"github.com/kallsyms/go-nexrad/export/geojson"
+	"github.com/kallsyms/go-nexrad/geo"
+)
+
+// MaxLineSpeed is the fastest (m/s) a line can move between volumes and still
+// be matched by Track
+const MaxLineSpeed = 40
+
+// Track estimates the movement of each line since the previous volume's lines,
+// setting Speed and Direction. Each line is matched with the previous line it's
+// closest to. Its speed is the mean distance of its points from the nearest
+// points on that line, i.e. roughly perpendicular to itself, and its direction
+// is that of the mean offset.
+func Track(previous, current []*Line) {
+	for _, l := range current {
+		best := math.Inf(1)
+		for _, p := range previous {
+			dt := l.Time.Sub(p.Time).Seconds()
+			if dt <= 0 {
+				continue
+			}
+			d, dx, dy := offset(p, l)
+			if d > MaxLineSpeed*dt || d >= best {
+				continue
+			}
+			best = d
+			l.Speed = d / dt
+			l.Direction = math.Mod(math.Atan2(dx, dy)*180/math.Pi+360, 360)
+		}
+	}
+}
+
+// offset returns the mean distance, and mean east and north offsets, in meters
+// from the nearest points of from to the points of to
+func offset(from, to *Line) (d, dx, dy float64) {
+	for _, p := range to.Points {
+		nearest, bearing := math.Inf(1), 0.0
+		for _, q := range from.Points {
+			b, d := geo.BearingDistance(q.Lat, q.Lon, p.Lat, p.Lon)
+			if d < nearest {
+				nearest, bearing = d, b
+			}
+		}
+		d += nearest
+		dx += nearest * math.Sin(bearing*math.Pi/180)
+		dy += nearest * math.Cos(bearing*math.Pi/180)
+	}
+	n := float64(len(to.Points))
+	return d / n, dx / n, dy / n
+}
+
+// GeoJSON returns the lines as LineString features with their time, length (km)
+// and, when tracked, speed (m/s) and direction (degrees) properties
+func GeoJSON(lines []*Line) *geojson.FeatureCollection {
+	fc := geojson.NewFeatureCollection()
+	for _, l := range lines {
+		points := make([]geojson.Position, len(l.Points))
+		for i, p := range l.Points {
+			points[i] = geojson.Position{p.Lon, p.Lat}
+		}
+		f := geojson.NewFeature(geojson.NewLineString(points))
+		f.Properties["time"] = l.Time.UTC().Format("2006-01-02T15:04:05Z")
+		f.Properties["length"] = l.Length / 1000
+		if !math.IsNaN(l.Speed) {
+			f.Properties["speed"] = l.Speed
+			f.Properties["direction"] = l.Direction
+		}
+		fc.Features = append(fc.Features, f)
+	}
+	return fc
+}
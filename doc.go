@@ -0,0 +1,24 @@
+// Package nexrad is the root of the go-nexrad module, which decodes and
+// processes NEXRAD weather radar data. The library is split into packages:
+//
+//	archive2           decodes Level 2 (archive 2) volumes
+//	archive2/realtime  follows volumes in the realtime chunks bucket
+//	level3             decodes Level 3 (NIDS) products
+//	container          reads volumes from tar and zip containers
+//	derived            computes products derived from the base moments
+//	detect             finds features such as gust fronts
+//	geo                places radar gates in the world
+//	grid               resamples sweeps onto geographic grids
+//	render             draws sweeps as images
+//	overlay            draws map layers such as boundaries over images
+//	sites              locates the WSR-88D and TDWR radar sites
+//	delta              streams volumes to realtime clients
+//	nexradpb           serializes volumes as protocol buffers
+//	export/...         writes volumes and grids in GIS and scientific formats
+//	sink               stores outputs in local directories, S3, GCS or Azure
+//	fetch              reads volumes from the NEXRAD archive bucket
+//
+// The commands built on them are in cmd/ and can be installed with
+//
+//	go install github.com/kallsyms/go-nexrad/cmd/...
+package nexrad
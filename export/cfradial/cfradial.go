@@ -0,0 +1,306 @@
+// Package cfradial writes archive 2 volumes as CfRadial (netCDF) files.
+//
+// See https://github.com/NCAR/CfRadial for the convention. Moments are stored
+// as packed shorts using the scale and offset of the first radial they appear
+// in, and are placed on the range axis of the reflectivity moment.
+package cfradial
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+const (
+	stringLength = 32
+	fillValue    = int16(math.MinInt16)
+)
+
+type moment struct {
+	name         string
+	longName     string
+	standardName string
+	units        string
+	get          func(m *archive2.Message31) *archive2.DataMoment
+}
+
+var moments = []moment{
+	{"DBZ", "reflectivity", "equivalent_reflectivity_factor", "dBZ",
+		func(m *archive2.Message31) *archive2.DataMoment { return m.ReflectivityData }},
+	{"VEL", "radial velocity", "radial_velocity_of_scatterers_away_from_instrument", "m/s",
+		func(m *archive2.Message31) *archive2.DataMoment { return m.VelocityData }},
+	{"WIDTH", "spectrum width", "doppler_spectrum_width", "m/s",
+		func(m *archive2.Message31) *archive2.DataMoment { return m.SwData }},
+	{"ZDR", "differential reflectivity", "log_differential_reflectivity_hv", "dB",
+		func(m *archive2.Message31) *archive2.DataMoment { return m.ZdrData }},
+	{"PHIDP", "differential phase", "differential_phase_hv", "degrees",
+		func(m *archive2.Message31) *archive2.DataMoment { return m.PhiData }},
+	{"RHOHV", "correlation coefficient", "cross_correlation_ratio_hv", "unitless",
+		func(m *archive2.Message31) *archive2.DataMoment { return m.RhoData }},
+}
+
+// Write encodes the volume as a CfRadial 1.3 netCDF file
+func Write(w io.Writer, ar2 *archive2.Archive2) error {
+	var elevations []int
+	for elv := range ar2.ElevationScans {
+		elevations = append(elevations, elv)
+	}
+	sort.Ints(elevations)
+
+	var rays []*archive2.Message31
+	var sweepStart, sweepEnd, sweepNumber []int32
+	var fixedAngles []float32
+	for i, elv := range elevations {
+		radials := ar2.ElevationScans[elv]
+		if len(radials) == 0 {
+			continue
+		}
+		sweepNumber = append(sweepNumber, int32(i))
+		sweepStart = append(sweepStart, int32(len(rays)))
+		rays = append(rays, radials...)
+		sweepEnd = append(sweepEnd, int32(len(rays)-1))
+		fixedAngles = append(fixedAngles, meanElevation(radials))
+	}
+	if len(rays) == 0 {
+		return errors.New("cfradial: volume has no radials")
+	}
+
+	firstGate, gateInterval, numGates := rangeGeometry(rays)
+	start := rays[0].Header.Date()
+	end := rays[len(rays)-1].Header.Date()
+	site := rays[0].VolumeData
+	icao := string(ar2.VolumeHeader.ICAO[:])
+
+	f := &ncFile{}
+	timeDim := f.addDim("time", len(rays))
+	rangeDim := f.addDim("range", numGates)
+	sweepDim := f.addDim("sweep", len(sweepNumber))
+	stringDim := f.addDim("string_length", stringLength)
+
+	f.attrs = []ncAttr{
+		{"Conventions", "CF/Radial"},
+		{"version", "1.3"},
+		{"title", "NEXRAD Level 2 volume " + ar2.VolumeHeader.FileName()},
+		{"institution", "NOAA National Weather Service"},
+		{"source", "go-nexrad"},
+		{"instrument_name", icao},
+		{"platform_is_mobile", "false"},
+		{"time_coverage_start", start.Format(time.RFC3339)},
+		{"time_coverage_end", end.Format(time.RFC3339)},
+	}
+
+	f.addVar(&ncVar{
+		name: "time", dims: []int{timeDim}, typ: ncDouble,
+		attrs: []ncAttr{
+			{"standard_name", "time"},
+			{"long_name", "time in seconds since volume start"},
+			{"units", "seconds since " + start.Format("2006-01-02T15:04:05Z")},
+		},
+		write: func(w io.Writer) error {
+			v := make([]float64, len(rays))
+			for i, r := range rays {
+				v[i] = r.Header.Date().Sub(start).Seconds()
+			}
+			return binary.Write(w, binary.BigEndian, v)
+		},
+	})
+	f.addVar(&ncVar{
+		name: "range", dims: []int{rangeDim}, typ: ncFloat,
+		attrs: []ncAttr{
+			{"standard_name", "projection_range_coordinate"},
+			{"long_name", "range to center of measurement volume"},
+			{"units", "meters"},
+			{"meters_to_center_of_first_gate", float32(firstGate)},
+			{"meters_between_gates", float32(gateInterval)},
+		},
+		write: func(w io.Writer) error {
+			v := make([]float32, numGates)
+			for i := range v {
+				v[i] = float32(firstGate + float64(i)*gateInterval)
+			}
+			return binary.Write(w, binary.BigEndian, v)
+		},
+	})
+	f.addVar(&ncVar{
+		name: "azimuth", dims: []int{timeDim}, typ: ncFloat,
+		attrs: []ncAttr{
+			{"standard_name", "beam_azimuth_angle"},
+			{"units", "degrees"},
+		},
+		write: func(w io.Writer) error {
+			v := make([]float32, len(rays))
+			for i, r := range rays {
+				v[i] = r.Header.AzimuthAngle
+			}
+			return binary.Write(w, binary.BigEndian, v)
+		},
+	})
+	f.addVar(&ncVar{
+		name: "elevation", dims: []int{timeDim}, typ: ncFloat,
+		attrs: []ncAttr{
+			{"standard_name", "beam_elevation_angle"},
+			{"units", "degrees"},
+		},
+		write: func(w io.Writer) error {
+			v := make([]float32, len(rays))
+			for i, r := range rays {
+				v[i] = r.Header.ElevationAngle
+			}
+			return binary.Write(w, binary.BigEndian, v)
+		},
+	})
+	f.addVar(int32Var("sweep_number", sweepDim, sweepNumber))
+	f.addVar(&ncVar{
+		name: "sweep_mode", dims: []int{sweepDim, stringDim}, typ: ncChar,
+		write: func(w io.Writer) error {
+			for range sweepNumber {
+				mode := make([]byte, stringLength)
+				copy(mode, "azimuth_surveillance")
+				if _, err := w.Write(mode); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+	f.addVar(&ncVar{
+		name: "fixed_angle", dims: []int{sweepDim}, typ: ncFloat,
+		attrs: []ncAttr{{"units", "degrees"}},
+		write: func(w io.Writer) error {
+			return binary.Write(w, binary.BigEndian, fixedAngles)
+		},
+	})
+	f.addVar(int32Var("sweep_start_ray_index", sweepDim, sweepStart))
+	f.addVar(int32Var("sweep_end_ray_index", sweepDim, sweepEnd))
+	f.addVar(scalarVar("latitude", "degrees_north", float64(site.Lat)))
+	f.addVar(scalarVar("longitude", "degrees_east", float64(site.Long)))
+	f.addVar(scalarVar("altitude", "meters", float64(site.SiteHeight)+float64(site.FeedhornHeight)))
+
+	for _, m := range moments {
+		if v := momentVar(m, rays, timeDim, rangeDim, firstGate, gateInterval, numGates); v != nil {
+			f.addVar(v)
+		}
+	}
+
+	return f.writeTo(w)
+}
+
+func int32Var(name string, dim int, values []int32) *ncVar {
+	return &ncVar{
+		name: name, dims: []int{dim}, typ: ncInt,
+		write: func(w io.Writer) error {
+			return binary.Write(w, binary.BigEndian, values)
+		},
+	}
+}
+
+func scalarVar(name, units string, value float64) *ncVar {
+	return &ncVar{
+		name: name, typ: ncDouble,
+		attrs: []ncAttr{{"units", units}},
+		write: func(w io.Writer) error {
+			return binary.Write(w, binary.BigEndian, value)
+		},
+	}
+}
+
+// momentVar returns the variable for the given moment, or nil if no radial in
+// the volume contains it.
+func momentVar(m moment, rays []*archive2.Message31, timeDim, rangeDim int, firstGate, gateInterval float64, numGates int) *ncVar {
+	var first *archive2.DataMoment
+	for _, r := range rays {
+		if d := m.get(r); d != nil {
+			first = d
+			break
+		}
+	}
+	if first == nil {
+		return nil
+	}
+
+	// F = (N - offset) / scale, so the netcdf packing is the inverse
+	scaleFactor, addOffset := float32(1), float32(0)
+	if first.Scale != 0 {
+		scaleFactor = 1 / first.Scale
+		addOffset = -first.Offset / first.Scale
+	}
+
+	return &ncVar{
+		name: m.name, dims: []int{timeDim, rangeDim}, typ: ncShort,
+		attrs: []ncAttr{
+			{"long_name", m.longName},
+			{"standard_name", m.standardName},
+			{"units", m.units},
+			{"scale_factor", scaleFactor},
+			{"add_offset", addOffset},
+			{"_FillValue", fillValue},
+			{"coordinates", "elevation azimuth range"},
+		},
+		write: func(w io.Writer) error {
+			row := make([]int16, numGates)
+			for _, r := range rays {
+				for i := range row {
+					row[i] = fillValue
+				}
+				if d := m.get(r); d != nil {
+					gates := d.ScaledData()
+					dFirst := float64(d.DataMomentRange)
+					dInterval := float64(d.DataMomentRangeSampleInterval)
+					for i := range row {
+						if dInterval == 0 {
+							break
+						}
+						j := int(math.Round((firstGate + float64(i)*gateInterval - dFirst) / dInterval))
+						if j < 0 || j >= len(gates) {
+							continue
+						}
+						v := gates[j]
+						if v == archive2.MomentDataBelowThreshold || v == archive2.MomentDataFolded {
+							continue
+						}
+						packed := math.Round(float64((v - addOffset) / scaleFactor))
+						row[i] = int16(math.Max(math.MinInt16+1, math.Min(math.MaxInt16, packed)))
+					}
+				}
+				if err := binary.Write(w, binary.BigEndian, row); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// rangeGeometry returns the range to the first gate, gate spacing (in meters)
+// and number of gates for the volume, based on the reflectivity moment.
+func rangeGeometry(rays []*archive2.Message31) (float64, float64, int) {
+	var firstGate, gateInterval float64
+	numGates := 0
+	for _, r := range rays {
+		d := r.ReflectivityData
+		if d == nil {
+			continue
+		}
+		if numGates == 0 {
+			firstGate = float64(d.DataMomentRange)
+			gateInterval = float64(d.DataMomentRangeSampleInterval)
+		}
+		if n := int(d.NumberDataMomentGates); n > numGates {
+			numGates = n
+		}
+	}
+	return firstGate, gateInterval, numGates
+}
+
+func meanElevation(radials []*archive2.Message31) float32 {
+	sum := float32(0)
+	for _, r := range radials {
+		sum += r.Header.ElevationAngle
+	}
+	return sum / float32(len(radials))
+}
@@ -0,0 +1,37 @@
+package cfradial
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/kallsyms/go-nexrad/internal/testvol"
+)
+
+func TestWrite(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := Write(buf, testvol.Volume(2)); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	if string(b[:4]) != "CDF\x02" {
+		t.Fatalf("bad magic %q", b[:4])
+	}
+	if tag := binary.BigEndian.Uint32(b[8:]); tag != ncDimensionTag {
+		t.Fatalf("expected dimension list, got tag %d", tag)
+	}
+	if n := binary.BigEndian.Uint32(b[12:]); n != 4 {
+		t.Fatalf("expected 4 dimensions, got %d", n)
+	}
+
+	// the last variable is DBZ, whose last ray is the last data in the file
+	// (4 gates of shorts, no padding needed). Values are packed back to the raw
+	// archive integers.
+	last := b[len(b)-8:]
+	want := []int16{fillValue, fillValue, 66, 106}
+	for i, w := range want {
+		if got := int16(binary.BigEndian.Uint16(last[i*2:])); got != w {
+			t.Errorf("gate %d: got %d, want %d", i, got, w)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package cfradial
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// This is a minimal writer for the netCDF classic file format using 64-bit
+// offsets (CDF-2). It only supports fixed size variables, which is all CfRadial
+// needs. See https://docs.unidata.ucar.edu/netcdf-c/current/file_format_specifications.html
+
+type ncType int32
+
+const (
+	ncChar   ncType = 2
+	ncShort  ncType = 3
+	ncInt    ncType = 4
+	ncFloat  ncType = 5
+	ncDouble ncType = 6
+
+	ncDimensionTag = 0x0A
+	ncVariableTag  = 0x0B
+	ncAttributeTag = 0x0C
+)
+
+func (t ncType) size() int64 {
+	switch t {
+	case ncChar:
+		return 1
+	case ncShort:
+		return 2
+	case ncInt, ncFloat:
+		return 4
+	case ncDouble:
+		return 8
+	}
+	panic(fmt.Sprintf("cfradial: unsupported netcdf type %d", t))
+}
+
+type ncDim struct {
+	name   string
+	length int
+}
+
+// ncAttr is a named attribute. Values may be a string, int16, int32, float32,
+// float64 or a slice of any of the numeric types.
+type ncAttr struct {
+	name  string
+	value interface{}
+}
+
+type ncVar struct {
+	name  string
+	dims  []int
+	typ   ncType
+	attrs []ncAttr
+	// write must write exactly the number of bytes implied by the variable's
+	// type and dimensions, in big endian byte order.
+	write func(w io.Writer) error
+}
+
+type ncFile struct {
+	dims  []ncDim
+	attrs []ncAttr
+	vars  []*ncVar
+}
+
+func (f *ncFile) addDim(name string, length int) int {
+	f.dims = append(f.dims, ncDim{name, length})
+	return len(f.dims) - 1
+}
+
+func (f *ncFile) addVar(v *ncVar) {
+	f.vars = append(f.vars, v)
+}
+
+// dataSize is the unpadded number of bytes of data for the variable
+func (f *ncFile) dataSize(v *ncVar) int64 {
+	n := v.typ.size()
+	for _, d := range v.dims {
+		n *= int64(f.dims[d].length)
+	}
+	return n
+}
+
+func pad4(n int64) int64 {
+	return (n + 3) &^ 3
+}
+
+func (f *ncFile) writeTo(out io.Writer) error {
+	// the header size doesn't depend on the offsets written in it, so render it
+	// once to find where the data begins, then again with the real offsets.
+	header := &headerBuffer{}
+	f.writeHeader(header, make([]int64, len(f.vars)))
+
+	begins := make([]int64, len(f.vars))
+	offset := int64(len(header.buf))
+	for i, v := range f.vars {
+		begins[i] = offset
+		offset += pad4(f.dataSize(v))
+	}
+	header = &headerBuffer{}
+	f.writeHeader(header, begins)
+
+	w := bufio.NewWriter(out)
+	if _, err := w.Write(header.buf); err != nil {
+		return err
+	}
+	for _, v := range f.vars {
+		cw := &countingWriter{w: w}
+		if err := v.write(cw); err != nil {
+			return fmt.Errorf("cfradial: writing %s: %s", v.name, err)
+		}
+		size := f.dataSize(v)
+		if cw.n != size {
+			return fmt.Errorf("cfradial: variable %s wrote %d bytes, expected %d", v.name, cw.n, size)
+		}
+		if _, err := w.Write(make([]byte, pad4(size)-size)); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func (f *ncFile) writeHeader(h *headerBuffer, begins []int64) {
+	h.bytes([]byte{'C', 'D', 'F', 2})
+	// numrecs, there is no record dimension
+	h.int32(0)
+
+	if len(f.dims) == 0 {
+		h.int32(0)
+		h.int32(0)
+	} else {
+		h.int32(ncDimensionTag)
+		h.int32(int32(len(f.dims)))
+		for _, d := range f.dims {
+			h.name(d.name)
+			h.int32(int32(d.length))
+		}
+	}
+
+	h.attrs(f.attrs)
+
+	if len(f.vars) == 0 {
+		h.int32(0)
+		h.int32(0)
+		return
+	}
+	h.int32(ncVariableTag)
+	h.int32(int32(len(f.vars)))
+	for i, v := range f.vars {
+		h.name(v.name)
+		h.int32(int32(len(v.dims)))
+		for _, d := range v.dims {
+			h.int32(int32(d))
+		}
+		h.attrs(v.attrs)
+		h.int32(int32(v.typ))
+		// vsize is clamped for very large variables, readers are expected to
+		// recompute it from the dimensions.
+		vsize := pad4(f.dataSize(v))
+		if vsize > 1<<32-4 {
+			vsize = 1<<32 - 1
+		}
+		h.int32(int32(uint32(vsize)))
+		h.int64(begins[i])
+	}
+}
+
+type headerBuffer struct {
+	buf []byte
+}
+
+func (h *headerBuffer) bytes(b []byte) {
+	h.buf = append(h.buf, b...)
+}
+
+func (h *headerBuffer) int32(v int32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(v))
+	h.bytes(b[:])
+}
+
+func (h *headerBuffer) int64(v int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	h.bytes(b[:])
+}
+
+func (h *headerBuffer) padded(b []byte) {
+	h.bytes(b)
+	h.bytes(make([]byte, pad4(int64(len(b)))-int64(len(b))))
+}
+
+func (h *headerBuffer) name(s string) {
+	h.int32(int32(len(s)))
+	h.padded([]byte(s))
+}
+
+func (h *headerBuffer) attrs(attrs []ncAttr) {
+	if len(attrs) == 0 {
+		h.int32(0)
+		h.int32(0)
+		return
+	}
+	h.int32(ncAttributeTag)
+	h.int32(int32(len(attrs)))
+	for _, a := range attrs {
+		h.name(a.name)
+		typ, n, data := encodeAttr(a.value)
+		h.int32(int32(typ))
+		h.int32(int32(n))
+		h.padded(data)
+	}
+}
+
+// encodeAttr returns the netcdf type, number of elements and big endian
+// encoding of an attribute value.
+func encodeAttr(value interface{}) (ncType, int, []byte) {
+	buf := &headerBuffer{}
+	switch v := value.(type) {
+	case string:
+		return ncChar, len(v), []byte(v)
+	case int16:
+		binary.Write(writerFunc(buf.bytes), binary.BigEndian, v)
+		return ncShort, 1, buf.buf
+	case int32:
+		buf.int32(v)
+		return ncInt, 1, buf.buf
+	case float32:
+		binary.Write(writerFunc(buf.bytes), binary.BigEndian, v)
+		return ncFloat, 1, buf.buf
+	case float64:
+		binary.Write(writerFunc(buf.bytes), binary.BigEndian, v)
+		return ncDouble, 1, buf.buf
+	case []float32:
+		binary.Write(writerFunc(buf.bytes), binary.BigEndian, v)
+		return ncFloat, len(v), buf.buf
+	case []int32:
+		binary.Write(writerFunc(buf.bytes), binary.BigEndian, v)
+		return ncInt, len(v), buf.buf
+	}
+	panic(fmt.Sprintf("cfradial: unsupported attribute type %T", value))
+}
+
+type writerFunc func([]byte)
+
+func (f writerFunc) Write(b []byte) (int, error) {
+	f(b)
+	return len(b), nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,185 @@
+package geojson
+
+import (
+	"math"
+	"sort"
+
+	"github.com/kallsyms/go-nexrad/grid"
+)
+
+// Contours returns one MultiPolygon feature per threshold, covering the grid
+// cells with values greater than or equal to it. Polygons follow cell edges, so
+// the output traces the grid exactly. Each feature has a "threshold" property.
+func Contours(g *grid.Grid, thresholds []float32) *FeatureCollection {
+	fc := NewFeatureCollection()
+	for _, threshold := range thresholds {
+		mask := make([]bool, len(g.Values))
+		for i, v := range g.Values {
+			mask[i] = !math.IsNaN(float64(v)) && v >= threshold
+		}
+		polygons := tracePolygons(mask, g.Width, g.Height)
+
+		coords := make([][][]Position, len(polygons))
+		for i, rings := range polygons {
+			coords[i] = make([][]Position, len(rings))
+			for j, ring := range rings {
+				coords[i][j] = make([]Position, len(ring))
+				for k, v := range ring {
+					lat, lon := g.Location(float64(v.x), float64(v.y))
+					coords[i][j][k] = Position{lon, lat}
+				}
+			}
+		}
+		f := NewFeature(NewMultiPolygon(coords))
+		f.Properties["threshold"] = threshold
+		fc.Features = append(fc.Features, f)
+	}
+	return fc
+}
+
+// vertex is a cell corner. y increases to the south, like the grid rows.
+type vertex struct {
+	x, y int
+}
+
+type edge struct {
+	from, to vertex
+	used     bool
+}
+
+// tracePolygons returns the outlines of the masked cells as polygons (lists of
+// closed rings, exterior first). Exterior rings are counterclockwise and holes
+// clockwise, when viewed with north up.
+func tracePolygons(mask []bool, width, height int) [][][]vertex {
+	in := func(x, y int) bool {
+		return x >= 0 && y >= 0 && x < width && y < height && mask[y*width+x]
+	}
+
+	// boundary edges, directed so the masked cells are on the left (with north up)
+	outgoing := map[vertex][]*edge{}
+	var edges []*edge
+	add := func(from, to vertex) {
+		e := &edge{from: from, to: to}
+		edges = append(edges, e)
+		outgoing[from] = append(outgoing[from], e)
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if !in(x, y) {
+				continue
+			}
+			if !in(x, y+1) {
+				add(vertex{x, y + 1}, vertex{x + 1, y + 1})
+			}
+			if !in(x+1, y) {
+				add(vertex{x + 1, y + 1}, vertex{x + 1, y})
+			}
+			if !in(x, y-1) {
+				add(vertex{x + 1, y}, vertex{x, y})
+			}
+			if !in(x-1, y) {
+				add(vertex{x, y}, vertex{x, y + 1})
+			}
+		}
+	}
+
+	var exteriors, holes [][]vertex
+	for _, start := range edges {
+		if start.used {
+			continue
+		}
+		ring := []vertex{start.from}
+		e := start
+		for {
+			e.used = true
+			ring = append(ring, e.to)
+			if e.to == start.from {
+				break
+			}
+			e = nextEdge(e, outgoing[e.to])
+		}
+		ring = simplify(ring)
+		if area(ring) > 0 {
+			exteriors = append(exteriors, ring)
+		} else {
+			holes = append(holes, ring)
+		}
+	}
+
+	// smallest exteriors first, so holes are assigned to the innermost polygon
+	// containing them
+	sort.Slice(exteriors, func(i, j int) bool { return area(exteriors[i]) < area(exteriors[j]) })
+	polygons := make([][][]vertex, len(exteriors))
+	for i, ext := range exteriors {
+		polygons[i] = [][]vertex{ext}
+	}
+	for _, hole := range holes {
+		// a point just inside the hole: the right of its first edge
+		a, b := hole[0], hole[1]
+		px := float64(a.x+b.x)/2 + float64(a.y-b.y)*0.25
+		py := float64(a.y+b.y)/2 + float64(b.x-a.x)*0.25
+		for i, ext := range exteriors {
+			if contains(ext, px, py) {
+				polygons[i] = append(polygons[i], hole)
+				break
+			}
+		}
+	}
+	return polygons
+}
+
+// nextEdge picks the unused edge to follow from the end of e. Where two cells
+// touch diagonally there are two candidates; turning left keeps them as
+// separate rings.
+func nextEdge(e *edge, candidates []*edge) *edge {
+	var best *edge
+	bestTurn := -2
+	dx, dy := e.to.x-e.from.x, e.to.y-e.from.y
+	for _, c := range candidates {
+		if c.used {
+			continue
+		}
+		cx, cy := c.to.x-c.from.x, c.to.y-c.from.y
+		// cross product with y flipped to point north: >0 is a left turn
+		turn := -(dx*cy - dy*cx)
+		if turn > bestTurn {
+			best, bestTurn = c, turn
+		}
+	}
+	return best
+}
+
+// simplify removes vertices in the middle of straight runs
+func simplify(ring []vertex) []vertex {
+	out := []vertex{ring[0]}
+	for i := 1; i < len(ring)-1; i++ {
+		prev, cur, next := out[len(out)-1], ring[i], ring[i+1]
+		if (cur.x-prev.x)*(next.y-cur.y)-(cur.y-prev.y)*(next.x-cur.x) != 0 {
+			out = append(out, cur)
+		}
+	}
+	return append(out, ring[len(ring)-1])
+}
+
+// area returns the signed area of the ring, positive when counterclockwise with
+// north up.
+func area(ring []vertex) float64 {
+	sum := 0
+	for i := 0; i < len(ring)-1; i++ {
+		sum += ring[i].x*ring[i+1].y - ring[i+1].x*ring[i].y
+	}
+	// y points south, so flip the sign
+	return -float64(sum) / 2
+}
+
+func contains(ring []vertex, px, py float64) bool {
+	inside := false
+	for i := 0; i < len(ring)-1; i++ {
+		ax, ay := float64(ring[i].x), float64(ring[i].y)
+		bx, by := float64(ring[i+1].x), float64(ring[i+1].y)
+		if (ay > py) != (by > py) && px < (bx-ax)*(py-ay)/(by-ay)+ax {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,71 @@
+package geojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/kallsyms/go-nexrad/grid"
+)
+
+func TestContours(t *testing.T) {
+	nan := float32(math.NaN())
+	// a ring of 30s around a 10 (a hole at the 20 threshold), and a separate
+	// cell touching it diagonally
+	g := &grid.Grid{West: -98, North: 36, DLon: 1, DLat: 1, Width: 5, Height: 4, Values: []float32{
+		30, 30, 30, nan, nan,
+		30, 10, 30, nan, nan,
+		30, 30, 30, nan, nan,
+		nan, nan, nan, 40, nan,
+	}}
+
+	fc := Contours(g, []float32{20, 35})
+	if len(fc.Features) != 2 {
+		t.Fatalf("expected 2 features, got %d", len(fc.Features))
+	}
+
+	polygons := fc.Features[0].Geometry.Coordinates.([][][]Position)
+	if len(polygons) != 2 {
+		t.Fatalf("expected 2 polygons at 20, got %d", len(polygons))
+	}
+	// the single cell polygon sorts first
+	if len(polygons[0]) != 1 || len(polygons[0][0]) != 5 {
+		t.Errorf("expected a single square ring, got %v", polygons[0])
+	}
+	if len(polygons[1]) != 2 {
+		t.Fatalf("expected the ring to have a hole, got %v", polygons[1])
+	}
+	if want := (Position{-98, 36}); polygons[1][0][0] != want && !containsPosition(polygons[1][0], want) {
+		t.Errorf("exterior ring missing north west corner: %v", polygons[1][0])
+	}
+
+	polygons = fc.Features[1].Geometry.Coordinates.([][][]Position)
+	if len(polygons) != 1 {
+		t.Fatalf("expected 1 polygon at 35, got %d", len(polygons))
+	}
+	if !containsPosition(polygons[0][0], Position{-95, 32}) {
+		t.Errorf("unexpected polygon at 35: %v", polygons[0])
+	}
+
+	buf := &bytes.Buffer{}
+	if err := fc.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["type"] != "FeatureCollection" {
+		t.Errorf("unexpected type %v", decoded["type"])
+	}
+}
+
+func containsPosition(ring []Position, p Position) bool {
+	for _, q := range ring {
+		if q == p {
+			return true
+		}
+	}
+	return false
+}
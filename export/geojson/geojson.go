@@ -0,0 +1,57 @@
+// Package geojson encodes radar features (such as contours of gridded moments)
+// as RFC 7946 GeoJSON.
+package geojson
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// FeatureCollection is a GeoJSON feature collection
+type FeatureCollection struct {
+	Type     string     `json:"type"`
+	Features []*Feature `json:"features"`
+}
+
+// NewFeatureCollection returns an empty feature collection
+func NewFeatureCollection() *FeatureCollection {
+	return &FeatureCollection{Type: "FeatureCollection", Features: []*Feature{}}
+}
+
+// Feature is a GeoJSON feature
+type Feature struct {
+	Type       string                 `json:"type"`
+	Geometry   *Geometry              `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// NewFeature returns a feature with the given geometry and no properties
+func NewFeature(geometry *Geometry) *Feature {
+	return &Feature{Type: "Feature", Geometry: geometry, Properties: map[string]interface{}{}}
+}
+
+// Geometry is a GeoJSON geometry. Coordinates are [lon, lat] positions, nested
+// according to the geometry type.
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// Position is a [lon, lat] coordinate
+type Position [2]float64
+
+// NewMultiPolygon returns a MultiPolygon geometry. Each polygon is a list of
+// rings, the first being the exterior ring and the rest holes.
+func NewMultiPolygon(polygons [][][]Position) *Geometry {
+	return &Geometry{Type: "MultiPolygon", Coordinates: polygons}
+}
+
+// NewLineString returns a LineString geometry
+func NewLineString(points []Position) *Geometry {
+	return &Geometry{Type: "LineString", Coordinates: points}
+}
+
+// Write encodes the feature collection as JSON
+func (fc *FeatureCollection) Write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(fc)
+}
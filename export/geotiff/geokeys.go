@@ -0,0 +1,115 @@
+package geotiff
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kallsyms/go-nexrad/geo"
+)
+
+const (
+	tagGeoDoubleParams = 34736
+
+	keyModelType         = 1024
+	keyRasterType        = 1025
+	keyGeographicType    = 2048
+	keyGeodeticDatum     = 2050
+	keyAngularUnits      = 2054
+	keyEllipsoid         = 2056
+	keySemiMajorAxis     = 2057
+	keySemiMinorAxis     = 2058
+	keyProjectedCSType   = 3072
+	keyProjection        = 3074
+	keyProjCoordTrans    = 3075
+	keyProjLinearUnits   = 3076
+	keyStdParallel1      = 3078
+	keyStdParallel2      = 3079
+	keyNatOriginLong     = 3080
+	keyNatOriginLat      = 3081
+	keyFalseEasting      = 3082
+	keyFalseNorthing     = 3083
+	keyFalseOriginLong   = 3084
+	keyFalseOriginLat    = 3085
+	keyCenterLong        = 3088
+	keyCenterLat         = 3089
+	keyScaleAtNatOrigin  = 3092
+	userDefined          = 32767
+	modelTypeProjected   = 1
+	modelTypeGeographic  = 2
+	rasterPixelIsArea    = 1
+	unitDegree           = 9102
+	unitMetre            = 9001
+	transMercator        = 7
+	transLambertConic2SP = 8
+	transAzimuthalEquid  = 12
+)
+
+// geoKeys builds the GeoKeyDirectory for a grid in the projection, and the
+// values of its double valued keys. Grids without a projection are WGS 84
+// lat/lon.
+func geoKeys(proj geo.Projection) ([]uint16, []float64, error) {
+	if proj == nil {
+		return []uint16{
+			1, 1, 0, 3,
+			keyModelType, 0, 1, modelTypeGeographic,
+			keyRasterType, 0, 1, rasterPixelIsArea,
+			// WGS 84
+			keyGeographicType, 0, 1, 4326,
+		}, nil, nil
+	}
+
+	if _, ok := proj.(geo.WebMercator); ok {
+		return []uint16{
+			1, 1, 0, 3,
+			keyModelType, 0, 1, modelTypeProjected,
+			keyRasterType, 0, 1, rasterPixelIsArea,
+			// WGS 84 / Pseudo-Mercator
+			keyProjectedCSType, 0, 1, 3857,
+		}, nil, nil
+	}
+
+	var trans uint16
+	var params [][2]float64
+	switch p := proj.(type) {
+	case geo.AzimuthalEquidistant:
+		trans = transAzimuthalEquid
+		params = [][2]float64{{keyCenterLong, p.Lon0}, {keyCenterLat, p.Lat0}}
+	case geo.LambertConformal:
+		trans = transLambertConic2SP
+		params = [][2]float64{{keyStdParallel1, p.Parallel1}, {keyStdParallel2, p.Parallel2}, {keyFalseOriginLong, p.Lon0}, {keyFalseOriginLat, p.Lat0}}
+	case geo.Mercator:
+		trans = transMercator
+		params = [][2]float64{{keyNatOriginLong, p.Lon0}, {keyNatOriginLat, 0}, {keyScaleAtNatOrigin, 1}}
+	default:
+		return nil, nil, fmt.Errorf("geotiff: unsupported projection %T", proj)
+	}
+	params = append(params, [2]float64{keyFalseEasting, 0}, [2]float64{keyFalseNorthing, 0})
+
+	// projections are on a sphere of geo.EarthRadius
+	keys := [][4]uint16{
+		{keyModelType, 0, 1, modelTypeProjected},
+		{keyRasterType, 0, 1, rasterPixelIsArea},
+		{keyGeographicType, 0, 1, userDefined},
+		{keyGeodeticDatum, 0, 1, userDefined},
+		{keyAngularUnits, 0, 1, unitDegree},
+		{keyEllipsoid, 0, 1, userDefined},
+		{keyProjectedCSType, 0, 1, userDefined},
+		{keyProjection, 0, 1, userDefined},
+		{keyProjCoordTrans, 0, 1, trans},
+		{keyProjLinearUnits, 0, 1, unitMetre},
+	}
+	doubles := []float64{geo.EarthRadius, geo.EarthRadius}
+	keys = append(keys, [4]uint16{keySemiMajorAxis, tagGeoDoubleParams, 1, 0}, [4]uint16{keySemiMinorAxis, tagGeoDoubleParams, 1, 1})
+	for _, p := range params {
+		keys = append(keys, [4]uint16{uint16(p[0]), tagGeoDoubleParams, 1, uint16(len(doubles))})
+		doubles = append(doubles, p[1])
+	}
+
+	// keys must be sorted by ID
+	sort.Slice(keys, func(i, j int) bool { return keys[i][0] < keys[j][0] })
+	directory := []uint16{1, 1, 0, uint16(len(keys))}
+	for _, k := range keys {
+		directory = append(directory, k[:]...)
+	}
+	return directory, doubles, nil
+}
@@ -0,0 +1,343 @@
+// Package geotiff writes gridded radar data as single band float32 GeoTIFFs,
+// optionally using the Cloud Optimized GeoTIFF (COG) layout.
+//
+// See http://docs.opengeospatial.org/is/19-008r4/19-008r4.html and
+// https://github.com/cogeotiff/cog-spec/blob/master/spec.md
+package geotiff
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/kallsyms/go-nexrad/grid"
+)
+
+// Options controls the layout of the written file
+type Options struct {
+	// COG writes a Cloud Optimized GeoTIFF: deflate compressed tiles, reduced
+	// resolution overviews, and all metadata at the start of the file so
+	// clients can read sections with HTTP range requests.
+	COG bool
+	// TileSize is the size in pixels of COG tiles. Defaults to 256.
+	TileSize int
+}
+
+const (
+	tagNewSubfileType    = 254
+	tagImageWidth        = 256
+	tagImageLength       = 257
+	tagBitsPerSample     = 258
+	tagCompression       = 259
+	tagPhotometric       = 262
+	tagStripOffsets      = 273
+	tagSamplesPerPixel   = 277
+	tagRowsPerStrip      = 278
+	tagStripByteCounts   = 279
+	tagPlanarConfig      = 284
+	tagTileWidth         = 322
+	tagTileLength        = 323
+	tagTileOffsets       = 324
+	tagTileByteCounts    = 325
+	tagSampleFormat      = 339
+	tagModelPixelScale   = 33550
+	tagModelTiepoint     = 33922
+	tagGeoKeyDirectory   = 34735
+	tagGDALNoData        = 42113
+	compressionNone      = 1
+	compressionDeflate   = 8
+	sampleFormatFloat    = 3
+	photometricMinIsZero = 1
+
+	typeASCII  = 2
+	typeShort  = 3
+	typeLong   = 4
+	typeDouble = 12
+)
+
+// Write encodes the grid as a GeoTIFF. Cells without data are NaN, which is
+// recorded as the nodata value. Grids in a projection are written in it, on a
+// sphere of geo.EarthRadius.
+func Write(w io.Writer, g *grid.Grid, opts Options) error {
+	if g.Width == 0 || g.Height == 0 {
+		return errors.New("geotiff: empty grid")
+	}
+	if opts.TileSize == 0 {
+		opts.TileSize = 256
+	}
+	keys, doubles, err := geoKeys(g.Projection)
+	if err != nil {
+		return err
+	}
+
+	images := []*image{newImage(g.Values, g.Width, g.Height, g.DLon, g.DLat)}
+	if opts.COG {
+		// overviews halve the resolution until the whole image fits in a tile
+		for last := images[0]; last.width > opts.TileSize || last.height > opts.TileSize; {
+			last = last.overview()
+			images = append(images, last)
+		}
+	}
+
+	for _, img := range images {
+		if opts.COG {
+			img.encodeTiles(opts.TileSize)
+		} else {
+			img.encodeStrips()
+		}
+	}
+
+	// IFDs for every image first, followed by the image data from the smallest
+	// overview to the full resolution image.
+	offset := uint32(8)
+	for i, img := range images {
+		img.ifd = img.buildIFD(g, i > 0, opts, keys, doubles)
+		img.ifdOffset = offset
+		offset += img.ifd.size()
+	}
+	for i := len(images) - 1; i >= 0; i-- {
+		img := images[i]
+		img.blockOffsets = make([]uint32, len(img.blocks))
+		for j, b := range img.blocks {
+			img.blockOffsets[j] = offset
+			offset += uint32(len(b))
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	buf.Write([]byte{'I', 'I', 42, 0})
+	binary.Write(buf, binary.LittleEndian, images[0].ifdOffset)
+	for i, img := range images {
+		img.ifd.setOffsets(img.blockOffsets)
+		next := uint32(0)
+		if i+1 < len(images) {
+			next = images[i+1].ifdOffset
+		}
+		img.ifd.write(buf, img.ifdOffset, next)
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	for i := len(images) - 1; i >= 0; i-- {
+		for _, b := range images[i].blocks {
+			if _, err := w.Write(b); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type image struct {
+	values        []float32
+	width, height int
+	dlon, dlat    float64
+
+	tileSize     int
+	compressed   bool
+	blocks       [][]byte
+	blockOffsets []uint32
+	ifd          *ifd
+	ifdOffset    uint32
+}
+
+func newImage(values []float32, width, height int, dlon, dlat float64) *image {
+	return &image{values: values, width: width, height: height, dlon: dlon, dlat: dlat}
+}
+
+// overview returns the image at half resolution, averaging the cells with data
+func (img *image) overview() *image {
+	w := (img.width + 1) / 2
+	h := (img.height + 1) / 2
+	values := make([]float32, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sum, n := float32(0), 0
+			for dy := 0; dy < 2; dy++ {
+				for dx := 0; dx < 2; dx++ {
+					sx, sy := x*2+dx, y*2+dy
+					if sx >= img.width || sy >= img.height {
+						continue
+					}
+					if v := img.values[sy*img.width+sx]; !math.IsNaN(float64(v)) {
+						sum += v
+						n++
+					}
+				}
+			}
+			if n == 0 {
+				values[y*w+x] = float32(math.NaN())
+			} else {
+				values[y*w+x] = sum / float32(n)
+			}
+		}
+	}
+	return newImage(values, w, h, img.dlon*float64(img.width)/float64(w), img.dlat*float64(img.height)/float64(h))
+}
+
+func (img *image) encodeStrips() {
+	for y := 0; y < img.height; y++ {
+		row := &bytes.Buffer{}
+		binary.Write(row, binary.LittleEndian, img.values[y*img.width:(y+1)*img.width])
+		img.blocks = append(img.blocks, row.Bytes())
+	}
+}
+
+func (img *image) encodeTiles(size int) {
+	img.tileSize = size
+	img.compressed = true
+	nan := float32(math.NaN())
+	tile := make([]float32, size*size)
+	for ty := 0; ty < img.height; ty += size {
+		for tx := 0; tx < img.width; tx += size {
+			// partial tiles at the edges are padded out to the full tile size
+			for y := 0; y < size; y++ {
+				for x := 0; x < size; x++ {
+					if tx+x < img.width && ty+y < img.height {
+						tile[y*size+x] = img.values[(ty+y)*img.width+tx+x]
+					} else {
+						tile[y*size+x] = nan
+					}
+				}
+			}
+			b := &bytes.Buffer{}
+			zw := zlib.NewWriter(b)
+			binary.Write(zw, binary.LittleEndian, tile)
+			zw.Close()
+			img.blocks = append(img.blocks, b.Bytes())
+		}
+	}
+}
+
+func (img *image) buildIFD(g *grid.Grid, overview bool, opts Options, keys []uint16, doubles []float64) *ifd {
+	d := &ifd{}
+	if overview {
+		d.add(tagNewSubfileType, typeLong, uint32(1))
+	}
+	d.add(tagImageWidth, typeLong, uint32(img.width))
+	d.add(tagImageLength, typeLong, uint32(img.height))
+	d.add(tagBitsPerSample, typeShort, uint16(32))
+	if img.compressed {
+		d.add(tagCompression, typeShort, uint16(compressionDeflate))
+	} else {
+		d.add(tagCompression, typeShort, uint16(compressionNone))
+	}
+	d.add(tagPhotometric, typeShort, uint16(photometricMinIsZero))
+	d.add(tagSamplesPerPixel, typeShort, uint16(1))
+	d.add(tagPlanarConfig, typeShort, uint16(1))
+	d.add(tagSampleFormat, typeShort, uint16(sampleFormatFloat))
+
+	counts := make([]uint32, len(img.blocks))
+	for i, b := range img.blocks {
+		counts[i] = uint32(len(b))
+	}
+	if img.tileSize > 0 {
+		d.add(tagTileWidth, typeShort, uint16(img.tileSize))
+		d.add(tagTileLength, typeShort, uint16(img.tileSize))
+		d.add(tagTileOffsets, typeLong, make([]uint32, len(img.blocks)))
+		d.add(tagTileByteCounts, typeLong, counts)
+	} else {
+		d.add(tagRowsPerStrip, typeLong, uint32(1))
+		d.add(tagStripOffsets, typeLong, make([]uint32, len(img.blocks)))
+		d.add(tagStripByteCounts, typeLong, counts)
+	}
+	d.add(tagGDALNoData, typeASCII, "nan")
+
+	// overviews inherit their georeferencing from the full resolution image
+	if !overview {
+		d.add(tagModelPixelScale, typeDouble, []float64{img.dlon, img.dlat, 0})
+		d.add(tagModelTiepoint, typeDouble, []float64{0, 0, 0, g.West, g.North, 0})
+		d.add(tagGeoKeyDirectory, typeShort, keys)
+		if len(doubles) > 0 {
+			d.add(tagGeoDoubleParams, typeDouble, doubles)
+		}
+	}
+	return d
+}
+
+type ifdEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte
+}
+
+type ifd struct {
+	entries []*ifdEntry
+}
+
+func (d *ifd) add(tag, typ uint16, value interface{}) {
+	b := &bytes.Buffer{}
+	count := uint32(1)
+	switch v := value.(type) {
+	case string:
+		b.WriteString(v)
+		b.WriteByte(0)
+		count = uint32(b.Len())
+	case []uint16:
+		count = uint32(len(v))
+		binary.Write(b, binary.LittleEndian, v)
+	case []uint32:
+		count = uint32(len(v))
+		binary.Write(b, binary.LittleEndian, v)
+	case []float64:
+		count = uint32(len(v))
+		binary.Write(b, binary.LittleEndian, v)
+	default:
+		binary.Write(b, binary.LittleEndian, v)
+	}
+	d.entries = append(d.entries, &ifdEntry{tag, typ, count, b.Bytes()})
+}
+
+// setOffsets fills in the strip or tile offsets once the data layout is known
+func (d *ifd) setOffsets(offsets []uint32) {
+	for _, e := range d.entries {
+		if e.tag == tagStripOffsets || e.tag == tagTileOffsets {
+			b := &bytes.Buffer{}
+			binary.Write(b, binary.LittleEndian, offsets)
+			e.data = b.Bytes()
+		}
+	}
+}
+
+// size of the IFD including values that don't fit inline
+func (d *ifd) size() uint32 {
+	n := uint32(2 + 12*len(d.entries) + 4)
+	for _, e := range d.entries {
+		if len(e.data) > 4 {
+			n += uint32(len(e.data)+1) &^ 1
+		}
+	}
+	return n
+}
+
+func (d *ifd) write(buf *bytes.Buffer, offset, next uint32) {
+	sort.Slice(d.entries, func(i, j int) bool { return d.entries[i].tag < d.entries[j].tag })
+
+	extra := &bytes.Buffer{}
+	extraOffset := offset + uint32(2+12*len(d.entries)+4)
+	binary.Write(buf, binary.LittleEndian, uint16(len(d.entries)))
+	for _, e := range d.entries {
+		binary.Write(buf, binary.LittleEndian, e.tag)
+		binary.Write(buf, binary.LittleEndian, e.typ)
+		binary.Write(buf, binary.LittleEndian, e.count)
+		if len(e.data) <= 4 {
+			value := make([]byte, 4)
+			copy(value, e.data)
+			buf.Write(value)
+			continue
+		}
+		binary.Write(buf, binary.LittleEndian, extraOffset+uint32(extra.Len()))
+		extra.Write(e.data)
+		if extra.Len()%2 == 1 {
+			// values must start on a word boundary
+			extra.WriteByte(0)
+		}
+	}
+	binary.Write(buf, binary.LittleEndian, next)
+	buf.Write(extra.Bytes())
+}
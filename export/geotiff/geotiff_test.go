@@ -0,0 +1,166 @@
+package geotiff
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"testing"
+
+	"github.com/kallsyms/go-nexrad/geo"
+	"github.com/kallsyms/go-nexrad/grid"
+)
+
+// readIFDs returns the tags of each IFD in the file, with inline values decoded
+// as uint32s and out of line values as their offset.
+func readIFDs(t *testing.T, b []byte) []map[uint16][]uint32 {
+	if string(b[:4]) != "II*\x00" {
+		t.Fatalf("bad header %q", b[:4])
+	}
+	var ifds []map[uint16][]uint32
+	for offset := binary.LittleEndian.Uint32(b[4:]); offset != 0; {
+		n := int(binary.LittleEndian.Uint16(b[offset:]))
+		tags := map[uint16][]uint32{}
+		for i := 0; i < n; i++ {
+			e := b[int(offset)+2+i*12:]
+			tag := binary.LittleEndian.Uint16(e)
+			typ := binary.LittleEndian.Uint16(e[2:])
+			count := binary.LittleEndian.Uint32(e[4:])
+			size := map[uint16]uint32{typeASCII: 1, typeShort: 2, typeLong: 4, typeDouble: 8}[typ] * count
+			data := e[8:12]
+			if size > 4 {
+				data = b[binary.LittleEndian.Uint32(e[8:]):]
+			}
+			var values []uint32
+			for j := uint32(0); j < count; j++ {
+				switch typ {
+				case typeShort:
+					values = append(values, uint32(binary.LittleEndian.Uint16(data[j*2:])))
+				case typeLong:
+					values = append(values, binary.LittleEndian.Uint32(data[j*4:]))
+				}
+			}
+			tags[tag] = values
+		}
+		ifds = append(ifds, tags)
+		offset = binary.LittleEndian.Uint32(b[int(offset)+2+n*12:])
+	}
+	return ifds
+}
+
+func testGrid(size int) *grid.Grid {
+	g := &grid.Grid{West: -98, North: 36, DLon: 0.01, DLat: 0.01, Width: size, Height: size}
+	for i := 0; i < size*size; i++ {
+		g.Values = append(g.Values, float32(i%size))
+	}
+	g.Values[0] = float32(math.NaN())
+	return g
+}
+
+func TestWrite(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := Write(buf, testGrid(10), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	ifds := readIFDs(t, b)
+	if len(ifds) != 1 {
+		t.Fatalf("expected 1 IFD, got %d", len(ifds))
+	}
+	tags := ifds[0]
+	if tags[tagImageWidth][0] != 10 || len(tags[tagStripOffsets]) != 10 {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+	if _, ok := tags[tagGeoKeyDirectory]; !ok {
+		t.Error("missing geokeys")
+	}
+	row := b[tags[tagStripOffsets][3]:]
+	if v := math.Float32frombits(binary.LittleEndian.Uint32(row[5*4:])); v != 5 {
+		t.Errorf("expected 5 at (5, 3), got %v", v)
+	}
+}
+
+func TestWriteCOG(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := Write(buf, testGrid(300), Options{COG: true, TileSize: 128}); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	ifds := readIFDs(t, b)
+	// 300 -> 150 -> 75
+	if len(ifds) != 3 {
+		t.Fatalf("expected 3 IFDs, got %d", len(ifds))
+	}
+	full := ifds[0]
+	if len(full[tagTileOffsets]) != 9 {
+		t.Fatalf("expected 9 tiles, got %d", len(full[tagTileOffsets]))
+	}
+	// data follows all of the IFDs, smallest overview first
+	if ifds[2][tagTileOffsets][0] >= full[tagTileOffsets][0] {
+		t.Error("overview data should come before the full resolution image")
+	}
+
+	// second tile across the top row
+	offset := full[tagTileOffsets][1]
+	zr, err := zlib.NewReader(bytes.NewReader(b[offset : offset+full[tagTileByteCounts][1]]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tile, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := math.Float32frombits(binary.LittleEndian.Uint32(tile[4:])); v != 129 {
+		t.Errorf("expected 129 at (129, 0), got %v", v)
+	}
+}
+
+func TestWriteProjected(t *testing.T) {
+	g := testGrid(10)
+	g.Projection = geo.LambertConformal{Lat0: 25, Lon0: -95, Parallel1: 25, Parallel2: 25}
+	buf := &bytes.Buffer{}
+	if err := Write(buf, g, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	tags := readIFDs(t, buf.Bytes())[0]
+	keys := map[uint32][]uint32{}
+	directory := tags[tagGeoKeyDirectory]
+	for i := 4; i+3 < len(directory); i += 4 {
+		if i > 4 && directory[i] <= directory[i-4] {
+			t.Errorf("geokeys not sorted: %d after %d", directory[i], directory[i-4])
+		}
+		keys[directory[i]] = directory[i+1 : i+4]
+	}
+	if k := keys[keyModelType]; k[2] != modelTypeProjected {
+		t.Errorf("expected a projected model, got %v", k)
+	}
+	if k := keys[keyProjCoordTrans]; k[2] != transLambertConic2SP {
+		t.Errorf("expected lambert conformal conic, got %v", k)
+	}
+	if k := keys[keyStdParallel1]; k[0] != tagGeoDoubleParams {
+		t.Errorf("expected the standard parallel in the double params, got %v", k)
+	}
+	if _, ok := tags[tagGeoDoubleParams]; !ok {
+		t.Error("missing double params")
+	}
+}
+
+func TestWebMercatorKeys(t *testing.T) {
+	directory, doubles, err := geoKeys(geo.WebMercator{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doubles) != 0 {
+		t.Errorf("expected no double params, got %v", doubles)
+	}
+	found := false
+	for i := 4; i+3 < len(directory); i += 4 {
+		if directory[i] == keyProjectedCSType && directory[i+3] == 3857 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected EPSG:3857, got %v", directory)
+	}
+}
@@ -0,0 +1,306 @@
+// Package grib2 writes gridded radar products as WMO GRIB edition 2 messages,
+// for ingest into NWP and other meteorological pipelines (wgrib2, ecCodes,
+// cfgrib).
+//
+// Each field is a message on a regular latitude/longitude grid (template 3.0),
+// with an analysis product definition (template 4.0) and simple packing
+// (template 5.0). Cells without data are left out with a bitmap.
+//
+// See https://www.nco.ncep.noaa.gov/pmb/docs/grib2/grib2_doc/
+package grib2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+
+	"github.com/kallsyms/go-nexrad/grid"
+)
+
+// Parameter identifies what a field holds, from WMO code table 4.2, along with
+// the fixed surface it applies to and how precisely it's stored
+type Parameter struct {
+	Discipline uint8
+	Category   uint8
+	Number     uint8
+	// Surface is the type of the first fixed surface, from code table 4.5
+	Surface uint8
+	// DecimalScale is the number of decimal digits of the values kept
+	DecimalScale int
+}
+
+const (
+	surfaceGround           = 1
+	surfaceEntireAtmosphere = 200
+)
+
+var (
+	// CompositeReflectivity in dB
+	CompositeReflectivity = Parameter{0, 16, 5, surfaceEntireAtmosphere, 1}
+	// EchoTop height in m
+	EchoTop = Parameter{0, 16, 3, surfaceEntireAtmosphere, 0}
+	// PrecipitationRate in kg m-2 s-1 (mm/s)
+	PrecipitationRate = Parameter{0, 1, 7, surfaceGround, 7}
+)
+
+// Field is a grid to encode as a message
+type Field struct {
+	Parameter Parameter
+	// Time the data was observed at
+	Time time.Time
+	Grid *grid.Grid
+}
+
+// Write encodes each field as a message. Messages are written one after the
+// other, as GRIB files are just concatenated messages.
+func Write(w io.Writer, fields ...Field) error {
+	for _, f := range fields {
+		msg, err := encode(f)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// section accumulates the contents of a section, which is written prefixed
+// with its length and number
+type section struct {
+	bytes.Buffer
+}
+
+func (s *section) u8(v uint8)   { s.WriteByte(v) }
+func (s *section) u16(v uint16) { binary.Write(s, binary.BigEndian, v) }
+func (s *section) u32(v uint32) { binary.Write(s, binary.BigEndian, v) }
+
+// i16 and i32 write GRIB's sign and magnitude signed integers
+func (s *section) i16(v int) {
+	if v < 0 {
+		s.u16(0x8000 | uint16(-v))
+		return
+	}
+	s.u16(uint16(v))
+}
+
+func (s *section) i32(v int64) {
+	if v < 0 {
+		s.u32(0x80000000 | uint32(-v))
+		return
+	}
+	s.u32(uint32(v))
+}
+
+func (s *section) bytes(num uint8) []byte {
+	out := make([]byte, 5, 5+s.Len())
+	binary.BigEndian.PutUint32(out, uint32(5+s.Len()))
+	out[4] = num
+	return append(out, s.Bytes()...)
+}
+
+// microdegrees converts degrees to the units of grid template 3.0
+func microdegrees(deg float64) int64 {
+	return int64(math.Round(deg * 1e6))
+}
+
+func encode(f Field) ([]byte, error) {
+	g := f.Grid
+	if g == nil || g.Width*g.Height == 0 || len(g.Values) != g.Width*g.Height {
+		return nil, errors.New("grib2: empty or malformed grid")
+	}
+	if g.Projection != nil {
+		return nil, errors.New("grib2: only latitude/longitude grids are supported")
+	}
+	t := f.Time.UTC()
+
+	// section 1: identification
+	ident := &section{}
+	ident.u16(65535) // originating centre: missing
+	ident.u16(0)     // sub-centre
+	ident.u8(2)      // master tables version
+	ident.u8(0)      // local tables version
+	ident.u8(3)      // significance of reference time: observation time
+	ident.u16(uint16(t.Year()))
+	ident.u8(uint8(t.Month()))
+	ident.u8(uint8(t.Day()))
+	ident.u8(uint8(t.Hour()))
+	ident.u8(uint8(t.Minute()))
+	ident.u8(uint8(t.Second()))
+	ident.u8(0) // production status: operational
+	ident.u8(7) // type of data: processed radar observations
+
+	// section 3: grid definition, template 3.0 (latitude/longitude)
+	lat1, lon1 := g.Center(0, 0)
+	lat2, lon2 := g.Center(g.Width-1, g.Height-1)
+	gds := &section{}
+	gds.u8(0) // source: code table 3.1
+	gds.u32(uint32(g.Width * g.Height))
+	gds.u8(0)  // no optional list of points
+	gds.u8(0)  // no interpretation of the list
+	gds.u16(0) // template 3.0
+	gds.u8(5)  // shape of the earth: WGS 84
+	gds.u8(0)  // radius and axes only apply to custom shapes
+	gds.u32(0)
+	gds.u8(0)
+	gds.u32(0)
+	gds.u8(0)
+	gds.u32(0)
+	gds.u32(uint32(g.Width))
+	gds.u32(uint32(g.Height))
+	gds.u32(0)          // basic angle: degrees
+	gds.u32(0xffffffff) // subdivisions: missing, so units are microdegrees
+	gds.i32(microdegrees(lat1))
+	gds.u32(uint32(microdegrees(longitude(lon1))))
+	gds.u8(0x30) // i and j increments given
+	gds.i32(microdegrees(lat2))
+	gds.u32(uint32(microdegrees(longitude(lon2))))
+	gds.u32(uint32(microdegrees(g.DLon)))
+	gds.u32(uint32(microdegrees(g.DLat)))
+	gds.u8(0) // scanning mode: west to east, north to south, rows consecutive
+
+	// section 4: product definition, template 4.0 (analysis at a point in time)
+	p := f.Parameter
+	pds := &section{}
+	pds.u16(0) // no coordinate values
+	pds.u16(0) // template 4.0
+	pds.u8(p.Category)
+	pds.u8(p.Number)
+	pds.u8(8)   // generating process: observation
+	pds.u8(255) // background generating process: missing
+	pds.u8(255) // generating process identifier: missing
+	pds.u16(0)  // hours after cutoff
+	pds.u8(0)   // minutes after cutoff
+	pds.u8(0)   // time unit: minute
+	pds.u32(0)  // forecast time
+	pds.u8(p.Surface)
+	pds.u8(0)
+	pds.u32(0)
+	pds.u8(255) // no second surface
+	pds.u8(0)
+	pds.u32(0)
+
+	// sections 5-7: simple packing of the cells with data, and the bitmap
+	// marking which cells those are
+	drs, bms, data := pack(g.Values, p.DecimalScale)
+
+	msg := &bytes.Buffer{}
+	msg.WriteString("GRIB")
+	msg.Write([]byte{0, 0, p.Discipline, 2})
+	// total length, filled in below
+	msg.Write(make([]byte, 8))
+	msg.Write(ident.bytes(1))
+	msg.Write(gds.bytes(3))
+	msg.Write(pds.bytes(4))
+	msg.Write(drs.bytes(5))
+	msg.Write(bms.bytes(6))
+	msg.Write(data.bytes(7))
+	msg.WriteString("7777")
+
+	out := msg.Bytes()
+	binary.BigEndian.PutUint64(out[8:], uint64(len(out)))
+	return out, nil
+}
+
+// longitude normalizes lon to [0, 360), as template 3.0 longitudes are unsigned
+func longitude(lon float64) float64 {
+	lon = math.Mod(lon, 360)
+	if lon < 0 {
+		lon += 360
+	}
+	return lon
+}
+
+// pack returns the data representation, bitmap and data sections for the
+// values using simple packing: Y * 10^D = R + X, with X stored in the fewest
+// bits that hold the largest value.
+func pack(values []float32, decimalScale int) (drs, bms, data *section) {
+	factor := math.Pow(10, float64(decimalScale))
+	var scaled []float64
+	bitmap := make([]byte, (len(values)+7)/8)
+	min, max := math.Inf(1), math.Inf(-1)
+	for i, v := range values {
+		if math.IsNaN(float64(v)) {
+			continue
+		}
+		bitmap[i/8] |= 0x80 >> uint(i%8)
+		s := math.Round(float64(v) * factor)
+		scaled = append(scaled, s)
+		min = math.Min(min, s)
+		max = math.Max(max, s)
+	}
+
+	ref := float32(0)
+	bits := uint(0)
+	if len(scaled) > 0 {
+		// the reference value is stored as a float32, which must not exceed
+		// any of the values
+		ref = float32(min)
+		if float64(ref) > min {
+			ref = math.Nextafter32(ref, float32(math.Inf(-1)))
+		}
+		for max-float64(ref) >= math.Exp2(float64(bits)) {
+			bits++
+		}
+	}
+
+	drs = &section{}
+	drs.u32(uint32(len(scaled)))
+	drs.u16(0) // template 5.0
+	drs.u32(math.Float32bits(ref))
+	drs.i16(0) // binary scale factor
+	drs.i16(decimalScale)
+	drs.u8(uint8(bits))
+	drs.u8(0) // original values were floating point
+
+	bms = &section{}
+	if len(scaled) == len(values) {
+		bms.u8(255) // no bitmap
+	} else {
+		bms.u8(0)
+		bms.Write(bitmap)
+	}
+
+	data = &section{}
+	w := bitWriter{buf: &data.Buffer}
+	for _, s := range scaled {
+		w.write(uint64(math.Round(s-float64(ref))), bits)
+	}
+	w.flush()
+	return drs, bms, data
+}
+
+// bitWriter packs values most significant bit first
+type bitWriter struct {
+	buf   *bytes.Buffer
+	acc   uint64
+	nbits uint
+}
+
+func (w *bitWriter) write(v uint64, bits uint) {
+	for bits > 0 {
+		n := bits
+		if n > 8 {
+			n = 8
+		}
+		bits -= n
+		w.acc = w.acc<<n | (v>>bits)&(1<<n-1)
+		w.nbits += n
+		for w.nbits >= 8 {
+			w.nbits -= 8
+			w.buf.WriteByte(byte(w.acc >> w.nbits))
+		}
+	}
+}
+
+func (w *bitWriter) flush() {
+	if w.nbits > 0 {
+		w.buf.WriteByte(byte(w.acc << (8 - w.nbits)))
+		w.nbits = 0
+	}
+	w.acc = 0
+}
@@ -0,0 +1,123 @@
+package grib2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/kallsyms/go-nexrad/grid"
+)
+
+// sections splits a message into its sections, keyed by number
+func sections(t *testing.T, msg []byte) map[uint8][]byte {
+	if string(msg[:4]) != "GRIB" || msg[7] != 2 {
+		t.Fatalf("bad indicator section %q", msg[:16])
+	}
+	if n := binary.BigEndian.Uint64(msg[8:]); n != uint64(len(msg)) {
+		t.Fatalf("total length %d, message is %d bytes", n, len(msg))
+	}
+	secs := map[uint8][]byte{}
+	pos := 16
+	for string(msg[pos:pos+4]) != "7777" {
+		n := int(binary.BigEndian.Uint32(msg[pos:]))
+		secs[msg[pos+4]] = msg[pos : pos+n]
+		pos += n
+	}
+	if pos+4 != len(msg) {
+		t.Fatalf("end section at %d of %d", pos, len(msg))
+	}
+	return secs
+}
+
+func TestWrite(t *testing.T) {
+	nan := float32(math.NaN())
+	g := &grid.Grid{
+		West: -98, North: 36, DLon: 0.5, DLat: 0.25, Width: 3, Height: 2,
+		Values: []float32{12.5, nan, -3.2, 0, 61.7, nan},
+	}
+	ts := time.Date(2017, 8, 25, 23, 57, 33, 0, time.UTC)
+
+	var buf bytes.Buffer
+	if err := Write(&buf, Field{CompositeReflectivity, ts, g}, Field{EchoTop, ts, g}); err != nil {
+		t.Fatal(err)
+	}
+	msgs := buf.Bytes()
+	first := int(binary.BigEndian.Uint64(msgs[8:]))
+	msg := msgs[:first]
+	if string(msgs[first:first+4]) != "GRIB" {
+		t.Fatal("second message missing")
+	}
+
+	secs := sections(t, msg)
+	for num, size := range map[uint8]int{1: 21, 3: 72, 4: 34, 5: 21, 6: 7} {
+		if len(secs[num]) != size {
+			t.Errorf("section %d is %d bytes, want %d", num, len(secs[num]), size)
+		}
+	}
+
+	ident := secs[1]
+	if y := binary.BigEndian.Uint16(ident[12:]); y != 2017 || ident[14] != 8 || ident[15] != 25 || ident[16] != 23 || ident[17] != 57 || ident[18] != 33 {
+		t.Errorf("reference time % x", ident[12:19])
+	}
+
+	gds := secs[3]
+	if ni, nj := binary.BigEndian.Uint32(gds[30:]), binary.BigEndian.Uint32(gds[34:]); ni != 3 || nj != 2 {
+		t.Errorf("grid %dx%d", ni, nj)
+	}
+	if la1, lo1 := binary.BigEndian.Uint32(gds[46:]), binary.BigEndian.Uint32(gds[50:]); la1 != 35875000 || lo1 != 262250000 {
+		t.Errorf("first point %d, %d", la1, lo1)
+	}
+	if di, dj := binary.BigEndian.Uint32(gds[63:]), binary.BigEndian.Uint32(gds[67:]); di != 500000 || dj != 250000 {
+		t.Errorf("increments %d, %d", di, dj)
+	}
+
+	if pds := secs[4]; pds[9] != 16 || pds[10] != 5 || pds[22] != surfaceEntireAtmosphere {
+		t.Errorf("parameter %d/%d surface %d", pds[9], pds[10], pds[22])
+	}
+
+	// unpack the values and check they round trip to the decimal scale
+	drs := secs[5]
+	n := int(binary.BigEndian.Uint32(drs[5:]))
+	ref := float64(math.Float32frombits(binary.BigEndian.Uint32(drs[11:])))
+	d := int(binary.BigEndian.Uint16(drs[17:]))
+	bits := uint(drs[19])
+	if n != 4 || d != 1 {
+		t.Fatalf("%d values with decimal scale %d", n, d)
+	}
+	bitmap := secs[6][6]
+	if bitmap != 0xb8 {
+		t.Errorf("bitmap %08b", bitmap)
+	}
+
+	data := secs[7][5:]
+	var got []float64
+	for i := 0; i < n; i++ {
+		x := uint64(0)
+		for b := uint(0); b < bits; b++ {
+			pos := uint(i)*bits + b
+			x = x<<1 | uint64(data[pos/8]>>(7-pos%8)&1)
+		}
+		got = append(got, (ref+float64(x))/math.Pow(10, float64(d)))
+	}
+	want := []float64{12.5, -3.2, 0, 61.7}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 0.05 {
+			t.Errorf("value %d = %f, want %f", i, got[i], want[i])
+		}
+	}
+}
+
+func TestPackConstant(t *testing.T) {
+	drs, bms, data := pack([]float32{7, 7, 7}, 0)
+	if bits := drs.Bytes()[14]; bits != 0 {
+		t.Errorf("constant field packed with %d bits", bits)
+	}
+	if bms.Bytes()[0] != 255 {
+		t.Errorf("bitmap indicator %d for a field without missing values", bms.Bytes()[0])
+	}
+	if data.Len() != 0 {
+		t.Errorf("%d bytes of data for a constant field", data.Len())
+	}
+}
@@ -0,0 +1,401 @@
+package odim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// This is a minimal HDF5 writer, producing files using the original (1.6
+// compatible) format: a version 0 superblock, version 1 object headers and
+// groups indexed with symbol tables. Only what ODIM needs is supported: nested
+// groups, contiguous unsigned integer datasets, and scalar string, float64 and
+// int64 attributes. See https://docs.hdfgroup.org/hdf5/develop/_f_m_t3.html
+
+const (
+	undefinedAddress = 0xffffffffffffffff
+
+	// a symbol table node holds 2*groupLeafK entries, and a group's B-tree node
+	// can point to 2*groupInternalK symbol table nodes.
+	groupLeafK     = 4
+	groupInternalK = 16
+
+	superblockSize  = 96
+	symbolEntrySize = 40
+
+	msgDataspace   = 0x0001
+	msgDatatype    = 0x0003
+	msgFillValue   = 0x0005
+	msgLayout      = 0x0008
+	msgAttribute   = 0x000C
+	msgSymbolTable = 0x0011
+)
+
+type h5Attr struct {
+	name string
+	// value is a string, float64 or int64
+	value interface{}
+}
+
+type h5Group struct {
+	name     string
+	attrs    []h5Attr
+	groups   []*h5Group
+	datasets []*h5Dataset
+}
+
+func (g *h5Group) group(name string, attrs ...h5Attr) *h5Group {
+	child := &h5Group{name: name, attrs: attrs}
+	g.groups = append(g.groups, child)
+	return child
+}
+
+type h5Dataset struct {
+	name  string
+	attrs []h5Attr
+	dims  []uint64
+	// elemSize is the size in bytes of the unsigned integer elements
+	elemSize int
+	// write must write the little endian elements of the dataset in row major order
+	write func(w io.Writer) error
+}
+
+type h5Writer struct {
+	w   io.WriteSeeker
+	off uint64
+}
+
+// writeHDF5 writes the file with the given root group
+func writeHDF5(w io.WriteSeeker, root *h5Group) error {
+	h := &h5Writer{w: w}
+	// the superblock is written last, once the root group's address is known
+	if _, err := h.append(make([]byte, superblockSize)); err != nil {
+		return err
+	}
+	rootAddr, btree, heap, err := h.writeGroup(root)
+	if err != nil {
+		return err
+	}
+
+	sb := &bytes.Buffer{}
+	sb.Write([]byte{0x89, 'H', 'D', 'F', '\r', '\n', 0x1a, '\n'})
+	// superblock, free-space, root group symbol table entry, reserved, shared
+	// header versions, size of offsets and lengths, reserved
+	sb.Write([]byte{0, 0, 0, 0, 0, 8, 8, 0})
+	le(sb, uint16(groupLeafK))
+	le(sb, uint16(groupInternalK))
+	le(sb, uint32(0))
+	le(sb, uint64(0))
+	le(sb, uint64(undefinedAddress))
+	le(sb, h.off)
+	le(sb, uint64(undefinedAddress))
+	writeSymbolEntry(sb, 0, rootAddr, btree, heap)
+
+	if _, err := w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := w.Write(sb.Bytes()); err != nil {
+		return err
+	}
+	_, err = w.Seek(int64(h.off), io.SeekStart)
+	return err
+}
+
+func le(w io.Writer, v interface{}) {
+	binary.Write(w, binary.LittleEndian, v)
+}
+
+func pad8(n int) int {
+	return (n + 7) &^ 7
+}
+
+// append writes b at the end of the file, returning its address
+func (h *h5Writer) append(b []byte) (uint64, error) {
+	addr := h.off
+	n, err := h.w.Write(b)
+	h.off += uint64(n)
+	return addr, err
+}
+
+func writeSymbolEntry(w *bytes.Buffer, nameOffset, objectHeader, btree, heap uint64) {
+	le(w, nameOffset)
+	le(w, objectHeader)
+	if btree == 0 {
+		le(w, uint32(0))
+		le(w, uint32(0))
+		w.Write(make([]byte, 16))
+		return
+	}
+	// cache type 1 holds the group's B-tree and heap addresses
+	le(w, uint32(1))
+	le(w, uint32(0))
+	le(w, btree)
+	le(w, heap)
+}
+
+type h5Link struct {
+	name string
+	addr uint64
+}
+
+// writeGroup writes the group's children, symbol table and object header. It
+// returns the object header, B-tree and local heap addresses.
+func (h *h5Writer) writeGroup(g *h5Group) (uint64, uint64, uint64, error) {
+	var links []h5Link
+	for _, ds := range g.datasets {
+		addr, err := h.writeDataset(ds)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		links = append(links, h5Link{ds.name, addr})
+	}
+	for _, child := range g.groups {
+		addr, _, _, err := h.writeGroup(child)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		links = append(links, h5Link{child.name, addr})
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].name < links[j].name })
+
+	// local heap holding the link names. Offset 0 must be the empty string.
+	heapData := &bytes.Buffer{}
+	heapData.Write(make([]byte, 8))
+	nameOffsets := make([]uint64, len(links))
+	for i, l := range links {
+		nameOffsets[i] = uint64(heapData.Len())
+		heapData.WriteString(l.name)
+		heapData.Write(make([]byte, pad8(len(l.name)+1)-len(l.name)))
+	}
+	heapDataAddr, err := h.append(heapData.Bytes())
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	heap := &bytes.Buffer{}
+	heap.WriteString("HEAP")
+	heap.Write([]byte{0, 0, 0, 0})
+	le(heap, uint64(heapData.Len()))
+	le(heap, uint64(undefinedAddress))
+	le(heap, heapDataAddr)
+	heapAddr, err := h.append(heap.Bytes())
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	// symbol table nodes, each holding up to 2*groupLeafK sorted entries
+	perNode := 2 * groupLeafK
+	var nodeAddrs []uint64
+	var nodeLastNames []uint64
+	for start := 0; start < len(links) || start == 0; start += perNode {
+		end := start + perNode
+		if end > len(links) {
+			end = len(links)
+		}
+		node := &bytes.Buffer{}
+		node.WriteString("SNOD")
+		node.Write([]byte{1, 0})
+		le(node, uint16(end-start))
+		for i := start; i < end; i++ {
+			writeSymbolEntry(node, nameOffsets[i], links[i].addr, 0, 0)
+		}
+		node.Write(make([]byte, (perNode-(end-start))*symbolEntrySize))
+		addr, err := h.append(node.Bytes())
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		nodeAddrs = append(nodeAddrs, addr)
+		last := uint64(0)
+		if end > start {
+			last = nameOffsets[end-1]
+		}
+		nodeLastNames = append(nodeLastNames, last)
+		if len(links) == 0 {
+			break
+		}
+	}
+	if len(nodeAddrs) > 2*groupInternalK {
+		return 0, 0, 0, fmt.Errorf("odim: group %s has too many members (%d)", g.name, len(links))
+	}
+
+	// a single leaf B-tree node pointing at the symbol table nodes. The key
+	// after each child is the heap offset of the last name in that child.
+	btree := &bytes.Buffer{}
+	btree.WriteString("TREE")
+	btree.Write([]byte{0, 0})
+	le(btree, uint16(len(nodeAddrs)))
+	le(btree, uint64(undefinedAddress))
+	le(btree, uint64(undefinedAddress))
+	le(btree, uint64(0))
+	for i, addr := range nodeAddrs {
+		le(btree, addr)
+		le(btree, nodeLastNames[i])
+	}
+	btree.Write(make([]byte, (2*groupInternalK-len(nodeAddrs))*16))
+	btreeAddr, err := h.append(btree.Bytes())
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	symbolTable := &bytes.Buffer{}
+	le(symbolTable, btreeAddr)
+	le(symbolTable, heapAddr)
+	msgs := []h5Message{{msgSymbolTable, symbolTable.Bytes()}}
+	msgs = append(msgs, attributeMessages(g.attrs)...)
+	addr, err := h.writeObjectHeader(msgs)
+	return addr, btreeAddr, heapAddr, err
+}
+
+func (h *h5Writer) writeDataset(ds *h5Dataset) (uint64, error) {
+	size := uint64(ds.elemSize)
+	for _, d := range ds.dims {
+		size *= d
+	}
+	dataAddr := h.off
+	cw := &countingWriter{w: h.w}
+	if err := ds.write(cw); err != nil {
+		return 0, fmt.Errorf("odim: writing %s: %s", ds.name, err)
+	}
+	h.off += cw.n
+	if cw.n != size {
+		return 0, fmt.Errorf("odim: dataset %s wrote %d bytes, expected %d", ds.name, cw.n, size)
+	}
+
+	layout := &bytes.Buffer{}
+	// version 3, contiguous storage
+	layout.Write([]byte{3, 1})
+	le(layout, dataAddr)
+	le(layout, size)
+
+	msgs := []h5Message{
+		{msgDataspace, dataspace(ds.dims)},
+		{msgDatatype, uintDatatype(ds.elemSize, false)},
+		// version 2, early allocation, never written, no fill value defined
+		{msgFillValue, []byte{2, 1, 2, 0}},
+		{msgLayout, layout.Bytes()},
+	}
+	msgs = append(msgs, attributeMessages(ds.attrs)...)
+	return h.writeObjectHeader(msgs)
+}
+
+type h5Message struct {
+	typ  uint16
+	data []byte
+}
+
+func (h *h5Writer) writeObjectHeader(msgs []h5Message) (uint64, error) {
+	body := &bytes.Buffer{}
+	for _, m := range msgs {
+		le(body, m.typ)
+		le(body, uint16(pad8(len(m.data))))
+		body.Write([]byte{0, 0, 0, 0})
+		body.Write(m.data)
+		body.Write(make([]byte, pad8(len(m.data))-len(m.data)))
+	}
+
+	oh := &bytes.Buffer{}
+	oh.Write([]byte{1, 0})
+	le(oh, uint16(len(msgs)))
+	// reference count, header size and padding to align the messages
+	le(oh, uint32(1))
+	le(oh, uint32(body.Len()))
+	oh.Write([]byte{0, 0, 0, 0})
+	oh.Write(body.Bytes())
+	return h.append(oh.Bytes())
+}
+
+func dataspace(dims []uint64) []byte {
+	b := &bytes.Buffer{}
+	b.Write([]byte{1, byte(len(dims)), 0, 0, 0, 0, 0, 0})
+	for _, d := range dims {
+		le(b, d)
+	}
+	return b.Bytes()
+}
+
+func uintDatatype(size int, signed bool) []byte {
+	b := &bytes.Buffer{}
+	flags := byte(0)
+	if signed {
+		flags = 0x08
+	}
+	// version 1, fixed point class; little endian
+	b.Write([]byte{0x10, flags, 0, 0})
+	le(b, uint32(size))
+	le(b, uint16(0))
+	le(b, uint16(size*8))
+	return b.Bytes()
+}
+
+func float64Datatype() []byte {
+	b := &bytes.Buffer{}
+	// version 1, floating point class; little endian, implied mantissa msb,
+	// sign at bit 63
+	b.Write([]byte{0x11, 0x20, 63, 0})
+	le(b, uint32(8))
+	le(b, uint16(0))
+	le(b, uint16(64))
+	b.Write([]byte{52, 11, 0, 52})
+	le(b, uint32(1023))
+	return b.Bytes()
+}
+
+func stringDatatype(size int) []byte {
+	b := &bytes.Buffer{}
+	// version 1, string class; null terminated ASCII
+	b.Write([]byte{0x13, 0, 0, 0})
+	le(b, uint32(size))
+	return b.Bytes()
+}
+
+func attributeMessages(attrs []h5Attr) []h5Message {
+	var msgs []h5Message
+	for _, a := range attrs {
+		var dtype, value []byte
+		switch v := a.value.(type) {
+		case string:
+			dtype = stringDatatype(len(v) + 1)
+			value = append([]byte(v), 0)
+		case float64:
+			dtype = float64Datatype()
+			buf := &bytes.Buffer{}
+			le(buf, v)
+			value = buf.Bytes()
+		case int64:
+			dtype = uintDatatype(8, true)
+			buf := &bytes.Buffer{}
+			le(buf, v)
+			value = buf.Bytes()
+		default:
+			panic(fmt.Sprintf("odim: unsupported attribute type %T", a.value))
+		}
+		space := dataspace(nil)
+
+		b := &bytes.Buffer{}
+		b.Write([]byte{1, 0})
+		le(b, uint16(len(a.name)+1))
+		le(b, uint16(len(dtype)))
+		le(b, uint16(len(space)))
+		b.WriteString(a.name)
+		b.Write(make([]byte, pad8(len(a.name)+1)-len(a.name)))
+		b.Write(dtype)
+		b.Write(make([]byte, pad8(len(dtype))-len(dtype)))
+		b.Write(space)
+		b.Write(make([]byte, pad8(len(space))-len(space)))
+		b.Write(value)
+		msgs = append(msgs, h5Message{msgAttribute, b.Bytes()})
+	}
+	return msgs
+}
+
+type countingWriter struct {
+	w io.Writer
+	n uint64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += uint64(n)
+	return n, err
+}
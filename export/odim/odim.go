@@ -0,0 +1,261 @@
+// Package odim writes archive 2 volumes as ODIM_H5 polar volumes, the OPERA
+// data information model used by BALTRAD, Rainbow and wradlib.
+//
+// Each elevation scan becomes a dataset with radials binned to their nominal
+// azimuths, starting from north. Moments are stored as their raw archive
+// integers with the matching gain and offset. See
+// https://www.eumetnet.eu/wp-content/uploads/2017/01/OPERA_hdf_description_2014.pdf
+package odim
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+const (
+	// raw values used for gates without data. These line up with the archive 2
+	// values for below threshold and range folded.
+	undetect = 0
+	nodata   = 1
+)
+
+type quantity struct {
+	name string
+	get  func(m *archive2.Message31) *archive2.DataMoment
+}
+
+var quantities = []quantity{
+	{"DBZH", func(m *archive2.Message31) *archive2.DataMoment { return m.ReflectivityData }},
+	{"VRADH", func(m *archive2.Message31) *archive2.DataMoment { return m.VelocityData }},
+	{"WRADH", func(m *archive2.Message31) *archive2.DataMoment { return m.SwData }},
+	{"ZDR", func(m *archive2.Message31) *archive2.DataMoment { return m.ZdrData }},
+	{"PHIDP", func(m *archive2.Message31) *archive2.DataMoment { return m.PhiData }},
+	{"RHOHV", func(m *archive2.Message31) *archive2.DataMoment { return m.RhoData }},
+}
+
+// Write encodes the volume as an ODIM_H5 2.2 polar volume (PVOL). HDF5 files
+// are written out of order, so w must be seekable.
+func Write(w io.WriteSeeker, ar2 *archive2.Archive2) error {
+	var elevations []int
+	for elv, radials := range ar2.ElevationScans {
+		if len(radials) > 0 {
+			elevations = append(elevations, elv)
+		}
+	}
+	if len(elevations) == 0 {
+		return errors.New("odim: volume has no radials")
+	}
+	sort.Ints(elevations)
+
+	first := ar2.ElevationScans[elevations[0]][0]
+	last := ar2.ElevationScans[elevations[len(elevations)-1]]
+	start := first.Header.Date()
+	end := last[len(last)-1].Header.Date()
+	icao := string(ar2.VolumeHeader.ICAO[:])
+
+	root := &h5Group{attrs: []h5Attr{{"Conventions", "ODIM_H5/V2_2"}}}
+	root.group("what",
+		h5Attr{"object", "PVOL"},
+		h5Attr{"version", "H5rad 2.2"},
+		h5Attr{"date", start.Format("20060102")},
+		h5Attr{"time", start.Format("150405")},
+		h5Attr{"source", fmt.Sprintf("NOD:%s,PLC:%s", strings.ToLower(icao), icao)},
+	)
+	root.group("where",
+		h5Attr{"lat", float64(first.VolumeData.Lat)},
+		h5Attr{"lon", float64(first.VolumeData.Long)},
+		h5Attr{"height", float64(first.VolumeData.SiteHeight) + float64(first.VolumeData.FeedhornHeight)},
+	)
+	root.group("how",
+		h5Attr{"system", "NEXRAD"},
+		h5Attr{"software", "go-nexrad"},
+		h5Attr{"startepochs", float64(start.Unix())},
+		h5Attr{"endepochs", float64(end.Unix())},
+		h5Attr{"beamwH", 0.95},
+		h5Attr{"beamwV", 0.95},
+	)
+
+	for i, elv := range elevations {
+		addSweep(root, fmt.Sprintf("dataset%d", i+1), ar2.ElevationScans[elv])
+	}
+
+	return writeHDF5(w, root)
+}
+
+func addSweep(root *h5Group, name string, radials []*archive2.Message31) {
+	sweepStart := radials[0].Header.Date()
+	sweepEnd := radials[len(radials)-1].Header.Date()
+
+	// bin the radials to their nominal azimuths. ODIM rays start at north and
+	// a1gate is the index of the first radial collected.
+	spacing := radials[0].Header.AzimuthResolutionSpacing()
+	nrays := int(math.Round(360 / spacing))
+	rays := make([]*archive2.Message31, nrays)
+	for _, r := range radials {
+		rays[rayIndex(r.Header.AzimuthAngle, spacing, nrays)] = r
+	}
+	a1gate := rayIndex(radials[0].Header.AzimuthAngle, spacing, nrays)
+
+	elevation := 0.0
+	for _, r := range radials {
+		elevation += float64(r.Header.ElevationAngle)
+	}
+	elevation /= float64(len(radials))
+
+	// moments are placed on the range axis of reflectivity if it's present,
+	// otherwise of the first moment in the sweep.
+	var geometry *archive2.DataMoment
+	for _, q := range quantities {
+		if geometry = firstMoment(q, radials); geometry != nil {
+			break
+		}
+	}
+	if geometry == nil {
+		return
+	}
+	nbins := 0
+	for _, r := range radials {
+		for _, q := range quantities {
+			if d := q.get(r); d != nil && d.DataMomentRange == geometry.DataMomentRange &&
+				d.DataMomentRangeSampleInterval == geometry.DataMomentRangeSampleInterval {
+				if n := int(d.NumberDataMomentGates); n > nbins {
+					nbins = n
+				}
+			}
+		}
+	}
+	rscale := float64(geometry.DataMomentRangeSampleInterval)
+	// rstart is the range to the start of the first bin, not its center
+	rstart := (float64(geometry.DataMomentRange) - rscale/2) / 1000
+
+	ds := root.group(name)
+	ds.group("what",
+		h5Attr{"product", "SCAN"},
+		h5Attr{"startdate", sweepStart.Format("20060102")},
+		h5Attr{"starttime", sweepStart.Format("150405")},
+		h5Attr{"enddate", sweepEnd.Format("20060102")},
+		h5Attr{"endtime", sweepEnd.Format("150405")},
+	)
+	ds.group("where",
+		h5Attr{"elangle", elevation},
+		h5Attr{"nbins", int64(nbins)},
+		h5Attr{"rstart", rstart},
+		h5Attr{"rscale", rscale},
+		h5Attr{"nrays", int64(nrays)},
+		h5Attr{"a1gate", int64(a1gate)},
+	)
+
+	n := 0
+	for _, q := range quantities {
+		m := firstMoment(q, radials)
+		if m == nil {
+			continue
+		}
+		n++
+		gain, offset := 1.0, 0.0
+		if m.Scale != 0 {
+			gain = 1 / float64(m.Scale)
+			offset = -float64(m.Offset) / float64(m.Scale)
+		}
+		data := ds.group(fmt.Sprintf("data%d", n))
+		data.group("what",
+			h5Attr{"quantity", q.name},
+			h5Attr{"gain", gain},
+			h5Attr{"offset", offset},
+			h5Attr{"nodata", float64(nodata)},
+			h5Attr{"undetect", float64(undetect)},
+		)
+		elemSize := int(m.DataWordSize) / 8
+		if elemSize != 2 {
+			elemSize = 1
+		}
+		data.datasets = append(data.datasets, &h5Dataset{
+			name: "data",
+			attrs: []h5Attr{
+				{"CLASS", "IMAGE"},
+				{"IMAGE_VERSION", "1.2"},
+			},
+			dims:     []uint64{uint64(nrays), uint64(nbins)},
+			elemSize: elemSize,
+			write:    momentWriter(q, m, rays, geometry, nbins, elemSize),
+		})
+	}
+}
+
+// momentWriter returns a function writing the raw moment values for each ray,
+// repacked to the scale and offset of ref and placed on the range axis of
+// geometry.
+func momentWriter(q quantity, ref *archive2.DataMoment, rays []*archive2.Message31, geometry *archive2.DataMoment, nbins, elemSize int) func(io.Writer) error {
+	maxValue := float64(math.MaxUint8)
+	if elemSize == 2 {
+		maxValue = math.MaxUint16
+	}
+	firstGate := float64(geometry.DataMomentRange)
+	gateInterval := float64(geometry.DataMomentRangeSampleInterval)
+
+	return func(w io.Writer) error {
+		row := make([]uint16, nbins)
+		buf := make([]byte, nbins*elemSize)
+		for _, ray := range rays {
+			for i := range row {
+				row[i] = nodata
+			}
+			if ray != nil {
+				if d := q.get(ray); d != nil && d.DataMomentRangeSampleInterval != 0 {
+					gates := d.ScaledData()
+					for i := range row {
+						j := int(math.Round((firstGate + float64(i)*gateInterval - float64(d.DataMomentRange)) / float64(d.DataMomentRangeSampleInterval)))
+						if j < 0 || j >= len(gates) {
+							continue
+						}
+						switch v := gates[j]; v {
+						case archive2.MomentDataBelowThreshold:
+							row[i] = undetect
+						case archive2.MomentDataFolded:
+							row[i] = nodata
+						default:
+							n := float64(v)
+							if ref.Scale != 0 {
+								n = float64(v*ref.Scale + ref.Offset)
+							}
+							// keep clear of the reserved values
+							row[i] = uint16(math.Max(2, math.Min(maxValue, math.Round(n))))
+						}
+					}
+				}
+			}
+			for i, v := range row {
+				if elemSize == 2 {
+					binary.LittleEndian.PutUint16(buf[i*2:], v)
+				} else {
+					buf[i] = byte(v)
+				}
+			}
+			if _, err := w.Write(buf); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func firstMoment(q quantity, radials []*archive2.Message31) *archive2.DataMoment {
+	for _, r := range radials {
+		if d := q.get(r); d != nil {
+			return d
+		}
+	}
+	return nil
+}
+
+func rayIndex(azimuth float32, spacing float64, nrays int) int {
+	i := int(math.Floor(float64(azimuth) / spacing))
+	return ((i % nrays) + nrays) % nrays
+}
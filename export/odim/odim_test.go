@@ -0,0 +1,55 @@
+package odim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/kallsyms/go-nexrad/internal/testvol"
+)
+
+func TestWrite(t *testing.T) {
+	f, err := ioutil.TempFile("", "odim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := Write(f, testvol.Volume(12)); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(b, []byte("\x89HDF\r\n\x1a\n")) {
+		t.Fatalf("missing HDF5 signature")
+	}
+	if eof := binary.LittleEndian.Uint64(b[40:]); eof != uint64(len(b)) {
+		t.Errorf("superblock end of file address %d, file is %d bytes", eof, len(b))
+	}
+	// root group symbol table entry, which must point at a group object header
+	rootHeader := binary.LittleEndian.Uint64(b[64:])
+	if rootHeader >= uint64(len(b)) || b[rootHeader] != 1 {
+		t.Fatalf("bad root object header address %d", rootHeader)
+	}
+	// the root group has 15 members (what, where, how and 12 datasets) so
+	// needs two symbol table nodes
+	btree := binary.LittleEndian.Uint64(b[80:])
+	if string(b[btree:btree+4]) != "TREE" {
+		t.Fatalf("bad root B-tree address %d", btree)
+	}
+	if n := binary.LittleEndian.Uint16(b[btree+6:]); n != 2 {
+		t.Errorf("expected 2 symbol table nodes, got %d", n)
+	}
+
+	for _, s := range []string{"ODIM_H5/V2_2", "PVOL", "DBZH", "dataset12"} {
+		if !bytes.Contains(b, []byte(s)) {
+			t.Errorf("output is missing %q", s)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"sort"
+)
+
+// parquet physical types
+const (
+	typeInt64  = 2
+	typeFloat  = 4
+	typeDouble = 5
+)
+
+const (
+	repetitionRequired = 0
+	repetitionOptional = 1
+
+	convertedTimestampMicros = 10
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecGzip = 2
+
+	pageTypeData = 0
+)
+
+const magic = "PAR1"
+
+// column is a flat column in the table schema
+type column struct {
+	name      string
+	typ       int32
+	optional  bool
+	converted int32
+}
+
+// chunk is an encoded column chunk in a row group
+type chunk struct {
+	offset           int64
+	numValues        int64
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+type rowGroup struct {
+	numRows int64
+	size    int64
+	chunks  []chunk
+}
+
+// fileWriter writes a parquet file of flat columns, one data page per column
+// chunk. Values are PLAIN encoded and pages gzip compressed. See
+// https://github.com/apache/parquet-format
+type fileWriter struct {
+	w         io.Writer
+	offset    int64
+	columns   []column
+	rowGroups []rowGroup
+	numRows   int64
+	metadata  map[string]string
+}
+
+func newFileWriter(w io.Writer, columns []column, metadata map[string]string) (*fileWriter, error) {
+	fw := &fileWriter{w: w, columns: columns, metadata: metadata}
+	return fw, fw.write([]byte(magic))
+}
+
+func (fw *fileWriter) write(b []byte) error {
+	n, err := fw.w.Write(b)
+	fw.offset += int64(n)
+	return err
+}
+
+// writeRowGroup writes a row group. values has a slice per column of []int64,
+// []float32 or []float64 holding the non-null values, and defined has, for
+// optional columns, whether each row is non-null.
+func (fw *fileWriter) writeRowGroup(numRows int, values []interface{}, defined [][]bool) error {
+	rg := rowGroup{numRows: int64(numRows)}
+	for i, c := range fw.columns {
+		page := &bytes.Buffer{}
+		if c.optional {
+			levels := encodeLevels(defined[i])
+			binary.Write(page, binary.LittleEndian, uint32(len(levels)))
+			page.Write(levels)
+		}
+		binary.Write(page, binary.LittleEndian, values[i])
+
+		compressed := &bytes.Buffer{}
+		zw := gzip.NewWriter(compressed)
+		zw.Write(page.Bytes())
+		if err := zw.Close(); err != nil {
+			return err
+		}
+
+		header := &thriftWriter{}
+		header.structBegin()
+		header.i32(1, pageTypeData)
+		header.i32(2, int32(page.Len()))
+		header.i32(3, int32(compressed.Len()))
+		header.structField(5, func() {
+			header.i32(1, int32(numRows))
+			header.i32(2, encodingPlain)
+			header.i32(3, encodingRLE)
+			header.i32(4, encodingRLE)
+		})
+		header.structEnd()
+
+		ch := chunk{
+			offset:           fw.offset,
+			numValues:        int64(numRows),
+			uncompressedSize: int64(header.buf.Len() + page.Len()),
+			compressedSize:   int64(header.buf.Len() + compressed.Len()),
+		}
+		if err := fw.write(header.buf.Bytes()); err != nil {
+			return err
+		}
+		if err := fw.write(compressed.Bytes()); err != nil {
+			return err
+		}
+		rg.chunks = append(rg.chunks, ch)
+		rg.size += ch.uncompressedSize
+	}
+	fw.rowGroups = append(fw.rowGroups, rg)
+	fw.numRows += int64(numRows)
+	return nil
+}
+
+// encodeLevels encodes definition levels (0 for null, 1 for defined) as a
+// single bit packed run of the RLE/bit packing hybrid encoding
+func encodeLevels(defined []bool) []byte {
+	groups := (len(defined) + 7) / 8
+	b := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+groups)
+	b = append(b[:binary.PutUvarint(b, uint64(groups)<<1|1)], make([]byte, groups)...)
+	start := len(b) - groups
+	for i, d := range defined {
+		if d {
+			b[start+i/8] |= 1 << uint(i%8)
+		}
+	}
+	return b
+}
+
+// close writes the file footer
+func (fw *fileWriter) close() error {
+	t := &thriftWriter{}
+	t.structBegin()
+	t.i32(1, 1)
+	t.structList(2, len(fw.columns)+1, func(i int) {
+		if i == 0 {
+			t.string(4, "schema")
+			t.i32(5, int32(len(fw.columns)))
+			return
+		}
+		c := fw.columns[i-1]
+		t.i32(1, c.typ)
+		if c.optional {
+			t.i32(3, repetitionOptional)
+		} else {
+			t.i32(3, repetitionRequired)
+		}
+		t.string(4, c.name)
+		if c.converted != 0 {
+			t.i32(6, c.converted)
+		}
+	})
+	t.i64(3, fw.numRows)
+	t.structList(4, len(fw.rowGroups), func(i int) {
+		rg := fw.rowGroups[i]
+		t.structList(1, len(rg.chunks), func(j int) {
+			ch := rg.chunks[j]
+			c := fw.columns[j]
+			t.i64(2, ch.offset)
+			t.structField(3, func() {
+				t.i32(1, c.typ)
+				t.i32List(2, []int32{encodingPlain, encodingRLE})
+				t.stringList(3, []string{c.name})
+				t.i32(4, codecGzip)
+				t.i64(5, ch.numValues)
+				t.i64(6, ch.uncompressedSize)
+				t.i64(7, ch.compressedSize)
+				t.i64(9, ch.offset)
+			})
+		})
+		t.i64(2, rg.size)
+		t.i64(3, rg.numRows)
+	})
+	var keys []string
+	for k := range fw.metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	t.structList(5, len(keys), func(i int) {
+		t.string(1, keys[i])
+		t.string(2, fw.metadata[keys[i]])
+	})
+	t.string(6, "go-nexrad")
+	t.structEnd()
+
+	if err := fw.write(t.buf.Bytes()); err != nil {
+		return err
+	}
+	footer := make([]byte, 4, 8)
+	binary.LittleEndian.PutUint32(footer, uint32(t.buf.Len()))
+	return fw.write(append(footer, magic...))
+}
@@ -0,0 +1,170 @@
+// Package parquet flattens archive 2 volumes into Parquet tables with a row per
+// gate, for analytics in DuckDB, Spark, pandas and the like.
+//
+// The table has time, elevation, azimuth, range, lat and lon columns locating
+// each gate, and nullable ref, vel, sw, zdr, phi and rho columns with the
+// moments. Moments are placed on the range axis of each radial's reflectivity
+// (or its first moment, if it has none). Each sweep is a row group.
+package parquet
+
+import (
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/geo"
+)
+
+// Options controls the exported table
+type Options struct {
+	// IncludeEmpty includes gates without data in any moment. They're skipped
+	// by default, which makes the table much smaller.
+	IncludeEmpty bool
+}
+
+var columns = []column{
+	{name: "time", typ: typeInt64, converted: convertedTimestampMicros},
+	{name: "elevation", typ: typeFloat},
+	{name: "azimuth", typ: typeFloat},
+	{name: "range", typ: typeFloat},
+	{name: "lat", typ: typeDouble},
+	{name: "lon", typ: typeDouble},
+}
+
+// the first moment column
+const firstMoment = 6
+
+func init() {
+	for _, name := range archive2.MomentNames {
+		columns = append(columns, column{name: strings.ToLower(name), typ: typeFloat, optional: true})
+	}
+}
+
+// Write encodes the volume as a Parquet gate table
+func Write(w io.Writer, ar2 *archive2.Archive2, opts Options) error {
+	var elevations []int
+	for elv, radials := range ar2.ElevationScans {
+		if len(radials) > 0 {
+			elevations = append(elevations, elv)
+		}
+	}
+	if len(elevations) == 0 {
+		return errors.New("parquet: volume has no radials")
+	}
+	sort.Ints(elevations)
+
+	fw, err := newFileWriter(w, columns, map[string]string{
+		"icao":  string(ar2.VolumeHeader.ICAO[:]),
+		"range": "meters to the center of the gate",
+	})
+	if err != nil {
+		return err
+	}
+	for _, elv := range elevations {
+		t := newTable()
+		for _, r := range ar2.ElevationScans[elv] {
+			t.addRadial(r, opts)
+		}
+		if t.rows == 0 {
+			continue
+		}
+		if err := fw.writeRowGroup(t.rows, t.values(), t.defined); err != nil {
+			return err
+		}
+	}
+	return fw.close()
+}
+
+// table accumulates the columns of a row group
+type table struct {
+	rows      int
+	time      []int64
+	elevation []float32
+	azimuth   []float32
+	rng       []float32
+	lat       []float64
+	lon       []float64
+	moments   [][]float32
+	defined   [][]bool
+}
+
+func newTable() *table {
+	return &table{
+		moments: make([][]float32, len(archive2.MomentNames)),
+		defined: make([][]bool, len(columns)),
+	}
+}
+
+func (t *table) values() []interface{} {
+	v := []interface{}{t.time, t.elevation, t.azimuth, t.rng, t.lat, t.lon}
+	for _, m := range t.moments {
+		v = append(v, m)
+	}
+	return v
+}
+
+func (t *table) addRadial(r *archive2.Message31, opts Options) {
+	geometry := r.ReflectivityData
+	gates := make([][]float32, len(archive2.MomentNames))
+	for i, name := range archive2.MomentNames {
+		d := r.Moment(name)
+		if d == nil || d.DataMomentRangeSampleInterval == 0 {
+			continue
+		}
+		if geometry == nil {
+			geometry = d
+		}
+		gates[i] = d.ScaledData()
+	}
+	if geometry == nil {
+		return
+	}
+
+	timestamp := r.Header.Date().UnixNano() / 1000
+	lat, lon := float64(r.VolumeData.Lat), float64(r.VolumeData.Long)
+	elevation := float64(r.Header.ElevationAngle)
+	azimuth := float64(r.Header.AzimuthAngle)
+	values := make([]float32, len(archive2.MomentNames))
+	defined := make([]bool, len(archive2.MomentNames))
+
+	for g := 0; g < int(geometry.NumberDataMomentGates); g++ {
+		rng := float64(geometry.DataMomentRange) + float64(g)*float64(geometry.DataMomentRangeSampleInterval)
+		hasData := false
+		for i, name := range archive2.MomentNames {
+			defined[i] = false
+			if gates[i] == nil {
+				continue
+			}
+			d := r.Moment(name)
+			j := int(math.Round((rng - float64(d.DataMomentRange)) / float64(d.DataMomentRangeSampleInterval)))
+			if j < 0 || j >= len(gates[i]) {
+				continue
+			}
+			if v := gates[i][j]; v != archive2.MomentDataBelowThreshold && v != archive2.MomentDataFolded {
+				values[i], defined[i] = v, true
+				hasData = true
+			}
+		}
+		if !hasData && !opts.IncludeEmpty {
+			continue
+		}
+
+		gateLat, gateLon := geo.Destination(lat, lon, azimuth, geo.GroundRange(rng, elevation))
+		t.time = append(t.time, timestamp)
+		t.elevation = append(t.elevation, float32(elevation))
+		t.azimuth = append(t.azimuth, float32(azimuth))
+		t.rng = append(t.rng, float32(rng))
+		t.lat = append(t.lat, gateLat)
+		t.lon = append(t.lon, gateLon)
+		for i := range values {
+			t.defined[firstMoment+i] = append(t.defined[firstMoment+i], defined[i])
+			if defined[i] {
+				t.moments[i] = append(t.moments[i], values[i])
+			}
+		}
+		t.rows++
+	}
+}
@@ -0,0 +1,55 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/kallsyms/go-nexrad/internal/testvol"
+)
+
+func TestWrite(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := Write(buf, testvol.Volume(2), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	if !bytes.HasPrefix(b, []byte(magic)) || !bytes.HasSuffix(b, []byte(magic)) {
+		t.Fatal("missing parquet magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	if footerLen <= 0 || footerLen > len(b)-12 {
+		t.Fatalf("bad footer length %d", footerLen)
+	}
+	footer := b[len(b)-8-footerLen : len(b)-8]
+	for _, name := range []string{"time", "azimuth", "lat", "ref", "rho", "KTST"} {
+		if !bytes.Contains(footer, []byte(name)) {
+			t.Errorf("footer missing %q", name)
+		}
+	}
+
+	// two of the four gates have data
+	tbl := newTable()
+	for _, r := range testvol.Volume(2).ElevationScans[1] {
+		tbl.addRadial(r, Options{})
+	}
+	if tbl.rows != 720 || len(tbl.moments[0]) != 720 || len(tbl.moments[1]) != 0 {
+		t.Errorf("unexpected table: %d rows, %d ref, %d vel", tbl.rows, len(tbl.moments[0]), len(tbl.moments[1]))
+	}
+	tbl = newTable()
+	for _, r := range testvol.Volume(2).ElevationScans[1] {
+		tbl.addRadial(r, Options{IncludeEmpty: true})
+	}
+	if tbl.rows != 1440 {
+		t.Errorf("expected 1440 rows including empty gates, got %d", tbl.rows)
+	}
+}
+
+func TestEncodeLevels(t *testing.T) {
+	got := encodeLevels([]bool{true, false, true, true, false, false, false, false, true})
+	// two bit packed groups of 8
+	want := []byte{2<<1 | 1, 0x0d, 0x01}
+	if !bytes.Equal(got, want) {
+		t.Errorf("expected %x, got %x", want, got)
+	}
+}
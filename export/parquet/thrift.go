@@ -0,0 +1,115 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs with the thrift compact protocol, which parquet
+// uses for its metadata. See
+// https://github.com/apache/thrift/blob/master/doc/specs/thrift-compact-protocol.md
+type thriftWriter struct {
+	buf bytes.Buffer
+	// last field id of each struct being written
+	lastField []int16
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	b := make([]byte, binary.MaxVarintLen64)
+	t.buf.Write(b[:binary.PutUvarint(b, v)])
+}
+
+func (t *thriftWriter) zigzag(v int64) {
+	t.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	last := &t.lastField[len(t.lastField)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.zigzag(int64(id))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) listHeader(n int, typ byte) {
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | typ)
+	} else {
+		t.buf.WriteByte(0xf0 | typ)
+		t.varint(uint64(n))
+	}
+}
+
+// structBegin starts a struct, either top level or as a list element
+func (t *thriftWriter) structBegin() {
+	t.lastField = append(t.lastField, 0)
+}
+
+func (t *thriftWriter) structEnd() {
+	t.buf.WriteByte(0)
+	t.lastField = t.lastField[:len(t.lastField)-1]
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.zigzag(v)
+}
+
+func (t *thriftWriter) string(id int16, v string) {
+	t.fieldHeader(id, thriftBinary)
+	t.varint(uint64(len(v)))
+	t.buf.WriteString(v)
+}
+
+func (t *thriftWriter) i32List(id int16, v []int32) {
+	t.fieldHeader(id, thriftList)
+	t.listHeader(len(v), thriftI32)
+	for _, x := range v {
+		t.zigzag(int64(x))
+	}
+}
+
+func (t *thriftWriter) stringList(id int16, v []string) {
+	t.fieldHeader(id, thriftList)
+	t.listHeader(len(v), thriftBinary)
+	for _, x := range v {
+		t.varint(uint64(len(x)))
+		t.buf.WriteString(x)
+	}
+}
+
+// structField writes a struct valued field, with fields written by fn
+func (t *thriftWriter) structField(id int16, fn func()) {
+	t.fieldHeader(id, thriftStruct)
+	t.structBegin()
+	fn()
+	t.structEnd()
+}
+
+// structList writes a list of n structs, the fields of each written by fn
+func (t *thriftWriter) structList(id int16, n int, fn func(i int)) {
+	t.fieldHeader(id, thriftList)
+	t.listHeader(n, thriftStruct)
+	for i := 0; i < n; i++ {
+		t.structBegin()
+		fn(i)
+		t.structEnd()
+	}
+}
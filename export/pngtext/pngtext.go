@@ -0,0 +1,157 @@
+// Package pngtext reads and writes the text metadata chunks of PNG images,
+// such as where and when an image is of, so it travels with the image rather
+// than in sidecar files.
+//
+// See https://www.w3.org/TR/png/#11textinfo
+package pngtext
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"unicode/utf8"
+)
+
+// Field is a keyword and its text. Keywords are 1 to 79 printable ASCII
+// characters; the PNG specification predefines some, such as Title, Software
+// and Source, and others can be used.
+type Field struct {
+	Key   string
+	Value string
+}
+
+var signature = []byte("\x89PNG\r\n\x1a\n")
+
+// Insert returns the PNG with a text chunk of each field after its header:
+// tEXt if the text is Latin-1, otherwise iTXt holding it in UTF-8
+func Insert(png []byte, fields []Field) ([]byte, error) {
+	header, err := headerEnd(png)
+	if err != nil {
+		return nil, err
+	}
+	var chunks bytes.Buffer
+	for _, f := range fields {
+		if !validKey(f.Key) {
+			return nil, fmt.Errorf("pngtext: invalid keyword %q", f.Key)
+		}
+		if latin1, ok := toLatin1(f.Value); ok {
+			writeChunk(&chunks, "tEXt", append(append([]byte(f.Key), 0), latin1...))
+			continue
+		}
+		// no compression, language or translated keyword
+		data := append([]byte(f.Key), 0, 0, 0, 0, 0)
+		writeChunk(&chunks, "iTXt", append(data, f.Value...))
+	}
+
+	out := make([]byte, 0, len(png)+chunks.Len())
+	out = append(out, png[:header]...)
+	out = append(out, chunks.Bytes()...)
+	return append(out, png[header:]...), nil
+}
+
+// Read returns the fields of the PNG's uncompressed tEXt and iTXt chunks, in
+// order
+func Read(png []byte) ([]Field, error) {
+	if !bytes.HasPrefix(png, signature) {
+		return nil, errors.New("pngtext: not a PNG")
+	}
+	var fields []Field
+	for p := png[len(signature):]; len(p) >= 12; {
+		n := int(binary.BigEndian.Uint32(p))
+		if n < 0 || len(p) < 12+n {
+			return nil, errors.New("pngtext: truncated chunk")
+		}
+		typ, data := string(p[4:8]), p[8:8+n]
+		p = p[12+n:]
+		key := bytes.IndexByte(data, 0)
+		if key < 1 {
+			continue
+		}
+		switch typ {
+		case "tEXt":
+			fields = append(fields, Field{string(data[:key]), fromLatin1(data[key+1:])})
+		case "iTXt":
+			rest := data[key+1:]
+			// compressed text isn't read
+			if len(rest) < 2 || rest[0] != 0 {
+				continue
+			}
+			rest = rest[2:]
+			// the language tag and translated keyword
+			for i := 0; i < 2; i++ {
+				end := bytes.IndexByte(rest, 0)
+				if end < 0 {
+					return nil, errors.New("pngtext: malformed iTXt chunk")
+				}
+				rest = rest[end+1:]
+			}
+			fields = append(fields, Field{string(data[:key]), string(rest)})
+		case "IEND":
+			return fields, nil
+		}
+	}
+	return fields, nil
+}
+
+// headerEnd returns the offset of the end of the PNG's IHDR chunk
+func headerEnd(png []byte) (int, error) {
+	if !bytes.HasPrefix(png, signature) || len(png) < len(signature)+8 || string(png[12:16]) != "IHDR" {
+		return 0, errors.New("pngtext: not a PNG")
+	}
+	end := len(signature) + 12 + int(binary.BigEndian.Uint32(png[8:]))
+	if end > len(png) {
+		return 0, errors.New("pngtext: truncated header")
+	}
+	return end, nil
+}
+
+func writeChunk(w *bytes.Buffer, typ string, data []byte) {
+	binary.Write(w, binary.BigEndian, uint32(len(data)))
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	crc.Write(data)
+	w.WriteString(typ)
+	w.Write(data)
+	binary.Write(w, binary.BigEndian, crc.Sum32())
+}
+
+// validKey reports whether the keyword is 1 to 79 printable ASCII characters
+// without leading, trailing or repeated spaces
+func validKey(key string) bool {
+	if len(key) == 0 || len(key) > 79 || key[0] == ' ' || key[len(key)-1] == ' ' || strings.Contains(key, "  ") {
+		return false
+	}
+	for _, c := range []byte(key) {
+		if c < 32 || c > 126 {
+			return false
+		}
+	}
+	return true
+}
+
+// toLatin1 encodes the UTF-8 text in Latin-1, returning false if it has
+// characters Latin-1 doesn't
+func toLatin1(s string) ([]byte, bool) {
+	if !utf8.ValidString(s) {
+		return nil, false
+	}
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xff || r == 0 {
+			return nil, false
+		}
+		b = append(b, byte(r))
+	}
+	return b, true
+}
+
+func fromLatin1(b []byte) string {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
@@ -0,0 +1,48 @@
+package pngtext
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"reflect"
+	"testing"
+)
+
+func TestInsert(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	fields := []Field{
+		{"Site", "KTLX"},
+		{"Bounding Box", "33.2,-99.6,37.4,-94.9"},
+		// Latin-1 goes in tEXt, anything else in iTXt
+		{"Comment", "5 °C"},
+		{"Title", "Réflectivité – 0.5°"},
+	}
+	out, err := Insert(buf.Bytes(), fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the image still decodes
+	if _, err := png.Decode(bytes.NewReader(out)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, fields) {
+		t.Errorf("expected %v, got %v", fields, got)
+	}
+	if !bytes.Contains(out, []byte("iTXtTitle")) || bytes.Contains(out, []byte("iTXtComment")) {
+		t.Error("expected only the title in an iTXt chunk")
+	}
+
+	if _, err := Insert(buf.Bytes(), []Field{{"", "x"}}); err == nil {
+		t.Error("expected an error for an empty keyword")
+	}
+	if _, err := Insert([]byte("GIF89a"), fields); err == nil {
+		t.Error("expected an error for a GIF")
+	}
+}
@@ -0,0 +1,55 @@
+package zarr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/kallsyms/go-nexrad/sink"
+)
+
+// Store is where a Zarr hierarchy is written to. Keys are slash separated paths
+// relative to the root of the store, e.g. "REF/.zarray" or "REF/0.0.0".
+type Store interface {
+	Put(key string, value []byte) error
+}
+
+// DirStore stores keys as files under a local directory
+type DirStore struct {
+	Dir string
+}
+
+// NewDirStore returns a store writing to dir, which is created if needed
+func NewDirStore(dir string) *DirStore {
+	return &DirStore{Dir: dir}
+}
+
+// Put writes the value to the file for key
+func (s *DirStore) Put(key string, value []byte) error {
+	path := filepath.Join(s.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, value, 0644)
+}
+
+// S3Store stores keys as objects in an S3 bucket, under a prefix
+type S3Store struct {
+	*sink.S3
+}
+
+// NewS3Store returns a store writing under an s3://bucket/prefix URL. The
+// region, credentials and endpoint are read from the environment, see
+// sink.NewS3.
+func NewS3Store(rawurl string) (*S3Store, error) {
+	s, err := sink.NewS3(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return &S3Store{s}, nil
+}
+
+// Put uploads the value as the object for key
+func (s *S3Store) Put(key string, value []byte) error {
+	return s.S3.Put(key, value, sink.Metadata{ContentType: "application/octet-stream"})
+}
@@ -0,0 +1,298 @@
+// Package zarr writes archive 2 volumes as Zarr (v2) stores, for cloud native
+// analysis with xarray, dask and similar tools.
+//
+// Each moment is a float32 array with dimensions (sweep, ray, range), chunked
+// so there's one chunk per sweep. Rays are stored in the order they were
+// collected and padded with NaN to the longest sweep, with the azimuth and
+// time of each ray in coordinate arrays. As in the CfRadial export, moments are
+// placed on the range axis of reflectivity. Dimension names are recorded in
+// the _ARRAY_DIMENSIONS attribute used by xarray, and metadata is consolidated
+// into .zmetadata. See https://zarr.readthedocs.io/en/stable/spec/v2.html
+package zarr
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+type moment struct {
+	name     string
+	longName string
+	units    string
+}
+
+var moments = []moment{
+	{"REF", "reflectivity", "dBZ"},
+	{"VEL", "radial velocity", "m/s"},
+	{"SW", "spectrum width", "m/s"},
+	{"ZDR", "differential reflectivity", "dB"},
+	{"PHI", "differential phase", "degrees"},
+	{"RHO", "correlation coefficient", "unitless"},
+}
+
+// Write writes the volume to the root of the store
+func Write(s Store, ar2 *archive2.Archive2) error {
+	var sweeps [][]*archive2.Message31
+	var elevations []int
+	for elv, radials := range ar2.ElevationScans {
+		if len(radials) > 0 {
+			elevations = append(elevations, elv)
+		}
+	}
+	sort.Ints(elevations)
+	for _, elv := range elevations {
+		sweeps = append(sweeps, ar2.ElevationScans[elv])
+	}
+	if len(sweeps) == 0 {
+		return errors.New("zarr: volume has no radials")
+	}
+
+	nrays := 0
+	for _, radials := range sweeps {
+		if len(radials) > nrays {
+			nrays = len(radials)
+		}
+	}
+	firstGate, gateInterval, ngates := rangeGeometry(sweeps)
+	first := sweeps[0][0]
+	start := first.Header.Date()
+	nan := float32(math.NaN())
+
+	w := &writer{store: s, metadata: map[string]interface{}{}}
+	if err := w.put(".zgroup", map[string]interface{}{"zarr_format": 2}); err != nil {
+		return err
+	}
+	err := w.put(".zattrs", map[string]interface{}{
+		"title":               "NEXRAD Level 2 volume " + strings.TrimRight(ar2.VolumeHeader.FileName(), "\x00"),
+		"source":              "go-nexrad",
+		"instrument_name":     string(ar2.VolumeHeader.ICAO[:]),
+		"latitude":            first.VolumeData.Lat,
+		"longitude":           first.VolumeData.Long,
+		"altitude":            float64(first.VolumeData.SiteHeight) + float64(first.VolumeData.FeedhornHeight),
+		"time_coverage_start": start.Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	rng := &array{name: "range", dims: []string{"range"}, shape: []int{ngates}, dtype: "<f4",
+		attrs: map[string]interface{}{"units": "meters", "long_name": "range to center of gate"}}
+	values := make([]float32, ngates)
+	for i := range values {
+		values[i] = float32(firstGate + float64(i)*gateInterval)
+	}
+	if err := w.putArray(rng); err != nil {
+		return err
+	}
+	if err := w.putChunk(rng, []int{0}, values); err != nil {
+		return err
+	}
+
+	elevation := &array{name: "elevation", dims: []string{"sweep"}, shape: []int{len(sweeps)}, dtype: "<f4",
+		attrs: map[string]interface{}{"units": "degrees", "long_name": "mean elevation angle of the sweep"}}
+	values = make([]float32, len(sweeps))
+	for i, radials := range sweeps {
+		for _, r := range radials {
+			values[i] += r.Header.ElevationAngle
+		}
+		values[i] /= float32(len(radials))
+	}
+	if err := w.putArray(elevation); err != nil {
+		return err
+	}
+	if err := w.putChunk(elevation, []int{0}, values); err != nil {
+		return err
+	}
+
+	azimuth := &array{name: "azimuth", dims: []string{"sweep", "ray"}, shape: []int{len(sweeps), nrays},
+		chunks: []int{1, nrays}, dtype: "<f4", attrs: map[string]interface{}{"units": "degrees"}}
+	times := &array{name: "time", dims: []string{"sweep", "ray"}, shape: []int{len(sweeps), nrays},
+		chunks: []int{1, nrays}, dtype: "<f8", attrs: map[string]interface{}{
+			"units":    "seconds since " + start.Format("2006-01-02T15:04:05Z"),
+			"calendar": "standard",
+		}}
+	if err := w.putArray(azimuth); err != nil {
+		return err
+	}
+	if err := w.putArray(times); err != nil {
+		return err
+	}
+	for i, radials := range sweeps {
+		az := make([]float32, nrays)
+		t := make([]float64, nrays)
+		for j := range az {
+			az[j], t[j] = nan, math.NaN()
+		}
+		for j, r := range radials {
+			az[j] = r.Header.AzimuthAngle
+			t[j] = r.Header.Date().Sub(start).Seconds()
+		}
+		if err := w.putChunk(azimuth, []int{i, 0}, az); err != nil {
+			return err
+		}
+		if err := w.putChunk(times, []int{i, 0}, t); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range moments {
+		a := &array{name: m.name, dims: []string{"sweep", "ray", "range"}, shape: []int{len(sweeps), nrays, ngates},
+			chunks: []int{1, nrays, ngates}, dtype: "<f4", attrs: map[string]interface{}{
+				"units":       m.units,
+				"long_name":   m.longName,
+				"coordinates": "elevation azimuth time range",
+			}}
+		written := false
+		for i, radials := range sweeps {
+			// sweeps without the moment are left as missing chunks, which read
+			// as the fill value
+			chunk, ok := momentChunk(m.name, radials, nrays, firstGate, gateInterval, ngates)
+			if !ok {
+				continue
+			}
+			if !written {
+				if err := w.putArray(a); err != nil {
+					return err
+				}
+				written = true
+			}
+			if err := w.putChunk(a, []int{i, 0, 0}, chunk); err != nil {
+				return err
+			}
+		}
+	}
+
+	return w.put(".zmetadata", map[string]interface{}{
+		"zarr_consolidated_format": 1,
+		"metadata":                 w.metadata,
+	})
+}
+
+// momentChunk returns the moment's values for a sweep, on the given range axis,
+// and whether any gate has data
+func momentChunk(name string, radials []*archive2.Message31, nrays int, firstGate, gateInterval float64, ngates int) ([]float32, bool) {
+	chunk := make([]float32, nrays*ngates)
+	for i := range chunk {
+		chunk[i] = float32(math.NaN())
+	}
+	found := false
+	for i, r := range radials {
+		d := r.Moment(name)
+		if d == nil || d.DataMomentRangeSampleInterval == 0 {
+			continue
+		}
+		gates := d.ScaledData()
+		for g := 0; g < ngates; g++ {
+			j := int(math.Round((firstGate + float64(g)*gateInterval - float64(d.DataMomentRange)) / float64(d.DataMomentRangeSampleInterval)))
+			if j < 0 || j >= len(gates) {
+				continue
+			}
+			if v := gates[j]; v != archive2.MomentDataBelowThreshold && v != archive2.MomentDataFolded {
+				chunk[i*ngates+g] = v
+				found = true
+			}
+		}
+	}
+	return chunk, found
+}
+
+// rangeGeometry returns the range to the first gate, gate spacing (in meters)
+// and number of gates for the volume, based on the reflectivity moment.
+func rangeGeometry(sweeps [][]*archive2.Message31) (float64, float64, int) {
+	var firstGate, gateInterval float64
+	ngates := 0
+	for _, radials := range sweeps {
+		for _, r := range radials {
+			d := r.ReflectivityData
+			if d == nil {
+				continue
+			}
+			if ngates == 0 {
+				firstGate = float64(d.DataMomentRange)
+				gateInterval = float64(d.DataMomentRangeSampleInterval)
+			}
+			if n := int(d.NumberDataMomentGates); n > ngates {
+				ngates = n
+			}
+		}
+	}
+	return firstGate, gateInterval, ngates
+}
+
+type array struct {
+	name  string
+	dims  []string
+	shape []int
+	// chunks defaults to shape, i.e. a single chunk
+	chunks []int
+	dtype  string
+	attrs  map[string]interface{}
+}
+
+type writer struct {
+	store    Store
+	metadata map[string]interface{}
+}
+
+// put writes a JSON metadata document, recording it for .zmetadata
+func (w *writer) put(key string, doc map[string]interface{}) error {
+	b, err := json.MarshalIndent(doc, "", "    ")
+	if err != nil {
+		return err
+	}
+	w.metadata[key] = doc
+	return w.store.Put(key, b)
+}
+
+func (w *writer) putArray(a *array) error {
+	chunks := a.chunks
+	if chunks == nil {
+		chunks = a.shape
+	}
+	err := w.put(a.name+"/.zarray", map[string]interface{}{
+		"zarr_format": 2,
+		"shape":       a.shape,
+		"chunks":      chunks,
+		"dtype":       a.dtype,
+		"compressor":  map[string]interface{}{"id": "zlib", "level": zlib.DefaultCompression},
+		"fill_value":  "NaN",
+		"order":       "C",
+		"filters":     nil,
+	})
+	if err != nil {
+		return err
+	}
+	attrs := map[string]interface{}{"_ARRAY_DIMENSIONS": a.dims}
+	for k, v := range a.attrs {
+		attrs[k] = v
+	}
+	return w.put(a.name+"/.zattrs", attrs)
+}
+
+// putChunk writes the compressed chunk at the given chunk index. data must be
+// a slice of the array's type, with the full chunk shape.
+func (w *writer) putChunk(a *array, index []int, data interface{}) error {
+	b := &bytes.Buffer{}
+	zw := zlib.NewWriter(b)
+	if err := binary.Write(zw, binary.LittleEndian, data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	key := make([]string, len(index))
+	for i, n := range index {
+		key[i] = strconv.Itoa(n)
+	}
+	return w.store.Put(a.name+"/"+strings.Join(key, "."), b.Bytes())
+}
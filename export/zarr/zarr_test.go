@@ -0,0 +1,108 @@
+package zarr
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"testing"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/internal/testvol"
+)
+
+type memStore map[string][]byte
+
+func (s memStore) Put(key string, value []byte) error {
+	s[key] = value
+	return nil
+}
+
+// testVolume has a super resolution first sweep and a legacy second one
+func testVolume() *archive2.Archive2 {
+	ar2 := testvol.Volume(2)
+	ar2.ElevationScans[1] = testvol.Sweep(1, 720)
+	return ar2
+}
+
+func TestWrite(t *testing.T) {
+	s := memStore{}
+	if err := Write(s, testVolume()); err != nil {
+		t.Fatal(err)
+	}
+
+	var zarray struct {
+		Shape  []int
+		Chunks []int
+		Dtype  string
+	}
+	if err := json.Unmarshal(s["REF/.zarray"], &zarray); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{2, 720, 4}; !equal(zarray.Shape, want) {
+		t.Errorf("expected shape %v, got %v", want, zarray.Shape)
+	}
+	if want := []int{1, 720, 4}; !equal(zarray.Chunks, want) {
+		t.Errorf("expected chunks %v, got %v", want, zarray.Chunks)
+	}
+	if _, ok := s["VEL/.zarray"]; ok {
+		t.Error("expected no array for a moment without data")
+	}
+
+	var consolidated struct {
+		Metadata map[string]json.RawMessage
+	}
+	if err := json.Unmarshal(s[".zmetadata"], &consolidated); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{".zgroup", ".zattrs", "REF/.zarray", "REF/.zattrs", "azimuth/.zarray", "range/.zattrs"} {
+		if _, ok := consolidated.Metadata[key]; !ok {
+			t.Errorf("consolidated metadata missing %s", key)
+		}
+	}
+
+	// the second sweep has 360 rays, padded to 720
+	zr, err := zlib.NewReader(bytes.NewReader(s["REF/1.0.0"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make([]float32, 720*4)
+	if err := binary.Read(zr, binary.LittleEndian, values); err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsNaN(float64(values[0])) || values[2] != 0 || values[3] != 20 {
+		t.Errorf("unexpected first ray %v", values[:4])
+	}
+	if !math.IsNaN(float64(values[360*4+3])) {
+		t.Errorf("expected padding rays to be NaN, got %v", values[360*4:360*4+4])
+	}
+}
+
+func equal(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDirStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zarr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewDirStore(dir)
+	if err := s.Put("REF/0.0.0", []byte("chunk")); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(dir + "/REF/0.0.0")
+	if err != nil || string(b) != "chunk" {
+		t.Errorf("unexpected chunk %q: %v", b, err)
+	}
+}
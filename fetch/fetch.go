@@ -0,0 +1,251 @@
+// Package fetch lists and reads archive 2 volumes from the public NEXRAD
+// Level 2 archive bucket, which holds every volume from every site under
+// YYYY/MM/DD/SITE/ prefixes, e.g.
+//
+//	2017/08/25/KCRP/KCRP20170825_235733_V06
+//
+// Volumes can be streamed straight into archive2.Extract or downloaded.
+package fetch
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/internal/objio"
+)
+
+// DefaultBucket is the public archive bucket
+const DefaultBucket = "https://noaa-nexrad-level2.s3.amazonaws.com"
+
+// Volume is a volume in the bucket
+type Volume struct {
+	Key  string
+	ICAO string
+	// Time is the start of the volume scan
+	Time time.Time
+	// Size of the object in bytes
+	Size int64
+}
+
+// Client reads volumes from an archive bucket
+type Client struct {
+	// Bucket is the base URL of the bucket
+	Bucket string
+	HTTP   *http.Client
+}
+
+// NewClient returns a client for the public bucket
+func NewClient() *Client {
+	return &Client{Bucket: DefaultBucket, HTTP: http.DefaultClient}
+}
+
+// ParseURL returns a client for the bucket of a volume's URL, and its key.
+// URLs are https:// (or http://) URLs of objects, or s3://bucket/key and
+// gs://bucket/key, which are read anonymously, so the bucket must be public
+// like the NEXRAD buckets on AWS and Google Cloud. AWS_ENDPOINT_URL, as for
+// sink.S3, points s3:// URLs at S3 compatible stores.
+func ParseURL(rawurl string) (*Client, string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, "", err
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || key == "" {
+		return nil, "", fmt.Errorf("fetch: invalid volume url %q", rawurl)
+	}
+	c := NewClient()
+	switch u.Scheme {
+	case "https", "http":
+		c.Bucket = u.Scheme + "://" + u.Host
+	case "s3":
+		c.Bucket = "https://" + u.Host + ".s3.amazonaws.com"
+		if endpoint := os.Getenv("AWS_ENDPOINT_URL"); endpoint != "" {
+			c.Bucket = strings.TrimSuffix(endpoint, "/") + "/" + u.Host
+		}
+	case "gs":
+		c.Bucket = "https://storage.googleapis.com/" + u.Host
+	default:
+		return nil, "", fmt.Errorf("fetch: unsupported volume url %q, expected https://, s3:// or gs://", rawurl)
+	}
+	return c, key, nil
+}
+
+// IsURL reports whether the volume name is a URL ParseURL reads
+func IsURL(name string) bool {
+	for _, scheme := range []string{"https://", "http://", "s3://", "gs://"} {
+		if strings.HasPrefix(name, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the site's volumes on the day (UTC), in time order
+func (c *Client) List(ctx context.Context, site string, day time.Time) ([]Volume, error) {
+	site = strings.ToUpper(site)
+	all, err := c.Prefix(ctx, day.UTC().Format("2006/01/02/")+site+"/")
+	if err != nil {
+		return nil, err
+	}
+	volumes := []Volume{}
+	for _, v := range all {
+		if v.ICAO == site {
+			volumes = append(volumes, v)
+		}
+	}
+	sort.Slice(volumes, func(i, j int) bool { return volumes[i].Time.Before(volumes[j].Time) })
+	return volumes, nil
+}
+
+// Prefix returns the volumes whose keys start with prefix, in key order.
+// Objects that aren't named like volumes, such as the _MDM metadata files, are
+// left out.
+func (c *Client) Prefix(ctx context.Context, prefix string) ([]Volume, error) {
+	objects, _, err := objio.List(ctx, c.HTTP, c.Bucket, prefix, "")
+	if err != nil {
+		return nil, fmt.Errorf("fetch: %s", err)
+	}
+	volumes := []Volume{}
+	for _, o := range objects {
+		vk, ok := archive2.ParseVolumeKey(o.Key)
+		if !ok {
+			continue
+		}
+		volumes = append(volumes, Volume{Key: o.Key, ICAO: vk.ICAO, Time: vk.Time, Size: o.Size})
+	}
+	return volumes, nil
+}
+
+// Range returns the site's volumes starting at or after start and before end,
+// in time order. The range may span several days.
+func (c *Client) Range(ctx context.Context, site string, start, end time.Time) ([]Volume, error) {
+	volumes := []Volume{}
+	for day := start.UTC().Truncate(24 * time.Hour); day.Before(end); day = day.AddDate(0, 0, 1) {
+		vs, err := c.List(ctx, site, day)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range vs {
+			if !v.Time.Before(start) && v.Time.Before(end) {
+				volumes = append(volumes, v)
+			}
+		}
+	}
+	return volumes, nil
+}
+
+// Open returns a reader for the volume, decompressing gzipped volumes
+func (c *Client) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", strings.TrimRight(c.Bucket, "/")+"/"+key, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTP.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetch: %s: %s", key, resp.Status)
+	}
+	if !strings.HasSuffix(key, ".gz") {
+		return resp.Body, nil
+	}
+	return objio.Gunzip(resp.Body)
+}
+
+// Extract streams the volume into archive2.Extract, without writing it to
+// disk
+func (c *Client) Extract(ctx context.Context, key string) (*archive2.Archive2, error) {
+	r, err := c.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return archive2.Extract(bufio.NewReader(r))
+}
+
+// ReaderAt returns a reader of the volume as stored that fetches each read
+// with a ranged GET, so archive2.ExtractAt can decode single elevations
+// without downloading the whole volume. Gzipped volumes can't be read this
+// way.
+func (c *Client) ReaderAt(ctx context.Context, key string) io.ReaderAt {
+	return &rangeReader{c: c, ctx: ctx, key: key}
+}
+
+type rangeReader struct {
+	c   *Client
+	ctx context.Context
+	key string
+}
+
+func (r *rangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	req, err := http.NewRequest("GET", strings.TrimRight(r.c.Bucket, "/")+"/"+r.key, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1))
+	resp, err := r.c.HTTP.Do(req.WithContext(r.ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusRequestedRangeNotSatisfiable:
+		return 0, io.EOF
+	default:
+		return 0, fmt.Errorf("fetch: %s: %s", r.key, resp.Status)
+	}
+	n, err := io.ReadFull(resp.Body, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Download copies the volume (as stored, i.e. still gzipped if it is) to the
+// file at path. It's written to a temporary file first so interrupted
+// downloads aren't mistaken for complete ones.
+func (c *Client) Download(ctx context.Context, key, path string) error {
+	req, err := http.NewRequest("GET", strings.TrimRight(c.Bucket, "/")+"/"+key, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.HTTP.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch: %s: %s", key, resp.Status)
+	}
+
+	tmp := path + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
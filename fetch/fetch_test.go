@@ -0,0 +1,243 @@
+package fetch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsnet/compress/bzip2"
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/internal/objio"
+)
+
+// bucket is a fake of the archive bucket
+type bucket map[string][]byte
+
+func (b bucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		data, ok := b[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+		return
+	}
+
+	prefix := r.URL.Query().Get("prefix")
+	var keys []string
+	for k := range b {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var result objio.ListResult
+	for _, k := range keys {
+		result.Contents = append(result.Contents, objio.Object{Key: k, Size: int64(len(b[k]))})
+	}
+	xml.NewEncoder(w).Encode(result)
+}
+
+// testVolume encodes a volume holding a metadata record with one message 2
+func testVolume(t *testing.T) []byte {
+	msg := &bytes.Buffer{}
+	msg.Write(make([]byte, archive2.LegacyCTMHeaderLen))
+	binary.Write(msg, binary.BigEndian, archive2.MessageHeader{MessageType: 2})
+	binary.Write(msg, binary.BigEndian, archive2.Message2{RDAStatus: archive2.RDAStatusOperate})
+	msg.Write(make([]byte, archive2.MessageBodySize-68))
+
+	compressed := &bytes.Buffer{}
+	w, err := bzip2.NewWriter(compressed, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(msg.Bytes())
+	w.Close()
+
+	vol := &bytes.Buffer{}
+	vh := archive2.VolumeHeaderRecord{}
+	copy(vh.ICAO[:], "KTLX")
+	binary.Write(vol, binary.BigEndian, vh)
+	binary.Write(vol, binary.BigEndian, int32(compressed.Len()))
+	vol.Write(compressed.Bytes())
+	return vol.Bytes()
+}
+
+func gzipped(data []byte) []byte {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func testClient(b bucket) (*Client, func()) {
+	srv := httptest.NewServer(b)
+	c := NewClient()
+	c.Bucket = srv.URL
+	return c, srv.Close
+}
+
+func TestList(t *testing.T) {
+	c, done := testClient(bucket{
+		"2020/01/01/KTLX/KTLX20200101_001000_V06":      []byte("b"),
+		"2020/01/01/KTLX/KTLX20200101_000000_V06.gz":   []byte("a"),
+		"2020/01/01/KTLX/KTLX20200101_000500_V06_MDM":  []byte("mdm"),
+		"2020/01/01/KTLX/NWS_NEXRAD_NXL2DPBL_KTLX.tar": []byte("tar"),
+		"2020/01/01/KINX/KINX20200101_000000_V06":      []byte("other site"),
+	})
+	defer done()
+
+	volumes, err := c.List(context.Background(), "ktlx", time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(volumes) != 2 {
+		t.Fatalf("expected 2 volumes, got %+v", volumes)
+	}
+	if volumes[0].Key != "2020/01/01/KTLX/KTLX20200101_000000_V06.gz" || volumes[0].Size != 1 || volumes[0].ICAO != "KTLX" {
+		t.Errorf("unexpected first volume %+v", volumes[0])
+	}
+	if !volumes[1].Time.Equal(time.Date(2020, 1, 1, 0, 10, 0, 0, time.UTC)) {
+		t.Errorf("unexpected second volume %+v", volumes[1])
+	}
+}
+
+func TestRange(t *testing.T) {
+	c, done := testClient(bucket{
+		"2019/12/31/KTLX/KTLX20191231_234000_V06": nil,
+		"2019/12/31/KTLX/KTLX20191231_235500_V06": nil,
+		"2020/01/01/KTLX/KTLX20200101_000500_V06": nil,
+		"2020/01/01/KTLX/KTLX20200101_003000_V06": nil,
+	})
+	defer done()
+
+	start := time.Date(2019, 12, 31, 23, 50, 0, 0, time.UTC)
+	volumes, err := c.Range(context.Background(), "KTLX", start, start.Add(30*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(volumes) != 2 || volumes[0].Time.Day() != 31 || volumes[1].Time.Day() != 1 {
+		t.Errorf("expected the volumes either side of midnight, got %+v", volumes)
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	c, done := testClient(bucket{
+		"2020/01/01/KTLX/KTLX20200101_000000_V06":     nil,
+		"2020/01/01/KTLX/KTLX20200101_000500_V06_MDM": nil,
+		"2020/01/01/KINX/KINX20200101_000000_V06.gz":  nil,
+		"2020/01/02/KTLX/KTLX20200102_000000_V06":     nil,
+	})
+	defer done()
+
+	volumes, err := c.Prefix(context.Background(), "2020/01/01/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(volumes) != 2 || volumes[0].ICAO != "KINX" || volumes[1].ICAO != "KTLX" {
+		t.Errorf("expected the volumes of both sites on the day in key order, got %+v", volumes)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	key := "2020/01/01/KTLX/KTLX20200101_000000_V06.gz"
+	c, done := testClient(bucket{key: gzipped(testVolume(t))})
+	defer done()
+
+	ar2, err := c.Extract(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ar2.RadarStatus == nil || ar2.RadarStatus.RDAStatus != archive2.RDAStatusOperate {
+		t.Errorf("volume wasn't decoded: %+v", ar2.RadarStatus)
+	}
+
+	if _, err := c.Open(context.Background(), "2020/01/01/KTLX/missing"); err == nil {
+		t.Error("expected an error opening a missing volume")
+	}
+}
+
+func TestParseURL(t *testing.T) {
+	os.Setenv("AWS_ENDPOINT_URL", "")
+	key := "2017/08/25/KCRP/KCRP20170825_235733_V06"
+	for _, c := range []struct{ url, bucket string }{
+		{"https://noaa-nexrad-level2.s3.amazonaws.com/" + key, "https://noaa-nexrad-level2.s3.amazonaws.com"},
+		{"s3://noaa-nexrad-level2/" + key, "https://noaa-nexrad-level2.s3.amazonaws.com"},
+		{"gs://gcp-public-data-nexrad-l2/" + key, "https://storage.googleapis.com/gcp-public-data-nexrad-l2"},
+	} {
+		client, k, err := ParseURL(c.url)
+		if err != nil {
+			t.Errorf("%s: %s", c.url, err)
+			continue
+		}
+		if client.Bucket != c.bucket || k != key {
+			t.Errorf("%s: expected %s %s, got %s %s", c.url, c.bucket, key, client.Bucket, k)
+		}
+	}
+	for _, u := range []string{"s3://bucket", "ftp://host/key", "gs:///key"} {
+		if _, _, err := ParseURL(u); err == nil {
+			t.Errorf("%s: expected an error", u)
+		}
+	}
+}
+
+func TestDownload(t *testing.T) {
+	key := "2020/01/01/KTLX/KTLX20200101_000000_V06.gz"
+	data := gzipped(testVolume(t))
+	c, done := testClient(bucket{key: data})
+	defer done()
+
+	dir, err := ioutil.TempDir("", "fetch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "volume.gz")
+	if err := c.Download(context.Background(), key, path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("downloaded volume differs from the stored one")
+	}
+	if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+		t.Error("temporary file was left behind")
+	}
+}
+
+func TestReaderAt(t *testing.T) {
+	key := "2020/01/01/KTLX/KTLX20200101_000000_V06"
+	data := testVolume(t)
+	c, done := testClient(bucket{key: data})
+	defer done()
+
+	r := c.ReaderAt(context.Background(), key)
+	p := make([]byte, 4)
+	if n, err := r.ReadAt(p, 24); err != nil || n != 4 || !bytes.Equal(p, data[24:28]) {
+		t.Errorf("unexpected read %v (%d, %v)", p, n, err)
+	}
+	if n, err := r.ReadAt(p, int64(len(data))-2); err != io.EOF || n != 2 {
+		t.Errorf("expected a short read at the end, got %d, %v", n, err)
+	}
+	if _, err := r.ReadAt(p, int64(len(data))+10); err != io.EOF {
+		t.Errorf("expected EOF past the end, got %v", err)
+	}
+}
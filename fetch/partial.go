@@ -0,0 +1,169 @@
+package fetch
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+// maxIndexes is the number of volume indexes a Loader keeps
+const maxIndexes = 256
+
+// Loader decodes single elevation scans of volumes in the bucket using ranged
+// GETs, fetching only the volume header, the metadata record and the LDM
+// records holding the scan. Every moment of a radial is in the same record,
+// so all of the scan's moments are loaded.
+//
+// The records' offsets are found by walking their control words, and the
+// records holding a scan by a binary search over them, since elevation
+// numbers only increase through a volume. Indexes are kept per volume, so
+// later scans of the same volume need fewer requests.
+type Loader struct {
+	Client *Client
+
+	mtx     sync.Mutex
+	indexes map[string]*archive2.Index
+}
+
+// NewLoader returns a loader reading through the client
+func NewLoader(c *Client) *Loader {
+	return &Loader{Client: c, indexes: map[string]*archive2.Index{}}
+}
+
+// Elevation returns the volume with only the elevation scan's radials (and
+// the metadata record's status messages) loaded. Gzipped volumes can't be
+// read in parts, so they're loaded whole.
+func (l *Loader) Elevation(ctx context.Context, key string, elevation int) (*archive2.Archive2, error) {
+	if strings.HasSuffix(key, ".gz") {
+		return l.Client.Extract(ctx, key)
+	}
+	r := &recordCache{ReaderAt: l.Client.ReaderAt(ctx, key), records: map[int64][]byte{}}
+	idx, err := l.index(key, r)
+	if err != nil {
+		return nil, err
+	}
+	located, err := l.locate(idx, r, elevation)
+	if err != nil {
+		return nil, err
+	}
+	return archive2.ExtractAt(r, located, elevation)
+}
+
+// index returns the volume's index, walking the records' control words if
+// it's not known yet. Only the offsets and sizes are filled in.
+func (l *Loader) index(key string, r io.ReaderAt) (*archive2.Index, error) {
+	l.mtx.Lock()
+	idx, ok := l.indexes[key]
+	l.mtx.Unlock()
+	if ok {
+		return idx, nil
+	}
+
+	idx = &archive2.Index{}
+	// records start after the volume header
+	offset := int64(24)
+	var size int32
+	for {
+		err := binary.Read(io.NewSectionReader(r, offset, 4), binary.BigEndian, &size)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if size, err = archive2.LDMRecordSize(size); err != nil {
+			return nil, fmt.Errorf("fetch: %s: record at %d: %s", key, offset, err)
+		}
+		idx.Records = append(idx.Records, archive2.IndexRecord{Offset: offset, Size: int64(size) + 4})
+		offset += int64(size) + 4
+	}
+	if len(idx.Records) == 0 {
+		return nil, fmt.Errorf("fetch: %s: no LDM records", key)
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if len(l.indexes) >= maxIndexes {
+		l.indexes = map[string]*archive2.Index{}
+	}
+	l.indexes[key] = idx
+	return idx, nil
+}
+
+// locate fills in the elevations of the records holding the elevation scan,
+// decoding records to find them, and returns a copy of the index
+func (l *Loader) locate(idx *archive2.Index, r *recordCache, elevation int) (archive2.Index, error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	// the metadata record has no radials, so scans start after it
+	records := idx.Records[1:]
+	var err error
+	elevationsOf := func(i int) []int {
+		rec := &records[i]
+		if rec.Elevations == nil && err == nil {
+			rec.Elevations, err = r.elevations(*rec)
+		}
+		return rec.Elevations
+	}
+	last := func(i int) int {
+		e := elevationsOf(i)
+		if len(e) == 0 {
+			return 0
+		}
+		return e[len(e)-1]
+	}
+	start := sort.Search(len(records), func(i int) bool { return last(i) >= elevation })
+	for i := start; i < len(records) && err == nil; i++ {
+		if e := elevationsOf(i); len(e) == 0 || e[0] > elevation {
+			break
+		}
+	}
+	located := archive2.Index{Records: make([]archive2.IndexRecord, len(idx.Records))}
+	copy(located.Records, idx.Records)
+	return located, err
+}
+
+// recordCache keeps the records decoded while locating a scan, so ExtractAt
+// doesn't fetch them again
+type recordCache struct {
+	io.ReaderAt
+	records map[int64][]byte
+}
+
+// elevations fetches and decodes the record, returning the elevation numbers
+// of its radials. Records without radials get an empty, non-nil list.
+func (c *recordCache) elevations(rec archive2.IndexRecord) ([]int, error) {
+	data := make([]byte, rec.Size)
+	if n, err := c.ReaderAt.ReadAt(data, rec.Offset); n < len(data) {
+		return nil, err
+	}
+	c.records[rec.Offset] = data
+	loaded, err := (&archive2.Archive2{}).LoadLDMRecord(io.NewSectionReader(c, rec.Offset, rec.Size))
+	if err != nil {
+		return nil, err
+	}
+	elevations := []int{}
+	for _, m31 := range loaded.M31s {
+		elv := int(m31.Header.ElevationNumber)
+		if len(elevations) == 0 || elevations[len(elevations)-1] != elv {
+			elevations = append(elevations, elv)
+		}
+	}
+	return elevations, nil
+}
+
+// ReadAt reads from a cached record if it holds the whole range
+func (c *recordCache) ReadAt(p []byte, off int64) (int, error) {
+	for start, data := range c.records {
+		if off >= start && off+int64(len(p)) <= start+int64(len(data)) {
+			return copy(p, data[off-start:]), nil
+		}
+	}
+	return c.ReaderAt.ReadAt(p, off)
+}
@@ -0,0 +1,115 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dsnet/compress/bzip2"
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+// testRadial encodes a message 31 with only the VOL, ELV and RAD blocks
+func testRadial(elevation uint8, azimuthNumber uint16) []byte {
+	body := &bytes.Buffer{}
+	h := archive2.Message31Header{
+		AzimuthNumber:                azimuthNumber,
+		AzimuthResolutionSpacingCode: 2,
+		ElevationNumber:              elevation,
+		DataBlockCount:               3,
+	}
+	vol := archive2.VolumeData{}
+	elv := archive2.ElevationData{}
+	h.VOLDataBlockPtr = uint32(binary.Size(h))
+	h.ELVDataBlockPtr = h.VOLDataBlockPtr + uint32(binary.Size(vol))
+	h.RADDataBlockPtr = h.ELVDataBlockPtr + uint32(binary.Size(elv))
+	binary.Write(body, binary.BigEndian, h)
+	binary.Write(body, binary.BigEndian, vol)
+	binary.Write(body, binary.BigEndian, elv)
+	binary.Write(body, binary.BigEndian, archive2.RadialData{})
+
+	msg := &bytes.Buffer{}
+	msg.Write(make([]byte, archive2.LegacyCTMHeaderLen))
+	binary.Write(msg, binary.BigEndian, archive2.MessageHeader{
+		MessageSize: uint16((archive2.MessageHeaderSize + body.Len()) / 2),
+		MessageType: 31,
+	})
+	msg.Write(body.Bytes())
+	return msg.Bytes()
+}
+
+// testElevations encodes a volume with a metadata record, then records of
+// five radials each. Each elevation has ten radials.
+func testElevations(t *testing.T, elevations int) []byte {
+	vol := bytes.NewBuffer(testVolume(t))
+	var radials [][]byte
+	for elv := 1; elv <= elevations; elv++ {
+		for az := 1; az <= 10; az++ {
+			radials = append(radials, testRadial(uint8(elv), uint16(az)))
+		}
+	}
+	// the first record is short, so records don't line up with scans
+	for start, end := 0, 3; start < len(radials); start, end = end, end+5 {
+		if end > len(radials) {
+			end = len(radials)
+		}
+		compressed := &bytes.Buffer{}
+		w, err := bzip2.NewWriter(compressed, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(bytes.Join(radials[start:end], nil))
+		w.Close()
+		binary.Write(vol, binary.BigEndian, int32(compressed.Len()))
+		vol.Write(compressed.Bytes())
+	}
+	return vol.Bytes()
+}
+
+func TestLoaderElevation(t *testing.T) {
+	key := "2020/01/01/KTLX/KTLX20200101_000000_V06"
+	data := testElevations(t, 6)
+	var served int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counter := &countingWriter{ResponseWriter: w, n: &served}
+		bucket{key: data}.ServeHTTP(counter, r)
+	}))
+	defer srv.Close()
+	c := NewClient()
+	c.Bucket = srv.URL
+	l := NewLoader(c)
+
+	for i, elv := range []int{4, 1, 6} {
+		ar2, err := l.Elevation(context.Background(), key, elv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ar2.ElevationScans) != 1 || len(ar2.ElevationScans[elv]) != 10 {
+			t.Errorf("elevation %d: expected 10 radials, got %d elevations %v", elv, len(ar2.ElevationScans), ar2.Summary().Cuts)
+		}
+		if ar2.RadarStatus == nil || string(ar2.VolumeHeader.ICAO[:]) != "KTLX" {
+			t.Errorf("elevation %d: metadata wasn't loaded", elv)
+		}
+		if i == 0 && served >= int64(len(data)) {
+			t.Errorf("served %d bytes loading one elevation of a %d byte volume", served, len(data))
+		}
+	}
+
+	if _, err := l.Elevation(context.Background(), key, 7); err == nil {
+		t.Error("expected an error loading a missing elevation")
+	}
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+	n *int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	atomic.AddInt64(w.n, int64(len(p)))
+	return w.ResponseWriter.Write(p)
+}
@@ -0,0 +1,83 @@
+// Package geo contains the geometry needed to place radar gates in the world:
+// beam propagation, gate georeferencing and map projections.
+package geo
+
+import "math"
+
+const (
+	// EarthRadius is the mean radius of the earth in meters
+	EarthRadius = 6371000.0
+	// EffectiveRadiusFactor accounts for standard atmospheric refraction bending
+	// the beam back towards the earth (the "4/3 earth" model).
+	EffectiveRadiusFactor = 4.0 / 3.0
+	// BeamWidth is the nominal half power beam width of the WSR-88D in degrees
+	BeamWidth = 0.95
+)
+
+// BeamHeight returns the height of the beam center above the radar in meters
+// for the given slant range (meters) and elevation angle (degrees), using the
+// 4/3 effective earth radius model.
+func BeamHeight(slantRange, elevation float64) float64 {
+	re := EarthRadius * EffectiveRadiusFactor
+	el := elevation * math.Pi / 180
+	return math.Sqrt(slantRange*slantRange+re*re+2*slantRange*re*math.Sin(el)) - re
+}
+
+// GroundRange returns the distance along the earth's surface in meters to the
+// point below the beam at the given slant range and elevation.
+func GroundRange(slantRange, elevation float64) float64 {
+	re := EarthRadius * EffectiveRadiusFactor
+	el := elevation * math.Pi / 180
+	h := BeamHeight(slantRange, elevation)
+	return re * math.Asin(slantRange*math.Cos(el)/(re+h))
+}
+
+// BeamEnvelope returns the heights in meters of the bottom, center and top of
+// the beam at the given slant range and elevation, using the nominal beam width.
+func BeamEnvelope(slantRange, elevation float64) (bottom, center, top float64) {
+	return BeamHeight(slantRange, elevation-BeamWidth/2),
+		BeamHeight(slantRange, elevation),
+		BeamHeight(slantRange, elevation+BeamWidth/2)
+}
+
+// BeamPoint is a sample along the beam path
+type BeamPoint struct {
+	SlantRange  float64
+	GroundRange float64
+	Bottom      float64
+	Center      float64
+	Top         float64
+}
+
+// BeamPath samples the beam envelope every step meters out to maxRange, which
+// is what's needed to draw the beam over a terrain profile. siteHeight (the
+// height of the antenna above sea level) is added to all heights. It returns
+// nil unless step is positive and maxRange finite and not negative.
+func BeamPath(elevation, siteHeight, maxRange, step float64) []BeamPoint {
+	if !(step > 0) || !(maxRange >= 0) || math.IsInf(maxRange, 1) {
+		return nil
+	}
+	n := int(maxRange/step) + 1
+	points := make([]BeamPoint, 0, n)
+	for i := 0; i < n; i++ {
+		r := float64(i) * step
+		bottom, center, top := BeamEnvelope(r, elevation)
+		points = append(points, BeamPoint{
+			SlantRange:  r,
+			GroundRange: GroundRange(r, elevation),
+			Bottom:      bottom + siteHeight,
+			Center:      center + siteHeight,
+			Top:         top + siteHeight,
+		})
+	}
+	return points
+}
+
+// SlantRange is the inverse of GroundRange, returning the slant range in meters
+// to the point of the beam above the given ground range.
+func SlantRange(groundRange, elevation float64) float64 {
+	re := EarthRadius * EffectiveRadiusFactor
+	el := elevation * math.Pi / 180
+	theta := groundRange / re
+	return re * math.Sin(theta) / math.Cos(theta+el)
+}
@@ -0,0 +1,67 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBeamHeight(t *testing.T) {
+	tests := []struct {
+		slantRange, elevation, want float64
+	}{
+		{0, 0.5, 0},
+		// 0.5 deg at 100 km is ~1.46 km, the commonly quoted value
+		{100000, 0.5, 1461},
+		{230000, 0.5, 5120},
+		{100000, 19.5, 34000},
+	}
+	for _, tt := range tests {
+		got := BeamHeight(tt.slantRange, tt.elevation)
+		if math.Abs(got-tt.want) > tt.want*0.01+1 {
+			t.Errorf("BeamHeight(%v, %v) = %v, want ~%v", tt.slantRange, tt.elevation, got, tt.want)
+		}
+	}
+}
+
+func TestBeamEnvelope(t *testing.T) {
+	bottom, center, top := BeamEnvelope(150000, 0.5)
+	if !(bottom < center && center < top) {
+		t.Errorf("envelope out of order: %v %v %v", bottom, center, top)
+	}
+	if g := GroundRange(150000, 0.5); g > 150000 || g < 149000 {
+		t.Errorf("unexpected ground range %v", g)
+	}
+}
+
+func TestBeamPath(t *testing.T) {
+	points := BeamPath(0.5, 400, 10000, 2500)
+	if len(points) != 5 || points[4].SlantRange != 10000 {
+		t.Fatalf("expected points every 2.5 km out to 10 km, got %+v", points)
+	}
+	if points[0].Center != 400 || points[4].Bottom >= points[4].Top {
+		t.Errorf("unexpected envelope %+v", points)
+	}
+	nan, inf := math.NaN(), math.Inf(1)
+	for _, tt := range [][2]float64{{10000, 0}, {10000, -1}, {10000, nan}, {nan, 100}, {inf, 100}, {-1, 100}} {
+		if p := BeamPath(0.5, 400, tt[0], tt[1]); p != nil {
+			t.Errorf("BeamPath out to %v every %v = %+v, expected nil", tt[0], tt[1], p)
+		}
+	}
+}
+
+func TestSlantRange(t *testing.T) {
+	for _, r := range []float64{1000, 50000, 230000} {
+		g := GroundRange(r, 2.4)
+		if s := SlantRange(g, 2.4); math.Abs(s-r) > 0.01 {
+			t.Errorf("SlantRange(GroundRange(%v)) = %v", r, s)
+		}
+	}
+}
+
+func TestDestination(t *testing.T) {
+	lat, lon := Destination(35.33, -97.28, 45, 100000)
+	bearing, distance := BearingDistance(35.33, -97.28, lat, lon)
+	if math.Abs(bearing-45) > 0.01 || math.Abs(distance-100000) > 0.01 {
+		t.Errorf("round trip gave bearing %v distance %v", bearing, distance)
+	}
+}
@@ -0,0 +1,41 @@
+package geo
+
+import "math"
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// Destination returns the point reached by travelling distance meters from
+// lat, lon along the great circle with the given initial bearing (degrees
+// clockwise from north).
+func Destination(lat, lon, bearing, distance float64) (float64, float64) {
+	phi1 := radians(lat)
+	lambda1 := radians(lon)
+	theta := radians(bearing)
+	delta := distance / EarthRadius
+
+	phi2 := math.Asin(math.Sin(phi1)*math.Cos(delta) + math.Cos(phi1)*math.Sin(delta)*math.Cos(theta))
+	lambda2 := lambda1 + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(phi1), math.Cos(delta)-math.Sin(phi1)*math.Sin(phi2))
+	return degrees(phi2), math.Mod(degrees(lambda2)+540, 360) - 180
+}
+
+// BearingDistance returns the initial bearing (degrees clockwise from north,
+// 0-360) and great circle distance in meters from the first point to the second.
+func BearingDistance(lat1, lon1, lat2, lon2 float64) (float64, float64) {
+	phi1 := radians(lat1)
+	phi2 := radians(lat2)
+	dLambda := radians(lon2 - lon1)
+
+	y := math.Sin(dLambda) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dLambda)
+	bearing := math.Mod(degrees(math.Atan2(y, x))+360, 360)
+
+	a := math.Pow(math.Sin((phi2-phi1)/2), 2) + math.Cos(phi1)*math.Cos(phi2)*math.Pow(math.Sin(dLambda/2), 2)
+	distance := 2 * EarthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+	return bearing, distance
+}
@@ -0,0 +1,138 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+)
+
+// Projection maps geographic coordinates (degrees) to a plane in meters and
+// back. Projections use a spherical earth of EarthRadius, like the rest of the
+// package.
+type Projection interface {
+	Forward(lat, lon float64) (x, y float64)
+	Inverse(x, y float64) (lat, lon float64)
+	// Proj4 describes the projection as a PROJ string, for GIS tools
+	Proj4() string
+}
+
+func normalizeLon(lon float64) float64 {
+	return math.Mod(lon+540, 360) - 180
+}
+
+// AzimuthalEquidistant is the azimuthal equidistant projection centered on
+// Lat0, Lon0. Distances and bearings from the center are true, so it's the
+// natural projection of a single radar's sweep.
+type AzimuthalEquidistant struct {
+	Lat0, Lon0 float64
+}
+
+// Forward projects lat, lon
+func (p AzimuthalEquidistant) Forward(lat, lon float64) (float64, float64) {
+	bearing, distance := BearingDistance(p.Lat0, p.Lon0, lat, lon)
+	return distance * math.Sin(radians(bearing)), distance * math.Cos(radians(bearing))
+}
+
+// Inverse returns the coordinates of x, y
+func (p AzimuthalEquidistant) Inverse(x, y float64) (float64, float64) {
+	if x == 0 && y == 0 {
+		return p.Lat0, p.Lon0
+	}
+	return Destination(p.Lat0, p.Lon0, degrees(math.Atan2(x, y)), math.Hypot(x, y))
+}
+
+// Proj4 describes the projection
+func (p AzimuthalEquidistant) Proj4() string {
+	return fmt.Sprintf("+proj=aeqd +lat_0=%g +lon_0=%g +R=%g +units=m +no_defs", p.Lat0, p.Lon0, EarthRadius)
+}
+
+// LambertConformal is the Lambert conformal conic projection with standard
+// parallels Parallel1 and Parallel2 (which may be the same), origin latitude
+// Lat0 and central meridian Lon0.
+type LambertConformal struct {
+	Lat0, Lon0           float64
+	Parallel1, Parallel2 float64
+}
+
+// cone returns the cone constant n, F and the radius of the origin latitude
+func (p LambertConformal) cone() (n, f, rho0 float64) {
+	phi1, phi2 := radians(p.Parallel1), radians(p.Parallel2)
+	t := func(phi float64) float64 { return math.Tan(math.Pi/4 + phi/2) }
+	if math.Abs(phi1-phi2) < 1e-10 {
+		n = math.Sin(phi1)
+	} else {
+		n = math.Log(math.Cos(phi1)/math.Cos(phi2)) / math.Log(t(phi2)/t(phi1))
+	}
+	f = math.Cos(phi1) * math.Pow(t(phi1), n) / n
+	rho0 = EarthRadius * f / math.Pow(t(radians(p.Lat0)), n)
+	return n, f, rho0
+}
+
+// Forward projects lat, lon
+func (p LambertConformal) Forward(lat, lon float64) (float64, float64) {
+	n, f, rho0 := p.cone()
+	rho := EarthRadius * f / math.Pow(math.Tan(math.Pi/4+radians(lat)/2), n)
+	theta := n * radians(normalizeLon(lon-p.Lon0))
+	return rho * math.Sin(theta), rho0 - rho*math.Cos(theta)
+}
+
+// Inverse returns the coordinates of x, y
+func (p LambertConformal) Inverse(x, y float64) (float64, float64) {
+	n, f, rho0 := p.cone()
+	dy := rho0 - y
+	rho := math.Copysign(math.Hypot(x, dy), n)
+	theta := math.Atan2(x, dy)
+	if n < 0 {
+		theta = math.Atan2(-x, -dy)
+	}
+	lat := 2*math.Atan(math.Pow(EarthRadius*f/rho, 1/n)) - math.Pi/2
+	return degrees(lat), normalizeLon(p.Lon0 + degrees(theta/n))
+}
+
+// Proj4 describes the projection
+func (p LambertConformal) Proj4() string {
+	return fmt.Sprintf("+proj=lcc +lat_0=%g +lon_0=%g +lat_1=%g +lat_2=%g +R=%g +units=m +no_defs",
+		p.Lat0, p.Lon0, p.Parallel1, p.Parallel2, EarthRadius)
+}
+
+// Mercator is the (spherical) Mercator projection with central meridian Lon0
+type Mercator struct {
+	Lon0 float64
+}
+
+// Forward projects lat, lon
+func (p Mercator) Forward(lat, lon float64) (float64, float64) {
+	return EarthRadius * radians(normalizeLon(lon-p.Lon0)), EarthRadius * math.Log(math.Tan(math.Pi/4+radians(lat)/2))
+}
+
+// Inverse returns the coordinates of x, y
+func (p Mercator) Inverse(x, y float64) (float64, float64) {
+	return degrees(2*math.Atan(math.Exp(y/EarthRadius)) - math.Pi/2), normalizeLon(p.Lon0 + degrees(x/EarthRadius))
+}
+
+// Proj4 describes the projection
+func (p Mercator) Proj4() string {
+	return fmt.Sprintf("+proj=merc +lon_0=%g +R=%g +units=m +no_defs", p.Lon0, EarthRadius)
+}
+
+// WebMercatorRadius is the radius of the sphere of Web Mercator, WGS 84's
+// semi-major axis
+const WebMercatorRadius = 6378137.0
+
+// WebMercator is the Web Mercator (EPSG:3857) projection of web maps, a
+// spherical Mercator on a sphere of WebMercatorRadius rather than EarthRadius
+type WebMercator struct{}
+
+// Forward projects lat, lon
+func (WebMercator) Forward(lat, lon float64) (float64, float64) {
+	return WebMercatorRadius * radians(normalizeLon(lon)), WebMercatorRadius * math.Log(math.Tan(math.Pi/4+radians(lat)/2))
+}
+
+// Inverse returns the coordinates of x, y
+func (WebMercator) Inverse(x, y float64) (float64, float64) {
+	return degrees(2*math.Atan(math.Exp(y/WebMercatorRadius)) - math.Pi/2), normalizeLon(degrees(x / WebMercatorRadius))
+}
+
+// Proj4 describes the projection
+func (WebMercator) Proj4() string {
+	return "+proj=merc +a=6378137 +b=6378137 +lat_ts=0 +lon_0=0 +x_0=0 +y_0=0 +k=1 +units=m +nadgrids=@null +no_defs"
+}
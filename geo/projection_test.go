@@ -0,0 +1,59 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestProjectionRoundTrip(t *testing.T) {
+	projections := []Projection{
+		AzimuthalEquidistant{35.33, -97.28},
+		LambertConformal{Lat0: 25, Lon0: -95, Parallel1: 25, Parallel2: 25},
+		LambertConformal{Lat0: 23, Lon0: -96, Parallel1: 33, Parallel2: 45},
+		LambertConformal{Lat0: -30, Lon0: 140, Parallel1: -20, Parallel2: -40},
+		Mercator{-97},
+		WebMercator{},
+	}
+	points := [][2]float64{{35.33, -97.28}, {36.5, -95}, {30, -100.5}, {-33.9, 151.2}}
+	for _, p := range projections {
+		for _, pt := range points {
+			x, y := p.Forward(pt[0], pt[1])
+			lat, lon := p.Inverse(x, y)
+			if math.Abs(lat-pt[0]) > 1e-6 || math.Abs(lon-pt[1]) > 1e-6 {
+				t.Errorf("%s: %v round trips to %v, %v", p.Proj4(), pt, lat, lon)
+			}
+		}
+	}
+}
+
+func TestAzimuthalEquidistant(t *testing.T) {
+	p := AzimuthalEquidistant{35.33, -97.28}
+	lat, lon := Destination(35.33, -97.28, 90, 100000)
+	if x, y := p.Forward(lat, lon); math.Abs(x-100000) > 1e-3 || math.Abs(y) > 1e-3 {
+		t.Errorf("expected 100 km east, got %v, %v", x, y)
+	}
+}
+
+func TestLambertConformal(t *testing.T) {
+	// Snyder, Map Projections: A Working Manual, p. 296 (for a unit sphere)
+	p := LambertConformal{Lat0: 23, Lon0: -96, Parallel1: 33, Parallel2: 45}
+	x, y := p.Forward(35, -75)
+	if math.Abs(x/EarthRadius-0.2966785) > 1e-6 || math.Abs(y/EarthRadius-0.2462112) > 1e-6 {
+		t.Errorf("got %v, %v", x/EarthRadius, y/EarthRadius)
+	}
+}
+
+func TestMercator(t *testing.T) {
+	p := Mercator{-97}
+	if x, y := p.Forward(0, -96); math.Abs(x-EarthRadius*math.Pi/180) > 1e-6 || math.Abs(y) > 1e-6 {
+		t.Errorf("got %v, %v", x, y)
+	}
+}
+
+func TestWebMercator(t *testing.T) {
+	// the north west corner of the web map tile pyramid
+	x, y := WebMercator{}.Forward(85.0511287798, -180)
+	if math.Abs(x+20037508.34) > 0.01 || math.Abs(y-20037508.34) > 0.01 {
+		t.Errorf("got %v, %v", x, y)
+	}
+}
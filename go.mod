@@ -6,6 +6,7 @@ require (
 	github.com/cheggaaa/pb/v3 v3.0.4
 	github.com/davecgh/go-spew v1.1.1
 	github.com/dsnet/compress v0.0.1
+	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
 	github.com/inconshreveable/mousetrap v1.0.0 // indirect
 	github.com/llgcode/draw2d v0.0.0-20180817132918-587a55234ca2
 	github.com/sirupsen/logrus v1.6.0
@@ -0,0 +1,300 @@
+// Package grid resamples polar radar sweeps onto regular geographic grids, for
+// exporting to GIS formats.
+package grid
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/geo"
+	"github.com/kallsyms/go-nexrad/sites"
+)
+
+// Grid is a regular latitude/longitude (EPSG:4326) grid of values, or a
+// regular grid in a projection's plane. Values are stored in row major order
+// starting from the north west corner, with NaN where there is no data.
+type Grid struct {
+	// West and North are the coordinates of the outer corner of the first cell
+	West  float64
+	North float64
+	// DLon and DLat are the size of each cell in degrees
+	DLon   float64
+	DLat   float64
+	Width  int
+	Height int
+	Values []float32
+	// Projection, if set, is the projection of the grid. West, North, DLon
+	// and DLat are then x, y and sizes in meters in the projection's plane.
+	Projection geo.Projection
+}
+
+// At returns the value of the cell at x, y
+func (g *Grid) At(x, y int) float32 {
+	return g.Values[y*g.Width+x]
+}
+
+// Center returns the coordinates of the center of the cell at x, y
+func (g *Grid) Center(x, y int) (lat, lon float64) {
+	return g.Location(float64(x)+0.5, float64(y)+0.5)
+}
+
+// Location returns the coordinates of a point given in cells east and south
+// of the grid's north west corner, e.g. 1, 1 for the south east corner of
+// the first cell
+func (g *Grid) Location(x, y float64) (lat, lon float64) {
+	if g.Projection != nil {
+		return g.Projection.Inverse(g.West+x*g.DLon, g.North-y*g.DLat)
+	}
+	return g.North - y*g.DLat, g.West + x*g.DLon
+}
+
+// Pixel returns the position of the point in cells east and south of the
+// grid's north west corner, the inverse of Location
+func (g *Grid) Pixel(lat, lon float64) (x, y float64) {
+	if g.Projection != nil {
+		px, py := g.Projection.Forward(lat, lon)
+		return (px - g.West) / g.DLon, (g.North - py) / g.DLat
+	}
+	return (lon - g.West) / g.DLon, (g.North - lat) / g.DLat
+}
+
+// WorldFile returns the grid's ESRI world file, which georeferences an image
+// of the grid (one pixel per cell) in GIS tools: the cell size, rotation and
+// the center of the north west cell, in degrees or the projection's meters
+func (g *Grid) WorldFile() []byte {
+	return []byte(fmt.Sprintf("%.12g\n0\n0\n%.12g\n%.12g\n%.12g\n", g.DLon, -g.DLat, g.West+g.DLon/2, g.North-g.DLat/2))
+}
+
+// MomentFunc selects the moment to grid from a radial
+type MomentFunc func(m *archive2.Message31) *archive2.DataMoment
+
+// FromSweep grids the moment over a size x size cell square extending radius
+// meters from the radar in each direction. Cells are filled with the value of
+// the gate beneath their center; below threshold and range folded gates are NaN.
+func FromSweep(radials []*archive2.Message31, moment MomentFunc, radius float64, size int) *Grid {
+	if len(radials) == 0 {
+		return newGrid(size, nil)
+	}
+	lat, lon, _, _ := sites.Locate(radials[0])
+	return FromSweepAt(radials, moment, lat, lon, radius, size)
+}
+
+// FromSweepAt grids the moment like FromSweep, but over a square centered on
+// lat, lon, e.g. to zoom in on a storm away from the radar. Only the gates
+// within radius meters of the radar are gridded when it's centered on the
+// radar, so the square holds a disc; otherwise every gate under it is.
+func FromSweepAt(radials []*archive2.Message31, moment MomentFunc, lat, lon, radius float64, size int) *Grid {
+	g := newGrid(size, nil)
+	if len(radials) == 0 {
+		return g
+	}
+
+	north, _ := geo.Destination(lat, lon, 0, radius)
+	_, east := geo.Destination(lat, lon, 90, radius)
+	g.North = north
+	g.West = lon - (east - lon)
+	g.DLat = 2 * (north - lat) / float64(size)
+	g.DLon = 2 * (east - lon) / float64(size)
+
+	g.sample(radials, moment, sampleRange(radials[0], lat, lon, radius))
+	return g
+}
+
+// FromSweepProjected grids the moment like FromSweep, but over a square in the
+// projection's plane centered on the radar. Cells are radius*2/size meters
+// across.
+func FromSweepProjected(radials []*archive2.Message31, moment MomentFunc, radius float64, size int, proj geo.Projection) *Grid {
+	if len(radials) == 0 {
+		return newGrid(size, proj)
+	}
+	lat, lon, _, _ := sites.Locate(radials[0])
+	return FromSweepProjectedAt(radials, moment, lat, lon, radius, size, proj)
+}
+
+// FromSweepProjectedAt grids the moment like FromSweepProjected, but over a
+// square centered on lat, lon
+func FromSweepProjectedAt(radials []*archive2.Message31, moment MomentFunc, lat, lon, radius float64, size int, proj geo.Projection) *Grid {
+	g := newGrid(size, proj)
+	if len(radials) == 0 {
+		return g
+	}
+
+	x, y := proj.Forward(lat, lon)
+	g.West = x - radius
+	g.North = y + radius
+	g.DLon = 2 * radius / float64(size)
+	g.DLat = g.DLon
+	g.sample(radials, moment, sampleRange(radials[0], lat, lon, radius))
+	return g
+}
+
+// newGrid returns a size x size grid with no data
+func newGrid(size int, proj geo.Projection) *Grid {
+	g := &Grid{Width: size, Height: size, Values: make([]float32, size*size), Projection: proj}
+	for i := range g.Values {
+		g.Values[i] = float32(math.NaN())
+	}
+	return g
+}
+
+// sampleRange returns the range from the radar to sample a grid centered on
+// lat, lon out to: radius if it's centered on the radar, otherwise far enough
+// to reach its corners
+func sampleRange(radial *archive2.Message31, lat, lon, radius float64) float64 {
+	rlat, rlon, _, _ := sites.Locate(radial)
+	_, d := geo.BearingDistance(rlat, rlon, lat, lon)
+	if d < 1 {
+		return radius
+	}
+	return d + radius*math.Sqrt2
+}
+
+func (g *Grid) sample(radials []*archive2.Message31, moment MomentFunc, radius float64) {
+	s := NewSampler(radials, moment, radius)
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			g.Values[y*g.Width+x] = s.At(g.Center(x, y))
+		}
+	}
+}
+
+// Sampler looks up the value of a sweep at arbitrary points, for resampling it
+// onto grids other than Grid's, e.g. map tiles. It's safe for concurrent use.
+type Sampler struct {
+	// Smooth interpolates between the gates around points rather than
+	// taking the nearest, so zoomed in images aren't blocky
+	Smooth bool
+
+	lat, lon  float64
+	radius    float64
+	elevation float64
+	index     *RadialIndex
+	moments   map[*archive2.Message31]*archive2.DataMoment
+	gates     map[*archive2.Message31][]float32
+}
+
+// NewSampler returns a sampler of the moment out to radius meters from the
+// radar
+func NewSampler(radials []*archive2.Message31, moment MomentFunc, radius float64) *Sampler {
+	s := &Sampler{
+		radius:  radius,
+		index:   NewRadialIndex(radials),
+		moments: map[*archive2.Message31]*archive2.DataMoment{},
+		gates:   map[*archive2.Message31][]float32{},
+	}
+	if len(radials) > 0 {
+		s.lat, s.lon, _, _ = sites.Locate(radials[0])
+		s.elevation = float64(radials[0].Header.ElevationAngle)
+	}
+	for _, r := range radials {
+		if m := moment(r); m != nil && m.DataMomentRangeSampleInterval != 0 {
+			s.moments[r] = m
+			s.gates[r] = m.ScaledData()
+		}
+	}
+	return s
+}
+
+// At returns the value of the gate beneath the point, or NaN if it's out of
+// range, below threshold or range folded
+func (s *Sampler) At(lat, lon float64) float32 {
+	nan := float32(math.NaN())
+	azimuth, distance := geo.BearingDistance(s.lat, s.lon, lat, lon)
+	if distance > s.radius {
+		return nan
+	}
+	radial := s.index.Nearest(azimuth)
+	m, ok := s.moments[radial]
+	if !ok {
+		return nan
+	}
+	values := s.gates[radial]
+	slantRange := geo.SlantRange(distance, s.elevation)
+	g := (slantRange - float64(m.DataMomentRange)) / float64(m.DataMomentRangeSampleInterval)
+	gate := int(math.Round(g))
+	if gate < 0 || gate >= len(values) {
+		return nan
+	}
+	v := values[gate]
+	if v == archive2.MomentDataBelowThreshold || v == archive2.MomentDataFolded {
+		return nan
+	}
+	if !s.Smooth {
+		return v
+	}
+
+	// the radial on the other side of the point from the center of the
+	// nearest, weighted by how far the point is towards it
+	offset := math.Mod(azimuth-float64(radial.Header.IndexedAzimuth())+540, 360) - 180
+	neighbor := s.index.Nearest(azimuth + math.Copysign(s.index.spacing, offset))
+	w := math.Min(1, math.Abs(offset)/s.index.spacing)
+	sum, weight := Interpolate(values, g, 1-w)
+	if n, ok := s.moments[neighbor]; ok && neighbor != radial {
+		ng := (slantRange - float64(n.DataMomentRange)) / float64(n.DataMomentRangeSampleInterval)
+		nsum, nweight := Interpolate(s.gates[neighbor], ng, w)
+		sum, weight = sum+nsum, weight+nweight
+	}
+	if weight == 0 {
+		return v
+	}
+	return float32(sum / weight)
+}
+
+// Interpolate returns the weighted sum, and the sum of the weights, of the two
+// gates around the position g (in gates from the center of the first) for
+// linear interpolation between them, with the weights of the gates scaled by
+// w. Gates that are out of range, below threshold or range folded are left
+// out, so the sum is divided by the weights to get the value.
+func Interpolate(values []float32, g, w float64) (sum, weight float64) {
+	g0 := math.Floor(g)
+	f := g - g0
+	for i, gw := range [2]float64{1 - f, f} {
+		gate := int(g0) + i
+		if gate < 0 || gate >= len(values) || gw == 0 {
+			continue
+		}
+		v := values[gate]
+		if v == archive2.MomentDataBelowThreshold || v == archive2.MomentDataFolded {
+			continue
+		}
+		sum += float64(v) * gw * w
+		weight += gw * w
+	}
+	return sum, weight
+}
+
+// RadialIndex looks up the radial covering a given azimuth
+type RadialIndex struct {
+	spacing float64
+	bins    []*archive2.Message31
+}
+
+// NewRadialIndex indexes the radials of a sweep by their azimuth, or indexed
+// azimuth if they're indexed to constant angles
+func NewRadialIndex(radials []*archive2.Message31) *RadialIndex {
+	spacing := 1.0
+	if len(radials) > 0 {
+		spacing = radials[0].Header.AzimuthResolutionSpacing()
+	}
+	idx := &RadialIndex{
+		spacing: spacing,
+		bins:    make([]*archive2.Message31, int(math.Round(360/spacing))),
+	}
+	for _, r := range radials {
+		idx.bins[idx.bin(float64(r.Header.IndexedAzimuth()))] = r
+	}
+	return idx
+}
+
+func (idx *RadialIndex) bin(azimuth float64) int {
+	n := len(idx.bins)
+	i := int(math.Floor(azimuth / idx.spacing))
+	return ((i % n) + n) % n
+}
+
+// Nearest returns the radial covering the azimuth (in degrees), or nil if the
+// sweep has no radial there.
+func (idx *RadialIndex) Nearest(azimuth float64) *archive2.Message31 {
+	return idx.bins[idx.bin(azimuth)]
+}
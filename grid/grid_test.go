@@ -0,0 +1,149 @@
+package grid
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/geo"
+	"github.com/kallsyms/go-nexrad/internal/testvol"
+)
+
+// testSweep has 0 dBZ everywhere within 100 km of the radar except the south
+// east quadrant, which is empty
+func testSweep() []*archive2.Message31 {
+	return testvol.Filled(0.5, 0, testvol.SouthEast)
+}
+
+func ref(m *archive2.Message31) *archive2.DataMoment { return m.ReflectivityData }
+
+func TestFromSweep(t *testing.T) {
+	g := FromSweep(testSweep(), ref, 100000, 100)
+
+	if lat, lon := g.Center(50, 50); math.Abs(lat-35) > 0.02 || math.Abs(lon+97) > 0.02 {
+		t.Errorf("grid not centered on the radar: %v, %v", lat, lon)
+	}
+	if v := g.At(25, 25); v != 0 {
+		t.Errorf("expected 0 dBZ in the north west, got %v", v)
+	}
+	if v := g.At(75, 75); !math.IsNaN(float64(v)) {
+		t.Errorf("expected no data in the south east, got %v", v)
+	}
+	if v := g.At(0, 0); !math.IsNaN(float64(v)) {
+		t.Errorf("expected no data outside the radius, got %v", v)
+	}
+}
+
+func TestFromSweepAt(t *testing.T) {
+	// 20 km square centered 50 km north of the radar
+	lat, _ := geo.Destination(35, -97, 0, 50000)
+	g := FromSweepAt(testSweep(), ref, lat, -97, 10000, 20)
+	if clat, clon := g.Center(10, 10); math.Abs(clat-lat) > 0.01 || math.Abs(clon+97) > 0.01 {
+		t.Errorf("grid not centered on %v, -97: %v, %v", lat, clat, clon)
+	}
+	// every cell is within the sweep, including the corners
+	for _, c := range [][2]int{{0, 0}, {19, 19}, {10, 10}} {
+		if v := g.At(c[0], c[1]); v != 0 {
+			t.Errorf("expected 0 dBZ at %v, got %v", c, v)
+		}
+	}
+}
+
+func TestPixel(t *testing.T) {
+	proj := geo.LambertConformal{Lat0: 25, Lon0: -95, Parallel1: 25, Parallel2: 25}
+	for _, g := range []*Grid{FromSweep(testSweep(), ref, 100000, 100), FromSweepProjected(testSweep(), ref, 100000, 100, proj)} {
+		lat, lon := g.Location(12.5, 80)
+		if x, y := g.Pixel(lat, lon); math.Abs(x-12.5) > 1e-6 || math.Abs(y-80) > 1e-6 {
+			t.Errorf("expected 12.5, 80, got %v, %v", x, y)
+		}
+	}
+}
+
+func TestWorldFile(t *testing.T) {
+	g := &Grid{West: -98, North: 36, DLon: 0.5, DLat: 0.25, Width: 4, Height: 8}
+	want := "0.5\n0\n0\n-0.25\n-97.75\n35.875\n"
+	if got := string(g.WorldFile()); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestSampler(t *testing.T) {
+	s := NewSampler(testSweep(), ref, 100000)
+	tests := []struct {
+		lat, lon float64
+		nan      bool
+	}{
+		{35.3, -97.3, false},
+		{34.7, -96.7, true},
+		{36, -97, true},
+	}
+	for _, tt := range tests {
+		v := s.At(tt.lat, tt.lon)
+		if math.IsNaN(float64(v)) != tt.nan || (!tt.nan && v != 0) {
+			t.Errorf("%v, %v: got %v", tt.lat, tt.lon, v)
+		}
+	}
+}
+
+func TestSamplerSmooth(t *testing.T) {
+	// reflectivity rising 0.5 dBZ a gate
+	radials := testSweep()
+	for _, r := range radials {
+		for i := range r.ReflectivityData.Data {
+			r.ReflectivityData.Data[i] = byte(66 + i)
+		}
+	}
+	s := NewSampler(radials, ref, 100000)
+	lat, lon := geo.Destination(35, -97, 30, 40300)
+	g := (geo.SlantRange(40300, 0.5) - 500) / 1000
+	if v := s.At(lat, lon); float64(v) != math.Round(g)/2 {
+		t.Errorf("expected the nearest gate, %v dBZ, got %v", math.Round(g)/2, v)
+	}
+	s.Smooth = true
+	if v := s.At(lat, lon); math.Abs(float64(v)-g/2) > 1e-3 {
+		t.Errorf("expected %v dBZ between the gates, got %v", g/2, v)
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	values := []float32{10, 20, archive2.MomentDataBelowThreshold}
+	if sum, w := Interpolate(values, 0.25, 1); sum/w != 12.5 {
+		t.Errorf("expected 12.5, got %v", sum/w)
+	}
+	// the below threshold gate is left out
+	if sum, w := Interpolate(values, 1.5, 0.5); sum/w != 20 || w != 0.25 {
+		t.Errorf("expected 20 at a weight of 0.25, got %v at %v", sum/w, w)
+	}
+	if _, w := Interpolate(values, 5, 1); w != 0 {
+		t.Errorf("expected no weight out of range, got %v", w)
+	}
+}
+
+func TestFromSweepWithoutVolumeData(t *testing.T) {
+	radials := testSweep()
+	for _, r := range radials {
+		r.VolumeData = archive2.VolumeData{}
+		copy(r.Header.RadarIdentifier[:], "KTLX")
+	}
+	g := FromSweep(radials, ref, 100000, 100)
+	if lat, lon := g.Center(50, 50); math.Abs(lat-35.33) > 0.02 || math.Abs(lon+97.28) > 0.02 {
+		t.Errorf("grid not centered on KTLX: %v, %v", lat, lon)
+	}
+}
+
+func TestFromSweepProjected(t *testing.T) {
+	proj := geo.LambertConformal{Lat0: 25, Lon0: -95, Parallel1: 25, Parallel2: 25}
+	g := FromSweepProjected(testSweep(), ref, 100000, 100, proj)
+	if g.DLon != 2000 || g.DLat != 2000 {
+		t.Errorf("expected 2 km cells, got %v x %v", g.DLon, g.DLat)
+	}
+	if lat, lon := g.Location(50, 50); math.Abs(lat-35) > 1e-6 || math.Abs(lon+97) > 1e-6 {
+		t.Errorf("grid not centered on the radar: %v, %v", lat, lon)
+	}
+	if v := g.At(25, 25); v != 0 {
+		t.Errorf("expected 0 dBZ in the north west, got %v", v)
+	}
+	if v := g.At(75, 75); !math.IsNaN(float64(v)) {
+		t.Errorf("expected no data in the south east, got %v", v)
+	}
+}
@@ -0,0 +1,88 @@
+package grid
+
+import (
+	"math"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/geo"
+)
+
+// FromBounds returns a grid with no data covering south to north and west to
+// east, in degrees, width cells across, for mosaicking sweeps into with
+// MergeSweep. Cells are square: in degrees on a lat/lon grid, otherwise in
+// meters in the projection's plane, covering the projected bounds.
+func FromBounds(south, west, north, east float64, width int, proj geo.Projection) *Grid {
+	g := &Grid{Width: width, Projection: proj}
+	var height float64
+	if proj == nil {
+		g.West, g.North = west, north
+		g.DLon = (east - west) / float64(width)
+		height = (north - south) / g.DLon
+	} else {
+		// the edges of the bounds are curves in most projections, so points
+		// along them are projected to find the extent
+		minX, minY := math.Inf(1), math.Inf(1)
+		maxX, maxY := math.Inf(-1), math.Inf(-1)
+		const steps = 16
+		for i := 0; i <= steps; i++ {
+			f := float64(i) / steps
+			lat, lon := south+f*(north-south), west+f*(east-west)
+			for _, p := range [][2]float64{{south, lon}, {north, lon}, {lat, west}, {lat, east}} {
+				x, y := proj.Forward(p[0], p[1])
+				minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+				minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+			}
+		}
+		g.West, g.North = minX, maxY
+		g.DLon = (maxX - minX) / float64(width)
+		height = (maxY - minY) / g.DLon
+	}
+	g.DLat = g.DLon
+	g.Height = int(math.Max(1, math.Round(height)))
+	g.Values = make([]float32, g.Width*g.Height)
+	for i := range g.Values {
+		g.Values[i] = float32(math.NaN())
+	}
+	return g
+}
+
+// MergeSweep samples the moment of the sweep into the cells of the grid within
+// radius meters of its radar, keeping the larger value where a cell already
+// has one. Merging the sweeps of several radars mosaics them, with the
+// strongest echo winning where they overlap.
+func (g *Grid) MergeSweep(radials []*archive2.Message31, moment MomentFunc, radius float64) {
+	if len(radials) == 0 {
+		return
+	}
+	s := NewSampler(radials, moment, radius)
+	x0, y0, x1, y1 := g.cover(s.lat, s.lon, radius)
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			v := s.At(g.Center(x, y))
+			if math.IsNaN(float64(v)) {
+				continue
+			}
+			if c := g.Values[y*g.Width+x]; math.IsNaN(float64(c)) || v > c {
+				g.Values[y*g.Width+x] = v
+			}
+		}
+	}
+}
+
+// cover returns the cells x0 to x1 and y0 to y1 (exclusive) that hold the
+// circle of radius meters around lat, lon, clamped to the grid
+func (g *Grid) cover(lat, lon, radius float64) (x0, y0, x1, y1 int) {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for bearing := 0.0; bearing < 360; bearing += 5 {
+		x, y := g.Pixel(geo.Destination(lat, lon, bearing, radius))
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	clamp := func(v float64, max int) int {
+		return int(math.Max(0, math.Min(float64(max), v)))
+	}
+	// a cell either side covers the circle bulging between the bearings
+	return clamp(math.Floor(minX)-1, g.Width), clamp(math.Floor(minY)-1, g.Height),
+		clamp(math.Ceil(maxX)+1, g.Width), clamp(math.Ceil(maxY)+1, g.Height)
+}
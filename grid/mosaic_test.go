@@ -0,0 +1,58 @@
+package grid
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kallsyms/go-nexrad/geo"
+)
+
+func TestFromBounds(t *testing.T) {
+	g := FromBounds(34, -98, 36, -94, 400, nil)
+	if g.Width != 400 || g.Height != 200 || g.DLat != 0.01 {
+		t.Errorf("unexpected %dx%d grid of %v degree cells", g.Width, g.Height, g.DLat)
+	}
+	if lat, lon := g.Center(0, 0); lat != 35.995 || math.Abs(lon+97.995) > 1e-9 {
+		t.Errorf("unexpected north west cell at %v, %v", lat, lon)
+	}
+
+	m := geo.Mercator{}
+	g = FromBounds(34, -98, 36, -94, 400, m)
+	x, _ := m.Forward(35, -98)
+	if g.West != x || math.Abs(g.DLon-g.DLat) > 1e-9 || g.Height < 200 {
+		t.Errorf("unexpected projected grid %+v", g)
+	}
+}
+
+func TestMergeSweep(t *testing.T) {
+	// two radars a degree of longitude apart, with 0 and 10 dBZ
+	a, b := testSweep(), testSweep()
+	for _, r := range b {
+		r.VolumeData.Long = -96
+		for i := range r.ReflectivityData.Data {
+			if r.ReflectivityData.Data[i] != 0 {
+				r.ReflectivityData.Data[i] = 86
+			}
+		}
+	}
+	g := FromBounds(34, -98, 36, -94, 400, nil)
+	g.MergeSweep(a, ref, 100000)
+	g.MergeSweep(b, ref, 100000)
+
+	at := func(lat, lon float64) float32 {
+		x, y := g.Pixel(lat, lon)
+		return g.At(int(x), int(y))
+	}
+	if v := at(35.2, -97.5); v != 0 {
+		t.Errorf("expected 0 dBZ west of the first radar, got %v", v)
+	}
+	if v := at(35.2, -96.5); v != 10 {
+		t.Errorf("expected the larger 10 dBZ between the radars, got %v", v)
+	}
+	if v := at(35.2, -95.5); v != 10 {
+		t.Errorf("expected 10 dBZ east of the second radar, got %v", v)
+	}
+	if v := at(35.95, -94.05); !math.IsNaN(float64(v)) {
+		t.Errorf("expected no data out of range, got %v", v)
+	}
+}
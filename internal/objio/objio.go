@@ -0,0 +1,99 @@
+// Package objio holds the helpers shared by the packages reading volumes from
+// buckets and files: anonymous S3 bucket listings and gzip decompression.
+package objio
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Object is an object in a bucket listing
+type Object struct {
+	Key  string
+	Size int64
+}
+
+// CommonPrefix is a prefix shared by the keys grouped by a listing's delimiter
+type CommonPrefix struct {
+	Prefix string
+}
+
+// ListResult is the response to an S3 ListObjectsV2 request
+type ListResult struct {
+	Contents              []Object
+	CommonPrefixes        []CommonPrefix
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// List returns the objects in the bucket under prefix, using the S3
+// ListObjectsV2 API without credentials. If delimiter isn't empty, keys
+// containing it after the prefix are grouped and returned as common prefixes
+// instead.
+func List(ctx context.Context, client *http.Client, bucket, prefix, delimiter string) ([]Object, []string, error) {
+	var objects []Object
+	var prefixes []string
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if delimiter != "" {
+			q.Set("delimiter", delimiter)
+		}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		req, err := http.NewRequest("GET", strings.TrimRight(bucket, "/")+"/?"+q.Encode(), nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, nil, err
+		}
+		var result ListResult
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("listing %s: %s", prefix, resp.Status)
+		} else {
+			err = xml.NewDecoder(resp.Body).Decode(&result)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		objects = append(objects, result.Contents...)
+		for _, p := range result.CommonPrefixes {
+			prefixes = append(prefixes, p.Prefix)
+		}
+		if !result.IsTruncated {
+			return objects, prefixes, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Gunzip returns a reader decompressing r, which it closes when closed. r is
+// closed if it isn't gzipped.
+func Gunzip(r io.ReadCloser) (io.ReadCloser, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	return &gzipReadCloser{gz, r}, nil
+}
+
+type gzipReadCloser struct {
+	*gzip.Reader
+	r io.Closer
+}
+
+func (g *gzipReadCloser) Close() error {
+	g.Reader.Close()
+	return g.r.Close()
+}
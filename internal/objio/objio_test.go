@@ -0,0 +1,86 @@
+package objio
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	// a bucket listing a page at a time
+	pages := map[string]ListResult{
+		"": {
+			Contents:              []Object{{Key: "KTLX/1/a", Size: 1}},
+			IsTruncated:           true,
+			NextContinuationToken: "next",
+		},
+		"next": {
+			Contents:       []Object{{Key: "KTLX/1/b", Size: 2}},
+			CommonPrefixes: []CommonPrefix{{Prefix: "KTLX/2/"}},
+		},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("list-type") != "2" || q.Get("prefix") != "KTLX/" || q.Get("delimiter") != "/" {
+			http.Error(w, "bad query "+r.URL.RawQuery, http.StatusBadRequest)
+			return
+		}
+		xml.NewEncoder(w).Encode(pages[q.Get("continuation-token")])
+	}))
+	defer srv.Close()
+
+	objects, prefixes, err := List(context.Background(), http.DefaultClient, srv.URL, "KTLX/", "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 || objects[1].Key != "KTLX/1/b" || objects[1].Size != 2 {
+		t.Errorf("expected the objects of both pages, got %+v", objects)
+	}
+	if len(prefixes) != 1 || prefixes[0] != "KTLX/2/" {
+		t.Errorf("unexpected common prefixes %v", prefixes)
+	}
+
+	if _, _, err := List(context.Background(), http.DefaultClient, srv.URL, "KINX/", ""); err == nil {
+		t.Error("expected an error for a failed listing")
+	}
+}
+
+type closer struct {
+	*bytes.Reader
+	closed bool
+}
+
+func (c *closer) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestGunzip(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	w.Write([]byte("volume"))
+	w.Close()
+
+	c := &closer{Reader: bytes.NewReader(buf.Bytes())}
+	r, err := Gunzip(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadAll(r); err != nil || string(data) != "volume" {
+		t.Errorf("unexpected data %q (%v)", data, err)
+	}
+	r.Close()
+	if !c.closed {
+		t.Error("underlying reader wasn't closed")
+	}
+
+	c = &closer{Reader: bytes.NewReader([]byte("volume"))}
+	if _, err := Gunzip(c); err == nil || !c.closed {
+		t.Errorf("expected an error closing the reader for data that isn't gzipped, got %v", err)
+	}
+}
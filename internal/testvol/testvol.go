@@ -0,0 +1,103 @@
+// Package testvol builds small synthetic volumes and sweeps for tests of the
+// packages that encode, export, grid and render them.
+package testvol
+
+import "github.com/kallsyms/go-nexrad/archive2"
+
+// Reflectivity returns 4 gates of reflectivity every 250 m from 2.125 km:
+// below threshold, range folded, 0 dBZ and 20 dBZ
+func Reflectivity() *archive2.DataMoment {
+	return &archive2.DataMoment{
+		GenericDataMoment: archive2.GenericDataMoment{
+			NumberDataMomentGates:         4,
+			DataMomentRange:               2125,
+			DataMomentRangeSampleInterval: 250,
+			DataWordSize:                  8,
+			Scale:                         2,
+			Offset:                        66,
+		},
+		Data: []byte{0, 1, 66, 106},
+	}
+}
+
+// Sweep returns elevation number elv (at elv/2 degrees) of n radials of
+// Reflectivity, centered every 360/n degrees, from KTST at 35N 97W. Sweeps of
+// 720 radials are super resolution.
+func Sweep(elv, n int) []*archive2.Message31 {
+	spacing := float32(360) / float32(n)
+	var radials []*archive2.Message31
+	for az := 0; az < n; az++ {
+		m31 := &archive2.Message31{}
+		copy(m31.Header.RadarIdentifier[:], "KTST")
+		m31.Header.CollectionDate = 18000
+		m31.Header.AzimuthNumber = uint16(az + 1)
+		m31.Header.AzimuthAngle = float32(az)*spacing + spacing/2
+		if n == 720 {
+			m31.Header.AzimuthResolutionSpacingCode = 1
+		}
+		m31.Header.ElevationNumber = uint8(elv)
+		m31.Header.ElevationAngle = float32(elv) / 2
+		m31.VolumeData.Lat = 35
+		m31.VolumeData.Long = -97
+		m31.ReflectivityData = Reflectivity()
+		radials = append(radials, m31)
+	}
+	return radials
+}
+
+// Volume returns a KTST volume of the elevations, each a Sweep of 360 radials
+func Volume(elevations int) *archive2.Archive2 {
+	ar2 := &archive2.Archive2{ElevationScans: map[int][]*archive2.Message31{}}
+	copy(ar2.VolumeHeader.ICAO[:], "KTST")
+	for elv := 1; elv <= elevations; elv++ {
+		ar2.ElevationScans[elv] = Sweep(elv, 360)
+	}
+	return ar2
+}
+
+// Filled returns a sweep at the elevation angle of 360 radials centered on the
+// half degrees, from KTST at 35N 97W with its antenna 390 m above sea level.
+// Each radial has 100 gates of reflectivity every 1 km from 500 m, out to
+// 100 km, of dbz, except for the radials whose whole degree of azimuth empty
+// reports, which are below threshold. empty may be nil.
+func Filled(elevation, dbz float32, empty func(az int) bool) []*archive2.Message31 {
+	var radials []*archive2.Message31
+	for az := 0; az < 360; az++ {
+		m31 := &archive2.Message31{}
+		copy(m31.Header.RadarIdentifier[:], "KTST")
+		m31.Header.CollectionDate = 18000
+		m31.Header.AzimuthNumber = uint16(az + 1)
+		m31.Header.AzimuthAngle = float32(az) + 0.5
+		m31.Header.AzimuthResolutionSpacingCode = 2
+		m31.Header.ElevationAngle = elevation
+		m31.VolumeData.Lat = 35
+		m31.VolumeData.Long = -97
+		m31.VolumeData.SiteHeight = 370
+		m31.VolumeData.FeedhornHeight = 20
+		data := make([]byte, 100)
+		if empty == nil || !empty(az) {
+			for i := range data {
+				data[i] = byte(dbz*2 + 66)
+			}
+		}
+		m31.ReflectivityData = &archive2.DataMoment{
+			GenericDataMoment: archive2.GenericDataMoment{
+				NumberDataMomentGates:         100,
+				DataMomentRange:               500,
+				DataMomentRangeSampleInterval: 1000,
+				DataWordSize:                  8,
+				Scale:                         2,
+				Offset:                        66,
+			},
+			Data: data,
+		}
+		radials = append(radials, m31)
+	}
+	return radials
+}
+
+// SouthEast reports whether the azimuth is in the south east quadrant, to
+// leave it empty in a Filled sweep
+func SouthEast(az int) bool {
+	return az >= 90 && az < 180
+}
@@ -0,0 +1,245 @@
+package level3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	metersPerNM = 1852
+	mpsPerKnot  = 1852.0 / 3600
+)
+
+// decodeTabular decodes the tabular alphanumeric block into pages of lines
+func (p *Product) decodeTabular(b []byte) error {
+	r := bytes.NewReader(b)
+	var block struct {
+		Divider int16
+		ID      int16
+		Length  int32
+	}
+	if err := binary.Read(r, binary.BigEndian, &block); err != nil {
+		return fmt.Errorf("level3: failed to read tabular block: %s", err)
+	}
+	if block.Divider != -1 || block.ID != 3 {
+		return fmt.Errorf("level3: bad tabular block header %d/%d", block.Divider, block.ID)
+	}
+	// the block repeats the message header and product description block
+	r.Seek(headerSize, io.SeekCurrent)
+	var pages struct {
+		Divider int16
+		Pages   int16
+	}
+	if err := binary.Read(r, binary.BigEndian, &pages); err != nil {
+		return fmt.Errorf("level3: failed to read tabular block: %s", err)
+	}
+
+	for i := 0; i < int(pages.Pages); i++ {
+		var page []string
+		for {
+			var n int16
+			if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+				return fmt.Errorf("level3: failed to read tabular page %d: %s", i, err)
+			}
+			// pages end with -1
+			if n == -1 {
+				break
+			}
+			if n < 0 || int(n) > r.Len() {
+				return fmt.Errorf("level3: bad line length %d on tabular page %d", n, i)
+			}
+			line := make([]byte, n)
+			r.Read(line)
+			page = append(page, strings.TrimRight(string(line), " "))
+		}
+		p.Tabular = append(p.Tabular, page)
+	}
+	return nil
+}
+
+// Position is the location of a feature relative to the radar
+type Position struct {
+	// Azimuth in degrees clockwise from north
+	Azimuth float64
+	// Range in meters
+	Range float64
+}
+
+// symbolPosition converts I and J (km/4 east and south of the radar) to a
+// position
+func symbolPosition(i, j int) Position {
+	east, north := float64(i)*250, -float64(j)*250
+	az := math.Atan2(east, north) * 180 / math.Pi
+	if az < 0 {
+		az += 360
+	}
+	return Position{Azimuth: az, Range: math.Hypot(east, north)}
+}
+
+// StormTrack is a storm cell tracked by the storm cell identification and
+// tracking algorithm, from the storm tracking information (NST) product
+type StormTrack struct {
+	ID       string
+	Position Position
+	// New is set for cells first identified in this volume, which have no
+	// movement or forecast
+	New bool
+	// Direction the cell is moving from, in degrees, and its speed in m/s
+	Direction float64
+	Speed     float64
+	// Forecast positions in 15 minute steps, up to an hour. Cells forecast
+	// to dissipate have fewer.
+	Forecast []Position
+	// ForecastError is the error of the previous volume's forecast of the
+	// cell, and MeanError the mean over the cell's life, in meters
+	ForecastError float64
+	MeanError     float64
+}
+
+var (
+	// a row of the storm position/forecast table, e.g.
+	//   U3    245/ 71    251/ 33     243/ 63   240/ 55   237/ 47   234/ 39     1.3/ 1.2
+	//   V1    199/ 94       NEW        NO DATA   NO DATA   NO DATA   NO DATA     0.0/ 0.0
+	stormTrackRow  = regexp.MustCompile(`^\s*([A-Z][0-9])\s+(\d+)/\s*(\d+)\s+(?:(NEW)|(\d+)/\s*(\d+))\s+(.*?)\s*(\d+\.\d+)/\s*(\d+\.\d+)\s*$`)
+	forecastColumn = regexp.MustCompile(`(\d+)/\s*(\d+)|NO DATA`)
+)
+
+// StormTracks parses the storm cells from the tabular block of a storm
+// tracking information (NST) product
+func (p *Product) StormTracks() ([]StormTrack, error) {
+	if len(p.Tabular) == 0 {
+		return nil, fmt.Errorf("level3: %s product has no tabular block", p.Name())
+	}
+	var tracks []StormTrack
+	for _, page := range p.Tabular {
+		for _, line := range page {
+			m := stormTrackRow.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			t := StormTrack{
+				ID:            m[1],
+				Position:      Position{Azimuth: atof(m[2]), Range: atof(m[3]) * metersPerNM},
+				New:           m[4] != "",
+				ForecastError: atof(m[8]) * metersPerNM,
+				MeanError:     atof(m[9]) * metersPerNM,
+			}
+			if !t.New {
+				t.Direction = atof(m[5])
+				t.Speed = atof(m[6]) * mpsPerKnot
+			}
+			for _, f := range forecastColumn.FindAllStringSubmatch(m[7], -1) {
+				if f[1] == "" {
+					break
+				}
+				t.Forecast = append(t.Forecast, Position{Azimuth: atof(f[1]), Range: atof(f[2]) * metersPerNM})
+			}
+			tracks = append(tracks, t)
+		}
+	}
+	return tracks, nil
+}
+
+func atof(s string) float64 {
+	f, _ := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return f
+}
+
+// HailIndex is the hail detection algorithm's estimate for a storm cell, from
+// the hail index (NHI) product
+type HailIndex struct {
+	ID       string
+	Position Position
+	// POH and POSH are the probability of hail and of severe hail, in percent.
+	// They're -999 when not computed, e.g. for cells too close to the radar.
+	POH  int
+	POSH int
+	// MaxSize is the maximum expected hail size in whole inches
+	MaxSize int
+}
+
+// HailIndices returns the hail indices of the storm cells in a hail index
+// (NHI) product. Cells are identified by the storm ID symbol at the same
+// position.
+func (p *Product) HailIndices() []HailIndex {
+	ids := p.stormIDs()
+	var hail []HailIndex
+	for _, s := range p.Symbols {
+		if s.Packet != packetHailIndex || len(s.Values) < 3 {
+			continue
+		}
+		hail = append(hail, HailIndex{
+			ID:       ids[[2]int{s.I, s.J}],
+			Position: symbolPosition(s.I, s.J),
+			POH:      s.Values[0],
+			POSH:     s.Values[1],
+			MaxSize:  s.Values[2],
+		})
+	}
+	return hail
+}
+
+// stormIDs returns the storm IDs of the product by their position
+func (p *Product) stormIDs() map[[2]int]string {
+	ids := map[[2]int]string{}
+	for _, s := range p.Symbols {
+		if s.Packet == packetStormID {
+			ids[[2]int{s.I, s.J}] = s.Text
+		}
+	}
+	return ids
+}
+
+// Mesocyclone is a circulation from a mesocyclone (NME) or mesocyclone
+// detection (NMD) product
+type Mesocyclone struct {
+	// ID is the storm cell the circulation is in, if known
+	ID       string
+	Position Position
+	// Radius of the circulation in meters
+	Radius float64
+	// Shear is set for 3D correlated shear features, which didn't meet the
+	// mesocyclone criteria
+	Shear bool
+	// FeatureType is the point feature type of mesocyclone detection
+	// algorithm features, or 0
+	FeatureType int
+}
+
+// Mesocyclones returns the circulations in a mesocyclone product
+func (p *Product) Mesocyclones() []Mesocyclone {
+	ids := p.stormIDs()
+	var mesos []Mesocyclone
+	for _, s := range p.Symbols {
+		if len(s.Values) < 1 {
+			continue
+		}
+		m := Mesocyclone{
+			ID:       ids[[2]int{s.I, s.J}],
+			Position: symbolPosition(s.I, s.J),
+		}
+		switch s.Packet {
+		case packetMesocyclone:
+			m.Radius = float64(s.Values[0]) * 250
+		case packetCorrelatedShear:
+			m.Radius = float64(s.Values[0]) * 250
+			m.Shear = true
+		case packetPointFeature:
+			if len(s.Values) < 2 {
+				continue
+			}
+			m.FeatureType = s.Values[0]
+			m.Radius = float64(s.Values[1]) * 250
+		default:
+			continue
+		}
+		mesos = append(mesos, m)
+	}
+	return mesos
+}
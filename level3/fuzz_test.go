@@ -0,0 +1,53 @@
+package level3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// FuzzDecode checks Decode returns errors, rather than panicking or allocating
+// without bound, on corrupt products. Run it with
+//
+//	go test ./level3 -run '^$' -fuzz FuzzDecode -fuzztime 1m
+//
+// Inputs that found bugs are kept in testdata/fuzz, and run as regression
+// tests by go test.
+func FuzzDecode(f *testing.F) {
+	digital := &bytes.Buffer{}
+	binary.Write(digital, binary.BigEndian, []int16{packetDigitalRadial, 0, 3, 256, 256, 999, 1, 3, 0, 5})
+	digital.Write([]byte{0, 1, 100, 0})
+	pd := ProductDescription{Code: 94, Dependent3: 5}
+	pd.Thresholds[1] = 5
+	pd.Thresholds[2] = 254
+	f.Add(buildProduct(f, pd, digital.Bytes(), false))
+	f.Add(buildProduct(f, pd, digital.Bytes(), true))
+
+	rle := &bytes.Buffer{}
+	binary.Write(rle, binary.BigEndian, []int16{packetRadial, 0, 6, 256, 256, 999, 1, 2, 3595, 10})
+	rle.Write([]byte{0x30, 0x25, 0x1f, 0x00})
+	binary.Write(rle, binary.BigEndian, []int16{packetStormID, 6, 10, -20})
+	rle.Write([]byte("A0"))
+	f.Add(buildProduct(f, ProductDescription{Code: 19}, rle.Bytes(), false))
+
+	raster := &bytes.Buffer{}
+	binary.Write(raster, binary.BigEndian, []int16{packetRaster, -32768, 0x00c0, -2048, -2048, 4, 0, 4, 0, 1, 2, 2})
+	raster.Write([]byte{0x21, 0x13})
+	f.Add(buildProduct(f, ProductDescription{Code: 37}, raster.Bytes(), false))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		if p.Radial != nil {
+			for _, r := range p.Radial.Radials {
+				if len(r.Levels) > p.Radial.NumberBins {
+					t.Fatalf("radial of %d levels in an image of %d bins", len(r.Levels), p.Radial.NumberBins)
+				}
+				p.Values(r.Levels)
+			}
+			p.Grid(230000, 8)
+		}
+	})
+}
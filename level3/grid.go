@@ -0,0 +1,72 @@
+package level3
+
+import (
+	"errors"
+	"math"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/geo"
+	"github.com/kallsyms/go-nexrad/grid"
+)
+
+// Grid resamples the radial image of the product onto a size x size cell
+// latitude/longitude grid extending radius meters from the radar in each
+// direction, like grid.FromSweep does archive 2 sweeps. Cells take the value
+// of the bin beneath their center; bins without a value are NaN.
+func (p *Product) Grid(radius float64, size int) (*grid.Grid, error) {
+	if p.Radial == nil {
+		return nil, errors.New("level3: product has no radial image")
+	}
+	spacing := p.GateSpacing()
+	if spacing == 0 {
+		return nil, errors.New("level3: unknown bin spacing")
+	}
+
+	// the radial covering each tenth of a degree of azimuth, if any
+	var radials [3600]*Radial
+	for i := range p.Radial.Radials {
+		r := &p.Radial.Radials[i]
+		from := int(math.Round(float64(r.StartAngle) * 10))
+		width := int(math.Min(math.Round(float64(r.AngleDelta)*10), 3600))
+		for a := 0; a < width; a++ {
+			radials[((from+a)%3600+3600)%3600] = r
+		}
+	}
+
+	lat, lon := p.Description.Latitude(), p.Description.Longitude()
+	elevation := p.Description.ElevationAngle()
+	north, _ := geo.Destination(lat, lon, 0, radius)
+	_, east := geo.Destination(lat, lon, 90, radius)
+	g := &grid.Grid{
+		North:  north,
+		West:   lon - (east - lon),
+		DLat:   2 * (north - lat) / float64(size),
+		DLon:   2 * (east - lon) / float64(size),
+		Width:  size,
+		Height: size,
+		Values: make([]float32, size*size),
+	}
+	nan := float32(math.NaN())
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			g.Values[y*size+x] = nan
+			clat, clon := g.Center(x, y)
+			azimuth, distance := geo.BearingDistance(lat, lon, clat, clon)
+			if distance > radius {
+				continue
+			}
+			r := radials[int(azimuth*10)%3600]
+			if r == nil {
+				continue
+			}
+			bin := int(geo.SlantRange(distance, elevation)/spacing) - p.Radial.FirstBin
+			if bin < 0 || bin >= len(r.Levels) {
+				continue
+			}
+			if v := p.Value(r.Levels[bin]); v != archive2.MomentDataBelowThreshold && v != archive2.MomentDataFolded {
+				g.Values[y*size+x] = v
+			}
+		}
+	}
+	return g, nil
+}
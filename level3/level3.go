@@ -0,0 +1,239 @@
+// Package level3 decodes NEXRAD Level III (NIDS) products: the message header,
+// product description block and the radial and raster symbology packets.
+//
+// Digital products (e.g. N0Q reflectivity, N0U velocity, DVL and EET) are
+// mapped from data levels to physical values, as are the older 16 level
+// products whose thresholds are stored in the product description block.
+//
+// See the Product Specification ICD (2620001)
+// https://www.roc.noaa.gov/wsr88d/PublicDocs/ICDs/2620001Y.pdf
+package level3
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"time"
+)
+
+// MessageHeader starts every product
+type MessageHeader struct {
+	// Code is the product code, e.g. 94 for digital reflectivity
+	Code int16
+	// X_Date and X_Time are the NEXRAD modified julian date (1970/1/1 = 1) and
+	// seconds past midnight the message was generated at
+	X_Date int16
+	X_Time int32
+	// Length of the message in bytes, including the header
+	Length        int32
+	SourceID      int16
+	DestinationID int16
+	NumberBlocks  int16
+}
+
+// Date returns the time the message was generated
+func (h MessageHeader) Date() time.Time {
+	return julianTime(h.X_Date, h.X_Time)
+}
+
+// ProductDescription is the product description block, following the message
+// header
+type ProductDescription struct {
+	Divider int16
+	// X_Latitude and X_Longitude of the radar in thousandths of a degree
+	X_Latitude  int32
+	X_Longitude int32
+	// Height of the radar in feet above sea level
+	Height          int16
+	Code            int16
+	OperationalMode int16
+	VCP             int16
+	SequenceNumber  int16
+	VolumeScan      int16
+	X_VolumeDate    int16
+	X_VolumeTime    int32
+	X_GenDate       int16
+	X_GenTime       int32
+	Dependent1      int16
+	Dependent2      int16
+	ElevationNumber int16
+	Dependent3      int16
+	// Thresholds describe how data levels map to values; their meaning
+	// depends on the product
+	Thresholds [16]uint16
+	Dependent4 int16
+	Dependent5 int16
+	Dependent6 int16
+	Dependent7 int16
+	// Dependent8 is the compression method of digital products, 1 for bzip2
+	Dependent8 int16
+	// Dependent9 and Dependent10 are the uncompressed size of compressed
+	// products
+	Dependent9      int16
+	Dependent10     int16
+	Version         uint8
+	SpotBlank       uint8
+	SymbologyOffset int32
+	GraphicOffset   int32
+	TabularOffset   int32
+}
+
+// Latitude of the radar in degrees
+func (pd ProductDescription) Latitude() float64 {
+	return float64(pd.X_Latitude) / 1000
+}
+
+// Longitude of the radar in degrees
+func (pd ProductDescription) Longitude() float64 {
+	return float64(pd.X_Longitude) / 1000
+}
+
+// VolumeTime returns the start time of the volume scan the product is from
+func (pd ProductDescription) VolumeTime() time.Time {
+	return julianTime(pd.X_VolumeDate, pd.X_VolumeTime)
+}
+
+// ElevationAngle returns the elevation angle in degrees of elevation based
+// products
+func (pd ProductDescription) ElevationAngle() float64 {
+	return float64(pd.Dependent3) / 10
+}
+
+func julianTime(days int16, seconds int32) time.Time {
+	return time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC).
+		AddDate(0, 0, int(days)-1).
+		Add(time.Duration(seconds) * time.Second)
+}
+
+// headerSize is the size of the message header and product description block.
+// Block offsets are counted from the start of the message header.
+const headerSize = 120
+
+// Product is a decoded Level III product
+type Product struct {
+	// WMOHeader and AWIPSID are from the text header products are distributed
+	// with, e.g. "SDUS54 KOUN 260000" and "N0QTLX", if present
+	WMOHeader   string
+	AWIPSID     string
+	Header      MessageHeader
+	Description ProductDescription
+	// Radial and Raster hold the image of the product, whichever it has
+	Radial *RadialImage
+	Raster *RasterImage
+	// Symbols are the points of the symbology, such as storm IDs
+	Symbols []Symbol
+	// Tabular holds the pages of text of the tabular alphanumeric block, if
+	// the product has one
+	Tabular [][]string
+
+	levels *levelMap
+}
+
+// Name returns the mnemonic of the product, e.g. N0Q, or an empty string for
+// unknown products
+func (p *Product) Name() string {
+	return productNames[p.Description.Code]
+}
+
+// Value converts a data level to a physical value in the product's units.
+// Levels without a value are archive2.MomentDataBelowThreshold or
+// archive2.MomentDataFolded, as for archive 2 moments.
+func (p *Product) Value(level uint8) float32 {
+	return p.levels.values[level]
+}
+
+// Values converts data levels to values, as Value
+func (p *Product) Values(levels []uint8) []float32 {
+	values := make([]float32, len(levels))
+	for i, l := range levels {
+		values[i] = p.levels.values[l]
+	}
+	return values
+}
+
+// Label returns the legend label of a data level, e.g. "TH" or "-10"
+func (p *Product) Label(level uint8) string {
+	return p.levels.labels[level]
+}
+
+// GateSpacing returns the length in meters of the bins of a radial product,
+// or 0 if it isn't known
+func (p *Product) GateSpacing() float64 {
+	maxRange := productRanges[p.Description.Code]
+	if p.Radial == nil || maxRange == 0 || p.Radial.NumberBins == 0 {
+		return 0
+	}
+	// products cover their maximum range with however many bins they have
+	return maxRange * 1000 / float64(p.Radial.NumberBins)
+}
+
+var textHeader = regexp.MustCompile(`^(?:\x01\r\r\n[0-9 ]+\r\r\n)?([A-Z]{4}[0-9]{2} [A-Z0-9]{4} [0-9]{6}[^\r\n]*)\r\r\n([A-Z0-9 ]{4,6})\r\r\n`)
+
+// Decode reads a product
+func Decode(r io.Reader) (*Product, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &Product{}
+	if m := textHeader.FindSubmatch(data); m != nil {
+		p.WMOHeader = string(m[1])
+		p.AWIPSID = string(bytes.TrimSpace(m[2]))
+		data = data[len(m[0]):]
+	}
+	if len(data) < headerSize {
+		return nil, errors.New("level3: product too short")
+	}
+
+	br := bytes.NewReader(data)
+	binary.Read(br, binary.BigEndian, &p.Header)
+	binary.Read(br, binary.BigEndian, &p.Description)
+	if p.Description.Divider != -1 {
+		return nil, fmt.Errorf("level3: bad product description block divider %d", p.Description.Divider)
+	}
+
+	if p.Description.Dependent8 == 1 && bytes.HasPrefix(data[headerSize:], []byte("BZh")) {
+		// everything after the product description block is bzip2 compressed
+		rest, err := ioutil.ReadAll(bzip2.NewReader(bytes.NewReader(data[headerSize:])))
+		if err != nil {
+			return nil, fmt.Errorf("level3: failed to decompress product: %s", err)
+		}
+		data = append(data[:headerSize:headerSize], rest...)
+	}
+
+	p.levels = newLevelMap(p.Description.Code, p.Description.Thresholds)
+
+	if off := int(p.Description.SymbologyOffset) * 2; off > 0 {
+		if off >= len(data) {
+			return nil, fmt.Errorf("level3: symbology block offset %d past end of product", off)
+		}
+		if err := p.decodeSymbology(data[off:]); err != nil {
+			return nil, err
+		}
+	}
+	if off := int(p.Description.TabularOffset) * 2; off > 0 {
+		if off >= len(data) {
+			return nil, fmt.Errorf("level3: tabular block offset %d past end of product", off)
+		}
+		if err := p.decodeTabular(data[off:]); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// NewProductFromFile decodes the product in the file
+func NewProductFromFile(filename string) (*Product, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Decode(f)
+}
@@ -0,0 +1,334 @@
+package level3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/dsnet/compress/bzip2"
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+// buildProduct returns a product with the description and symbology layer
+// packets, optionally bzip2 compressing everything after the description
+func buildProduct(t testing.TB, pd ProductDescription, packets []byte, compress bool) []byte {
+	sym := &bytes.Buffer{}
+	binary.Write(sym, binary.BigEndian, struct {
+		Divider  int16
+		ID       int16
+		Length   int32
+		Layers   int16
+		Divider2 int16
+		LayerLen int32
+	}{-1, 1, int32(16 + len(packets)), 1, -1, int32(len(packets))})
+	sym.Write(packets)
+
+	pd.Divider = -1
+	pd.SymbologyOffset = headerSize / 2
+	body := sym.Bytes()
+	if compress {
+		pd.Dependent8 = 1
+		buf := &bytes.Buffer{}
+		w, err := bzip2.NewWriter(buf, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(body)
+		w.Close()
+		body = buf.Bytes()
+	}
+
+	out := &bytes.Buffer{}
+	binary.Write(out, binary.BigEndian, MessageHeader{
+		Code:         pd.Code,
+		X_Date:       17404,
+		X_Time:       86253,
+		Length:       int32(headerSize + len(body)),
+		NumberBlocks: 3,
+	})
+	binary.Write(out, binary.BigEndian, pd)
+	out.Write(body)
+	return out.Bytes()
+}
+
+func TestDecodeDigitalRadial(t *testing.T) {
+	pkt := &bytes.Buffer{}
+	binary.Write(pkt, binary.BigEndian, []int16{packetDigitalRadial, 0, 3, 256, 256, 999, 2})
+	// a radial of 3 bins, padded to a halfword
+	binary.Write(pkt, binary.BigEndian, []int16{3, 0, 5})
+	pkt.Write([]byte{0, 1, 100, 0})
+	binary.Write(pkt, binary.BigEndian, []int16{3, 5, 5})
+	pkt.Write([]byte{2, 255, 3, 0})
+
+	pd := ProductDescription{Code: 94, X_Latitude: 35333, X_Longitude: -97278, Dependent3: 5}
+	pd.Thresholds[0] = uint16(0xffff - 320 + 1) // -32.0 dBZ
+	pd.Thresholds[1] = 5                        // 0.5 dBZ
+	pd.Thresholds[2] = 254
+	data := append([]byte("SDUS54 KOUN 252357\r\r\nN0QTLX\r\r\n"), buildProduct(t, pd, pkt.Bytes(), true)...)
+
+	p, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.WMOHeader != "SDUS54 KOUN 252357" || p.AWIPSID != "N0QTLX" || p.Name() != "N0Q" {
+		t.Errorf("got header %q, id %q, name %q", p.WMOHeader, p.AWIPSID, p.Name())
+	}
+	if want := time.Date(2017, 8, 25, 23, 57, 33, 0, time.UTC); !p.Header.Date().Equal(want) {
+		t.Errorf("got date %s, want %s", p.Header.Date(), want)
+	}
+	if p.Description.Latitude() != 35.333 || p.Description.ElevationAngle() != 0.5 {
+		t.Errorf("got lat %v, elevation %v", p.Description.Latitude(), p.Description.ElevationAngle())
+	}
+	if p.Radial == nil || len(p.Radial.Radials) != 2 {
+		t.Fatalf("got radial image %+v", p.Radial)
+	}
+	if g := p.GateSpacing(); g != 460000/3.0 {
+		t.Errorf("got gate spacing %v", g)
+	}
+
+	r := p.Radial.Radials[1]
+	if r.StartAngle != 0.5 || r.AngleDelta != 0.5 || !bytes.Equal(r.Levels, []byte{2, 255, 3}) {
+		t.Errorf("got radial %+v", r)
+	}
+	want := []float32{archive2.MomentDataBelowThreshold, archive2.MomentDataFolded, 17}
+	for i, v := range p.Values(p.Radial.Radials[0].Levels) {
+		if v != want[i] {
+			t.Errorf("bin %d = %v, want %v", i, v, want[i])
+		}
+	}
+	if v := p.Value(255); v != 94.5 {
+		t.Errorf("level 255 = %v, want 94.5", v)
+	}
+}
+
+func TestDecodeRLERadial(t *testing.T) {
+	pkt := &bytes.Buffer{}
+	binary.Write(pkt, binary.BigEndian, []int16{packetRadial, 0, 6, 256, 256, 999, 1})
+	// 2 halfwords of runs: 3 x level 0, 2 x level 5, 1 x level 15
+	binary.Write(pkt, binary.BigEndian, []int16{2, 3595, 10})
+	pkt.Write([]byte{0x30, 0x25, 0x1f, 0x00})
+
+	// a generic packet in the same layer is skipped
+	binary.Write(pkt, binary.BigEndian, []int16{packetGeneric, 0})
+	binary.Write(pkt, binary.BigEndian, int32(4))
+	pkt.Write([]byte{1, 2, 3, 4})
+
+	pd := ProductDescription{Code: 19}
+	pd.Thresholds[0] = 0x8002         // ND
+	pd.Thresholds[1] = 0x0100 | 20    // -20
+	pd.Thresholds[5] = 5              // 5
+	pd.Thresholds[15] = 0x8003        // RF
+	pd.Thresholds[14] = 0x0800 | 0x48 // >72
+	p, err := Decode(bytes.NewReader(buildProduct(t, pd, pkt.Bytes(), false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := p.Radial.Radials[0]
+	if r.StartAngle != 359.5 || r.AngleDelta != 1 || !bytes.Equal(r.Levels, []byte{0, 0, 0, 5, 5, 15}) {
+		t.Errorf("got radial %+v", r)
+	}
+	for level, want := range map[uint8]string{0: "ND", 1: "-20", 5: "5", 14: ">72", 15: "RF"} {
+		if got := p.Label(level); got != want {
+			t.Errorf("level %d label %q, want %q", level, got, want)
+		}
+	}
+	if p.Value(1) != -20 || p.Value(15) != archive2.MomentDataFolded || p.Value(0) != archive2.MomentDataBelowThreshold {
+		t.Errorf("got values %v %v %v", p.Value(0), p.Value(1), p.Value(15))
+	}
+}
+
+func TestDecodeRaster(t *testing.T) {
+	pkt := &bytes.Buffer{}
+	binary.Write(pkt, binary.BigEndian, []int16{packetRaster, -32768, 0x00c0, -2048, -2048, 4, 0, 4, 0, 2, 2})
+	binary.Write(pkt, binary.BigEndian, int16(2))
+	pkt.Write([]byte{0x21, 0x13})
+	binary.Write(pkt, binary.BigEndian, int16(2))
+	pkt.Write([]byte{0x10, 0x2f})
+
+	p, err := Decode(bytes.NewReader(buildProduct(t, ProductDescription{Code: 37}, pkt.Bytes(), false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Raster == nil || len(p.Raster.Rows) != 2 || p.Raster.XScale != 4 {
+		t.Fatalf("got raster %+v", p.Raster)
+	}
+	if !bytes.Equal(p.Raster.Rows[0], []byte{1, 1, 3}) || !bytes.Equal(p.Raster.Rows[1], []byte{0, 15, 15}) {
+		t.Errorf("got rows %v", p.Raster.Rows)
+	}
+}
+
+func TestGrid(t *testing.T) {
+	// 360 radials of 4 bins of 17 dBZ, except the south east quadrant which
+	// is below threshold
+	pkt := &bytes.Buffer{}
+	binary.Write(pkt, binary.BigEndian, []int16{packetDigitalRadial, 0, 4, 256, 256, 999, 360})
+	for az := 0; az < 360; az++ {
+		binary.Write(pkt, binary.BigEndian, []int16{4, int16(az * 10), 10})
+		level := byte(100)
+		if az >= 90 && az < 180 {
+			level = 0
+		}
+		pkt.Write([]byte{level, level, level, level})
+	}
+	pd := ProductDescription{Code: 94, X_Latitude: 35000, X_Longitude: -97000, Dependent3: 5}
+	pd.Thresholds[0] = uint16(0xffff - 320 + 1)
+	pd.Thresholds[1] = 5
+	pd.Thresholds[2] = 254
+	p, err := Decode(bytes.NewReader(buildProduct(t, pd, pkt.Bytes(), false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := p.Grid(230000, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lat, lon := g.Center(5, 5); math.Abs(lat-35) > 0.5 || math.Abs(lon+97) > 0.5 {
+		t.Errorf("grid not centered on the radar: %v, %v", lat, lon)
+	}
+	if v := g.At(2, 2); v != 17 {
+		t.Errorf("expected 17 dBZ in the north west, got %v", v)
+	}
+	if v := g.At(7, 7); !math.IsNaN(float64(v)) {
+		t.Errorf("expected no data in the south east, got %v", v)
+	}
+	if v := g.At(0, 0); !math.IsNaN(float64(v)) {
+		t.Errorf("expected no data outside the radius, got %v", v)
+	}
+
+	if _, err := (&Product{}).Grid(230000, 10); err == nil {
+		t.Error("expected an error gridding a product without a radial image")
+	}
+}
+
+func TestFloat16(t *testing.T) {
+	for v, want := range map[uint16]float64{
+		0x4400: 2,
+		0xc400: -2,
+		0x4600: 3,
+		0x0100: 0.5,
+	} {
+		if got := float16(v); got != want {
+			t.Errorf("float16(%#x) = %v, want %v", v, got, want)
+		}
+	}
+}
+
+// tabularBlock returns a tabular alphanumeric block with the pages of lines
+func tabularBlock(pages [][]string) []byte {
+	body := &bytes.Buffer{}
+	body.Write(make([]byte, headerSize))
+	binary.Write(body, binary.BigEndian, []int16{-1, int16(len(pages))})
+	for _, page := range pages {
+		for _, line := range page {
+			binary.Write(body, binary.BigEndian, int16(len(line)))
+			body.WriteString(line)
+		}
+		binary.Write(body, binary.BigEndian, int16(-1))
+	}
+	out := &bytes.Buffer{}
+	binary.Write(out, binary.BigEndian, []int16{-1, 3})
+	binary.Write(out, binary.BigEndian, int32(8+body.Len()))
+	out.Write(body.Bytes())
+	return out.Bytes()
+}
+
+func TestStormTracks(t *testing.T) {
+	pkt := &bytes.Buffer{}
+	binary.Write(pkt, binary.BigEndian, []int16{packetStormID, 6, 40, -40})
+	pkt.WriteString("U3")
+	data := buildProduct(t, ProductDescription{Code: 58}, pkt.Bytes(), false)
+
+	// point the description block at the tabular block appended to the end
+	binary.BigEndian.PutUint32(data[116:], uint32(len(data)/2))
+	data = append(data, tabularBlock([][]string{{
+		"                         STORM POSITION/FORECAST",
+		"     RADAR ID 577  DATE/TIME 08:25:17/23:57:33   NUMBER OF STORM CELLS   2",
+		"",
+		" STORM    CURRENT POSITION              FORECAST POSITIONS              ERROR",
+		"   ID     AZRAN     MOVEMENT     15 MIN    30 MIN    45 MIN    60 MIN    FCST/MEAN",
+		"         (DEG/NM)   (DEG/KTS)    (DEG/NM)  (DEG/NM)  (DEG/NM)  (DEG/NM)    (NM)",
+		"",
+		"   U3    245/ 71    251/ 33     243/ 63   240/ 55   NO DATA   NO DATA     1.3/ 1.2",
+		"   V1    199/ 94       NEW        NO DATA   NO DATA   NO DATA   NO DATA     0.0/ 0.0",
+	}})...)
+
+	p, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Tabular) != 1 || len(p.Tabular[0]) != 9 {
+		t.Fatalf("got tabular pages %q", p.Tabular)
+	}
+	tracks, err := p.StormTracks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 2 {
+		t.Fatalf("got %d tracks", len(tracks))
+	}
+	u3 := tracks[0]
+	if u3.ID != "U3" || u3.New || u3.Position.Azimuth != 245 || u3.Position.Range != 71*1852 || u3.Direction != 251 {
+		t.Errorf("got track %+v", u3)
+	}
+	if math.Abs(u3.Speed-16.98) > 0.01 || len(u3.Forecast) != 2 || u3.Forecast[1].Range != 55*1852 || u3.ForecastError != 1.3*1852 {
+		t.Errorf("got track %+v", u3)
+	}
+	if v1 := tracks[1]; v1.ID != "V1" || !v1.New || len(v1.Forecast) != 0 {
+		t.Errorf("got track %+v", v1)
+	}
+}
+
+func TestHailIndices(t *testing.T) {
+	pkt := &bytes.Buffer{}
+	// one storm ID packet per cell, and a hail index packet for both
+	binary.Write(pkt, binary.BigEndian, []int16{packetStormID, 6, 40, -40})
+	pkt.WriteString("U3")
+	binary.Write(pkt, binary.BigEndian, []int16{packetStormID, 6, 0, 100})
+	pkt.WriteString("V1")
+	binary.Write(pkt, binary.BigEndian, []int16{packetHailIndex, 20, 40, -40, 100, 70, 2, 0, 100, -999, -999, 0})
+
+	p, err := Decode(bytes.NewReader(buildProduct(t, ProductDescription{Code: 59}, pkt.Bytes(), false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hail := p.HailIndices()
+	if len(hail) != 2 {
+		t.Fatalf("got %d hail indices", len(hail))
+	}
+	if h := hail[0]; h.ID != "U3" || h.POH != 100 || h.POSH != 70 || h.MaxSize != 2 || math.Abs(h.Position.Azimuth-45) > 1e-9 || math.Abs(h.Position.Range-10000*math.Sqrt2) > 1e-6 {
+		t.Errorf("got %+v", h)
+	}
+	if h := hail[1]; h.ID != "V1" || h.POH != -999 || h.Position.Azimuth != 180 || h.Position.Range != 25000 {
+		t.Errorf("got %+v", h)
+	}
+}
+
+func TestMesocyclones(t *testing.T) {
+	pkt := &bytes.Buffer{}
+	binary.Write(pkt, binary.BigEndian, []int16{packetStormID, 6, -80, 0})
+	pkt.WriteString("J0")
+	binary.Write(pkt, binary.BigEndian, []int16{packetMesocyclone, 6, -80, 0, 8})
+	binary.Write(pkt, binary.BigEndian, []int16{packetCorrelatedShear, 6, 10, 10, 4})
+	binary.Write(pkt, binary.BigEndian, []int16{packetPointFeature, 8, 20, 0, 2, 6})
+
+	p, err := Decode(bytes.NewReader(buildProduct(t, ProductDescription{Code: 60}, pkt.Bytes(), false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mesos := p.Mesocyclones()
+	if len(mesos) != 3 {
+		t.Fatalf("got %d mesocyclones", len(mesos))
+	}
+	if m := mesos[0]; m.ID != "J0" || m.Radius != 2000 || m.Shear || m.Position.Azimuth != 270 || m.Position.Range != 20000 {
+		t.Errorf("got %+v", m)
+	}
+	if m := mesos[1]; !m.Shear || m.Radius != 1000 || m.ID != "" {
+		t.Errorf("got %+v", m)
+	}
+	if m := mesos[2]; m.FeatureType != 2 || m.Radius != 1500 || m.Position.Azimuth != 90 {
+		t.Errorf("got %+v", m)
+	}
+}
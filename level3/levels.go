@@ -0,0 +1,185 @@
+package level3
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+// productNames are the mnemonics of the products, by product code
+var productNames = map[int16]string{
+	19:  "N0R",
+	20:  "N0Z",
+	27:  "N0V",
+	30:  "N0W",
+	37:  "NCR",
+	38:  "NCZ",
+	41:  "NET",
+	57:  "NVL",
+	58:  "NST",
+	59:  "NHI",
+	60:  "NME",
+	94:  "N0Q",
+	99:  "N0U",
+	134: "DVL",
+	135: "EET",
+	141: "NMD",
+}
+
+// productRanges are the maximum ranges (km) of radial products, by product code
+var productRanges = map[int16]float64{
+	19:  230,
+	20:  460,
+	27:  230,
+	30:  230,
+	94:  460,
+	99:  300,
+	134: 460,
+	135: 345,
+}
+
+// levelMap converts the data levels of a product to values
+type levelMap struct {
+	values [256]float32
+	labels [256]string
+}
+
+func newLevelMap(code int16, thresholds [16]uint16) *levelMap {
+	m := &levelMap{}
+	for i := range m.values {
+		m.values[i] = archive2.MomentDataBelowThreshold
+	}
+	switch code {
+	case 94, 99:
+		m.digital(thresholds)
+	case 134:
+		m.vil(thresholds)
+	case 135:
+		m.echoTops(thresholds)
+	default:
+		m.legacy(thresholds)
+	}
+	return m
+}
+
+// digital maps levels linearly: level 0 is below threshold, 1 range folded,
+// and levels from 2 are min + (level - 2) * increment, with min and increment
+// in tenths
+func (m *levelMap) digital(t [16]uint16) {
+	min := float64(int16(t[0])) / 10
+	inc := float64(t[1]) / 10
+	levels := int(t[2])
+	m.labels[0] = "TH"
+	m.values[1] = archive2.MomentDataFolded
+	m.labels[1] = "RF"
+	for i := 0; i < levels && i+2 < 256; i++ {
+		v := min + float64(i)*inc
+		m.values[i+2] = float32(v)
+		m.labels[i+2] = fmt.Sprintf("%.1f", v)
+	}
+}
+
+// vil maps levels linearly up to a threshold, then logarithmically, with the
+// parameters stored as NEXRAD 16 bit floats. Units are kg/m^2.
+func (m *levelMap) vil(t [16]uint16) {
+	linScale, linOffset := float16(t[0]), float16(t[1])
+	logStart := int(t[2])
+	logScale, logOffset := float16(t[3]), float16(t[4])
+	m.labels[0] = "TH"
+	m.values[1] = archive2.MomentDataFolded
+	m.labels[1] = "RF"
+	// 255 is reserved
+	for i := 2; i < 255; i++ {
+		var v float64
+		if i < logStart {
+			v = (float64(i) - linOffset) / linScale
+		} else {
+			v = math.Exp((float64(i) - logOffset) / logScale)
+		}
+		m.values[i] = float32(v)
+		m.labels[i] = fmt.Sprintf("%.1f", v)
+	}
+}
+
+// echoTops masks off the "topped" flag of levels, which are then scaled
+// linearly. Units are kft.
+func (m *levelMap) echoTops(t [16]uint16) {
+	mask := int(t[0])
+	scale, offset := float64(t[1]), float64(t[2])
+	if scale == 0 {
+		return
+	}
+	m.labels[0] = "TH"
+	m.values[1] = archive2.MomentDataFolded
+	m.labels[1] = "ND"
+	for i := 2; i < 256; i++ {
+		v := (float64(i&mask) - offset) / scale
+		m.values[i] = float32(v)
+		m.labels[i] = fmt.Sprintf("%.0f", v)
+	}
+}
+
+// legacyCodes are the labels of the special values of 16 level products
+var legacyCodes = []string{"", "TH", "ND", "RF", "BI", "GC", "IC", "GR", "WS", "DS", "RA", "HR", "BD", "HA", "UK"}
+
+// legacy maps the 16 levels of older products, whose thresholds hold each
+// level's value in the low byte and flags in the high byte: a special value
+// (e.g. TH or RF), a scale of 0.01, 0.05 or 0.1, and the sign and < or >
+// qualifiers of the label.
+func (m *levelMap) legacy(t [16]uint16) {
+	for i, th := range t {
+		flags, v := th>>8, float64(th&0xff)
+		label := ""
+		switch {
+		case flags&0x80 != 0:
+			if int(v) < len(legacyCodes) {
+				label = legacyCodes[int(v)]
+			}
+			if label == "RF" {
+				v = archive2.MomentDataFolded
+			} else {
+				v = archive2.MomentDataBelowThreshold
+			}
+		case flags&0x40 != 0:
+			v *= 0.01
+		case flags&0x20 != 0:
+			v *= 0.05
+		case flags&0x10 != 0:
+			v *= 0.1
+		}
+		if flags&0x80 == 0 {
+			if flags&0x01 != 0 {
+				v = -v
+			}
+			label = fmt.Sprint(v)
+			if flags&0x02 != 0 {
+				label = "+" + label
+			}
+			if flags&0x04 != 0 {
+				label = "<" + label
+			} else if flags&0x08 != 0 {
+				label = ">" + label
+			}
+		}
+		m.values[i] = float32(v)
+		m.labels[i] = label
+	}
+}
+
+// float16 decodes the 16 bit floats used in product parameters: a sign bit, 5
+// bit exponent and 10 bit fraction
+func float16(v uint16) float64 {
+	frac := float64(v & 0x3ff)
+	exp := int(v>>10) & 0x1f
+	var f float64
+	if exp != 0 {
+		f = math.Exp2(float64(exp-16)) * (1 + frac/1024)
+	} else {
+		f = frac / 512
+	}
+	if v&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
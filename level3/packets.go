@@ -0,0 +1,311 @@
+package level3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// RadialImage is the image of a radial product, from a digital radial data
+// array (packet 16) or run length encoded radial (packet AF1F) packet
+type RadialImage struct {
+	// FirstBin is the index of the first range bin
+	FirstBin   int
+	NumberBins int
+	// I and J are the position of the radar in the image, in km/4
+	I, J    int
+	Radials []Radial
+}
+
+// Radial is a radial of data levels, one per range bin
+type Radial struct {
+	// StartAngle and AngleDelta are the azimuth the radial starts at and its
+	// width in degrees
+	StartAngle float32
+	AngleDelta float32
+	Levels     []uint8
+}
+
+// RasterImage is the image of a raster product, from a raster data packet
+// (BA0F or BA07), with rows starting from the north
+type RasterImage struct {
+	// I and J are the position of the upper left corner of the image
+	I, J int
+	// XScale and YScale are the size of each cell in km/4
+	XScale, YScale float32
+	Rows           [][]uint8
+}
+
+const (
+	packetDigitalRadial = 16
+	packetRadial        = -20705 // 0xAF1F
+	packetRaster        = -17905 // 0xBA0F
+	packetRaster7       = -17913 // 0xBA07
+	packetGeneric       = 28
+	packetGenericExtra  = 29
+
+	packetMesocyclone     = 3
+	packetCorrelatedShear = 11
+	packetStormID         = 15
+	packetHailIndex       = 19
+	packetPointFeature    = 20
+)
+
+// symbolSizes are the sizes in bytes of each symbol in point symbol packets,
+// which hold one or more of them
+var symbolSizes = map[int16]int{
+	packetMesocyclone:     6,
+	packetCorrelatedShear: 6,
+	packetStormID:         6,
+	packetHailIndex:       10,
+	packetPointFeature:    8,
+}
+
+// Symbol is a point in a product's symbology, such as a storm ID or hail index
+type Symbol struct {
+	Packet int16
+	// I and J are the position east and south of the radar in km/4
+	I, J int
+	// Text is the label of storm ID symbols
+	Text string
+	// Values are the attributes following the position: the radius of
+	// mesocyclones and correlated shear, the probability of hail, probability
+	// of severe hail and maximum hail size of hail indices, and the type and
+	// attribute of point features
+	Values []int
+}
+
+// decodeSymbology decodes the product symbology block. Packets other than the
+// radial and raster images (e.g. text and symbols) are skipped.
+func (p *Product) decodeSymbology(b []byte) error {
+	r := bytes.NewReader(b)
+	var block struct {
+		Divider int16
+		ID      int16
+		Length  int32
+		Layers  int16
+	}
+	if err := binary.Read(r, binary.BigEndian, &block); err != nil {
+		return fmt.Errorf("level3: failed to read symbology block: %s", err)
+	}
+	if block.Divider != -1 || block.ID != 1 {
+		return fmt.Errorf("level3: bad symbology block header %d/%d", block.Divider, block.ID)
+	}
+
+	for layer := 0; layer < int(block.Layers); layer++ {
+		var header struct {
+			Divider int16
+			Length  int32
+		}
+		if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+			return fmt.Errorf("level3: failed to read symbology layer %d: %s", layer, err)
+		}
+		if header.Divider != -1 || header.Length < 0 || int(header.Length) > r.Len() {
+			return fmt.Errorf("level3: bad symbology layer %d header", layer)
+		}
+		data := make([]byte, header.Length)
+		r.Read(data)
+		if err := p.decodePackets(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Product) decodePackets(b []byte) error {
+	r := bytes.NewReader(b)
+	for r.Len() > 0 {
+		var code int16
+		if err := binary.Read(r, binary.BigEndian, &code); err != nil {
+			return err
+		}
+		var err error
+		switch code {
+		case packetDigitalRadial, packetRadial:
+			p.Radial, err = decodeRadials(r, code == packetRadial)
+		case packetRaster, packetRaster7:
+			p.Raster, err = decodeRaster(r)
+		case packetMesocyclone, packetCorrelatedShear, packetStormID, packetHailIndex, packetPointFeature:
+			err = p.decodeSymbols(r, code)
+		case packetGeneric, packetGenericExtra:
+			// a reserved halfword, then the length in bytes as a fullword
+			var h struct {
+				Reserved int16
+				Length   int32
+			}
+			if err := binary.Read(r, binary.BigEndian, &h); err != nil {
+				return err
+			}
+			if h.Length < 0 {
+				err = fmt.Errorf("negative length %d", h.Length)
+				break
+			}
+			_, err = r.Seek(int64(h.Length), io.SeekCurrent)
+		default:
+			// other packets are prefixed with their length in bytes
+			var length uint16
+			if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+				return err
+			}
+			_, err = r.Seek(int64(length), io.SeekCurrent)
+		}
+		if err != nil {
+			return fmt.Errorf("level3: failed to decode packet %x: %s", uint16(code), err)
+		}
+	}
+	return nil
+}
+
+// decodeSymbols reads a point symbol packet after its code
+func (p *Product) decodeSymbols(r *bytes.Reader, code int16) error {
+	var length uint16
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return err
+	}
+	if int(length) > r.Len() {
+		return fmt.Errorf("length %d past end of layer", length)
+	}
+	data := make([]byte, length)
+	r.Read(data)
+	size := symbolSizes[code]
+	for ; len(data) >= size; data = data[size:] {
+		s := Symbol{
+			Packet: code,
+			I:      int(int16(binary.BigEndian.Uint16(data))),
+			J:      int(int16(binary.BigEndian.Uint16(data[2:]))),
+		}
+		if code == packetStormID {
+			s.Text = string(bytes.TrimRight(data[4:6], " \x00"))
+		} else {
+			for i := 4; i < size; i += 2 {
+				s.Values = append(s.Values, int(int16(binary.BigEndian.Uint16(data[i:]))))
+			}
+		}
+		p.Symbols = append(p.Symbols, s)
+	}
+	return nil
+}
+
+// decodeRadials reads a radial packet after its code. Digital radials store a
+// level per byte; run length encoded ones store a run (high nibble) and level
+// (low nibble) per byte.
+func decodeRadials(r *bytes.Reader, rle bool) (*RadialImage, error) {
+	var h struct {
+		FirstBin    int16
+		NumberBins  int16
+		I, J        int16
+		ScaleFactor int16
+		NumRadials  int16
+	}
+	if err := binary.Read(r, binary.BigEndian, &h); err != nil {
+		return nil, err
+	}
+	// radials are truncated to the number of bins, so an image of radials
+	// must have some
+	if h.NumberBins < 0 || h.NumRadials < 0 || (h.NumberBins == 0 && h.NumRadials > 0) {
+		return nil, fmt.Errorf("bad radial image size of %d radials of %d bins", h.NumRadials, h.NumberBins)
+	}
+	img := &RadialImage{
+		FirstBin:   int(h.FirstBin),
+		NumberBins: int(h.NumberBins),
+		I:          int(h.I),
+		J:          int(h.J),
+	}
+	for i := 0; i < int(h.NumRadials); i++ {
+		var rh struct {
+			// Length is in bytes for digital radials, halfwords for run
+			// length encoded ones
+			Length     int16
+			StartAngle int16
+			AngleDelta int16
+		}
+		if err := binary.Read(r, binary.BigEndian, &rh); err != nil {
+			return nil, err
+		}
+		n := int(rh.Length)
+		if rle {
+			n *= 2
+		} else if n%2 == 1 {
+			// padded to a halfword
+			n++
+		}
+		if n < 0 || n > r.Len() {
+			return nil, fmt.Errorf("radial %d length %d past end of packet", i, n)
+		}
+		data := make([]byte, n)
+		r.Read(data)
+
+		radial := Radial{
+			StartAngle: float32(rh.StartAngle) / 10,
+			AngleDelta: float32(rh.AngleDelta) / 10,
+		}
+		if rle {
+			radial.Levels = decodeRLE(data, img.NumberBins)
+		} else {
+			if len(data) > img.NumberBins {
+				data = data[:img.NumberBins]
+			}
+			radial.Levels = data
+		}
+		img.Radials = append(img.Radials, radial)
+	}
+	return img, nil
+}
+
+// decodeRaster reads a raster packet after its code
+func decodeRaster(r *bytes.Reader) (*RasterImage, error) {
+	var h struct {
+		Flags1, Flags2 uint16
+		I, J           int16
+		XScale         int16
+		XScaleFrac     int16
+		YScale         int16
+		YScaleFrac     int16
+		NumRows        int16
+		Packing        int16
+	}
+	if err := binary.Read(r, binary.BigEndian, &h); err != nil {
+		return nil, err
+	}
+	img := &RasterImage{
+		I:      int(h.I),
+		J:      int(h.J),
+		XScale: float32(h.XScale),
+		YScale: float32(h.YScale),
+	}
+	for i := 0; i < int(h.NumRows); i++ {
+		var n int16
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return nil, err
+		}
+		if n < 0 || int(n) > r.Len() {
+			return nil, fmt.Errorf("row %d length %d past end of packet", i, n)
+		}
+		data := make([]byte, n)
+		r.Read(data)
+		img.Rows = append(img.Rows, decodeRLE(data, 0))
+	}
+	return img, nil
+}
+
+// decodeRLE expands run length encoded levels, each byte holding a run in its
+// high nibble and level in its low nibble. If size is non-zero the result is
+// padded or truncated to it.
+func decodeRLE(data []byte, size int) []uint8 {
+	var levels []uint8
+	for _, b := range data {
+		for run := b >> 4; run > 0; run-- {
+			levels = append(levels, b&0xf)
+		}
+	}
+	if size > 0 {
+		if len(levels) > size {
+			levels = levels[:size]
+		}
+		for len(levels) < size {
+			levels = append(levels, 0)
+		}
+	}
+	return levels
+}
@@ -0,0 +1,486 @@
+// Package nexradpb serializes decoded archive 2 volumes and derived products as
+// protocol buffers, following the schema in nexrad.proto, so they can be passed
+// between services without re-parsing the original ar2v file.
+package nexradpb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/derived"
+)
+
+// Marshal encodes the volume as a Volume message. LDM records and offsets are
+// not included.
+func Marshal(ar2 *archive2.Archive2) ([]byte, error) {
+	e := &encoder{}
+	e.message(1, func(e *encoder) {
+		vh := ar2.VolumeHeader
+		e.string(1, trimNull(vh.X_FileName[:]))
+		e.int(2, int64(vh.X_ModifiedJulianDate))
+		e.int(3, int64(vh.X_ModifiedTime))
+		e.string(4, trimNull(vh.ICAO[:]))
+	})
+	if ar2.RadarStatus != nil {
+		b := &bytes.Buffer{}
+		if err := binary.Write(b, binary.BigEndian, ar2.RadarStatus); err != nil {
+			return nil, err
+		}
+		e.bytes(2, b.Bytes())
+	}
+	if ar2.RadarPerformance != nil {
+		b := &bytes.Buffer{}
+		if err := binary.Write(b, binary.BigEndian, ar2.RadarPerformance); err != nil {
+			return nil, err
+		}
+		e.bytes(3, b.Bytes())
+	}
+
+	var elevations []int
+	for elv := range ar2.ElevationScans {
+		elevations = append(elevations, elv)
+	}
+	sort.Ints(elevations)
+	for _, elv := range elevations {
+		e.message(4, func(e *encoder) {
+			e.int(1, int64(elv))
+			for _, r := range ar2.ElevationScans[elv] {
+				e.message(2, func(e *encoder) { encodeRadial(e, r) })
+			}
+		})
+	}
+	return e.buf, nil
+}
+
+func encodeRadial(e *encoder, r *archive2.Message31) {
+	h := r.Header
+	e.string(1, trimNull(h.RadarIdentifier[:]))
+	e.uint(2, uint64(h.CollectionTime))
+	e.uint(3, uint64(h.CollectionDate))
+	e.uint(4, uint64(h.AzimuthNumber))
+	e.float(5, h.AzimuthAngle)
+	e.uint(6, uint64(h.CompressionIndicator))
+	e.uint(7, uint64(h.RadialLength))
+	e.uint(8, uint64(h.AzimuthResolutionSpacingCode))
+	e.uint(9, uint64(h.RadialStatus))
+	e.uint(10, uint64(h.ElevationNumber))
+	e.uint(11, uint64(h.CutSectorNumber))
+	e.float(12, h.ElevationAngle)
+	e.uint(13, uint64(h.RadialSpotBlankingStatus))
+	e.uint(14, uint64(h.AzimuthIndexingMode))
+	e.uint(15, uint64(h.DataBlockCount))
+
+	e.message(16, func(e *encoder) {
+		v := r.VolumeData
+		e.uint(1, uint64(v.VersionMajor))
+		e.uint(2, uint64(v.VersionMinor))
+		e.float(3, v.Lat)
+		e.float(4, v.Long)
+		e.uint(5, uint64(v.SiteHeight))
+		e.uint(6, uint64(v.FeedhornHeight))
+		e.float(7, v.CalibrationConstant)
+		e.float(8, v.SHVTXPowerHor)
+		e.float(9, v.SHVTXPowerVer)
+		e.float(10, v.SystemDifferentialReflectivity)
+		e.float(11, v.InitialSystemDifferentialPhase)
+		e.uint(12, uint64(v.VolumeCoveragePatternNumber))
+		e.uint(13, uint64(v.ProcessingStatus))
+	})
+	e.message(17, func(e *encoder) {
+		e.bytes(1, r.ElevationData.ATMOS[:])
+		e.float(2, r.ElevationData.CalibConst)
+	})
+	e.message(18, func(e *encoder) {
+		v := r.RadialData
+		e.uint(1, uint64(v.UnambiguousRange))
+		e.float(2, v.NoiseLevelHorz)
+		e.float(3, v.NoiseLevelVert)
+		e.uint(4, uint64(v.NyquistVelocity))
+		e.float(5, v.CalibConstHorzChan)
+		e.float(6, v.CalibConstVertChan)
+	})
+	for _, name := range archive2.MomentNames {
+		if d := r.Moment(name); d != nil {
+			e.message(19, func(e *encoder) { encodeMoment(e, name, d) })
+		}
+	}
+}
+
+func encodeMoment(e *encoder, name string, d *archive2.DataMoment) {
+	e.string(1, name)
+	e.uint(2, uint64(d.NumberDataMomentGates))
+	e.uint(3, uint64(d.DataMomentRange))
+	e.uint(4, uint64(d.DataMomentRangeSampleInterval))
+	e.uint(5, uint64(d.TOVER))
+	e.uint(6, uint64(d.SNRThreshold))
+	e.uint(7, uint64(d.ControlFlags))
+	e.uint(8, uint64(d.DataWordSize))
+	e.float(9, d.Scale)
+	e.float(10, d.Offset)
+	e.bytes(11, d.Data)
+}
+
+// Unmarshal decodes a Volume message
+func Unmarshal(b []byte) (*archive2.Archive2, error) {
+	ar2 := &archive2.Archive2{ElevationScans: map[int][]*archive2.Message31{}}
+	d := &decoder{buf: b}
+	for {
+		field, err := d.next()
+		if err != nil || field == 0 {
+			return ar2, err
+		}
+		switch field {
+		case 1:
+			if err := decodeVolumeHeader(d.bytes, &ar2.VolumeHeader); err != nil {
+				return nil, err
+			}
+		case 2:
+			ar2.RadarStatus = &archive2.Message2{}
+			if err := binary.Read(bytes.NewReader(d.bytes), binary.BigEndian, ar2.RadarStatus); err != nil {
+				return nil, fmt.Errorf("nexradpb: radar status: %s", err)
+			}
+		case 3:
+			ar2.RadarPerformance = &archive2.Message3{}
+			if err := binary.Read(bytes.NewReader(d.bytes), binary.BigEndian, ar2.RadarPerformance); err != nil {
+				return nil, fmt.Errorf("nexradpb: radar performance: %s", err)
+			}
+		case 4:
+			elv, radials, err := decodeElevationScan(d.bytes)
+			if err != nil {
+				return nil, err
+			}
+			ar2.ElevationScans[elv] = append(ar2.ElevationScans[elv], radials...)
+		}
+	}
+}
+
+func decodeVolumeHeader(b []byte, vh *archive2.VolumeHeaderRecord) error {
+	d := &decoder{buf: b}
+	for {
+		field, err := d.next()
+		if err != nil || field == 0 {
+			return err
+		}
+		switch field {
+		case 1:
+			copy(vh.X_FileName[:], d.bytes)
+		case 2:
+			vh.X_ModifiedJulianDate = int32(d.varint)
+		case 3:
+			vh.X_ModifiedTime = int32(d.varint)
+		case 4:
+			copy(vh.ICAO[:], d.bytes)
+		}
+	}
+}
+
+func decodeElevationScan(b []byte) (int, []*archive2.Message31, error) {
+	elv := 0
+	var radials []*archive2.Message31
+	d := &decoder{buf: b}
+	for {
+		field, err := d.next()
+		if err != nil || field == 0 {
+			return elv, radials, err
+		}
+		switch field {
+		case 1:
+			elv = int(int32(d.varint))
+		case 2:
+			r, err := decodeRadial(d.bytes)
+			if err != nil {
+				return 0, nil, err
+			}
+			radials = append(radials, r)
+		}
+	}
+}
+
+func decodeRadial(b []byte) (*archive2.Message31, error) {
+	r := &archive2.Message31{}
+	h := &r.Header
+	d := &decoder{buf: b}
+	for {
+		field, err := d.next()
+		if err != nil || field == 0 {
+			return r, err
+		}
+		switch field {
+		case 1:
+			copy(h.RadarIdentifier[:], d.bytes)
+		case 2:
+			h.CollectionTime = uint32(d.varint)
+		case 3:
+			h.CollectionDate = uint16(d.varint)
+		case 4:
+			h.AzimuthNumber = uint16(d.varint)
+		case 5:
+			h.AzimuthAngle = d.float()
+		case 6:
+			h.CompressionIndicator = uint8(d.varint)
+		case 7:
+			h.RadialLength = uint16(d.varint)
+		case 8:
+			h.AzimuthResolutionSpacingCode = uint8(d.varint)
+		case 9:
+			h.RadialStatus = uint8(d.varint)
+		case 10:
+			h.ElevationNumber = uint8(d.varint)
+		case 11:
+			h.CutSectorNumber = uint8(d.varint)
+		case 12:
+			h.ElevationAngle = d.float()
+		case 13:
+			h.RadialSpotBlankingStatus = uint8(d.varint)
+		case 14:
+			h.AzimuthIndexingMode = uint8(d.varint)
+		case 15:
+			h.DataBlockCount = uint16(d.varint)
+		case 16:
+			if err := decodeVolumeData(d.bytes, &r.VolumeData); err != nil {
+				return nil, err
+			}
+		case 17:
+			if err := decodeElevationData(d.bytes, &r.ElevationData); err != nil {
+				return nil, err
+			}
+		case 18:
+			if err := decodeRadialData(d.bytes, &r.RadialData); err != nil {
+				return nil, err
+			}
+		case 19:
+			m, err := decodeMoment(d.bytes)
+			if err != nil {
+				return nil, err
+			}
+			setMoment(r, m)
+		}
+	}
+}
+
+func decodeVolumeData(b []byte, v *archive2.VolumeData) error {
+	v.DataBlock = dataBlock('R', "VOL")
+	d := &decoder{buf: b}
+	for {
+		field, err := d.next()
+		if err != nil || field == 0 {
+			return err
+		}
+		switch field {
+		case 1:
+			v.VersionMajor = uint8(d.varint)
+		case 2:
+			v.VersionMinor = uint8(d.varint)
+		case 3:
+			v.Lat = d.float()
+		case 4:
+			v.Long = d.float()
+		case 5:
+			v.SiteHeight = uint16(d.varint)
+		case 6:
+			v.FeedhornHeight = uint16(d.varint)
+		case 7:
+			v.CalibrationConstant = d.float()
+		case 8:
+			v.SHVTXPowerHor = d.float()
+		case 9:
+			v.SHVTXPowerVer = d.float()
+		case 10:
+			v.SystemDifferentialReflectivity = d.float()
+		case 11:
+			v.InitialSystemDifferentialPhase = d.float()
+		case 12:
+			v.VolumeCoveragePatternNumber = uint16(d.varint)
+		case 13:
+			v.ProcessingStatus = uint16(d.varint)
+		}
+	}
+}
+
+func decodeElevationData(b []byte, v *archive2.ElevationData) error {
+	v.DataBlock = dataBlock('R', "ELV")
+	d := &decoder{buf: b}
+	for {
+		field, err := d.next()
+		if err != nil || field == 0 {
+			return err
+		}
+		switch field {
+		case 1:
+			copy(v.ATMOS[:], d.bytes)
+		case 2:
+			v.CalibConst = d.float()
+		}
+	}
+}
+
+func decodeRadialData(b []byte, v *archive2.RadialData) error {
+	v.DataBlock = dataBlock('R', "RAD")
+	d := &decoder{buf: b}
+	for {
+		field, err := d.next()
+		if err != nil || field == 0 {
+			return err
+		}
+		switch field {
+		case 1:
+			v.UnambiguousRange = uint16(d.varint)
+		case 2:
+			v.NoiseLevelHorz = d.float()
+		case 3:
+			v.NoiseLevelVert = d.float()
+		case 4:
+			v.NyquistVelocity = uint16(d.varint)
+		case 5:
+			v.CalibConstHorzChan = d.float()
+		case 6:
+			v.CalibConstVertChan = d.float()
+		}
+	}
+}
+
+func decodeMoment(b []byte) (*archive2.DataMoment, error) {
+	m := &archive2.DataMoment{}
+	d := &decoder{buf: b}
+	for {
+		field, err := d.next()
+		if err != nil || field == 0 {
+			return m, err
+		}
+		switch field {
+		case 1:
+			m.DataBlock = dataBlock('D', d.string())
+		case 2:
+			m.NumberDataMomentGates = uint16(d.varint)
+		case 3:
+			m.DataMomentRange = uint16(d.varint)
+		case 4:
+			m.DataMomentRangeSampleInterval = uint16(d.varint)
+		case 5:
+			m.TOVER = uint16(d.varint)
+		case 6:
+			m.SNRThreshold = uint16(d.varint)
+		case 7:
+			m.ControlFlags = uint8(d.varint)
+		case 8:
+			m.DataWordSize = uint8(d.varint)
+		case 9:
+			m.Scale = d.float()
+		case 10:
+			m.Offset = d.float()
+		case 11:
+			m.Data = d.copyBytes()
+		}
+	}
+}
+
+// dataBlock returns a data block header. Names are padded with spaces, as in
+// the archive (e.g. "SW ").
+func dataBlock(typ byte, name string) archive2.DataBlock {
+	b := archive2.DataBlock{DataBlockType: [1]byte{typ}, DataName: [3]byte{' ', ' ', ' '}}
+	copy(b.DataName[:], name)
+	return b
+}
+
+// trimNull returns a fixed width string field without its null padding
+func trimNull(b []byte) string {
+	return strings.TrimRight(string(b), "\x00")
+}
+
+func momentName(d *archive2.DataMoment) string {
+	return strings.TrimRight(string(d.DataName[:]), " \x00")
+}
+
+// setMoment stores the moment in the radial's field for its name. Moments with
+// unknown names are dropped.
+func setMoment(r *archive2.Message31, m *archive2.DataMoment) {
+	r.SetMoment(momentName(m), m)
+}
+
+// MarshalProduct encodes a derived product as a Product message. Each radial
+// is identified by its elevation and azimuth numbers.
+func MarshalProduct(name string, p derived.Product) ([]byte, error) {
+	var radials []*archive2.Message31
+	for r := range p {
+		radials = append(radials, r)
+	}
+	// map iteration order is random, so sort for stable output
+	sort.Slice(radials, func(i, j int) bool {
+		a, b := radials[i].Header, radials[j].Header
+		if a.ElevationNumber != b.ElevationNumber {
+			return a.ElevationNumber < b.ElevationNumber
+		}
+		return a.AzimuthNumber < b.AzimuthNumber
+	})
+
+	e := &encoder{}
+	e.string(1, name)
+	for _, r := range radials {
+		m := p[r]
+		e.message(2, func(e *encoder) {
+			e.uint(1, uint64(r.Header.ElevationNumber))
+			e.uint(2, uint64(r.Header.AzimuthNumber))
+			e.message(3, func(e *encoder) { encodeMoment(e, momentName(m), m) })
+		})
+	}
+	return e.buf, nil
+}
+
+// UnmarshalProduct decodes a Product message, attaching its moments to the
+// matching radials of ar2. Moments for radials that aren't in ar2 are dropped.
+func UnmarshalProduct(b []byte, ar2 *archive2.Archive2) (string, derived.Product, error) {
+	type key struct {
+		elevation uint8
+		azimuth   uint16
+	}
+	radials := map[key]*archive2.Message31{}
+	for _, scan := range ar2.ElevationScans {
+		for _, r := range scan {
+			radials[key{r.Header.ElevationNumber, r.Header.AzimuthNumber}] = r
+		}
+	}
+
+	name := ""
+	p := derived.Product{}
+	d := &decoder{buf: b}
+	for {
+		field, err := d.next()
+		if err != nil || field == 0 {
+			return name, p, err
+		}
+		switch field {
+		case 1:
+			name = d.string()
+		case 2:
+			var k key
+			var m *archive2.DataMoment
+			rd := &decoder{buf: d.bytes}
+			for {
+				field, err := rd.next()
+				if err != nil {
+					return "", nil, err
+				}
+				if field == 0 {
+					break
+				}
+				switch field {
+				case 1:
+					k.elevation = uint8(rd.varint)
+				case 2:
+					k.azimuth = uint16(rd.varint)
+				case 3:
+					if m, err = decodeMoment(rd.bytes); err != nil {
+						return "", nil, err
+					}
+				}
+			}
+			if r := radials[k]; r != nil && m != nil {
+				p[r] = m
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package nexradpb
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/derived"
+	"github.com/kallsyms/go-nexrad/internal/testvol"
+)
+
+// testVolume is the shared test volume with a file name, time and RDA status,
+// and velocity and site details on every radial
+func testVolume() *archive2.Archive2 {
+	ar2 := testvol.Volume(2)
+	ar2.RadarStatus = &archive2.Message2{VolumeCoveragePatternNum: 212, RDABuild: 1900}
+	copy(ar2.VolumeHeader.X_FileName[:], "AR2V0006.001")
+	ar2.VolumeHeader.X_ModifiedJulianDate = 18000
+	ar2.VolumeHeader.X_ModifiedTime = 3600000
+	for _, scan := range ar2.ElevationScans {
+		for i, m31 := range scan {
+			m31.Header.CollectionTime = uint32(3600000 + i*50)
+			m31.VolumeData.SiteHeight = 370
+			m31.RadialData.NyquistVelocity = 2850
+			// velocity on the reflectivity's gates: below threshold, 0, 5 and 10 m/s
+			m31.VelocityData = testvol.Reflectivity()
+			m31.VelocityData.Offset = 129
+			m31.VelocityData.Data = []byte{0, 129, 139, 149}
+		}
+	}
+	return ar2
+}
+
+func TestMarshal(t *testing.T) {
+	ar2 := testVolume()
+	b, err := Marshal(ar2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Unmarshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.VolumeHeader != ar2.VolumeHeader {
+		t.Errorf("volume header %v, expected %v", decoded.VolumeHeader, ar2.VolumeHeader)
+	}
+	if *decoded.RadarStatus != *ar2.RadarStatus {
+		t.Errorf("radar status %+v, expected %+v", decoded.RadarStatus, ar2.RadarStatus)
+	}
+	if decoded.RadarPerformance != nil {
+		t.Errorf("expected no radar performance")
+	}
+	if len(decoded.ElevationScans[2]) != 360 {
+		t.Fatalf("expected 360 radials, got %d", len(decoded.ElevationScans[2]))
+	}
+	want, got := ar2.ElevationScans[2][90], decoded.ElevationScans[2][90]
+	if got.Header != want.Header {
+		t.Errorf("header %v, expected %v", got.Header, want.Header)
+	}
+	if got.VolumeData.Lat != want.VolumeData.Lat || got.VolumeData.SiteHeight != want.VolumeData.SiteHeight ||
+		got.RadialData.NyquistVelocity != want.RadialData.NyquistVelocity {
+		t.Errorf("unexpected volume or radial data %+v %+v", got.VolumeData, got.RadialData)
+	}
+	if got.SwData != nil {
+		t.Errorf("expected no spectrum width")
+	}
+	if !reflect.DeepEqual(got.VelocityData.ScaledData(), want.VelocityData.ScaledData()) ||
+		got.VelocityData.GenericDataMoment.Offset != 129 {
+		t.Errorf("velocity %v, expected %v", got.VelocityData.ScaledData(), want.VelocityData.ScaledData())
+	}
+	if name := string(got.VelocityData.DataName[:]); name != "VEL" {
+		t.Errorf("expected VEL data block, got %q", name)
+	}
+}
+
+func TestMarshalProduct(t *testing.T) {
+	ar2 := testVolume()
+	p := derived.Divergence(ar2.ElevationScans[2], 500)
+	b, err := MarshalProduct("div", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := MarshalProduct("div", p)
+	if !bytes.Equal(b, again) {
+		t.Error("expected stable encoding")
+	}
+
+	// attach to a copy of the volume, as a service receiving both would
+	vb, _ := Marshal(ar2)
+	decoded, _ := Unmarshal(vb)
+	name, dp, err := UnmarshalProduct(b, decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "div" || len(dp) != 360 {
+		t.Fatalf("unexpected product %q with %d radials", name, len(dp))
+	}
+	got := dp.Moment(decoded.ElevationScans[2][10]).ScaledData()
+	want := p.Moment(ar2.ElevationScans[2][10]).ScaledData()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("divergence %v, expected %v", got, want)
+	}
+}
+
+func TestUnmarshalTruncated(t *testing.T) {
+	b, _ := Marshal(testVolume())
+	if _, err := Unmarshal(b[:len(b)-3]); err == nil {
+		t.Error("expected an error decoding a truncated volume")
+	}
+}
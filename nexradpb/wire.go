@@ -0,0 +1,155 @@
+package nexradpb
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("nexradpb: truncated message")
+
+// encoder appends protobuf encoded fields. Zero values are omitted, as in
+// proto3.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	e.buf = append(e.buf, b[:binary.PutUvarint(b[:], v)]...)
+}
+
+func (e *encoder) tag(field, wireType int) {
+	e.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (e *encoder) uint(field int, v uint64) {
+	if v != 0 {
+		e.tag(field, wireVarint)
+		e.varint(v)
+	}
+}
+
+func (e *encoder) int(field int, v int64) {
+	// int32 and int64 are encoded as two's complement varints
+	e.uint(field, uint64(v))
+}
+
+func (e *encoder) float(field int, v float32) {
+	if v != 0 {
+		e.tag(field, wireFixed32)
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], math.Float32bits(v))
+		e.buf = append(e.buf, b[:]...)
+	}
+}
+
+func (e *encoder) bytes(field int, v []byte) {
+	if len(v) != 0 {
+		e.tag(field, wireBytes)
+		e.varint(uint64(len(v)))
+		e.buf = append(e.buf, v...)
+	}
+}
+
+func (e *encoder) string(field int, v string) {
+	e.bytes(field, []byte(v))
+}
+
+// message encodes an embedded message, with fields written by fn. Unlike
+// scalars, empty messages are still written so repeated fields keep their
+// elements.
+func (e *encoder) message(field int, fn func(e *encoder)) {
+	m := &encoder{}
+	fn(m)
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(m.buf)))
+	e.buf = append(e.buf, m.buf...)
+}
+
+// decoder reads protobuf encoded fields
+type decoder struct {
+	buf []byte
+	// the value of the last field read by next
+	varint uint64
+	fixed  uint64
+	bytes  []byte
+}
+
+// next reads the next field, returning its number, or 0 at the end of the
+// message
+func (d *decoder) next() (int, error) {
+	if len(d.buf) == 0 {
+		return 0, nil
+	}
+	key, err := d.readVarint()
+	if err != nil {
+		return 0, err
+	}
+	switch key & 7 {
+	case wireVarint:
+		d.varint, err = d.readVarint()
+	case wireFixed64:
+		if len(d.buf) < 8 {
+			return 0, errTruncated
+		}
+		d.fixed = binary.LittleEndian.Uint64(d.buf)
+		d.buf = d.buf[8:]
+	case wireFixed32:
+		if len(d.buf) < 4 {
+			return 0, errTruncated
+		}
+		d.fixed = uint64(binary.LittleEndian.Uint32(d.buf))
+		d.buf = d.buf[4:]
+	case wireBytes:
+		var n uint64
+		if n, err = d.readVarint(); err != nil {
+			return 0, err
+		}
+		if uint64(len(d.buf)) < n {
+			return 0, errTruncated
+		}
+		d.bytes = d.buf[:n]
+		d.buf = d.buf[n:]
+	default:
+		return 0, errors.New("nexradpb: unsupported wire type")
+	}
+	if err != nil {
+		return 0, err
+	}
+	if key>>3 == 0 {
+		return 0, errors.New("nexradpb: invalid field number 0")
+	}
+	return int(key >> 3), nil
+}
+
+func (d *decoder) readVarint() (uint64, error) {
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	d.buf = d.buf[n:]
+	return v, nil
+}
+
+func (d *decoder) float() float32 {
+	return math.Float32frombits(uint32(d.fixed))
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes)
+}
+
+// copyBytes returns a copy of the last bytes field, so decoded values don't
+// hold on to the whole input
+func (d *decoder) copyBytes() []byte {
+	return append([]byte(nil), d.bytes...)
+}
@@ -0,0 +1,86 @@
+package overlay
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// geoJSON holds the members of any GeoJSON object that layers are read from
+type geoJSON struct {
+	Type        string          `json:"type"`
+	Features    []geoJSON       `json:"features"`
+	Geometry    *geoJSON        `json:"geometry"`
+	Geometries  []geoJSON       `json:"geometries"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// ReadGeoJSON reads a layer from a GeoJSON feature collection, feature or
+// geometry. LineStrings and the rings of Polygons are drawn; points are
+// ignored.
+func ReadGeoJSON(r io.Reader) (*Layer, error) {
+	var obj geoJSON
+	if err := json.NewDecoder(r).Decode(&obj); err != nil {
+		return nil, fmt.Errorf("overlay: invalid GeoJSON: %s", err)
+	}
+	l := newLayer()
+	if err := l.addGeoJSON(&obj); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Layer) addGeoJSON(obj *geoJSON) error {
+	switch obj.Type {
+	case "FeatureCollection":
+		for i := range obj.Features {
+			if err := l.addGeoJSON(&obj.Features[i]); err != nil {
+				return err
+			}
+		}
+	case "Feature":
+		// features without a location have a null geometry
+		if obj.Geometry != nil {
+			return l.addGeoJSON(obj.Geometry)
+		}
+	case "GeometryCollection":
+		for i := range obj.Geometries {
+			if err := l.addGeoJSON(&obj.Geometries[i]); err != nil {
+				return err
+			}
+		}
+	case "LineString":
+		var line [][2]float64
+		if err := obj.coordinates(&line); err != nil {
+			return err
+		}
+		l.Lines = append(l.Lines, line)
+	case "MultiLineString", "Polygon":
+		var lines [][][2]float64
+		if err := obj.coordinates(&lines); err != nil {
+			return err
+		}
+		l.Lines = append(l.Lines, lines...)
+	case "MultiPolygon":
+		var polygons [][][][2]float64
+		if err := obj.coordinates(&polygons); err != nil {
+			return err
+		}
+		for _, rings := range polygons {
+			l.Lines = append(l.Lines, rings...)
+		}
+	case "Point", "MultiPoint":
+	default:
+		return fmt.Errorf("overlay: unsupported GeoJSON type %q", obj.Type)
+	}
+	return nil
+}
+
+// coordinates decodes the geometry's coordinates into v. Positions with an
+// altitude have it dropped.
+func (obj *geoJSON) coordinates(v interface{}) error {
+	if err := json.Unmarshal(obj.Coordinates, v); err != nil {
+		return fmt.Errorf("overlay: invalid %s coordinates: %s", obj.Type, err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Package overlay draws map layers, such as state and county boundaries and
+// highways, over rendered images. Layers are read from GeoJSON or shapefiles
+// as lines; polygons are drawn as their outlines. Range rings and site
+// markers are generated around the radar.
+package overlay
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/llgcode/draw2d"
+	"github.com/llgcode/draw2d/draw2dimg"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/inconsolata"
+	"golang.org/x/image/math/fixed"
+)
+
+// DefaultColor is the color layers are drawn in unless set
+var DefaultColor color.Color = color.NRGBA{0xff, 0xff, 0xff, 0xb0}
+
+// Layer is a set of lines to draw over an image
+type Layer struct {
+	// Lines are lists of [lon, lat] points in degrees
+	Lines [][][2]float64
+	Color color.Color
+	// Width is the width of the lines in pixels
+	Width float64
+	// Labels are drawn over the lines
+	Labels []Label
+}
+
+// PixelFunc returns the position in an image of a point, or NaNs if it isn't
+// in the image's projection
+type PixelFunc func(lat, lon float64) (x, y float64)
+
+// Load reads a layer from a file: a shapefile if the name ends in .shp,
+// otherwise GeoJSON
+func Load(name string) (*Layer, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var l *Layer
+	if strings.EqualFold(filepath.Ext(name), ".shp") {
+		l, err = ReadShapefile(f)
+	} else {
+		l, err = ReadGeoJSON(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	return l, nil
+}
+
+func newLayer() *Layer {
+	return &Layer{Color: DefaultColor, Width: 1}
+}
+
+// Draw strokes the layer's lines onto the image, then its labels, placing
+// points with pixel. Lines are broken where pixel returns NaNs.
+func (l *Layer) Draw(img *image.RGBA, pixel PixelFunc) {
+	gc := draw2dimg.NewGraphicContext(img)
+	gc.SetStrokeColor(l.Color)
+	gc.SetLineWidth(l.Width)
+	gc.SetLineCap(draw2d.RoundCap)
+	gc.SetLineJoin(draw2d.RoundJoin)
+	for _, line := range l.Lines {
+		started := false
+		for _, p := range line {
+			x, y := pixel(p[1], p[0])
+			if math.IsNaN(x) || math.IsNaN(y) {
+				started = false
+				continue
+			}
+			if started {
+				gc.LineTo(x, y)
+			} else {
+				gc.MoveTo(x, y)
+				started = true
+			}
+		}
+	}
+	gc.Stroke()
+
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(l.Color), Face: inconsolata.Regular8x16}
+	for _, label := range l.Labels {
+		x, y := pixel(label.Lat, label.Lon)
+		if math.IsNaN(x) || math.IsNaN(y) {
+			continue
+		}
+		if label.Marker {
+			gc.SetFillColor(l.Color)
+			gc.MoveTo(x+3, y)
+			gc.ArcTo(x, y, 3, 3, 0, 2*math.Pi)
+			gc.Fill()
+			x += 6
+		} else {
+			// centered above the point
+			x -= float64(d.MeasureString(label.Text).Round()) / 2
+			y -= 3
+		}
+		d.Dot = fixed.Point26_6{X: fixed.Int26_6(x * 64), Y: fixed.Int26_6(y * 64)}
+		d.DrawString(label.Text)
+	}
+}
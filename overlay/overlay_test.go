@@ -0,0 +1,142 @@
+package overlay
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestReadGeoJSON(t *testing.T) {
+	l, err := ReadGeoJSON(strings.NewReader(`{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "properties": {}, "geometry": {"type": "LineString", "coordinates": [[-97, 35], [-96, 35, 300]]}},
+		{"type": "Feature", "properties": {}, "geometry": {"type": "MultiPolygon", "coordinates": [[[[-98, 34], [-97, 34], [-97, 33], [-98, 34]]]]}},
+		{"type": "Feature", "properties": {}, "geometry": {"type": "Point", "coordinates": [-97, 35]}},
+		{"type": "Feature", "properties": {}, "geometry": null}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(l.Lines))
+	}
+	if p := l.Lines[0][1]; p != [2]float64{-96, 35} {
+		t.Errorf("expected the altitude dropped, got %v", p)
+	}
+	if len(l.Lines[1]) != 4 {
+		t.Errorf("expected the polygon's ring, got %v", l.Lines[1])
+	}
+
+	if _, err := ReadGeoJSON(strings.NewReader(`{"type": "Circle"}`)); err == nil {
+		t.Error("expected an error for an unknown type")
+	}
+	if _, err := ReadGeoJSON(strings.NewReader(`{"type": "LineString", "coordinates": 5}`)); err == nil {
+		t.Error("expected an error for invalid coordinates")
+	}
+}
+
+// testShapefile returns a polyline shapefile of the parts
+func testShapefile(parts ...[][2]float64) []byte {
+	var records bytes.Buffer
+	for i, part := range parts {
+		var content bytes.Buffer
+		binary.Write(&content, binary.LittleEndian, int32(shapePolyLine))
+		binary.Write(&content, binary.LittleEndian, [4]float64{})
+		binary.Write(&content, binary.LittleEndian, [2]int32{1, int32(len(part))})
+		binary.Write(&content, binary.LittleEndian, int32(0))
+		binary.Write(&content, binary.LittleEndian, part)
+		binary.Write(&records, binary.BigEndian, [2]int32{int32(i + 1), int32(content.Len() / 2)})
+		records.Write(content.Bytes())
+	}
+	header := make([]byte, 100)
+	binary.BigEndian.PutUint32(header[0:], 9994)
+	binary.BigEndian.PutUint32(header[24:], uint32((100+records.Len())/2))
+	binary.LittleEndian.PutUint32(header[28:], 1000)
+	binary.LittleEndian.PutUint32(header[32:], shapePolyLine)
+	return append(header, records.Bytes()...)
+}
+
+func TestReadShapefile(t *testing.T) {
+	shp := testShapefile([][2]float64{{-97, 35}, {-96, 35}}, [][2]float64{{-98, 34}, {-97, 34}, {-97, 33}})
+	l, err := ReadShapefile(bytes.NewReader(shp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Lines) != 2 || len(l.Lines[1]) != 3 {
+		t.Fatalf("expected lines of 2 and 3 points, got %v", l.Lines)
+	}
+	if p := l.Lines[1][2]; p != [2]float64{-97, 33} {
+		t.Errorf("expected -97, 33, got %v", p)
+	}
+
+	// a point count past the end of the record
+	binary.LittleEndian.PutUint32(shp[100+8+40:], 1000)
+	if _, err := ReadShapefile(bytes.NewReader(shp)); err == nil {
+		t.Error("expected an error for a truncated record")
+	}
+	if _, err := ReadShapefile(bytes.NewReader(make([]byte, 100))); err == nil {
+		t.Error("expected an error for a file that isn't a shapefile")
+	}
+}
+
+func TestDraw(t *testing.T) {
+	l := &Layer{
+		Lines: [][][2]float64{{{0, 5}, {8, 5}, {10, 5}}},
+		Color: color.RGBA{0xff, 0, 0, 0xff},
+		Width: 2,
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	// lon, lat are x, y, except lon 10 isn't in the image
+	l.Draw(img, func(lat, lon float64) (float64, float64) {
+		if lon > 9 {
+			return math.NaN(), math.NaN()
+		}
+		return lon, lat
+	})
+	if r, _, _, _ := img.At(4, 5).RGBA(); r < 0x8000 {
+		t.Errorf("expected the line drawn, got %v", img.At(4, 5))
+	}
+	if _, _, _, a := img.At(5, 1).RGBA(); a != 0 {
+		t.Errorf("expected nothing drawn away from the line, got %v", img.At(5, 1))
+	}
+}
+
+func TestRings(t *testing.T) {
+	l := Rings(35, -97, []float64{50000, 100000}, 90)
+	// two rings and four spokes
+	if len(l.Lines) != 6 {
+		t.Fatalf("expected 6 lines, got %d", len(l.Lines))
+	}
+	if len(l.Labels) != 2 || l.Labels[1].Text != "100 km" {
+		t.Errorf("expected labels for each ring, got %v", l.Labels)
+	}
+	// the east spoke reaches the outer ring
+	spoke := l.Lines[3]
+	if p := spoke[1]; math.Abs(p[1]-35) > 0.01 || math.Abs(p[0]-(-97+100/(111.2*math.Cos(35*math.Pi/180)))) > 0.01 {
+		t.Errorf("expected the east spoke to end 100 km east, got %v", p)
+	}
+}
+
+func TestMarker(t *testing.T) {
+	l := Marker(5, 5, "KTLX")
+	l.Color = color.RGBA{0xff, 0, 0, 0xff}
+	img := image.NewRGBA(image.Rect(0, 0, 50, 20))
+	l.Draw(img, func(lat, lon float64) (float64, float64) { return lon, lat })
+	if r, _, _, _ := img.At(5, 5).RGBA(); r < 0x8000 {
+		t.Errorf("expected a dot at the site, got %v", img.At(5, 5))
+	}
+	drawn := false
+	for x := 12; x < 50; x++ {
+		for y := 0; y < 20; y++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0 {
+				drawn = true
+			}
+		}
+	}
+	if !drawn {
+		t.Error("expected the site's name beside the dot")
+	}
+}
@@ -0,0 +1,54 @@
+package overlay
+
+import (
+	"fmt"
+
+	"github.com/kallsyms/go-nexrad/geo"
+)
+
+// Label is text drawn at a point of a layer
+type Label struct {
+	Lat, Lon float64
+	Text     string
+	// Marker draws a dot at the point, with the text beside it
+	Marker bool
+}
+
+// Rings returns a layer of range rings around the radar at lat, lon, at each
+// of ranges meters, labelled with their range in km at the top. If spokes
+// isn't 0, azimuth lines are drawn every spokes degrees clockwise from north
+// out to the last ring.
+func Rings(lat, lon float64, ranges []float64, spokes float64) *Layer {
+	l := newLayer()
+	outer := 0.0
+	for _, r := range ranges {
+		if r <= 0 {
+			continue
+		}
+		ring := make([][2]float64, 0, 361)
+		for az := 0; az <= 360; az++ {
+			plat, plon := geo.Destination(lat, lon, float64(az), r)
+			ring = append(ring, [2]float64{plon, plat})
+		}
+		l.Lines = append(l.Lines, ring)
+		plat, plon := geo.Destination(lat, lon, 0, r)
+		l.Labels = append(l.Labels, Label{Lat: plat, Lon: plon, Text: fmt.Sprintf("%g km", r/1000)})
+		if r > outer {
+			outer = r
+		}
+	}
+	if spokes > 0 && outer > 0 {
+		for az := 0.0; az < 360; az += spokes {
+			plat, plon := geo.Destination(lat, lon, az, outer)
+			l.Lines = append(l.Lines, [][2]float64{{lon, lat}, {plon, plat}})
+		}
+	}
+	return l
+}
+
+// Marker returns a layer marking the radar site at lat, lon with its name
+func Marker(lat, lon float64, name string) *Layer {
+	l := newLayer()
+	l.Labels = []Label{{Lat: lat, Lon: lon, Text: name, Marker: true}}
+	return l
+}
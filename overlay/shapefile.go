@@ -0,0 +1,115 @@
+package overlay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// shapefile shape types
+const (
+	shapeNull     = 0
+	shapePolyLine = 3
+	shapePolygon  = 5
+	// the Z and M variants have the same parts and points, followed by the
+	// measures
+	shapePolyLineZ = 13
+	shapePolygonZ  = 15
+	shapePolyLineM = 23
+	shapePolygonM  = 25
+)
+
+// maxShapeRecord is the largest shapefile record read, in bytes, so a corrupt
+// file fails rather than allocating GBs
+const maxShapeRecord = 64 << 20
+
+// ReadShapefile reads a layer from the .shp file of a shapefile of polylines
+// or polygons. The coordinates must be longitude and latitude, as in the
+// Census Bureau's TIGER/Line and cartographic boundary files; the .prj file
+// isn't read.
+func ReadShapefile(r io.Reader) (*Layer, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, 100)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("overlay: shapefile header: %s", err)
+	}
+	if code := binary.BigEndian.Uint32(header[0:]); code != 9994 {
+		return nil, fmt.Errorf("overlay: not a shapefile, file code %d", code)
+	}
+	switch t := binary.LittleEndian.Uint32(header[32:]); t {
+	case shapeNull, shapePolyLine, shapePolygon, shapePolyLineZ, shapePolygonZ, shapePolyLineM, shapePolygonM:
+	default:
+		return nil, fmt.Errorf("overlay: unsupported shape type %d, expected polylines or polygons", t)
+	}
+
+	l := newLayer()
+	recordHeader := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(br, recordHeader); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("overlay: shapefile record: %s", err)
+		}
+		number := binary.BigEndian.Uint32(recordHeader[0:])
+		// the length is in 16 bit words
+		length := int64(binary.BigEndian.Uint32(recordHeader[4:])) * 2
+		if length < 4 || length > maxShapeRecord {
+			return nil, fmt.Errorf("overlay: shapefile record %d: invalid length %d", number, length)
+		}
+		content := make([]byte, length)
+		if _, err := io.ReadFull(br, content); err != nil {
+			return nil, fmt.Errorf("overlay: shapefile record %d: %s", number, err)
+		}
+		lines, err := readShape(content)
+		if err != nil {
+			return nil, fmt.Errorf("overlay: shapefile record %d: %s", number, err)
+		}
+		l.Lines = append(l.Lines, lines...)
+	}
+	return l, nil
+}
+
+// readShape returns the parts of a polyline or polygon record's content
+func readShape(b []byte) ([][][2]float64, error) {
+	switch t := binary.LittleEndian.Uint32(b); t {
+	case shapeNull:
+		return nil, nil
+	case shapePolyLine, shapePolygon, shapePolyLineZ, shapePolygonZ, shapePolyLineM, shapePolygonM:
+	default:
+		return nil, fmt.Errorf("unsupported shape type %d", t)
+	}
+	// type, bounding box, number of parts and points
+	if len(b) < 44 {
+		return nil, fmt.Errorf("%d bytes, too short for a shape", len(b))
+	}
+	numParts := int64(binary.LittleEndian.Uint32(b[36:]))
+	numPoints := int64(binary.LittleEndian.Uint32(b[40:]))
+	pointsAt := 44 + 4*numParts
+	if pointsAt+16*numPoints > int64(len(b)) {
+		return nil, fmt.Errorf("%d parts and %d points don't fit in %d bytes", numParts, numPoints, len(b))
+	}
+
+	lines := make([][][2]float64, 0, numParts)
+	for i := int64(0); i < numParts; i++ {
+		start := int64(binary.LittleEndian.Uint32(b[44+4*i:]))
+		end := numPoints
+		if i+1 < numParts {
+			end = int64(binary.LittleEndian.Uint32(b[44+4*(i+1):]))
+		}
+		if start > end || end > numPoints {
+			return nil, fmt.Errorf("part %d has invalid points %d to %d", i, start, end)
+		}
+		line := make([][2]float64, end-start)
+		for j := range line {
+			p := pointsAt + 16*(start+int64(j))
+			line[j] = [2]float64{
+				math.Float64frombits(binary.LittleEndian.Uint64(b[p:])),
+				math.Float64frombits(binary.LittleEndian.Uint64(b[p+8:])),
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
@@ -0,0 +1,78 @@
+package render
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+// benchSweep is a full range super-resolution reflectivity sweep: 720
+// radials of 1832 gates, with values varying over range and azimuth so
+// neighboring pixels change color about as often as in real sweeps
+func benchSweep() []*archive2.Message31 {
+	var radials []*archive2.Message31
+	for az := 0; az < 720; az++ {
+		m31 := &archive2.Message31{}
+		m31.Header.AzimuthAngle = float32(az)/2 + 0.25
+		m31.Header.AzimuthResolutionSpacingCode = 1
+		data := make([]byte, 1832)
+		for i := range data {
+			// below threshold in a third of the sweep
+			if (i/100+az/30)%3 != 0 {
+				data[i] = byte(66 + (i/8+az/4)%150)
+			}
+		}
+		m31.ReflectivityData = &archive2.DataMoment{
+			GenericDataMoment: archive2.GenericDataMoment{
+				NumberDataMomentGates:         1832,
+				DataMomentRange:               2125,
+				DataMomentRangeSampleInterval: 250,
+				DataWordSize:                  8,
+				Scale:                         2,
+				Offset:                        66,
+			},
+			Data: data,
+		}
+		radials = append(radials, m31)
+	}
+	return radials
+}
+
+// benchSizes are the image sizes benchmarked, from web thumbnails to print
+var benchSizes = []int{512, 1024, 4096}
+
+func BenchmarkPPI(b *testing.B) {
+	sweep := benchSweep()
+	for _, smooth := range []bool{false, true} {
+		for _, size := range benchSizes {
+			name := fmt.Sprintf("%dpx", size)
+			if smooth {
+				name += "/smooth"
+			}
+			b.Run(name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := PPI(sweep, Options{Size: size, Smooth: smooth}); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestPPIAllocations is PPI's allocation budget: the image and a few
+// allocations a radial decoding and indexing its gates, not an allocation for
+// each pixel
+func TestPPIAllocations(t *testing.T) {
+	sweep := benchSweep()
+	n := testing.AllocsPerRun(5, func() {
+		if _, err := PPI(sweep, Options{Size: 512}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if budget := float64(4 * len(sweep)); n > budget {
+		t.Errorf("PPI allocated %v times, expected at most %v", n, budget)
+	}
+}
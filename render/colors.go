@@ -1,7 +1,8 @@
-package main
+package render
 
 import (
 	"image/color"
+	"math"
 
 	"github.com/kallsyms/go-nexrad/archive2"
 	"golang.org/x/image/colornames"
@@ -10,7 +11,7 @@ import (
 func rhoColor(val float32) color.Color {
 	// fmt.Println(val)
 	if val < 0.275 {
-		return colornames.Black
+		return color.Transparent
 	} else if val < 0.35 {
 		return colornames.Darkgrey
 	} else if val < 0.4 {
@@ -55,10 +56,8 @@ func rhoColor(val float32) color.Color {
 		return colornames.Pink
 	} else if val < 1.05 {
 		return colornames.Lavenderblush
-	} else {
-		return colornames.White
 	}
-	return colornames.Black
+	return colornames.White
 }
 
 func swColor(swx float32) color.Color {
@@ -78,12 +77,12 @@ func swColor(swx float32) color.Color {
 	} else if swx < 1000 {
 		return color.NRGBA{0x77, 0x00, 0x7d, 0xFF} // dark purple
 	}
-	return colornames.Black
+	return color.Transparent
 }
 
 func dbzColor(dbz float32) color.Color {
 	if dbz < 5.0 {
-		return colornames.Black
+		return color.Transparent
 	} else if dbz >= 5.0 && dbz < 10.0 {
 		return color.NRGBA{0x9C, 0x9C, 0x9C, 0xFF}
 	} else if dbz >= 10.0 && dbz < 15.0 {
@@ -118,7 +117,7 @@ func dbzColor(dbz float32) color.Color {
 
 func dbzColorCleanAirMode(dbz float32) color.Color {
 	if dbz < -28.0 {
-		return colornames.Black
+		return color.Transparent
 	} else if dbz >= -28.0 && dbz < -24.0 {
 		return color.NRGBA{0x9C, 0x9C, 0x9C, 0xFF}
 	} else if dbz >= -24.0 && dbz < -20.0 {
@@ -200,7 +199,7 @@ func dbzColorNOAA(dbz float32) color.Color {
 
 func dbzColorScopeClassic(dbz float32) color.Color {
 	if dbz < 5.0 {
-		return colornames.Black
+		return color.Transparent
 	} else if dbz >= 5.0 && dbz < 10.0 {
 		return color.NRGBA{0x02, 0x0d, 0x02, 0xFF}
 	} else if dbz >= 10.0 && dbz < 15.0 {
@@ -370,5 +369,191 @@ func dbzColorScope(dbz float32) color.Color {
 	if int(dbz) >= 0 && int(dbz) < len(colors) {
 		return colors[int(dbz)]
 	}
-	return colornames.Black
+	return color.Transparent
+}
+
+// gradientColor colors velocity derived gradients (s^-1) such as divergence and
+// shear. Like velocity, negative values (convergence, anticyclonic shear) are
+// green and positive values (divergence, cyclonic shear) are red.
+func gradientColor(val float32) color.Color {
+	colors := []color.Color{
+		color.NRGBA{0x57, 0xFA, 0x63, 0xff}, // -0.01
+		color.NRGBA{0x31, 0xE3, 0x2B, 0xff},
+		color.NRGBA{0x24, 0xAA, 0x1F, 0xff},
+		color.NRGBA{0x19, 0x76, 0x13, 0xff},
+		color.NRGBA{0x45, 0x67, 0x42, 0xff},
+		color.NRGBA{0x63, 0x4F, 0x50, 0xff}, // 0
+		color.NRGBA{0x7F, 0x03, 0x0C, 0xff},
+		color.NRGBA{0xB6, 0x07, 0x16, 0xff},
+		color.NRGBA{0xF3, 0x22, 0x45, 0xff},
+		color.NRGBA{0xF6, 0x50, 0x8A, 0xff},
+		color.NRGBA{0xFB, 0x8B, 0xBF, 0xff}, // 0.01
+	}
+	// scale to 10^-4 s^-1 so small gradients aren't truncated
+	v := int32(val * 1e4)
+	if v < -100 {
+		v = -100
+	} else if v > 100 {
+		v = 100
+	}
+	return colors[scaleInt(v, 100, -100, int32(len(colors))-1, 0)]
+}
+
+// zdrColor colors differential reflectivity from -4 dB (small drops and hail
+// tumbling in the beam) to 8 dB (large, flattened drops)
+func zdrColor(val float32) color.Color {
+	colors := []color.Color{
+		color.NRGBA{0x40, 0x40, 0x40, 0xff}, // -4
+		color.NRGBA{0x80, 0x80, 0x80, 0xff}, // -3
+		color.NRGBA{0xC0, 0xC0, 0xC0, 0xff}, // -2
+		color.NRGBA{0x3A, 0x2D, 0x8F, 0xff}, // -1
+		color.NRGBA{0x1E, 0x64, 0xD2, 0xff}, // 0
+		color.NRGBA{0x3C, 0xC8, 0xDC, 0xff}, // 1
+		color.NRGBA{0x32, 0xB4, 0x3C, 0xff}, // 2
+		color.NRGBA{0xF0, 0xE6, 0x32, 0xff}, // 3
+		color.NRGBA{0xF5, 0x96, 0x1E, 0xff}, // 4
+		color.NRGBA{0xE6, 0x1E, 0x1E, 0xff}, // 5
+		color.NRGBA{0x96, 0x0A, 0x14, 0xff}, // 6
+		color.NRGBA{0xF0, 0x78, 0xB4, 0xff}, // 7
+		color.NRGBA{0xFF, 0xFF, 0xFF, 0xff}, // 8
+	}
+	return rampColor(val, -4, 8, colors)
+}
+
+// phiColor colors differential phase from 0 to 360 degrees
+func phiColor(val float32) color.Color {
+	colors := []color.Color{
+		color.NRGBA{0x1E, 0x1E, 0x64, 0xff}, // 0
+		color.NRGBA{0x1E, 0x64, 0xD2, 0xff}, // 45
+		color.NRGBA{0x3C, 0xC8, 0xDC, 0xff}, // 90
+		color.NRGBA{0x32, 0xB4, 0x3C, 0xff}, // 135
+		color.NRGBA{0xF0, 0xE6, 0x32, 0xff}, // 180
+		color.NRGBA{0xF5, 0x96, 0x1E, 0xff}, // 225
+		color.NRGBA{0xE6, 0x1E, 0x1E, 0xff}, // 270
+		color.NRGBA{0x96, 0x0A, 0x14, 0xff}, // 315
+		color.NRGBA{0xF0, 0x78, 0xB4, 0xff}, // 360
+	}
+	return rampColor(val, 0, 360, colors)
+}
+
+// kdpColor colors specific differential phase from -2 to 8 deg/km. Values
+// around 0 are gray, as KDP is noisy outside of rain.
+func kdpColor(val float32) color.Color {
+	colors := []color.Color{
+		color.NRGBA{0x40, 0x40, 0x40, 0xff}, // -2
+		color.NRGBA{0x80, 0x80, 0x80, 0xff}, // -1
+		color.NRGBA{0xA0, 0xA0, 0xA0, 0xff}, // 0
+		color.NRGBA{0x1E, 0x64, 0xD2, 0xff}, // 1
+		color.NRGBA{0x3C, 0xC8, 0xDC, 0xff}, // 2
+		color.NRGBA{0x32, 0xB4, 0x3C, 0xff}, // 3
+		color.NRGBA{0xF0, 0xE6, 0x32, 0xff}, // 4
+		color.NRGBA{0xF5, 0x96, 0x1E, 0xff}, // 5
+		color.NRGBA{0xE6, 0x1E, 0x1E, 0xff}, // 6
+		color.NRGBA{0x96, 0x0A, 0x14, 0xff}, // 7
+		color.NRGBA{0xF0, 0x78, 0xB4, 0xff}, // 8
+	}
+	return rampColor(val, -2, 8, colors)
+}
+
+// cfpColor colors the power removed by the clutter filter from 0 to 50 dB
+func cfpColor(val float32) color.Color {
+	colors := []color.Color{
+		color.NRGBA{0x30, 0x30, 0x30, 0xff}, // 0
+		color.NRGBA{0x60, 0x60, 0x60, 0xff}, // 5
+		color.NRGBA{0x1E, 0x64, 0xD2, 0xff}, // 10
+		color.NRGBA{0x3C, 0xC8, 0xDC, 0xff}, // 15
+		color.NRGBA{0x32, 0xB4, 0x3C, 0xff}, // 20
+		color.NRGBA{0xF0, 0xE6, 0x32, 0xff}, // 25
+		color.NRGBA{0xF5, 0x96, 0x1E, 0xff}, // 30
+		color.NRGBA{0xE6, 0x1E, 0x1E, 0xff}, // 35
+		color.NRGBA{0x96, 0x0A, 0x14, 0xff}, // 40
+		color.NRGBA{0xF0, 0x78, 0xB4, 0xff}, // 45
+		color.NRGBA{0xFF, 0xFF, 0xFF, 0xff}, // 50
+	}
+	return rampColor(val, 0, 50, colors)
+}
+
+// etColor colors echo tops every 5 kft (1524 m) from the surface to 70 kft,
+// from gray through blues, greens, yellows and reds to magenta and white
+func etColor(height float32) color.Color {
+	colors := []color.Color{
+		color.NRGBA{0x76, 0x76, 0x76, 0xff}, // 0
+		color.NRGBA{0x00, 0xe0, 0xfe, 0xff}, // 5 kft
+		color.NRGBA{0x00, 0x80, 0xff, 0xff}, // 10
+		color.NRGBA{0x00, 0x00, 0xff, 0xff}, // 15
+		color.NRGBA{0x00, 0xfb, 0x90, 0xff}, // 20
+		color.NRGBA{0x00, 0xbb, 0x00, 0xff}, // 25
+		color.NRGBA{0x00, 0x8f, 0x00, 0xff}, // 30
+		color.NRGBA{0xff, 0xff, 0x00, 0xff}, // 35
+		color.NRGBA{0xff, 0xcf, 0x00, 0xff}, // 40
+		color.NRGBA{0xf8, 0x87, 0x00, 0xff}, // 45
+		color.NRGBA{0xff, 0x00, 0x00, 0xff}, // 50
+		color.NRGBA{0xae, 0x00, 0x00, 0xff}, // 55
+		color.NRGBA{0xff, 0x00, 0xff, 0xff}, // 60
+		color.NRGBA{0x99, 0x00, 0xcc, 0xff}, // 65
+		color.NRGBA{0xff, 0xff, 0xff, 0xff}, // 70
+	}
+	return colors[int(math.Max(0, math.Min(float64(len(colors)-1), float64(height)/1524)))]
+}
+
+// vilColor colors vertically integrated liquid every 5 kg/m^2 from 0 to 80
+func vilColor(vil float32) color.Color {
+	colors := []color.Color{
+		color.NRGBA{0x00, 0x00, 0x00, 0x00}, // 0
+		color.NRGBA{0x9c, 0x9c, 0x9c, 0xff}, // 5
+		color.NRGBA{0x76, 0x76, 0x76, 0xff}, // 10
+		color.NRGBA{0xff, 0xaa, 0xaa, 0xff}, // 15
+		color.NRGBA{0xee, 0x8c, 0x8c, 0xff}, // 20
+		color.NRGBA{0xc9, 0x70, 0x70, 0xff}, // 25
+		color.NRGBA{0x00, 0xfb, 0x90, 0xff}, // 30
+		color.NRGBA{0x00, 0xbb, 0x00, 0xff}, // 35
+		color.NRGBA{0xff, 0xff, 0x70, 0xff}, // 40
+		color.NRGBA{0xd0, 0xd0, 0x60, 0xff}, // 45
+		color.NRGBA{0xff, 0x60, 0x60, 0xff}, // 50
+		color.NRGBA{0xda, 0x00, 0x00, 0xff}, // 55
+		color.NRGBA{0xae, 0x00, 0x00, 0xff}, // 60
+		color.NRGBA{0x00, 0x00, 0xff, 0xff}, // 65
+		color.NRGBA{0xff, 0xff, 0xff, 0xff}, // 70
+		color.NRGBA{0xe7, 0x00, 0xff, 0xff}, // 75
+	}
+	return colors[int(math.Max(0, math.Min(float64(len(colors)-1), float64(vil)/5)))]
+}
+
+// vwpColor colors wind barbs by the RMS error in m/s of their fit, green for
+// the best fits through yellow, red and cyan to magenta, like the NEXRAD VAD
+// wind profile
+func vwpColor(rms float32) color.Color {
+	switch {
+	case rms < 1:
+		return color.NRGBA{0x00, 0xe0, 0x00, 0xff}
+	case rms < 2:
+		return color.NRGBA{0xff, 0xff, 0x00, 0xff}
+	case rms < 3:
+		return color.NRGBA{0xff, 0x00, 0x00, 0xff}
+	case rms < 4:
+		return color.NRGBA{0x00, 0xff, 0xff, 0xff}
+	}
+	return color.NRGBA{0xff, 0x00, 0xff, 0xff}
+}
+
+// rampColor returns the color of val from colors spread evenly from min to
+// max. Values out of range take the color at the end they're past.
+func rampColor(val, min, max float32, colors []color.Color) color.Color {
+	if val == archive2.MomentDataFolded {
+		return color.NRGBA{0x77, 0x00, 0x7d, 0xff}
+	}
+	i := int((val-min)/(max-min)*float32(len(colors)-1) + 0.5)
+	if i < 0 {
+		i = 0
+	} else if i >= len(colors) {
+		i = len(colors) - 1
+	}
+	return colors[i]
+}
+
+// scaleInt scales a number form one range to another range
+func scaleInt(value, oldMax, oldMin, newMax, newMin int32) int32 {
+	oldRange := (oldMax - oldMin)
+	newRange := (newMax - newMin)
+	return (((value - oldMin) * newRange) / oldRange) + newMin
 }
@@ -0,0 +1,137 @@
+package render
+
+import (
+	"image"
+	"image/draw"
+)
+
+// motionStep is the spacing in pixels of the points compared in the first,
+// coarse, search for motion, and of the shifts it tries
+const motionStep = 8
+
+// Motion estimates the displacement in pixels of the echoes from image a to
+// image b, up to maxShift in each direction, by finding the shift that best
+// matches them. Pixels that are the same in both images, such as the
+// background, overlays and the legend, are ignored, so only what moved is
+// matched.
+func Motion(a, b image.Image, maxShift int) image.Point {
+	ra, rb := toRGBA(a), toRGBA(b)
+	bounds := ra.Bounds().Intersect(rb.Bounds())
+
+	// the coarse search compares a pixel in every motionStep square, and the
+	// refinement every other pixel
+	changed := func(step int) []image.Point {
+		var points []image.Point
+		for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+			for x := bounds.Min.X; x < bounds.Max.X; x += step {
+				if pixelDistance(ra, rb, x, y, x, y) != 0 {
+					points = append(points, image.Pt(x, y))
+				}
+			}
+		}
+		return points
+	}
+	points := changed(motionStep)
+	if len(points) == 0 {
+		return image.ZP
+	}
+
+	// cost is the mean distance of the changed pixels of a to those of b
+	// shifted by d
+	cost := func(d image.Point) float64 {
+		sum, n := 0, 0
+		for _, p := range points {
+			q := p.Add(d)
+			if q.In(bounds) {
+				sum += pixelDistance(ra, rb, p.X, p.Y, q.X, q.Y)
+				n++
+			}
+		}
+		// shifts that leave little overlap aren't trusted
+		if n < len(points)/4 {
+			return float64(1 << 30)
+		}
+		return float64(sum) / float64(n)
+	}
+	best, bestCost := image.ZP, cost(image.ZP)
+	search := func(center image.Point, radius, step int) {
+		for dy := -radius; dy <= radius; dy += step {
+			for dx := -radius; dx <= radius; dx += step {
+				d := center.Add(image.Pt(dx, dy))
+				if d.X < -maxShift || d.X > maxShift || d.Y < -maxShift || d.Y > maxShift {
+					continue
+				}
+				if c := cost(d); c < bestCost {
+					best, bestCost = d, c
+				}
+			}
+		}
+	}
+	search(image.ZP, maxShift/motionStep*motionStep, motionStep)
+	points = changed(2)
+	bestCost = cost(best)
+	search(best, motionStep-1, 1)
+	return best
+}
+
+// Tween returns the image between a and b at t, from 0 (a) to 1 (b). Both
+// are moved along the motion of the echoes from a to b, see Motion, to where
+// they'd be at t and blended, so echoes move smoothly between volume scans
+// rather than jumping. With no motion the images are cross-faded. Pixels that
+// are the same in both are kept as they are.
+func Tween(a, b image.Image, t float64, motion image.Point) *image.RGBA {
+	ra, rb := toRGBA(a), toRGBA(b)
+	bounds := ra.Bounds().Intersect(rb.Bounds())
+	out := image.NewRGBA(bounds)
+
+	// a is moved forward by t of the motion and b back by the rest
+	da := image.Pt(int(float64(motion.X)*t+0.5), int(float64(motion.Y)*t+0.5))
+	db := motion.Sub(da)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			i := out.PixOffset(x, y)
+			if pixelDistance(ra, rb, x, y, x, y) == 0 {
+				copy(out.Pix[i:i+4], ra.Pix[ra.PixOffset(x, y):])
+				continue
+			}
+			pa, pb := image.Pt(x, y).Sub(da), image.Pt(x, y).Add(db)
+			if !pa.In(bounds) {
+				pa = image.Pt(x, y)
+			}
+			if !pb.In(bounds) {
+				pb = image.Pt(x, y)
+			}
+			ca := ra.Pix[ra.PixOffset(pa.X, pa.Y):]
+			cb := rb.Pix[rb.PixOffset(pb.X, pb.Y):]
+			for c := 0; c < 4; c++ {
+				out.Pix[i+c] = uint8(float64(ca[c])*(1-t) + float64(cb[c])*t + 0.5)
+			}
+		}
+	}
+	return out
+}
+
+// pixelDistance returns the sum of the differences of the channels of the
+// pixel of a at ax, ay and that of b at bx, by
+func pixelDistance(a, b *image.RGBA, ax, ay, bx, by int) int {
+	pa := a.Pix[a.PixOffset(ax, ay):]
+	pb := b.Pix[b.PixOffset(bx, by):]
+	d := 0
+	for c := 0; c < 4; c++ {
+		if pa[c] > pb[c] {
+			d += int(pa[c] - pb[c])
+		} else {
+			d += int(pb[c] - pa[c])
+		}
+	}
+	return d
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return rgba
+}
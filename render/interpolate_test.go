@@ -0,0 +1,52 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// echoImage returns a black image with a red square echo at x, y, its blue
+// varying across it, and a static white line across the top
+func echoImage(x, y int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 200, 200))
+	draw.Draw(img, img.Bounds(), image.Black, image.ZP, draw.Src)
+	for i := 0; i < 30; i++ {
+		for j := 0; j < 30; j++ {
+			img.SetRGBA(x+i, y+j, color.RGBA{0xff, 0, uint8(i*j) % 97, 0xff})
+		}
+	}
+	draw.Draw(img, image.Rect(0, 10, 200, 12), image.White, image.ZP, draw.Src)
+	return img
+}
+
+func TestMotion(t *testing.T) {
+	a, b := echoImage(50, 80), echoImage(63, 71)
+	if got, want := Motion(a, b, 40), image.Pt(13, -9); got != want {
+		t.Errorf("expected motion %v, got %v", want, got)
+	}
+	if got := Motion(a, a, 40); got != image.ZP {
+		t.Errorf("expected no motion of the same image, got %v", got)
+	}
+}
+
+func TestTween(t *testing.T) {
+	a, b := echoImage(50, 80), echoImage(70, 80)
+	mid := Tween(a, b, 0.5, image.Pt(20, 0))
+	// the echo is half way, at 60 to 90
+	if got := mid.RGBAAt(62, 90); got.R != 0xff {
+		t.Errorf("expected the moved echo at 62, got %v", got)
+	}
+	if got := mid.RGBAAt(55, 90); got.R != 0 {
+		t.Errorf("expected the echo gone from 55, got %v", got)
+	}
+	if got := mid.RGBAAt(100, 11); got.G != 0xff {
+		t.Errorf("expected the static line kept, got %v", got)
+	}
+
+	fade := Tween(a, b, 0.25, image.ZP)
+	if got := fade.RGBAAt(55, 90); got.R != 0xbf {
+		t.Errorf("expected a 3/4 faded echo at 55, got %v", got)
+	}
+}
@@ -0,0 +1,211 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/colornames"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/inconsolata"
+	"golang.org/x/image/math/fixed"
+)
+
+// labelMargin is the space in pixels between labels and the edges of images
+const labelMargin = 10
+
+// Corner is the corner of an image a label is drawn in
+type Corner int
+
+const (
+	BottomRight Corner = iota
+	BottomLeft
+	TopLeft
+	TopRight
+)
+
+var corners = map[string]Corner{
+	"bottom-right": BottomRight,
+	"bottom-left":  BottomLeft,
+	"top-left":     TopLeft,
+	"top-right":    TopRight,
+}
+
+// ParseCorner parses a corner by name: top-left, top-right, bottom-left or
+// bottom-right
+func ParseCorner(s string) (Corner, error) {
+	c, ok := corners[s]
+	if !ok {
+		return 0, fmt.Errorf("render: invalid corner %q, expected top-left, top-right, bottom-left or bottom-right", s)
+	}
+	return c, nil
+}
+
+// Font is a typeface at a size to draw labels in
+type Font struct {
+	Face font.Face
+	// Family, Size in pixels and Bold describe the face to SVG viewers,
+	// which draw text in their own fonts
+	Family string
+	Size   float64
+	Bold   bool
+}
+
+// DefaultFont is the bold 8x16 Inconsolata labels are drawn in by default
+var DefaultFont = &Font{Face: inconsolata.Bold8x16, Family: "monospace", Size: 14, Bold: true}
+
+// builtinFonts are the TrueType fonts LoadFont knows by name, and their
+// families and weights
+var builtinFonts = map[string]struct {
+	ttf    []byte
+	family string
+	bold   bool
+}{
+	"go":         {goregular.TTF, "sans-serif", false},
+	"gobold":     {gobold.TTF, "sans-serif", true},
+	"gomono":     {gomono.TTF, "monospace", false},
+	"gomonobold": {gomonobold.TTF, "monospace", true},
+}
+
+// LoadFont returns the named font at size pixels: inconsolata, the bitmap
+// DefaultFont, which is only 16 pixels, one of the Go fonts go, gobold, gomono
+// and gomonobold, or a TrueType (.ttf) file
+func LoadFont(name string, size float64) (*Font, error) {
+	if name == "inconsolata" {
+		if size != 16 {
+			return nil, fmt.Errorf("render: inconsolata is only 16 pixels, not %g", size)
+		}
+		return DefaultFont, nil
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("render: invalid font size %g", size)
+	}
+	builtin, ok := builtinFonts[name]
+	if !ok {
+		if !strings.HasSuffix(strings.ToLower(name), ".ttf") {
+			return nil, fmt.Errorf("render: unknown font %q, expected inconsolata, go, gobold, gomono, gomonobold or a .ttf file", name)
+		}
+		ttf, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		builtin.ttf, builtin.family = ttf, "sans-serif"
+	}
+	f, err := truetype.Parse(builtin.ttf)
+	if err != nil {
+		return nil, fmt.Errorf("render: invalid font %s: %s", name, err)
+	}
+	family := builtin.family
+	if n := f.Name(truetype.NameIDFontFamily); n != "" {
+		family = fmt.Sprintf("'%s', %s", n, family)
+	}
+	face := truetype.NewFace(f, &truetype.Options{Size: size, Hinting: font.HintingFull})
+	return &Font{Face: face, Family: family, Size: size, Bold: builtin.bold}, nil
+}
+
+// LabelStyle is how a label is drawn. The zero value draws it in the
+// DefaultFont, in gray, in the bottom right corner.
+type LabelStyle struct {
+	Font   *Font
+	Color  color.Color
+	Corner Corner
+}
+
+// watermarkColor is the default color of watermarks, translucent so the
+// data shows through
+var watermarkColor = color.NRGBA{0xff, 0xff, 0xff, 0x80}
+
+// withDefaults returns the style with its defaults filled in, c as the color
+func (s LabelStyle) withDefaults(c color.Color) LabelStyle {
+	if s.Font == nil {
+		s.Font = DefaultFont
+	}
+	if s.Color == nil {
+		s.Color = c
+	}
+	return s
+}
+
+// labelOrigin returns the start of the baseline of the text drawn in the
+// style in an image of the bounds, measuring it in the style's face
+func labelOrigin(bounds image.Rectangle, text string, s LabelStyle) (x, y int) {
+	m := s.Font.Face.Metrics()
+	switch s.Corner {
+	case TopLeft, BottomLeft:
+		x = bounds.Min.X + labelMargin
+	default:
+		x = bounds.Max.X - labelMargin - font.MeasureString(s.Font.Face, text).Ceil()
+	}
+	switch s.Corner {
+	case TopLeft, TopRight:
+		y = bounds.Min.Y + labelMargin + m.Ascent.Ceil()
+	default:
+		y = bounds.Max.Y - labelMargin
+	}
+	return x, y
+}
+
+// drawLabels draws the options' label and watermark
+func drawLabels(img *image.RGBA, opts Options) {
+	if opts.Label != "" {
+		drawLabel(img, opts.Label, opts.LabelStyle.withDefaults(colornames.Gray))
+	}
+	if opts.Watermark != "" {
+		drawLabel(img, opts.Watermark, opts.watermarkStyle())
+	}
+}
+
+// watermarkStyle returns the WatermarkStyle with its defaults, the label's
+// font and a translucent white
+func (opts *Options) watermarkStyle() LabelStyle {
+	s := opts.WatermarkStyle
+	if s.Font == nil {
+		s.Font = opts.LabelStyle.Font
+	}
+	return s.withDefaults(watermarkColor)
+}
+
+func drawLabel(img *image.RGBA, text string, s LabelStyle) {
+	x, y := labelOrigin(img.Bounds(), text, s)
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(s.Color),
+		Face: s.Font.Face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
+
+// writeSVGLabels writes the options' label and watermark as text
+func writeSVGLabels(w io.Writer, opts Options) {
+	bounds := image.Rect(0, 0, opts.Size, opts.Size)
+	if opts.Label != "" {
+		writeSVGLabel(w, bounds, opts.Label, opts.LabelStyle.withDefaults(colornames.Gray))
+	}
+	if opts.Watermark != "" {
+		writeSVGLabel(w, bounds, opts.Watermark, opts.watermarkStyle())
+	}
+}
+
+func writeSVGLabel(w io.Writer, bounds image.Rectangle, text string, s LabelStyle) {
+	x, y := labelOrigin(bounds, text, s)
+	anchor := "start"
+	if s.Corner == TopRight || s.Corner == BottomRight {
+		// viewers' fonts differ in width, so the text is anchored at its end
+		x, anchor = bounds.Max.X-labelMargin, "end"
+	}
+	weight := ""
+	if s.Font.Bold {
+		weight = " font-weight=\"bold\""
+	}
+	c := color.NRGBAModel.Convert(s.Color).(color.NRGBA)
+	fmt.Fprintf(w, "<text x=\"%d\" y=\"%d\" text-anchor=\"%s\" fill=%s font-family=\"%s\"%s font-size=\"%s\">%s</text>\n", x, y, anchor, svgColor("fill", c), escape(s.Font.Family), weight, coord(s.Font.Size), escape(text))
+}
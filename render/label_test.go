@@ -0,0 +1,78 @@
+package render
+
+import (
+	"image"
+	"testing"
+)
+
+// inked returns the bounds of the pixels drawn on in the image
+func inked(img *image.RGBA) image.Rectangle {
+	var r image.Rectangle
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if img.RGBAAt(x, y).A != 0 {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+func TestDrawLabel(t *testing.T) {
+	go16, err := LoadFont("go", 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		style  LabelStyle
+		corner image.Point
+	}{
+		{LabelStyle{}, image.Pt(400, 400)},
+		{LabelStyle{Corner: TopLeft}, image.Pt(0, 0)},
+		{LabelStyle{Font: go16, Corner: TopRight}, image.Pt(400, 0)},
+		{LabelStyle{Font: go16, Corner: BottomLeft}, image.Pt(0, 400)},
+	} {
+		img := image.NewRGBA(image.Rect(0, 0, 400, 400))
+		drawLabel(img, "KCRP 0.5", c.style.withDefaults(watermarkColor))
+		r := inked(img)
+		if r.Empty() {
+			t.Errorf("%v: nothing drawn", c.style.Corner)
+			continue
+		}
+		// the label is within the margin and a line's height of the corner
+		near := func(v, edge int) bool {
+			d := v - edge
+			return d >= -labelMargin-20 && d <= labelMargin+20
+		}
+		x, y := r.Min.X, r.Min.Y
+		if c.corner.X != 0 {
+			x = r.Max.X
+		}
+		if c.corner.Y != 0 {
+			y = r.Max.Y
+		}
+		if !near(x, c.corner.X) || !near(y, c.corner.Y) {
+			t.Errorf("%v: expected the label by %v, got %v", c.style.Corner, c.corner, r)
+		}
+	}
+}
+
+func TestLoadFont(t *testing.T) {
+	if f, err := LoadFont("inconsolata", 16); err != nil || f != DefaultFont {
+		t.Errorf("expected the default font, got %v, %v", f, err)
+	}
+	if _, err := LoadFont("inconsolata", 24); err == nil {
+		t.Error("expected an error sizing inconsolata")
+	}
+	if _, err := LoadFont("comic", 16); err == nil {
+		t.Error("expected an error for an unknown font")
+	}
+	f, err := LoadFont("gomono", 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := f.Face.Metrics().Height.Ceil(); h < 20 || h > 32 {
+		t.Errorf("expected a 24 pixel font, got a height of %d", h)
+	}
+}
@@ -0,0 +1,141 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/inconsolata"
+	"golang.org/x/image/math/fixed"
+)
+
+// Scale is the range of values and units shown by a legend
+type Scale struct {
+	Min, Max float32
+	Units    string
+}
+
+// ProductScales are the legend scales of the products, covering the values
+// their color schemes distinguish
+var ProductScales = map[string]Scale{
+	"ref":   {0, 80, "dBZ"},
+	"vel":   {-70, 70, "m/s"},
+	"sw":    {0, 30, "m/s"},
+	"zdr":   {-4, 8, "dB"},
+	"phi":   {0, 360, "deg"},
+	"kdp":   {-2, 8, "deg/km"},
+	"rho":   {0.2, 1.05, ""},
+	"cfp":   {0, 50, "dB"},
+	"div":   {-0.01, 0.01, "1/s"},
+	"shear": {-0.01, 0.01, "1/s"},
+	"cref":  {0, 80, "dBZ"},
+	"et":    {0, 21336, "m"},
+	"vil":   {0, 80, "kg/m2"},
+	"vwp":   {0, 5, "m/s"},
+}
+
+// SchemeScales are the legend scales of color schemes covering other values
+// than their product's ProductScales
+var SchemeScales = map[string]Scale{
+	"clean-air": {-28, 28, "dBZ"},
+}
+
+// ScaleFor returns the legend scale of the product in the color scheme
+func ScaleFor(product, scheme string) Scale {
+	if s, ok := SchemeScales[scheme]; ok {
+		return s
+	}
+	return ProductScales[product]
+}
+
+const (
+	legendBarWidth = 16
+	// legendWidth leaves room for the labels right of the bar
+	legendWidth  = legendBarWidth + 64
+	legendMargin = 8
+)
+
+var legendBackdrop = color.NRGBA{0, 0, 0, 0xa0}
+
+// legendLayout is the placement of a legend in an image
+type legendLayout struct {
+	// x0 is the left of the bar, and y0 to y1 its top and bottom
+	x0, y0, y1 int
+	backdrop   image.Rectangle
+	ticks      []legendTick
+}
+
+// legendTick is a labelled value on a legend's bar
+type legendTick struct {
+	y    int
+	text string
+}
+
+// layoutLegend places a legend of the scale in an image of the bounds, on the
+// right, with ticks at round values. ok is false if it doesn't fit.
+func layoutLegend(b image.Rectangle, s Scale) (l legendLayout, ok bool) {
+	height := b.Dy() * 3 / 5
+	if height < 60 || b.Dx() < legendWidth+2*legendMargin || s.Max <= s.Min {
+		return l, false
+	}
+	l.x0 = b.Max.X - legendWidth - legendMargin
+	l.y0 = b.Min.Y + (b.Dy()-height)/2
+	l.y1 = l.y0 + height
+	l.backdrop = image.Rect(l.x0-legendMargin, l.y0-24, b.Max.X-legendMargin/2, l.y1+legendMargin)
+
+	step := tickStep(float64(s.Max-s.Min) / 5)
+	decimals := int(math.Max(0, -math.Floor(math.Log10(step))))
+	for n := math.Ceil(float64(s.Min) / step); n*step <= float64(s.Max); n++ {
+		v := n * step
+		y := l.y0 + int(math.Round((float64(s.Max)-v)/float64(s.Max-s.Min)*float64(height-1)))
+		l.ticks = append(l.ticks, legendTick{y, strconv.FormatFloat(v, 'f', decimals, 64)})
+	}
+	return l, true
+}
+
+// valueAt returns the value of the scale at y on the bar
+func (l legendLayout) valueAt(s Scale, y int) float32 {
+	return s.Max - float32(y-l.y0)/float32(l.y1-l.y0-1)*(s.Max-s.Min)
+}
+
+// drawLegend draws a vertical color bar of the scale on the right of the
+// image, with ticks labelled at round values and the units on top
+func drawLegend(img *image.RGBA, s Scale, colors ColorTable) {
+	l, ok := layoutLegend(img.Bounds(), s)
+	if !ok {
+		return
+	}
+	// a translucent backdrop keeps the labels readable over the data
+	draw.Draw(img, l.backdrop, image.NewUniform(legendBackdrop), image.ZP, draw.Over)
+	for y := l.y0; y < l.y1; y++ {
+		draw.Draw(img, image.Rect(l.x0, y, l.x0+legendBarWidth, y+1), image.NewUniform(colors(l.valueAt(s, y))), image.ZP, draw.Over)
+	}
+
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(color.White), Face: inconsolata.Regular8x16}
+	text := func(x, y int, s string) {
+		d.Dot = fixed.P(x, y)
+		d.DrawString(s)
+	}
+	if s.Units != "" {
+		text(l.x0, l.y0-8, s.Units)
+	}
+	for _, t := range l.ticks {
+		draw.Draw(img, image.Rect(l.x0+legendBarWidth, t.y, l.x0+legendBarWidth+4, t.y+1), image.White, image.ZP, draw.Src)
+		text(l.x0+legendBarWidth+6, t.y+5, t.text)
+	}
+}
+
+// tickStep returns the round number (1, 2 or 5 times a power of 10) at least
+// as large as step
+func tickStep(step float64) float64 {
+	magnitude := math.Pow(10, math.Floor(math.Log10(step)))
+	for _, m := range []float64{1, 2, 5} {
+		if m*magnitude >= step {
+			return m * magnitude
+		}
+	}
+	return 10 * magnitude
+}
@@ -0,0 +1,43 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestTickStep(t *testing.T) {
+	for _, c := range []struct{ step, want float64 }{
+		{16, 20},
+		{28, 50},
+		{0.17, 0.2},
+		{0.004, 0.005},
+		{7, 10},
+		{1, 1},
+	} {
+		if got := tickStep(c.step); got != c.want {
+			t.Errorf("%g: expected %g, got %g", c.step, c.want, got)
+		}
+	}
+}
+
+func TestLegend(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 400))
+	// red above 0, blue below
+	drawLegend(img, Scale{-10, 10, "dB"}, func(v float32) color.Color {
+		if v > 0 {
+			return color.RGBA{0xff, 0, 0, 0xff}
+		}
+		return color.RGBA{0, 0, 0xff, 0xff}
+	})
+	x := 400 - legendWidth - legendMargin + legendBarWidth/2
+	if r, _, _, _ := img.At(x, 100).RGBA(); r != 0xffff {
+		t.Errorf("expected the top of the bar red, got %v", img.At(x, 100))
+	}
+	if _, _, b, _ := img.At(x, 300).RGBA(); b != 0xffff {
+		t.Errorf("expected the bottom of the bar blue, got %v", img.At(x, 300))
+	}
+	if _, _, _, a := img.At(100, 200).RGBA(); a != 0 {
+		t.Errorf("expected nothing drawn left of the legend, got %v", img.At(100, 200))
+	}
+}
@@ -0,0 +1,61 @@
+package render
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/kallsyms/go-nexrad/level3"
+)
+
+// Level3Products maps the Level III products that can be rendered to the
+// product whose color tables they're drawn with
+var Level3Products = map[string]string{
+	"N0R": "ref",
+	"N0Z": "ref",
+	"N0Q": "ref",
+	"NCR": "cref",
+	"NCZ": "cref",
+	"N0V": "vel",
+	"N0U": "vel",
+	"N0W": "sw",
+	"NET": "et",
+	"EET": "et",
+	"NVL": "vil",
+	"DVL": "vil",
+}
+
+// feetPerKft converts echo tops from the kft of Level III products to the
+// meters of the et color table
+const feetPerKft = 304.8
+
+// Level3 renders the radial image of a Level III product centered on the
+// radar, resampled as with Grid. Product defaults to the product's entry in
+// Level3Products, and Size and Radius to those of PPI.
+func Level3(p *level3.Product, opts Options) (image.Image, error) {
+	if opts.Product == "" {
+		product, ok := Level3Products[p.Name()]
+		if !ok {
+			return nil, fmt.Errorf("render: unsupported level 3 product %q", p.Name())
+		}
+		opts.Product = product
+	}
+	if opts.Size == 0 {
+		opts.Size = 1024
+	}
+	if opts.Size < 0 {
+		return nil, fmt.Errorf("render: invalid size %d", opts.Size)
+	}
+	if opts.Radius == 0 {
+		opts.Radius = DefaultRadius
+	}
+	g, err := p.Grid(opts.Radius, opts.Size)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Product == "et" {
+		for i := range g.Values {
+			g.Values[i] *= feetPerKft
+		}
+	}
+	return Grid(g, opts)
+}
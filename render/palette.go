@@ -0,0 +1,261 @@
+package render
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+// Palette is a color table loaded from a file, made of stops that colors are
+// interpolated between
+type Palette struct {
+	Stops []Stop
+	// Folded is the color of range folded gates
+	Folded color.Color
+	// Scale and Offset convert values to the palette's units before looking
+	// up their color, v*Scale + Offset
+	Scale  float32
+	Offset float32
+}
+
+// Stop is a value in a palette and its color. Values from it up to the next
+// stop are interpolated from Color to End, or are all Color if Solid is set.
+type Stop struct {
+	Value float32
+	Color color.NRGBA
+	End   color.NRGBA
+	Solid bool
+}
+
+// Color returns the color of the value, interpolated between the stops around
+// it. Values below the first stop are transparent, and those past the last
+// are the last stop's color.
+func (p *Palette) Color(v float32) color.Color {
+	if v == archive2.MomentDataFolded {
+		return p.Folded
+	}
+	v = v*p.Scale + p.Offset
+	i := sort.Search(len(p.Stops), func(i int) bool { return p.Stops[i].Value > v }) - 1
+	if i < 0 {
+		return color.Transparent
+	}
+	s := p.Stops[i]
+	if s.Solid || i == len(p.Stops)-1 {
+		return s.Color
+	}
+	f := float64(v-s.Value) / float64(p.Stops[i+1].Value-s.Value)
+	lerp := func(a, b uint8) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*f + 0.5)
+	}
+	return color.NRGBA{lerp(s.Color.R, s.End.R), lerp(s.Color.G, s.End.G), lerp(s.Color.B, s.End.B), lerp(s.Color.A, s.End.A)}
+}
+
+// Range returns the values from the first stop to the last, in the units of
+// the values colored rather than the palette's
+func (p *Palette) Range() (min, max float32) {
+	first, last := p.Stops[0].Value, p.Stops[len(p.Stops)-1].Value
+	min, max = (first-p.Offset)/p.Scale, (last-p.Offset)/p.Scale
+	if min > max {
+		min, max = max, min
+	}
+	return min, max
+}
+
+// LoadPalette reads a color table file: a GRLevelX palette if the name ends in
+// .pal, otherwise CSV
+func LoadPalette(name string) (*Palette, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(name), ".pal") {
+		return ReadPal(f)
+	}
+	return ReadCSV(f)
+}
+
+// ReadPal reads a GRLevelX (GR2Analyst) palette. The Color, Color4,
+// SolidColor, SolidColor4, Scale, Offset and RF lines are used; other lines,
+// such as Product and Units, and comments starting with ; or # are ignored.
+//
+// A Color line with a second color interpolates from the first to it up to
+// the next stop; otherwise it interpolates to the next stop's color.
+func ReadPal(r io.Reader) (*Palette, error) {
+	p := newPalette()
+	// stops whose end color is the next stop's color
+	var open []int
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.IndexAny(line, ";#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:colon]))
+		fields := strings.Fields(line[colon+1:])
+
+		var err error
+		switch key {
+		case "color", "color4", "solidcolor", "solidcolor4":
+			var s Stop
+			var end bool
+			s, end, err = parseStop(fields, strings.HasSuffix(key, "4"))
+			s.Solid = strings.HasPrefix(key, "solid")
+			if err == nil {
+				if !end {
+					open = append(open, len(p.Stops))
+				}
+				p.Stops = append(p.Stops, s)
+			}
+		case "scale", "offset":
+			var v float32
+			v, err = parseFloat(fields)
+			if key == "scale" {
+				p.Scale = v
+			} else {
+				p.Offset = v
+			}
+		case "rf":
+			var c color.NRGBA
+			c, err = parseColor(fields, false)
+			p.Folded = c
+		}
+		if err != nil {
+			return nil, fmt.Errorf("render: palette line %d: %s", n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for _, i := range open {
+		p.Stops[i].End = p.Stops[i].Color
+		if i+1 < len(p.Stops) {
+			p.Stops[i].End = p.Stops[i+1].Color
+		}
+	}
+	return p.check()
+}
+
+// ReadCSV reads a color table of value,r,g,b[,a] stops, one per line. Colors
+// are interpolated between the stops. Lines that don't start with a number,
+// such as a header, are ignored.
+func ReadCSV(r io.Reader) (*Palette, error) {
+	p := newPalette()
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("render: color table: %s", err)
+		}
+		if _, err := strconv.ParseFloat(record[0], 32); err != nil {
+			continue
+		}
+		if len(record) != 4 && len(record) != 5 {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("render: color table line %d: expected value,r,g,b[,a]", line)
+		}
+		s, _, err := parseStop(record, len(record) == 5)
+		if err != nil {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("render: color table line %d: %s", line, err)
+		}
+		p.Stops = append(p.Stops, s)
+	}
+	for i := range p.Stops {
+		p.Stops[i].End = p.Stops[i].Color
+		if i+1 < len(p.Stops) {
+			p.Stops[i].End = p.Stops[i+1].Color
+		}
+	}
+	return p.check()
+}
+
+func newPalette() *Palette {
+	// the range folded color of the compiled in schemes
+	return &Palette{Folded: color.NRGBA{0x77, 0x00, 0x7d, 0xff}, Scale: 1}
+}
+
+// check returns the palette if its stops are in increasing order
+func (p *Palette) check() (*Palette, error) {
+	if len(p.Stops) == 0 {
+		return nil, fmt.Errorf("render: color table has no colors")
+	}
+	for i := 1; i < len(p.Stops); i++ {
+		if p.Stops[i].Value <= p.Stops[i-1].Value {
+			return nil, fmt.Errorf("render: color table values aren't increasing at %g", p.Stops[i].Value)
+		}
+	}
+	return p, nil
+}
+
+// parseStop parses a value followed by one or two r g b (a if alpha is set)
+// colors. end is set if there are two, the second being the stop's End.
+func parseStop(fields []string, alpha bool) (s Stop, end bool, err error) {
+	if s.Value, err = parseFloat(fields); err != nil {
+		return Stop{}, false, err
+	}
+	if s.Color, err = parseColor(fields[1:], alpha); err != nil {
+		return Stop{}, false, err
+	}
+	s.End = s.Color
+	n := 3
+	if alpha {
+		n = 4
+	}
+	if len(fields) > 1+n {
+		if s.End, err = parseColor(fields[1+n:], alpha); err != nil {
+			return Stop{}, false, err
+		}
+		end = true
+	}
+	return s, end, nil
+}
+
+func parseFloat(fields []string) (float32, error) {
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("missing value")
+	}
+	v, err := strconv.ParseFloat(fields[0], 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", fields[0])
+	}
+	return float32(v), nil
+}
+
+// parseColor parses r g b, and a if alpha is set, components from 0 to 255
+func parseColor(fields []string, alpha bool) (color.NRGBA, error) {
+	n := 3
+	if alpha {
+		n = 4
+	}
+	if len(fields) < n {
+		return color.NRGBA{}, fmt.Errorf("expected %d color components, got %d", n, len(fields))
+	}
+	c := [4]uint8{255, 255, 255, 255}
+	for i := 0; i < n; i++ {
+		v, err := strconv.Atoi(strings.TrimSpace(fields[i]))
+		if err != nil || v < 0 || v > 255 {
+			return color.NRGBA{}, fmt.Errorf("invalid color component %q", fields[i])
+		}
+		c[i] = uint8(v)
+	}
+	return color.NRGBA{c[0], c[1], c[2], c[3]}, nil
+}
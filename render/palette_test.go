@@ -0,0 +1,69 @@
+package render
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+func TestReadPal(t *testing.T) {
+	pal := `; reflectivity
+Product: BR
+Units: DBZ
+Step: 5
+
+Color: 10 0 0 0 0 0 100
+Color4: 20 0 200 0 255
+SolidColor: 30 200 0 0
+Color: 40 255 255 255
+RF: 10 20 30
+`
+	p, err := ReadPal(strings.NewReader(pal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		v    float32
+		want color.Color
+	}{
+		{5, color.Transparent},
+		{10, color.NRGBA{0, 0, 0, 255}},
+		// interpolated to the stop's second color
+		{15, color.NRGBA{0, 0, 50, 255}},
+		// interpolated to the next stop's color
+		{25, color.NRGBA{100, 100, 0, 255}},
+		{35, color.NRGBA{200, 0, 0, 255}},
+		{70, color.NRGBA{255, 255, 255, 255}},
+		{archive2.MomentDataFolded, color.NRGBA{10, 20, 30, 255}},
+	} {
+		if got := p.Color(c.v); got != c.want {
+			t.Errorf("%g: expected %v, got %v", c.v, c.want, got)
+		}
+	}
+
+	if min, max := p.Range(); min != 10 || max != 40 {
+		t.Errorf("expected a range of 10 to 40, got %g to %g", min, max)
+	}
+
+	if _, err := ReadPal(strings.NewReader("Color: 10 0 0\n")); err == nil {
+		t.Error("expected an error for a color with too few components")
+	}
+	if _, err := ReadPal(strings.NewReader("Color: 20 0 0 0\nColor: 10 0 0 0\n")); err == nil {
+		t.Error("expected an error for decreasing values")
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	p, err := ReadCSV(strings.NewReader("value,r,g,b,a\n# comment\n0,0,0,0,255\n10, 100, 200, 0, 255\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.Color(5), (color.NRGBA{50, 100, 0, 255}); got != want {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if _, err := ReadCSV(strings.NewReader("0,0,0\n")); err == nil {
+		t.Error("expected an error for a short line")
+	}
+}
@@ -0,0 +1,48 @@
+package render
+
+import (
+	"image"
+	"image/color"
+)
+
+// Paletted returns the image as a paletted image if it has at most max
+// colors, up to 256, otherwise nil. Color tables have far fewer colors than
+// that, so rendered images usually fit, and paletted images encode to PNGs
+// several times smaller, and quicker, than RGBA. Transparent is always the
+// first color of the palette, as GIFs need.
+func Paletted(img image.Image, max int) *image.Paletted {
+	if max > 256 {
+		max = 256
+	}
+	rgba := toRGBA(img)
+	b := rgba.Bounds()
+	pi := image.NewPaletted(b, nil)
+	p := color.Palette{color.RGBA{}}
+	index := map[uint32]uint8{0: 0}
+	// neighboring pixels are mostly the same color, so the last is
+	// remembered to skip most lookups
+	last, lastIndex := uint32(0), uint8(0)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		src := rgba.Pix[rgba.PixOffset(b.Min.X, y):]
+		dst := pi.Pix[pi.PixOffset(b.Min.X, y):]
+		for x := 0; x < b.Dx(); x++ {
+			c := src[4*x : 4*x+4]
+			key := uint32(c[0])<<24 | uint32(c[1])<<16 | uint32(c[2])<<8 | uint32(c[3])
+			if key != last {
+				i, ok := index[key]
+				if !ok {
+					if len(p) >= max {
+						return nil
+					}
+					i = uint8(len(p))
+					index[key] = i
+					p = append(p, color.RGBA{c[0], c[1], c[2], c[3]})
+				}
+				last, lastIndex = key, i
+			}
+			dst[x] = lastIndex
+		}
+	}
+	pi.Palette = p
+	return pi
+}
@@ -0,0 +1,31 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestPaletted(t *testing.T) {
+	img := image.NewRGBA(image.Rect(10, 10, 60, 60))
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	draw.Draw(img, image.Rect(20, 20, 40, 40), image.NewUniform(red), image.ZP, draw.Src)
+	img.Set(50, 50, color.RGBA{0, 0, 0x80, 0x80})
+
+	pi := Paletted(img, 256)
+	if pi == nil {
+		t.Fatal("expected a paletted image")
+	}
+	if len(pi.Palette) != 3 || pi.Palette[0] != (color.RGBA{}) {
+		t.Errorf("expected transparent and two colors, got %v", pi.Palette)
+	}
+	for _, p := range []image.Point{{10, 10}, {25, 30}, {50, 50}, {59, 59}} {
+		if got, want := pi.At(p.X, p.Y), img.At(p.X, p.Y); got != want {
+			t.Errorf("%v: expected %v, got %v", p, want, got)
+		}
+	}
+	if Paletted(img, 2) != nil {
+		t.Error("expected nil for an image with more colors than the limit")
+	}
+}
@@ -0,0 +1,531 @@
+// Package render draws radar sweeps as images, in the plan position indicator
+// (PPI) view with the radar at the center.
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/derived"
+	"github.com/kallsyms/go-nexrad/geo"
+	"github.com/kallsyms/go-nexrad/grid"
+	"github.com/kallsyms/go-nexrad/overlay"
+	"github.com/kallsyms/go-nexrad/sites"
+	"golang.org/x/image/colornames"
+)
+
+// DefaultRadius is the range in meters from the radar covered by an image
+const DefaultRadius = 460000
+
+// Products are the products that can be rendered
+var Products = []string{"ref", "vel", "sw", "zdr", "phi", "kdp", "rho", "cfp", "div", "shear"}
+
+// VolumeProducts are the products computed from every sweep of a volume,
+// rendered from grids rather than sweeps: composite reflectivity (dBZ), echo
+// tops (m above sea level) and vertically integrated liquid (kg/m^2)
+var VolumeProducts = []string{"cref", "et", "vil"}
+
+// ProductMoments maps products to the archive 2 moment they're rendered (or
+// derived) from
+var ProductMoments = map[string]string{
+	"ref":   "REF",
+	"vel":   "VEL",
+	"sw":    "SW",
+	"zdr":   "ZDR",
+	"phi":   "PHI",
+	"kdp":   "PHI",
+	"rho":   "RHO",
+	"cfp":   "CFP",
+	"div":   "VEL",
+	"shear": "VEL",
+}
+
+// ColorTable returns the color of a product value
+type ColorTable func(float32) color.Color
+
+// ColorSchemes are the color tables of each product by name. Every product has
+// a noaa scheme, which is its default.
+var ColorSchemes = map[string]map[string]ColorTable{
+	"ref": {
+		"noaa":          dbzColorNOAA,
+		"radarscope":    dbzColorScope,
+		"scope-classic": dbzColorScopeClassic,
+		"pink":          dbzColor,
+		"clean-air":     dbzColorCleanAirMode,
+	},
+	"vel": {
+		"noaa":       velColorRadarscope, // placeholder for default product value
+		"radarscope": velColorRadarscope,
+	},
+	"sw": {
+		"noaa": swColor,
+	},
+	"zdr": {
+		"noaa": zdrColor,
+	},
+	"phi": {
+		"noaa": phiColor,
+	},
+	"kdp": {
+		"noaa": kdpColor,
+	},
+	"rho": {
+		"noaa": rhoColor,
+	},
+	"cfp": {
+		"noaa": cfpColor,
+	},
+	"div": {
+		"noaa": gradientColor,
+	},
+	"shear": {
+		"noaa": gradientColor,
+	},
+	"cref": {
+		"noaa":          dbzColorNOAA,
+		"radarscope":    dbzColorScope,
+		"scope-classic": dbzColorScopeClassic,
+		"pink":          dbzColor,
+		"clean-air":     dbzColorCleanAirMode,
+	},
+	"et": {
+		"noaa": etColor,
+	},
+	"vil": {
+		"noaa": vilColor,
+	},
+	"vwp": {
+		"noaa": vwpColor,
+	},
+}
+
+// Colors returns the product's color table with the name
+func Colors(product, scheme string) (ColorTable, error) {
+	c, ok := ColorSchemes[product][scheme]
+	if !ok {
+		return nil, fmt.Errorf("render: unsupported %s color scheme %s", product, scheme)
+	}
+	return c, nil
+}
+
+// MomentFor returns a function selecting the product's moment from a radial
+// of the sweep. Derived products are computed for the whole sweep up front.
+func MomentFor(product string, radials []*archive2.Message31) grid.MomentFunc {
+	switch product {
+	case "div":
+		return derived.Divergence(radials, 1000).Moment
+	case "shear":
+		return derived.AzimuthalShear(radials, 2).Moment
+	case "kdp":
+		return derived.KDP(radials, 2000).Moment
+	}
+	name, ok := ProductMoments[product]
+	if !ok {
+		name = "REF"
+	}
+	return func(m *archive2.Message31) *archive2.DataMoment { return m.Moment(name) }
+}
+
+// AvailableProducts returns the products that have data in the elevation scan
+func AvailableProducts(ar2 *archive2.Archive2, elv int) []string {
+	moments := map[string]bool{}
+	for _, m := range ar2.AvailableMoments(elv) {
+		moments[m] = true
+	}
+	available := []string{}
+	for _, p := range Products {
+		if moments[ProductMoments[p]] {
+			available = append(available, p)
+		}
+	}
+	return available
+}
+
+// HasProduct reports whether the product has data in the elevation scan
+func HasProduct(ar2 *archive2.Archive2, elv int, product string) bool {
+	for _, p := range AvailableProducts(ar2, elv) {
+		if p == product {
+			return true
+		}
+	}
+	return false
+}
+
+// Options configure how a sweep is rendered. The zero value renders
+// reflectivity in the noaa color scheme at 1024 pixels.
+type Options struct {
+	// Product is the product to render, one of Products
+	Product string
+	// Size is the width and height of the image in pixels
+	Size int
+	// ColorTable colors the product's values, defaulting to the product's
+	// noaa color scheme
+	ColorTable ColorTable
+	// Radius is the range in meters from the center to the edges of the
+	// image, defaulting to DefaultRadius
+	Radius float64
+	// East and North place the center of the image, in meters from the
+	// radar, to zoom in on part of the sweep. The image is centered on the
+	// radar by default.
+	East, North float64
+	// Label, if set, is drawn in the LabelStyle, by default in the bottom
+	// right corner
+	Label      string
+	LabelStyle LabelStyle
+	// Watermark, if set, is drawn in the WatermarkStyle, by default in the
+	// top left corner in the label's font in translucent white, e.g. to stamp
+	// the time on every frame of an animation
+	Watermark      string
+	WatermarkStyle LabelStyle
+	// Background fills the image behind the gates, defaulting to black. Use
+	// color.Transparent for images to be composited over a map.
+	Background color.Color
+	// Overlays are drawn over the gates in order, e.g. state and county
+	// boundaries
+	Overlays []*overlay.Layer
+	// Legend, if set, draws a color bar of the scale on the right
+	Legend *Scale
+	// Moment, if set, selects the values of the product from each radial
+	// instead of MomentFor, e.g. to render a thresholded moment
+	Moment grid.MomentFunc
+	// Smooth interpolates between gates rather than drawing each as a block
+	// of its color, for images without blocky gate edges when zoomed in
+	Smooth bool
+}
+
+// PPI renders the product from the sweep's radials with the radar at the
+// center of the image. Each pixel takes the color of the gate under its
+// center, so adjacent gates and radials meet without seams or overlaps. Gates
+// below threshold, and values the color table leaves transparent, show the
+// background.
+func PPI(sweep []*archive2.Message31, opts Options) (image.Image, error) {
+	if err := opts.ppiDefaults(); err != nil {
+		return nil, err
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, opts.Size, opts.Size))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(opts.Background), image.ZP, draw.Src)
+
+	pxPerKm, xc, yc := opts.ppiGeometry()
+	gates := newPolarIndex(sweep, opts.momentFor(sweep))
+	defer gates.release()
+	lookup := gates.at
+	if opts.Smooth {
+		lookup = gates.smoothAt
+	}
+
+	mPerPx := 1000 / pxPerKm
+	// neighboring pixels are mostly the same gate, so the last color is
+	// reused rather than looked up again. It's kept as RGBA, which the canvas
+	// sets without converting and boxing it for every pixel.
+	last, lastColor := float32(math.NaN()), color.RGBA{}
+	for py := 0; py < opts.Size; py++ {
+		y := (float64(py) + 0.5 - yc) * mPerPx
+		for px := 0; px < opts.Size; px++ {
+			v, ok := lookup((float64(px)+0.5-xc)*mPerPx, y)
+			if !ok {
+				continue
+			}
+			if v != last {
+				last, lastColor = v, rgbaColor(opts.ColorTable(v))
+			}
+			setPixelRGBA(canvas, px, py, lastColor)
+		}
+	}
+
+	if len(opts.Overlays) > 0 && len(sweep) > 0 {
+		pixel := ppiPixel(sweep, pxPerKm, xc, yc)
+		for _, l := range opts.Overlays {
+			l.Draw(canvas, pixel)
+		}
+	}
+	if opts.Legend != nil {
+		drawLegend(canvas, *opts.Legend, opts.ColorTable)
+	}
+	drawLabels(canvas, opts)
+	return canvas, nil
+}
+
+// polarIndex finds the gate of a sweep at a point, by looking up the radial
+// covering its angle and the gate at its range
+type polarIndex struct {
+	// bins are the radials covering each binsPerDegree of a degree of angle
+	// clockwise from east (as screenAzimuth), nil where there's none
+	bins          []*polarRadial
+	binsPerDegree float64
+	radials       []*polarRadial
+}
+
+// polarRadial is the scaled gates of a radial and their ranges in meters
+type polarRadial struct {
+	gates           []float32
+	first, interval float64
+	// start and bins are the bins of the index it covers
+	start, bins int
+}
+
+func newPolarIndex(sweep []*archive2.Message31, moment grid.MomentFunc) *polarIndex {
+	// the bins are as fine as the finest radials, so every radial has its
+	// own
+	spacing := 1.0
+	for _, radial := range sweep {
+		spacing = math.Min(spacing, radial.Header.AzimuthResolutionSpacing())
+	}
+	idx := &polarIndex{binsPerDegree: 1 / spacing}
+	idx.bins = make([]*polarRadial, int(360*idx.binsPerDegree))
+	for _, radial := range sweep {
+		m := moment(radial)
+		if m == nil {
+			continue
+		}
+		r := &polarRadial{
+			gates:    m.ScaledData(),
+			first:    float64(m.DataMomentRange),
+			interval: float64(m.DataMomentRangeSampleInterval),
+		}
+		idx.radials = append(idx.radials, r)
+		azimuth, width := screenAzimuth(radial)
+		r.start = int(math.Round(azimuth * idx.binsPerDegree))
+		r.bins = int(math.Round(width * idx.binsPerDegree))
+		for i := 0; i < r.bins; i++ {
+			idx.bins[(r.start+i)%len(idx.bins)] = r
+		}
+	}
+	return idx
+}
+
+// at returns the value of the gate x meters east and y meters south of the
+// radar, and false if there's none or it's below threshold. Gates are
+// centered on their ranges.
+func (idx *polarIndex) at(x, y float64) (float32, bool) {
+	angle := math.Atan2(y, x) * 180 / math.Pi
+	if angle < 0 {
+		angle += 360
+	}
+	r := idx.bins[int(angle*idx.binsPerDegree)%len(idx.bins)]
+	if r == nil {
+		return 0, false
+	}
+	gate := int(math.Floor((math.Hypot(x, y)-r.first)/r.interval + 0.5))
+	if gate < 0 || gate >= len(r.gates) || r.gates[gate] == archive2.MomentDataBelowThreshold {
+		return 0, false
+	}
+	return r.gates[gate], true
+}
+
+// smoothAt is at, interpolating bilinearly between the two gates of the two
+// radials around the point rather than taking the nearest gate, so images
+// aren't blocky when zoomed in. Points whose nearest gate is below threshold
+// are still left out, so echoes keep their outlines.
+func (idx *polarIndex) smoothAt(x, y float64) (float32, bool) {
+	v, ok := idx.at(x, y)
+	if !ok || v == archive2.MomentDataFolded {
+		return v, ok
+	}
+	angle := math.Atan2(y, x) * 180 / math.Pi
+	if angle < 0 {
+		angle += 360
+	}
+	r := idx.bins[int(angle*idx.binsPerDegree)%len(idx.bins)]
+	distance := math.Hypot(x, y)
+
+	// the radial on the other side of the point from r's center, weighted by
+	// how far the point is towards it
+	n := len(idx.bins)
+	offset := math.Mod(angle*idx.binsPerDegree-float64(r.start)-float64(r.bins)/2+1.5*float64(n), float64(n)) - float64(n)/2
+	neighbor := idx.bins[(r.start+r.bins)%n]
+	if offset < 0 {
+		neighbor = idx.bins[(r.start-1+n)%n]
+	}
+	w := math.Min(1, math.Abs(offset)/float64(r.bins))
+
+	sum, weight := grid.Interpolate(r.gates, (distance-r.first)/r.interval, 1-w)
+	if neighbor != nil && neighbor != r {
+		nsum, nweight := grid.Interpolate(neighbor.gates, (distance-neighbor.first)/neighbor.interval, w)
+		sum, weight = sum+nsum, weight+nweight
+	}
+	if weight == 0 {
+		return v, true
+	}
+	return float32(sum / weight), true
+}
+
+// release returns the scaled gates to the pool
+func (idx *polarIndex) release() {
+	for _, r := range idx.radials {
+		archive2.ReleaseScaled(r.gates)
+	}
+}
+
+// ppiDefaults checks the options of a PPI and fills in the defaults
+func (opts *Options) ppiDefaults() error {
+	if opts.Product == "" {
+		opts.Product = "ref"
+	}
+	if _, ok := ProductMoments[opts.Product]; !ok {
+		return fmt.Errorf("render: unsupported product %s", opts.Product)
+	}
+	if opts.Size == 0 {
+		opts.Size = 1024
+	}
+	if opts.Size < 0 {
+		return fmt.Errorf("render: invalid size %d", opts.Size)
+	}
+	if opts.ColorTable == nil {
+		c, err := Colors(opts.Product, "noaa")
+		if err != nil {
+			return err
+		}
+		opts.ColorTable = c
+	}
+	if opts.Radius == 0 {
+		opts.Radius = DefaultRadius
+	}
+	if opts.Background == nil {
+		opts.Background = color.Black
+	}
+	return nil
+}
+
+// momentFor returns the Moment of the options, or the product's moment from
+// the sweep if it isn't set
+func (opts *Options) momentFor(sweep []*archive2.Message31) grid.MomentFunc {
+	if opts.Moment != nil {
+		return opts.Moment
+	}
+	return MomentFor(opts.Product, sweep)
+}
+
+// ppiGeometry returns the scale of a PPI and the radar's position in it
+func (opts *Options) ppiGeometry() (pxPerKm, xc, yc float64) {
+	size := float64(opts.Size)
+	pxPerKm = size / 2 / (opts.Radius / 1000)
+	return pxPerKm, size/2 - opts.East/1000*pxPerKm, size/2 + opts.North/1000*pxPerKm
+}
+
+// screenAzimuth returns the start of the radial in degrees clockwise from
+// east, as angles are in images, and its width. Indexed radials are drawn at
+// their indexed azimuth, so sweeps from successive volumes line up.
+func screenAzimuth(radial *archive2.Message31) (azimuth, spacing float64) {
+	// round to the nearest rounded azimuth for the given resolution.
+	// ex: for radial 20.5432, round to 20.5
+	azimuthAngle := float64(radial.Header.IndexedAzimuth()) - 90
+	if azimuthAngle < 0 {
+		azimuthAngle = 360.0 + azimuthAngle
+	}
+	spacing = radial.Header.AzimuthResolutionSpacing()
+	azimuth = math.Floor(azimuthAngle)
+	if math.Floor(azimuthAngle+spacing) > azimuth {
+		azimuth += spacing
+	}
+	return azimuth, spacing
+}
+
+// ppiPixel places overlays in a PPI by ground range, which the slant range of
+// the gates is within a few hundred meters of
+func ppiPixel(sweep []*archive2.Message31, pxPerKm, xc, yc float64) overlay.PixelFunc {
+	lat, lon, _, _ := sites.Locate(sweep[0])
+	return func(plat, plon float64) (float64, float64) {
+		bearing, distance := geo.BearingDistance(lat, lon, plat, plon)
+		bearing *= math.Pi / 180
+		return xc + math.Sin(bearing)*distance/1000*pxPerKm, yc - math.Cos(bearing)*distance/1000*pxPerKm
+	}
+}
+
+// ParseColor parses a color by name: "transparent", an SVG color name such as
+// "black" or "navy", or hex #rrggbb or #rrggbbaa
+func ParseColor(s string) (color.Color, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if name == "transparent" {
+		return color.Transparent, nil
+	}
+	if c, ok := colornames.Map[name]; ok {
+		return c, nil
+	}
+	if strings.HasPrefix(name, "#") && (len(name) == 7 || len(name) == 9) {
+		v, err := strconv.ParseUint(name[1:], 16, 32)
+		if err == nil {
+			if len(name) == 7 {
+				v = v<<8 | 0xff
+			}
+			return color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+		}
+	}
+	return nil, fmt.Errorf("render: invalid color %q, expected transparent, a color name or #rrggbb[aa]", s)
+}
+
+// Grid renders the gridded product as an image with one pixel per cell, for
+// images in a geographic projection rather than PPI's radar centered one.
+// Product, ColorTable, Background, Overlays, Legend, and the labels and their
+// styles are used from opts.
+func Grid(g *grid.Grid, opts Options) (image.Image, error) {
+	if opts.Product == "" {
+		opts.Product = "ref"
+	}
+	if opts.ColorTable == nil {
+		c, err := Colors(opts.Product, "noaa")
+		if err != nil {
+			return nil, err
+		}
+		opts.ColorTable = c
+	}
+	if opts.Background == nil {
+		opts.Background = color.Black
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, g.Width, g.Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(opts.Background), image.ZP, draw.Src)
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			v := g.At(x, y)
+			if math.IsNaN(float64(v)) {
+				continue
+			}
+			setPixel(img, x, y, opts.ColorTable(v))
+		}
+	}
+	for _, l := range opts.Overlays {
+		l.Draw(img, g.Pixel)
+	}
+	if opts.Legend != nil {
+		drawLegend(img, *opts.Legend, opts.ColorTable)
+	}
+	drawLabels(img, opts)
+	return img, nil
+}
+
+// setPixel draws the color at x, y, blending translucent colors over the
+// background
+func setPixel(img *image.RGBA, x, y int, c color.Color) {
+	switch _, _, _, a := c.RGBA(); a {
+	case 0:
+	case 0xffff:
+		img.Set(x, y, c)
+	default:
+		draw.Draw(img, image.Rect(x, y, x+1, y+1), image.NewUniform(c), image.ZP, draw.Over)
+	}
+}
+
+// setPixelRGBA is setPixel without allocating for opaque colors
+func setPixelRGBA(img *image.RGBA, x, y int, c color.RGBA) {
+	switch c.A {
+	case 0:
+	case 0xff:
+		img.SetRGBA(x, y, c)
+	default:
+		setPixel(img, x, y, c)
+	}
+}
+
+// rgbaColor converts the color to RGBA without boxing the result in a
+// color.Color, as color.RGBAModel does
+func rgbaColor(c color.Color) color.RGBA {
+	r, g, b, a := c.RGBA()
+	return color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+}
@@ -0,0 +1,238 @@
+package render
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/grid"
+	"github.com/kallsyms/go-nexrad/internal/testvol"
+	"github.com/kallsyms/go-nexrad/overlay"
+)
+
+// testSweep has reflectivity within 100 km of the radar except the south east
+// quadrant, which is below threshold
+func testSweep() []*archive2.Message31 {
+	return testvol.Filled(0.5, 50, testvol.SouthEast)
+}
+
+func TestPPI(t *testing.T) {
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	isRed := func(c color.Color) bool {
+		return color.RGBAModel.Convert(c) == red
+	}
+	img, err := PPI(testSweep(), Options{
+		Size:       200,
+		Radius:     200000,
+		ColorTable: func(float32) color.Color { return red },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 200 || b.Dy() != 200 {
+		t.Fatalf("unexpected bounds %v", b)
+	}
+	// north, 50 km from the radar
+	if c := img.At(100, 75); !isRed(c) {
+		t.Errorf("expected data north of the radar, got %v", c)
+	}
+	// south east, and beyond the last gate
+	if c := img.At(120, 120); isRed(c) {
+		t.Errorf("expected no data south east of the radar, got %v", c)
+	}
+	if c := img.At(100, 10); isRed(c) {
+		t.Errorf("expected no data past the last gate, got %v", c)
+	}
+
+	// every pixel over the gates is drawn, with no seams between radials
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 200; x++ {
+			dx, dy := float64(x)+0.5-100, float64(y)+0.5-100
+			// pixels are 2 km and the gates run from 0 to 100 km
+			if r := math.Hypot(dx, dy) * 2; r < 2 || r > 98 || (dx > -1 && dy > -1) {
+				continue
+			}
+			if c := img.At(x, y); !isRed(c) {
+				t.Fatalf("expected data at %d, %d, got %v", x, y, c)
+			}
+		}
+	}
+
+	if _, err := PPI(testSweep(), Options{Product: "xyz"}); err == nil {
+		t.Error("expected an error for an unknown product")
+	}
+}
+
+func TestColors(t *testing.T) {
+	for _, p := range append(append([]string{}, Products...), VolumeProducts...) {
+		if _, err := Colors(p, "noaa"); err != nil {
+			t.Errorf("%s has no default color scheme: %s", p, err)
+		}
+	}
+	if _, err := Colors("ref", "xyz"); err == nil {
+		t.Error("expected an error for an unknown color scheme")
+	}
+
+	// values past the ends of a ramp take the end colors
+	if zdrColor(-10) != zdrColor(-4) || zdrColor(20) != zdrColor(8) {
+		t.Error("expected out of range ZDR to take the end colors")
+	}
+	if kdpColor(0) == kdpColor(4) {
+		t.Error("expected KDP of 0 and 4 deg/km to differ")
+	}
+	if etColor(-100) != etColor(0) || etColor(30000) != etColor(21336) {
+		t.Error("expected out of range echo tops to take the end colors")
+	}
+}
+
+func TestBackground(t *testing.T) {
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	img, err := PPI(testSweep(), Options{
+		Size:       200,
+		Radius:     200000,
+		ColorTable: func(float32) color.Color { return red },
+		Background: color.Transparent,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// below threshold gates, and beyond the last gate
+	for _, p := range [][2]int{{120, 120}, {100, 10}} {
+		if _, _, _, a := img.At(p[0], p[1]).RGBA(); a != 0 {
+			t.Errorf("expected a transparent pixel at %v, got alpha %d", p, a)
+		}
+	}
+	if _, _, _, a := img.At(100, 75).RGBA(); a == 0 {
+		t.Error("expected an opaque pixel where there's data")
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want color.Color
+	}{
+		{"transparent", color.Transparent},
+		{"Black", color.RGBA{0, 0, 0, 0xff}},
+		{"#102030", color.NRGBA{0x10, 0x20, 0x30, 0xff}},
+		{"#10203080", color.NRGBA{0x10, 0x20, 0x30, 0x80}},
+	}
+	for _, tt := range tests {
+		got, err := ParseColor(tt.in)
+		if err != nil {
+			t.Errorf("%s: %s", tt.in, err)
+		} else if got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.in, tt.want, got)
+		}
+	}
+	for _, in := range []string{"", "#12345", "#gggggg", "blurple"} {
+		if _, err := ParseColor(in); err == nil {
+			t.Errorf("expected an error for %q", in)
+		}
+	}
+}
+
+func TestPPICenter(t *testing.T) {
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	// 50 km square centered 50 km east of the radar, so the radar is on the
+	// left edge
+	img, err := PPI(testSweep(), Options{
+		Size:       200,
+		Radius:     50000,
+		East:       50000,
+		ColorTable: func(float32) color.Color { return red },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// north east of the radar, within the data
+	if r, _, _, _ := img.At(50, 50).RGBA(); r < 0x8000 {
+		t.Errorf("expected data north east of the radar, got %v", img.At(50, 50))
+	}
+	// south east of the radar
+	if r, _, _, _ := img.At(50, 150).RGBA(); r > 0x8000 {
+		t.Errorf("expected no data south east of the radar, got %v", img.At(50, 150))
+	}
+}
+
+func TestGrid(t *testing.T) {
+	nan := float32(math.NaN())
+	g := &grid.Grid{Width: 2, Height: 2, Values: []float32{10, nan, 50, 0}}
+	img, err := Grid(g, Options{
+		ColorTable: func(v float32) color.Color {
+			if v == 0 {
+				return color.Transparent
+			}
+			return color.RGBA{0xff, 0, 0, 0xff}
+		},
+		Background: color.Transparent,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 2 || b.Dy() != 2 {
+		t.Fatalf("expected a pixel per cell, got %v", b)
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r != 0xffff {
+		t.Errorf("expected a red pixel with data, got %v", img.At(0, 0))
+	}
+	for _, p := range [][2]int{{1, 0}, {1, 1}} {
+		if _, _, _, a := img.At(p[0], p[1]).RGBA(); a != 0 {
+			t.Errorf("expected a transparent pixel at %v, got %v", p, img.At(p[0], p[1]))
+		}
+	}
+}
+
+func TestPPIOverlay(t *testing.T) {
+	sweep := testSweep()
+	for _, m31 := range sweep {
+		m31.VolumeData.Lat = 35
+		m31.VolumeData.Long = -97
+	}
+	// a line from the radar to 100 km east
+	l := &overlay.Layer{
+		Lines: [][][2]float64{{{-97, 35}, {-97 + 100/(111.2*math.Cos(35*math.Pi/180)), 35}}},
+		Color: color.RGBA{0, 0, 0xff, 0xff},
+		Width: 3,
+	}
+	img, err := PPI(sweep, Options{
+		Size:       200,
+		Radius:     200000,
+		ColorTable: func(float32) color.Color { return color.Transparent },
+		Overlays:   []*overlay.Layer{l},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 50 km east of the radar
+	if _, _, b, _ := img.At(125, 100).RGBA(); b < 0x8000 {
+		t.Errorf("expected the overlay east of the radar, got %v", img.At(125, 100))
+	}
+	if _, _, b, _ := img.At(100, 75).RGBA(); b != 0 {
+		t.Errorf("expected no overlay north of the radar, got %v", img.At(100, 75))
+	}
+}
+
+func TestPPISmooth(t *testing.T) {
+	// reflectivity rising 0.5 dBZ a gate
+	sweep := testSweep()
+	for _, r := range sweep {
+		for i := range r.ReflectivityData.Data {
+			r.ReflectivityData.Data[i] = byte(66 + i)
+		}
+	}
+	idx := newPolarIndex(sweep, MomentFor("ref", sweep))
+	defer idx.release()
+	// 39.8 gates north of the radar
+	if v, ok := idx.at(0, -40300); !ok || v != 20 {
+		t.Errorf("expected the nearest gate, 20 dBZ, got %v", v)
+	}
+	if v, ok := idx.smoothAt(0, -40300); !ok || math.Abs(float64(v)-19.9) > 1e-3 {
+		t.Errorf("expected 19.9 dBZ between the gates, got %v", v)
+	}
+	// across radials the values are the same
+	if v, ok := idx.smoothAt(1000, -40300); !ok || math.Abs(float64(v)-19.9) > 0.1 {
+		t.Errorf("expected about 19.9 dBZ between radials, got %v", v)
+	}
+}
@@ -0,0 +1,186 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+
+	"github.com/kallsyms/go-nexrad/derived"
+	"github.com/kallsyms/go-nexrad/geo"
+	"github.com/llgcode/draw2d/draw2dimg"
+	"golang.org/x/image/colornames"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/inconsolata"
+	"golang.org/x/image/math/fixed"
+)
+
+// the space around plots, such as cross-sections, for their axes' labels
+const (
+	plotLeft   = 48
+	plotBottom = 28
+	plotTop    = 24
+	plotRight  = 24
+)
+
+// SectionSize returns the width and height of the plot of a cross-section in
+// an image of the options' Size, with its Legend, to make sections with a cell
+// per pixel
+func SectionSize(opts Options) (width, height int) {
+	if opts.Size == 0 {
+		opts.Size = 1024
+	}
+	return opts.Size - plotLeft - plotRight - plotLegendWidth(opts), opts.Size/2 - plotTop - plotBottom
+}
+
+func plotLegendWidth(opts Options) int {
+	if opts.Legend == nil {
+		return 0
+	}
+	return legendWidth + 2*legendMargin
+}
+
+// Section renders the cross-section as an image of the product's values by
+// distance along the section (across) and height above sea level (up), Size
+// pixels wide and half as high, with axes in km. The section is scaled to
+// fill the plot; see SectionSize. The top and bottom edges of the section's
+// Beams are outlined over the values. Product, Size, ColorTable, Background,
+// Legend, and the labels and their styles are used from opts.
+func Section(s *derived.Section, opts Options) (image.Image, error) {
+	if opts.Product == "" {
+		opts.Product = "ref"
+	}
+	if opts.Size == 0 {
+		opts.Size = 1024
+	}
+	if opts.ColorTable == nil {
+		c, err := Colors(opts.Product, "noaa")
+		if err != nil {
+			return nil, err
+		}
+		opts.ColorTable = c
+	}
+	if opts.Background == nil {
+		opts.Background = color.Black
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, opts.Size, opts.Size/2))
+	draw.Draw(img, img.Bounds(), image.NewUniform(opts.Background), image.ZP, draw.Src)
+	w, h := SectionSize(opts)
+	plot := image.Rect(plotLeft, plotTop, plotLeft+w, plotTop+h)
+	if w <= 0 || h <= 0 {
+		return img, nil
+	}
+	if s.Width > 0 && s.Height > 0 {
+		for y := 0; y < h; y++ {
+			row := y * s.Height / h
+			for x := 0; x < w; x++ {
+				v := s.At(x*s.Width/w, row)
+				if math.IsNaN(float64(v)) {
+					continue
+				}
+				setPixel(img, plot.Min.X+x, plot.Min.Y+y, opts.ColorTable(v))
+			}
+		}
+	}
+	if len(s.Beams) > 0 && s.Length > 0 && s.Top > 0 {
+		drawBeams(img, plot, s)
+	}
+	drawSectionAxes(img, plot, s.Length/1000, s.Top/1000)
+	if opts.Legend != nil {
+		drawLegend(img, *opts.Legend, opts.ColorTable)
+	}
+	drawLabels(img, opts)
+	return img, nil
+}
+
+// drawBeams outlines the top and bottom edges of the section's beams on the
+// plot, by ground range from the start of the section and height above sea
+// level. They're drawn on a layer the size of the plot so they're clipped to
+// it.
+func drawBeams(img *image.RGBA, plot image.Rectangle, s *derived.Section) {
+	layer := image.NewRGBA(image.Rect(0, 0, plot.Dx(), plot.Dy()))
+	at := func(p geo.BeamPoint, height float64) (float64, float64) {
+		return p.GroundRange / s.Length * float64(plot.Dx()-1),
+			float64(plot.Dy()-1) - height/s.Top*float64(plot.Dy()-1)
+	}
+	gc := draw2dimg.NewGraphicContext(layer)
+	gc.SetStrokeColor(colornames.White)
+	gc.SetLineWidth(1)
+	for _, beam := range s.Beams {
+		for _, edge := range []func(p geo.BeamPoint) float64{
+			func(p geo.BeamPoint) float64 { return p.Bottom },
+			func(p geo.BeamPoint) float64 { return p.Top },
+		} {
+			for i, p := range beam {
+				x, y := at(p, edge(p))
+				if i == 0 {
+					gc.MoveTo(x, y)
+				} else {
+					gc.LineTo(x, y)
+				}
+			}
+		}
+	}
+	gc.Stroke()
+	draw.Draw(img, plot, layer, image.ZP, draw.Over)
+}
+
+// drawSectionAxes draws the distance and height axes along the bottom and
+// left of the plot, with ticks at round numbers of km
+func drawSectionAxes(img *image.RGBA, plot image.Rectangle, length, top float64) {
+	drawHeightAxis(img, plot, top)
+	d := axisDrawer(img)
+	if length > 0 {
+		step, decimals := axisTicks(length, 8)
+		for v := 0.0; v <= length; v += step {
+			x := plot.Min.X + int(math.Round(v/length*float64(plot.Dx()-1)))
+			drawAxisTick(img, x, plot.Max.Y, x+1, plot.Max.Y+5)
+			// centered on the tick
+			label := strconv.FormatFloat(v, 'f', decimals, 64)
+			drawAxisText(d, x-d.MeasureString(label).Ceil()/2, plot.Max.Y+20, label)
+		}
+	}
+	drawAxisText(d, plot.Max.X+4, plot.Max.Y+20, "km")
+}
+
+// drawHeightAxis draws the axes along the bottom and left of the plot, with
+// ticks of the height in km up the left from 0 to top
+func drawHeightAxis(img *image.RGBA, plot image.Rectangle, top float64) {
+	drawAxisTick(img, plot.Min.X-1, plot.Min.Y, plot.Min.X, plot.Max.Y+1)
+	drawAxisTick(img, plot.Min.X-1, plot.Max.Y, plot.Max.X, plot.Max.Y+1)
+	d := axisDrawer(img)
+	if top > 0 {
+		step, decimals := axisTicks(top, 5)
+		for v := 0.0; v <= top; v += step {
+			y := plot.Max.Y - int(math.Round(v/top*float64(plot.Dy()-1)))
+			drawAxisTick(img, plot.Min.X-5, y, plot.Min.X, y+1)
+			// right aligned to the tick
+			label := strconv.FormatFloat(v, 'f', decimals, 64)
+			drawAxisText(d, plot.Min.X-8-d.MeasureString(label).Ceil(), y+5, label)
+		}
+	}
+	drawAxisText(d, plot.Min.X-8-d.MeasureString("km").Ceil(), plot.Min.Y-8, "km")
+}
+
+// axisTicks returns the round step between about n ticks from 0 to max, and
+// the decimals to label them with
+func axisTicks(max float64, n int) (float64, int) {
+	step := tickStep(max / float64(n))
+	return step, int(math.Max(0, -math.Floor(math.Log10(step))))
+}
+
+func axisDrawer(img *image.RGBA) *font.Drawer {
+	return &font.Drawer{Dst: img, Src: image.NewUniform(color.White), Face: inconsolata.Regular8x16}
+}
+
+func drawAxisText(d *font.Drawer, x, y int, s string) {
+	d.Dot = fixed.P(x, y)
+	d.DrawString(s)
+}
+
+// drawAxisTick fills the rectangle in the color of axes and ticks
+func drawAxisTick(img *image.RGBA, x0, y0, x1, y1 int) {
+	draw.Draw(img, image.Rect(x0, y0, x1, y1), image.NewUniform(colornames.Gray), image.ZP, draw.Src)
+}
@@ -0,0 +1,68 @@
+package render
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/kallsyms/go-nexrad/derived"
+	"github.com/kallsyms/go-nexrad/geo"
+)
+
+func TestSection(t *testing.T) {
+	// the bottom half of the section has data
+	s := &derived.Section{Length: 100000, Top: 10000, Width: 10, Height: 10, Values: make([]float32, 100)}
+	for i := range s.Values[:50] {
+		s.Values[i] = float32(math.NaN())
+	}
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	img, err := Section(s, Options{Size: 400, ColorTable: func(float32) color.Color { return red }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 400 || b.Dy() != 200 {
+		t.Fatalf("expected a 400x200 image, got %v", b)
+	}
+	w, h := SectionSize(Options{Size: 400})
+	if img.At(plotLeft+w/2, plotTop+h*3/4) != red {
+		t.Errorf("expected data in the bottom of the plot, got %v", img.At(plotLeft+w/2, plotTop+h*3/4))
+	}
+	if r, _, _, _ := img.At(plotLeft+w/2, plotTop+h/4).RGBA(); r != 0 {
+		t.Errorf("expected no data in the top of the plot, got %v", img.At(plotLeft+w/2, plotTop+h/4))
+	}
+}
+
+func TestSectionBeams(t *testing.T) {
+	// a beam rising from the bottom left corner to 5 km up at the end, and on
+	// out of the top of the plot
+	s := &derived.Section{Length: 100000, Top: 10000, Width: 10, Height: 10, Values: make([]float32, 100)}
+	for i := range s.Values {
+		s.Values[i] = float32(math.NaN())
+	}
+	var beam []geo.BeamPoint
+	for r := 0.0; r <= 300000; r += 1000 {
+		beam = append(beam, geo.BeamPoint{GroundRange: r, Bottom: r / 20, Top: r/20 + 500})
+	}
+	s.Beams = [][]geo.BeamPoint{beam}
+	img, err := Section(s, Options{Size: 400})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, h := SectionSize(Options{Size: 400})
+	white := func(x, y int) bool {
+		r, g, b, _ := img.At(x, y).RGBA()
+		return r > 0x8000 && g > 0x8000 && b > 0x8000
+	}
+	// the bottom edge half way along
+	x := plotLeft + w/2
+	y := plotTop + h - 1 - int(math.Round(2500.0/10000*float64(h-1)))
+	if !white(x, y) && !white(x, y-1) && !white(x, y+1) {
+		t.Errorf("expected the bottom of the beam at %d,%d", x, y)
+	}
+	// the beam leaves the top of the plot, and isn't drawn over the margin
+	for x := plotLeft; x < plotLeft+w; x++ {
+		if white(x, plotTop-2) {
+			t.Fatalf("beam drawn outside the plot at %d,%d", x, plotTop-2)
+		}
+	}
+}
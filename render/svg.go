@@ -0,0 +1,212 @@
+package render
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/overlay"
+)
+
+// SVG writes the product from the sweep's radials as an SVG image, the vector
+// equivalent of PPI, for figures that scale to any resolution. Gates are
+// filled annular sectors, with runs of gates of the same color along a radial
+// merged into one, and are grouped by color to keep the file small. Options
+// are as for PPI; Size is the width and height of the image in pixels at 1:1.
+func SVG(w io.Writer, sweep []*archive2.Message31, opts Options) error {
+	if err := opts.ppiDefaults(); err != nil {
+		return err
+	}
+	pxPerKm, xc, yc := opts.ppiGeometry()
+	moment := opts.momentFor(sweep)
+
+	// the path data of the gates of each color, in the order the colors are
+	// first seen
+	var fills []color.NRGBA
+	paths := map[color.NRGBA]*bytes.Buffer{}
+	sector := func(c color.NRGBA, r0, r1, a0, a1 float64) {
+		d := paths[c]
+		if d == nil {
+			d = &bytes.Buffer{}
+			paths[c], fills = d, append(fills, c)
+		}
+		point := func(r, a float64) string {
+			return coord(xc+r*math.Cos(a)) + " " + coord(yc+r*math.Sin(a))
+		}
+		fmt.Fprintf(d, "M%sL%sA%s %s 0 0 1 %sL%s", point(r0, a0), point(r1, a0), coord(r1), coord(r1), point(r1, a1), point(r0, a1))
+		if r0 > 0 {
+			fmt.Fprintf(d, "A%s %s 0 0 0 %s", coord(r0), coord(r0), point(r0, a0))
+		}
+		d.WriteString("Z")
+	}
+
+	for _, radial := range sweep {
+		m := moment(radial)
+		if m == nil {
+			continue
+		}
+		firstGatePx := float64(m.DataMomentRange) / 1000 * pxPerKm
+		gateWidthPx := float64(m.DataMomentRangeSampleInterval) / 1000 * pxPerKm
+		azimuth, spacing := screenAzimuth(radial)
+		// radials overlap slightly so antialiasing doesn't leave seams
+		// between them
+		a0 := azimuth * math.Pi / 180
+		a1 := (azimuth+spacing)*math.Pi/180 + .002
+
+		gates := m.ScaledData()
+		// the run of gates of the same color being merged, from start
+		run, start := color.NRGBA{}, -1
+		end := func(i int) {
+			if start >= 0 {
+				sector(run, firstGatePx+float64(start)*gateWidthPx, firstGatePx+float64(i)*gateWidthPx, a0, a1)
+			}
+			start = -1
+		}
+		for i, v := range gates {
+			var c color.NRGBA
+			if v != archive2.MomentDataBelowThreshold {
+				c = color.NRGBAModel.Convert(opts.ColorTable(v)).(color.NRGBA)
+			}
+			if c.A == 0 {
+				end(i)
+				continue
+			}
+			if start >= 0 && c == run {
+				continue
+			}
+			end(i)
+			run, start = c, i
+		}
+		end(len(gates))
+		archive2.ReleaseScaled(gates)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", opts.Size, opts.Size, opts.Size, opts.Size)
+	if bg := color.NRGBAModel.Convert(opts.Background).(color.NRGBA); bg.A != 0 {
+		fmt.Fprintf(bw, "<rect width=\"100%%\" height=\"100%%\" fill=%s/>\n", svgColor("fill", bg))
+	}
+	bw.WriteString("<g id=\"gates\">\n")
+	for _, c := range fills {
+		fmt.Fprintf(bw, "<path fill=%s d=\"%s\"/>\n", svgColor("fill", c), paths[c].Bytes())
+	}
+	bw.WriteString("</g>\n")
+
+	if len(opts.Overlays) > 0 && len(sweep) > 0 {
+		pixel := ppiPixel(sweep, pxPerKm, xc, yc)
+		for _, l := range opts.Overlays {
+			writeSVGLayer(bw, l, pixel)
+		}
+	}
+	if opts.Legend != nil {
+		writeSVGLegend(bw, opts.Size, *opts.Legend, opts.ColorTable)
+	}
+	writeSVGLabels(bw, opts)
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+// writeSVGLayer writes the overlay's lines as a path, and its labels, placing
+// points with pixel
+func writeSVGLayer(w io.Writer, l *overlay.Layer, pixel overlay.PixelFunc) {
+	c := color.NRGBAModel.Convert(l.Color).(color.NRGBA)
+	var d bytes.Buffer
+	for _, line := range l.Lines {
+		started := false
+		for _, p := range line {
+			x, y := pixel(p[1], p[0])
+			if math.IsNaN(x) || math.IsNaN(y) {
+				started = false
+				continue
+			}
+			if started {
+				d.WriteString("L")
+			} else {
+				d.WriteString("M")
+				started = true
+			}
+			d.WriteString(coord(x) + " " + coord(y))
+		}
+	}
+	if d.Len() > 0 {
+		fmt.Fprintf(w, "<path fill=\"none\" stroke=%s stroke-width=\"%s\" stroke-linecap=\"round\" stroke-linejoin=\"round\" d=\"%s\"/>\n", svgColor("stroke", c), coord(l.Width), d.Bytes())
+	}
+	for _, label := range l.Labels {
+		x, y := pixel(label.Lat, label.Lon)
+		if math.IsNaN(x) || math.IsNaN(y) {
+			continue
+		}
+		anchor := "middle"
+		if label.Marker {
+			fmt.Fprintf(w, "<circle cx=\"%s\" cy=\"%s\" r=\"3\" fill=%s/>\n", coord(x), coord(y), svgColor("fill", c))
+			x += 6
+			anchor = "start"
+		} else {
+			y -= 3
+		}
+		fmt.Fprintf(w, "<text x=\"%s\" y=\"%s\" text-anchor=\"%s\" fill=%s font-family=\"monospace\" font-size=\"14\">%s</text>\n", coord(x), coord(y), anchor, svgColor("fill", c), escape(label.Text))
+	}
+}
+
+// writeSVGLegend writes the legend drawLegend draws, as rectangles of each
+// color on the bar
+func writeSVGLegend(w io.Writer, size int, s Scale, colors ColorTable) {
+	l, ok := layoutLegend(image.Rect(0, 0, size, size), s)
+	if !ok {
+		return
+	}
+	b := l.backdrop
+	fmt.Fprintf(w, "<g id=\"legend\">\n<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=%s/>\n", b.Min.X, b.Min.Y, b.Dx(), b.Dy(), svgColor("fill", legendBackdrop))
+	// rows of the same color are merged
+	for y := l.y0; y < l.y1; {
+		c := color.NRGBAModel.Convert(colors(l.valueAt(s, y))).(color.NRGBA)
+		n := 1
+		for y+n < l.y1 && color.NRGBAModel.Convert(colors(l.valueAt(s, y+n))) == c {
+			n++
+		}
+		if c.A != 0 {
+			fmt.Fprintf(w, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=%s/>\n", l.x0, y, legendBarWidth, n, svgColor("fill", c))
+		}
+		y += n
+	}
+	text := func(x, y int, s string) {
+		fmt.Fprintf(w, "<text x=\"%d\" y=\"%d\" fill=\"#ffffff\" font-family=\"monospace\" font-size=\"14\">%s</text>\n", x, y, escape(s))
+	}
+	if s.Units != "" {
+		text(l.x0, l.y0-8, s.Units)
+	}
+	for _, t := range l.ticks {
+		fmt.Fprintf(w, "<rect x=\"%d\" y=\"%d\" width=\"4\" height=\"1\" fill=\"#ffffff\"/>\n", l.x0+legendBarWidth, t.y)
+		text(l.x0+legendBarWidth+6, t.y+5, t.text)
+	}
+	io.WriteString(w, "</g>\n")
+}
+
+// svgColor returns the quoted color as an SVG attribute value, followed by
+// its opacity attribute for the property (fill or stroke) if it's translucent
+func svgColor(property string, c color.NRGBA) string {
+	s := fmt.Sprintf("\"#%02x%02x%02x\"", c.R, c.G, c.B)
+	if c.A != 0xff {
+		s += fmt.Sprintf(" %s-opacity=\"%s\"", property, strconv.FormatFloat(float64(c.A)/0xff, 'f', 3, 64))
+	}
+	return s
+}
+
+// coord formats a coordinate to a tenth of a pixel, enough for any zoom the
+// figure is likely to be viewed at
+func coord(v float64) string {
+	return strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64)
+}
+
+func escape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,50 @@
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestSVG(t *testing.T) {
+	var buf bytes.Buffer
+	err := SVG(&buf, testSweep(), Options{
+		Size:       200,
+		Radius:     200000,
+		ColorTable: func(float32) color.Color { return color.RGBA{0xff, 0, 0, 0xff} },
+		Label:      "KCRP <test>",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var svg struct {
+		Width string `xml:"width,attr"`
+		Rect  struct {
+			Fill string `xml:"fill,attr"`
+		} `xml:"rect"`
+		Paths []struct {
+			Fill string `xml:"fill,attr"`
+			D    string `xml:"d,attr"`
+		} `xml:"g>path"`
+		Text string `xml:"text"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &svg); err != nil {
+		t.Fatalf("invalid svg: %s", err)
+	}
+	if svg.Width != "200" || svg.Rect.Fill != "#000000" {
+		t.Errorf("unexpected size %s or background %s", svg.Width, svg.Rect.Fill)
+	}
+	if len(svg.Paths) != 1 || svg.Paths[0].Fill != "#ff0000" {
+		t.Fatalf("expected one red path, got %+v", svg.Paths)
+	}
+	// the gates of each radial are one run, and the south east quadrant is
+	// below threshold
+	if n := strings.Count(svg.Paths[0].D, "M"); n != 270 {
+		t.Errorf("expected 270 sectors, got %d", n)
+	}
+	if svg.Text != "KCRP <test>" {
+		t.Errorf("unexpected label %q", svg.Text)
+	}
+}
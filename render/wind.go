@@ -0,0 +1,230 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+
+	"github.com/kallsyms/go-nexrad/derived"
+	"github.com/llgcode/draw2d"
+	"github.com/llgcode/draw2d/draw2dimg"
+	"golang.org/x/image/colornames"
+)
+
+// knots is the number of knots in a m/s
+const knots = 1.943844
+
+// hodographBands color the hodograph by height above sea level in meters
+var hodographBands = []struct {
+	top   float64
+	label string
+	color color.Color
+}{
+	{3000, "0-3 km", colornames.Red},
+	{6000, "3-6 km", colornames.Lime},
+	{9000, "6-9 km", colornames.Yellow},
+	{math.Inf(1), "9+ km", colornames.Cyan},
+}
+
+// WindBarbs renders the wind profiles as a time-height chart of wind barbs in
+// knots, a column for each profile in order across and height above sea level
+// up, Size pixels wide and half as high. Barbs are colored by the RMS error of
+// their fit, with the vwp color scheme by default. Size, ColorTable,
+// Background, Legend, and the labels and their styles are used from opts.
+func WindBarbs(profiles []*derived.WindProfile, opts Options) (image.Image, error) {
+	opts.Product = "vwp"
+	img, err := windImage(&opts, 2)
+	if err != nil {
+		return nil, err
+	}
+	w, h := SectionSize(opts)
+	plot := image.Rect(plotLeft, plotTop, plotLeft+w, plotTop+h)
+	if w <= 0 || h <= 0 {
+		return img, nil
+	}
+
+	// the chart reaches a level above the highest wind, and at least 3 km
+	top := 3000.0
+	for _, p := range profiles {
+		if n := len(p.Levels); n > 0 {
+			top = math.Max(top, p.Levels[n-1].Height+derived.VADLevel)
+		}
+	}
+	drawHeightAxis(img, plot, top/1000)
+
+	d := axisDrawer(img)
+	column := float64(w) / float64(len(profiles))
+	length := math.Min(column*0.8, math.Max(16, float64(opts.Size)/32))
+	// label every few columns so the times don't overlap
+	every := int(math.Ceil(56 / column))
+	gc := draw2dimg.NewGraphicContext(img)
+	for i, p := range profiles {
+		x := float64(plot.Min.X) + column*(float64(i)+0.5)
+		if i%every == 0 {
+			label := p.Time.UTC().Format("15:04")
+			drawAxisTick(img, int(x), plot.Max.Y, int(x)+1, plot.Max.Y+5)
+			drawAxisText(d, int(x)-d.MeasureString(label).Ceil()/2, plot.Max.Y+20, label)
+		}
+		// levels closer than half a barb to the last are left out, so barbs
+		// don't overlap
+		last := math.Inf(1)
+		for _, l := range p.Levels {
+			y := float64(plot.Max.Y) - l.Height/top*float64(h-1)
+			if last-y < length/2 {
+				continue
+			}
+			last = y
+			drawBarb(gc, x, y, l.Direction(), l.Speed()*knots, length, opts.ColorTable(float32(l.RMS)))
+		}
+	}
+	drawAxisText(d, plot.Max.X+4, plot.Max.Y+20, "UTC")
+	if opts.Legend != nil {
+		drawLegend(img, *opts.Legend, opts.ColorTable)
+	}
+	drawLabels(img, opts)
+	return img, nil
+}
+
+// Hodograph renders the wind profile as a hodograph, the line through the
+// tips of its winds' vectors from the lowest up, colored by height, on rings
+// of speed in knots. The image is Size pixels square. Size, Background, and
+// the labels and their styles are used from opts.
+func Hodograph(p *derived.WindProfile, opts Options) (image.Image, error) {
+	opts.Product = "vwp"
+	img, err := windImage(&opts, 1)
+	if err != nil {
+		return nil, err
+	}
+	size := float64(opts.Size)
+	xc, yc := size/2, size/2
+	radius := size/2 - 40
+
+	// rings reach the fastest wind
+	max := 20.0
+	for _, l := range p.Levels {
+		max = math.Max(max, l.Speed()*knots)
+	}
+	step := tickStep(max / 4)
+	max = math.Ceil(max/step) * step
+	pxPerKt := radius / max
+
+	gc := draw2dimg.NewGraphicContext(img)
+	gc.SetStrokeColor(colornames.Gray)
+	gc.SetLineWidth(1)
+	gc.MoveTo(xc-radius, yc)
+	gc.LineTo(xc+radius, yc)
+	gc.MoveTo(xc, yc-radius)
+	gc.LineTo(xc, yc+radius)
+	for r := step; r <= max; r += step {
+		gc.MoveTo(xc+r*pxPerKt, yc)
+		gc.ArcTo(xc, yc, r*pxPerKt, r*pxPerKt, 0, 2*math.Pi)
+	}
+	gc.Stroke()
+	d := axisDrawer(img)
+	for r := step; r <= max; r += step {
+		drawAxisText(d, int(xc+r*pxPerKt*math.Sqrt2/2)+2, int(yc+r*pxPerKt*math.Sqrt2/2)+14, strconv.FormatFloat(r, 'f', -1, 64))
+	}
+	drawAxisText(d, int(xc+radius)-d.MeasureString("kt").Ceil(), int(yc)-4, "kt")
+
+	gc.SetLineWidth(3)
+	gc.SetLineCap(draw2d.RoundCap)
+	for i := 1; i < len(p.Levels); i++ {
+		a, b := p.Levels[i-1], p.Levels[i]
+		gc.SetStrokeColor(hodographColor(b.Height))
+		gc.MoveTo(xc+a.U*knots*pxPerKt, yc-a.V*knots*pxPerKt)
+		gc.LineTo(xc+b.U*knots*pxPerKt, yc-b.V*knots*pxPerKt)
+		gc.Stroke()
+	}
+
+	// the key of the heights' colors
+	for i, b := range hodographBands {
+		y := 24 + i*18
+		draw.Draw(img, image.Rect(12, y-10, 24, y-2), image.NewUniform(b.color), image.ZP, draw.Src)
+		drawAxisText(d, 30, y, b.label)
+	}
+	drawLabels(img, opts)
+	return img, nil
+}
+
+// hodographColor returns the color of the hodograph at the height
+func hodographColor(height float64) color.Color {
+	for _, b := range hodographBands {
+		if height < b.top {
+			return b.color
+		}
+	}
+	return hodographBands[len(hodographBands)-1].color
+}
+
+// windImage sets the defaults of the options of a wind plot and returns its
+// background, Size wide and Size/aspect high
+func windImage(opts *Options, aspect int) (*image.RGBA, error) {
+	if opts.Size == 0 {
+		opts.Size = 1024
+	}
+	if opts.ColorTable == nil {
+		c, err := Colors(opts.Product, "noaa")
+		if err != nil {
+			return nil, err
+		}
+		opts.ColorTable = c
+	}
+	if opts.Background == nil {
+		opts.Background = color.Black
+	}
+	img := image.NewRGBA(image.Rect(0, 0, opts.Size, opts.Size/aspect))
+	draw.Draw(img, img.Bounds(), image.NewUniform(opts.Background), image.ZP, draw.Src)
+	return img, nil
+}
+
+// drawBarb draws a wind barb at x, y of the wind from the direction in
+// degrees at the speed in knots: a staff length pixels long pointing into the
+// wind, with a pennant for each 50 knots, a barb for each 10 and a half barb
+// for 5 at its end. Calm winds are drawn as a circle.
+func drawBarb(gc *draw2dimg.GraphicContext, x, y, direction, speed, length float64, c color.Color) {
+	gc.SetStrokeColor(c)
+	gc.SetFillColor(c)
+	gc.SetLineWidth(1.5)
+	kt := int(math.Round(speed/5)) * 5
+	if kt == 0 {
+		gc.MoveTo(x+length/6, y)
+		gc.ArcTo(x, y, length/6, length/6, 0, 2*math.Pi)
+		gc.Stroke()
+		return
+	}
+
+	// along the staff from the station and across it, to the clockwise side
+	sx, sy := math.Sin(direction*math.Pi/180), -math.Cos(direction*math.Pi/180)
+	px, py := -sy, sx
+	feather, gap := length*0.4, length*0.15
+	gc.MoveTo(x, y)
+	gc.LineTo(x+sx*length, y+sy*length)
+	gc.Stroke()
+
+	t := length
+	for ; kt >= 50; kt -= 50 {
+		gc.MoveTo(x+sx*t, y+sy*t)
+		gc.LineTo(x+sx*(t-gap)+px*feather, y+sy*(t-gap)+py*feather)
+		gc.LineTo(x+sx*(t-2*gap), y+sy*(t-2*gap))
+		gc.Close()
+		gc.Fill()
+		t -= 2.5 * gap
+	}
+	for ; kt >= 10; kt -= 10 {
+		gc.MoveTo(x+sx*t, y+sy*t)
+		gc.LineTo(x+sx*(t+gap)+px*feather, y+sy*(t+gap)+py*feather)
+		gc.Stroke()
+		t -= gap
+	}
+	if kt == 5 {
+		// a lone half barb is set in from the end of the staff
+		if t == length {
+			t -= gap
+		}
+		gc.MoveTo(x+sx*t, y+sy*t)
+		gc.LineTo(x+sx*(t+gap/2)+px*feather/2, y+sy*(t+gap/2)+py*feather/2)
+		gc.Stroke()
+	}
+}
@@ -0,0 +1,60 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/kallsyms/go-nexrad/derived"
+	"golang.org/x/image/colornames"
+)
+
+// contains reports whether any pixel of the image is c
+func contains(img image.Image, c color.Color) bool {
+	r0, g0, b0, a0 := c.RGBA()
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if r, g, b, a := img.At(x, y).RGBA(); r == r0 && g == g0 && b == b0 && a == a0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestWindPlots(t *testing.T) {
+	// 30 kt from the southwest veering to 50 kt from the west
+	p := &derived.WindProfile{Time: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	for h := 500.0; h < 5000; h += derived.VADLevel {
+		f := h / 5000
+		p.Levels = append(p.Levels, derived.WindLevel{Height: h, U: (11 + 15*f), V: 11 * (1 - f), RMS: 0.5})
+	}
+
+	blue := color.RGBA{0, 0, 0xff, 0xff}
+	img, err := WindBarbs([]*derived.WindProfile{p, p}, Options{Size: 400, ColorTable: func(float32) color.Color { return blue }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 400 || b.Dy() != 200 {
+		t.Fatalf("expected a 400x200 image, got %v", b)
+	}
+	if !contains(img, blue) {
+		t.Error("expected wind barbs in the chart")
+	}
+
+	img, err = Hodograph(p, Options{Size: 400})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 400 || b.Dy() != 400 {
+		t.Fatalf("expected a 400x400 image, got %v", b)
+	}
+	// the line through the winds crosses 3 km
+	for _, c := range []color.Color{colornames.Red, colornames.Lime} {
+		if !contains(img.(*image.RGBA).SubImage(image.Rect(100, 100, 400, 400)), c) {
+			t.Errorf("expected the hodograph in %v", c)
+		}
+	}
+}
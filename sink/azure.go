@@ -0,0 +1,114 @@
+package sink
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Azure writes blobs to an Azure Blob Storage container, under a prefix,
+// authorizing with a shared access signature.
+type Azure struct {
+	Account   string
+	Container string
+	Prefix    string
+	// Endpoint overrides https://ACCOUNT.blob.core.windows.net, e.g. for
+	// Azurite
+	Endpoint string
+	// SAS is the shared access signature query string, without the leading ?
+	SAS    string
+	Client *http.Client
+}
+
+// NewAzure returns a sink writing under an azure://account/container/prefix
+// URL. The shared access signature is read from the AZURE_STORAGE_SAS_TOKEN
+// environment variable, and must allow creating and writing blobs, and reading
+// them to Get them.
+func NewAzure(rawurl string) (*Azure, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.SplitN(strings.Trim(u.Path, "/"), "/", 2)
+	if u.Scheme != "azure" || u.Host == "" || parts[0] == "" {
+		return nil, fmt.Errorf("sink: invalid azure url %q", rawurl)
+	}
+	s := &Azure{
+		Account:   u.Host,
+		Container: parts[0],
+		Endpoint:  "https://" + u.Host + ".blob.core.windows.net",
+		SAS:       strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?"),
+		Client:    http.DefaultClient,
+	}
+	if len(parts) > 1 {
+		s.Prefix = parts[1]
+	}
+	if s.SAS == "" {
+		return nil, errors.New("sink: AZURE_STORAGE_SAS_TOKEN must be set to write to azure")
+	}
+	return s, nil
+}
+
+// Put uploads the data as the block blob for name
+func (s *Azure) Put(name string, data []byte, meta Metadata) error {
+	key, u := s.blob(name)
+
+	req, err := http.NewRequest("PUT", u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = int64(len(data))
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+	req.Header.Set("X-Ms-Version", "2019-12-12")
+	req.Header.Set("X-Ms-Blob-Content-Type", meta.contentType(name))
+	if meta.CacheControl != "" {
+		req.Header.Set("X-Ms-Blob-Cache-Control", meta.CacheControl)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("sink: put %s: %s: %s", key, resp.Status, body)
+	}
+	return nil
+}
+
+// Exists reports whether the blob for name exists
+func (s *Azure) Exists(name string) (bool, error) {
+	key, u := s.blob(name)
+	req, err := http.NewRequest("HEAD", u, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("X-Ms-Version", "2019-12-12")
+	return exists(s.Client, req, key)
+}
+
+// Get downloads the blob for name
+func (s *Azure) Get(name string) ([]byte, error) {
+	key, u := s.blob(name)
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Ms-Version", "2019-12-12")
+	return get(s.Client, req, key)
+}
+
+// blob returns the name and URL, with the SAS, of the blob for name
+func (s *Azure) blob(name string) (key, u string) {
+	key = name
+	if s.Prefix != "" {
+		key = s.Prefix + "/" + key
+	}
+	return key, strings.TrimSuffix(s.Endpoint, "/") + "/" + s.Container + "/" + uriEncode(key, false) + "?" + s.SAS
+}
@@ -0,0 +1,107 @@
+package sink
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// GCS writes objects to a Google Cloud Storage bucket, under a prefix, using
+// the XML API with an OAuth access token.
+type GCS struct {
+	Bucket string
+	Prefix string
+	// Endpoint overrides https://storage.googleapis.com, e.g. for an emulator
+	Endpoint string
+	Token    string
+	Client   *http.Client
+}
+
+// NewGCS returns a sink writing under a gs://bucket/prefix URL. The access
+// token is read from the GOOGLE_OAUTH_ACCESS_TOKEN environment variable, which
+// can be set with
+//
+//	export GOOGLE_OAUTH_ACCESS_TOKEN=$(gcloud auth print-access-token)
+func NewGCS(rawurl string) (*GCS, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "gs" || u.Host == "" {
+		return nil, fmt.Errorf("sink: invalid gs url %q", rawurl)
+	}
+	s := &GCS{
+		Bucket:   u.Host,
+		Prefix:   strings.Trim(u.Path, "/"),
+		Endpoint: "https://storage.googleapis.com",
+		Token:    os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"),
+		Client:   http.DefaultClient,
+	}
+	if s.Token == "" {
+		return nil, errors.New("sink: GOOGLE_OAUTH_ACCESS_TOKEN must be set to write to gcs")
+	}
+	return s, nil
+}
+
+// Put uploads the data as the object for name
+func (s *GCS) Put(name string, data []byte, meta Metadata) error {
+	key, u := s.object(name)
+
+	req, err := http.NewRequest("PUT", u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = int64(len(data))
+	req.Header.Set("Authorization", "Bearer "+s.Token)
+	req.Header.Set("Content-Type", meta.contentType(name))
+	if meta.CacheControl != "" {
+		req.Header.Set("Cache-Control", meta.CacheControl)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("sink: put %s: %s: %s", key, resp.Status, body)
+	}
+	return nil
+}
+
+// Exists reports whether the object for name exists
+func (s *GCS) Exists(name string) (bool, error) {
+	key, u := s.object(name)
+	req, err := http.NewRequest("HEAD", u, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.Token)
+	return exists(s.Client, req, key)
+}
+
+// Get downloads the object for name
+func (s *GCS) Get(name string) ([]byte, error) {
+	key, u := s.object(name)
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.Token)
+	return get(s.Client, req, key)
+}
+
+// object returns the key and URL of the object for name
+func (s *GCS) object(name string) (key, u string) {
+	key = name
+	if s.Prefix != "" {
+		key = s.Prefix + "/" + key
+	}
+	return key, strings.TrimSuffix(s.Endpoint, "/") + "/" + s.Bucket + "/" + uriEncode(key, false)
+}
@@ -0,0 +1,226 @@
+package sink
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 writes objects to an S3 bucket, under a prefix. Requests are signed with
+// AWS signature version 4.
+type S3 struct {
+	Bucket string
+	Prefix string
+	Region string
+	// Endpoint overrides the AWS endpoint, e.g. for MinIO. Buckets are
+	// addressed by path on custom endpoints.
+	Endpoint        string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Client          *http.Client
+}
+
+// NewS3 returns a sink writing under an s3://bucket/prefix URL. The region,
+// credentials and endpoint are read from the standard AWS_REGION,
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and
+// AWS_ENDPOINT_URL environment variables.
+func NewS3(rawurl string) (*S3, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "s3" || u.Host == "" {
+		return nil, fmt.Errorf("sink: invalid s3 url %q", rawurl)
+	}
+	s := &S3{
+		Bucket:          u.Host,
+		Prefix:          strings.Trim(u.Path, "/"),
+		Region:          os.Getenv("AWS_REGION"),
+		Endpoint:        os.Getenv("AWS_ENDPOINT_URL"),
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		Client:          http.DefaultClient,
+	}
+	if s.Region == "" {
+		s.Region = "us-east-1"
+	}
+	if s.AccessKeyID == "" || s.SecretAccessKey == "" {
+		return nil, errors.New("sink: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set to write to s3")
+	}
+	return s, nil
+}
+
+// Put uploads the data as the object for name
+func (s *S3) Put(name string, data []byte, meta Metadata) error {
+	key, u := s.object(name)
+	req, err := http.NewRequest("PUT", u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = int64(len(data))
+	req.Header.Set("Content-Type", meta.contentType(name))
+	if meta.CacheControl != "" {
+		req.Header.Set("Cache-Control", meta.CacheControl)
+	}
+	sum := sha256.Sum256(data)
+	payloadHash := hex.EncodeToString(sum[:])
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	signV4(req, payloadHash, s.AccessKeyID, s.SecretAccessKey, s.Region, "s3", time.Now())
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("sink: put %s: %s: %s", key, resp.Status, body)
+	}
+	return nil
+}
+
+// Exists reports whether the object for name exists
+func (s *S3) Exists(name string) (bool, error) {
+	key, req, err := s.request("HEAD", name)
+	if err != nil {
+		return false, err
+	}
+	return exists(s.Client, req, key)
+}
+
+// Get downloads the object for name
+func (s *S3) Get(name string) ([]byte, error) {
+	key, req, err := s.request("GET", name)
+	if err != nil {
+		return nil, err
+	}
+	return get(s.Client, req, key)
+}
+
+// request returns a signed request without a body for the object for name
+func (s *S3) request(method, name string) (key string, req *http.Request, err error) {
+	key, u := s.object(name)
+	req, err = http.NewRequest(method, u, nil)
+	if err != nil {
+		return key, nil, err
+	}
+	sum := sha256.Sum256(nil)
+	payloadHash := hex.EncodeToString(sum[:])
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	signV4(req, payloadHash, s.AccessKeyID, s.SecretAccessKey, s.Region, "s3", time.Now())
+	return key, req, nil
+}
+
+// object returns the key and URL of the object for name
+func (s *S3) object(name string) (key, u string) {
+	key = name
+	if s.Prefix != "" {
+		key = s.Prefix + "/" + key
+	}
+	if s.Endpoint != "" {
+		return key, strings.TrimSuffix(s.Endpoint, "/") + "/" + s.Bucket + "/" + uriEncode(key, false)
+	}
+	return key, fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.Bucket, s.Region, uriEncode(key, false))
+}
+
+// signV4 adds the AWS signature version 4 Authorization header to req, signing
+// the host and every header already set on it. See
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func signV4(req *http.Request, payloadHash, accessKeyID, secretAccessKey, region, service string, t time.Time) {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	query := req.URL.Query()
+	var params []string
+	for name, values := range query {
+		for _, v := range values {
+			params = append(params, uriEncode(name, true)+"="+uriEncode(v, true))
+		}
+	}
+	sort.Strings(params)
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		strings.Join(params, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// uriEncode percent encodes everything but unreserved characters, and slashes
+// unless encodeSlash is set, as AWS signing requires
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
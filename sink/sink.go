@@ -0,0 +1,177 @@
+// Package sink writes output files to, and reads them back from, a local
+// directory or object storage (S3, Google Cloud Storage or Azure Blob
+// Storage), so rendered products can be published directly to a bucket
+// serving them.
+package sink
+
+import (
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Sink is where output files are written to. Names are slash separated paths
+// relative to the root of the sink, e.g. "frames/KTLX.png" or "7/29/50.png".
+type Sink interface {
+	Put(name string, data []byte, meta Metadata) error
+	// Exists reports whether a file has been written for name, e.g. to skip
+	// outputs that were already written
+	Exists(name string) (bool, error)
+}
+
+// Storage is a Sink that files can also be read back from, e.g. to serve or
+// update what was written. Every backend Open returns is one.
+type Storage interface {
+	Sink
+	// Get returns the file for name. Files that don't exist return an error
+	// satisfying os.IsNotExist.
+	Get(name string) ([]byte, error)
+}
+
+// Metadata is sent with objects written to object storage. Directories
+// ignore it.
+type Metadata struct {
+	// ContentType defaults to the type for the name's extension
+	ContentType string
+	// CacheControl is the Cache-Control header objects are served with, e.g.
+	// "public, max-age=300". It's left unset if empty.
+	CacheControl string
+}
+
+// Open returns the storage for dest, an s3://bucket/prefix, gs://bucket/prefix
+// or azure://account/container/prefix URL or a local directory
+func Open(dest string) (Storage, error) {
+	switch {
+	case strings.HasPrefix(dest, "s3://"):
+		return NewS3(dest)
+	case strings.HasPrefix(dest, "gs://"):
+		return NewGCS(dest)
+	case strings.HasPrefix(dest, "azure://"):
+		return NewAzure(dest)
+	}
+	return NewDir(dest), nil
+}
+
+// IsRemote reports whether dest is an object storage URL
+func IsRemote(dest string) bool {
+	return strings.HasPrefix(dest, "s3://") || strings.HasPrefix(dest, "gs://") || strings.HasPrefix(dest, "azure://")
+}
+
+// Split splits the path or URL of a single output file into the sink it's
+// written to and its name there
+func Split(dest string) (dir, name string) {
+	if IsRemote(dest) {
+		dir, name = path.Split(dest)
+	} else {
+		dir, name = filepath.Split(dest)
+	}
+	if dir == "" {
+		dir = "."
+	}
+	return dir, name
+}
+
+var contentTypes = map[string]string{
+	".png":     "image/png",
+	".jpg":     "image/jpeg",
+	".jpeg":    "image/jpeg",
+	".svg":     "image/svg+xml",
+	".tif":     "image/tiff",
+	".tiff":    "image/tiff",
+	".geojson": "application/geo+json",
+	".json":    "application/json",
+	".txt":     "text/plain; charset=utf-8",
+	".grib2":   "application/octet-stream",
+	".nc":      "application/x-netcdf",
+}
+
+// ContentType returns the content type for the file name's extension
+func ContentType(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if t, ok := contentTypes[ext]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// contentType returns the content type to upload name with
+func (m Metadata) contentType(name string) string {
+	if m.ContentType != "" {
+		return m.ContentType
+	}
+	return ContentType(name)
+}
+
+// Dir writes files under a local directory
+type Dir struct {
+	Path string
+}
+
+// NewDir returns a sink writing to dir, which is created if needed
+func NewDir(dir string) *Dir {
+	return &Dir{Path: dir}
+}
+
+// Put writes the data to the file for name
+func (d *Dir) Put(name string, data []byte, meta Metadata) error {
+	p := filepath.Join(d.Path, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p, data, 0644)
+}
+
+// Exists reports whether the file for name exists
+func (d *Dir) Exists(name string) (bool, error) {
+	_, err := os.Stat(filepath.Join(d.Path, filepath.FromSlash(name)))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Get reads the file for name
+func (d *Dir) Get(name string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(d.Path, filepath.FromSlash(name)))
+}
+
+// exists sends the HEAD request for an object in a bucket, reporting whether
+// it exists
+func exists(c *http.Client, req *http.Request, key string) (bool, error) {
+	resp, err := c.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode/100 != 2:
+		return false, fmt.Errorf("sink: head %s: %s", key, resp.Status)
+	}
+	return true, nil
+}
+
+// get sends the GET request for an object in a bucket, returning its contents
+func get(c *http.Client, req *http.Request, key string) ([]byte, error) {
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, &os.PathError{Op: "get", Path: key, Err: os.ErrNotExist}
+	case resp.StatusCode/100 != 2:
+		return nil, fmt.Errorf("sink: get %s: %s", key, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
@@ -0,0 +1,102 @@
+// Package sites is a table of the WSR-88D (NEXRAD) and TDWR radar sites, so
+// radials can be placed on the map without their volume data block. Realtime
+// chunks other than the first of a volume sometimes arrive without it.
+package sites
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+	"github.com/kallsyms/go-nexrad/geo"
+)
+
+// Type is the kind of radar at a site
+type Type int
+
+const (
+	// NEXRAD is a WSR-88D
+	NEXRAD Type = iota
+	// TDWR is a Terminal Doppler Weather Radar, near a major airport
+	TDWR
+)
+
+func (t Type) String() string {
+	if t == TDWR {
+		return "TDWR"
+	}
+	return "NEXRAD"
+}
+
+// Site is a radar site
+type Site struct {
+	ICAO string
+	Name string
+	// State is the two letter state or territory code, or the country for
+	// sites outside the US
+	State     string
+	Type      Type
+	Latitude  float64
+	Longitude float64
+	// Elevation of the ground at the site in meters above sea level
+	Elevation float64
+	// TowerHeight is the height of the antenna above the ground in meters
+	TowerHeight float64
+}
+
+// AntennaHeight returns the height of the antenna in meters above sea level
+func (s Site) AntennaHeight() float64 {
+	return s.Elevation + s.TowerHeight
+}
+
+var byICAO = map[string]Site{}
+
+func init() {
+	for _, s := range table {
+		byICAO[s.ICAO] = s
+	}
+}
+
+// Lookup returns the site with the ICAO identifier, e.g. KTLX
+func Lookup(icao string) (Site, bool) {
+	s, ok := byICAO[strings.ToUpper(strings.TrimSpace(icao))]
+	return s, ok
+}
+
+// All returns every site, ordered by ICAO identifier
+func All() []Site {
+	all := append([]Site(nil), table...)
+	sort.Slice(all, func(i, j int) bool { return all[i].ICAO < all[j].ICAO })
+	return all
+}
+
+// Nearest returns the site of the given type closest to the point, and its
+// distance in meters
+func Nearest(lat, lon float64, t Type) (Site, float64) {
+	var nearest Site
+	min := -1.0
+	for _, s := range table {
+		if s.Type != t {
+			continue
+		}
+		if _, d := geo.BearingDistance(lat, lon, s.Latitude, s.Longitude); min < 0 || d < min {
+			nearest, min = s, d
+		}
+	}
+	return nearest, min
+}
+
+// Locate returns the position of the radar that collected the radial, and
+// the height of its antenna in meters above sea level. The radial's volume
+// data block is used if it has one, otherwise the site is looked up by the
+// radar identifier. ok is false if neither is available.
+func Locate(m31 *archive2.Message31) (lat, lon, height float64, ok bool) {
+	if v := m31.VolumeData; v.Lat != 0 || v.Long != 0 {
+		return float64(v.Lat), float64(v.Long), float64(v.SiteHeight) + float64(v.FeedhornHeight), true
+	}
+	s, ok := Lookup(string(m31.Header.RadarIdentifier[:]))
+	if !ok {
+		return 0, 0, 0, false
+	}
+	return s.Latitude, s.Longitude, s.AntennaHeight(), true
+}
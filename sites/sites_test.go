@@ -0,0 +1,77 @@
+package sites
+
+import (
+	"testing"
+
+	"github.com/kallsyms/go-nexrad/archive2"
+)
+
+func TestTable(t *testing.T) {
+	seen := map[string]bool{}
+	for _, s := range table {
+		if len(s.ICAO) != 4 || seen[s.ICAO] {
+			t.Errorf("bad or duplicate ICAO %q", s.ICAO)
+		}
+		seen[s.ICAO] = true
+		if s.Latitude < -90 || s.Latitude > 90 || s.Longitude < -180 || s.Longitude > 180 {
+			t.Errorf("%s: bad position %v, %v", s.ICAO, s.Latitude, s.Longitude)
+		}
+		if (s.Type == TDWR) != (s.ICAO[0] == 'T' && s.ICAO != "TJUA") {
+			t.Errorf("%s: unexpected type %s", s.ICAO, s.Type)
+		}
+	}
+
+	all := All()
+	if len(all) != len(table) {
+		t.Fatalf("expected %d sites, got %d", len(table), len(all))
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i-1].ICAO >= all[i].ICAO {
+			t.Fatalf("sites not ordered: %s before %s", all[i-1].ICAO, all[i].ICAO)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	s, ok := Lookup("ktlx")
+	if !ok || s.Name != "Oklahoma City" || s.Type != NEXRAD {
+		t.Fatalf("unexpected KTLX %+v", s)
+	}
+	if _, ok := Lookup("XXXX"); ok {
+		t.Error("expected no XXXX site")
+	}
+}
+
+func TestNearest(t *testing.T) {
+	// downtown Oklahoma City
+	if s, d := Nearest(35.47, -97.52, NEXRAD); s.ICAO != "KTLX" || d > 30000 {
+		t.Errorf("expected KTLX nearby, got %s at %v m", s.ICAO, d)
+	}
+	if s, _ := Nearest(35.47, -97.52, TDWR); s.ICAO != "TOKC" {
+		t.Errorf("expected TOKC, got %s", s.ICAO)
+	}
+}
+
+func TestLocate(t *testing.T) {
+	m31 := &archive2.Message31{}
+	copy(m31.Header.RadarIdentifier[:], "KCRP")
+	lat, lon, height, ok := Locate(m31)
+	crp, _ := Lookup("KCRP")
+	if !ok || lat != crp.Latitude || lon != crp.Longitude || height != crp.AntennaHeight() {
+		t.Errorf("expected the KCRP site without volume data, got %v, %v, %v", lat, lon, height)
+	}
+
+	m31.VolumeData.Lat = 27.78
+	m31.VolumeData.Long = -97.51
+	m31.VolumeData.SiteHeight = 13
+	m31.VolumeData.FeedhornHeight = 20
+	if lat, lon, height, ok := Locate(m31); !ok || lat != float64(float32(27.78)) || lon != float64(float32(-97.51)) || height != 33 {
+		t.Errorf("expected the volume data position, got %v, %v, %v", lat, lon, height)
+	}
+
+	copy(m31.Header.RadarIdentifier[:], "XXXX")
+	m31.VolumeData = archive2.VolumeData{}
+	if _, _, _, ok := Locate(m31); ok {
+		t.Error("expected an unknown radar without volume data not to be located")
+	}
+}
@@ -0,0 +1,214 @@
+package sites
+
+// table holds every site. Tower heights are the common 20 m WSR-88D and 17 m
+// TDWR towers; radials' volume data blocks have the exact feedhorn height.
+var table = []Site{
+	// WSR-88D
+	{"KABR", "Aberdeen", "SD", NEXRAD, 45.4558, -98.4132, 397, 20},
+	{"KABX", "Albuquerque", "NM", NEXRAD, 35.1497, -106.8239, 1789, 20},
+	{"KAKQ", "Wakefield", "VA", NEXRAD, 36.9841, -77.0074, 34, 20},
+	{"KAMA", "Amarillo", "TX", NEXRAD, 35.2334, -101.7092, 1093, 20},
+	{"KAMX", "Miami", "FL", NEXRAD, 25.6111, -80.4128, 4, 20},
+	{"KAPX", "Gaylord", "MI", NEXRAD, 44.9072, -84.7198, 446, 20},
+	{"KARX", "La Crosse", "WI", NEXRAD, 43.8228, -91.1911, 389, 20},
+	{"KATX", "Seattle", "WA", NEXRAD, 48.1946, -122.4958, 151, 20},
+	{"KBBX", "Beale AFB", "CA", NEXRAD, 39.4961, -121.6317, 53, 20},
+	{"KBGM", "Binghamton", "NY", NEXRAD, 42.1997, -75.9847, 490, 20},
+	{"KBHX", "Eureka", "CA", NEXRAD, 40.4985, -124.2922, 732, 20},
+	{"KBIS", "Bismarck", "ND", NEXRAD, 46.7709, -100.7605, 505, 20},
+	{"KBIX", "Keesler AFB", "MS", NEXRAD, 30.5239, -88.9847, 38, 20},
+	{"KBLX", "Billings", "MT", NEXRAD, 45.8538, -108.6068, 1097, 20},
+	{"KBMX", "Birmingham", "AL", NEXRAD, 33.1722, -86.7697, 197, 20},
+	{"KBOX", "Boston", "MA", NEXRAD, 41.9558, -71.1369, 36, 20},
+	{"KBRO", "Brownsville", "TX", NEXRAD, 25.9160, -97.4189, 7, 20},
+	{"KBUF", "Buffalo", "NY", NEXRAD, 42.9488, -78.7369, 211, 20},
+	{"KBYX", "Key West", "FL", NEXRAD, 24.5975, -81.7031, 2, 20},
+	{"KCAE", "Columbia", "SC", NEXRAD, 33.9487, -81.1184, 70, 20},
+	{"KCBW", "Caribou", "ME", NEXRAD, 46.0391, -67.8066, 227, 20},
+	{"KCBX", "Boise", "ID", NEXRAD, 43.4902, -116.2360, 933, 20},
+	{"KCCX", "State College", "PA", NEXRAD, 40.9231, -78.0039, 733, 20},
+	{"KCLE", "Cleveland", "OH", NEXRAD, 41.4132, -81.8598, 233, 20},
+	{"KCLX", "Charleston", "SC", NEXRAD, 32.6555, -81.0423, 30, 20},
+	{"KCRP", "Corpus Christi", "TX", NEXRAD, 27.7840, -97.5112, 14, 20},
+	{"KCXX", "Burlington", "VT", NEXRAD, 44.5111, -73.1664, 97, 20},
+	{"KCYS", "Cheyenne", "WY", NEXRAD, 41.1519, -104.8061, 1868, 20},
+	{"KDAX", "Sacramento", "CA", NEXRAD, 38.5011, -121.6778, 9, 20},
+	{"KDDC", "Dodge City", "KS", NEXRAD, 37.7608, -99.9689, 789, 20},
+	{"KDFX", "Laughlin AFB", "TX", NEXRAD, 29.2731, -100.2806, 345, 20},
+	{"KDGX", "Jackson/Brandon", "MS", NEXRAD, 32.2800, -89.9844, 151, 20},
+	{"KDIX", "Philadelphia", "NJ", NEXRAD, 39.9469, -74.4108, 45, 20},
+	{"KDLH", "Duluth", "MN", NEXRAD, 46.8369, -92.2097, 435, 20},
+	{"KDMX", "Des Moines", "IA", NEXRAD, 41.7311, -93.7228, 299, 20},
+	{"KDOX", "Dover AFB", "DE", NEXRAD, 38.8258, -75.4400, 15, 20},
+	{"KDTX", "Detroit", "MI", NEXRAD, 42.6999, -83.4718, 327, 20},
+	{"KDVN", "Davenport", "IA", NEXRAD, 41.6117, -90.5808, 230, 20},
+	{"KDYX", "Dyess AFB", "TX", NEXRAD, 32.5386, -99.2542, 462, 20},
+	{"KEAX", "Kansas City", "MO", NEXRAD, 38.8103, -94.2644, 303, 20},
+	{"KEMX", "Tucson", "AZ", NEXRAD, 31.8936, -110.6303, 1586, 20},
+	{"KENX", "Albany", "NY", NEXRAD, 42.5864, -74.0639, 557, 20},
+	{"KEOX", "Fort Rucker", "AL", NEXRAD, 31.4606, -85.4594, 132, 20},
+	{"KEPZ", "El Paso", "NM", NEXRAD, 31.8731, -106.6978, 1251, 20},
+	{"KESX", "Las Vegas", "NV", NEXRAD, 35.7011, -114.8914, 1483, 20},
+	{"KEVX", "Eglin AFB", "FL", NEXRAD, 30.5644, -85.9214, 43, 20},
+	{"KEWX", "Austin/San Antonio", "TX", NEXRAD, 29.7039, -98.0286, 193, 20},
+	{"KEYX", "Edwards AFB", "CA", NEXRAD, 35.0978, -117.5608, 840, 20},
+	{"KFCX", "Roanoke", "VA", NEXRAD, 37.0244, -80.2739, 874, 20},
+	{"KFDR", "Frederick", "OK", NEXRAD, 34.3622, -98.9764, 386, 20},
+	{"KFDX", "Cannon AFB", "NM", NEXRAD, 34.6342, -103.6189, 1417, 20},
+	{"KFFC", "Atlanta", "GA", NEXRAD, 33.3636, -84.5658, 262, 20},
+	{"KFSD", "Sioux Falls", "SD", NEXRAD, 43.5878, -96.7294, 436, 20},
+	{"KFSX", "Flagstaff", "AZ", NEXRAD, 34.5744, -111.1981, 2261, 20},
+	{"KFTG", "Denver", "CO", NEXRAD, 39.7867, -104.5458, 1675, 20},
+	{"KFWS", "Dallas/Fort Worth", "TX", NEXRAD, 32.5731, -97.3031, 208, 20},
+	{"KGGW", "Glasgow", "MT", NEXRAD, 48.2064, -106.6253, 694, 20},
+	{"KGJX", "Grand Junction", "CO", NEXRAD, 39.0622, -108.2139, 3046, 20},
+	{"KGLD", "Goodland", "KS", NEXRAD, 39.3667, -101.7003, 1113, 20},
+	{"KGRB", "Green Bay", "WI", NEXRAD, 44.4986, -88.1114, 208, 20},
+	{"KGRK", "Fort Hood", "TX", NEXRAD, 30.7219, -97.3831, 164, 20},
+	{"KGRR", "Grand Rapids", "MI", NEXRAD, 42.8939, -85.5447, 237, 20},
+	{"KGSP", "Greer", "SC", NEXRAD, 34.8833, -82.2200, 287, 20},
+	{"KGWX", "Columbus AFB", "MS", NEXRAD, 33.8967, -88.3289, 145, 20},
+	{"KGYX", "Portland", "ME", NEXRAD, 43.8914, -70.2567, 125, 20},
+	{"KHDX", "Holloman AFB", "NM", NEXRAD, 33.0764, -106.1228, 1287, 20},
+	{"KHGX", "Houston/Galveston", "TX", NEXRAD, 29.4719, -95.0792, 5, 20},
+	{"KHNX", "San Joaquin Valley", "CA", NEXRAD, 36.3142, -119.6319, 74, 20},
+	{"KHPX", "Fort Campbell", "KY", NEXRAD, 36.7367, -87.2850, 176, 20},
+	{"KHTX", "Huntsville", "AL", NEXRAD, 34.9306, -86.0833, 536, 20},
+	{"KICT", "Wichita", "KS", NEXRAD, 37.6544, -97.4428, 407, 20},
+	{"KICX", "Cedar City", "UT", NEXRAD, 37.5908, -112.8622, 3231, 20},
+	{"KILN", "Wilmington", "OH", NEXRAD, 39.4203, -83.8217, 322, 20},
+	{"KILX", "Lincoln", "IL", NEXRAD, 40.1506, -89.3369, 177, 20},
+	{"KIND", "Indianapolis", "IN", NEXRAD, 39.7075, -86.2803, 241, 20},
+	{"KINX", "Tulsa", "OK", NEXRAD, 36.1750, -95.5647, 204, 20},
+	{"KIWA", "Phoenix", "AZ", NEXRAD, 33.2892, -111.6700, 412, 20},
+	{"KIWX", "Northern Indiana", "IN", NEXRAD, 41.3586, -85.7000, 293, 20},
+	{"KJAX", "Jacksonville", "FL", NEXRAD, 30.4847, -81.7019, 10, 20},
+	{"KJGX", "Robins AFB", "GA", NEXRAD, 32.6753, -83.3511, 159, 20},
+	{"KJKL", "Jackson", "KY", NEXRAD, 37.5908, -83.3131, 416, 20},
+	{"KLBB", "Lubbock", "TX", NEXRAD, 33.6539, -101.8142, 993, 20},
+	{"KLCH", "Lake Charles", "LA", NEXRAD, 30.1253, -93.2158, 4, 20},
+	{"KLGX", "Langley Hill", "WA", NEXRAD, 47.1169, -124.1067, 112, 20},
+	{"KLIX", "New Orleans", "LA", NEXRAD, 30.3367, -89.8256, 7, 20},
+	{"KLNX", "North Platte", "NE", NEXRAD, 41.9578, -100.5761, 905, 20},
+	{"KLOT", "Chicago", "IL", NEXRAD, 41.6044, -88.0847, 202, 20},
+	{"KLRX", "Elko", "NV", NEXRAD, 40.7397, -116.8028, 2056, 20},
+	{"KLSX", "St. Louis", "MO", NEXRAD, 38.6989, -90.6828, 185, 20},
+	{"KLTX", "Wilmington", "NC", NEXRAD, 33.9894, -78.4289, 20, 20},
+	{"KLVX", "Louisville", "KY", NEXRAD, 37.9753, -85.9439, 219, 20},
+	{"KLWX", "Sterling", "VA", NEXRAD, 38.9753, -77.4778, 83, 20},
+	{"KLZK", "Little Rock", "AR", NEXRAD, 34.8364, -92.2622, 173, 20},
+	{"KMAF", "Midland/Odessa", "TX", NEXRAD, 31.9433, -102.1892, 874, 20},
+	{"KMAX", "Medford", "OR", NEXRAD, 42.0811, -122.7172, 2290, 20},
+	{"KMBX", "Minot AFB", "ND", NEXRAD, 48.3925, -100.8644, 455, 20},
+	{"KMHX", "Morehead City", "NC", NEXRAD, 34.7761, -76.8761, 9, 20},
+	{"KMKX", "Milwaukee", "WI", NEXRAD, 42.9678, -88.5506, 292, 20},
+	{"KMLB", "Melbourne", "FL", NEXRAD, 28.1133, -80.6542, 11, 20},
+	{"KMOB", "Mobile", "AL", NEXRAD, 30.6794, -88.2397, 63, 20},
+	{"KMPX", "Minneapolis", "MN", NEXRAD, 44.8489, -93.5656, 288, 20},
+	{"KMQT", "Marquette", "MI", NEXRAD, 46.5311, -87.5483, 430, 20},
+	{"KMRX", "Knoxville", "TN", NEXRAD, 36.1686, -83.4017, 408, 20},
+	{"KMSX", "Missoula", "MT", NEXRAD, 47.0411, -113.9864, 2394, 20},
+	{"KMTX", "Salt Lake City", "UT", NEXRAD, 41.2628, -112.4478, 1969, 20},
+	{"KMUX", "San Francisco", "CA", NEXRAD, 37.1553, -121.8983, 1057, 20},
+	{"KMVX", "Grand Forks", "ND", NEXRAD, 47.5278, -97.3253, 301, 20},
+	{"KMXX", "Maxwell AFB", "AL", NEXRAD, 32.5367, -85.7897, 122, 20},
+	{"KNKX", "San Diego", "CA", NEXRAD, 32.9189, -117.0419, 291, 20},
+	{"KNQA", "Memphis", "TN", NEXRAD, 35.3447, -89.8733, 86, 20},
+	{"KOAX", "Omaha", "NE", NEXRAD, 41.3203, -96.3667, 350, 20},
+	{"KOHX", "Nashville", "TN", NEXRAD, 36.2472, -86.5625, 176, 20},
+	{"KOKX", "New York City", "NY", NEXRAD, 40.8656, -72.8639, 26, 20},
+	{"KOTX", "Spokane", "WA", NEXRAD, 47.6803, -117.6267, 727, 20},
+	{"KPAH", "Paducah", "KY", NEXRAD, 37.0683, -88.7719, 119, 20},
+	{"KPBZ", "Pittsburgh", "PA", NEXRAD, 40.5317, -80.2183, 361, 20},
+	{"KPDT", "Pendleton", "OR", NEXRAD, 45.6906, -118.8528, 462, 20},
+	{"KPOE", "Fort Polk", "LA", NEXRAD, 31.1556, -92.9758, 124, 20},
+	{"KPUX", "Pueblo", "CO", NEXRAD, 38.4594, -104.1814, 1600, 20},
+	{"KRAX", "Raleigh/Durham", "NC", NEXRAD, 35.6656, -78.4897, 106, 20},
+	{"KRGX", "Reno", "NV", NEXRAD, 39.7542, -119.4622, 2530, 20},
+	{"KRIW", "Riverton", "WY", NEXRAD, 43.0661, -108.4772, 1697, 20},
+	{"KRLX", "Charleston", "WV", NEXRAD, 38.3111, -81.7231, 329, 20},
+	{"KRTX", "Portland", "OR", NEXRAD, 45.7150, -122.9653, 479, 20},
+	{"KSFX", "Pocatello/Idaho Falls", "ID", NEXRAD, 43.1056, -112.6861, 1364, 20},
+	{"KSGF", "Springfield", "MO", NEXRAD, 37.2353, -93.4006, 390, 20},
+	{"KSHV", "Shreveport", "LA", NEXRAD, 32.4508, -93.8414, 83, 20},
+	{"KSJT", "San Angelo", "TX", NEXRAD, 31.3711, -100.4925, 576, 20},
+	{"KSOX", "Santa Ana Mountains", "CA", NEXRAD, 33.8178, -117.6358, 923, 20},
+	{"KSRX", "Fort Smith", "AR", NEXRAD, 35.2903, -94.3619, 195, 20},
+	{"KTBW", "Tampa", "FL", NEXRAD, 27.7056, -82.4017, 12, 20},
+	{"KTFX", "Great Falls", "MT", NEXRAD, 47.4597, -111.3853, 1132, 20},
+	{"KTLH", "Tallahassee", "FL", NEXRAD, 30.3975, -84.3289, 19, 20},
+	{"KTLX", "Oklahoma City", "OK", NEXRAD, 35.3331, -97.2778, 370, 20},
+	{"KTWX", "Topeka", "KS", NEXRAD, 38.9969, -96.2325, 417, 20},
+	{"KTYX", "Montague", "NY", NEXRAD, 43.7558, -75.6800, 563, 20},
+	{"KUDX", "Rapid City", "SD", NEXRAD, 44.1250, -102.8297, 919, 20},
+	{"KUEX", "Hastings", "NE", NEXRAD, 40.3208, -98.4419, 602, 20},
+	{"KVAX", "Moody AFB", "GA", NEXRAD, 30.8903, -83.0017, 54, 20},
+	{"KVBX", "Vandenberg AFB", "CA", NEXRAD, 34.8383, -120.3975, 376, 20},
+	{"KVNX", "Vance AFB", "OK", NEXRAD, 36.7406, -98.1278, 369, 20},
+	{"KVTX", "Los Angeles", "CA", NEXRAD, 34.4117, -119.1794, 831, 20},
+	{"KVWX", "Evansville", "IN", NEXRAD, 38.2603, -87.7247, 190, 20},
+	{"KYUX", "Yuma", "AZ", NEXRAD, 32.4953, -114.6567, 53, 20},
+	{"PABC", "Bethel", "AK", NEXRAD, 60.7919, -161.8764, 49, 20},
+	{"PACG", "Sitka", "AK", NEXRAD, 56.8528, -135.5292, 63, 20},
+	{"PAEC", "Nome", "AK", NEXRAD, 64.5114, -165.2950, 16, 20},
+	{"PAHG", "Anchorage", "AK", NEXRAD, 60.7259, -151.3514, 73, 20},
+	{"PAIH", "Middleton Island", "AK", NEXRAD, 59.4614, -146.3031, 20, 20},
+	{"PAKC", "King Salmon", "AK", NEXRAD, 58.6794, -156.6294, 19, 20},
+	{"PAPD", "Fairbanks", "AK", NEXRAD, 65.0350, -147.5017, 790, 20},
+	{"PGUA", "Andersen AFB", "GU", NEXRAD, 13.4558, 144.8111, 80, 20},
+	{"PHKI", "South Kauai", "HI", NEXRAD, 21.8939, -159.5525, 55, 20},
+	{"PHKM", "Kohala", "HI", NEXRAD, 20.1256, -155.7781, 1162, 20},
+	{"PHMO", "Molokai", "HI", NEXRAD, 21.1328, -157.1803, 415, 20},
+	{"PHWA", "South Shore", "HI", NEXRAD, 19.0950, -155.5689, 421, 20},
+	{"RKJK", "Kunsan AB", "KR", NEXRAD, 35.9242, 126.6222, 24, 20},
+	{"RKSG", "Camp Humphreys", "KR", NEXRAD, 36.9558, 127.0211, 16, 20},
+	{"RODN", "Kadena AB", "JP", NEXRAD, 26.3019, 127.9097, 66, 20},
+	{"TJUA", "San Juan", "PR", NEXRAD, 18.1156, -66.0781, 852, 20},
+
+	// TDWR
+	{"TADW", "Andrews AFB", "MD", TDWR, 38.695, -76.845, 76, 17},
+	{"TATL", "Atlanta", "GA", TDWR, 33.647, -84.262, 295, 17},
+	{"TBNA", "Nashville", "TN", TDWR, 35.980, -86.662, 222, 17},
+	{"TBOS", "Boston", "MA", TDWR, 42.158, -70.933, 80, 17},
+	{"TBWI", "Baltimore/Washington", "MD", TDWR, 39.090, -76.630, 56, 17},
+	{"TCLT", "Charlotte", "NC", TDWR, 35.337, -80.885, 230, 17},
+	{"TCMH", "Columbus", "OH", TDWR, 40.006, -82.715, 313, 17},
+	{"TCVG", "Cincinnati", "KY", TDWR, 38.898, -84.580, 290, 17},
+	{"TDAL", "Dallas Love Field", "TX", TDWR, 32.926, -96.968, 164, 17},
+	{"TDAY", "Dayton", "OH", TDWR, 40.022, -84.123, 307, 17},
+	{"TDCA", "Washington National", "MD", TDWR, 38.759, -76.962, 102, 17},
+	{"TDEN", "Denver", "CO", TDWR, 39.728, -104.526, 1692, 17},
+	{"TDFW", "Dallas/Fort Worth", "TX", TDWR, 33.065, -96.918, 181, 17},
+	{"TDTW", "Detroit", "MI", TDWR, 42.111, -83.515, 204, 17},
+	{"TEWR", "Newark", "NJ", TDWR, 40.593, -74.270, 17, 17},
+	{"TFLL", "Fort Lauderdale", "FL", TDWR, 26.143, -80.344, 4, 17},
+	{"THOU", "Houston Hobby", "TX", TDWR, 29.516, -95.242, 13, 17},
+	{"TIAD", "Washington Dulles", "VA", TDWR, 39.084, -77.529, 112, 17},
+	{"TIAH", "Houston Intercontinental", "TX", TDWR, 30.065, -95.567, 47, 17},
+	{"TICH", "Wichita", "KS", TDWR, 37.507, -97.437, 384, 17},
+	{"TIDS", "Indianapolis", "IN", TDWR, 39.637, -86.436, 226, 17},
+	{"TJFK", "New York JFK", "NY", TDWR, 40.589, -73.881, 7, 17},
+	{"TLAS", "Las Vegas", "NV", TDWR, 36.144, -115.007, 594, 17},
+	{"TLVE", "Cleveland", "OH", TDWR, 41.290, -82.008, 252, 17},
+	{"TMCI", "Kansas City", "MO", TDWR, 39.498, -94.742, 326, 17},
+	{"TMCO", "Orlando", "FL", TDWR, 28.344, -81.326, 22, 17},
+	{"TMDW", "Chicago Midway", "IL", TDWR, 41.651, -87.730, 199, 17},
+	{"TMEM", "Memphis", "TN", TDWR, 34.896, -89.993, 115, 17},
+	{"TMIA", "Miami", "FL", TDWR, 25.758, -80.491, 3, 17},
+	{"TMKE", "Milwaukee", "WI", TDWR, 42.819, -88.046, 246, 17},
+	{"TMSP", "Minneapolis", "MN", TDWR, 44.871, -92.933, 291, 17},
+	{"TMSY", "New Orleans", "LA", TDWR, 30.022, -90.403, 1, 17},
+	{"TOKC", "Oklahoma City", "OK", TDWR, 35.276, -97.510, 369, 17},
+	{"TORD", "Chicago O'Hare", "IL", TDWR, 41.797, -87.858, 196, 17},
+	{"TPBI", "West Palm Beach", "FL", TDWR, 26.688, -80.273, 6, 17},
+	{"TPHL", "Philadelphia", "PA", TDWR, 39.949, -75.069, 9, 17},
+	{"TPHX", "Phoenix", "AZ", TDWR, 33.421, -112.163, 318, 17},
+	{"TPIT", "Pittsburgh", "PA", TDWR, 40.501, -80.486, 370, 17},
+	{"TRDU", "Raleigh/Durham", "NC", TDWR, 36.002, -78.697, 105, 17},
+	{"TSDF", "Louisville", "KY", TDWR, 38.046, -85.611, 188, 17},
+	{"TSJU", "San Juan", "PR", TDWR, 18.474, -66.179, 12, 17},
+	{"TSLC", "Salt Lake City", "UT", TDWR, 40.967, -111.930, 1288, 17},
+	{"TSTL", "St. Louis", "MO", TDWR, 38.805, -90.489, 166, 17},
+	{"TTPA", "Tampa", "FL", TDWR, 27.859, -82.518, 4, 17},
+	{"TTUL", "Tulsa", "OK", TDWR, 36.071, -95.827, 217, 17},
+}